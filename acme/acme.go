@@ -35,6 +35,7 @@ type ACME struct {
 	Domains             []Domain `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
 	Storage             string   `description:"File or key used for certificates storage."`
 	StorageFile         string   // deprecated
+	StorageKey          string   `description:"Passphrase encrypting the account and certificates stored in the KV store in cluster mode."`
 	OnDemand            bool     `description:"Enable on demand certificate. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."`
 	OnHostRule          bool     `description:"Enable certificate generation on frontends Host rules."`
 	CAServer            string   `description:"CA server to use."`
@@ -138,14 +139,16 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 		return nil
 	}
 
-	datastore, err := cluster.NewDataStore(
-		leadership.Pool.Ctx(),
-		staert.KvSource{
-			Store:  leadership.Store,
-			Prefix: a.Storage,
-		},
-		&Account{},
-		listener)
+	kvSource := staert.KvSource{
+		Store:  leadership.Store,
+		Prefix: a.Storage,
+	}
+	var datastore *cluster.Datastore
+	if len(a.StorageKey) > 0 {
+		datastore, err = cluster.NewEncryptedDataStore(leadership.Pool.Ctx(), kvSource, a.StorageKey, &Account{}, listener)
+	} else {
+		datastore, err = cluster.NewDataStore(leadership.Pool.Ctx(), kvSource, &Account{}, listener)
+	}
 	if err != nil {
 		return err
 	}
@@ -1,9 +1,14 @@
 package cluster
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -14,11 +19,13 @@ import (
 	"github.com/containous/traefik/safe"
 	"github.com/docker/libkv/store"
 	"github.com/satori/go.uuid"
+	"golang.org/x/crypto/scrypt"
 )
 
 // Metadata stores Object plus metadata
 type Metadata struct {
 	object Object
+	cipher *passphraseCipher
 	Object []byte
 	Lock   string
 }
@@ -28,10 +35,14 @@ func NewMetadata(object Object) *Metadata {
 	return &Metadata{object: object}
 }
 
-// Marshall marshalls object
+// Marshall marshalls object, and encrypts it with the key of the datastore if any
 func (m *Metadata) Marshall() error {
-	var err error
-	m.Object, err = json.Marshal(m.object)
+	content, err := json.Marshal(m.object)
+	if err != nil || m.cipher == nil {
+		m.Object = content
+		return err
+	}
+	m.Object, err = m.cipher.encrypt(content)
 	return err
 }
 
@@ -39,7 +50,101 @@ func (m *Metadata) unmarshall() error {
 	if len(m.Object) == 0 {
 		return nil
 	}
-	return json.Unmarshal(m.Object, m.object)
+	if m.cipher == nil {
+		return json.Unmarshal(m.Object, m.object)
+	}
+	content, err := m.cipher.decrypt(m.Object)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, m.object)
+}
+
+const (
+	saltSize = 16
+	// scrypt parameters recommended for interactive logins
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// passphraseCipher encrypts the objects with AES-256-GCM, with a key derived from the passphrase by scrypt.
+// The random salt of the key is stored before the nonce in each object, and the key of the last salt read
+// is kept to encrypt the next objects, for the key not to be derived again for each object.
+type passphraseCipher struct {
+	passphrase string
+	mutex      sync.Mutex
+	salt       []byte
+	aead       cipher.AEAD
+}
+
+func newPassphraseCipher(passphrase string) (*passphraseCipher, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	c := &passphraseCipher{passphrase: passphrase}
+	if _, err := c.aeadOf(salt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// aeadOf returns the cipher of the key derived with the salt, which becomes the one encrypting the next objects
+func (c *passphraseCipher) aeadOf(salt []byte) (cipher.AEAD, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.aead != nil && bytes.Equal(c.salt, salt) {
+		return c.aead, nil
+	}
+	key, err := scrypt.Key([]byte(c.passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.salt = append([]byte(nil), salt...)
+	c.aead = aead
+	return aead, nil
+}
+
+func (c *passphraseCipher) encrypt(content []byte) ([]byte, error) {
+	c.mutex.Lock()
+	salt, aead := c.salt, c.aead
+	c.mutex.Unlock()
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte(nil), salt...), nonce...)
+	return aead.Seal(sealed, nonce, content, nil), nil
+}
+
+func (c *passphraseCipher) decrypt(object []byte) ([]byte, error) {
+	if len(object) < saltSize {
+		return nil, fmt.Errorf("datastore object too short to be encrypted")
+	}
+	aead, err := c.aeadOf(object[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aead.NonceSize()
+	if len(object) < saltSize+nonceSize {
+		return nil, fmt.Errorf("datastore object too short to be encrypted")
+	}
+	content, err := aead.Open(nil, object[saltSize:saltSize+nonceSize], object[saltSize+nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt the datastore object: %v", err)
+	}
+	return content, nil
 }
 
 // Listener is called when Object has been changed in KV store
@@ -59,10 +164,23 @@ type Datastore struct {
 
 // NewDataStore creates a Datastore
 func NewDataStore(ctx context.Context, kvSource staert.KvSource, object Object, listener Listener) (*Datastore, error) {
+	return newDataStore(ctx, kvSource, &Metadata{object: object}, listener)
+}
+
+// NewEncryptedDataStore creates a Datastore encrypting the object in the KV store with a key derived from the passphrase
+func NewEncryptedDataStore(ctx context.Context, kvSource staert.KvSource, passphrase string, object Object, listener Listener) (*Datastore, error) {
+	passphraseCipher, err := newPassphraseCipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return newDataStore(ctx, kvSource, &Metadata{object: object, cipher: passphraseCipher}, listener)
+}
+
+func newDataStore(ctx context.Context, kvSource staert.KvSource, meta *Metadata, listener Listener) (*Datastore, error) {
 	datastore := Datastore{
 		kv:        kvSource,
 		ctx:       ctx,
-		meta:      &Metadata{object: object},
+		meta:      meta,
 		lockKey:   kvSource.Prefix + "/lock",
 		localLock: &sync.RWMutex{},
 		listener:  listener,
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testObject struct {
+	Value string
+}
+
+func TestMetadataEncryption(t *testing.T) {
+	encrypting, err := newPassphraseCipher("passphrase")
+	require.NoError(t, err)
+	samePassphrase, err := newPassphraseCipher("passphrase")
+	require.NoError(t, err)
+	otherPassphrase, err := newPassphraseCipher("other")
+	require.NoError(t, err)
+
+	meta := &Metadata{object: &testObject{Value: "secret"}, cipher: encrypting}
+	require.NoError(t, meta.Marshall())
+	assert.NotContains(t, string(meta.Object), "secret")
+
+	plain := &Metadata{object: &testObject{Value: "plain"}}
+	require.NoError(t, plain.Marshall())
+
+	tampered := append([]byte(nil), meta.Object...)
+	tampered[len(tampered)-1] ^= 1
+
+	testCases := []struct {
+		desc          string
+		cipher        *passphraseCipher
+		object        []byte
+		expectedError bool
+		expectedValue string
+	}{
+		{
+			desc:          "same cipher",
+			cipher:        encrypting,
+			object:        meta.Object,
+			expectedValue: "secret",
+		},
+		{
+			desc:          "same passphrase with another salt",
+			cipher:        samePassphrase,
+			object:        meta.Object,
+			expectedValue: "secret",
+		},
+		{
+			desc:          "wrong passphrase",
+			cipher:        otherPassphrase,
+			object:        meta.Object,
+			expectedError: true,
+		},
+		{
+			desc:          "tampered object",
+			cipher:        encrypting,
+			object:        tampered,
+			expectedError: true,
+		},
+		{
+			desc:          "object not encrypted",
+			cipher:        encrypting,
+			object:        plain.Object,
+			expectedError: true,
+		},
+		{
+			desc:          "object too short",
+			cipher:        encrypting,
+			object:        []byte("{}"),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			decrypted := &Metadata{object: &testObject{}, cipher: test.cipher, Object: test.object}
+			err := decrypted.unmarshall()
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedValue, decrypted.object.(*testObject).Value)
+		})
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/docker/leadership"
 )
 
+// DefaultLeaderTTL is the default duration of the leader lock in the KV store
+const DefaultLeaderTTL = 20 * time.Second
+
 // Leadership allows leadership election using a KV store
 type Leadership struct {
 	*safe.Pool
@@ -22,10 +25,14 @@ type Leadership struct {
 
 // NewLeadership creates a leadership
 func NewLeadership(ctx context.Context, cluster *types.Cluster) *Leadership {
+	leaderTTL := time.Duration(cluster.LeaderTTL)
+	if leaderTTL <= 0 {
+		leaderTTL = DefaultLeaderTTL
+	}
 	return &Leadership{
 		Pool:      safe.NewPool(ctx),
 		Cluster:   cluster,
-		candidate: leadership.NewCandidate(cluster.Store, cluster.Store.Prefix+"/leader", cluster.Node, 20*time.Second),
+		candidate: leadership.NewCandidate(cluster.Store, cluster.Store.Prefix+"/leader", cluster.Node, leaderTTL),
 		listeners: []LeaderListener{},
 		leader:    safe.New(false),
 	}
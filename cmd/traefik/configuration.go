@@ -1,11 +1,13 @@
 package main
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/containous/flaeg"
 	"github.com/containous/traefik/configuration"
 	"github.com/containous/traefik/middlewares/accesslog"
+	"github.com/containous/traefik/ping"
 	"github.com/containous/traefik/provider/boltdb"
 	"github.com/containous/traefik/provider/consul"
 	"github.com/containous/traefik/provider/docker"
@@ -20,6 +22,7 @@ import (
 	"github.com/containous/traefik/provider/rancher"
 	"github.com/containous/traefik/provider/web"
 	"github.com/containous/traefik/provider/zk"
+	"github.com/containous/traefik/tracing"
 	"github.com/containous/traefik/types"
 )
 
@@ -37,6 +40,7 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	defaultDocker.ExposedByDefault = true
 	defaultDocker.Endpoint = "unix:///var/run/docker.sock"
 	defaultDocker.SwarmMode = false
+	defaultDocker.EventsDebounce = flaeg.Duration(docker.DefaultEventsDebounce)
 
 	// default File
 	var defaultFile file.Provider
@@ -63,6 +67,11 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 			Address:      "localhost:8125",
 			PushInterval: "10s",
 		},
+		InfluxDB: &types.InfluxDB{
+			Address:      "localhost:8089",
+			Protocol:     "udp",
+			PushInterval: "10s",
+		},
 	}
 
 	// default Marathon
@@ -73,6 +82,7 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	defaultMarathon.Constraints = types.Constraints{}
 	defaultMarathon.DialerTimeout = flaeg.Duration(60 * time.Second)
 	defaultMarathon.KeepAlive = flaeg.Duration(10 * time.Second)
+	defaultMarathon.PollInterval = flaeg.Duration(30 * time.Second)
 
 	// default Consul
 	var defaultConsul consul.Provider
@@ -177,7 +187,39 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 
 	// default ForwardingTimeouts
 	forwardingTimeouts := configuration.ForwardingTimeouts{
-		DialTimeout: flaeg.Duration(configuration.DefaultDialTimeout),
+		DialTimeout:     flaeg.Duration(configuration.DefaultDialTimeout),
+		IdleConnTimeout: flaeg.Duration(configuration.DefaultIdleConnTimeout),
+	}
+
+	// default ForwardingTransport
+	forwardingTransport := configuration.ForwardingTransport{
+		KeepAlive: flaeg.Duration(configuration.DefaultKeepAlive),
+	}
+
+	// default Tracing
+	defaultTracing := tracing.Config{
+		Backend:     tracing.OTLPName,
+		ServiceName: "traefik",
+		OTLP: &tracing.OTLPConfig{
+			Endpoint:      "http://localhost:4318",
+			BatchSize:     512,
+			FlushInterval: "5s",
+		},
+		Jaeger: &tracing.JaegerConfig{
+			SamplingType:      tracing.SamplerTypeConst,
+			SamplingParam:     1,
+			CollectorEndpoint: "http://localhost:4318",
+			Propagation:       tracing.JaegerPropagationFormat,
+			BatchSize:         512,
+			FlushInterval:     "5s",
+		},
+		Zipkin: &tracing.ZipkinConfig{
+			HTTPEndpoint:  "http://localhost:9411/api/v2/spans",
+			SampleRate:    1,
+			Propagation:   tracing.B3MultiPropagationFormat,
+			BatchSize:     512,
+			FlushInterval: "5s",
+		},
 	}
 
 	// default LifeCycle
@@ -186,28 +228,33 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	}
 
 	defaultConfiguration := configuration.GlobalConfiguration{
-		Docker:             &defaultDocker,
-		File:               &defaultFile,
-		Web:                &defaultWeb,
-		Marathon:           &defaultMarathon,
-		Consul:             &defaultConsul,
-		ConsulCatalog:      &defaultConsulCatalog,
-		Etcd:               &defaultEtcd,
-		Zookeeper:          &defaultZookeeper,
-		Boltdb:             &defaultBoltDb,
-		Kubernetes:         &defaultKubernetes,
-		Mesos:              &defaultMesos,
-		ECS:                &defaultECS,
-		Rancher:            &defaultRancher,
-		Eureka:             &defaultEureka,
-		DynamoDB:           &defaultDynamoDB,
-		Retry:              &configuration.Retry{},
-		HealthCheck:        &healthCheck,
-		RespondingTimeouts: &respondingTimeouts,
-		ForwardingTimeouts: &forwardingTimeouts,
-		TraefikLog:         &defaultTraefikLog,
-		AccessLog:          &defaultAccessLog,
-		LifeCycle:          &defaultLifeycle,
+		Docker:              &defaultDocker,
+		File:                &defaultFile,
+		Web:                 &defaultWeb,
+		Marathon:            &defaultMarathon,
+		Consul:              &defaultConsul,
+		ConsulCatalog:       &defaultConsulCatalog,
+		Etcd:                &defaultEtcd,
+		Zookeeper:           &defaultZookeeper,
+		Boltdb:              &defaultBoltDb,
+		Kubernetes:          &defaultKubernetes,
+		Mesos:               &defaultMesos,
+		ECS:                 &defaultECS,
+		Rancher:             &defaultRancher,
+		Eureka:              &defaultEureka,
+		DynamoDB:            &defaultDynamoDB,
+		Retry:               &configuration.Retry{},
+		HealthCheck:         &healthCheck,
+		RespondingTimeouts:  &respondingTimeouts,
+		ForwardingTimeouts:  &forwardingTimeouts,
+		ForwardingTransport: &forwardingTransport,
+		TraefikLog:          &defaultTraefikLog,
+		AccessLog:           &defaultAccessLog,
+		LifeCycle:           &defaultLifeycle,
+		Tracing:             &defaultTracing,
+		Ping:                &ping.Handler{EntryPoint: "http"},
+		DefaultBackend:      &types.DefaultBackend{StatusCode: http.StatusNotFound},
+		GeoIP:               &configuration.GeoIP{},
 	}
 
 	return &TraefikConfiguration{
@@ -0,0 +1,16 @@
+// +build !windows
+
+package main
+
+import (
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/configuration"
+)
+
+// addServiceCommand adds the command managing the Windows service, only on Windows
+func addServiceCommand(f *flaeg.Flaeg) {}
+
+// runWindowsService returns false, Traefik being run as a Windows service only on Windows
+func runWindowsService(globalConfiguration *configuration.GlobalConfiguration, configFile string) bool {
+	return false
+}
@@ -0,0 +1,260 @@
+// +build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	fmtlog "log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/server"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	defaultServiceName        = "traefik"
+	serviceDisplayName        = "Traefik"
+	serviceDescription        = "Træfik, a modern HTTP reverse proxy and load balancer"
+	serviceStartWaitHint      = 30 * time.Second
+	serviceStopWaitHintMargin = 10 * time.Second
+
+	errorCallNotImplemented             = 120
+	errorFailedServiceControllerConnect = syscall.Errno(1063)
+)
+
+var procRegisterServiceCtrlHandlerExW = windows.NewLazySystemDLL("advapi32.dll").NewProc("RegisterServiceCtrlHandlerExW")
+
+// serviceConfiguration is the configuration of the command managing the Windows service
+type serviceConfiguration struct {
+	Install    bool   `description:"Install Traefik as a Windows service, started automatically with the configuration file"`
+	Uninstall  bool   `description:"Uninstall the Windows service"`
+	Name       string `description:"Name of the Windows service"`
+	ConfigFile string `description:"Configuration file of the Windows service (TOML)"`
+}
+
+// addServiceCommand adds the command installing and uninstalling the Windows service
+func addServiceCommand(f *flaeg.Flaeg) {
+	serviceConfig := &serviceConfiguration{Name: defaultServiceName}
+	f.AddCommand(&flaeg.Command{
+		Name:                  "service",
+		Description:           `Install or uninstall Traefik as a Windows service. Traefik will not start.`,
+		Config:                serviceConfig,
+		DefaultPointersConfig: &serviceConfiguration{},
+		Run: func() error {
+			switch {
+			case serviceConfig.Install:
+				return installService(serviceConfig.Name, serviceConfig.ConfigFile)
+			case serviceConfig.Uninstall:
+				return uninstallService(serviceConfig.Name)
+			}
+			return errors.New("Error using command service, --install or --uninstall is required")
+		},
+	})
+}
+
+// installService creates the Windows service running Traefik with the configuration file, and its event source
+func installService(name, configFile string) error {
+	if len(configFile) == 0 {
+		return errors.New("Error installing the Windows service, --configFile is required")
+	}
+	configFile, err := filepath.Abs(configFile)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	manager, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_ALL_ACCESS)
+	if err != nil {
+		return fmt.Errorf("Error connecting to the service control manager: %v", err)
+	}
+	defer windows.CloseServiceHandle(manager)
+
+	binaryPath := windows.EscapeArg(executable) + " " + windows.EscapeArg("--configFile="+configFile)
+	service, err := windows.CreateService(manager, windows.StringToUTF16Ptr(name), windows.StringToUTF16Ptr(serviceDisplayName),
+		windows.SERVICE_ALL_ACCESS, windows.SERVICE_WIN32_OWN_PROCESS, windows.SERVICE_AUTO_START, windows.SERVICE_ERROR_NORMAL,
+		windows.StringToUTF16Ptr(binaryPath), nil, nil, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Error creating the Windows service %s: %v", name, err)
+	}
+	defer windows.CloseServiceHandle(service)
+
+	description := windows.SERVICE_DESCRIPTION{Description: windows.StringToUTF16Ptr(serviceDescription)}
+	if err := windows.ChangeServiceConfig2(service, windows.SERVICE_CONFIG_DESCRIPTION, (*byte)(unsafe.Pointer(&description))); err != nil {
+		fmtlog.Printf("Error setting the description of the Windows service %s: %s\n", name, err)
+	}
+	if err := log.InstallEventLogSource(name); err != nil {
+		windows.DeleteService(service)
+		return fmt.Errorf("Error installing the event source %s: %v", name, err)
+	}
+
+	fmt.Printf("Windows service %s installed: %s\n", name, binaryPath)
+	return nil
+}
+
+// uninstallService deletes the Windows service, and its event source
+func uninstallService(name string) error {
+	manager, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_ALL_ACCESS)
+	if err != nil {
+		return fmt.Errorf("Error connecting to the service control manager: %v", err)
+	}
+	defer windows.CloseServiceHandle(manager)
+
+	service, err := windows.OpenService(manager, windows.StringToUTF16Ptr(name), windows.SERVICE_ALL_ACCESS)
+	if err != nil {
+		return fmt.Errorf("Error opening the Windows service %s: %v", name, err)
+	}
+	defer windows.CloseServiceHandle(service)
+
+	if err := windows.DeleteService(service); err != nil {
+		return fmt.Errorf("Error deleting the Windows service %s: %v", name, err)
+	}
+	if err := log.RemoveEventLogSource(name); err != nil {
+		fmtlog.Printf("Error removing the event source %s: %s\n", name, err)
+	}
+
+	fmt.Printf("Windows service %s uninstalled\n", name)
+	return nil
+}
+
+// windowsService runs Traefik as a Windows service, reporting its status to the service control manager
+type windowsService struct {
+	globalConfiguration *configuration.GlobalConfiguration
+	configFile          string
+	stop                chan struct{}
+	mutex               sync.Mutex
+	statusHandle        windows.Handle
+}
+
+// runWindowsService runs Traefik as the Windows service it has been started as, until it is stopped.
+// It returns false when Traefik has not been started by the service control manager.
+func runWindowsService(globalConfiguration *configuration.GlobalConfiguration, configFile string) bool {
+	service := &windowsService{
+		globalConfiguration: globalConfiguration,
+		configFile:          configFile,
+		stop:                make(chan struct{}, 1),
+	}
+	serviceTable := []windows.SERVICE_TABLE_ENTRY{
+		{ServiceName: windows.StringToUTF16Ptr(""), ServiceProc: syscall.NewCallback(service.main)},
+		{},
+	}
+	err := windows.StartServiceCtrlDispatcher(&serviceTable[0])
+	if err == errorFailedServiceControllerConnect {
+		return false
+	}
+	if err != nil {
+		fmtlog.Printf("Error running the Windows service: %s\n", err)
+		os.Exit(-1)
+	}
+	return true
+}
+
+// main is the ServiceMain function of the service, returning once Traefik is stopped
+func (s *windowsService) main(argc uintptr, argv **uint16) uintptr {
+	name := defaultServiceName
+	if argc > 0 {
+		name = windows.UTF16ToString((*[256]uint16)(unsafe.Pointer(*argv))[:])
+	}
+
+	statusHandle, err := registerServiceCtrlHandlerEx(name, syscall.NewCallback(s.handle))
+	if err != nil {
+		fmtlog.Printf("Error registering the control handler of the Windows service %s: %s\n", name, err)
+		return 0
+	}
+	s.statusHandle = statusHandle
+	s.setStatus(windows.SERVICE_START_PENDING, 0)
+
+	configureLogging(s.globalConfiguration)
+	traefikLog := s.globalConfiguration.TraefikLog
+	if len(s.globalConfiguration.TraefikLogsFile) == 0 && (traefikLog == nil || len(traefikLog.FilePath) == 0 && traefikLog.Syslog == nil) {
+		hook, err := log.NewEventLogHook(name)
+		if err != nil {
+			log.Errorf("Error opening the event log %s: %v", name, err)
+		} else {
+			log.SetOutput(ioutil.Discard)
+			log.AddHook(hook)
+			defer hook.Close()
+		}
+	}
+
+	svr := startTraefik(s.globalConfiguration, s.configFile)
+	s.setStatus(windows.SERVICE_RUNNING, windows.SERVICE_ACCEPT_STOP|windows.SERVICE_ACCEPT_SHUTDOWN)
+	go s.shutdown(svr)
+	svr.Wait()
+	svr.Close()
+	shutdownTraefik(s.globalConfiguration)
+	s.setStatus(windows.SERVICE_STOPPED, 0)
+	return 0
+}
+
+// shutdown stops the server gracefully once the service control manager stops the service
+func (s *windowsService) shutdown(svr *server.Server) {
+	<-s.stop
+	log.Info("Stopping the Windows service")
+	s.setStatus(windows.SERVICE_STOP_PENDING, 0)
+	svr.Shutdown()
+}
+
+// handle is the HandlerEx function of the service, handling the controls sent by the service control manager
+func (s *windowsService) handle(control, eventType, eventData, context uintptr) uintptr {
+	switch control {
+	case windows.SERVICE_CONTROL_STOP, windows.SERVICE_CONTROL_SHUTDOWN:
+		select {
+		case s.stop <- struct{}{}:
+		default:
+		}
+		return windows.NO_ERROR
+	case windows.SERVICE_CONTROL_INTERROGATE:
+		return windows.NO_ERROR
+	}
+	return errorCallNotImplemented
+}
+
+// setStatus reports the state of the service, and the controls it accepts, to the service control manager
+func (s *windowsService) setStatus(state, controlsAccepted uint32) {
+	status := windows.SERVICE_STATUS{
+		ServiceType:      windows.SERVICE_WIN32_OWN_PROCESS,
+		CurrentState:     state,
+		ControlsAccepted: controlsAccepted,
+	}
+	switch state {
+	case windows.SERVICE_START_PENDING:
+		status.WaitHint = uint32(serviceStartWaitHint / time.Millisecond)
+	case windows.SERVICE_STOP_PENDING:
+		waitHint := serviceStopWaitHintMargin
+		if lifeCycle := s.globalConfiguration.LifeCycle; lifeCycle != nil {
+			waitHint += time.Duration(lifeCycle.RequestAcceptGraceTimeout) + time.Duration(lifeCycle.GraceTimeOut)
+		}
+		status.WaitHint = uint32(waitHint / time.Millisecond)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := windows.SetServiceStatus(s.statusHandle, &status); err != nil {
+		log.Errorf("Error setting the status of the Windows service: %v", err)
+	}
+}
+
+func registerServiceCtrlHandlerEx(name string, handler uintptr) (windows.Handle, error) {
+	serviceName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(serviceName)), handler, 0)
+	if handle == 0 {
+		return 0, err
+	}
+	return windows.Handle(handle), nil
+}
@@ -4,12 +4,14 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	fmtlog "log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 	"github.com/containous/traefik/cluster"
 	"github.com/containous/traefik/configuration"
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/plugins"
 	"github.com/containous/traefik/provider/ecs"
 	"github.com/containous/traefik/provider/kubernetes"
 	"github.com/containous/traefik/safe"
@@ -45,6 +48,9 @@ Complete documentation is available at https://traefik.io`,
 		Config:                traefikConfiguration,
 		DefaultPointersConfig: traefikPointersConfiguration,
 		Run: func() error {
+			if runWindowsService(&traefikConfiguration.GlobalConfiguration, traefikConfiguration.ConfigFile) {
+				return nil
+			}
 			run(&traefikConfiguration.GlobalConfiguration, traefikConfiguration.ConfigFile)
 			return nil
 		},
@@ -139,21 +145,14 @@ Complete documentation is available at https://traefik.io`,
 
 	//init flaeg source
 	f := flaeg.New(traefikCmd, os.Args[1:])
-	//add custom parsers
-	f.AddParser(reflect.TypeOf(configuration.EntryPoints{}), &configuration.EntryPoints{})
-	f.AddParser(reflect.TypeOf(configuration.DefaultEntryPoints{}), &configuration.DefaultEntryPoints{})
-	f.AddParser(reflect.TypeOf(configuration.RootCAs{}), &configuration.RootCAs{})
-	f.AddParser(reflect.TypeOf(types.Constraints{}), &types.Constraints{})
-	f.AddParser(reflect.TypeOf(kubernetes.Namespaces{}), &kubernetes.Namespaces{})
-	f.AddParser(reflect.TypeOf(ecs.Clusters{}), &ecs.Clusters{})
-	f.AddParser(reflect.TypeOf([]acme.Domain{}), &acme.Domains{})
-	f.AddParser(reflect.TypeOf(types.Buckets{}), &types.Buckets{})
+	addParsers(f)
 
 	//add commands
 	f.AddCommand(newVersionCmd())
 	f.AddCommand(newBugCmd(traefikConfiguration, traefikPointersConfiguration))
 	f.AddCommand(storeConfigCmd)
 	f.AddCommand(healthCheckCmd)
+	addServiceCommand(f)
 
 	usedCmd, err := f.GetCommand()
 	if err != nil {
@@ -210,9 +209,71 @@ Complete documentation is available at https://traefik.io`,
 	os.Exit(0)
 }
 
+// addParsers adds the custom parsers of the configuration to the flaeg source
+func addParsers(f *flaeg.Flaeg) {
+	f.AddParser(reflect.TypeOf(configuration.EntryPoints{}), &configuration.EntryPoints{})
+	f.AddParser(reflect.TypeOf(configuration.DefaultEntryPoints{}), &configuration.DefaultEntryPoints{})
+	f.AddParser(reflect.TypeOf(configuration.Plugins{}), &configuration.Plugins{})
+	f.AddParser(reflect.TypeOf(configuration.RootCAs{}), &configuration.RootCAs{})
+	f.AddParser(reflect.TypeOf(types.Constraints{}), &types.Constraints{})
+	f.AddParser(reflect.TypeOf(types.StatusCodes{}), &types.StatusCodes{})
+	f.AddParser(reflect.TypeOf(types.ExcludePaths{}), &types.ExcludePaths{})
+	f.AddParser(reflect.TypeOf(kubernetes.Namespaces{}), &kubernetes.Namespaces{})
+	f.AddParser(reflect.TypeOf(ecs.Clusters{}), &ecs.Clusters{})
+	f.AddParser(reflect.TypeOf([]acme.Domain{}), &acme.Domains{})
+	f.AddParser(reflect.TypeOf(types.Buckets{}), &types.Buckets{})
+}
+
+// loadGlobalConfiguration reads the global configuration again from the TOML configuration file and the arguments
+func loadGlobalConfiguration(configFile string) (*configuration.GlobalConfiguration, error) {
+	traefikConfiguration := NewTraefikConfiguration()
+	traefikCmd := &flaeg.Command{
+		Name:                  "traefik",
+		Config:                traefikConfiguration,
+		DefaultPointersConfig: NewTraefikDefaultPointersConfiguration(),
+		Run:                   func() error { return nil },
+	}
+
+	f := flaeg.New(traefikCmd, os.Args[1:])
+	addParsers(f)
+	if _, err := f.Parse(traefikCmd); err != nil {
+		return nil, fmt.Errorf("error parsing command: %v", err)
+	}
+
+	s := staert.NewStaert(traefikCmd)
+	if len(configFile) > 0 {
+		s.AddSource(staert.NewTomlSource("traefik", []string{configFile}))
+	}
+	s.AddSource(f)
+	if _, err := s.LoadConfig(); err != nil {
+		return nil, fmt.Errorf("error reading TOML config file %s: %v", configFile, err)
+	}
+
+	globalConfiguration := &traefikConfiguration.GlobalConfiguration
+	if globalConfiguration.Debug {
+		globalConfiguration.LogLevel = "DEBUG"
+	}
+	globalConfiguration.SetEffectiveConfiguration(configFile)
+	return globalConfiguration, nil
+}
+
+// writePidFile writes the process ID of Traefik to the file
+func writePidFile(pidFile string) error {
+	return ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
 func run(globalConfiguration *configuration.GlobalConfiguration, configFile string) {
 	configureLogging(globalConfiguration)
 
+	svr := startTraefik(globalConfiguration, configFile)
+	defer svr.Close()
+	svr.Wait()
+	shutdownTraefik(globalConfiguration)
+	logrus.Exit(0)
+}
+
+// startTraefik starts the server of the global configuration, the logging being configured
+func startTraefik(globalConfiguration *configuration.GlobalConfiguration, configFile string) *server.Server {
 	if len(configFile) > 0 {
 		log.Infof("Using TOML configuration file %s", configFile)
 	}
@@ -229,9 +290,21 @@ func run(globalConfiguration *configuration.GlobalConfiguration, configFile stri
 	}
 
 	log.Debugf("Global configuration loaded %s", string(jsonConf))
+	for _, pluginFile := range globalConfiguration.Plugins {
+		if err := plugins.Load(pluginFile); err != nil {
+			log.Error(err)
+		}
+	}
+	if len(globalConfiguration.PidFile) > 0 {
+		if err := writePidFile(globalConfiguration.PidFile); err != nil {
+			log.Errorf("Error writing the pid file %s: %v", globalConfiguration.PidFile, err)
+		}
+	}
 	svr := server.NewServer(*globalConfiguration)
+	svr.SetGlobalConfigurationLoader(func() (*configuration.GlobalConfiguration, error) {
+		return loadGlobalConfiguration(configFile)
+	})
 	svr.Start()
-	defer svr.Close()
 	sent, err := daemon.SdNotify(false, "READY=1")
 	if !sent && err != nil {
 		log.Error("Fail to notify", err)
@@ -252,9 +325,15 @@ func run(globalConfiguration *configuration.GlobalConfiguration, configFile stri
 			}
 		})
 	}
-	svr.Wait()
+	return svr
+}
+
+// shutdownTraefik cleans up once the server is stopped
+func shutdownTraefik(globalConfiguration *configuration.GlobalConfiguration) {
 	log.Info("Shutting down")
-	logrus.Exit(0)
+	if len(globalConfiguration.PidFile) > 0 {
+		os.Remove(globalConfiguration.PidFile)
+	}
 }
 
 func configureLogging(globalConfiguration *configuration.GlobalConfiguration) {
@@ -284,10 +363,10 @@ func configureLogging(globalConfiguration *configuration.GlobalConfiguration) {
 	// configure log format
 	var formatter logrus.Formatter
 	if globalConfiguration.TraefikLog != nil && globalConfiguration.TraefikLog.Format == "json" {
-		formatter = &logrus.JSONFormatter{}
+		formatter = log.NewJSONFormatter()
 	} else {
 		disableColors := false
-		if len(logFile) > 0 {
+		if len(logFile) > 0 || globalConfiguration.TraefikLog != nil && globalConfiguration.TraefikLog.Syslog != nil {
 			disableColors = true
 		}
 		formatter = &logrus.TextFormatter{DisableColors: disableColors, FullTimestamp: true, DisableSorting: true}
@@ -312,6 +391,21 @@ func configureLogging(globalConfiguration *configuration.GlobalConfiguration) {
 			log.Error("Error opening file", err)
 		}
 	}
+
+	if globalConfiguration.TraefikLog != nil && globalConfiguration.TraefikLog.Syslog != nil {
+		syslogHook, err := globalConfiguration.TraefikLog.Syslog.CreateHook()
+		if err != nil {
+			log.Errorf("Error connecting to syslog: %v", err)
+			return
+		}
+		if len(logFile) == 0 {
+			log.SetOutput(ioutil.Discard)
+		}
+		log.AddHook(syslogHook)
+		logrus.RegisterExitHandler(func() {
+			syslogHook.Close()
+		})
+	}
 }
 
 // CreateKvSource creates KvSource
@@ -0,0 +1,52 @@
+package brotli
+
+// bitWriter writes the bits of the brotli stream, packed from the least significant ones
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// bitWriterState is a position of the writer, to go back to
+type bitWriterState struct {
+	length int
+	bits   uint64
+	nbits  uint
+}
+
+// write writes the n least significant bits of value, n being at most 32
+func (b *bitWriter) write(value uint64, n uint) {
+	b.bits |= (value & (1<<n - 1)) << b.nbits
+	b.nbits += n
+	for b.nbits >= 8 {
+		b.out = append(b.out, byte(b.bits))
+		b.bits >>= 8
+		b.nbits -= 8
+	}
+}
+
+// align writes the zero bits padding the last byte
+func (b *bitWriter) align() {
+	if b.nbits > 0 {
+		b.write(0, 8-b.nbits)
+	}
+}
+
+func (b *bitWriter) state() bitWriterState {
+	return bitWriterState{length: len(b.out), bits: b.bits, nbits: b.nbits}
+}
+
+func (b *bitWriter) restore(state bitWriterState) {
+	b.out = b.out[:state.length]
+	b.bits, b.nbits = state.bits, state.nbits
+}
+
+// highBit returns the position of the most significant bit set in v, v being positive
+func highBit(v uint32) uint {
+	n := uint(0)
+	for v > 1 {
+		v >>= 1
+		n++
+	}
+	return n
+}
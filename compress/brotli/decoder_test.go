@@ -0,0 +1,322 @@
+package brotli
+
+import (
+	"errors"
+	"fmt"
+)
+
+// decode decompresses the brotli stream, with a decoder limited to what the writer produces, following RFC 7932
+// independently of the writer
+func decode(stream []byte) ([]byte, error) {
+	r := &bitReader{data: stream}
+	window := 16
+	if r.read(1) == 1 {
+		if bits := r.read(3); bits != 0 {
+			window = 17 + bits
+		} else {
+			return nil, errors.New("unexpected window size")
+		}
+	}
+	maxDistance := 1<<uint(window) - 16
+
+	var out []byte
+	distances := [4]int{4, 11, 15, 16}
+	for {
+		if r.pastEnd() {
+			return nil, errors.New("truncated stream")
+		}
+		last := r.read(1) == 1
+		if last && r.read(1) == 1 {
+			break
+		}
+		nibbles := r.read(2) + 4
+		if nibbles == 7 {
+			// metadata block
+			if r.read(1) != 0 {
+				return nil, errors.New("reserved bit set")
+			}
+			skipBytes := r.read(2)
+			skip := 0
+			if skipBytes > 0 {
+				skip = r.read(uint(8*skipBytes)) + 1
+			}
+			r.align()
+			r.pos += 8 * skip
+			continue
+		}
+		length := r.read(uint(4*nibbles)) + 1
+		if !last && r.read(1) == 1 {
+			r.align()
+			start := r.pos / 8
+			if start+length > len(stream) {
+				return nil, errors.New("truncated uncompressed meta-block")
+			}
+			out = append(out, stream[start:start+length]...)
+			r.pos += 8 * length
+			continue
+		}
+
+		var err error
+		if out, err = decodeMetaBlock(r, out, length, &distances, maxDistance); err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+	}
+	if r.pastEnd() {
+		return nil, errors.New("truncated stream")
+	}
+	return out, nil
+}
+
+// decodeMetaBlock decodes a compressed meta-block of a single block type for each alphabet, without context modeling
+func decodeMetaBlock(r *bitReader, out []byte, length int, distances *[4]int, maxDistance int) ([]byte, error) {
+	for i := 0; i < 3; i++ {
+		if r.read(1) != 0 {
+			return nil, errors.New("unexpected block types")
+		}
+	}
+	postfix := uint(r.read(2))
+	direct := r.read(4) << postfix
+	r.read(2)
+	if r.read(1) != 0 || r.read(1) != 0 {
+		return nil, errors.New("unexpected context maps")
+	}
+	literals, err := readPrefixCode(r, 256)
+	if err != nil {
+		return nil, fmt.Errorf("literals code: %v", err)
+	}
+	insertAndCopy, err := readPrefixCode(r, 704)
+	if err != nil {
+		return nil, fmt.Errorf("insert and copy code: %v", err)
+	}
+	distancesCode, err := readPrefixCode(r, 16+direct+48<<postfix)
+	if err != nil {
+		return nil, fmt.Errorf("distances code: %v", err)
+	}
+
+	// the cells of the insert and copy lengths codes, as groups of 8 of the insert and copy codes
+	cells := [][2]int{{0, 0}, {0, 1}, {0, 0}, {0, 1}, {1, 0}, {1, 1}, {0, 2}, {2, 0}, {1, 2}, {2, 1}, {2, 2}}
+	end := len(out) + length
+	for len(out) < end {
+		symbol := insertAndCopy.decode(r)
+		cell := cells[symbol>>6]
+		insertCode, copyCode := cell[0]*8+symbol>>3&7, cell[1]*8+symbol&7
+		insertLength := insertLengthsBases[insertCode] + r.read(insertLengthsBits[insertCode])
+		copyLength := copyLengthsBases[copyCode] + r.read(copyLengthsBits[copyCode])
+		for i := 0; i < insertLength; i++ {
+			out = append(out, byte(literals.decode(r)))
+		}
+		if len(out) >= end {
+			break
+		}
+
+		distanceCode := 0
+		if symbol >= 128 {
+			distanceCode = distancesCode.decode(r)
+		}
+		var distance int
+		switch {
+		case distanceCode < 16:
+			last := []struct{ index, delta int }{
+				{0, 0}, {1, 0}, {2, 0}, {3, 0}, {0, -1}, {0, 1}, {0, -2}, {0, 2}, {0, -3}, {0, 3},
+				{1, -1}, {1, 1}, {1, -2}, {1, 2}, {1, -3}, {1, 3},
+			}[distanceCode]
+			distance = distances[last.index] + last.delta
+		case distanceCode < 16+direct:
+			distance = distanceCode - 15
+		default:
+			code := distanceCode - direct - 16
+			bits := uint(1 + code>>(postfix+1))
+			high := code >> postfix & 1
+			low := code & (1<<postfix - 1)
+			offset := (2+high)<<bits - 4
+			distance = (offset+r.read(bits))<<postfix + low + direct + 1
+		}
+		if distance <= 0 || distance > len(out) || distance > maxDistance {
+			return nil, fmt.Errorf("invalid distance %d", distance)
+		}
+		if distanceCode != 0 {
+			distances[3], distances[2], distances[1], distances[0] = distances[2], distances[1], distances[0], distance
+		}
+		for i := 0; i < copyLength; i++ {
+			out = append(out, out[len(out)-distance])
+		}
+	}
+	if len(out) != end {
+		return nil, fmt.Errorf("meta-block of %d bytes instead of %d", len(out)-end+length, length)
+	}
+	return out, nil
+}
+
+// huffmanDecoder decodes the canonical prefix codes bit by bit, with the counts of codes by length
+type huffmanDecoder struct {
+	counts  [16]int
+	symbols []int
+}
+
+func newHuffmanDecoder(lengths []int) *huffmanDecoder {
+	h := &huffmanDecoder{}
+	for length := 1; length < 16; length++ {
+		for symbol, l := range lengths {
+			if l == length {
+				h.counts[length]++
+				h.symbols = append(h.symbols, symbol)
+			}
+		}
+	}
+	return h
+}
+
+func (h *huffmanDecoder) decode(r *bitReader) int {
+	if len(h.symbols) == 1 {
+		return h.symbols[0]
+	}
+	code, first, index := 0, 0, 0
+	for length := 1; length < 16; length++ {
+		code |= r.read(1)
+		count := h.counts[length]
+		if code-count < first {
+			return h.symbols[index+code-first]
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return -1
+}
+
+// readPrefixCode reads the description of a prefix code of the alphabet
+func readPrefixCode(r *bitReader, alphabetSize int) (*huffmanDecoder, error) {
+	alphabetBits := uint(0)
+	for 1<<alphabetBits < alphabetSize {
+		alphabetBits++
+	}
+	lengths := make([]int, alphabetSize)
+
+	hskip := r.read(2)
+	if hskip == 1 {
+		count := r.read(2) + 1
+		symbols := make([]int, count)
+		for i := range symbols {
+			symbols[i] = r.read(alphabetBits)
+			if symbols[i] >= alphabetSize {
+				return nil, fmt.Errorf("symbol %d beyond the alphabet", symbols[i])
+			}
+		}
+		switch count {
+		case 1:
+			return &huffmanDecoder{symbols: symbols}, nil
+		case 2:
+			lengths[symbols[0]], lengths[symbols[1]] = 1, 1
+		case 3:
+			lengths[symbols[0]], lengths[symbols[1]], lengths[symbols[2]] = 1, 2, 2
+		default:
+			if r.read(1) == 0 {
+				for _, symbol := range symbols {
+					lengths[symbol] = 2
+				}
+			} else {
+				lengths[symbols[0]], lengths[symbols[1]], lengths[symbols[2]], lengths[symbols[3]] = 1, 2, 3, 3
+			}
+		}
+		// the symbols of the same length are ordered by their values
+		return newHuffmanDecoder(lengths), nil
+	}
+
+	order := []int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	codeLengthLengths := make([]int, 18)
+	space, count := 32, 0
+	for _, symbol := range order[hskip:] {
+		// the static code of the code length code lengths, read from its first bit
+		value, n := 0, uint(0)
+		static := map[[2]int]int{{0, 2}: 0, {7, 4}: 1, {3, 3}: 2, {2, 2}: 3, {1, 2}: 4, {15, 4}: 5}
+		for {
+			value |= r.read(1) << n
+			n++
+			if length, ok := static[[2]int{value, int(n)}]; ok {
+				codeLengthLengths[symbol] = length
+				break
+			}
+		}
+		if length := codeLengthLengths[symbol]; length != 0 {
+			space -= 32 >> uint(length)
+			count++
+			if space <= 0 {
+				break
+			}
+		}
+	}
+	if count != 1 && space != 0 {
+		return nil, errors.New("incomplete code length code")
+	}
+	codeLengths := newHuffmanDecoder(codeLengthLengths)
+
+	symbol, previous, repeat, repeatLength := 0, 8, 0, 0
+	space = 1 << 15
+	for symbol < alphabetSize && space > 0 {
+		code := codeLengths.decode(r)
+		if code < 16 {
+			lengths[symbol] = code
+			symbol++
+			if code != 0 {
+				previous = code
+				space -= 1 << 15 >> uint(code)
+			}
+			repeat = 0
+			continue
+		}
+		extraBits, length := uint(2), previous
+		if code == 17 {
+			extraBits, length = 3, 0
+		}
+		if repeatLength != length {
+			repeat, repeatLength = 0, length
+		}
+		oldRepeat := repeat
+		if repeat > 0 {
+			repeat = (repeat - 2) << extraBits
+		}
+		repeat += r.read(extraBits) + 3
+		for i := 0; i < repeat-oldRepeat; i++ {
+			if symbol >= alphabetSize {
+				return nil, errors.New("repeated code lengths beyond the alphabet")
+			}
+			lengths[symbol] = length
+			symbol++
+			if length != 0 {
+				space -= 1 << 15 >> uint(length)
+			}
+		}
+	}
+	if space != 0 {
+		return nil, errors.New("incomplete code")
+	}
+	return newHuffmanDecoder(lengths), nil
+}
+
+// bitReader reads the bits of the stream from the least significant ones
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n uint) int {
+	value := 0
+	for i := uint(0); i < n; i++ {
+		if r.pos < 8*len(r.data) {
+			value |= int(r.data[r.pos/8]>>uint(r.pos%8)) & 1 << i
+		}
+		r.pos++
+	}
+	return value
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) / 8 * 8
+}
+
+func (r *bitReader) pastEnd() bool {
+	return r.pos > 8*len(r.data)
+}
@@ -0,0 +1,187 @@
+package brotli
+
+import "github.com/containous/traefik/compress/internal/huffman"
+
+const (
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 5
+	repeatPrevious          = 16
+	repeatZero              = 17
+	// initialPrevious is the code length repeated by the decoder before any code length
+	initialPrevious = 8
+)
+
+var (
+	// codeLengthCodeOrder is the order the code lengths of the code lengths alphabet are written in
+	codeLengthCodeOrder = []int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	// codeLengthCodeLengths is the static code of the code lengths of the code lengths alphabet, as values and lengths
+	codeLengthCodeLengths = [][2]uint{{0, 2}, {7, 4}, {3, 3}, {2, 2}, {1, 2}, {15, 4}}
+)
+
+// prefixCode holds the codes of the symbols of an alphabet, as written in the stream
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+	// symbols holds the symbols used when there are at most four of them, ordered by the lengths of their codes
+	symbols []int
+}
+
+// newPrefixCode returns the code of the symbols with the frequencies
+func newPrefixCode(frequencies []int, maxLength uint8) *prefixCode {
+	c := &prefixCode{lengths: huffman.Lengths(frequencies, maxLength)}
+	for length := uint8(1); length <= maxLength && len(c.symbols) <= 4; length++ {
+		for symbol, l := range c.lengths {
+			if l == length {
+				c.symbols = append(c.symbols, symbol)
+			}
+		}
+	}
+	if len(c.symbols) > 4 {
+		c.symbols = nil
+	}
+	if len(c.symbols) == 1 {
+		// the single symbol of a code is written without bits
+		c.lengths[c.symbols[0]] = 0
+	}
+	c.codes = canonicalCodes(c.lengths)
+	return c
+}
+
+// write writes the code of the symbol
+func (c *prefixCode) write(b *bitWriter, symbol int) {
+	b.write(uint64(c.codes[symbol]), uint(c.lengths[symbol]))
+}
+
+// writeDescription writes the description of the code, simple when there are at most four symbols
+func (c *prefixCode) writeDescription(b *bitWriter, alphabetBits uint) {
+	if c.symbols != nil {
+		b.write(1, 2)
+		b.write(uint64(len(c.symbols)-1), 2)
+		for _, symbol := range c.symbols {
+			b.write(uint64(symbol), alphabetBits)
+		}
+		if len(c.symbols) == 4 {
+			// the tree select of the codes of lengths 1, 2, 3 and 3, rather than 2 for all the symbols
+			if c.lengths[c.symbols[0]] == 1 {
+				b.write(1, 1)
+			} else {
+				b.write(0, 1)
+			}
+		}
+		return
+	}
+
+	tokens := codeLengthTokens(c.lengths)
+	var frequencies [repeatZero + 1]int
+	for _, token := range tokens {
+		frequencies[token.symbol]++
+	}
+	code := newPrefixCode(frequencies[:], maxCodeLengthCodeLength)
+	lengths := code.lengths
+	if code.symbols != nil && len(code.symbols) == 1 {
+		// the single code length symbol is written with a length, and decoded without bits
+		lengths = make([]uint8, len(code.lengths))
+		lengths[code.symbols[0]] = 1
+	}
+
+	// no code length is skipped, and they are written until the code is complete
+	b.write(0, 2)
+	space := 32
+	for _, symbol := range codeLengthCodeOrder {
+		length := lengths[symbol]
+		b.write(uint64(codeLengthCodeLengths[length][0]), codeLengthCodeLengths[length][1])
+		if length != 0 {
+			space -= 32 >> length
+			if space == 0 {
+				break
+			}
+		}
+	}
+	for _, token := range tokens {
+		code.write(b, token.symbol)
+		switch token.symbol {
+		case repeatPrevious:
+			b.write(uint64(token.extra), 2)
+		case repeatZero:
+			b.write(uint64(token.extra), 3)
+		}
+	}
+}
+
+type codeLengthToken struct {
+	symbol int
+	extra  int
+}
+
+// codeLengthTokens returns the code lengths run length encoded, up to the last symbol having a code,
+// the decoder stopping once the code is complete. The repeat codes are never consecutive, for them to be
+// read as simple repeats.
+func codeLengthTokens(lengths []uint8) []codeLengthToken {
+	end := len(lengths)
+	for end > 0 && lengths[end-1] == 0 {
+		end--
+	}
+
+	var tokens []codeLengthToken
+	previous, last := uint8(initialPrevious), -1
+	for i := 0; i < end; {
+		length := lengths[i]
+		run := 1
+		for i+run < end && lengths[i+run] == length {
+			run++
+		}
+
+		switch {
+		case length == 0 && run >= 3 && last != repeatZero:
+			if run > 10 {
+				run = 10
+			}
+			tokens = append(tokens, codeLengthToken{symbol: repeatZero, extra: run - 3})
+		case length != 0 && length == previous && run >= 3 && last != repeatPrevious:
+			if run > 6 {
+				run = 6
+			}
+			tokens = append(tokens, codeLengthToken{symbol: repeatPrevious, extra: run - 3})
+		default:
+			run = 1
+			tokens = append(tokens, codeLengthToken{symbol: int(length)})
+			if length != 0 {
+				previous = length
+			}
+		}
+		last = tokens[len(tokens)-1].symbol
+		i += run
+	}
+	return tokens
+}
+
+// canonicalCodes returns the canonical codes of the lengths, bit reversed for the decoder to read them
+// from their first bit
+func canonicalCodes(lengths []uint8) []uint16 {
+	var counts, next [maxCodeLength + 2]uint16
+	for _, length := range lengths {
+		counts[length]++
+	}
+	counts[0] = 0
+	code := uint16(0)
+	for length := 1; length <= maxCodeLength+1; length++ {
+		code = (code + counts[length-1]) << 1
+		next[length] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		code := next[length]
+		next[length]++
+		reversed := uint16(0)
+		for i := uint8(0); i < length; i++ {
+			reversed = reversed<<1 | code&1
+			code >>= 1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
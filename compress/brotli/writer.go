@@ -0,0 +1,338 @@
+// Package brotli implements a writer of the brotli compressed data format, as specified by RFC 7932.
+//
+// The writer finds the copies with hash chains, within a window of 128KB, and encodes each block in a meta-block
+// with a single prefix code for each alphabet, without static dictionary references nor context modeling.
+package brotli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containous/traefik/compress/internal/lz"
+)
+
+const (
+	// BestSpeed is the fastest compression level
+	BestSpeed = 0
+	// BestCompression is the compression level with the smallest output
+	BestCompression = 11
+	// DefaultCompression is the compression level of the writers created by NewWriter
+	DefaultCompression = 6
+
+	windowLog = 17
+	// lazyLevel is the level from which the copies are found with lazy matching
+	lazyLevel = 4
+
+	literalAlphabetBits       = 8
+	insertAndCopyAlphabetSize = 704
+	insertAndCopyAlphabetBits = 10
+	distanceAlphabetSize      = 64
+	distanceAlphabetBits      = 6
+	explicitDistances         = 16
+	lastDistancesCount        = 4
+)
+
+var (
+	// depths holds the number of candidates compared at each position, by level
+	depths = []int{1, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024}
+
+	insertLengthsBases = []int{0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98, 130, 194, 322, 578, 1090, 2114, 6210, 22594}
+	insertLengthsBits  = []uint{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 12, 14, 24}
+	copyLengthsBases   = []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54, 70, 102, 134, 198, 326, 582, 1094, 2118}
+	copyLengthsBits    = []uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 24}
+
+	// insertAndCopyCells holds the first symbols of the cells of the insert and copy lengths codes by groups of 8,
+	// the copies of these symbols having an explicit distance
+	insertAndCopyCells = [3][3]int{{128, 192, 384}, {256, 320, 512}, {448, 576, 640}}
+
+	// initialDistances holds the last distances the decoder starts with, the last one first
+	initialDistances = [lastDistancesCount]int{4, 11, 15, 16}
+
+	errClosed = errors.New("brotli: write to a closed writer")
+)
+
+// Writer is an io.WriteCloser compressing the data written to it in a brotli stream
+type Writer struct {
+	w           io.Writer
+	finder      *lz.Finder
+	sequences   []lz.Sequence
+	commands    []command
+	distances   [lastDistancesCount]int
+	b           bitWriter
+	wroteHeader bool
+	closed      bool
+	err         error
+}
+
+// command is the insertion of literals followed by a copy, encoded with an insert and copy lengths symbol
+type command struct {
+	sequence       lz.Sequence
+	insertCode     int
+	copyCode       int
+	symbol         int
+	distanceSymbol int // -1 when the distance is the last one implied by the symbol, or not read
+	distanceBits   uint
+	distanceExtra  int
+}
+
+// NewWriter returns a writer compressing the data written to it to w, with the default level
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel returns a writer compressing the data written to it to w, with the level between BestSpeed
+// and BestCompression
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("brotli: invalid compression level: %d", level)
+	}
+	return &Writer{
+		w:         w,
+		finder:    lz.NewFinder(windowLog, depths[level], level >= lazyLevel),
+		distances: initialDistances,
+	}, nil
+}
+
+// Reset discards the state of the writer, for it to compress to w as a new one with the same level
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.finder.Reset()
+	z.distances = initialDistances
+	z.b = bitWriter{out: z.b.out[:0]}
+	z.wroteHeader = false
+	z.closed = false
+	z.err = nil
+}
+
+// Write compresses p, the data being written once a block is full, or on Flush and Close
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errClosed
+	}
+	written := 0
+	for written < len(p) {
+		if z.finder.Pending() == z.finder.Window() {
+			z.writeMetaBlock()
+			if err := z.output(); err != nil {
+				return written, err
+			}
+		}
+		written += z.finder.Append(p[written:])
+	}
+	return written, nil
+}
+
+// Flush writes the data pending in a meta-block, followed by an empty metadata block padding the stream to a byte,
+// for the data written so far to be decompressed
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.writeHeader()
+	z.writeMetaBlock()
+	z.b.write(0, 1)
+	z.b.write(3, 2)
+	z.b.write(0, 1)
+	z.b.write(0, 2)
+	z.b.align()
+	return z.output()
+}
+
+// Close writes the data pending and the last empty meta-block of the stream, without closing the underlying writer
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	z.writeMetaBlock()
+	z.writeHeader()
+	z.b.write(1, 1)
+	z.b.write(1, 1)
+	z.b.align()
+	return z.output()
+}
+
+// output writes the complete bytes of the stream to the underlying writer
+func (z *Writer) output() error {
+	if _, err := z.w.Write(z.b.out); err != nil {
+		z.err = err
+		return err
+	}
+	z.b.out = z.b.out[:0]
+	return nil
+}
+
+// writeHeader writes the size of the window of the stream, once
+func (z *Writer) writeHeader() {
+	if z.wroteHeader {
+		return
+	}
+	// the window is the one of 18 bits, the smallest one holding the 128KB of the finder
+	z.b.write(1, 1)
+	z.b.write(1, 3)
+	z.wroteHeader = true
+}
+
+// writeMetaBlock writes the pending data in a meta-block, uncompressed when it is smaller
+func (z *Writer) writeMetaBlock() {
+	if z.finder.Pending() == 0 {
+		return
+	}
+	z.writeHeader()
+
+	var block []byte
+	z.sequences, block = z.finder.Find(z.sequences[:0])
+	start, distances := z.b.state(), z.distances
+	z.writeMetaBlockHeader(len(block), false)
+	z.writeCommands(block)
+	if len(z.b.out)-start.length <= len(block) {
+		return
+	}
+
+	// the decoder doesn't update the last distances with the uncompressed meta-blocks
+	z.b.restore(start)
+	z.distances = distances
+	z.writeMetaBlockHeader(len(block), true)
+	z.b.align()
+	z.b.out = append(z.b.out, block...)
+}
+
+func (z *Writer) writeMetaBlockHeader(length int, uncompressed bool) {
+	nibbles := uint(4)
+	for length-1 >= 1<<(4*nibbles) {
+		nibbles++
+	}
+	z.b.write(0, 1)
+	z.b.write(uint64(nibbles-4), 2)
+	z.b.write(uint64(length-1), 4*nibbles)
+	if uncompressed {
+		z.b.write(1, 1)
+	} else {
+		z.b.write(0, 1)
+	}
+}
+
+// writeCommands writes the commands of the block, with their prefix codes
+func (z *Writer) writeCommands(block []byte) {
+	var literals [256]int
+	var insertAndCopy [insertAndCopyAlphabetSize]int
+	var distances [distanceAlphabetSize]int
+	z.commands = z.commands[:0]
+	pos := 0
+	for _, sequence := range z.sequences {
+		c := z.newCommand(sequence)
+		z.commands = append(z.commands, c)
+		for _, literal := range block[pos : pos+sequence.Literals] {
+			literals[literal]++
+		}
+		insertAndCopy[c.symbol]++
+		if c.distanceSymbol >= 0 {
+			distances[c.distanceSymbol]++
+		}
+		pos += sequence.Literals + sequence.Length
+	}
+	literalsCode := newPrefixCode(nonEmpty(literals[:]), maxCodeLength)
+	insertAndCopyCode := newPrefixCode(insertAndCopy[:], maxCodeLength)
+	distancesCode := newPrefixCode(nonEmpty(distances[:]), maxCodeLength)
+
+	// a single block type for each alphabet, no postfix bits nor direct distances, and a single prefix code
+	// for the literals and the distances
+	b := &z.b
+	b.write(0, 1)
+	b.write(0, 1)
+	b.write(0, 1)
+	b.write(0, 2)
+	b.write(0, 4)
+	b.write(0, 2)
+	b.write(0, 1)
+	b.write(0, 1)
+	literalsCode.writeDescription(b, literalAlphabetBits)
+	insertAndCopyCode.writeDescription(b, insertAndCopyAlphabetBits)
+	distancesCode.writeDescription(b, distanceAlphabetBits)
+
+	pos = 0
+	for _, c := range z.commands {
+		insertAndCopyCode.write(b, c.symbol)
+		b.write(uint64(c.sequence.Literals-insertLengthsBases[c.insertCode]), insertLengthsBits[c.insertCode])
+		if c.sequence.Length > 0 {
+			b.write(uint64(c.sequence.Length-copyLengthsBases[c.copyCode]), copyLengthsBits[c.copyCode])
+		}
+		for _, literal := range block[pos : pos+c.sequence.Literals] {
+			literalsCode.write(b, int(literal))
+		}
+		if c.distanceSymbol >= 0 {
+			distancesCode.write(b, c.distanceSymbol)
+			b.write(uint64(c.distanceExtra), c.distanceBits)
+		}
+		pos += c.sequence.Literals + c.sequence.Length
+	}
+}
+
+// newCommand returns the command of the sequence, and updates the last distances as the decoder does.
+// The decoder ignores the empty copy of the last sequence, the meta-block ending with its literals.
+func (z *Writer) newCommand(sequence lz.Sequence) command {
+	c := command{sequence: sequence, distanceSymbol: -1}
+	c.insertCode = len(insertLengthsBases) - 1
+	for insertLengthsBases[c.insertCode] > sequence.Literals {
+		c.insertCode--
+	}
+	if sequence.Length > 0 {
+		c.copyCode = len(copyLengthsBases) - 1
+		for copyLengthsBases[c.copyCode] > sequence.Length {
+			c.copyCode--
+		}
+	}
+
+	lastDistance := sequence.Length == 0 || sequence.Offset == z.distances[0]
+	if lastDistance && c.insertCode < 8 && c.copyCode < 16 {
+		c.symbol = c.copyCode>>3<<6 | c.insertCode<<3 | c.copyCode&7
+		return c
+	}
+	c.symbol = insertAndCopyCells[c.insertCode>>3][c.copyCode>>3] | (c.insertCode&7)<<3 | c.copyCode&7
+	if sequence.Length == 0 {
+		return c
+	}
+
+	for i, distance := range z.distances {
+		if distance == sequence.Offset {
+			c.distanceSymbol = i
+			break
+		}
+	}
+	if c.distanceSymbol < 0 {
+		v := uint32(sequence.Offset + 3)
+		bits := highBit(v) - 1
+		b := int(v>>bits) & 1
+		c.distanceSymbol = explicitDistances + 2*int(bits-1) + b
+		c.distanceBits = bits
+		c.distanceExtra = int(v) - (2+b)<<bits
+	}
+	// the last distance used again is the only one not pushed to the last distances
+	if c.distanceSymbol != 0 {
+		copy(z.distances[1:], z.distances[:lastDistancesCount-1])
+		z.distances[0] = sequence.Offset
+	}
+	return c
+}
+
+// nonEmpty returns the frequencies, with a symbol when there is none, for the alphabet to have a code
+func nonEmpty(frequencies []int) []int {
+	for _, frequency := range frequencies {
+		if frequency > 0 {
+			return frequencies
+		}
+	}
+	frequencies[0] = 1
+	return frequencies
+}
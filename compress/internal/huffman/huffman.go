@@ -0,0 +1,91 @@
+// Package huffman computes the lengths of the prefix codes of the brotli and zstd writers, limited to the longest
+// code the formats can decode.
+package huffman
+
+import "sort"
+
+// Lengths returns the lengths of the codes of the symbols with the frequencies, the symbols of frequency 0 having
+// no code, and the longest code being at most maxLength bits long. The code of a single symbol is 1 bit long.
+func Lengths(frequencies []int, maxLength uint8) []uint8 {
+	lengths := make([]uint8, len(frequencies))
+	// the frequencies are raised to the count limit until the codes are short enough, the codes of symbols
+	// of equal frequencies being as long as the number of symbols requires
+	for countLimit := 1; ; countLimit *= 2 {
+		if build(frequencies, countLimit, lengths) <= maxLength {
+			return lengths
+		}
+	}
+}
+
+type node struct {
+	frequency int
+	parent    int
+}
+
+// build sets the lengths of the Huffman codes of the symbols, with their frequencies raised to countLimit at least,
+// and returns the length of the longest code
+func build(frequencies []int, countLimit int, lengths []uint8) uint8 {
+	var leaves []int
+	for symbol, frequency := range frequencies {
+		lengths[symbol] = 0
+		if frequency > 0 {
+			leaves = append(leaves, symbol)
+		}
+	}
+	if len(leaves) == 0 {
+		return 0
+	}
+	if len(leaves) == 1 {
+		lengths[leaves[0]] = 1
+		return 1
+	}
+
+	frequency := func(symbol int) int {
+		if frequencies[symbol] < countLimit {
+			return countLimit
+		}
+		return frequencies[symbol]
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return frequency(leaves[i]) < frequency(leaves[j])
+	})
+
+	// the leaves come first, then the internal nodes, created in increasing frequency order
+	nodes := make([]node, len(leaves), 2*len(leaves)-1)
+	for i, symbol := range leaves {
+		nodes[i] = node{frequency: frequency(symbol), parent: -1}
+	}
+	nextLeaf, nextInternal := 0, len(leaves)
+	smallest := func() int {
+		if nextLeaf < len(leaves) && (nextInternal >= len(nodes) || nodes[nextLeaf].frequency <= nodes[nextInternal].frequency) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextInternal++
+		return nextInternal - 1
+	}
+	for len(nodes) < cap(nodes) {
+		left := smallest()
+		right := smallest()
+		nodes = append(nodes, node{frequency: nodes[left].frequency + nodes[right].frequency, parent: -1})
+		nodes[left].parent = len(nodes) - 1
+		nodes[right].parent = len(nodes) - 1
+	}
+
+	// the depths are computed from the root, the parents coming after their children
+	depths := make([]int, len(nodes))
+	maxDepth := 0
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depths[i] = depths[nodes[i].parent] + 1
+		if i < len(leaves) && depths[i] > maxDepth {
+			maxDepth = depths[i]
+		}
+	}
+	if maxDepth > 255 {
+		return 255
+	}
+	for i, symbol := range leaves {
+		lengths[symbol] = uint8(depths[i])
+	}
+	return uint8(maxDepth)
+}
@@ -0,0 +1,78 @@
+package huffman
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLengths(t *testing.T) {
+	fibonacci := make([]int, 30)
+	fibonacci[0], fibonacci[1] = 1, 1
+	for i := 2; i < len(fibonacci); i++ {
+		fibonacci[i] = fibonacci[i-1] + fibonacci[i-2]
+	}
+
+	testCases := []struct {
+		desc        string
+		frequencies []int
+		maxLength   uint8
+		expected    []uint8
+	}{
+		{
+			desc:        "no symbol",
+			frequencies: []int{0, 0},
+			maxLength:   15,
+			expected:    []uint8{0, 0},
+		},
+		{
+			desc:        "single symbol",
+			frequencies: []int{0, 5, 0},
+			maxLength:   15,
+			expected:    []uint8{0, 1, 0},
+		},
+		{
+			desc:        "equal frequencies",
+			frequencies: []int{3, 3, 3, 3},
+			maxLength:   15,
+			expected:    []uint8{2, 2, 2, 2},
+		},
+		{
+			desc:        "skewed frequencies",
+			frequencies: []int{8, 0, 4, 2, 1, 1},
+			maxLength:   15,
+			expected:    []uint8{1, 0, 2, 3, 4, 4},
+		},
+		{
+			desc:        "limited lengths",
+			frequencies: fibonacci,
+			maxLength:   11,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			lengths := Lengths(test.frequencies, test.maxLength)
+			if test.expected != nil {
+				assert.Equal(t, test.expected, lengths)
+			}
+
+			// the codes of more than one symbol fill the code space exactly
+			space, symbols := 0, 0
+			for symbol, length := range lengths {
+				assert.True(t, length <= test.maxLength, "code of %d bits", length)
+				assert.Equal(t, test.frequencies[symbol] > 0, length > 0)
+				if length > 0 {
+					space += 1 << (test.maxLength - length)
+					symbols++
+				}
+			}
+			if symbols > 1 {
+				assert.Equal(t, 1<<test.maxLength, space)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package lz finds the repeated strings of the data compressed by the brotli and zstd writers, the formats
+// then encoding the literals and the copies of the sequences found in their own way.
+package lz
+
+const (
+	// MinMatch is the length of the shortest copies found
+	MinMatch = 4
+	// MaxMatch is the length of the longest copies found, longer repeated strings being split in several copies
+	MaxMatch = 1 << 16
+
+	// farOffset is the offset from which the copies of MinMatch bytes cost more than their literals
+	farOffset = 1 << 12
+
+	hashLog      = 16
+	hashMultiply = 0x9E3779B1
+	// maxBase is the position of the data from which the tables are reset, for the positions to fit in them
+	maxBase = 1 << 30
+)
+
+// Sequence is a run of literals followed by the copy of Length bytes found Offset bytes back.
+// The copy of the last sequence of a block can be empty, for the literals ending the block.
+type Sequence struct {
+	Literals int
+	Length   int
+	Offset   int
+}
+
+// Finder finds the copies of the data appended to it in the window of the data previously appended, with hash chains.
+// The depth is the number of candidates compared at each position, the lazy matching trying the next position
+// before taking a copy.
+type Finder struct {
+	window int
+	depth  int
+	lazy   bool
+	buf    []byte
+	// base is the position in the data of buf[0], the positions in the tables being the ones in the data plus one,
+	// zero meaning none
+	base    int
+	pending int // position in buf of the data not encoded yet
+	head    []int32
+	chain   []int32
+	// lastOffset is the offset of the last copy, tried first since the formats encode it in fewer bits
+	lastOffset int
+}
+
+// NewFinder returns a finder of the copies within 1<<windowLog bytes, trying depth candidates at each position
+func NewFinder(windowLog uint, depth int, lazy bool) *Finder {
+	if depth < 1 {
+		depth = 1
+	}
+	window := 1 << windowLog
+	return &Finder{
+		window: window,
+		depth:  depth,
+		lazy:   lazy,
+		buf:    make([]byte, 0, 2*window),
+		head:   make([]int32, 1<<hashLog),
+		chain:  make([]int32, window),
+	}
+}
+
+// Reset forgets the data appended, for the finder to be reused for another stream
+func (f *Finder) Reset() {
+	f.buf = f.buf[:0]
+	f.base = 0
+	f.pending = 0
+	f.lastOffset = 0
+	for i := range f.head {
+		f.head[i] = 0
+	}
+	for i := range f.chain {
+		f.chain[i] = 0
+	}
+}
+
+// Window returns the maximum offset of the copies, as well as the maximum size of the blocks
+func (f *Finder) Window() int {
+	return f.window
+}
+
+// Pending returns the number of bytes appended and not yet returned by Find
+func (f *Finder) Pending() int {
+	return len(f.buf) - f.pending
+}
+
+// Append appends the beginning of p to the data to find copies in, up to a window of pending data,
+// and returns the number of bytes appended
+func (f *Finder) Append(p []byte) int {
+	n := f.window - f.Pending()
+	if n > len(p) {
+		n = len(p)
+	}
+	if len(f.buf)+n > cap(f.buf) {
+		// only the window before the pending data is kept
+		shift := f.pending - f.window
+		if shift > 0 {
+			copy(f.buf, f.buf[shift:])
+			f.buf = f.buf[:len(f.buf)-shift]
+			f.pending -= shift
+			f.base += shift
+		}
+	}
+	f.buf = append(f.buf, p[:n]...)
+	return n
+}
+
+// Find returns the pending data as a block, with the sequences of literals and copies it is made of, appended to seqs.
+// The copies refer to the data of the block, and of the window of data before it.
+func (f *Finder) Find(seqs []Sequence) ([]Sequence, []byte) {
+	if f.base+len(f.buf) > maxBase {
+		f.resetTables()
+	}
+
+	start := f.pending
+	end := len(f.buf)
+	literals := 0
+	pos := start
+	for pos < end {
+		length, offset := f.longestMatch(pos, end)
+		if length >= MinMatch && f.lazy && pos+1 < end {
+			f.insert(pos)
+			if nextLength, nextOffset := f.longestMatch(pos+1, end); nextLength > length+1 {
+				literals++
+				pos++
+				length, offset = nextLength, nextOffset
+			}
+		}
+		if length < MinMatch || (length == MinMatch && offset > farOffset && offset != f.lastOffset) {
+			f.insert(pos)
+			literals++
+			pos++
+			continue
+		}
+
+		seqs = append(seqs, Sequence{Literals: literals, Length: length, Offset: offset})
+		literals = 0
+		f.lastOffset = offset
+		for last := pos + length; pos < last; pos++ {
+			f.insert(pos)
+		}
+	}
+	if literals > 0 {
+		seqs = append(seqs, Sequence{Literals: literals})
+	}
+
+	f.pending = end
+	return seqs, f.buf[start:end]
+}
+
+// longestMatch returns the longest copy of the data at pos, up to end, found within the depth of its hash chain
+func (f *Finder) longestMatch(pos, end int) (int, int) {
+	if end-pos < MinMatch {
+		return 0, 0
+	}
+	maxLength := end - pos
+	if maxLength > MaxMatch {
+		maxLength = MaxMatch
+	}
+	minPos := f.base + pos - f.window + 1
+	absPos := f.base + pos
+
+	bestLength, bestOffset := 0, 0
+	if c := pos - f.lastOffset; f.lastOffset > 0 && c >= 0 {
+		if length := f.matchLength(c, pos, maxLength); length >= MinMatch {
+			bestLength, bestOffset = length, f.lastOffset
+		}
+	}
+	candidate := int(f.head[f.hash(pos)]) - 1
+	for tries := 0; tries < f.depth && candidate >= 0 && candidate >= minPos && candidate < absPos; tries++ {
+		c := candidate - f.base
+		if bestLength < maxLength && f.buf[c+bestLength] == f.buf[pos+bestLength] {
+			if length := f.matchLength(c, pos, maxLength); length > bestLength {
+				bestLength, bestOffset = length, absPos-candidate
+				if length == maxLength {
+					break
+				}
+			}
+		}
+
+		next := int(f.chain[candidate&(f.window-1)]) - 1
+		if next >= candidate {
+			break
+		}
+		candidate = next
+	}
+	return bestLength, bestOffset
+}
+
+// matchLength returns the length of the common prefix of the data at c and pos, up to maxLength
+func (f *Finder) matchLength(c, pos, maxLength int) int {
+	length := 0
+	for length < maxLength && f.buf[c+length] == f.buf[pos+length] {
+		length++
+	}
+	return length
+}
+
+// insert adds the position to its hash chain, once
+func (f *Finder) insert(pos int) {
+	if len(f.buf)-pos < MinMatch {
+		return
+	}
+	absPos := f.base + pos
+	h := f.hash(pos)
+	if int(f.head[h]) == absPos+1 {
+		return
+	}
+	f.chain[absPos&(f.window-1)] = f.head[h]
+	f.head[h] = int32(absPos + 1)
+}
+
+func (f *Finder) hash(pos int) uint32 {
+	b := f.buf[pos : pos+MinMatch]
+	v := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+	return (v * hashMultiply) >> (32 - hashLog)
+}
+
+// resetTables moves the positions of the data back to 0, forgetting the previous data to find copies in
+func (f *Finder) resetTables() {
+	copy(f.buf, f.buf[f.pending:])
+	f.buf = f.buf[:len(f.buf)-f.pending]
+	f.base = 0
+	f.pending = 0
+	for i := range f.head {
+		f.head[i] = 0
+	}
+	for i := range f.chain {
+		f.chain[i] = 0
+	}
+}
@@ -0,0 +1,46 @@
+package zstd
+
+// bitWriter writes the bit streams of the zstd format: the bits are packed from the least significant ones,
+// and the stream is closed with a 1 bit, the decoders reading it backward from there
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// write writes the n least significant bits of value, n being at most 32
+func (b *bitWriter) write(value uint64, n uint) {
+	b.bits |= (value & (1<<n - 1)) << b.nbits
+	b.nbits += n
+	for b.nbits >= 8 {
+		b.out = append(b.out, byte(b.bits))
+		b.bits >>= 8
+		b.nbits -= 8
+	}
+}
+
+// close writes the final 1 bit and the padding of the last byte, and returns the stream
+func (b *bitWriter) close() []byte {
+	b.write(1, 1)
+	return b.align()
+}
+
+// align writes the padding of the last byte, and returns the stream
+func (b *bitWriter) align() []byte {
+	if b.nbits > 0 {
+		b.out = append(b.out, byte(b.bits))
+	}
+	out := b.out
+	b.out, b.bits, b.nbits = nil, 0, 0
+	return out
+}
+
+// highBit returns the position of the most significant bit set in v, v being positive
+func highBit(v uint32) uint {
+	n := uint(0)
+	for v > 1 {
+		v >>= 1
+		n++
+	}
+	return n
+}
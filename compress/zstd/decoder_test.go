@@ -0,0 +1,528 @@
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// decode decompresses the zstd frame, with a decoder limited to what the writer produces, following RFC 8878
+// independently of the writer
+func decode(frame []byte) ([]byte, error) {
+	if len(frame) < 6 || !bytes.Equal(frame[:4], magic) {
+		return nil, errors.New("not a zstd frame")
+	}
+	descriptor := frame[4]
+	if descriptor != 0 {
+		return nil, fmt.Errorf("unexpected frame header descriptor %#x", descriptor)
+	}
+	windowDescriptor := frame[5]
+	window := 1 << (10 + windowDescriptor>>3)
+	window += window / 8 * int(windowDescriptor&7)
+
+	d := &decoder{offsets: [3]int{1, 4, 8}, window: window}
+	data := frame[6:]
+	for {
+		if len(data) < 3 {
+			return nil, errors.New("truncated block header")
+		}
+		header := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+		data = data[3:]
+		last, blockType, size := header&1 == 1, header>>1&3, header>>3
+		switch blockType {
+		case blockRaw:
+			if len(data) < size {
+				return nil, errors.New("truncated raw block")
+			}
+			d.out = append(d.out, data[:size]...)
+			data = data[size:]
+		case blockRLE:
+			if len(data) < 1 {
+				return nil, errors.New("truncated RLE block")
+			}
+			d.out = append(d.out, bytes.Repeat(data[:1], size)...)
+			data = data[1:]
+		case blockCompressed:
+			if len(data) < size {
+				return nil, errors.New("truncated compressed block")
+			}
+			if err := d.decodeBlock(data[:size]); err != nil {
+				return nil, err
+			}
+			data = data[size:]
+		default:
+			return nil, errors.New("reserved block type")
+		}
+		if last {
+			break
+		}
+	}
+	if len(data) > 0 {
+		return nil, errors.New("data after the last block")
+	}
+	return d.out, nil
+}
+
+type decoder struct {
+	out     []byte
+	offsets [3]int
+	window  int
+}
+
+func (d *decoder) decodeBlock(block []byte) error {
+	literals, rest, err := decodeLiterals(block)
+	if err != nil {
+		return err
+	}
+	return d.decodeSequences(rest, literals)
+}
+
+// decodeLiterals returns the literals of the literals section of the block, and the sequences section
+func decodeLiterals(block []byte) ([]byte, []byte, error) {
+	if len(block) < 1 {
+		return nil, nil, errors.New("empty block")
+	}
+	literalsType, sizeFormat := block[0]&3, block[0]>>2&3
+	if literalsType == literalsRaw || literalsType == literalsRLE {
+		var size, headerSize int
+		switch sizeFormat {
+		case 0, 2:
+			size, headerSize = int(block[0]>>3), 1
+		case 1:
+			size, headerSize = int(block[0]>>4)|int(block[1])<<4, 2
+		default:
+			size, headerSize = int(block[0]>>4)|int(block[1])<<4|int(block[2])<<12, 3
+		}
+		block = block[headerSize:]
+		if literalsType == literalsRLE {
+			return bytes.Repeat(block[:1], size), block[1:], nil
+		}
+		return block[:size], block[size:], nil
+	}
+	if literalsType != literalsCompressed {
+		return nil, nil, errors.New("unexpected treeless literals")
+	}
+
+	var regenerated, compressed, headerSize int
+	streams := 4
+	switch sizeFormat {
+	case 0, 1:
+		h := int(block[0]) | int(block[1])<<8 | int(block[2])<<16
+		regenerated, compressed, headerSize = h>>4&0x3FF, h>>14&0x3FF, 3
+		if sizeFormat == 0 {
+			streams = 1
+		}
+	case 2:
+		h := int(block[0]) | int(block[1])<<8 | int(block[2])<<16 | int(block[3])<<24
+		regenerated, compressed, headerSize = h>>4&0x3FFF, h>>18&0x3FFF, 4
+	default:
+		h := int(block[0]) | int(block[1])<<8 | int(block[2])<<16 | int(block[3])<<24 | int(block[4])<<32
+		regenerated, compressed, headerSize = h>>4&0x3FFFF, h>>22&0x3FFFF, 5
+	}
+	data, rest := block[headerSize:headerSize+compressed], block[headerSize+compressed:]
+
+	table, maxBits, data, err := decodeHuffmanTree(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var literals []byte
+	if streams == 1 {
+		literals, err = decodeHuffmanStream(data, regenerated, table, maxBits)
+		return literals, rest, err
+	}
+	sizes := []int{int(data[0]) | int(data[1])<<8, int(data[2]) | int(data[3])<<8, int(data[4]) | int(data[5])<<8}
+	data = data[6:]
+	segment := (regenerated + 3) / 4
+	for i := 0; i < 4; i++ {
+		stream, count := data, segment
+		if i < 3 {
+			stream = data[:sizes[i]]
+			data = data[sizes[i]:]
+		} else {
+			count = regenerated - 3*segment
+		}
+		decoded, err := decodeHuffmanStream(stream, count, table, maxBits)
+		if err != nil {
+			return nil, nil, err
+		}
+		literals = append(literals, decoded...)
+	}
+	return literals, rest, nil
+}
+
+type huffmanEntry struct {
+	symbol byte
+	nbBits uint
+}
+
+// decodeHuffmanTree returns the decoding table of the Huffman tree description, indexed by the next maxBits bits
+func decodeHuffmanTree(data []byte) ([]huffmanEntry, uint, []byte, error) {
+	var weights []int
+	header := int(data[0])
+	if header >= 128 {
+		count := header - 127
+		for i := 0; i < count; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, int(b>>4))
+			} else {
+				weights = append(weights, int(b&0xF))
+			}
+		}
+		data = data[1+(count+1)/2:]
+	} else {
+		var err error
+		weights, err = decodeCompressedWeights(data[1 : 1+header])
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		data = data[1+header:]
+	}
+
+	// the weight of the last symbol completes the sum of the weights to the next power of 2
+	sum := 0
+	for _, weight := range weights {
+		if weight > 0 {
+			sum += 1 << uint(weight-1)
+		}
+	}
+	maxBits := highBit(uint32(sum)) + 1
+	rest := 1<<maxBits - sum
+	if rest&(rest-1) != 0 {
+		return nil, 0, nil, errors.New("invalid Huffman weights")
+	}
+	weights = append(weights, int(highBit(uint32(rest)))+1)
+
+	// the symbols fill the table by increasing weights, then symbols
+	table := make([]huffmanEntry, 0, 1<<maxBits)
+	for weight := 1; weight <= int(maxBits); weight++ {
+		for symbol, w := range weights {
+			if w != weight {
+				continue
+			}
+			for i := 0; i < 1<<uint(weight-1); i++ {
+				table = append(table, huffmanEntry{symbol: byte(symbol), nbBits: maxBits + 1 - uint(weight)})
+			}
+		}
+	}
+	return table, maxBits, data, nil
+}
+
+func decodeHuffmanStream(stream []byte, count int, table []huffmanEntry, maxBits uint) ([]byte, error) {
+	r, err := newBackwardReader(stream)
+	if err != nil {
+		return nil, err
+	}
+	literals := make([]byte, 0, count)
+	for len(literals) < count {
+		entry := table[r.peek(maxBits)]
+		r.skip(entry.nbBits)
+		literals = append(literals, entry.symbol)
+	}
+	if r.pos != 0 {
+		return nil, fmt.Errorf("Huffman stream not fully consumed: %d bits left", r.pos)
+	}
+	return literals, nil
+}
+
+// decodeCompressedWeights returns the weights decoded with two interleaved FSE states
+func decodeCompressedWeights(data []byte) ([]int, error) {
+	table, data, err := decodeDistribution(data, maxWeightsLog)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newBackwardReader(data)
+	if err != nil {
+		return nil, err
+	}
+	states := [2]int{r.read(table.log), r.read(table.log)}
+	var weights []int
+	for i := 0; ; i = 1 - i {
+		weights = append(weights, table.symbols[states[i]])
+		states[i] = table.update(r, states[i])
+		if r.overflow() {
+			weights = append(weights, table.symbols[states[1-i]])
+			return weights, nil
+		}
+	}
+}
+
+// decodingTable is the FSE decoding table of a distribution
+type decodingTable struct {
+	log      uint
+	symbols  []int
+	nbBits   []uint
+	baseline []int
+}
+
+func newDecodingTable(distribution []int16, log uint) *decodingTable {
+	size := 1 << log
+	t := &decodingTable{log: log, symbols: make([]int, size), nbBits: make([]uint, size), baseline: make([]int, size)}
+	counts := make([]int, len(distribution))
+	high := size - 1
+	for symbol, probability := range distribution {
+		if probability == -1 {
+			t.symbols[high] = symbol
+			high--
+			counts[symbol] = 1
+		} else {
+			counts[symbol] = int(probability)
+		}
+	}
+	position := 0
+	for symbol, probability := range distribution {
+		for i := 0; i < int(probability); i++ {
+			t.symbols[position] = symbol
+			for {
+				position = (position + size>>1 + size>>3 + 3) & (size - 1)
+				if position <= high {
+					break
+				}
+			}
+		}
+	}
+	for state := 0; state < size; state++ {
+		n := counts[t.symbols[state]]
+		counts[t.symbols[state]]++
+		nbBits := log - highBit(uint32(n))
+		t.nbBits[state] = nbBits
+		t.baseline[state] = n<<nbBits - size
+	}
+	return t
+}
+
+func (t *decodingTable) update(r *backwardReader, state int) int {
+	return t.baseline[state] + r.read(t.nbBits[state])
+}
+
+// decodeDistribution returns the decoding table of the FSE table description, and the data following it
+func decodeDistribution(data []byte, maxLog uint) (*decodingTable, []byte, error) {
+	r := &forwardReader{data: data}
+	log := uint(r.read(4)) + minTableLog
+	if log > maxLog {
+		return nil, nil, fmt.Errorf("accuracy log %d too large", log)
+	}
+	remaining, threshold, nbBits := 1<<log+1, 1<<log, log+1
+	var distribution []int16
+	for remaining > 1 {
+		max := 2*threshold - 1 - remaining
+		value := r.peek(nbBits)
+		if value&(threshold-1) < max {
+			value &= threshold - 1
+			r.skip(nbBits - 1)
+		} else {
+			if value >= threshold {
+				value -= max
+			}
+			r.skip(nbBits)
+		}
+		probability := value - 1
+		distribution = append(distribution, int16(probability))
+		if probability < 0 {
+			remaining--
+		} else {
+			remaining -= probability
+		}
+		if probability == 0 {
+			for {
+				repeat := r.read(2)
+				for i := 0; i < repeat; i++ {
+					distribution = append(distribution, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+		}
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 {
+		return nil, nil, errors.New("invalid distribution")
+	}
+	return newDecodingTable(distribution, log), data[(r.pos+7)/8:], nil
+}
+
+// decodeSequences decodes and executes the sequences section of the block, with the literals of the block
+func (d *decoder) decodeSequences(data []byte, literals []byte) error {
+	count := int(data[0])
+	switch {
+	case count == 0:
+		d.out = append(d.out, literals...)
+		return nil
+	case count < 128:
+		data = data[1:]
+	case count < 255:
+		count = (count-128)<<8 + int(data[1])
+		data = data[2:]
+	default:
+		count = int(data[1]) + int(data[2])<<8 + 0x7F00
+		data = data[3:]
+	}
+
+	modes := data[0]
+	data = data[1:]
+	var tables [3]*decodingTable
+	predefined := []struct {
+		distribution []int16
+		log          uint
+		maxLog       uint
+	}{
+		{literalLengthsDistribution, 6, maxLiteralLengthsLog},
+		{offsetsDistribution, 5, maxOffsetsLog},
+		{matchLengthsDistribution, 6, maxMatchLengthsLog},
+	}
+	for i := range tables {
+		switch mode := modes >> uint(6-2*i) & 3; mode {
+		case modePredefined:
+			tables[i] = newDecodingTable(predefined[i].distribution, predefined[i].log)
+		case modeRLE:
+			distribution := make([]int16, data[0]+1)
+			distribution[data[0]] = 1
+			tables[i] = newDecodingTable(distribution, 0)
+			data = data[1:]
+		case modeCompressed:
+			var err error
+			if tables[i], data, err = decodeDistribution(data, predefined[i].maxLog); err != nil {
+				return err
+			}
+		default:
+			return errors.New("unexpected repeat mode")
+		}
+	}
+	literalLengths, offsets, matchLengths := tables[0], tables[1], tables[2]
+
+	r, err := newBackwardReader(data)
+	if err != nil {
+		return err
+	}
+	literalLengthState := r.read(literalLengths.log)
+	offsetState := r.read(offsets.log)
+	matchLengthState := r.read(matchLengths.log)
+	for i := 0; i < count; i++ {
+		offsetCode := uint(offsets.symbols[offsetState])
+		matchLengthCode := matchLengths.symbols[matchLengthState]
+		literalLengthCode := literalLengths.symbols[literalLengthState]
+
+		offsetValue := 1<<offsetCode + r.read(offsetCode)
+		matchLength := matchLengthCode + 3
+		if matchLengthCode >= 32 {
+			matchLength = int(matchLengthsBaselines[matchLengthCode-32]) + r.read(matchLengthsBits[matchLengthCode-32])
+		}
+		literalLength := literalLengthCode
+		if literalLengthCode >= 16 {
+			literalLength = int(literalLengthsBaselines[literalLengthCode-16]) + r.read(literalLengthsBits[literalLengthCode-16])
+		}
+		if i < count-1 {
+			literalLengthState = literalLengths.update(r, literalLengthState)
+			matchLengthState = matchLengths.update(r, matchLengthState)
+			offsetState = offsets.update(r, offsetState)
+		}
+
+		if literalLength > len(literals) {
+			return errors.New("literal length beyond the literals")
+		}
+		d.out = append(d.out, literals[:literalLength]...)
+		literals = literals[literalLength:]
+		offset := d.offset(offsetValue, literalLength)
+		if offset > len(d.out) || offset > d.window {
+			return fmt.Errorf("offset %d beyond the output or the window", offset)
+		}
+		for j := 0; j < matchLength; j++ {
+			d.out = append(d.out, d.out[len(d.out)-offset])
+		}
+	}
+	if r.pos != 0 {
+		return fmt.Errorf("sequences stream not fully consumed: %d bits left", r.pos)
+	}
+	d.out = append(d.out, literals...)
+	return nil
+}
+
+// offset returns the offset of the offset value, and updates the repeated offsets
+func (d *decoder) offset(value, literalLength int) int {
+	r := &d.offsets
+	if value > 3 {
+		r[0], r[1], r[2] = value-3, r[0], r[1]
+		return r[0]
+	}
+	if literalLength == 0 {
+		value++
+	}
+	switch value {
+	case 1:
+	case 2:
+		r[0], r[1] = r[1], r[0]
+	case 3:
+		r[0], r[1], r[2] = r[2], r[0], r[1]
+	default:
+		r[0], r[1], r[2] = r[0]-1, r[0], r[1]
+	}
+	return r[0]
+}
+
+// backwardReader reads a bit stream from its end, the highest bits first, past the final 1 bit
+type backwardReader struct {
+	data []byte
+	pos  int
+}
+
+func newBackwardReader(data []byte) (*backwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errors.New("bit stream without final bit")
+	}
+	return &backwardReader{data: data, pos: 8*(len(data)-1) + int(highBit(uint32(data[len(data)-1])))}, nil
+}
+
+func (r *backwardReader) peek(n uint) int {
+	value := 0
+	for i := 1; i <= int(n); i++ {
+		value <<= 1
+		if bit := r.pos - i; bit >= 0 {
+			value |= int(r.data[bit/8]>>uint(bit%8)) & 1
+		}
+	}
+	return value
+}
+
+func (r *backwardReader) skip(n uint) {
+	r.pos -= int(n)
+}
+
+func (r *backwardReader) read(n uint) int {
+	value := r.peek(n)
+	r.skip(n)
+	return value
+}
+
+func (r *backwardReader) overflow() bool {
+	return r.pos < 0
+}
+
+// forwardReader reads a bit stream from its beginning, the lowest bits first
+type forwardReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *forwardReader) peek(n uint) int {
+	value := 0
+	for i := 0; i < int(n); i++ {
+		if bit := r.pos + i; bit < 8*len(r.data) {
+			value |= int(r.data[bit/8]>>uint(bit%8)) & 1 << uint(i)
+		}
+	}
+	return value
+}
+
+func (r *forwardReader) skip(n uint) {
+	r.pos += int(n)
+}
+
+func (r *forwardReader) read(n uint) int {
+	value := r.peek(n)
+	r.skip(n)
+	return value
+}
@@ -0,0 +1,217 @@
+package zstd
+
+import "math"
+
+const minTableLog = 5
+
+// fseTable is the table of the finite state entropy coding of the symbols of a distribution
+type fseTable struct {
+	log      uint
+	nbBits   []uint8
+	baseline []uint16
+	// states holds, for each symbol, the state whose range of next states contains each state
+	states [][]uint16
+}
+
+// newFSETable builds the decoding table of the distribution as specified by RFC 8878, and how to go back
+// from the states to the previous ones, for the encoder processing the symbols backward
+func newFSETable(distribution []int16, log uint) *fseTable {
+	size := 1 << log
+	symbols := make([]int, size)
+	next := make([]int, len(distribution))
+
+	// the less than 1 probabilities are at the end of the table, the other ones being spread
+	high := size - 1
+	for symbol, probability := range distribution {
+		if probability == -1 {
+			symbols[high] = symbol
+			high--
+			next[symbol] = 1
+		}
+	}
+	position, step, mask := 0, size>>1+size>>3+3, size-1
+	for symbol, probability := range distribution {
+		if probability <= 0 {
+			continue
+		}
+		next[symbol] = int(probability)
+		for i := 0; i < int(probability); i++ {
+			symbols[position] = symbol
+			position = (position + step) & mask
+			for position > high {
+				position = (position + step) & mask
+			}
+		}
+	}
+
+	table := &fseTable{
+		log:      log,
+		nbBits:   make([]uint8, size),
+		baseline: make([]uint16, size),
+		states:   make([][]uint16, len(distribution)),
+	}
+	for symbol := range table.states {
+		table.states[symbol] = make([]uint16, size)
+	}
+	for state, symbol := range symbols {
+		n := next[symbol]
+		next[symbol]++
+		nbBits := log - highBit(uint32(n))
+		table.nbBits[state] = uint8(nbBits)
+		table.baseline[state] = uint16(n<<nbBits - size)
+		for nextState := int(table.baseline[state]); nextState < int(table.baseline[state])+1<<nbBits; nextState++ {
+			table.states[symbol][nextState] = uint16(state)
+		}
+	}
+	return table
+}
+
+// encode writes the bits going from the state of the symbol to the current one, and returns the state of the symbol
+func (t *fseTable) encode(b *bitWriter, symbol uint8, current uint16) uint16 {
+	state := t.states[symbol][current]
+	b.write(uint64(current-t.baseline[state]), uint(t.nbBits[state]))
+	return state
+}
+
+// readingState returns the state of the symbol reading the most bits to go to the previous one
+func (t *fseTable) readingState(symbol uint8) uint16 {
+	state := t.states[symbol][0]
+	for _, s := range t.states[symbol] {
+		if t.nbBits[s] > t.nbBits[state] {
+			state = s
+		}
+	}
+	return state
+}
+
+// normalize returns the distribution of the counts, summing to 1<<log, every symbol counted having a probability
+func normalize(counts []int, maxLog uint) ([]int16, uint) {
+	total, maxSymbol := 0, 0
+	for symbol, count := range counts {
+		total += count
+		if count > 0 {
+			maxSymbol = symbol
+		}
+	}
+
+	// the accuracy is the one the reference implementation chooses for the number of symbols
+	log := int(maxLog)
+	if bits := int(highBit(uint32(total-1))) - 2; bits < log {
+		log = bits
+	}
+	minBits := int(highBit(uint32(total))) + 1
+	if bits := int(highBit(uint32(maxSymbol))) + 2; bits < minBits {
+		minBits = bits
+	}
+	if minBits > log {
+		log = minBits
+	}
+	if log < minTableLog {
+		log = minTableLog
+	}
+	if log > int(maxLog) {
+		log = int(maxLog)
+	}
+
+	size := 1 << uint(log)
+	distribution := make([]int16, maxSymbol+1)
+	sum, largest := 0, 0
+	for symbol, count := range counts[:maxSymbol+1] {
+		if count == 0 {
+			continue
+		}
+		probability := (count*size + total/2) / total
+		if probability < 1 {
+			probability = 1
+		}
+		distribution[symbol] = int16(probability)
+		sum += probability
+		if distribution[symbol] > distribution[largest] {
+			largest = symbol
+		}
+	}
+	// the rounding difference is given to, or taken from, the most probable symbols
+	for sum > size {
+		largest := 0
+		for symbol, probability := range distribution {
+			if probability > distribution[largest] {
+				largest = symbol
+			}
+		}
+		distribution[largest]--
+		sum--
+	}
+	distribution[largest] += int16(size - sum)
+	return distribution, uint(log)
+}
+
+// cost returns the number of bits of the symbols with the counts encoded with the distribution
+func cost(counts []int, distribution []int16, log uint) float64 {
+	bits := 0.0
+	for symbol, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if symbol >= len(distribution) || distribution[symbol] == 0 {
+			return math.Inf(1)
+		}
+		probability := float64(distribution[symbol])
+		if probability < 0 {
+			probability = 1
+		}
+		bits += float64(count) * (float64(log) - math.Log2(probability))
+	}
+	return bits
+}
+
+// appendDistribution appends the description of the distribution, as the reference implementation writes it
+func appendDistribution(out []byte, distribution []int16, log uint) []byte {
+	b := &bitWriter{out: out}
+	b.write(uint64(log-minTableLog), 4)
+
+	size := 1 << log
+	remaining, threshold, nbBits := size+1, size, log+1
+	previousZero := false
+	for symbol := 0; symbol < len(distribution) && remaining > 1; {
+		if previousZero {
+			// the runs of symbols of probability 0 are written in repeat flags
+			start := symbol
+			for distribution[symbol] == 0 {
+				symbol++
+			}
+			for symbol >= start+24 {
+				start += 24
+				b.write(0xFFFF, 16)
+			}
+			for symbol >= start+3 {
+				start += 3
+				b.write(3, 2)
+			}
+			b.write(uint64(symbol-start), 2)
+		}
+
+		probability := int(distribution[symbol])
+		symbol++
+		max := 2*threshold - 1 - remaining
+		if probability < 0 {
+			remaining--
+		} else {
+			remaining -= probability
+		}
+		value := probability + 1
+		if value >= threshold {
+			value += max
+		}
+		if value < max {
+			b.write(uint64(value), nbBits-1)
+		} else {
+			b.write(uint64(value), nbBits)
+		}
+		previousZero = value == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	return b.align()
+}
@@ -0,0 +1,223 @@
+package zstd
+
+import (
+	"sort"
+
+	"github.com/containous/traefik/compress/internal/huffman"
+)
+
+const (
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+
+	maxHuffmanBits = 11
+	maxWeightsLog  = 6
+	// maxCompressedWeights is the greatest size of the weights compressed with FSE
+	maxCompressedWeights = 127
+	// maxDirectSymbol is the greatest symbol of the Huffman trees described with their weights written directly
+	maxDirectSymbol = 128
+	// maxSingleStream is the greatest number of literals encoded in a single Huffman stream
+	maxSingleStream = 1023
+)
+
+// appendLiterals appends the literals section of the literals, Huffman coded when it is smaller
+func appendLiterals(out []byte, literals []byte) []byte {
+	if len(literals) == 0 {
+		return appendLiteralsHeader(out, literalsRaw, 0)
+	}
+
+	var frequencies [256]int
+	maxSymbol := 0
+	for _, literal := range literals {
+		frequencies[literal]++
+		if int(literal) > maxSymbol {
+			maxSymbol = int(literal)
+		}
+	}
+	if frequencies[literals[0]] == len(literals) {
+		return append(appendLiteralsHeader(out, literalsRLE, len(literals)), literals[0])
+	}
+
+	if compressed := appendHuffmanLiterals(nil, literals, frequencies[:maxSymbol+1]); compressed != nil {
+		return append(out, compressed...)
+	}
+	return append(appendLiteralsHeader(out, literalsRaw, len(literals)), literals...)
+}
+
+// appendLiteralsHeader appends the header of the raw or RLE literals
+func appendLiteralsHeader(out []byte, literalsType byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(out, literalsType|byte(size)<<3)
+	case size < 4096:
+		return append(out, literalsType|1<<2|byte(size&0xF)<<4, byte(size>>4))
+	default:
+		return append(out, literalsType|3<<2|byte(size&0xF)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// appendHuffmanLiterals appends the Huffman coded literals, and returns nil if they are not smaller than the raw ones
+func appendHuffmanLiterals(out []byte, literals []byte, frequencies []int) []byte {
+	lengths := huffman.Lengths(frequencies, maxHuffmanBits)
+	maxBits := uint8(0)
+	for _, length := range lengths {
+		if length > maxBits {
+			maxBits = length
+		}
+	}
+
+	weights := make([]uint8, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			weights[symbol] = maxBits + 1 - length
+		}
+	}
+	tree := huffmanTree(weights)
+	if tree == nil {
+		return nil
+	}
+	codes := huffmanCodes(weights, lengths)
+
+	var streams [][]byte
+	if len(literals) <= maxSingleStream {
+		streams = [][]byte{huffmanStream(literals, codes, lengths)}
+	} else {
+		segment := (len(literals) + 3) / 4
+		for i := 0; i < 4; i++ {
+			end := (i + 1) * segment
+			if end > len(literals) {
+				end = len(literals)
+			}
+			streams = append(streams, huffmanStream(literals[i*segment:end], codes, lengths))
+		}
+	}
+
+	size := len(tree)
+	for _, stream := range streams {
+		size += len(stream)
+	}
+	if len(streams) > 1 {
+		// the jump table holds the sizes of the first three streams
+		size += 6
+	}
+	if size >= len(literals) {
+		return nil
+	}
+
+	regenerated := uint64(len(literals))
+	switch {
+	case len(streams) == 1:
+		if size > maxSingleStream {
+			return nil
+		}
+		h := literalsCompressed | regenerated<<4 | uint64(size)<<14
+		out = append(out, byte(h), byte(h>>8), byte(h>>16))
+	case len(literals) < 1<<14 && size < 1<<14:
+		h := literalsCompressed | 2<<2 | regenerated<<4 | uint64(size)<<18
+		out = append(out, byte(h), byte(h>>8), byte(h>>16), byte(h>>24))
+	default:
+		h := literalsCompressed | 3<<2 | regenerated<<4 | uint64(size)<<22
+		out = append(out, byte(h), byte(h>>8), byte(h>>16), byte(h>>24), byte(h>>32))
+	}
+	out = append(out, tree...)
+	if len(streams) > 1 {
+		for _, stream := range streams[:3] {
+			out = append(out, byte(len(stream)), byte(len(stream)>>8))
+		}
+	}
+	for _, stream := range streams {
+		out = append(out, stream...)
+	}
+	return out
+}
+
+// huffmanTree returns the description of the Huffman tree with the weights of all its symbols but the last one,
+// the decoder deducing it, the weights being compressed with FSE when it is smaller or when there are too many of them
+func huffmanTree(weights []uint8) []byte {
+	weights = weights[:len(weights)-1]
+	tree := compressedWeights(weights)
+	if len(weights) > maxDirectSymbol || (tree != nil && len(tree) <= 1+(len(weights)+1)/2) {
+		return tree
+	}
+
+	tree = []byte{byte(127 + len(weights))}
+	for i := 0; i < len(weights); i += 2 {
+		b := weights[i] << 4
+		if i+1 < len(weights) {
+			b |= weights[i+1]
+		}
+		tree = append(tree, b)
+	}
+	return tree
+}
+
+// compressedWeights returns the weights compressed with FSE, with two states encoding the weights alternately,
+// or nil if they can't be
+func compressedWeights(weights []uint8) []byte {
+	var counts [maxHuffmanBits + 1]int
+	symbols := 0
+	for _, weight := range weights {
+		if counts[weight] == 0 {
+			symbols++
+		}
+		counts[weight]++
+	}
+	if symbols < 2 {
+		return nil
+	}
+
+	distribution, log := normalize(counts[:], maxWeightsLog)
+	table := newFSETable(distribution, log)
+	b := &bitWriter{out: appendDistribution(nil, distribution, log)}
+
+	// the decoder ends when the updates of the states of the last weights overflow the stream, the states
+	// of the last weights being ones reading bits
+	var states [2]uint16
+	n := len(weights)
+	states[(n-1)%2] = table.readingState(weights[n-1])
+	states[(n-2)%2] = table.readingState(weights[n-2])
+	for i := n - 3; i >= 0; i-- {
+		states[i%2] = table.encode(b, weights[i], states[i%2])
+	}
+	b.write(uint64(states[1]), log)
+	b.write(uint64(states[0]), log)
+	stream := b.close()
+	if len(stream) > maxCompressedWeights {
+		return nil
+	}
+	return append([]byte{byte(len(stream))}, stream...)
+}
+
+// huffmanCodes returns the codes of the symbols, assigned from the lowest weights as the decoder does
+func huffmanCodes(weights, lengths []uint8) []uint16 {
+	var symbols []int
+	for symbol, weight := range weights {
+		if weight > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return weights[symbols[i]] < weights[symbols[j]]
+	})
+
+	codes := make([]uint16, len(weights))
+	code, length := uint16(0), lengths[symbols[0]]
+	for i, symbol := range symbols {
+		if i > 0 {
+			code = (code + 1) >> (length - lengths[symbol])
+			length = lengths[symbol]
+		}
+		codes[symbol] = code
+	}
+	return codes
+}
+
+// huffmanStream returns the Huffman stream of the literals, written backward for the decoder to read them forward
+func huffmanStream(literals []byte, codes []uint16, lengths []uint8) []byte {
+	b := &bitWriter{out: make([]byte, 0, len(literals))}
+	for i := len(literals) - 1; i >= 0; i-- {
+		b.write(uint64(codes[literals[i]]), uint(lengths[literals[i]]))
+	}
+	return b.close()
+}
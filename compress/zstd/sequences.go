@@ -0,0 +1,208 @@
+package zstd
+
+import "github.com/containous/traefik/compress/internal/lz"
+
+// The default distributions of the literal lengths, match lengths and offsets codes, the -1 probabilities being
+// the "less than 1" ones, used by the sequences encoded in the predefined mode
+var (
+	literalLengthsDistribution = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	matchLengthsDistribution = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	offsetsDistribution = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+
+	literalLengthsTable = newFSETable(literalLengthsDistribution, 6)
+	matchLengthsTable   = newFSETable(matchLengthsDistribution, 6)
+	offsetsTable        = newFSETable(offsetsDistribution, 5)
+)
+
+const (
+	modePredefined = 0
+	modeRLE        = 1
+	modeCompressed = 2
+
+	maxLiteralLengthsLog = 9
+	maxMatchLengthsLog   = 9
+	maxOffsetsLog        = 8
+	maxOffsetCode        = 31
+)
+
+// The baselines and numbers of extra bits of the literal lengths codes from 16, and of the match lengths codes from 32
+var (
+	literalLengthsBaselines = []uint32{16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536}
+	literalLengthsBits      = []uint{1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	matchLengthsBaselines   = []uint32{35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051, 4099, 8195, 16387, 32771, 65539}
+	matchLengthsBits        = []uint{1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+)
+
+// sequenceCodes holds the codes of a sequence and their extra bits
+type sequenceCodes struct {
+	literalLength, matchLength, offset                uint8
+	literalLengthBits, matchLengthBits, offsetBits    uint
+	literalLengthExtra, matchLengthExtra, offsetExtra uint32
+}
+
+// repeatedOffsets holds the offsets of the last copies, the copies from them sending their rank instead
+type repeatedOffsets [3]int
+
+var initialOffsets = repeatedOffsets{1, 4, 8}
+
+// value returns the offset value of the copy, updating the repeated offsets as the decoder does
+func (r *repeatedOffsets) value(offset, literals int) uint32 {
+	if literals > 0 {
+		switch offset {
+		case r[0]:
+			return 1
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 2
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 3
+		}
+	} else {
+		// the first repeated offset would have been part of the previous copy
+		switch offset {
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 1
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 2
+		case r[0] - 1:
+			r[0], r[1], r[2] = r[0]-1, r[0], r[1]
+			return 3
+		}
+	}
+	r[0], r[1], r[2] = offset, r[0], r[1]
+	return uint32(offset + 3)
+}
+
+func newSequenceCodes(sequence lz.Sequence, offsetValue uint32) sequenceCodes {
+	var codes sequenceCodes
+
+	literalLength := uint32(sequence.Literals)
+	if literalLength < 16 {
+		codes.literalLength = uint8(literalLength)
+	} else {
+		code := len(literalLengthsBaselines) - 1
+		for literalLengthsBaselines[code] > literalLength {
+			code--
+		}
+		codes.literalLength = uint8(16 + code)
+		codes.literalLengthBits = literalLengthsBits[code]
+		codes.literalLengthExtra = literalLength - literalLengthsBaselines[code]
+	}
+
+	matchLength := uint32(sequence.Length)
+	if matchLength < 35 {
+		codes.matchLength = uint8(matchLength - 3)
+	} else {
+		code := len(matchLengthsBaselines) - 1
+		for matchLengthsBaselines[code] > matchLength {
+			code--
+		}
+		codes.matchLength = uint8(32 + code)
+		codes.matchLengthBits = matchLengthsBits[code]
+		codes.matchLengthExtra = matchLength - matchLengthsBaselines[code]
+	}
+
+	codes.offsetBits = highBit(offsetValue)
+	codes.offset = uint8(codes.offsetBits)
+	codes.offsetExtra = offsetValue - 1<<codes.offsetBits
+	return codes
+}
+
+// appendSequences appends the sequences section of the sequences with copies, the codes being encoded
+// with the predefined distributions or with the ones of the block, whichever is smaller
+func appendSequences(out []byte, sequences []lz.Sequence, offsets *repeatedOffsets) []byte {
+	count := len(sequences)
+	switch {
+	case count < 128:
+		out = append(out, byte(count))
+	case count < 0x7F00:
+		out = append(out, byte(count>>8+128), byte(count))
+	default:
+		out = append(out, 0xFF, byte(count-0x7F00), byte((count-0x7F00)>>8))
+	}
+	if count == 0 {
+		return out
+	}
+
+	codes := make([]sequenceCodes, count)
+	var literalLengthsCounts, matchLengthsCounts, offsetsCounts [64]int
+	for i, sequence := range sequences {
+		codes[i] = newSequenceCodes(sequence, offsets.value(sequence.Offset, sequence.Literals))
+		literalLengthsCounts[codes[i].literalLength]++
+		matchLengthsCounts[codes[i].matchLength]++
+		offsetsCounts[codes[i].offset]++
+	}
+
+	modesAt := len(out)
+	out = append(out, 0)
+	var literalLengthsMode, offsetsMode, matchLengthsMode byte
+	var literalLengths, offsetsCodes, matchLengths *fseTable
+	out, literalLengthsMode, literalLengths = appendTable(out, literalLengthsCounts[:len(literalLengthsDistribution)], literalLengthsTable, literalLengthsDistribution, maxLiteralLengthsLog)
+	out, offsetsMode, offsetsCodes = appendTable(out, offsetsCounts[:maxOffsetCode+1], offsetsTable, offsetsDistribution, maxOffsetsLog)
+	out, matchLengthsMode, matchLengths = appendTable(out, matchLengthsCounts[:len(matchLengthsDistribution)], matchLengthsTable, matchLengthsDistribution, maxMatchLengthsLog)
+	out[modesAt] = literalLengthsMode<<6 | offsetsMode<<4 | matchLengthsMode<<2
+
+	// the sequences are encoded backward, for the decoder to read them forward from the end of the stream
+	b := &bitWriter{out: out}
+	last := codes[count-1]
+	literalLengthState := literalLengths.states[last.literalLength][0]
+	matchLengthState := matchLengths.states[last.matchLength][0]
+	offsetState := offsetsCodes.states[last.offset][0]
+	writeExtraBits(b, last)
+	for i := count - 2; i >= 0; i-- {
+		offsetState = offsetsCodes.encode(b, codes[i].offset, offsetState)
+		matchLengthState = matchLengths.encode(b, codes[i].matchLength, matchLengthState)
+		literalLengthState = literalLengths.encode(b, codes[i].literalLength, literalLengthState)
+		writeExtraBits(b, codes[i])
+	}
+	b.write(uint64(matchLengthState), matchLengths.log)
+	b.write(uint64(offsetState), offsetsCodes.log)
+	b.write(uint64(literalLengthState), literalLengths.log)
+	return b.close()
+}
+
+// appendTable chooses how to encode the codes with the counts, appends the description of their table if any,
+// and returns the mode and the table
+func appendTable(out []byte, counts []int, predefined *fseTable, predefinedDistribution []int16, maxLog uint) ([]byte, byte, *fseTable) {
+	symbols, symbol := 0, 0
+	for s, count := range counts {
+		if count > 0 {
+			symbols++
+			symbol = s
+		}
+	}
+	if symbols == 1 {
+		distribution := make([]int16, symbol+1)
+		distribution[symbol] = 1
+		return append(out, byte(symbol)), modeRLE, newFSETable(distribution, 0)
+	}
+
+	distribution, log := normalize(counts, maxLog)
+	description := appendDistribution(nil, distribution, log)
+	if cost(counts, distribution, log)+float64(8*len(description)) >= cost(counts, predefinedDistribution, predefined.log) {
+		return out, modePredefined, predefined
+	}
+	return append(out, description...), modeCompressed, newFSETable(distribution, log)
+}
+
+// writeExtraBits writes the extra bits of the codes, backward to the order the decoder reads them in
+func writeExtraBits(b *bitWriter, codes sequenceCodes) {
+	b.write(uint64(codes.literalLengthExtra), codes.literalLengthBits)
+	b.write(uint64(codes.matchLengthExtra), codes.matchLengthBits)
+	b.write(uint64(codes.offsetExtra), codes.offsetBits)
+}
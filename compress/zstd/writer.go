@@ -0,0 +1,192 @@
+// Package zstd implements a writer of the Zstandard compressed data format, as specified by RFC 8878.
+//
+// The writer finds the copies with hash chains, and encodes the literals with Huffman codes and the sequences
+// with the predefined distributions of the format, within a window of 128KB.
+package zstd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containous/traefik/compress/internal/lz"
+)
+
+const (
+	// BestSpeed is the fastest compression level
+	BestSpeed = 1
+	// BestCompression is the compression level with the smallest output
+	BestCompression = 19
+	// DefaultCompression is the compression level of the writers created by NewWriter
+	DefaultCompression = 3
+
+	windowLog = 17
+
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+)
+
+var (
+	magic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+	// depths holds the number of candidates compared at each position, by level
+	depths = []int{1, 2, 4, 8, 8, 16, 16, 32, 32, 64, 64, 128, 128, 256, 256, 512, 512, 1024, 1024}
+
+	errClosed = errors.New("zstd: write to a closed writer")
+)
+
+// Writer is an io.WriteCloser compressing the data written to it in a zstd frame
+type Writer struct {
+	w           io.Writer
+	finder      *lz.Finder
+	sequences   []lz.Sequence
+	literals    []byte
+	offsets     repeatedOffsets
+	out         []byte
+	wroteHeader bool
+	closed      bool
+	err         error
+}
+
+// NewWriter returns a writer compressing the data written to it to w, with the default level
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel returns a writer compressing the data written to it to w, with the level between BestSpeed
+// and BestCompression
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	return &Writer{
+		w:       w,
+		finder:  lz.NewFinder(windowLog, depths[level-1], level >= DefaultCompression),
+		offsets: initialOffsets,
+	}, nil
+}
+
+// Reset discards the state of the writer, for it to compress to w as a new one with the same level
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.finder.Reset()
+	z.offsets = initialOffsets
+	z.wroteHeader = false
+	z.closed = false
+	z.err = nil
+}
+
+// Write compresses p, the data being written once a block is full, or on Flush and Close
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errClosed
+	}
+	written := 0
+	for written < len(p) {
+		// the block is encoded once more data comes, the last one being encoded on Close
+		if z.finder.Pending() == z.finder.Window() {
+			if err := z.writeBlock(false); err != nil {
+				return written, err
+			}
+		}
+		written += z.finder.Append(p[written:])
+	}
+	return written, nil
+}
+
+// Flush writes the data pending in a block, for the data written so far to be decompressed
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed || z.finder.Pending() == 0 {
+		return nil
+	}
+	return z.writeBlock(false)
+}
+
+// Close writes the last block of the frame, without closing the underlying writer
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	return z.writeBlock(true)
+}
+
+// writeBlock writes the pending data in a block, as a compressed one when it is smaller
+func (z *Writer) writeBlock(last bool) error {
+	z.out = z.out[:0]
+	if !z.wroteHeader {
+		// the frame has no content size nor checksum, and its window descriptor is the exponent of the window
+		z.out = append(z.out, magic...)
+		z.out = append(z.out, 0, (windowLog-10)<<3)
+		z.wroteHeader = true
+	}
+
+	var block []byte
+	z.sequences, block = z.finder.Find(z.sequences[:0])
+	headerAt := len(z.out)
+	z.out = append(z.out, 0, 0, 0)
+	blockType, size := blockRaw, len(block)
+	switch {
+	case len(block) > 1 && isRLE(block):
+		blockType = blockRLE
+		z.out = append(z.out, block[0])
+	case len(block) > 0:
+		offsets := z.offsets
+		z.out = z.appendCompressed(z.out, block)
+		blockType, size = blockCompressed, len(z.out)-headerAt-3
+		if size >= len(block) {
+			// the decoder only updates the repeated offsets with the sequences of the compressed blocks
+			z.offsets = offsets
+			z.out = append(z.out[:headerAt+3], block...)
+			blockType, size = blockRaw, len(block)
+		}
+	}
+	header := uint32(size)<<3 | uint32(blockType)<<1
+	if last {
+		header |= 1
+	}
+	z.out[headerAt], z.out[headerAt+1], z.out[headerAt+2] = byte(header), byte(header>>8), byte(header>>16)
+
+	if _, err := z.w.Write(z.out); err != nil {
+		z.err = err
+		return err
+	}
+	return nil
+}
+
+// appendCompressed appends the literals and sequences sections of the block
+func (z *Writer) appendCompressed(out []byte, block []byte) []byte {
+	z.literals = z.literals[:0]
+	sequences := z.sequences
+	pos := 0
+	for _, sequence := range sequences {
+		z.literals = append(z.literals, block[pos:pos+sequence.Literals]...)
+		pos += sequence.Literals + sequence.Length
+	}
+	// the literals of a last sequence without a copy are the ones following the sequences of the block
+	if len(sequences) > 0 && sequences[len(sequences)-1].Length == 0 {
+		sequences = sequences[:len(sequences)-1]
+	}
+	out = appendLiterals(out, z.literals)
+	return appendSequences(out, sequences, &z.offsets)
+}
+
+func isRLE(block []byte) bool {
+	for _, b := range block[1:] {
+		if b != block[0] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonSample returns a JSON document of about size bytes, repetitive as the API responses are
+func jsonSample(size int) []byte {
+	random := rand.New(rand.NewSource(1))
+	buf := bytes.NewBufferString("[")
+	for i := 0; buf.Len() < size; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(buf, `{"id":%d,"name":"backend-%d","url":"http://10.0.%d.%d:%d","weight":%d,"healthy":%t}`,
+			i, random.Intn(1000), random.Intn(256), random.Intn(256), 8000+random.Intn(100), random.Intn(10), random.Intn(2) == 0)
+	}
+	buf.WriteString("]")
+	return buf.Bytes()
+}
+
+func randomBytes(size int) []byte {
+	b := make([]byte, size)
+	rand.New(rand.NewSource(2)).Read(b)
+	return b
+}
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		data  []byte
+		level int
+		chunk int
+		flush bool
+	}{
+		{
+			desc:  "empty",
+			level: DefaultCompression,
+		},
+		{
+			desc:  "single byte",
+			data:  []byte("a"),
+			level: DefaultCompression,
+		},
+		{
+			desc:  "run of a single byte",
+			data:  bytes.Repeat([]byte("a"), 300000),
+			level: DefaultCompression,
+		},
+		{
+			desc:  "random bytes",
+			data:  randomBytes(200000),
+			level: DefaultCompression,
+		},
+		{
+			desc:  "JSON with the best speed",
+			data:  jsonSample(300000),
+			level: BestSpeed,
+		},
+		{
+			desc:  "JSON with the default level",
+			data:  jsonSample(300000),
+			level: DefaultCompression,
+		},
+		{
+			desc:  "JSON with the best compression",
+			data:  jsonSample(300000),
+			level: BestCompression,
+		},
+		{
+			desc:  "JSON written by chunks",
+			data:  jsonSample(300000),
+			level: DefaultCompression,
+			chunk: 1000,
+		},
+		{
+			desc:  "JSON written by flushed chunks",
+			data:  jsonSample(100000),
+			level: DefaultCompression,
+			chunk: 3000,
+			flush: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			z, err := NewWriterLevel(&buf, test.level)
+			require.NoError(t, err)
+
+			chunk := test.chunk
+			if chunk == 0 {
+				chunk = len(test.data) + 1
+			}
+			for pos := 0; pos < len(test.data); pos += chunk {
+				end := pos + chunk
+				if end > len(test.data) {
+					end = len(test.data)
+				}
+				n, err := z.Write(test.data[pos:end])
+				require.NoError(t, err)
+				require.Equal(t, end-pos, n)
+				if test.flush {
+					require.NoError(t, z.Flush())
+				}
+			}
+			require.NoError(t, z.Close())
+
+			decoded, err := decode(buf.Bytes())
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(test.data, decoded), "decoded data differs from the data written")
+		})
+	}
+}
+
+func TestWriterCompressesJSON(t *testing.T) {
+	t.Parallel()
+
+	data := jsonSample(300000)
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	_, err := z.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, z.Close())
+
+	assert.True(t, buf.Len() < len(data)/5, "compressed %d bytes to %d bytes", len(data), buf.Len())
+}
+
+func TestWriterFlush(t *testing.T) {
+	t.Parallel()
+
+	data := jsonSample(10000)
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	_, err := z.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, z.Flush())
+
+	// the flushed blocks are complete, the frame only missing its last block
+	flushed := append([]byte(nil), buf.Bytes()...)
+	require.NoError(t, z.Close())
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), flushed))
+
+	decoded, err := decode(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+}
+
+func TestWriterReset(t *testing.T) {
+	t.Parallel()
+
+	z, err := NewWriterLevel(nil, BestCompression)
+	require.NoError(t, err)
+	for _, data := range [][]byte{jsonSample(50000), []byte("reset"), jsonSample(20000)} {
+		var buf bytes.Buffer
+		z.Reset(&buf)
+		_, err := z.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, z.Close())
+
+		decoded, err := decode(buf.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, string(data), string(decoded))
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	t.Parallel()
+
+	z := NewWriter(&bytes.Buffer{})
+	require.NoError(t, z.Close())
+	require.NoError(t, z.Close())
+
+	_, err := z.Write([]byte("closed"))
+	assert.Error(t, err)
+}
+
+func TestNewWriterLevel(t *testing.T) {
+	testCases := []struct {
+		level    int
+		expected bool
+	}{
+		{level: BestSpeed - 1},
+		{level: BestSpeed, expected: true},
+		{level: DefaultCompression, expected: true},
+		{level: BestCompression, expected: true},
+		{level: BestCompression + 1},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(fmt.Sprintf("level %d", test.level), func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewWriterLevel(&bytes.Buffer{}, test.level)
+			if test.expected {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/containous/flaeg"
 	"github.com/containous/traefik/acme"
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/ping"
 	"github.com/containous/traefik/provider/boltdb"
 	"github.com/containous/traefik/provider/consul"
 	"github.com/containous/traefik/provider/docker"
@@ -26,6 +28,7 @@ import (
 	"github.com/containous/traefik/provider/rancher"
 	"github.com/containous/traefik/provider/web"
 	"github.com/containous/traefik/provider/zk"
+	"github.com/containous/traefik/tracing"
 	"github.com/containous/traefik/types"
 )
 
@@ -36,6 +39,12 @@ const (
 	// DefaultDialTimeout when connecting to a backend server.
 	DefaultDialTimeout = 30 * time.Second
 
+	// DefaultIdleConnTimeout before closing an idle connection to a backend server.
+	DefaultIdleConnTimeout = 90 * time.Second
+
+	// DefaultKeepAlive interval of the TCP keep-alive probes of the connections to the backend servers.
+	DefaultKeepAlive = 30 * time.Second
+
 	// DefaultIdleTimeout before closing an idle connection.
 	DefaultIdleTimeout = 180 * time.Second
 
@@ -58,7 +67,7 @@ type GlobalConfiguration struct {
 	LogLevel                  string                  `short:"l" description:"Log level" export:"true"`
 	EntryPoints               EntryPoints             `description:"Entrypoints definition using format: --entryPoints='Name:http Address::8000 Redirect.EntryPoint:https' --entryPoints='Name:https Address::4442 TLS:tests/traefik.crt,tests/traefik.key;prod/traefik.crt,prod/traefik.key'" export:"true"`
 	Cluster                   *types.Cluster          `description:"Enable clustering" export:"true"`
-	Constraints               types.Constraints       `description:"Filter services by constraint, matching with service tags" export:"true"`
+	Constraints               types.Constraints       `description:"Filter services by constraint, matching with service tags or labels" export:"true"`
 	ACME                      *acme.ACME              `description:"Enable ACME (Let's Encrypt): automatic SSL" export:"true"`
 	DefaultEntryPoints        DefaultEntryPoints      `description:"Entrypoints to be used by frontends that do not specify any entrypoint" export:"true"`
 	ProvidersThrottleDuration flaeg.Duration          `description:"Backends throttle duration: minimum duration between 2 events from providers before applying a new configuration. It avoids unnecessary reloads if multiples events are sent in a short amount of time." export:"true"`
@@ -70,6 +79,8 @@ type GlobalConfiguration struct {
 	HealthCheck               *HealthCheckConfig      `description:"Health check parameters" export:"true"`
 	RespondingTimeouts        *RespondingTimeouts     `description:"Timeouts for incoming requests to the Traefik instance" export:"true"`
 	ForwardingTimeouts        *ForwardingTimeouts     `description:"Timeouts for requests forwarded to the backend servers" export:"true"`
+	ForwardingTransport       *ForwardingTransport    `description:"Tuning of the connections of the requests forwarded to the backend servers" export:"true"`
+	WebsocketTimeouts         *WebsocketTimeouts      `description:"Timeouts for websocket connections proxied by the Traefik instance" export:"true"`
 	Docker                    *docker.Provider        `description:"Enable Docker backend with default settings" export:"true"`
 	File                      *file.Provider          `description:"Enable File backend with default settings" export:"true"`
 	Web                       *web.Provider           `description:"Enable Web backend with default settings" export:"true"`
@@ -85,6 +96,12 @@ type GlobalConfiguration struct {
 	ECS                       *ecs.Provider           `description:"Enable ECS backend with default settings" export:"true"`
 	Rancher                   *rancher.Provider       `description:"Enable Rancher backend with default settings" export:"true"`
 	DynamoDB                  *dynamodb.Provider      `description:"Enable DynamoDB backend with default settings" export:"true"`
+	Plugins                   Plugins                 `description:"Go plugin files registering middlewares, loaded at startup" export:"true"`
+	Tracing                   *tracing.Config         `description:"Distributed tracing configuration" export:"true"`
+	Ping                      *ping.Handler           `description:"Enable ping" export:"true"`
+	DefaultBackend            *types.DefaultBackend   `description:"Handling of the requests matching no frontend" export:"true"`
+	GeoIP                     *GeoIP                  `description:"Locate the clients with a MaxMind GeoIP database" export:"true"`
+	PidFile                   string                  `description:"File the process ID of Traefik is written to" export:"true"`
 }
 
 // SetEffectiveConfiguration adds missing configuration parameters derived from
@@ -95,6 +112,13 @@ func (gc *GlobalConfiguration) SetEffectiveConfiguration(configFile string) {
 		gc.DefaultEntryPoints = []string{"http"}
 	}
 
+	if gc.Ping != nil && len(gc.Ping.EntryPoint) == 0 {
+		gc.Ping.EntryPoint = "http"
+		if len(gc.DefaultEntryPoints) > 0 {
+			gc.Ping.EntryPoint = gc.DefaultEntryPoints[0]
+		}
+	}
+
 	// Make sure LifeCycle isn't nil to spare nil checks elsewhere.
 	if gc.LifeCycle == nil {
 		gc.LifeCycle = &LifeCycle{}
@@ -139,6 +163,38 @@ func (gc *GlobalConfiguration) SetEffectiveConfiguration(configFile string) {
 	}
 }
 
+// Plugins holds the paths of the Go plugin files to load
+type Plugins []string
+
+// String is the method to format the flag's value, part of the flag.Value interface.
+// The String method's output will be used in diagnostics.
+func (p *Plugins) String() string {
+	return strings.Join(*p, ",")
+}
+
+// Set is the method to set the flag value, part of the flag.Value interface.
+// Set's argument is a string to be parsed to set the flag.
+// It's a comma-separated list, so we split it.
+func (p *Plugins) Set(value string) error {
+	*p = append(*p, strings.Split(value, ",")...)
+	return nil
+}
+
+// Get return the Plugins slice
+func (p *Plugins) Get() interface{} {
+	return Plugins(*p)
+}
+
+// SetValue sets the Plugins slice with val
+func (p *Plugins) SetValue(val interface{}) {
+	*p = Plugins(val.(Plugins))
+}
+
+// Type is type of the struct
+func (p *Plugins) Type() string {
+	return "plugins"
+}
+
 // DefaultEntryPoints holds default entry points
 type DefaultEntryPoints []string
 
@@ -291,6 +347,27 @@ func (ep *EntryPoints) Set(value string) error {
 
 	compress := toBool(result, "Compress")
 
+	var compression *Compression
+	if len(result["CompressionEncodings"]) > 0 || len(result["CompressionBrotliLevel"]) > 0 || len(result["CompressionZstdLevel"]) > 0 || len(result["CompressionGzipLevel"]) > 0 {
+		compression = &Compression{}
+		if len(result["CompressionEncodings"]) > 0 {
+			compression.Encodings = strings.Split(result["CompressionEncodings"], ",")
+		}
+		levels := map[string]*int{
+			"CompressionBrotliLevel": &compression.BrotliLevel,
+			"CompressionZstdLevel":   &compression.ZstdLevel,
+			"CompressionGzipLevel":   &compression.GzipLevel,
+		}
+		for key, level := range levels {
+			if len(result[key]) == 0 {
+				continue
+			}
+			if *level, err = strconv.Atoi(result[key]); err != nil {
+				return fmt.Errorf("bad compression level %q: %v", result[key], err)
+			}
+		}
+	}
+
 	var proxyProtocol *ProxyProtocol
 	if len(result["ProxyProtocol"]) > 0 {
 		trustedIPs := strings.Split(result["ProxyProtocol"], ",")
@@ -299,20 +376,44 @@ func (ep *EntryPoints) Set(value string) error {
 		}
 	}
 
+	var forwardedHeaders *ForwardedHeaders
+	if len(result["ForwardedHeadersInsecure"]) > 0 || len(result["ForwardedHeadersTrustedIPs"]) > 0 {
+		forwardedHeaders = &ForwardedHeaders{
+			Insecure: toBool(result, "ForwardedHeadersInsecure"),
+		}
+		if len(result["ForwardedHeadersTrustedIPs"]) > 0 {
+			forwardedHeaders.TrustedIPs = strings.Split(result["ForwardedHeadersTrustedIPs"], ",")
+		}
+	}
+
+	var http3 *HTTP3
+	if toBool(result, "HTTP3") || len(result["HTTP3AdvertisedPort"]) > 0 {
+		http3 = &HTTP3{}
+		if len(result["HTTP3AdvertisedPort"]) > 0 {
+			if http3.AdvertisedPort, err = strconv.Atoi(result["HTTP3AdvertisedPort"]); err != nil {
+				return fmt.Errorf("bad HTTP/3 advertised port %q: %v", result["HTTP3AdvertisedPort"], err)
+			}
+		}
+	}
+
 	(*ep)[result["Name"]] = &EntryPoint{
 		Address:              result["Address"],
 		TLS:                  configTLS,
 		Redirect:             redirect,
 		Compress:             compress,
+		Compression:          compression,
 		WhitelistSourceRange: whiteListSourceRange,
 		ProxyProtocol:        proxyProtocol,
+		ForwardedHeaders:     forwardedHeaders,
+		HTTP3:                http3,
+		Protocol:             result["Protocol"],
 	}
 
 	return nil
 }
 
 func parseEntryPointsConfiguration(value string) (map[string]string, error) {
-	regex := regexp.MustCompile(`(?:Name:(?P<Name>\S*))\s*(?:Address:(?P<Address>\S*))?\s*(?:TLS:(?P<TLS>\S*))?\s*(?P<TLSACME>TLS)?\s*(?:CA:(?P<CA>\S*))?\s*(?:Redirect\.EntryPoint:(?P<RedirectEntryPoint>\S*))?\s*(?:Redirect\.Regex:(?P<RedirectRegex>\S*))?\s*(?:Redirect\.Replacement:(?P<RedirectReplacement>\S*))?\s*(?:Compress:(?P<Compress>\S*))?\s*(?:WhiteListSourceRange:(?P<WhiteListSourceRange>\S*))?\s*(?:ProxyProtocol\.TrustedIPs:(?P<ProxyProtocol>\S*))?`)
+	regex := regexp.MustCompile(`(?:Name:(?P<Name>\S*))\s*(?:Address:(?P<Address>\S*))?\s*(?:TLS:(?P<TLS>\S*))?\s*(?P<TLSACME>TLS)?\s*(?:CA:(?P<CA>\S*))?\s*(?:Redirect\.EntryPoint:(?P<RedirectEntryPoint>\S*))?\s*(?:Redirect\.Regex:(?P<RedirectRegex>\S*))?\s*(?:Redirect\.Replacement:(?P<RedirectReplacement>\S*))?\s*(?:Compress:(?P<Compress>\S*))?\s*(?:Compression\.Encodings:(?P<CompressionEncodings>\S*))?\s*(?:Compression\.BrotliLevel:(?P<CompressionBrotliLevel>\S*))?\s*(?:Compression\.ZstdLevel:(?P<CompressionZstdLevel>\S*))?\s*(?:Compression\.GzipLevel:(?P<CompressionGzipLevel>\S*))?\s*(?:WhiteListSourceRange:(?P<WhiteListSourceRange>\S*))?\s*(?:ProxyProtocol\.TrustedIPs:(?P<ProxyProtocol>\S*))?\s*(?:ForwardedHeaders\.Insecure:(?P<ForwardedHeadersInsecure>\S*))?\s*(?:ForwardedHeaders\.TrustedIPs:(?P<ForwardedHeadersTrustedIPs>\S*))?\s*(?:HTTP3:(?P<HTTP3>\S*))?\s*(?:HTTP3\.AdvertisedPort:(?P<HTTP3AdvertisedPort>\S*))?\s*(?:Protocol:(?P<Protocol>\S*))?`)
 	match := regex.FindAllStringSubmatch(value, -1)
 	if match == nil {
 		return nil, fmt.Errorf("bad EntryPoints format: %s", value)
@@ -359,8 +460,33 @@ type EntryPoint struct {
 	Redirect             *Redirect   `export:"true"`
 	Auth                 *types.Auth `export:"true"`
 	WhitelistSourceRange []string
-	Compress             bool           `export:"true"`
-	ProxyProtocol        *ProxyProtocol `export:"true"`
+	Compress             bool              `export:"true"`
+	Compression          *Compression      `export:"true"`
+	RequestID            bool              `export:"true"`
+	ProxyProtocol        *ProxyProtocol    `export:"true"`
+	ForwardedHeaders     *ForwardedHeaders `export:"true"`
+	HTTP3                *HTTP3            `export:"true"`
+	Protocol             string            `export:"true"`
+}
+
+const (
+	// EntryPointProtocolTCP is the protocol of the entry points proxying raw TCP connections instead of HTTP requests
+	EntryPointProtocolTCP = "tcp"
+	// EntryPointProtocolUDP is the protocol of the entry points proxying UDP datagrams
+	EntryPointProtocolUDP = "udp"
+)
+
+// Compression configures the encodings of the responses compressed by an entry point, and their levels
+type Compression struct {
+	Encodings   []string
+	BrotliLevel int `export:"true"`
+	ZstdLevel   int `export:"true"`
+	GzipLevel   int `export:"true"`
+}
+
+// HTTP3 enables the experimental HTTP/3 listener of a TLS entry point, on the UDP port of its address
+type HTTP3 struct {
+	AdvertisedPort int `export:"true"` // the port advertised in the Alt-Svc header, defaults to the one of the entry point
 }
 
 // Redirect configures a redirection of an entry point to another, or to an URL
@@ -372,10 +498,23 @@ type Redirect struct {
 
 // TLS configures TLS for an entry point
 type TLS struct {
-	MinVersion    string `export:"true"`
-	CipherSuites  []string
-	Certificates  Certificates
-	ClientCAFiles []string
+	MinVersion         string `export:"true"`
+	MaxVersion         string `export:"true"`
+	CipherSuites       []string
+	CurvePreferences   []string
+	Certificates       Certificates
+	DefaultCertificate *Certificate
+	ClientCAFiles      []string
+	ClientAuth         string `export:"true"`
+}
+
+// ClientAuthTypes Map of allowed TLS client authentication policies
+var ClientAuthTypes = map[string]tls.ClientAuthType{
+	`NoClientCert`:               tls.NoClientCert,
+	`RequestClientCert`:          tls.RequestClientCert,
+	`RequireAnyClientCert`:       tls.RequireAnyClientCert,
+	`VerifyClientCertIfGiven`:    tls.VerifyClientCertIfGiven,
+	`RequireAndVerifyClientCert`: tls.RequireAndVerifyClientCert,
 }
 
 // MinVersion Map of allowed TLS minimum versions
@@ -385,6 +524,22 @@ var MinVersion = map[string]uint16{
 	`VersionTLS12`: tls.VersionTLS12,
 }
 
+// MaxVersion Map of allowed TLS maximum versions
+var MaxVersion = map[string]uint16{
+	`VersionTLS10`: tls.VersionTLS10,
+	`VersionTLS11`: tls.VersionTLS11,
+	`VersionTLS12`: tls.VersionTLS12,
+}
+
+// Curves Map of TLS elliptic curves from crypto/tls
+// Available Curves defined at https://golang.org/pkg/crypto/tls/#CurveID
+var Curves = map[string]tls.CurveID{
+	`CurveP256`: tls.CurveP256,
+	`CurveP384`: tls.CurveP384,
+	`CurveP521`: tls.CurveP521,
+	`X25519`:    tls.X25519,
+}
+
 // CipherSuites Map of TLS CipherSuites from crypto/tls
 // Available CipherSuites defined at https://golang.org/pkg/crypto/tls/#pkg-constants
 var CipherSuites = map[string]uint16{
@@ -422,24 +577,12 @@ func (certs *Certificates) CreateTLSConfig() (*tls.Config, error) {
 	config.Certificates = []tls.Certificate{}
 	certsSlice := []Certificate(*certs)
 	for _, v := range certsSlice {
-		var err error
-
-		certContent, err := v.CertFile.Read()
-		if err != nil {
-			return nil, err
-		}
-
-		keyContent, err := v.KeyFile.Read()
-		if err != nil {
-			return nil, err
-		}
-
-		cert, err := tls.X509KeyPair(certContent, keyContent)
+		cert, err := v.CreateTLSCertificate()
 		if err != nil {
 			return nil, err
 		}
 
-		config.Certificates = append(config.Certificates, cert)
+		config.Certificates = append(config.Certificates, *cert)
 	}
 	return config, nil
 }
@@ -487,6 +630,25 @@ type Certificate struct {
 	KeyFile  FileOrContent
 }
 
+// CreateTLSCertificate loads the cert/key pair of the Certificate
+func (c *Certificate) CreateTLSCertificate() (*tls.Certificate, error) {
+	certContent, err := c.CertFile.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	keyContent, err := c.KeyFile.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certContent, keyContent)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
 // Retry contains request retry config
 type Retry struct {
 	Attempts int `description:"Number of attempts" export:"true"`
@@ -504,20 +666,52 @@ type RespondingTimeouts struct {
 	IdleTimeout  flaeg.Duration `description:"IdleTimeout is the maximum amount duration an idle (keep-alive) connection will remain idle before closing itself. Defaults to 180 seconds. If zero, no timeout is set" export:"true"`
 }
 
+// WebsocketTimeouts contains timeout configurations for websocket connections proxied by the Traefik instance.
+type WebsocketTimeouts struct {
+	ReadTimeout  flaeg.Duration `description:"ReadTimeout is the maximum duration to wait for the next message from the client on a websocket connection. If zero, no timeout is set" export:"true"`
+	WriteTimeout flaeg.Duration `description:"WriteTimeout is the maximum duration of a write to the client on a websocket connection. If zero, no timeout is set" export:"true"`
+}
+
 // ForwardingTimeouts contains timeout configurations for forwarding requests to the backend servers.
 type ForwardingTimeouts struct {
 	DialTimeout           flaeg.Duration `description:"The amount of time to wait until a connection to a backend server can be established. Defaults to 30 seconds. If zero, no timeout exists" export:"true"`
 	ResponseHeaderTimeout flaeg.Duration `description:"The amount of time to wait for a server's response headers after fully writing the request (including its body, if any). If zero, no timeout exists" export:"true"`
+	IdleConnTimeout       flaeg.Duration `description:"The maximum amount of time an idle (keep-alive) connection to a backend server remains open. Defaults to 90 seconds" export:"true"`
+}
+
+// ForwardingTransport contains the tuning of the connections of the requests forwarded to the backend servers.
+type ForwardingTransport struct {
+	MaxIdleConns       int            `description:"The maximum number of idle (keep-alive) connections to all the backend servers. If zero, there is no limit" export:"true"`
+	KeepAlive          flaeg.Duration `description:"The interval between the TCP keep-alive probes of the connections to the backend servers. Defaults to 30 seconds. If negative, the TCP keep-alive is disabled" export:"true"`
+	DisableKeepAlives  bool           `description:"Open a new connection to the backend servers for each request, instead of reusing the idle ones" export:"true"`
+	DisableCompression bool           `description:"Do not request gzip compressed responses from the backend servers when the clients do not accept them" export:"true"`
+	ReadBufferSize     int            `description:"The size in bytes of the TCP receive buffer of the connections to the backend servers. If zero, the system default is used" export:"true"`
+	WriteBufferSize    int            `description:"The size in bytes of the TCP send buffer of the connections to the backend servers. If zero, the system default is used" export:"true"`
+	ResolveInterval    flaeg.Duration `description:"The interval between the resolutions of the host names of the backend servers, closing the idle connections to the previous addresses of a host when they change. If zero, the host names are only resolved when connecting" export:"true"`
 }
 
 // ProxyProtocol contains Proxy-Protocol configuration
 type ProxyProtocol struct {
+	TrustedIPs    []string
+	HeaderTimeout flaeg.Duration // maximum duration to read the header of a connection, the default one if zero, none if negative
+}
+
+// ForwardedHeaders configures the trust of the X-Forwarded-* headers of the requests of an entry point: the headers of
+// the requests not coming from the TrustedIPs are replaced by the ones of the connection, unless Insecure.
+type ForwardedHeaders struct {
+	Insecure   bool
 	TrustedIPs []string
 }
 
+// GeoIP configures the MaxMind GeoIP2 or GeoLite2 database locating the clients of the entry points
+type GeoIP struct {
+	Database string `description:"Path of the MaxMind database (.mmdb) of the countries or the cities" export:"true"`
+}
+
 // LifeCycle contains configurations relevant to the lifecycle (such as the
 // shutdown phase) of Traefik.
 type LifeCycle struct {
 	RequestAcceptGraceTimeout flaeg.Duration `description:"Duration to keep accepting requests before Traefik initiates the graceful shutdown procedure"`
 	GraceTimeOut              flaeg.Duration `description:"Duration to give active requests a chance to finish before Traefik stops"`
+	PreShutdownCommand        string         `description:"Command run when Traefik receives a stop signal, during the request accepting grace period, e.g. to deregister the instance from a load-balancer"`
 }
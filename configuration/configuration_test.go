@@ -22,7 +22,7 @@ func Test_parseEntryPointsConfiguration(t *testing.T) {
 	}{
 		{
 			name:  "all parameters",
-			value: "Name:foo Address:bar TLS:goo TLS CA:car Redirect.EntryPoint:RedirectEntryPoint Redirect.Regex:RedirectRegex Redirect.Replacement:RedirectReplacement Compress:true WhiteListSourceRange:WhiteListSourceRange ProxyProtocol.TrustedIPs:192.168.0.1",
+			value: "Name:foo Address:bar TLS:goo TLS CA:car Redirect.EntryPoint:RedirectEntryPoint Redirect.Regex:RedirectRegex Redirect.Replacement:RedirectReplacement Compress:true WhiteListSourceRange:WhiteListSourceRange ProxyProtocol.TrustedIPs:192.168.0.1 Protocol:tcp",
 			expectedResult: map[string]string{
 				"Name":                 "foo",
 				"Address":              "bar",
@@ -35,6 +35,28 @@ func Test_parseEntryPointsConfiguration(t *testing.T) {
 				"WhiteListSourceRange": "WhiteListSourceRange",
 				"ProxyProtocol":        "192.168.0.1",
 				"Compress":             "true",
+				"Protocol":             "tcp",
+			},
+		},
+		{
+			name:  "forwarded headers",
+			value: "Name:foo ProxyProtocol.TrustedIPs:192.168.0.1 ForwardedHeaders.Insecure:false ForwardedHeaders.TrustedIPs:10.0.0.0/8,192.168.0.1 Protocol:tcp",
+			expectedResult: map[string]string{
+				"Name":                       "foo",
+				"ProxyProtocol":              "192.168.0.1",
+				"ForwardedHeadersInsecure":   "false",
+				"ForwardedHeadersTrustedIPs": "10.0.0.0/8,192.168.0.1",
+				"Protocol":                   "tcp",
+			},
+		},
+		{
+			name:  "http3",
+			value: "Name:foo TLS:goo,gii HTTP3:true HTTP3.AdvertisedPort:443",
+			expectedResult: map[string]string{
+				"Name":                "foo",
+				"TLS":                 "goo,gii",
+				"HTTP3":               "true",
+				"HTTP3AdvertisedPort": "443",
 			},
 		},
 		{
@@ -140,7 +162,7 @@ func TestEntryPoints_Set(t *testing.T) {
 	}{
 		{
 			name:                   "all parameters",
-			expression:             "Name:foo Address:bar TLS:goo,gii TLS CA:car Redirect.EntryPoint:RedirectEntryPoint Redirect.Regex:RedirectRegex Redirect.Replacement:RedirectReplacement Compress:true WhiteListSourceRange:Range ProxyProtocol.TrustedIPs:192.168.0.1",
+			expression:             "Name:foo Address:bar TLS:goo,gii TLS CA:car Redirect.EntryPoint:RedirectEntryPoint Redirect.Regex:RedirectRegex Redirect.Replacement:RedirectReplacement Compress:true WhiteListSourceRange:Range ProxyProtocol.TrustedIPs:192.168.0.1 Protocol:tcp",
 			expectedEntryPointName: "foo",
 			expectedEntryPoint: &EntryPoint{
 				Address: "bar",
@@ -154,6 +176,7 @@ func TestEntryPoints_Set(t *testing.T) {
 					TrustedIPs: []string{"192.168.0.1"},
 				},
 				WhitelistSourceRange: []string{"Range"},
+				Protocol:             "tcp",
 				TLS: &TLS{
 					ClientCAFiles: []string{"car"},
 					Certificates: Certificates{
@@ -165,6 +188,44 @@ func TestEntryPoints_Set(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:                   "forwarded headers",
+			expression:             "Name:foo ForwardedHeaders.TrustedIPs:10.0.0.0/8,192.168.0.1",
+			expectedEntryPointName: "foo",
+			expectedEntryPoint: &EntryPoint{
+				ForwardedHeaders: &ForwardedHeaders{
+					TrustedIPs: []string{"10.0.0.0/8", "192.168.0.1"},
+				},
+				WhitelistSourceRange: []string{},
+			},
+		},
+		{
+			name:                   "insecure forwarded headers",
+			expression:             "Name:foo ForwardedHeaders.Insecure:true",
+			expectedEntryPointName: "foo",
+			expectedEntryPoint: &EntryPoint{
+				ForwardedHeaders:     &ForwardedHeaders{Insecure: true},
+				WhitelistSourceRange: []string{},
+			},
+		},
+		{
+			name:                   "http3",
+			expression:             "Name:foo HTTP3:true",
+			expectedEntryPointName: "foo",
+			expectedEntryPoint: &EntryPoint{
+				HTTP3:                &HTTP3{},
+				WhitelistSourceRange: []string{},
+			},
+		},
+		{
+			name:                   "http3 advertised port",
+			expression:             "Name:foo HTTP3.AdvertisedPort:443",
+			expectedEntryPointName: "foo",
+			expectedEntryPoint: &EntryPoint{
+				HTTP3:                &HTTP3{AdvertisedPort: 443},
+				WhitelistSourceRange: []string{},
+			},
+		},
 		{
 			name:                   "compress on",
 			expression:             "Name:foo Compress:on",
@@ -183,6 +244,20 @@ func TestEntryPoints_Set(t *testing.T) {
 				WhitelistSourceRange: []string{},
 			},
 		},
+		{
+			name:                   "compression",
+			expression:             "Name:foo Compress:true Compression.Encodings:zstd,gzip Compression.ZstdLevel:9 Compression.GzipLevel:1",
+			expectedEntryPointName: "foo",
+			expectedEntryPoint: &EntryPoint{
+				Compress: true,
+				Compression: &Compression{
+					Encodings: []string{"zstd", "gzip"},
+					ZstdLevel: 9,
+					GzipLevel: 1,
+				},
+				WhitelistSourceRange: []string{},
+			},
+		},
 	}
 
 	for _, test := range testCases {
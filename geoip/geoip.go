@@ -0,0 +1,78 @@
+package geoip
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// Database is a MaxMind GeoIP2 or GeoLite2 Country or City database
+type Database struct {
+	reader *reader
+}
+
+// Location is the location of an IP: ISO 3166-1 code of its country, code of its continent, and English name of its city
+type Location struct {
+	Country   string
+	Continent string
+	City      string
+}
+
+// Open loads the MaxMind database file
+func Open(path string) (*Database, error) {
+	buffer, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newDatabase(buffer)
+}
+
+func newDatabase(buffer []byte) (*Database, error) {
+	r, err := newReader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{reader: r}, nil
+}
+
+// Type returns the type of the database, e.g. GeoLite2-Country
+func (d *Database) Type() string {
+	return d.reader.description
+}
+
+// Lookup returns the location of the IP, nil if the IP is not in the database
+func (d *Database) Lookup(ip net.IP) (*Location, error) {
+	record, err := d.reader.lookup(ip)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up %s in the GeoIP database: %v", ip, err)
+	}
+	fields, ok := record.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	location := &Location{
+		Country:   stringField(fields, "country", "iso_code"),
+		Continent: stringField(fields, "continent", "code"),
+		City:      stringField(fields, "city", "names", "en"),
+	}
+	if len(location.Country) == 0 {
+		// the anycast and satellite providers only have a registered country
+		location.Country = stringField(fields, "registered_country", "iso_code")
+	}
+	return location, nil
+}
+
+// stringField returns the string at the path of nested maps, empty if there is none
+func stringField(fields map[string]interface{}, path ...string) string {
+	var value interface{} = fields
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+	s, _ := value.(string)
+	return s
+}
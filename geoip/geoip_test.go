@@ -0,0 +1,211 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatabase builds a MaxMind DB file of the networks, their records sharing the continent through a pointer
+type testDatabase struct {
+	recordSize uint
+	ipVersion  uint
+	nodes      [][2]int // children of the nodes: index of the child node, -1 if empty, or -2-offset of the data
+	data       []byte
+}
+
+func newTestDatabase(recordSize uint, ipVersion uint) *testDatabase {
+	return &testDatabase{recordSize: recordSize, ipVersion: ipVersion, nodes: [][2]int{{-1, -1}}}
+}
+
+func (db *testDatabase) insert(t *testing.T, cidr string, record []byte) {
+	_, network, err := net.ParseCIDR(cidr)
+	require.NoError(t, err)
+	ip := network.IP
+	ones, _ := network.Mask.Size()
+	if db.ipVersion == 6 && len(ip) == net.IPv4len {
+		ip = append(make(net.IP, 12), ip...)
+		ones += 96
+	}
+
+	offset := len(db.data)
+	db.data = append(db.data, record...)
+
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+		if i == ones-1 {
+			db.nodes[node][bit] = -2 - offset
+			return
+		}
+		if db.nodes[node][bit] < 0 {
+			db.nodes = append(db.nodes, [2]int{-1, -1})
+			db.nodes[node][bit] = len(db.nodes) - 1
+		}
+		node = db.nodes[node][bit]
+	}
+}
+
+func (db *testDatabase) build() []byte {
+	nodeCount := uint(len(db.nodes))
+	recordValue := func(child int) uint {
+		switch {
+		case child == -1:
+			return nodeCount
+		case child < -1:
+			return nodeCount + dataSectionSeparatorSize + uint(-2-child)
+		default:
+			return uint(child)
+		}
+	}
+
+	var buffer bytes.Buffer
+	for _, node := range db.nodes {
+		left, right := recordValue(node[0]), recordValue(node[1])
+		switch db.recordSize {
+		case 24:
+			buffer.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			buffer.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>24)<<4 | byte(right>>24), byte(right >> 16), byte(right >> 8), byte(right)})
+		default:
+			buffer.Write([]byte{byte(left >> 24), byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 24), byte(right >> 16), byte(right >> 8), byte(right)})
+		}
+	}
+	buffer.Write(make([]byte, dataSectionSeparatorSize))
+	buffer.Write(db.data)
+	buffer.Write(metadataStartMarker)
+	buffer.Write(encodeMap(
+		"node_count", encodeUint32(uint32(nodeCount)),
+		"record_size", encodeUint16(uint16(db.recordSize)),
+		"ip_version", encodeUint16(uint16(db.ipVersion)),
+		"database_type", encodeString("GeoLite2-City"),
+		"binary_format_major_version", encodeUint16(2),
+	))
+	return buffer.Bytes()
+}
+
+func encodeString(s string) []byte {
+	return append([]byte{byte(typeString<<5 | len(s))}, s...)
+}
+
+func encodeUint16(v uint16) []byte {
+	return []byte{byte(typeUint16<<5 | 2), byte(v >> 8), byte(v)}
+}
+
+func encodeUint32(v uint32) []byte {
+	return []byte{byte(typeUint32<<5 | 4), byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func encodePointer(offset int) []byte {
+	return []byte{byte(typePointer<<5 | (offset>>8)&0x7), byte(offset)}
+}
+
+// encodeMap encodes the map of the keys and encoded values
+func encodeMap(keysAndValues ...interface{}) []byte {
+	encoded := []byte{byte(typeMap<<5 | len(keysAndValues)/2)}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		encoded = append(encoded, encodeString(keysAndValues[i].(string))...)
+		encoded = append(encoded, keysAndValues[i+1].([]byte)...)
+	}
+	return encoded
+}
+
+func TestDatabaseLookup(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		recordSize uint
+		ipVersion  uint
+	}{
+		{desc: "IPv4 database with 24 bits records", recordSize: 24, ipVersion: 4},
+		{desc: "IPv6 database with 28 bits records", recordSize: 28, ipVersion: 6},
+		{desc: "IPv6 database with 32 bits records", recordSize: 32, ipVersion: 6},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			db := newTestDatabase(test.recordSize, test.ipVersion)
+			europe := encodeMap("code", encodeString("EU"))
+			// the first record holds the continent shared by the second one
+			db.insert(t, "81.0.0.0/8", encodeMap(
+				"continent", europe,
+				"country", encodeMap("iso_code", encodeString("FR")),
+				"city", encodeMap("names", encodeMap("en", encodeString("Paris"))),
+			))
+			db.insert(t, "85.214.0.0/16", encodeMap(
+				"continent", encodePointer(1+len(encodeString("continent"))),
+				"registered_country", encodeMap("iso_code", encodeString("DE")),
+			))
+
+			database, err := newDatabase(db.build())
+			require.NoError(t, err)
+			assert.Equal(t, "GeoLite2-City", database.Type())
+
+			location, err := database.Lookup(net.ParseIP("81.2.69.142"))
+			require.NoError(t, err)
+			assert.Equal(t, &Location{Country: "FR", Continent: "EU", City: "Paris"}, location)
+
+			location, err = database.Lookup(net.ParseIP("85.214.132.117"))
+			require.NoError(t, err)
+			assert.Equal(t, &Location{Country: "DE", Continent: "EU"}, location)
+
+			location, err = database.Lookup(net.ParseIP("10.0.0.1"))
+			require.NoError(t, err)
+			assert.Nil(t, location)
+		})
+	}
+}
+
+func TestNewDatabaseInvalid(t *testing.T) {
+	_, err := newDatabase([]byte("not a MaxMind database"))
+	assert.Error(t, err)
+}
+
+func TestDecoderPointers(t *testing.T) {
+	eu := encodeString("EU")
+
+	testCases := []struct {
+		desc          string
+		buffer        []byte
+		offset        uint
+		expectedValue interface{}
+		expectedError bool
+	}{
+		{
+			desc:          "pointer to a string",
+			buffer:        append(append([]byte(nil), eu...), encodePointer(0)...),
+			offset:        uint(len(eu)),
+			expectedValue: "EU",
+		},
+		{
+			desc:          "pointer to a pointer",
+			buffer:        append(encodePointer(2), encodePointer(0)...),
+			expectedError: true,
+		},
+		{
+			desc:          "map pointing to itself",
+			buffer:        encodeMap("self", encodePointer(0)),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			value, _, err := (&decoder{buffer: test.buffer}).decode(test.offset)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedValue, value)
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataStartMarker precedes the metadata at the end of a MaxMind DB file
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparatorSize is the size of the zeros between the search tree and the data section
+const dataSectionSeparatorSize = 16
+
+// types of the fields of the data section
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeSlice
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// reader reads the records of a MaxMind DB file: https://maxmind.github.io/MaxMind-DB/
+type reader struct {
+	buffer      []byte
+	nodeCount   uint
+	recordSize  uint
+	ipVersion   uint
+	treeSize    uint
+	data        []byte
+	ipv4Start   uint
+	description string
+}
+
+func newReader(buffer []byte) (*reader, error) {
+	metadataStart := bytes.LastIndex(buffer, metadataStartMarker)
+	if metadataStart == -1 {
+		return nil, errors.New("invalid MaxMind DB file: metadata not found")
+	}
+	metadataStart += len(metadataStartMarker)
+
+	metadata, _, err := (&decoder{buffer: buffer[metadataStart:]}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MaxMind DB metadata: %v", err)
+	}
+	fields, ok := metadata.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata: not a map")
+	}
+
+	r := &reader{buffer: buffer}
+	r.nodeCount = uint(toUint64(fields["node_count"]))
+	r.recordSize = uint(toUint64(fields["record_size"]))
+	r.ipVersion = uint(toUint64(fields["ip_version"]))
+	if databaseType, ok := fields["database_type"].(string); ok {
+		r.description = databaseType
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB IP version %d", r.ipVersion)
+	}
+
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	dataStart := r.treeSize + dataSectionSeparatorSize
+	if dataStart > uint(metadataStart-len(metadataStartMarker)) {
+		return nil, errors.New("invalid MaxMind DB file: search tree larger than the file")
+	}
+	r.data = buffer[dataStart : metadataStart-len(metadataStartMarker)]
+
+	// the IPv4 addresses are the ::a.b.c.d addresses of the IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// lookup returns the record of the IP, nil if the IP is not in the database
+func (r *reader) lookup(ip net.IP) (interface{}, error) {
+	node, bitCount := uint(0), uint(128)
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+		node, bitCount = r.ipv4Start, 32
+	} else if r.ipVersion == 4 {
+		return nil, fmt.Errorf("IPv6 address %s looked up in an IPv4 only database", ip)
+	}
+
+	for i := uint(0); i < bitCount && node < r.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-(i&7))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node > r.nodeCount:
+		offset := node - r.nodeCount - dataSectionSeparatorSize
+		if offset >= uint(len(r.data)) {
+			return nil, errors.New("invalid MaxMind DB file: record outside of the data section")
+		}
+		value, _, err := (&decoder{buffer: r.data}).decode(offset)
+		return value, err
+	default:
+		return nil, errors.New("invalid MaxMind DB file: search tree too deep")
+	}
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of the node
+func (r *reader) readNode(node uint, bit uint) uint {
+	offset := node * r.recordSize / 4
+	b := r.buffer[offset:]
+	switch r.recordSize {
+	case 24:
+		offset = bit * 3
+		return uint(b[offset])<<16 | uint(b[offset+1])<<8 | uint(b[offset+2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		offset = bit * 4
+		return uint(binary.BigEndian.Uint32(b[offset:]))
+	}
+}
+
+// maxDecodeDepth is the depth of the maps and slices nested in a field above which it is rejected,
+// for the pointers of an invalid file not to recurse forever
+const maxDecodeDepth = 512
+
+// decoder decodes the fields of the data section
+type decoder struct {
+	buffer []byte
+	depth  uint
+}
+
+// decode returns the value of the field at the offset, and the offset of the next field
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDecodeDepth {
+		return nil, 0, errors.New("field nested too deep, or pointers in a cycle")
+	}
+
+	fieldType, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if fieldType == typePointer {
+		pointer, next, err := d.decodePointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// a pointer cannot point to a pointer
+		if pointedType, _, _, err := d.decodeControl(pointer); err != nil {
+			return nil, 0, err
+		} else if pointedType == typePointer {
+			return nil, 0, errors.New("pointer to a pointer")
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+
+	switch fieldType {
+	case typeMap:
+		return d.decodeMap(size, offset)
+	case typeSlice:
+		return d.decodeSlice(size, offset)
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buffer)) {
+		return nil, 0, errors.New("field larger than the data section")
+	}
+	value := d.buffer[offset : offset+size]
+	next := offset + size
+
+	switch fieldType {
+	case typeString:
+		return string(value), next, nil
+	case typeBytes:
+		return append([]byte(nil), value...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(value)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(value)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		return decodeUint(value), next, nil
+	case typeInt32:
+		return int32(decodeUint(value)), next, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(value), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported field type %d", fieldType)
+	}
+}
+
+// decodeControl returns the type and the size of the field at the offset, and the offset of its value
+func (d *decoder) decodeControl(offset uint) (uint, uint, uint, error) {
+	if offset >= uint(len(d.buffer)) {
+		return 0, 0, 0, errors.New("field outside of the data section")
+	}
+	control := d.buffer[offset]
+	offset++
+
+	fieldType := uint(control >> 5)
+	if fieldType == typeExtended {
+		if offset >= uint(len(d.buffer)) {
+			return 0, 0, 0, errors.New("unexpected end of the extended type")
+		}
+		fieldType = 7 + uint(d.buffer[offset])
+		offset++
+	}
+
+	size := uint(control & 0x1F)
+	if fieldType == typePointer || size < 29 {
+		return fieldType, size, offset, nil
+	}
+
+	sizeBytes := size - 28
+	if offset+sizeBytes > uint(len(d.buffer)) {
+		return 0, 0, 0, errors.New("unexpected end of the field size")
+	}
+	extra := uint(decodeUint(d.buffer[offset : offset+sizeBytes]))
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+	return fieldType, size, offset + sizeBytes, nil
+}
+
+// decodePointer returns the offset pointed by the pointer whose size bits are given, and the offset of the next field
+func (d *decoder) decodePointer(size uint, offset uint) (uint, uint, error) {
+	pointerSize := ((size >> 3) & 0x3) + 1
+	if offset+pointerSize > uint(len(d.buffer)) {
+		return 0, 0, errors.New("unexpected end of the pointer")
+	}
+	b := d.buffer[offset : offset+pointerSize]
+
+	var prefix uint
+	if pointerSize != 4 {
+		prefix = size & 0x7
+	}
+	pointer := prefix<<(8*pointerSize) | uint(decodeUint(b))
+	switch pointerSize {
+	case 2:
+		pointer += 2048
+	case 3:
+		pointer += 526336
+	}
+	return pointer, offset + pointerSize, nil
+}
+
+func (d *decoder) decodeMap(size uint, offset uint) (interface{}, uint, error) {
+	fields := make(map[string]interface{}, size)
+	for i := uint(0); i < size; i++ {
+		key, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, 0, errors.New("map key is not a string")
+		}
+		value, next, err := d.decode(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		fields[name] = value
+		offset = next
+	}
+	return fields, offset, nil
+}
+
+func (d *decoder) decodeSlice(size uint, offset uint) (interface{}, uint, error) {
+	values := make([]interface{}, 0, size)
+	for i := uint(0); i < size; i++ {
+		value, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		values = append(values, value)
+		offset = next
+	}
+	return values, offset, nil
+}
+
+func decodeUint(b []byte) uint64 {
+	var value uint64
+	for _, c := range b {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
+
+func toUint64(value interface{}) uint64 {
+	if v, ok := value.(uint64); ok {
+		return v
+	}
+	return 0
+}
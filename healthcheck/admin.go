@@ -0,0 +1,274 @@
+package healthcheck
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+var adminSingleton *ServersAdmin
+var adminOnce sync.Once
+
+// GetServersAdmin returns the administration of the servers which is guaranteed to be a singleton.
+func GetServersAdmin() *ServersAdmin {
+	adminOnce.Do(func() {
+		adminSingleton = &ServersAdmin{
+			states: make(map[string]map[string]*ServerState),
+		}
+	})
+	return adminSingleton
+}
+
+// ServerState is the state of a server of a backend set at runtime, overriding its configuration
+type ServerState struct {
+	Weight   *int `json:"weight,omitempty"`
+	Disabled bool `json:"disabled"`
+}
+
+// removed returns whether the server is out of the load-balancer, drained or disabled
+func (s *ServerState) removed() bool {
+	return s != nil && (s.Disabled || s.Weight != nil && *s.Weight == 0)
+}
+
+// ServersAdmin holds the states of the servers set at runtime, and applies them to the load-balancers
+// of the backends of the current configuration.
+// The states are kept across the configuration reloads, for the servers of the same names.
+type ServersAdmin struct {
+	statesMutex sync.RWMutex
+	states      map[string]map[string]*ServerState // by provider and backend, then by server name
+	mutex       sync.Mutex
+	balancers   map[string]*AdminLoadBalancer
+}
+
+func adminBackendKey(providerName, backendName string) string {
+	return providerName + "/" + backendName
+}
+
+// NewLoadBalancer wraps the load-balancer of a backend so that the states of its servers are applied to it.
+// The names of the servers are given by their URLs.
+func (a *ServersAdmin) NewLoadBalancer(providerName, backendName string, lb LoadBalancer, serverNames map[string]string) *AdminLoadBalancer {
+	return &AdminLoadBalancer{
+		admin:       a,
+		backend:     adminBackendKey(providerName, backendName),
+		lb:          lb,
+		serverNames: serverNames,
+		weights:     make(map[string]int),
+		down:        make(map[string]bool),
+	}
+}
+
+// SetLoadBalancers replaces the load-balancers of the previous configuration by the ones of the backends
+func (a *ServersAdmin) SetLoadBalancers(balancers map[string]*AdminLoadBalancer) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.balancers = balancers
+}
+
+// State returns the state of a server of a backend, nil if it has not been set
+func (a *ServersAdmin) State(providerName, backendName, serverName string) *ServerState {
+	return a.state(adminBackendKey(providerName, backendName), serverName)
+}
+
+// SetWeight sets the weight of a server of a backend, 0 draining it, or restores its configured weight when nil.
+// It takes effect at once in the load-balancers of the backend.
+func (a *ServersAdmin) SetWeight(providerName, backendName, serverName string, weight *int) *ServerState {
+	return a.update(providerName, backendName, serverName, func(state *ServerState) {
+		state.Weight = weight
+	})
+}
+
+// SetDisabled disables or enables a server of a backend.
+// It takes effect at once in the load-balancers of the backend.
+func (a *ServersAdmin) SetDisabled(providerName, backendName, serverName string, disabled bool) *ServerState {
+	return a.update(providerName, backendName, serverName, func(state *ServerState) {
+		state.Disabled = disabled
+	})
+}
+
+func (a *ServersAdmin) update(providerName, backendName, serverName string, update func(state *ServerState)) *ServerState {
+	backend := adminBackendKey(providerName, backendName)
+
+	a.statesMutex.Lock()
+	state, ok := a.states[backend][serverName]
+	if !ok {
+		state = &ServerState{}
+	}
+	update(state)
+	if state.Weight == nil && !state.Disabled {
+		delete(a.states[backend], serverName)
+	} else {
+		if a.states[backend] == nil {
+			a.states[backend] = make(map[string]*ServerState)
+		}
+		a.states[backend][serverName] = state
+	}
+	stateCopy := *state
+	a.statesMutex.Unlock()
+
+	a.mutex.Lock()
+	var balancers []*AdminLoadBalancer
+	for _, balancer := range a.balancers {
+		if balancer.backend == backend {
+			balancers = append(balancers, balancer)
+		}
+	}
+	a.mutex.Unlock()
+
+	for _, balancer := range balancers {
+		balancer.applyServer(serverName)
+	}
+	return &stateCopy
+}
+
+func (a *ServersAdmin) state(backend, serverName string) *ServerState {
+	a.statesMutex.RLock()
+	defer a.statesMutex.RUnlock()
+	if state, ok := a.states[backend][serverName]; ok {
+		stateCopy := *state
+		return &stateCopy
+	}
+	return nil
+}
+
+// AdminLoadBalancer is a load-balancer applying the states of the servers set at runtime to the servers
+// upserted by the configuration and the resolvers: it holds the weights they give to the servers, and removes
+// a drained or disabled server, or a server down according to the health checks, from the underlying load-balancer.
+type AdminLoadBalancer struct {
+	admin       *ServersAdmin
+	backend     string
+	lb          LoadBalancer
+	serverNames map[string]string
+	mutex       sync.Mutex
+	weights     map[string]int  // weights of the servers of the backend, by URL
+	down        map[string]bool // servers of the backend taken out by the health checks, by URL
+}
+
+// UpsertServer adds or updates a server, with the weight set at runtime if any.
+// A server down is only given its weight, for it to be added back with it once up.
+func (b *AdminLoadBalancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	weight, err := optionsWeight(u, options)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.weights[u.String()] = weight
+	if b.down[u.String()] {
+		return nil
+	}
+	return b.apply(u)
+}
+
+// RemoveServer removes a server from the backend, which is already out of the underlying load-balancer
+// when drained, disabled or down
+func (b *AdminLoadBalancer) RemoveServer(u *url.URL) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.weights, u.String())
+	if b.down[u.String()] {
+		delete(b.down, u.String())
+		return nil
+	}
+	if b.admin.state(b.backend, b.serverNames[u.String()]).removed() {
+		return nil
+	}
+	return b.lb.RemoveServer(u)
+}
+
+// DisableServer takes a server down out of the underlying load-balancer, keeping it in the backend
+func (b *AdminLoadBalancer) DisableServer(u *url.URL) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.weights[u.String()]; !ok || b.down[u.String()] {
+		return nil
+	}
+	b.down[u.String()] = true
+	if b.admin.state(b.backend, b.serverNames[u.String()]).removed() {
+		return nil
+	}
+	return b.lb.RemoveServer(u)
+}
+
+// EnableServer adds back a server up, with its weight, and returns false if it is no longer in the backend,
+// having been removed by the configuration or the resolvers while down
+func (b *AdminLoadBalancer) EnableServer(u *url.URL) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.weights[u.String()]; !ok {
+		return false, nil
+	}
+	if !b.down[u.String()] {
+		return true, nil
+	}
+	delete(b.down, u.String())
+	return true, b.apply(u)
+}
+
+// Servers returns the servers of the underlying load-balancer
+func (b *AdminLoadBalancer) Servers() []*url.URL {
+	return b.lb.Servers()
+}
+
+// ServerWeight returns the weight given to a server, regardless of the weight set at runtime,
+// so that the health checks restore it
+func (b *AdminLoadBalancer) ServerWeight(u *url.URL) (int, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	weight, ok := b.weights[u.String()]
+	return weight, ok
+}
+
+// applyServer applies the state of a server, by its name, to the underlying load-balancer
+func (b *AdminLoadBalancer) applyServer(serverName string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for serverURL, name := range b.serverNames {
+		if name != serverName {
+			continue
+		}
+		if _, ok := b.weights[serverURL]; !ok || b.down[serverURL] {
+			// down or removed, the state is applied when it is upserted again
+			continue
+		}
+		u, err := url.Parse(serverURL)
+		if err != nil {
+			continue
+		}
+		if err := b.apply(u); err != nil {
+			log.Errorf("Error applying the state of server %s of backend %s: %v", serverName, b.backend, err)
+		}
+	}
+}
+
+// apply upserts the server with the weight set at runtime or the given one, or removes it when drained or disabled
+func (b *AdminLoadBalancer) apply(u *url.URL) error {
+	state := b.admin.state(b.backend, b.serverNames[u.String()])
+	if state.removed() {
+		log.Debugf("Server %s of backend %s is drained or disabled", u, b.backend)
+		if err := b.lb.RemoveServer(u); err != nil {
+			log.Debugf("Server %s already removed: %v", u, err)
+		}
+		return nil
+	}
+	weight := b.weights[u.String()]
+	if state != nil && state.Weight != nil {
+		weight = *state.Weight
+	}
+	return b.lb.UpsertServer(u, roundrobin.Weight(weight))
+}
+
+// optionsWeight returns the weight set by the options of a server, which cannot be read from outside oxy
+func optionsWeight(u *url.URL, options []roundrobin.ServerOption) (int, error) {
+	rr, err := roundrobin.New(nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := rr.UpsertServer(u, options...); err != nil {
+		return 0, err
+	}
+	weight, _ := rr.ServerWeight(u)
+	return weight, nil
+}
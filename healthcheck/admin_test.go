@@ -0,0 +1,99 @@
+package healthcheck
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestAdminLoadBalancer(t *testing.T) {
+	server1, _ := url.Parse("http://10.0.0.1:80")
+	server2, _ := url.Parse("http://10.0.0.2:80")
+
+	admin := &ServersAdmin{states: make(map[string]map[string]*ServerState)}
+	rr, err := roundrobin.New(http.NotFoundHandler())
+	require.NoError(t, err)
+	lb := admin.NewLoadBalancer("file", "backend", rr, map[string]string{server1.String(): "server1", server2.String(): "server2"})
+	admin.SetLoadBalancers(map[string]*AdminLoadBalancer{"file/http/backend": lb})
+
+	require.NoError(t, lb.UpsertServer(server1, roundrobin.Weight(10)))
+	require.NoError(t, lb.UpsertServer(server2, roundrobin.Weight(20)))
+
+	// reweighted at once, the configured weight is kept for the health checks
+	weight := 5
+	admin.SetWeight("file", "backend", "server1", &weight)
+	assertWeight(t, rr, server1, 5)
+	assertWeight(t, lb, server1, 10)
+
+	// drained
+	weight = 0
+	admin.SetWeight("file", "backend", "server1", &weight)
+	assert.Equal(t, []*url.URL{server2}, rr.Servers())
+
+	// restored with its configured weight
+	admin.SetWeight("file", "backend", "server1", nil)
+	assertWeight(t, rr, server1, 10)
+
+	// a disabled server is not added back by the health checks
+	admin.SetDisabled("file", "backend", "server2", true)
+	assert.Equal(t, []*url.URL{server1}, rr.Servers())
+	require.NoError(t, lb.RemoveServer(server2))
+	require.NoError(t, lb.UpsertServer(server2, roundrobin.Weight(20)))
+	assert.Equal(t, []*url.URL{server1}, rr.Servers())
+
+	admin.SetDisabled("file", "backend", "server2", false)
+	assertWeight(t, rr, server2, 20)
+	assert.Nil(t, admin.State("file", "backend", "server2"))
+
+	// the states of a new load-balancer of the backend are applied to its servers
+	admin.SetDisabled("file", "backend", "server1", true)
+	rr, err = roundrobin.New(http.NotFoundHandler())
+	require.NoError(t, err)
+	lb = admin.NewLoadBalancer("file", "backend", rr, map[string]string{server1.String(): "server1", server2.String(): "server2"})
+	require.NoError(t, lb.UpsertServer(server1, roundrobin.Weight(10)))
+	require.NoError(t, lb.UpsertServer(server2, roundrobin.Weight(20)))
+	assert.Equal(t, []*url.URL{server2}, rr.Servers())
+}
+
+func TestAdminLoadBalancerDownServers(t *testing.T) {
+	server1, _ := url.Parse("http://10.0.0.1:80")
+	server2, _ := url.Parse("http://10.0.0.2:80")
+
+	admin := &ServersAdmin{states: make(map[string]map[string]*ServerState)}
+	rr, err := roundrobin.New(http.NotFoundHandler())
+	require.NoError(t, err)
+	lb := admin.NewLoadBalancer("file", "backend", rr, map[string]string{server1.String(): "server1", server2.String(): "server2"})
+
+	require.NoError(t, lb.UpsertServer(server1, roundrobin.Weight(10)))
+	require.NoError(t, lb.UpsertServer(server2, roundrobin.Weight(20)))
+
+	// a server down is reweighted by the resolvers without being added back
+	require.NoError(t, lb.DisableServer(server1))
+	assert.Equal(t, []*url.URL{server2}, rr.Servers())
+	require.NoError(t, lb.UpsertServer(server1, roundrobin.Weight(5)))
+	assert.Equal(t, []*url.URL{server2}, rr.Servers())
+
+	enabled, err := lb.EnableServer(server1)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assertWeight(t, rr, server1, 5)
+
+	// a server removed from the backend while down is not added back
+	require.NoError(t, lb.DisableServer(server2))
+	require.NoError(t, lb.RemoveServer(server2))
+	enabled, err = lb.EnableServer(server2)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.Equal(t, []*url.URL{server1}, rr.Servers())
+}
+
+func assertWeight(t *testing.T, lb weightedLoadBalancer, u *url.URL, expected int) {
+	t.Helper()
+	weight, ok := lb.ServerWeight(u)
+	assert.True(t, ok)
+	assert.Equal(t, expected, weight)
+}
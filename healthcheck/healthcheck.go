@@ -26,31 +26,58 @@ func GetHealthCheck() *HealthCheck {
 	return singleton
 }
 
+// DefaultTimeout is the default duration to wait for the response of a health check
+const DefaultTimeout = 5 * time.Second
+
 // Options are the public health check options.
 type Options struct {
-	Path     string
-	Port     int
-	Interval time.Duration
-	LB       LoadBalancer
+	Path      string
+	Port      int
+	Interval  time.Duration
+	Timeout   time.Duration
+	Status    int
+	LB        LoadBalancer
+	Transport http.RoundTripper
 }
 
 func (opt Options) String() string {
-	return fmt.Sprintf("[Path: %s Port: %d Interval: %s]", opt.Path, opt.Port, opt.Interval)
+	return fmt.Sprintf("[Path: %s Port: %d Interval: %s Timeout: %s Status: %d]", opt.Path, opt.Port, opt.Interval, opt.Timeout, opt.Status)
 }
 
 // BackendHealthCheck HealthCheck configuration for a backend
 type BackendHealthCheck struct {
 	Options
+	mutex          sync.RWMutex
 	disabledURLs   []*url.URL
+	weights        map[string]int // weights of the disabled servers, only used by the health check goroutine
 	requestTimeout time.Duration
 }
 
 //HealthCheck struct
 type HealthCheck struct {
+	mutex    sync.RWMutex
 	Backends map[string]*BackendHealthCheck
 	cancel   context.CancelFunc
 }
 
+// BackendStatus holds the servers of a backend, according to their health checks
+type BackendStatus struct {
+	Up   []string `json:"up"`
+	Down []string `json:"down"`
+}
+
+// weightedLoadBalancer is implemented by the load balancers able to give the weight of their servers
+type weightedLoadBalancer interface {
+	ServerWeight(u *url.URL) (int, bool)
+}
+
+// serversDisabler is implemented by the load balancers telling the servers down from the servers removed
+// from the backend, so that the health checks only add back the servers still in the backend
+type serversDisabler interface {
+	DisableServer(u *url.URL) error
+	EnableServer(u *url.URL) (bool, error)
+}
+
 // LoadBalancer includes functionality for load-balancing management.
 type LoadBalancer interface {
 	RemoveServer(u *url.URL) error
@@ -64,17 +91,30 @@ func newHealthCheck() *HealthCheck {
 	}
 }
 
+// BackendID returns the identifier of the backend of a provider on an entrypoint, namespaced by the provider
+// so that the backends of the same name of several providers do not clash
+func BackendID(providerName, entryPointName, backendName string) string {
+	return providerName + "/" + entryPointName + "/" + backendName
+}
+
 // NewBackendHealthCheck Instantiate a new BackendHealthCheck
 func NewBackendHealthCheck(options Options) *BackendHealthCheck {
+	requestTimeout := DefaultTimeout
+	if options.Timeout > 0 {
+		requestTimeout = options.Timeout
+	}
 	return &BackendHealthCheck{
 		Options:        options,
-		requestTimeout: 5 * time.Second,
+		weights:        make(map[string]int),
+		requestTimeout: requestTimeout,
 	}
 }
 
 //SetBackendsConfiguration set backends configuration
 func (hc *HealthCheck) SetBackendsConfiguration(parentCtx context.Context, backends map[string]*BackendHealthCheck) {
+	hc.mutex.Lock()
 	hc.Backends = backends
+	hc.mutex.Unlock()
 	if hc.cancel != nil {
 		hc.cancel()
 	}
@@ -107,25 +147,83 @@ func (hc *HealthCheck) execute(ctx context.Context, backendID string, backend *B
 	}
 }
 
+// Status returns the servers of each backend, according to their health checks
+func (hc *HealthCheck) Status() map[string]*BackendStatus {
+	hc.mutex.RLock()
+	defer hc.mutex.RUnlock()
+
+	status := make(map[string]*BackendStatus)
+	for backendID, backend := range hc.Backends {
+		status[backendID] = backend.status()
+	}
+	return status
+}
+
+func (backend *BackendHealthCheck) status() *BackendStatus {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+
+	status := &BackendStatus{Up: []string{}, Down: []string{}}
+	for _, u := range backend.LB.Servers() {
+		status.Up = append(status.Up, u.String())
+	}
+	for _, u := range backend.disabledURLs {
+		status.Down = append(status.Down, u.String())
+	}
+	return status
+}
+
 func checkBackend(currentBackend *BackendHealthCheck) {
 	enabledURLs := currentBackend.LB.Servers()
+	disabler, _ := currentBackend.LB.(serversDisabler)
 	var newDisabledURLs []*url.URL
 	for _, url := range currentBackend.disabledURLs {
 		if checkHealth(url, currentBackend) {
-			log.Debugf("HealthCheck is up [%s]: Upsert in server list", url.String())
-			currentBackend.LB.UpsertServer(url, roundrobin.Weight(1))
+			if disabler != nil {
+				if enabled, err := disabler.EnableServer(url); err != nil {
+					log.Errorf("Error adding back server %s: %v", url, err)
+				} else if enabled {
+					log.Infof("HealthCheck is up [%s]: Upsert in server list", url.String())
+				} else {
+					log.Debugf("HealthCheck is up [%s], no longer in the backend", url.String())
+				}
+				continue
+			}
+			log.Infof("HealthCheck is up [%s]: Upsert in server list", url.String())
+			weight, ok := currentBackend.weights[url.String()]
+			if !ok {
+				weight = 1
+			}
+			delete(currentBackend.weights, url.String())
+			currentBackend.LB.UpsertServer(url, roundrobin.Weight(weight))
 		} else {
 			log.Warnf("HealthCheck is still failing [%s]", url.String())
 			newDisabledURLs = append(newDisabledURLs, url)
 		}
 	}
+	currentBackend.mutex.Lock()
 	currentBackend.disabledURLs = newDisabledURLs
+	currentBackend.mutex.Unlock()
 
 	for _, url := range enabledURLs {
 		if !checkHealth(url, currentBackend) {
 			log.Warnf("HealthCheck has failed [%s]: Remove from server list", url.String())
-			currentBackend.LB.RemoveServer(url)
+			if disabler != nil {
+				if err := disabler.DisableServer(url); err != nil {
+					log.Errorf("Error removing server %s: %v", url, err)
+				}
+			} else {
+				// the weight is restored when the server recovers
+				if lb, ok := currentBackend.LB.(weightedLoadBalancer); ok {
+					if weight, found := lb.ServerWeight(url); found {
+						currentBackend.weights[url.String()] = weight
+					}
+				}
+				currentBackend.LB.RemoveServer(url)
+			}
+			currentBackend.mutex.Lock()
 			currentBackend.disabledURLs = append(currentBackend.disabledURLs, url)
+			currentBackend.mutex.Unlock()
 		}
 	}
 }
@@ -146,7 +244,8 @@ func (backend *BackendHealthCheck) newRequest(serverURL *url.URL) (*http.Request
 
 func checkHealth(serverURL *url.URL, backend *BackendHealthCheck) bool {
 	client := http.Client{
-		Timeout: backend.requestTimeout,
+		Timeout:   backend.requestTimeout,
+		Transport: backend.Transport,
 	}
 	req, err := backend.newRequest(serverURL)
 	if err != nil {
@@ -155,9 +254,19 @@ func checkHealth(serverURL *url.URL, backend *BackendHealthCheck) bool {
 	}
 
 	resp, err := client.Do(req)
+	if err != nil {
+		log.Debugf("HealthCheck request failed [%s]: %s", serverURL, err)
+		return false
+	}
+	defer resp.Body.Close()
 
-	if err == nil {
-		defer resp.Body.Close()
+	expectedStatus := http.StatusOK
+	if backend.Status != 0 {
+		expectedStatus = backend.Status
+	}
+	if resp.StatusCode != expectedStatus {
+		log.Debugf("HealthCheck got status %d instead of %d [%s]", resp.StatusCode, expectedStatus, serverURL)
+		return false
 	}
-	return err == nil && resp.StatusCode == 200
+	return true
 }
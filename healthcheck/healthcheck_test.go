@@ -188,6 +188,167 @@ func TestNewRequest(t *testing.T) {
 	}
 }
 
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		desc           string
+		responseStatus int
+		responseDelay  time.Duration
+		status         int
+		timeout        time.Duration
+		expected       bool
+	}{
+		{
+			desc:           "default expected status",
+			responseStatus: http.StatusOK,
+			expected:       true,
+		},
+		{
+			desc:           "unexpected status",
+			responseStatus: http.StatusServiceUnavailable,
+			expected:       false,
+		},
+		{
+			desc:           "custom expected status",
+			responseStatus: http.StatusNoContent,
+			status:         http.StatusNoContent,
+			expected:       true,
+		},
+		{
+			desc:           "200 when another status is expected",
+			responseStatus: http.StatusOK,
+			status:         http.StatusNoContent,
+			expected:       false,
+		},
+		{
+			desc:           "timeout",
+			responseStatus: http.StatusOK,
+			responseDelay:  200 * time.Millisecond,
+			timeout:        50 * time.Millisecond,
+			expected:       false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(test.responseDelay)
+				w.WriteHeader(test.responseStatus)
+			}))
+			defer ts.Close()
+
+			backend := NewBackendHealthCheck(Options{
+				Path:    "/path",
+				Timeout: test.timeout,
+				Status:  test.status,
+			})
+
+			healthy := checkHealth(testhelpers.MustParseURL(ts.URL), backend)
+			if healthy != test.expected {
+				t.Errorf("got healthy %t, wanted %t", healthy, test.expected)
+			}
+		})
+	}
+}
+
+func TestCheckBackendRestoresWeight(t *testing.T) {
+	healthy := true
+	var mutex sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	lb, err := roundrobin.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverURL := testhelpers.MustParseURL(ts.URL)
+	if err := lb.UpsertServer(serverURL, roundrobin.Weight(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NewBackendHealthCheck(Options{Path: "/path", LB: lb})
+	check := HealthCheck{Backends: map[string]*BackendHealthCheck{"backend": backend}}
+
+	mutex.Lock()
+	healthy = false
+	mutex.Unlock()
+	checkBackend(backend)
+	status := check.Status()["backend"]
+	if len(status.Up) != 0 || len(status.Down) != 1 || status.Down[0] != ts.URL {
+		t.Fatalf("got status %+v, wanted %s down", status, ts.URL)
+	}
+
+	mutex.Lock()
+	healthy = true
+	mutex.Unlock()
+	checkBackend(backend)
+	status = check.Status()["backend"]
+	if len(status.Up) != 1 || len(status.Down) != 0 || status.Up[0] != ts.URL {
+		t.Fatalf("got status %+v, wanted %s up", status, ts.URL)
+	}
+
+	if weight, _ := lb.ServerWeight(serverURL); weight != 3 {
+		t.Errorf("got weight %d after recovery, wanted 3", weight)
+	}
+}
+
+func TestCheckBackendServerRemovedWhileDown(t *testing.T) {
+	healthy := false
+	var mutex sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	rr, err := roundrobin.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverURL := testhelpers.MustParseURL(ts.URL)
+	admin := &ServersAdmin{states: make(map[string]map[string]*ServerState)}
+	lb := admin.NewLoadBalancer("file", "backend", rr, map[string]string{})
+	if err := lb.UpsertServer(serverURL, roundrobin.Weight(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NewBackendHealthCheck(Options{Path: "/path", LB: lb})
+	checkBackend(backend)
+	if len(rr.Servers()) != 0 {
+		t.Fatalf("got servers %v, wanted %s down", rr.Servers(), ts.URL)
+	}
+
+	// removed by the resolver while down
+	if err := lb.RemoveServer(serverURL); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	healthy = true
+	mutex.Unlock()
+	checkBackend(backend)
+	if len(rr.Servers()) != 0 {
+		t.Errorf("got servers %v, wanted %s not added back", rr.Servers(), ts.URL)
+	}
+	if status := backend.status(); len(status.Down) != 0 {
+		t.Errorf("got servers %v down, wanted none", status.Down)
+	}
+}
+
 type testLoadBalancer struct {
 	// RWMutex needed due to parallel test execution: Both the system-under-test
 	// and the test assertions reference the counters.
@@ -0,0 +1,195 @@
+package healthcheck
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// DefaultMaxFailures is the default number of consecutive failures after which a server is removed from the load balancer
+const DefaultMaxFailures = 5
+
+// DefaultEjectionTime is the default duration during which a failing server is removed from the load balancer
+const DefaultEjectionTime = 30 * time.Second
+
+// PassiveOptions are the passive health check options.
+type PassiveOptions struct {
+	MaxFailures  int
+	EjectionTime time.Duration
+}
+
+func (opt PassiveOptions) String() string {
+	return fmt.Sprintf("[MaxFailures: %d EjectionTime: %s]", opt.MaxFailures, opt.EjectionTime)
+}
+
+// PassiveHealthCheck watches the responses of the servers on the live traffic: a server answering
+// MaxFailures times in a row with a 5XX status code, connection failures included, is removed from
+// the load balancer during EjectionTime.
+// The last server of the load balancer is never removed.
+type PassiveHealthCheck struct {
+	PassiveOptions
+	next     http.Handler
+	mutex    sync.Mutex
+	lb       LoadBalancer
+	failures map[string]int
+	ejected  map[string]bool
+}
+
+// NewPassiveHealthCheck creates a PassiveHealthCheck forwarding the requests to next
+func NewPassiveHealthCheck(next http.Handler, options PassiveOptions) *PassiveHealthCheck {
+	if options.MaxFailures <= 0 {
+		options.MaxFailures = DefaultMaxFailures
+	}
+	if options.EjectionTime <= 0 {
+		options.EjectionTime = DefaultEjectionTime
+	}
+	return &PassiveHealthCheck{
+		PassiveOptions: options,
+		next:           next,
+		failures:       make(map[string]int),
+		ejected:        make(map[string]bool),
+	}
+}
+
+// SetLoadBalancer sets the load balancer the failing servers are removed from
+func (p *PassiveHealthCheck) SetLoadBalancer(lb LoadBalancer) {
+	p.mutex.Lock()
+	p.lb = lb
+	p.mutex.Unlock()
+}
+
+func (p *PassiveHealthCheck) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	recorder := &statusCodeRecorder{ResponseWriter: rw, statusCode: http.StatusOK}
+	p.next.ServeHTTP(recorder, req)
+	p.record(req.URL, recorder.statusCode < http.StatusInternalServerError)
+}
+
+func (p *PassiveHealthCheck) record(serverURL *url.URL, success bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := serverURL.Scheme + "://" + serverURL.Host
+	if success {
+		delete(p.failures, key)
+		return
+	}
+
+	p.failures[key]++
+	if p.failures[key] < p.MaxFailures || p.ejected[key] || p.lb == nil {
+		return
+	}
+	delete(p.failures, key)
+
+	var server *url.URL
+	servers := p.lb.Servers()
+	for _, u := range servers {
+		if u.Scheme+"://"+u.Host == key {
+			server = u
+		}
+	}
+	if server == nil {
+		return
+	}
+	if len(servers) == 1 {
+		log.Warnf("Passive HealthCheck has failed [%s]: last server kept in server list", key)
+		return
+	}
+
+	log.Warnf("Passive HealthCheck has failed [%s]: Remove from server list for %s", key, p.EjectionTime)
+	lb := p.lb
+	if disabler, ok := lb.(serversDisabler); ok {
+		if err := disabler.DisableServer(server); err != nil {
+			log.Errorf("Error removing server %s: %v", key, err)
+			return
+		}
+		p.ejected[key] = true
+		time.AfterFunc(p.EjectionTime, func() {
+			p.endEjection(key, func() (bool, error) {
+				return disabler.EnableServer(server)
+			})
+		})
+		return
+	}
+
+	weight := 1
+	if weighted, ok := lb.(weightedLoadBalancer); ok {
+		if w, found := weighted.ServerWeight(server); found {
+			weight = w
+		}
+	}
+	if err := lb.RemoveServer(server); err != nil {
+		log.Errorf("Error removing server %s: %v", key, err)
+		return
+	}
+	p.ejected[key] = true
+	time.AfterFunc(p.EjectionTime, func() {
+		p.endEjection(key, func() (bool, error) {
+			// the load balancer replaced by a reload has the servers of the new configuration
+			if p.lb != lb {
+				return false, nil
+			}
+			return true, lb.UpsertServer(server, roundrobin.Weight(weight))
+		})
+	})
+}
+
+// endEjection adds back the server ejected, with the lock held, unless it has been removed from the backend
+// in the meantime, by a reload of the configuration, the resolvers or the API
+func (p *PassiveHealthCheck) endEjection(key string, addBack func() (bool, error)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.ejected, key)
+	added, err := addBack()
+	switch {
+	case err != nil:
+		log.Errorf("Error adding server %s: %v", key, err)
+	case added:
+		log.Infof("Passive HealthCheck ejection is over [%s]: Upsert in server list", key)
+	default:
+		log.Infof("Passive HealthCheck ejection is over [%s]: no longer in server list", key)
+	}
+}
+
+// statusCodeRecorder records the status code of the response
+type statusCodeRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *statusCodeRecorder) WriteHeader(code int) {
+	r.statusCode = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack hijacks the connection
+func (r *statusCodeRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("not a hijacker: %T", r.ResponseWriter)
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (r *statusCodeRecorder) CloseNotify() <-chan bool {
+	if c, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client.
+func (r *statusCodeRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,136 @@
+package healthcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestPassiveHealthCheck(t *testing.T) {
+	// the server "bad" always fails
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Host == "bad" {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	phc := NewPassiveHealthCheck(next, PassiveOptions{MaxFailures: 3, EjectionTime: 100 * time.Millisecond})
+	rr, err := roundrobin.New(phc)
+	require.NoError(t, err)
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://good"), roundrobin.Weight(1)))
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://bad"), roundrobin.Weight(2)))
+	phc.SetLoadBalancer(rr)
+
+	serve := func(host string) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL = testhelpers.MustParseURL("http://" + host)
+		phc.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// the failures of a server must be consecutive
+	badURL := testhelpers.MustParseURL("http://bad")
+	phc.record(badURL, false)
+	phc.record(badURL, false)
+	phc.record(badURL, true)
+	serve("bad")
+	serve("bad")
+	assert.Len(t, rr.Servers(), 2)
+
+	serve("bad")
+	assert.Equal(t, []string{"http://good"}, serverHosts(rr))
+
+	// the last server is never removed
+	for i := 0; i < 3; i++ {
+		serve("good")
+	}
+	phc.record(testhelpers.MustParseURL("http://good"), false)
+	phc.record(testhelpers.MustParseURL("http://good"), false)
+	phc.record(testhelpers.MustParseURL("http://good"), false)
+	assert.Equal(t, []string{"http://good"}, serverHosts(rr))
+
+	// the server is back with its weight after the ejection time
+	deadline := time.Now().Add(time.Second)
+	for len(rr.Servers()) != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, rr.Servers(), 2)
+	weight, found := rr.ServerWeight(badURL)
+	assert.True(t, found)
+	assert.Equal(t, 2, weight)
+}
+
+func TestPassiveHealthCheckServerRemovedDuringEjection(t *testing.T) {
+	goodURL := testhelpers.MustParseURL("http://good")
+	badURL := testhelpers.MustParseURL("http://bad")
+
+	testCases := []struct {
+		desc   string
+		admin  bool
+		remove func(phc *PassiveHealthCheck, lb LoadBalancer)
+	}{
+		{
+			desc:  "removed from the backend by the resolvers",
+			admin: true,
+			remove: func(phc *PassiveHealthCheck, lb LoadBalancer) {
+				require.NoError(t, lb.RemoveServer(badURL))
+			},
+		},
+		{
+			desc: "load balancer replaced by a reload",
+			remove: func(phc *PassiveHealthCheck, lb LoadBalancer) {
+				rr, err := roundrobin.New(http.NotFoundHandler())
+				require.NoError(t, err)
+				phc.SetLoadBalancer(rr)
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			phc := NewPassiveHealthCheck(http.NotFoundHandler(), PassiveOptions{MaxFailures: 1, EjectionTime: 50 * time.Millisecond})
+			rr, err := roundrobin.New(phc)
+			require.NoError(t, err)
+			var lb LoadBalancer = rr
+			if test.admin {
+				admin := &ServersAdmin{states: make(map[string]map[string]*ServerState)}
+				lb = admin.NewLoadBalancer("file", "backend", rr, map[string]string{})
+			}
+			require.NoError(t, lb.UpsertServer(goodURL, roundrobin.Weight(1)))
+			require.NoError(t, lb.UpsertServer(badURL, roundrobin.Weight(1)))
+			phc.SetLoadBalancer(lb)
+
+			phc.record(badURL, false)
+			assert.Equal(t, []string{"http://good"}, serverHosts(rr))
+
+			test.remove(phc, lb)
+			time.Sleep(200 * time.Millisecond)
+			assert.Equal(t, []string{"http://good"}, serverHosts(rr))
+		})
+	}
+}
+
+func TestPassiveHealthCheckDefaults(t *testing.T) {
+	phc := NewPassiveHealthCheck(nil, PassiveOptions{})
+
+	assert.Equal(t, DefaultMaxFailures, phc.MaxFailures)
+	assert.Equal(t, DefaultEjectionTime, phc.EjectionTime)
+}
+
+func serverHosts(lb LoadBalancer) []string {
+	var hosts []string
+	for _, u := range lb.Servers() {
+		hosts = append(hosts, u.String())
+	}
+	return hosts
+}
@@ -0,0 +1,158 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/containous/traefik/quic"
+)
+
+// frame types (RFC 9114 section 7.2)
+const (
+	frameData        = 0x00
+	frameHeaders     = 0x01
+	frameCancelPush  = 0x03
+	frameSettings    = 0x04
+	framePushPromise = 0x05
+	frameGoAway      = 0x07
+	frameMaxPushID   = 0x0d
+)
+
+// unidirectional stream types (RFC 9114 section 6.2 and RFC 9204 section 4.2)
+const (
+	streamControl      = 0x00
+	streamPush         = 0x01
+	streamQPACKEncoder = 0x02
+	streamQPACKDecoder = 0x03
+)
+
+// settingMaxFieldSectionSize is the SETTINGS_MAX_FIELD_SECTION_SIZE setting (RFC 9114 section 7.2.4.1)
+const settingMaxFieldSectionSize = 0x06
+
+// error codes (RFC 9114 section 8.1 and RFC 9204 section 6)
+const (
+	errorNoError              = 0x100
+	errorInternal             = 0x102
+	errorStreamCreation       = 0x103
+	errorClosedCriticalStream = 0x104
+	errorFrameUnexpected      = 0x105
+	errorFrame                = 0x106
+	errorExcessiveLoad        = 0x107
+	errorSettings             = 0x109
+	errorMissingSettings      = 0x10a
+	errorRequestRejected      = 0x10b
+	errorRequestIncomplete    = 0x10d
+	errorMessage              = 0x10e
+	errorQPACKDecompression   = 0x200
+)
+
+// connectionError is an error closing the whole connection
+type connectionError struct {
+	code   uint64
+	reason string
+}
+
+func (e *connectionError) Error() string {
+	return fmt.Sprintf("http3: connection error 0x%x: %s", e.code, e.reason)
+}
+
+// streamError is an error aborting a request stream only
+type streamError struct {
+	code   uint64
+	reason string
+}
+
+func (e *streamError) Error() string {
+	return fmt.Sprintf("http3: stream error 0x%x: %s", e.code, e.reason)
+}
+
+var errFrameTooLarge = errors.New("http3: frame too large")
+
+// frameReader reads the frames of a stream
+type frameReader struct {
+	r *bufio.Reader
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReader(r)}
+}
+
+// next reads the header of the next frame, returning io.EOF if the stream ends between two frames
+func (f *frameReader) next() (typ, length uint64, err error) {
+	typ, err = quic.ReadVarint(f.r)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err = quic.ReadVarint(f.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return typ, length, err
+}
+
+// payload reads the payload of a frame, up to maxLength bytes
+func (f *frameReader) payload(length uint64, maxLength int) ([]byte, error) {
+	if length > uint64(maxLength) {
+		return nil, errFrameTooLarge
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(f.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// skip discards the payload of a frame
+func (f *frameReader) skip(length uint64) error {
+	n, err := io.CopyN(ioutil.Discard, f.r, int64(length))
+	if err == io.EOF && uint64(n) < length {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func appendFrameHeader(b []byte, typ uint64, length int) []byte {
+	return quic.AppendVarint(quic.AppendVarint(b, typ), uint64(length))
+}
+
+// isReservedHTTP2Frame returns whether the frame type is one of the HTTP/2 frame types not used by HTTP/3
+func isReservedHTTP2Frame(typ uint64) bool {
+	return typ == 0x02 || typ == 0x06 || typ == 0x08 || typ == 0x09
+}
+
+// appendSettingsFrame appends the SETTINGS frame of the server, which has no QPACK dynamic table
+func appendSettingsFrame(b []byte, maxFieldSectionSize uint64) []byte {
+	var settings []byte
+	settings = quic.AppendVarint(quic.AppendVarint(settings, settingMaxFieldSectionSize), maxFieldSectionSize)
+	return append(appendFrameHeader(b, frameSettings, len(settings)), settings...)
+}
+
+// checkSettings validates the SETTINGS frame of the client, the settings of the dynamic table being useless
+// for a server which never sends instructions on its QPACK encoder stream
+func checkSettings(payload []byte) error {
+	r := bytes.NewReader(payload)
+	seen := make(map[uint64]bool)
+	for {
+		id, err := quic.ReadVarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &connectionError{code: errorFrame, reason: "invalid SETTINGS frame"}
+		}
+		if _, err := quic.ReadVarint(r); err != nil {
+			return &connectionError{code: errorFrame, reason: "invalid SETTINGS frame"}
+		}
+		if id >= 0x02 && id <= 0x05 || seen[id] {
+			return &connectionError{code: errorSettings, reason: fmt.Sprintf("invalid setting 0x%x", id)}
+		}
+		seen[id] = true
+	}
+}
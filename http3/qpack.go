@@ -0,0 +1,306 @@
+package http3
+
+import (
+	"errors"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// headerField is a field line of a field section
+type headerField struct {
+	name, value string
+}
+
+// staticTable is the QPACK static table (RFC 9204 appendix A)
+var staticTable = [...]headerField{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
+
+var (
+	staticFieldIndex = make(map[headerField]int)
+	staticNameIndex  = make(map[string]int)
+)
+
+func init() {
+	for i, field := range staticTable {
+		staticFieldIndex[field] = i
+		if _, ok := staticNameIndex[field.name]; !ok {
+			staticNameIndex[field.name] = i
+		}
+	}
+}
+
+var (
+	errQPACKDynamicTable = errors.New("reference to the dynamic table")
+	errQPACKInvalid      = errors.New("invalid field section")
+	errQPACKTooLarge     = errors.New("field section too large")
+)
+
+// encodeFieldSection encodes a field section without referencing the dynamic table, the names being lowercase
+func encodeFieldSection(b []byte, fields []headerField) []byte {
+	// Required Insert Count and Delta Base
+	b = append(b, 0, 0)
+	for _, field := range fields {
+		if i, ok := staticFieldIndex[field]; ok {
+			// indexed field line, static table
+			b = appendPrefixInt(b, 0xc0, 6, uint64(i))
+			continue
+		}
+		if i, ok := staticNameIndex[field.name]; ok {
+			// literal field line with a name reference to the static table
+			b = appendPrefixInt(b, 0x50, 4, uint64(i))
+			b = appendString(b, 0, 7, field.value)
+			continue
+		}
+		// literal field line with a literal name
+		b = appendString(b, 0x20, 3, field.name)
+		b = appendString(b, 0, 7, field.value)
+	}
+	return b
+}
+
+// decodeFieldSection decodes a field section referencing the static table only, the decoder having no dynamic table.
+// The decoded size of the section, as defined by RFC 9114 section 4.2.2, is limited to maxSize.
+func decodeFieldSection(data []byte, maxSize int) ([]headerField, error) {
+	d := &qpackDecoder{data: data}
+	if insertCount, _ := d.prefixInt(8); insertCount != 0 {
+		return nil, errQPACKDynamicTable
+	}
+	d.prefixInt(7) // Delta Base, meaningless without the dynamic table
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var fields []headerField
+	size := 0
+	for len(d.data) > 0 {
+		first := d.data[0]
+		var field headerField
+		switch {
+		case first&0x80 != 0:
+			// indexed field line
+			if first&0x40 == 0 {
+				return nil, errQPACKDynamicTable
+			}
+			i, _ := d.prefixInt(6)
+			field = d.static(i)
+		case first&0x40 != 0:
+			// literal field line with a name reference
+			if first&0x10 == 0 {
+				return nil, errQPACKDynamicTable
+			}
+			i, _ := d.prefixInt(4)
+			field = d.static(i)
+			field.value = d.string(7)
+		case first&0x20 != 0:
+			// literal field line with a literal name
+			field.name = d.string(3)
+			field.value = d.string(7)
+		default:
+			// indexed field line or literal field line with a post-base index
+			return nil, errQPACKDynamicTable
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if size += len(field.name) + len(field.value) + 32; size > maxSize {
+			return nil, errQPACKTooLarge
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+type qpackDecoder struct {
+	data []byte
+	err  error
+}
+
+// prefixInt reads an integer encoded with a prefix of n bits (RFC 7541 section 5.1), returning also the bits before it
+func (d *qpackDecoder) prefixInt(n uint) (uint64, byte) {
+	if d.err != nil || len(d.data) == 0 {
+		d.fail()
+		return 0, 0
+	}
+	mask := uint64(1)<<n - 1
+	flags := d.data[0] &^ byte(mask)
+	v := uint64(d.data[0]) & mask
+	d.data = d.data[1:]
+	if v < mask {
+		return v, flags
+	}
+	for shift := uint(0); ; shift += 7 {
+		if len(d.data) == 0 || shift > 56 {
+			d.fail()
+			return 0, 0
+		}
+		b := d.data[0]
+		d.data = d.data[1:]
+		v += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, flags
+		}
+	}
+}
+
+// string reads a string literal whose length has a prefix of n bits, preceded by the Huffman flag
+func (d *qpackDecoder) string(n uint) string {
+	length, flags := d.prefixInt(n)
+	if d.err != nil {
+		return ""
+	}
+	if length > uint64(len(d.data)) {
+		d.fail()
+		return ""
+	}
+	data := d.data[:length]
+	d.data = d.data[length:]
+	if flags&(1<<n) == 0 {
+		return string(data)
+	}
+	s, err := hpack.HuffmanDecodeToString(data)
+	if err != nil {
+		d.fail()
+	}
+	return s
+}
+
+func (d *qpackDecoder) static(i uint64) headerField {
+	if d.err != nil {
+		return headerField{}
+	}
+	if i >= uint64(len(staticTable)) {
+		d.fail()
+		return headerField{}
+	}
+	return staticTable[i]
+}
+
+func (d *qpackDecoder) fail() {
+	if d.err == nil {
+		d.err = errQPACKInvalid
+	}
+}
+
+// appendPrefixInt appends an integer encoded with a prefix of n bits, after the bits of flags
+func appendPrefixInt(b []byte, flags byte, n uint, v uint64) []byte {
+	mask := uint64(1)<<n - 1
+	if v < mask {
+		return append(b, flags|byte(v))
+	}
+	b = append(b, flags|byte(mask))
+	v -= mask
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendString appends a string literal whose length has a prefix of n bits, Huffman encoded when shorter
+func appendString(b []byte, flags byte, n uint, s string) []byte {
+	if length := hpack.HuffmanEncodeLength(s); length < uint64(len(s)) {
+		b = appendPrefixInt(b, flags|1<<n, n, length)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendPrefixInt(b, flags, n, uint64(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,111 @@
+package http3
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQPACKRoundTrip(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		fields []headerField
+	}{
+		{
+			desc:   "static table fields",
+			fields: []headerField{{":method", "GET"}, {":scheme", "https"}, {":path", "/"}},
+		},
+		{
+			desc:   "static table names",
+			fields: []headerField{{":authority", "www.example.com"}, {":path", "/index.html"}, {"user-agent", "curl/7.54"}},
+		},
+		{
+			desc:   "literal names",
+			fields: []headerField{{"x-request-id", "4f8e6a3c"}, {"x-empty", ""}},
+		},
+		{
+			desc:   "long value",
+			fields: []headerField{{"cookie", strings.Repeat("a=1; ", 100)}},
+		},
+		{
+			desc:   "non-ASCII value",
+			fields: []headerField{{"x-value", "caf\xc3\xa9"}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fields, err := decodeFieldSection(encodeFieldSection(nil, test.fields), 1<<20)
+			require.NoError(t, err)
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+func TestDecodeFieldSection(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		section       string
+		maxSize       int
+		expected      []headerField
+		expectedError error
+	}{
+		{
+			// the example of RFC 9204 appendix B.1
+			desc:     "literal field line with a name reference",
+			section:  "0000510b2f696e6465782e68746d6c",
+			maxSize:  1 << 10,
+			expected: []headerField{{":path", "/index.html"}},
+		},
+		{
+			desc:     "Huffman-encoded value",
+			section:  "000050" + "8c" + "f1e3c2e5f23a6ba0ab90f4ff",
+			maxSize:  1 << 10,
+			expected: []headerField{{":authority", "www.example.com"}},
+		},
+		{
+			desc:          "reference to the dynamic table",
+			section:       "0200" + "80",
+			maxSize:       1 << 10,
+			expectedError: errQPACKDynamicTable,
+		},
+		{
+			desc:          "indexed field line of the dynamic table",
+			section:       "0000" + "80",
+			maxSize:       1 << 10,
+			expectedError: errQPACKDynamicTable,
+		},
+		{
+			desc:          "too large",
+			section:       "0000510b2f696e6465782e68746d6c",
+			maxSize:       32,
+			expectedError: errQPACKTooLarge,
+		},
+		{
+			desc:          "truncated",
+			section:       "0000510b2f696e",
+			maxSize:       1 << 10,
+			expectedError: errQPACKInvalid,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			section, err := hex.DecodeString(test.section)
+			require.NoError(t, err)
+
+			fields, err := decodeFieldSection(section, test.maxSize)
+			assert.Equal(t, test.expectedError, err)
+			assert.Equal(t, test.expected, fields)
+		})
+	}
+}
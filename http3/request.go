@@ -0,0 +1,407 @@
+package http3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/quic"
+	"golang.org/x/net/lex/httplex"
+)
+
+// requestHeaders holds the pseudo-header fields and the header fields of a request
+type requestHeaders struct {
+	method, scheme, authority, path string
+	header                          http.Header
+}
+
+// connectionHeaders are the header fields forbidden in HTTP/3 messages (RFC 9114 section 4.2)
+var connectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// parseRequestHeaders validates the field section of a request, returning an H3_MESSAGE_ERROR stream error
+// if the request is malformed (RFC 9114 section 4.1.2)
+func parseRequestHeaders(fields []headerField) (*requestHeaders, error) {
+	h := &requestHeaders{header: make(http.Header)}
+	pseudo := true
+	seen := make(map[string]bool)
+	var cookies []string
+	for _, field := range fields {
+		if strings.HasPrefix(field.name, ":") {
+			if !pseudo {
+				return nil, malformed("pseudo-header field %s after the header fields", field.name)
+			}
+			if seen[field.name] {
+				return nil, malformed("duplicate pseudo-header field %s", field.name)
+			}
+			seen[field.name] = true
+			switch field.name {
+			case ":method":
+				h.method = field.value
+			case ":scheme":
+				h.scheme = field.value
+			case ":authority":
+				h.authority = field.value
+			case ":path":
+				h.path = field.value
+			default:
+				return nil, malformed("invalid pseudo-header field %s", field.name)
+			}
+			continue
+		}
+
+		pseudo = false
+		if !httplex.ValidHeaderFieldName(field.name) || strings.ToLower(field.name) != field.name {
+			return nil, malformed("invalid header field name %q", field.name)
+		}
+		if !httplex.ValidHeaderFieldValue(field.value) {
+			return nil, malformed("invalid value of the header field %s", field.name)
+		}
+		if connectionHeaders[field.name] || field.name == "te" && field.value != "trailers" {
+			return nil, malformed("connection-specific header field %s", field.name)
+		}
+		if field.name == "cookie" {
+			cookies = append(cookies, field.value)
+			continue
+		}
+		h.header.Add(http.CanonicalHeaderKey(field.name), field.value)
+	}
+	if len(cookies) > 0 {
+		// the cookie header fields are concatenated for the HTTP/1.1 backends (RFC 9114 section 4.2.1)
+		h.header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	if h.method == "" {
+		return nil, malformed("missing :method pseudo-header field")
+	}
+	if h.method == http.MethodConnect {
+		if h.scheme != "" || h.path != "" || h.authority == "" {
+			return nil, malformed("invalid pseudo-header fields of CONNECT request")
+		}
+		return h, nil
+	}
+	if h.scheme == "" || h.path == "" {
+		return nil, malformed("missing :scheme or :path pseudo-header field")
+	}
+	if (h.scheme == "http" || h.scheme == "https") && h.authority == "" && h.header.Get("Host") == "" {
+		return nil, malformed("missing :authority pseudo-header field")
+	}
+	return h, nil
+}
+
+func malformed(format string, args ...interface{}) error {
+	return &streamError{code: errorMessage, reason: fmt.Sprintf(format, args...)}
+}
+
+// serveRequest reads the request of a stream, and writes the response of the handler
+func (sc *serverConn) serveRequest(s *quic.Stream) {
+	fr := newFrameReader(s)
+	req, body, err := sc.readRequest(s, fr)
+	if err != nil {
+		sc.abortRequest(s, err)
+		return
+	}
+
+	w := newResponseWriter(s, req)
+	if !sc.runHandler(w, req) {
+		s.CancelWrite(errorInternal)
+		s.CancelRead(errorInternal)
+		return
+	}
+	if err := w.finish(); err != nil {
+		s.CancelWrite(errorInternal)
+	}
+	if !body.done {
+		// the response is complete, the client can stop sending the request (RFC 9114 section 4.1.1)
+		s.CancelRead(errorNoError)
+	}
+}
+
+// runHandler runs the handler, returning false if it panics
+func (sc *serverConn) runHandler(w *responseWriter, req *http.Request) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err != http.ErrAbortHandler {
+				buf := make([]byte, 64<<10)
+				buf = buf[:runtime.Stack(buf, false)]
+				log.Errorf("http3: panic serving %v: %v\n%s", req.RemoteAddr, err, buf)
+			}
+			ok = false
+		}
+	}()
+	sc.server.Handler.ServeHTTP(w, req)
+	return true
+}
+
+// abortRequest aborts a request stream, or the whole connection, after an error reading the request
+func (sc *serverConn) abortRequest(s *quic.Stream, err error) {
+	switch e := err.(type) {
+	case *connectionError:
+		sc.close(e)
+	case *streamError:
+		log.Debugf("Aborting HTTP/3 request from %s: %v", sc.conn.RemoteAddr(), err)
+		s.CancelRead(e.code)
+		s.CancelWrite(e.code)
+	default:
+		s.CancelRead(errorRequestIncomplete)
+		s.CancelWrite(errorRequestIncomplete)
+	}
+}
+
+// readRequest reads the HEADERS frame of a request stream, and builds the request with its body
+func (sc *serverConn) readRequest(s *quic.Stream, fr *frameReader) (*http.Request, *requestBody, error) {
+	fields, err := sc.readHeaders(fr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fields == nil {
+		return nil, nil, &streamError{code: errorRequestIncomplete, reason: "stream ended before the request headers"}
+	}
+	h, err := parseRequestHeaders(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var u *url.URL
+	requestURI := h.path
+	if h.method == http.MethodConnect {
+		u = &url.URL{Host: h.authority}
+		requestURI = h.authority
+	} else if u, err = url.ParseRequestURI(h.path); err != nil {
+		return nil, nil, malformed("invalid :path %q", h.path)
+	}
+
+	host := h.authority
+	if host == "" {
+		host = h.header.Get("Host")
+	}
+	h.header.Del("Host")
+
+	body := &requestBody{sc: sc, stream: s, fr: fr, contentLength: -1}
+	if values, ok := h.header["Content-Length"]; ok {
+		length, err := strconv.ParseInt(values[0], 10, 64)
+		for _, value := range values[1:] {
+			if value != values[0] {
+				err = fmt.Errorf("multiple values")
+			}
+		}
+		if err != nil || length < 0 {
+			return nil, nil, malformed("invalid content-length %q", strings.Join(values, ","))
+		}
+		body.contentLength = length
+	}
+	if _, err := s.Read(nil); fr.r.Buffered() == 0 && err == io.EOF {
+		// the request has no body, the stream having ended with its headers
+		if body.contentLength > 0 {
+			return nil, nil, malformed("request body smaller than its content-length")
+		}
+		body.contentLength = 0
+		body.done = true
+	}
+
+	state := sc.conn.ConnectionState()
+	ctx := context.WithValue(s.Context(), http.LocalAddrContextKey, sc.conn.LocalAddr())
+	req := &http.Request{
+		Method:        h.method,
+		URL:           u,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		Header:        h.header,
+		Host:          host,
+		RemoteAddr:    sc.conn.RemoteAddr().String(),
+		RequestURI:    requestURI,
+		TLS:           &state,
+		ContentLength: body.contentLength,
+		Body:          body,
+	}
+	if body.done {
+		req.Body = http.NoBody
+	}
+	for _, value := range h.header["Trailer"] {
+		for _, key := range strings.Split(value, ",") {
+			if key = http.CanonicalHeaderKey(strings.TrimSpace(key)); key != "" {
+				if req.Trailer == nil {
+					req.Trailer = make(http.Header)
+				}
+				req.Trailer[key] = nil
+			}
+		}
+	}
+	body.trailer = req.Trailer
+	return req.WithContext(ctx), body, nil
+}
+
+// readHeaders reads the field section of the first HEADERS frame of a request stream, returning nil if the stream
+// ends before it
+func (sc *serverConn) readHeaders(fr *frameReader) ([]headerField, error) {
+	for {
+		typ, length, err := fr.next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case typ == frameHeaders:
+			return sc.readFieldSection(fr, length)
+		case typ == frameData:
+			return nil, &connectionError{code: errorFrameUnexpected, reason: "DATA frame before the request headers"}
+		case typ == frameSettings || typ == frameGoAway || typ == frameMaxPushID || typ == frameCancelPush || typ == framePushPromise || isReservedHTTP2Frame(typ):
+			return nil, &connectionError{code: errorFrameUnexpected, reason: fmt.Sprintf("frame 0x%x on a request stream", typ)}
+		default:
+			if err := fr.skip(length); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readFieldSection reads and decodes the payload of a HEADERS frame
+func (sc *serverConn) readFieldSection(fr *frameReader, length uint64) ([]headerField, error) {
+	payload, err := fr.payload(length, sc.maxHeaderBytes)
+	if err == errFrameTooLarge {
+		return nil, &streamError{code: errorExcessiveLoad, reason: "request headers too large"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := decodeFieldSection(payload, sc.maxHeaderBytes)
+	switch err {
+	case nil:
+		return fields, nil
+	case errQPACKTooLarge:
+		return nil, &streamError{code: errorExcessiveLoad, reason: "request headers too large"}
+	default:
+		return nil, &connectionError{code: errorQPACKDecompression, reason: err.Error()}
+	}
+}
+
+// requestBody reads the payload of the DATA frames of a request stream, and its trailers
+type requestBody struct {
+	sc            *serverConn
+	stream        *quic.Stream
+	fr            *frameReader
+	remaining     uint64 // the remaining payload of the current DATA frame
+	contentLength int64
+	read          int64
+	trailer       http.Header
+	done          bool
+	err           error
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	for b.remaining == 0 {
+		if err := b.nextFrame(); err != nil {
+			b.fail(err)
+			return 0, b.err
+		}
+	}
+
+	if uint64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.fr.r.Read(p)
+	b.remaining -= uint64(n)
+	b.read += int64(n)
+	if b.contentLength >= 0 && b.read > b.contentLength {
+		b.fail(malformed("request body larger than its content-length"))
+		return n, b.err
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		b.fail(err)
+		return n, b.err
+	}
+	return n, nil
+}
+
+// nextFrame reads the frames up to the next DATA frame, returning io.EOF at the end of the stream
+func (b *requestBody) nextFrame() error {
+	typ, length, err := b.fr.next()
+	if err == io.EOF {
+		if b.contentLength >= 0 && b.read != b.contentLength {
+			return malformed("request body smaller than its content-length")
+		}
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case typ == frameData:
+		b.remaining = length
+	case typ == frameHeaders:
+		return b.readTrailers(length)
+	case typ == frameSettings || typ == frameGoAway || typ == frameMaxPushID || typ == frameCancelPush || typ == framePushPromise || isReservedHTTP2Frame(typ):
+		return &connectionError{code: errorFrameUnexpected, reason: fmt.Sprintf("frame 0x%x on a request stream", typ)}
+	default:
+		return b.fr.skip(length)
+	}
+	return nil
+}
+
+// readTrailers reads the trailer section, which must end the request stream
+func (b *requestBody) readTrailers(length uint64) error {
+	fields, err := b.sc.readFieldSection(b.fr, length)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if strings.HasPrefix(field.name, ":") || !httplex.ValidHeaderFieldName(field.name) || strings.ToLower(field.name) != field.name {
+			return malformed("invalid trailer field %q", field.name)
+		}
+		if b.trailer == nil {
+			continue
+		}
+		key := http.CanonicalHeaderKey(field.name)
+		if _, declared := b.trailer[key]; declared {
+			b.trailer[key] = append(b.trailer[key], field.value)
+		}
+	}
+
+	if _, _, err := b.fr.next(); err != io.EOF {
+		if err == nil {
+			return &connectionError{code: errorFrameUnexpected, reason: "frame after the request trailers"}
+		}
+		return err
+	}
+	if b.contentLength >= 0 && b.read != b.contentLength {
+		return malformed("request body smaller than its content-length")
+	}
+	return io.EOF
+}
+
+// fail records the error returned by the next reads, aborting the stream on the protocol errors.
+// The stream has then nothing left to read.
+func (b *requestBody) fail(err error) {
+	b.err = err
+	switch e := err.(type) {
+	case *connectionError, *streamError:
+		b.sc.abortRequest(b.stream, e)
+	}
+	b.done = true
+}
+
+func (b *requestBody) Close() error {
+	return nil
+}
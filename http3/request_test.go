@@ -0,0 +1,144 @@
+package http3
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestHeaders(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		fields        []headerField
+		expected      *requestHeaders
+		expectedError bool
+	}{
+		{
+			desc:   "GET request",
+			fields: []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/bar?baz"}, {"accept", "*/*"}},
+			expected: &requestHeaders{
+				method:    "GET",
+				scheme:    "https",
+				authority: "foo.com",
+				path:      "/bar?baz",
+				header:    http.Header{"Accept": {"*/*"}},
+			},
+		},
+		{
+			desc:   "cookies",
+			fields: []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {"cookie", "a=1"}, {"cookie", "b=2"}},
+			expected: &requestHeaders{
+				method:    "GET",
+				scheme:    "https",
+				authority: "foo.com",
+				path:      "/",
+				header:    http.Header{"Cookie": {"a=1; b=2"}},
+			},
+		},
+		{
+			desc:   "host header",
+			fields: []headerField{{":method", "GET"}, {":scheme", "https"}, {":path", "/"}, {"host", "foo.com"}},
+			expected: &requestHeaders{
+				method: "GET",
+				scheme: "https",
+				path:   "/",
+				header: http.Header{"Host": {"foo.com"}},
+			},
+		},
+		{
+			desc:   "CONNECT request",
+			fields: []headerField{{":method", "CONNECT"}, {":authority", "foo.com:443"}},
+			expected: &requestHeaders{
+				method:    "CONNECT",
+				authority: "foo.com:443",
+				header:    http.Header{},
+			},
+		},
+		{
+			desc:   "te trailers",
+			fields: []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {"te", "trailers"}},
+			expected: &requestHeaders{
+				method:    "GET",
+				scheme:    "https",
+				authority: "foo.com",
+				path:      "/",
+				header:    http.Header{"Te": {"trailers"}},
+			},
+		},
+		{
+			desc:          "missing method",
+			fields:        []headerField{{":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}},
+			expectedError: true,
+		},
+		{
+			desc:          "missing path",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}},
+			expectedError: true,
+		},
+		{
+			desc:          "missing authority",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":path", "/"}},
+			expectedError: true,
+		},
+		{
+			desc:          "CONNECT request with a path",
+			fields:        []headerField{{":method", "CONNECT"}, {":authority", "foo.com:443"}, {":path", "/"}},
+			expectedError: true,
+		},
+		{
+			desc:          "duplicate pseudo-header field",
+			fields:        []headerField{{":method", "GET"}, {":method", "POST"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}},
+			expectedError: true,
+		},
+		{
+			desc:          "unknown pseudo-header field",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {":protocol", "websocket"}},
+			expectedError: true,
+		},
+		{
+			desc:          "pseudo-header field after the header fields",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {"accept", "*/*"}, {":authority", "foo.com"}, {":path", "/"}},
+			expectedError: true,
+		},
+		{
+			desc:          "uppercase header field name",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {"Accept", "*/*"}},
+			expectedError: true,
+		},
+		{
+			desc:          "invalid header field value",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {"accept", "a\r\nb"}},
+			expectedError: true,
+		},
+		{
+			desc:          "connection-specific header field",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {"connection", "keep-alive"}},
+			expectedError: true,
+		},
+		{
+			desc:          "te header field other than trailers",
+			fields:        []headerField{{":method", "GET"}, {":scheme", "https"}, {":authority", "foo.com"}, {":path", "/"}, {"te", "gzip"}},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := parseRequestHeaders(test.fields)
+			if test.expectedError {
+				require.Error(t, err)
+				streamErr, ok := err.(*streamError)
+				require.True(t, ok)
+				assert.Equal(t, uint64(errorMessage), streamErr.code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, h)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package http3
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/quic"
+	"golang.org/x/net/lex/httplex"
+)
+
+// bufferSize is the size of the buffer of the responses, whose content length is set when the handler
+// writes less data
+const bufferSize = 4 << 10
+
+// responseWriter is the http.ResponseWriter of the requests, writing the response as HEADERS and DATA frames
+type responseWriter struct {
+	stream *quic.Stream
+	req    *http.Request
+	header http.Header
+	buffer *bufio.Writer
+
+	status        int
+	wroteHeader   bool
+	sentHeader    bool
+	handlerDone   bool
+	contentLength int64 // the content length of the response, -1 if unknown
+	written       int64
+	trailers      []string // the trailers declared by the Trailer header
+	closeNotify   chan bool
+}
+
+func newResponseWriter(s *quic.Stream, req *http.Request) *responseWriter {
+	w := &responseWriter{stream: s, req: req, header: make(http.Header), contentLength: -1}
+	w.buffer = bufio.NewWriterSize(chunkWriter{w}, bufferSize)
+	return w
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		log.Debugf("http3: superfluous response.WriteHeader call from %s", w.req.RemoteAddr)
+		return
+	}
+	if code < 100 || code > 999 {
+		panic("http3: invalid WriteHeader code " + strconv.Itoa(code))
+	}
+	w.wroteHeader = true
+	w.status = code
+	if length := w.header.Get("Content-Length"); length != "" {
+		if n, err := strconv.ParseInt(length, 10, 64); err == nil && n >= 0 {
+			w.contentLength = n
+		} else {
+			log.Debugf("http3: invalid Content-Length %q in the response", length)
+			w.header.Del("Content-Length")
+		}
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !bodyAllowed(w.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	w.written += int64(len(p))
+	if w.contentLength >= 0 && w.written > w.contentLength {
+		return 0, http.ErrContentLength
+	}
+	if w.req.Method == http.MethodHead {
+		return len(p), nil
+	}
+	return w.buffer.Write(p)
+}
+
+// Flush sends the response headers and the data buffered
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := w.buffer.Flush(); err != nil {
+		return
+	}
+	if !w.sentHeader {
+		w.writeHeaders(nil)
+	}
+}
+
+// CloseNotify returns a channel receiving a value once the request is aborted, or the connection closed
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if w.closeNotify == nil {
+		w.closeNotify = make(chan bool, 1)
+		go func() {
+			<-w.stream.Context().Done()
+			w.closeNotify <- true
+		}()
+	}
+	return w.closeNotify
+}
+
+// finish writes the end of the response once the handler returns
+func (w *responseWriter) finish() error {
+	w.handlerDone = true
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := w.buffer.Flush(); err != nil {
+		return err
+	}
+	if !w.sentHeader {
+		if err := w.writeHeaders(nil); err != nil {
+			return err
+		}
+	}
+	if w.contentLength >= 0 && w.written < w.contentLength && w.req.Method != http.MethodHead {
+		return http.ErrContentLength
+	}
+
+	var trailers []headerField
+	for _, key := range w.trailers {
+		for _, value := range w.header[key] {
+			trailers = appendField(trailers, key, value)
+		}
+	}
+	for key, values := range w.header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			for _, value := range values {
+				trailers = appendField(trailers, strings.TrimPrefix(key, http.TrailerPrefix), value)
+			}
+		}
+	}
+	if len(trailers) > 0 {
+		if _, err := w.stream.Write(appendHeadersFrame(nil, trailers)); err != nil {
+			return err
+		}
+	}
+	return w.stream.Close()
+}
+
+// writeHeaders sends the HEADERS frame of the response, before the first data written if any
+func (w *responseWriter) writeHeaders(data []byte) error {
+	w.sentHeader = true
+
+	if w.contentLength < 0 && w.handlerDone && bodyAllowed(w.status) && w.req.Method != http.MethodHead {
+		w.contentLength = int64(len(data))
+		w.header.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	if _, ok := w.header["Content-Type"]; !ok && len(data) > 0 && bodyAllowed(w.status) {
+		w.header.Set("Content-Type", http.DetectContentType(data))
+	}
+	if _, ok := w.header["Date"]; !ok {
+		w.header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	for _, value := range w.header["Trailer"] {
+		for _, key := range strings.Split(value, ",") {
+			if key = http.CanonicalHeaderKey(strings.TrimSpace(key)); key != "" {
+				w.trailers = append(w.trailers, key)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(w.header))
+	for key := range w.header {
+		if !strings.HasPrefix(key, http.TrailerPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fields := []headerField{{":status", strconv.Itoa(w.status)}}
+	for _, key := range keys {
+		for _, value := range w.header[key] {
+			fields = appendField(fields, key, value)
+		}
+	}
+	_, err := w.stream.Write(appendHeadersFrame(nil, fields))
+	return err
+}
+
+// chunkWriter writes the data buffered by the response writer as DATA frames
+type chunkWriter struct {
+	w *responseWriter
+}
+
+func (c chunkWriter) Write(p []byte) (int, error) {
+	if !c.w.sentHeader {
+		if err := c.w.writeHeaders(p); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := c.w.stream.Write(appendFrameHeader(nil, frameData, len(p))); err != nil {
+		return 0, err
+	}
+	return c.w.stream.Write(p)
+}
+
+// appendField appends a header field to the field section, the connection-specific and invalid ones being dropped
+func appendField(fields []headerField, key, value string) []headerField {
+	name := strings.ToLower(key)
+	if connectionHeaders[name] || !httplex.ValidHeaderFieldName(name) || !httplex.ValidHeaderFieldValue(value) {
+		return fields
+	}
+	return append(fields, headerField{name, value})
+}
+
+func appendHeadersFrame(b []byte, fields []headerField) []byte {
+	section := encodeFieldSection(nil, fields)
+	return append(appendFrameHeader(b, frameHeaders, len(section)), section...)
+}
+
+// bodyAllowed returns whether a response with the status can have a body
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,344 @@
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/quic"
+)
+
+// NextProto is the ALPN protocol ID of HTTP/3
+const NextProto = "h3"
+
+// maxControlFrameSize is the maximum size of the payload of the frames of the control stream
+const maxControlFrameSize = 16 << 10
+
+// Server serves HTTP/3 on UDP sockets (RFC 9114). Its QPACK encoder and decoder have no dynamic table,
+// and it neither pushes responses nor accepts 0-RTT requests.
+type Server struct {
+	Handler        http.Handler
+	TLSConfig      *tls.Config   // the certificates of the server, its NextProtos being replaced by h3
+	IdleTimeout    time.Duration // the maximum time a connection stays idle, defaults to 30s
+	MaxHeaderBytes int           // the maximum size of the request headers, defaults to http.DefaultMaxHeaderBytes
+
+	mu           sync.Mutex
+	listeners    map[*quic.Listener]struct{}
+	conns        map[*serverConn]struct{}
+	shuttingDown bool
+}
+
+// Serve accepts the QUIC connections on the socket and serves their requests, returning http.ErrServerClosed
+// once the server is closed or shut down
+func (s *Server) Serve(conn net.PacketConn) error {
+	if s.TLSConfig == nil {
+		return fmt.Errorf("http3: no TLS configuration")
+	}
+	tlsConfig := s.TLSConfig.Clone()
+	tlsConfig.NextProtos = []string{NextProto}
+
+	l, err := quic.Listen(conn, tlsConfig, &quic.Config{MaxIdleTimeout: s.IdleTimeout})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		l.Close()
+		return http.ErrServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[*quic.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		qconn, err := l.Accept()
+		if err != nil {
+			if err == quic.ErrListenerClosed {
+				return http.ErrServerClosed
+			}
+			return err
+		}
+
+		sc := &serverConn{server: s, conn: qconn, maxHeaderBytes: s.maxHeaderBytes()}
+		s.mu.Lock()
+		if s.shuttingDown {
+			s.mu.Unlock()
+			go qconn.CloseWithError(errorNoError, "")
+			continue
+		}
+		if s.conns == nil {
+			s.conns = make(map[*serverConn]struct{})
+		}
+		s.conns[sc] = struct{}{}
+		s.mu.Unlock()
+		go sc.serve()
+	}
+}
+
+// Close closes the sockets and the connections of the server at once
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	listeners := s.listeners
+	s.listeners = nil
+	s.mu.Unlock()
+
+	var err error
+	for l := range listeners {
+		if closeErr := l.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Shutdown sends a GOAWAY frame on the connections of the server, and waits for their requests to be
+// served before closing it. The connections are closed at once if the context expires first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	conns := make([]*serverConn, 0, len(s.conns))
+	for sc := range s.conns {
+		conns = append(conns, sc)
+	}
+	s.mu.Unlock()
+
+	for _, sc := range conns {
+		sc.goAway()
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		idle := len(s.conns) == 0
+		s.mu.Unlock()
+		if idle {
+			return s.Close()
+		}
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) maxHeaderBytes() int {
+	if s.MaxHeaderBytes > 0 {
+		return s.MaxHeaderBytes
+	}
+	return http.DefaultMaxHeaderBytes
+}
+
+func (s *Server) removeConn(sc *serverConn) {
+	s.mu.Lock()
+	delete(s.conns, sc)
+	s.mu.Unlock()
+}
+
+// serverConn is an HTTP/3 connection of the server
+type serverConn struct {
+	server         *Server
+	conn           *quic.Conn
+	maxHeaderBytes int
+
+	mu           sync.Mutex
+	control      *quic.Stream
+	requests     int    // the number of requests being served
+	nextStreamID uint64 // the ID of the first request stream not accepted yet
+	goingAway    bool
+	peerStreams  map[uint64]bool // the unidirectional stream types opened by the client
+	closeOnce    sync.Once
+}
+
+func (sc *serverConn) serve() {
+	defer sc.server.removeConn(sc)
+
+	control, err := sc.conn.OpenUniStream()
+	if err != nil {
+		sc.close(&connectionError{code: errorInternal, reason: err.Error()})
+		return
+	}
+	preface := quic.AppendVarint(nil, streamControl)
+	if _, err := control.Write(appendSettingsFrame(preface, uint64(sc.maxHeaderBytes))); err != nil {
+		return
+	}
+	sc.mu.Lock()
+	sc.control = control
+	sc.mu.Unlock()
+
+	go sc.acceptUniStreams()
+
+	for {
+		s, err := sc.conn.AcceptStream()
+		if err != nil {
+			return
+		}
+
+		sc.mu.Lock()
+		if sc.goingAway {
+			sc.mu.Unlock()
+			s.CancelRead(errorRequestRejected)
+			s.CancelWrite(errorRequestRejected)
+			continue
+		}
+		sc.requests++
+		sc.nextStreamID = s.ID() + 4
+		sc.mu.Unlock()
+
+		go func() {
+			sc.serveRequest(s)
+			// the response is acknowledged before the request is done, for it not to be lost when going away
+			s.WaitSent()
+			sc.requestDone()
+		}()
+	}
+}
+
+// goAway sends a GOAWAY frame, rejecting the requests not accepted yet, and closes the connection
+// once the requests accepted are served
+func (sc *serverConn) goAway() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.goingAway {
+		return
+	}
+	sc.goingAway = true
+	if sc.control != nil {
+		payload := quic.AppendVarint(nil, sc.nextStreamID)
+		frame := append(appendFrameHeader(nil, frameGoAway, len(payload)), payload...)
+		if _, err := sc.control.Write(frame); err != nil {
+			log.Debugf("Error sending HTTP/3 GOAWAY to %s: %v", sc.conn.RemoteAddr(), err)
+		}
+	}
+	if sc.requests == 0 {
+		go sc.close(nil)
+	}
+}
+
+func (sc *serverConn) requestDone() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.requests--
+	if sc.goingAway && sc.requests == 0 {
+		go sc.close(nil)
+	}
+}
+
+// close closes the connection with the error, or without error if nil
+func (sc *serverConn) close(err *connectionError) {
+	sc.closeOnce.Do(func() {
+		if err == nil {
+			sc.conn.CloseWithError(errorNoError, "")
+			return
+		}
+		log.Debugf("Closing HTTP/3 connection from %s: %v", sc.conn.RemoteAddr(), err)
+		sc.conn.CloseWithError(err.code, err.reason)
+	})
+}
+
+func (sc *serverConn) acceptUniStreams() {
+	for {
+		s, err := sc.conn.AcceptUniStream()
+		if err != nil {
+			return
+		}
+		go sc.handleUniStream(s)
+	}
+}
+
+func (sc *serverConn) handleUniStream(s *quic.Stream) {
+	fr := newFrameReader(s)
+	typ, err := quic.ReadVarint(fr.r)
+	if err != nil {
+		return
+	}
+
+	switch typ {
+	case streamControl, streamQPACKEncoder, streamQPACKDecoder:
+		sc.mu.Lock()
+		if sc.peerStreams == nil {
+			sc.peerStreams = make(map[uint64]bool)
+		}
+		duplicate := sc.peerStreams[typ]
+		sc.peerStreams[typ] = true
+		sc.mu.Unlock()
+		if duplicate {
+			sc.close(&connectionError{code: errorStreamCreation, reason: fmt.Sprintf("duplicate stream of type 0x%x", typ)})
+			return
+		}
+	case streamPush:
+		sc.close(&connectionError{code: errorStreamCreation, reason: "push stream opened by the client"})
+		return
+	default:
+		s.CancelRead(errorStreamCreation)
+		return
+	}
+
+	if typ == streamControl {
+		err = sc.readControlStream(fr)
+	} else {
+		// the instructions of the client are ignored, the dynamic tables being disabled
+		_, err = io.Copy(ioutil.Discard, fr.r)
+	}
+	switch e := err.(type) {
+	case nil:
+		sc.close(&connectionError{code: errorClosedCriticalStream, reason: fmt.Sprintf("stream of type 0x%x closed", typ)})
+	case *connectionError:
+		sc.close(e)
+	}
+}
+
+// readControlStream reads the frames of the control stream of the client, returning nil if the stream ends
+func (sc *serverConn) readControlStream(fr *frameReader) error {
+	settings := false
+	for {
+		typ, length, err := fr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !settings && typ != frameSettings {
+			return &connectionError{code: errorMissingSettings, reason: "first frame of the control stream is not SETTINGS"}
+		}
+		switch {
+		case typ == frameSettings:
+			if settings {
+				return &connectionError{code: errorFrameUnexpected, reason: "duplicate SETTINGS frame"}
+			}
+			settings = true
+			payload, err := fr.payload(length, maxControlFrameSize)
+			if err == errFrameTooLarge {
+				return &connectionError{code: errorExcessiveLoad, reason: "SETTINGS frame too large"}
+			}
+			if err != nil {
+				return err
+			}
+			if err := checkSettings(payload); err != nil {
+				return err
+			}
+		case typ == frameData || typ == frameHeaders || typ == framePushPromise || isReservedHTTP2Frame(typ):
+			return &connectionError{code: errorFrameUnexpected, reason: fmt.Sprintf("frame 0x%x on the control stream", typ)}
+		default:
+			// GOAWAY, MAX_PUSH_ID and CANCEL_PUSH are ignored, the server neither opening requests nor pushing responses
+			if err := fr.skip(length); err != nil {
+				return err
+			}
+		}
+	}
+}
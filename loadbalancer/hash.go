@@ -0,0 +1,172 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+// DefaultHashExtractorFunc is the request attribute hashed when none is configured
+const DefaultHashExtractorFunc = "client.ip"
+
+// virtualNodes is the number of points a server of weight 1 gets on the ring
+const virtualNodes = 100
+
+const cookieExtractorPrefix = "request.cookie."
+
+type ringNode struct {
+	hash   uint32
+	server *url.URL
+}
+
+// HashBalancer is a consistent-hash load balancer: the requests sharing the same key
+// (client IP, header or cookie) are forwarded to the same server, and adding or removing
+// a server only moves the keys of this server.
+type HashBalancer struct {
+	*roundrobin.RoundRobin
+	extractor utils.SourceExtractor
+	mutex     sync.RWMutex
+	ring      []ringNode
+}
+
+// NewHashBalancer creates a HashBalancer over the servers of the given round robin, hashing the
+// request attribute given by extractorFunc: client.ip, request.host, request.header.<name> or request.cookie.<name>.
+func NewHashBalancer(rr *roundrobin.RoundRobin, extractorFunc string) (*HashBalancer, error) {
+	extractor, err := newHashExtractor(extractorFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	hb := &HashBalancer{
+		RoundRobin: rr,
+		extractor:  extractor,
+	}
+	hb.buildRing()
+	return hb, nil
+}
+
+func newHashExtractor(extractorFunc string) (utils.SourceExtractor, error) {
+	if len(extractorFunc) == 0 {
+		extractorFunc = DefaultHashExtractorFunc
+	}
+
+	if strings.HasPrefix(extractorFunc, cookieExtractorPrefix) {
+		name := strings.TrimPrefix(extractorFunc, cookieExtractorPrefix)
+		if len(name) == 0 {
+			return nil, fmt.Errorf("wrong cookie: %s", extractorFunc)
+		}
+		return utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+			cookie, err := req.Cookie(name)
+			if err != nil {
+				return "", 1, nil
+			}
+			return cookie.Value, 1, nil
+		}), nil
+	}
+
+	if extractorFunc == DefaultHashExtractorFunc {
+		return utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+			return clientIP(req), 1, nil
+		}), nil
+	}
+
+	return utils.NewExtractor(extractorFunc)
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// UpsertServer adds or updates a server and rebuilds the ring
+func (hb *HashBalancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	if err := hb.RoundRobin.UpsertServer(u, options...); err != nil {
+		return err
+	}
+	hb.buildRing()
+	return nil
+}
+
+// RemoveServer removes a server and rebuilds the ring
+func (hb *HashBalancer) RemoveServer(u *url.URL) error {
+	if err := hb.RoundRobin.RemoveServer(u); err != nil {
+		return err
+	}
+	hb.buildRing()
+	return nil
+}
+
+func (hb *HashBalancer) buildRing() {
+	var ring []ringNode
+	for _, server := range hb.RoundRobin.Servers() {
+		weight, _ := hb.RoundRobin.ServerWeight(server)
+		for i := 0; i < weight*virtualNodes; i++ {
+			ring = append(ring, ringNode{hash: hashKey(server.String() + "-" + strconv.Itoa(i)), server: server})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	hb.mutex.Lock()
+	hb.ring = ring
+	hb.mutex.Unlock()
+}
+
+// ServerForKey returns the server owning the key on the ring
+func (hb *HashBalancer) ServerForKey(key string) (*url.URL, error) {
+	hb.mutex.RLock()
+	defer hb.mutex.RUnlock()
+
+	if len(hb.ring) == 0 {
+		return nil, fmt.Errorf("no servers in the pool")
+	}
+
+	hash := hashKey(key)
+	i := sort.Search(len(hb.ring), func(i int) bool {
+		return hb.ring[i].hash >= hash
+	})
+	if i == len(hb.ring) {
+		i = 0
+	}
+	return utils.CopyURL(hb.ring[i].server), nil
+}
+
+func (hb *HashBalancer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	key, _, err := hb.extractor.Extract(req)
+	if err != nil {
+		utils.DefaultHandler.ServeHTTP(rw, req, err)
+		return
+	}
+	// requests without the attribute are spread according to the client IP
+	if len(key) == 0 {
+		key = clientIP(req)
+	}
+
+	server, err := hb.ServerForKey(key)
+	if err != nil {
+		utils.DefaultHandler.ServeHTTP(rw, req, err)
+		return
+	}
+
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *req
+	newReq.URL = server
+	hb.RoundRobin.Next().ServeHTTP(rw, &newReq)
+}
+
+func hashKey(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
@@ -0,0 +1,160 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func hostHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.Host))
+	})
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u
+}
+
+func newTestHashBalancer(t *testing.T, extractorFunc string, servers ...string) *HashBalancer {
+	rr, err := roundrobin.New(hostHandler())
+	require.NoError(t, err)
+	hb, err := NewHashBalancer(rr, extractorFunc)
+	require.NoError(t, err)
+	for _, server := range servers {
+		require.NoError(t, hb.UpsertServer(mustParseURL(t, server), roundrobin.Weight(1)))
+	}
+	return hb
+}
+
+func TestNewHashBalancer(t *testing.T) {
+	testCases := []struct {
+		extractorFunc string
+		expectedError bool
+	}{
+		{extractorFunc: ""},
+		{extractorFunc: "client.ip"},
+		{extractorFunc: "request.host"},
+		{extractorFunc: "request.header.X-User"},
+		{extractorFunc: "request.cookie.session"},
+		{extractorFunc: "request.cookie.", expectedError: true},
+		{extractorFunc: "request.header.", expectedError: true},
+		{extractorFunc: "foo", expectedError: true},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.extractorFunc, func(t *testing.T) {
+			t.Parallel()
+
+			rr, err := roundrobin.New(hostHandler())
+			require.NoError(t, err)
+			_, err = NewHashBalancer(rr, test.extractorFunc)
+			if test.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHashBalancerServeHTTP(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		extractorFunc string
+		request       func(key string) *http.Request
+	}{
+		{
+			desc: "client IP",
+			request: func(key string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = key + ":1234"
+				return req
+			},
+		},
+		{
+			desc:          "header",
+			extractorFunc: "request.header.X-User",
+			request: func(key string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-User", key)
+				return req
+			},
+		},
+		{
+			desc:          "cookie",
+			extractorFunc: "request.cookie.session",
+			request: func(key string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(&http.Cookie{Name: "session", Value: key})
+				return req
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			hb := newTestHashBalancer(t, test.extractorFunc, "http://10.0.0.1", "http://10.0.0.2", "http://10.0.0.3")
+
+			serve := func(key string) string {
+				recorder := httptest.NewRecorder()
+				hb.ServeHTTP(recorder, test.request(key))
+				return recorder.Body.String()
+			}
+
+			used := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				key := "10.1.0." + strconv.Itoa(i)
+				server := serve(key)
+				used[server] = true
+				assert.Equal(t, server, serve(key), "key %s", key)
+			}
+			assert.Len(t, used, 3)
+		})
+	}
+}
+
+func TestHashBalancerRemoveServer(t *testing.T) {
+	hb := newTestHashBalancer(t, "request.header.X-User", "http://10.0.0.1", "http://10.0.0.2", "http://10.0.0.3")
+
+	before := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		key := strconv.Itoa(i)
+		server, err := hb.ServerForKey(key)
+		require.NoError(t, err)
+		before[key] = server.Host
+	}
+
+	require.NoError(t, hb.RemoveServer(mustParseURL(t, "http://10.0.0.2")))
+
+	for key, host := range before {
+		server, err := hb.ServerForKey(key)
+		require.NoError(t, err)
+		if host == "10.0.0.2" {
+			assert.NotEqual(t, host, server.Host, "key %s", key)
+		} else {
+			assert.Equal(t, host, server.Host, "key %s", key)
+		}
+	}
+}
+
+func TestHashBalancerNoServer(t *testing.T) {
+	hb := newTestHashBalancer(t, "")
+
+	recorder := httptest.NewRecorder()
+	hb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
@@ -0,0 +1,116 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+type server struct {
+	url      *url.URL
+	weight   int
+	inflight int
+}
+
+// lessLoaded tells whether a has fewer in-flight requests than b relatively to their weights
+func lessLoaded(a, b *server) bool {
+	return a.inflight*b.weight < b.inflight*a.weight
+}
+
+// inflightBalancer counts the in-flight requests of each server of a round robin,
+// and forwards each request to the server chosen by pick among the servers with a positive weight.
+type inflightBalancer struct {
+	*roundrobin.RoundRobin
+	mutex   sync.Mutex
+	servers []*server
+	// pick is called with the mutex held and at least one server
+	pick func(servers []*server) *server
+}
+
+func newInflightBalancer(rr *roundrobin.RoundRobin, pick func(servers []*server) *server) *inflightBalancer {
+	lb := &inflightBalancer{RoundRobin: rr, pick: pick}
+	lb.refreshServers()
+	return lb
+}
+
+// UpsertServer adds or updates a server
+func (lb *inflightBalancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	if err := lb.RoundRobin.UpsertServer(u, options...); err != nil {
+		return err
+	}
+	lb.refreshServers()
+	return nil
+}
+
+// RemoveServer removes a server
+func (lb *inflightBalancer) RemoveServer(u *url.URL) error {
+	if err := lb.RoundRobin.RemoveServer(u); err != nil {
+		return err
+	}
+	lb.refreshServers()
+	return nil
+}
+
+// refreshServers copies the servers of the round robin, keeping the in-flight requests of the remaining ones
+func (lb *inflightBalancer) refreshServers() {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	existing := make(map[string]*server)
+	for _, s := range lb.servers {
+		existing[s.url.String()] = s
+	}
+
+	var servers []*server
+	for _, u := range lb.RoundRobin.Servers() {
+		weight, _ := lb.RoundRobin.ServerWeight(u)
+		if weight <= 0 {
+			continue
+		}
+		s, ok := existing[u.String()]
+		if !ok {
+			s = &server{url: u}
+		}
+		s.weight = weight
+		servers = append(servers, s)
+	}
+	lb.servers = servers
+}
+
+// acquire selects a server and counts a new in-flight request on it
+func (lb *inflightBalancer) acquire() (*server, error) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	if len(lb.servers) == 0 {
+		return nil, fmt.Errorf("no servers in the pool")
+	}
+
+	selected := lb.pick(lb.servers)
+	selected.inflight++
+	return selected, nil
+}
+
+func (lb *inflightBalancer) release(s *server) {
+	lb.mutex.Lock()
+	s.inflight--
+	lb.mutex.Unlock()
+}
+
+func (lb *inflightBalancer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s, err := lb.acquire()
+	if err != nil {
+		utils.DefaultHandler.ServeHTTP(rw, req, err)
+		return
+	}
+	defer lb.release(s)
+
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *req
+	newReq.URL = utils.CopyURL(s.url)
+	lb.RoundRobin.Next().ServeHTTP(rw, &newReq)
+}
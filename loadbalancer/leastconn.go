@@ -0,0 +1,31 @@
+package loadbalancer
+
+import (
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// LeastConnBalancer forwards each request to the server having the fewest in-flight requests
+// relatively to its weight. Ties are broken in a round robin fashion.
+type LeastConnBalancer struct {
+	*inflightBalancer
+	index int
+}
+
+// NewLeastConnBalancer creates a LeastConnBalancer over the servers of the given round robin
+func NewLeastConnBalancer(rr *roundrobin.RoundRobin) *LeastConnBalancer {
+	lb := &LeastConnBalancer{}
+	lb.inflightBalancer = newInflightBalancer(rr, lb.pick)
+	return lb
+}
+
+func (lb *LeastConnBalancer) pick(servers []*server) *server {
+	lb.index = (lb.index + 1) % len(servers)
+	var selected *server
+	for i := range servers {
+		s := servers[(lb.index+i)%len(servers)]
+		if selected == nil || lessLoaded(s, selected) {
+			selected = s
+		}
+	}
+	return selected
+}
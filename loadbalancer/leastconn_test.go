@@ -0,0 +1,125 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestLeastConnBalancerServeHTTP(t *testing.T) {
+	// the requests to the slow server are blocked until release is closed
+	release := make(chan struct{})
+	started := make(chan struct{}, 100)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Host == "slow" {
+			started <- struct{}{}
+			<-release
+		}
+		rw.Write([]byte(req.URL.Host))
+	})
+
+	rr, err := roundrobin.New(next)
+	require.NoError(t, err)
+	lb := NewLeastConnBalancer(rr)
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://slow"), roundrobin.Weight(1)))
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://fast"), roundrobin.Weight(1)))
+
+	// the first request goes to one server or the other, tie broken by round robin:
+	// send requests until one is stuck on the slow server
+	var done sync.WaitGroup
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		for {
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			if recorder.Body.String() == "slow" {
+				return
+			}
+		}
+	}()
+	<-started
+
+	// while the slow server has an in-flight request, the fast one gets all the requests
+	for i := 0; i < 10; i++ {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "fast", recorder.Body.String())
+	}
+
+	close(release)
+	done.Wait()
+
+	// both servers are idle again
+	used := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		used[recorder.Body.String()] = true
+	}
+	assert.Len(t, used, 2)
+}
+
+func TestLeastConnBalancerWeight(t *testing.T) {
+	rr, err := roundrobin.New(hostHandler())
+	require.NoError(t, err)
+	lb := NewLeastConnBalancer(rr)
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://heavy"), roundrobin.Weight(3)))
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://light"), roundrobin.Weight(1)))
+
+	// requests are kept in flight: the heavy server takes 3 of them for each one of the light server
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		s, err := lb.acquire()
+		require.NoError(t, err)
+		counts[s.url.Host]++
+	}
+	assert.Equal(t, map[string]int{"heavy": 6, "light": 2}, counts)
+}
+
+func TestLeastConnBalancerRemoveServer(t *testing.T) {
+	rr, err := roundrobin.New(hostHandler())
+	require.NoError(t, err)
+	lb := NewLeastConnBalancer(rr)
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://a"), roundrobin.Weight(1)))
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://b"), roundrobin.Weight(1)))
+
+	inflight, err := lb.acquire()
+	require.NoError(t, err)
+
+	var other string
+	for _, s := range lb.servers {
+		if s != inflight {
+			other = s.url.String()
+		}
+	}
+	require.NoError(t, lb.RemoveServer(mustParseURL(t, other)))
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, other), roundrobin.Weight(1)))
+
+	// the in-flight request is still counted after the pool changed
+	s, err := lb.acquire()
+	require.NoError(t, err)
+	assert.Equal(t, other, s.url.String())
+
+	lb.release(inflight)
+	lb.release(s)
+	for _, s := range lb.servers {
+		assert.Equal(t, 0, s.inflight)
+	}
+}
+
+func TestLeastConnBalancerNoServer(t *testing.T) {
+	rr, err := roundrobin.New(hostHandler())
+	require.NoError(t, err)
+	lb := NewLeastConnBalancer(rr)
+
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
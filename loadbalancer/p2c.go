@@ -0,0 +1,39 @@
+package loadbalancer
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// P2CBalancer implements the power of two choices: for each request, two servers are drawn at random
+// and the one having the fewest in-flight requests relatively to its weight is chosen.
+// It gets close to LeastConnBalancer without scanning all the servers.
+type P2CBalancer struct {
+	*inflightBalancer
+	rand *rand.Rand
+}
+
+// NewP2CBalancer creates a P2CBalancer over the servers of the given round robin
+func NewP2CBalancer(rr *roundrobin.RoundRobin) *P2CBalancer {
+	lb := &P2CBalancer{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	lb.inflightBalancer = newInflightBalancer(rr, lb.pick)
+	return lb
+}
+
+func (lb *P2CBalancer) pick(servers []*server) *server {
+	if len(servers) == 1 {
+		return servers[0]
+	}
+
+	first := lb.rand.Intn(len(servers))
+	second := lb.rand.Intn(len(servers) - 1)
+	if second >= first {
+		second++
+	}
+	if lessLoaded(servers[second], servers[first]) {
+		return servers[second]
+	}
+	return servers[first]
+}
@@ -0,0 +1,54 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestP2CBalancerPick(t *testing.T) {
+	rr, err := roundrobin.New(hostHandler())
+	require.NoError(t, err)
+	lb := NewP2CBalancer(rr)
+	for _, host := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://"+host), roundrobin.Weight(1)))
+	}
+
+	// with requests kept in flight, the loads of the servers stay balanced
+	for i := 0; i < 100; i++ {
+		_, err := lb.acquire()
+		require.NoError(t, err)
+
+		min, max := lb.servers[0].inflight, lb.servers[0].inflight
+		for _, s := range lb.servers {
+			if s.inflight < min {
+				min = s.inflight
+			}
+			if s.inflight > max {
+				max = s.inflight
+			}
+		}
+		assert.True(t, max-min <= len(lb.servers), "loads between %d and %d", min, max)
+	}
+}
+
+func TestP2CBalancerServeHTTP(t *testing.T) {
+	rr, err := roundrobin.New(hostHandler())
+	require.NoError(t, err)
+	lb := NewP2CBalancer(rr)
+
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	require.NoError(t, lb.UpsertServer(mustParseURL(t, "http://single"), roundrobin.Weight(1)))
+
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "single", recorder.Body.String())
+	assert.Equal(t, 0, lb.servers[0].inflight)
+}
@@ -0,0 +1,110 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"sync"
+)
+
+// BackendSplitter spreads the requests of a frontend over several backends according to their weights,
+// in a smooth weighted round robin fashion. The backends with a zero weight receive no request.
+type BackendSplitter struct {
+	mutex    sync.Mutex
+	backends []*weightedBackend
+	header   string
+	cookie   string
+}
+
+type weightedBackend struct {
+	name    string
+	handler http.Handler
+	weight  int
+	current int
+}
+
+// NewBackendSplitter creates a BackendSplitter without backend
+func NewBackendSplitter() *BackendSplitter {
+	return &BackendSplitter{}
+}
+
+// AddBackend adds the handler of a backend with the given weight
+func (s *BackendSplitter) AddBackend(name string, handler http.Handler, weight int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.backends = append(s.backends, &weightedBackend{name: name, handler: handler, weight: weight})
+}
+
+// SetVariants assigns the requests to the backend named by their header or cookie if any, e.g. for A/B testing.
+// With a cookie, the backend chosen by weight is kept in the cookie of the response, so the client sticks to it.
+func (s *BackendSplitter) SetVariants(header string, cookie string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.header = header
+	s.cookie = cookie
+}
+
+func (s *BackendSplitter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	backend, assigned := s.variant(req)
+	if backend == nil {
+		backend = s.next()
+	}
+	if backend != nil && !assigned && len(s.cookie) > 0 {
+		http.SetCookie(rw, &http.Cookie{Name: s.cookie, Value: backend.name, Path: "/", HttpOnly: true})
+	}
+	if backend == nil {
+		http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	backend.handler.ServeHTTP(rw, req)
+}
+
+// variant returns the backend requested by the header, which can have a zero weight, or the backend assigned
+// in the cookie, if it has still a weight, and whether the client is already assigned to it
+func (s *BackendSplitter) variant(req *http.Request) (*weightedBackend, bool) {
+	if len(s.header) > 0 {
+		if backend := s.backend(req.Header.Get(s.header)); backend != nil {
+			return backend, true
+		}
+	}
+	if len(s.cookie) > 0 {
+		if cookie, err := req.Cookie(s.cookie); err == nil {
+			if backend := s.backend(cookie.Value); backend != nil && backend.weight > 0 {
+				return backend, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (s *BackendSplitter) backend(name string) *weightedBackend {
+	if len(name) == 0 {
+		return nil
+	}
+	for _, b := range s.backends {
+		if b.name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func (s *BackendSplitter) next() *weightedBackend {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	total := 0
+	var selected *weightedBackend
+	for _, b := range s.backends {
+		if b.weight <= 0 {
+			continue
+		}
+		b.current += b.weight
+		total += b.weight
+		if selected == nil || b.current > selected.current {
+			selected = b
+		}
+	}
+	if selected != nil {
+		selected.current -= total
+	}
+	return selected
+}
@@ -0,0 +1,146 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendSplitter(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		weights  map[string]int
+		requests int
+		expected map[string]int
+	}{
+		{
+			desc:     "canary",
+			weights:  map[string]int{"stable": 95, "canary": 5},
+			requests: 100,
+			expected: map[string]int{"stable": 95, "canary": 5},
+		},
+		{
+			desc:     "even split",
+			weights:  map[string]int{"blue": 1, "green": 1},
+			requests: 10,
+			expected: map[string]int{"blue": 5, "green": 5},
+		},
+		{
+			desc:     "zero weight",
+			weights:  map[string]int{"blue": 0, "green": 3},
+			requests: 10,
+			expected: map[string]int{"green": 10},
+		},
+		{
+			desc:     "no weight",
+			weights:  map[string]int{"blue": 0},
+			requests: 10,
+			expected: map[string]int{"unavailable": 10},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			splitter := NewBackendSplitter()
+			for name, weight := range test.weights {
+				name := name
+				splitter.AddBackend(name, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					rw.Write([]byte(name))
+				}), weight)
+			}
+
+			counts := map[string]int{}
+			for i := 0; i < test.requests; i++ {
+				recorder := httptest.NewRecorder()
+				splitter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+				if recorder.Code == http.StatusServiceUnavailable {
+					counts["unavailable"]++
+				} else {
+					counts[recorder.Body.String()]++
+				}
+			}
+
+			assert.Equal(t, test.expected, counts)
+		})
+	}
+}
+
+func TestBackendSplitterVariants(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		header           string
+		cookie           string
+		expectedBackend  string
+		expectedAssigned string
+	}{
+		{
+			desc:             "assigned by weight",
+			expectedBackend:  "control",
+			expectedAssigned: "control",
+		},
+		{
+			desc:            "assigned in the cookie",
+			cookie:          "experiment",
+			expectedBackend: "experiment",
+		},
+		{
+			desc:            "requested by the header, even without weight",
+			header:          "preview",
+			cookie:          "experiment",
+			expectedBackend: "preview",
+		},
+		{
+			desc:             "cookie of an unknown backend",
+			cookie:           "unknown",
+			expectedBackend:  "control",
+			expectedAssigned: "control",
+		},
+		{
+			desc:             "cookie of a backend without weight",
+			cookie:           "preview",
+			expectedBackend:  "control",
+			expectedAssigned: "control",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			splitter := NewBackendSplitter()
+			splitter.SetVariants("X-Variant", "variant")
+			for _, name := range []string{"control", "experiment", "preview"} {
+				name := name
+				weight := map[string]int{"control": 2, "experiment": 1}[name]
+				splitter.AddBackend(name, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					rw.Write([]byte(name))
+				}), weight)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(test.header) > 0 {
+				req.Header.Set("X-Variant", test.header)
+			}
+			if len(test.cookie) > 0 {
+				req.AddCookie(&http.Cookie{Name: "variant", Value: test.cookie})
+			}
+			recorder := httptest.NewRecorder()
+			splitter.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedBackend, recorder.Body.String())
+			var assigned string
+			for _, cookie := range (&http.Response{Header: recorder.Header()}).Cookies() {
+				if cookie.Name == "variant" {
+					assigned = cookie.Value
+				}
+			}
+			assert.Equal(t, test.expectedAssigned, assigned)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// +build windows
+
+package log
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+// eventLogMessageFile is the message file of the event sources of Traefik, whose events 1 to 1000 are their messages
+const eventLogMessageFile = `%SystemRoot%\System32\EventCreate.exe`
+
+// eventLogEventID is the event identifier of the entries written to the event log
+const eventLogEventID = 1
+
+const eventLogSourcesKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+
+var (
+	advapi32              = windows.NewLazySystemDLL("advapi32.dll")
+	procRegCreateKeyExW   = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW    = advapi32.NewProc("RegSetValueExW")
+	procRegDeleteKeyW     = advapi32.NewProc("RegDeleteKeyW")
+	eventLogLevelsEntries = map[logrus.Level]uint16{
+		logrus.PanicLevel: windows.EVENTLOG_ERROR_TYPE,
+		logrus.FatalLevel: windows.EVENTLOG_ERROR_TYPE,
+		logrus.ErrorLevel: windows.EVENTLOG_ERROR_TYPE,
+		logrus.WarnLevel:  windows.EVENTLOG_WARNING_TYPE,
+		logrus.InfoLevel:  windows.EVENTLOG_INFORMATION_TYPE,
+		logrus.DebugLevel: windows.EVENTLOG_INFORMATION_TYPE,
+	}
+)
+
+// EventLogHook is a logrus hook writing the log entries to the Windows event log
+type EventLogHook struct {
+	handle    windows.Handle
+	formatter logrus.Formatter
+}
+
+// NewEventLogHook opens the event log of the event source
+func NewEventLogHook(source string) (*EventLogHook, error) {
+	sourceName, err := windows.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.RegisterEventSource(nil, sourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLogHook{
+		handle:    handle,
+		formatter: &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true, DisableSorting: true},
+	}, nil
+}
+
+// Levels returns the levels of the entries written to the event log
+func (h *EventLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire writes the entry to the event log, as an error, a warning or an information according to its level
+func (h *EventLogHook) Fire(entry *logrus.Entry) error {
+	message, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	eventType, ok := eventLogLevelsEntries[entry.Level]
+	if !ok {
+		eventType = windows.EVENTLOG_INFORMATION_TYPE
+	}
+	messagePtr, err := windows.UTF16PtrFromString(strings.TrimSuffix(string(message), "\n"))
+	if err != nil {
+		return err
+	}
+	return windows.ReportEvent(h.handle, eventType, 0, eventLogEventID, 0, 1, 0, &messagePtr, nil)
+}
+
+// Close closes the event log
+func (h *EventLogHook) Close() error {
+	return windows.DeregisterEventSource(h.handle)
+}
+
+// InstallEventLogSource registers the event source in the Application event log
+func InstallEventLogSource(source string) error {
+	var key windows.Handle
+	keyName, err := windows.UTF16PtrFromString(eventLogSourcesKey + source)
+	if err != nil {
+		return err
+	}
+	r, _, _ := procRegCreateKeyExW.Call(uintptr(windows.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(keyName)), 0, 0, 0,
+		uintptr(windows.KEY_WRITE), 0, uintptr(unsafe.Pointer(&key)), 0)
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	defer windows.RegCloseKey(key)
+
+	messageFile, err := windows.UTF16FromString(eventLogMessageFile)
+	if err != nil {
+		return err
+	}
+	if err := setRegistryValue(key, "EventMessageFile", windows.REG_EXPAND_SZ, (*byte)(unsafe.Pointer(&messageFile[0])), len(messageFile)*2); err != nil {
+		return err
+	}
+	typesSupported := uint32(windows.EVENTLOG_ERROR_TYPE | windows.EVENTLOG_WARNING_TYPE | windows.EVENTLOG_INFORMATION_TYPE)
+	if err := setRegistryValue(key, "TypesSupported", windows.REG_DWORD, (*byte)(unsafe.Pointer(&typesSupported)), 4); err != nil {
+		return err
+	}
+	customSource := uint32(1)
+	return setRegistryValue(key, "CustomSource", windows.REG_DWORD, (*byte)(unsafe.Pointer(&customSource)), 4)
+}
+
+// RemoveEventLogSource removes the event source from the Application event log
+func RemoveEventLogSource(source string) error {
+	keyName, err := windows.UTF16PtrFromString(eventLogSourcesKey + source)
+	if err != nil {
+		return err
+	}
+	if r, _, _ := procRegDeleteKeyW.Call(uintptr(windows.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(keyName))); r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func setRegistryValue(key windows.Handle, name string, valueType uint32, data *byte, size int) error {
+	valueName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if r, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(valueName)), 0, uintptr(valueType),
+		uintptr(unsafe.Pointer(data)), uintptr(size)); r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
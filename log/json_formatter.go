@@ -0,0 +1,69 @@
+package log
+
+import (
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// ModuleKey is the key of the field of the JSON logs holding the module logging the entry
+const ModuleKey = "module"
+
+const traefikPackage = "github.com/containous/traefik/"
+
+// JSONFormatter formats the log entries as JSON objects on a single line: the timestamp, with nanoseconds,
+// the level, the message, the fields of the entry, and the module logging it, e.g. provider/docker.
+type JSONFormatter struct {
+	formatter logrus.JSONFormatter
+}
+
+// NewJSONFormatter creates the JSON formatter of the Traefik logs
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{formatter: logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}}
+}
+
+// Format formats the entry, adding the module logging it unless the entry already has a module field
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if _, ok := entry.Data[ModuleKey]; !ok {
+		if module := callerModule(); len(module) > 0 {
+			data := make(logrus.Fields, len(entry.Data)+1)
+			for key, value := range entry.Data {
+				data[key] = value
+			}
+			data[ModuleKey] = module
+			entry = &logrus.Entry{Logger: entry.Logger, Data: data, Time: entry.Time, Level: entry.Level, Message: entry.Message}
+		}
+	}
+	return f.formatter.Format(entry)
+}
+
+// callerModule returns the package of the first caller out of logrus and of this package,
+// relative to Traefik for its own packages and for the vendored ones
+func callerModule() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		module := functionPackage(frame.Function)
+		if len(module) > 0 && !strings.HasSuffix(module, "/logrus") && module != traefikPackage+"log" {
+			module = strings.TrimPrefix(module, traefikPackage)
+			return strings.TrimPrefix(module, "vendor/")
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// functionPackage returns the package of the fully qualified name of a function, e.g. github.com/containous/traefik/server
+// for github.com/containous/traefik/server.(*Server).Start
+func functionPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return function[:slash+1+dot]
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFormatter(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		fields         logrus.Fields
+		expectedModule string
+	}{
+		{
+			// the package of the tests is skipped, as the package of the logging functions
+			desc:           "module of the caller",
+			fields:         logrus.Fields{"provider": "docker"},
+			expectedModule: "testing",
+		},
+		{
+			desc:           "module field kept",
+			fields:         logrus.Fields{"provider": "docker", ModuleKey: "provider/docker"},
+			expectedModule: "provider/docker",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			out := &bytes.Buffer{}
+			logger := &logrus.Logger{
+				Out:       out,
+				Formatter: NewJSONFormatter(),
+				Hooks:     make(logrus.LevelHooks),
+				Level:     logrus.InfoLevel,
+			}
+			logger.WithFields(test.fields).Warn("message")
+
+			data := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(out.Bytes(), &data))
+			assert.Equal(t, "warning", data["level"])
+			assert.Equal(t, "message", data["msg"])
+			assert.Equal(t, "docker", data["provider"])
+			assert.Equal(t, test.expectedModule, data[ModuleKey])
+			_, err := time.Parse(time.RFC3339Nano, data["time"].(string))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFunctionPackage(t *testing.T) {
+	testCases := []struct {
+		function        string
+		expectedPackage string
+	}{
+		{
+			function:        "github.com/containous/traefik/server.(*Server).Start",
+			expectedPackage: "github.com/containous/traefik/server",
+		},
+		{
+			function:        "github.com/containous/traefik/provider/docker.(*Provider).Provide.func1",
+			expectedPackage: "github.com/containous/traefik/provider/docker",
+		},
+		{
+			function:        "github.com/containous/traefik/vendor/gopkg.in/yaml%2ev2.Unmarshal",
+			expectedPackage: "github.com/containous/traefik/vendor/gopkg.in/yaml%2ev2",
+		},
+		{
+			function:        "main.run",
+			expectedPackage: "main",
+		},
+		{
+			function: "",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.function, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedPackage, functionPackage(test.function))
+		})
+	}
+}
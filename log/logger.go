@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sync"
 
 	"github.com/Sirupsen/logrus"
 )
@@ -14,6 +15,9 @@ var (
 	logger      *logrus.Entry
 	logFilePath string
 	logFile     *os.File
+
+	levelMutex       sync.Mutex
+	levelBeforeDebug = logrus.InfoLevel // level restored when the debug level is toggled off
 )
 
 func init() {
@@ -38,9 +42,27 @@ func SetFormatter(formatter logrus.Formatter) {
 
 // SetLevel sets the standard logger level.
 func SetLevel(level logrus.Level) {
+	levelMutex.Lock()
+	defer levelMutex.Unlock()
+	if level != logrus.DebugLevel {
+		levelBeforeDebug = level
+	}
 	logrus.SetLevel(level)
 }
 
+// ToggleDebugLevel sets the standard logger level to debug, or back to the level it had before, and returns the new level.
+func ToggleDebugLevel() logrus.Level {
+	levelMutex.Lock()
+	defer levelMutex.Unlock()
+	if logrus.GetLevel() == logrus.DebugLevel {
+		logrus.SetLevel(levelBeforeDebug)
+	} else {
+		levelBeforeDebug = logrus.GetLevel()
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	return logrus.GetLevel()
+}
+
 // GetLevel returns the standard logger level.
 func GetLevel() logrus.Level {
 	return logrus.GetLevel()
@@ -227,16 +249,16 @@ func RotateFile() error {
 		return nil
 	}
 
-	if logFile != nil {
-		defer func(f *os.File) {
-			f.Close()
-		}(logFile)
-	}
-
+	oldLogFile := logFile
 	if err := OpenFile(logFilePath); err != nil {
+		// keep on writing to the current file
+		logFile = oldLogFile
 		return fmt.Errorf("error opening log file: %s", err)
 	}
 
+	if oldLogFile != nil {
+		oldLogFile.Close()
+	}
 	return nil
 }
 
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Sirupsen/logrus"
 )
 
 func TestLogRotation(t *testing.T) {
@@ -77,3 +79,21 @@ func lineCount(t *testing.T, fileName string) int {
 
 	return count
 }
+
+func TestToggleDebugLevel(t *testing.T) {
+	defer SetLevel(GetLevel())
+
+	SetLevel(logrus.WarnLevel)
+	if level := ToggleDebugLevel(); level != logrus.DebugLevel {
+		t.Errorf("Wanted level %s after toggling on, got %s", logrus.DebugLevel, level)
+	}
+	if level := ToggleDebugLevel(); level != logrus.WarnLevel {
+		t.Errorf("Wanted level %s after toggling off, got %s", logrus.WarnLevel, level)
+	}
+
+	SetLevel(logrus.ErrorLevel)
+	SetLevel(logrus.DebugLevel)
+	if level := ToggleDebugLevel(); level != logrus.ErrorLevel {
+		t.Errorf("Wanted level %s after toggling off the debug level set, got %s", logrus.ErrorLevel, level)
+	}
+}
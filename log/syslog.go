@@ -0,0 +1,286 @@
+package log
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// syslogTimeout is the longest duration to connect to the syslog server, and to send it a message
+const syslogTimeout = 5 * time.Second
+
+// syslogQueueSize is the number of messages waiting to be sent to syslog, beyond which the messages are dropped
+const syslogQueueSize = 1024
+
+// syslogMinBackoff and syslogMaxBackoff bound the duration between the attempts to connect again to syslog,
+// the messages being dropped in the meantime
+const (
+	syslogMinBackoff = time.Second
+	syslogMaxBackoff = time.Minute
+)
+
+// rfc5424Timestamp is the layout of the timestamps of the RFC 5424 messages, at most with microseconds
+const rfc5424Timestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+// localSyslogSockets are the paths of the local syslog socket, depending on the system
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+var syslogSeverities = map[logrus.Level]int{
+	logrus.PanicLevel: 1, // alert
+	logrus.FatalLevel: 2, // crit
+	logrus.ErrorLevel: 3, // err
+	logrus.WarnLevel:  4, // warning
+	logrus.InfoLevel:  6, // info
+	logrus.DebugLevel: 7, // debug
+}
+
+// SyslogHook is a logrus hook sending the log entries to syslog, with the severities of their levels:
+// to the local syslog socket in the BSD format, or to a remote syslog server in the RFC 5424 format,
+// over UDP, or over TCP or TLS with the octet counting framing of RFC 6587.
+// The messages are sent in the background, so that logging never waits for syslog: they are dropped when
+// too many are waiting, and while syslog cannot be reached. The connection is opened again when a message
+// cannot be sent, with an increasing delay between the attempts, and the number of dropped messages is
+// sent once it is back.
+type SyslogHook struct {
+	dropped   int64 // accessed atomically, first to be 64-bit aligned
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	facility  int
+	tag       string
+	hostname  string
+	messages  chan string
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// used by the background writer only
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// NewSyslogHook connects to the syslog server at the address, over the network udp, tcp or tcp+tls,
+// or to the local syslog socket when the network is empty.
+// The facility is daemon, and the tag traefik, when empty.
+func NewSyslogHook(network, address string, tlsConfig *tls.Config, facility, tag string) (*SyslogHook, error) {
+	switch network {
+	case "":
+	case "udp", "tcp", "tcp+tls":
+		if len(address) == 0 {
+			return nil, errors.New("missing syslog server address")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", network)
+	}
+
+	facilityCode := syslogFacilities["daemon"]
+	if len(facility) > 0 {
+		code, ok := syslogFacilities[strings.ToLower(facility)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility: %s", facility)
+		}
+		facilityCode = code
+	}
+	if len(tag) == 0 {
+		tag = "traefik"
+	}
+	hostname, _ := os.Hostname()
+	if len(hostname) == 0 {
+		hostname = "-"
+	}
+
+	hook := &SyslogHook{
+		network:   network,
+		address:   address,
+		tlsConfig: tlsConfig,
+		facility:  facilityCode,
+		tag:       tag,
+		hostname:  hostname,
+		messages:  make(chan string, syslogQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if err := hook.connect(); err != nil {
+		return nil, err
+	}
+	go hook.run()
+	return hook, nil
+}
+
+func (h *SyslogHook) connect() error {
+	if h.conn != nil {
+		h.conn.Close()
+		h.conn = nil
+	}
+
+	var err error
+	switch h.network {
+	case "":
+		for _, socket := range localSyslogSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				if h.conn, err = net.DialTimeout(network, socket, syslogTimeout); err == nil {
+					return nil
+				}
+			}
+		}
+		return errors.New("unable to connect to the local syslog socket")
+	case "tcp+tls":
+		h.conn, err = tls.DialWithDialer(&net.Dialer{Timeout: syslogTimeout}, "tcp", h.address, h.tlsConfig)
+	default:
+		h.conn, err = net.DialTimeout(h.network, h.address, syslogTimeout)
+	}
+	if err != nil {
+		h.conn = nil
+		return fmt.Errorf("unable to connect to the syslog server %s: %v", h.address, err)
+	}
+	return nil
+}
+
+// Levels returns the levels of the entries sent to syslog
+func (h *SyslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire queues the entry to be sent to syslog, formatted by the formatter of its logger, or drops it
+// when the queue is full
+func (h *SyslogHook) Fire(entry *logrus.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+	severity, ok := syslogSeverities[entry.Level]
+	if !ok {
+		severity = syslogSeverities[logrus.InfoLevel]
+	}
+
+	select {
+	case h.messages <- h.format(h.facility*8+severity, entry.Time, strings.TrimSpace(line)):
+	default:
+		atomic.AddInt64(&h.dropped, 1)
+	}
+	return nil
+}
+
+// run sends the queued messages until the hook is closed, then the ones still queued
+func (h *SyslogHook) run() {
+	defer close(h.done)
+	for {
+		select {
+		case message := <-h.messages:
+			h.write(message)
+		case <-h.stop:
+			for {
+				select {
+				case message := <-h.messages:
+					h.write(message)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write sends the message, connecting again to syslog if needed, unless the previous attempts failed recently
+func (h *SyslogHook) write(message string) {
+	if h.conn == nil || h.send(message) != nil {
+		if time.Now().Before(h.retryAt) {
+			atomic.AddInt64(&h.dropped, 1)
+			return
+		}
+		if err := h.connect(); err != nil || h.send(message) != nil {
+			h.backOff()
+			atomic.AddInt64(&h.dropped, 1)
+			return
+		}
+		h.backoff = 0
+	}
+
+	if dropped := atomic.SwapInt64(&h.dropped, 0); dropped > 0 {
+		notice := fmt.Sprintf("%d log messages were not sent to syslog", dropped)
+		if h.send(h.format(h.facility*8+syslogSeverities[logrus.WarnLevel], time.Now(), notice)) != nil {
+			atomic.AddInt64(&h.dropped, dropped)
+		}
+	}
+}
+
+// backOff doubles the delay before the next attempt to connect to syslog
+func (h *SyslogHook) backOff() {
+	if h.conn != nil {
+		h.conn.Close()
+		h.conn = nil
+	}
+	h.backoff *= 2
+	if h.backoff < syslogMinBackoff {
+		h.backoff = syslogMinBackoff
+	} else if h.backoff > syslogMaxBackoff {
+		h.backoff = syslogMaxBackoff
+	}
+	h.retryAt = time.Now().Add(h.backoff)
+}
+
+func (h *SyslogHook) format(priority int, timestamp time.Time, line string) string {
+	if len(h.network) == 0 {
+		return fmt.Sprintf("<%d>%s %s[%d]: %s\n", priority, timestamp.Format(time.Stamp), h.tag, os.Getpid(), line)
+	}
+	message := fmt.Sprintf("<%d>1 %s %s %s %d - - %s", priority, timestamp.Format(rfc5424Timestamp), h.hostname, h.tag, os.Getpid(), line)
+	if h.network != "udp" {
+		return strconv.Itoa(len(message)) + " " + message
+	}
+	return message
+}
+
+func (h *SyslogHook) send(message string) error {
+	if err := h.conn.SetWriteDeadline(time.Now().Add(syslogTimeout)); err != nil {
+		return err
+	}
+	_, err := h.conn.Write([]byte(message))
+	return err
+}
+
+// Close sends the queued messages, and closes the connection to syslog
+func (h *SyslogHook) Close() error {
+	var err error
+	h.closeOnce.Do(func() {
+		close(h.stop)
+		<-h.done
+		if h.conn != nil {
+			err = h.conn.Close()
+			h.conn = nil
+		}
+	})
+	return err
+}
@@ -0,0 +1,167 @@
+package log
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogHookRemote(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		network  string
+		facility string
+		expected string
+	}{
+		{
+			desc:     "udp",
+			network:  "udp",
+			expected: "<28>1 ",
+		},
+		{
+			desc:     "tcp with octet counting",
+			network:  "tcp",
+			facility: "local0",
+			expected: "<132>1 ",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			messages := make(chan string, 1)
+			var address string
+			if test.network == "udp" {
+				conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+				require.NoError(t, err)
+				defer conn.Close()
+				address = conn.LocalAddr().String()
+				go func() {
+					buffer := make([]byte, 1024)
+					n, _, err := conn.ReadFrom(buffer)
+					if err == nil {
+						messages <- string(buffer[:n])
+					}
+				}()
+			} else {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+				defer listener.Close()
+				address = listener.Addr().String()
+				go func() {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					defer conn.Close()
+					reader := bufio.NewReader(conn)
+					length, err := reader.ReadString(' ')
+					if err != nil {
+						return
+					}
+					size, _ := strconv.Atoi(strings.TrimSpace(length))
+					buffer := make([]byte, size)
+					if _, err := reader.Read(buffer); err == nil {
+						messages <- length + string(buffer)
+					}
+				}()
+			}
+
+			hook, err := NewSyslogHook(test.network, address, nil, test.facility, "")
+			require.NoError(t, err)
+			defer hook.Close()
+
+			logger := &logrus.Logger{
+				Out:       ioutil.Discard,
+				Formatter: &logrus.TextFormatter{DisableTimestamp: true, DisableColors: true},
+				Hooks:     make(logrus.LevelHooks),
+				Level:     logrus.InfoLevel,
+			}
+			logger.Hooks.Add(hook)
+			logger.Warn("syslog message")
+
+			message := <-messages
+			hostname, _ := os.Hostname()
+			if test.network == "tcp" {
+				parts := strings.SplitN(message, " ", 2)
+				require.Len(t, parts, 2)
+				assert.Equal(t, strconv.Itoa(len(parts[1])), parts[0])
+				message = parts[1]
+			}
+			assert.True(t, strings.HasPrefix(message, test.expected), message)
+			assert.Contains(t, message, " "+hostname+" traefik "+strconv.Itoa(os.Getpid())+" - - ")
+			assert.True(t, strings.HasSuffix(message, `level=warning msg="syslog message"`), message)
+		})
+	}
+}
+
+func TestSyslogHookUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	hook, err := NewSyslogHook("tcp", listener.Addr().String(), nil, "", "")
+	require.NoError(t, err)
+	defer hook.Close()
+	listener.Close()
+
+	// the entries are dropped without waiting for syslog, nor failing
+	start := time.Now()
+	for i := 0; i < 2*syslogQueueSize; i++ {
+		assert.NoError(t, hook.Fire(&logrus.Entry{Logger: logrus.New(), Level: logrus.WarnLevel, Message: "syslog message"}))
+	}
+	assert.True(t, time.Since(start) < syslogTimeout, "logging waited for syslog")
+	assert.NoError(t, hook.Close())
+	assert.NotZero(t, atomic.LoadInt64(&hook.dropped))
+}
+
+func TestNewSyslogHookInvalid(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		network  string
+		address  string
+		facility string
+	}{
+		{
+			desc:    "unsupported network",
+			network: "sctp",
+			address: "127.0.0.1:514",
+		},
+		{
+			desc:    "missing address",
+			network: "udp",
+		},
+		{
+			desc:     "unknown facility",
+			network:  "udp",
+			address:  "127.0.0.1:514",
+			facility: "local8",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewSyslogHook(test.network, test.address, nil, test.facility, "")
+			assert.Error(t, err)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/types"
 	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/dogstatsd"
 )
 
@@ -22,6 +23,14 @@ const (
 	ddMetricsReqsName    = "requests.total"
 	ddMetricsLatencyName = "request.duration"
 	ddRetriesTotalName   = "backend.retries.total"
+
+	ddFrontendReqsName         = "frontend.requests.total"
+	ddFrontendLatencyName      = "frontend.request.duration"
+	ddEntryPointReqsName       = "entrypoint.requests.total"
+	ddEntryPointLatencyName    = "entrypoint.request.duration"
+	ddOpenConnectionsName      = "entrypoint.connections.open"
+	ddConfigReloadsName        = "config.reload.total"
+	ddConfigReloadsFailureName = "config.reload.failure.total"
 )
 
 // RegisterDatadog registers the metrics pusher if this didn't happen yet and creates a datadog Registry instance.
@@ -31,15 +40,57 @@ func RegisterDatadog(config *types.Datadog) Registry {
 	}
 
 	registry := &standardRegistry{
-		enabled:              true,
-		reqsCounter:          datadogClient.NewCounter(ddMetricsReqsName, 1.0),
-		reqDurationHistogram: datadogClient.NewHistogram(ddMetricsLatencyName, 1.0),
-		retriesCounter:       datadogClient.NewCounter(ddRetriesTotalName, 1.0),
+		enabled:                        true,
+		reqsCounter:                    &datadogCounter{datadogClient.NewCounter(ddMetricsReqsName, 1.0)},
+		reqDurationHistogram:           &datadogHistogram{datadogClient.NewHistogram(ddMetricsLatencyName, 1.0)},
+		retriesCounter:                 &datadogCounter{datadogClient.NewCounter(ddRetriesTotalName, 1.0)},
+		frontendReqsCounter:            &datadogCounter{datadogClient.NewCounter(ddFrontendReqsName, 1.0)},
+		frontendReqDurationHistogram:   &datadogHistogram{datadogClient.NewHistogram(ddFrontendLatencyName, 1.0)},
+		entryPointReqsCounter:          &datadogCounter{datadogClient.NewCounter(ddEntryPointReqsName, 1.0)},
+		entryPointReqDurationHistogram: &datadogHistogram{datadogClient.NewHistogram(ddEntryPointLatencyName, 1.0)},
+		openConnectionsGauge:           datadogClient.NewGauge(ddOpenConnectionsName),
+		configReloadsCounter:           datadogClient.NewCounter(ddConfigReloadsName, 1.0),
+		configReloadsFailureCounter:    datadogClient.NewCounter(ddConfigReloadsFailureName, 1.0),
 	}
 
 	return registry
 }
 
+// datadogCounter is a counter tagged with the dimensions derived from its labels
+type datadogCounter struct {
+	metrics.Counter
+}
+
+func (c *datadogCounter) With(labelValues ...string) metrics.Counter {
+	return &datadogCounter{c.Counter.With(datadogTags(labelValues)...)}
+}
+
+// datadogHistogram is a histogram tagged with the dimensions derived from its labels
+type datadogHistogram struct {
+	metrics.Histogram
+}
+
+func (h *datadogHistogram) With(labelValues ...string) metrics.Histogram {
+	return &datadogHistogram{h.Histogram.With(datadogTags(labelValues)...)}
+}
+
+// datadogTags adds to the label values the backend of the service, and the class (2xx, 4xx, ...) of the status code,
+// so that the metrics can be aggregated by these dimensions in Datadog.
+func datadogTags(labelValues []string) []string {
+	tags := append([]string{}, labelValues...)
+	for i := 0; i+1 < len(labelValues); i += 2 {
+		switch value := labelValues[i+1]; labelValues[i] {
+		case "service":
+			tags = append(tags, "backend", value)
+		case "code":
+			if len(value) > 0 {
+				tags = append(tags, "status_class", value[:1]+"xx")
+			}
+		}
+	}
+	return tags
+}
+
 func initDatadogClient(config *types.Datadog) *time.Ticker {
 	address := config.Address
 	if len(address) == 0 {
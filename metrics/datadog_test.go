@@ -24,10 +24,11 @@ func TestDatadog(t *testing.T) {
 
 	expected := []string{
 		// We are only validating counts, as it is nearly impossible to validate latency, since it varies every run
-		"traefik.requests.total:1.000000|c|#service:test,code:404,method:GET\n",
-		"traefik.requests.total:1.000000|c|#service:test,code:200,method:GET\n",
-		"traefik.backend.retries.total:2.000000|c|#service:test\n",
-		"traefik.request.duration:10000.000000|h|#service:test,code:200",
+		"traefik.requests.total:1.000000|c|#service:test,code:404,method:GET,backend:test,status_class:4xx\n",
+		"traefik.requests.total:1.000000|c|#service:test,code:200,method:GET,backend:test,status_class:2xx\n",
+		"traefik.backend.retries.total:2.000000|c|#service:test,backend:test\n",
+		"traefik.request.duration:10000.000000|h|#service:test,code:200,backend:test,status_class:2xx",
+		"traefik.frontend.requests.total:1.000000|c|#frontend:web,code:503,method:GET,status_class:5xx\n",
 	}
 
 	udp.ShouldReceiveAll(t, expected, func() {
@@ -36,5 +37,6 @@ func TestDatadog(t *testing.T) {
 		datadogRegistry.ReqDurationHistogram().With("service", "test", "code", strconv.Itoa(http.StatusOK)).Observe(10000)
 		datadogRegistry.RetriesCounter().With("service", "test").Add(1)
 		datadogRegistry.RetriesCounter().With("service", "test").Add(1)
+		datadogRegistry.FrontendReqsCounter().With("frontend", "web", "code", strconv.Itoa(http.StatusServiceUnavailable), "method", http.MethodGet).Add(1)
 	})
 }
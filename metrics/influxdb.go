@@ -0,0 +1,352 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
+)
+
+// Measurement names of the InfluxDB metrics, tagged with their labels
+const (
+	influxDBReqsName                 = "traefik.requests.total"
+	influxDBLatencyName              = "traefik.request.duration"
+	influxDBRetriesTotalName         = "traefik.backend.retries.total"
+	influxDBFrontendReqsName         = "traefik.frontend.requests.total"
+	influxDBFrontendLatencyName      = "traefik.frontend.request.duration"
+	influxDBEntryPointReqsName       = "traefik.entrypoint.requests.total"
+	influxDBEntryPointLatencyName    = "traefik.entrypoint.request.duration"
+	influxDBOpenConnectionsName      = "traefik.entrypoint.connections.open"
+	influxDBConfigReloadsName        = "traefik.config.reload.total"
+	influxDBConfigReloadsFailureName = "traefik.config.reload.failure.total"
+
+	defaultInfluxDBBatchSize = 1000
+	// maxInfluxDBUDPPayload is the maximum size of a UDP datagram, the batches sent over UDP being split to fit in it
+	maxInfluxDBUDPPayload = 65507
+)
+
+var influxDBSeriesStore = newInfluxDBStore()
+
+var influxDBTicker *time.Ticker
+
+// RegisterInfluxDB registers the metrics pusher if this didn't happen yet and creates an InfluxDB Registry instance.
+func RegisterInfluxDB(config *types.InfluxDB) Registry {
+	if influxDBTicker == nil {
+		influxDBTicker = initInfluxDBTicker(config, influxDBSeriesStore)
+	}
+	store := influxDBSeriesStore
+
+	return &standardRegistry{
+		enabled:                        true,
+		reqsCounter:                    &influxDBCounter{store: store, name: influxDBReqsName},
+		reqDurationHistogram:           &influxDBHistogram{store: store, name: influxDBLatencyName},
+		retriesCounter:                 &influxDBCounter{store: store, name: influxDBRetriesTotalName},
+		frontendReqsCounter:            &influxDBCounter{store: store, name: influxDBFrontendReqsName},
+		frontendReqDurationHistogram:   &influxDBHistogram{store: store, name: influxDBFrontendLatencyName},
+		entryPointReqsCounter:          &influxDBCounter{store: store, name: influxDBEntryPointReqsName},
+		entryPointReqDurationHistogram: &influxDBHistogram{store: store, name: influxDBEntryPointLatencyName},
+		openConnectionsGauge:           &influxDBGauge{store: store, name: influxDBOpenConnectionsName},
+		configReloadsCounter:           &influxDBCounter{store: store, name: influxDBConfigReloadsName},
+		configReloadsFailureCounter:    &influxDBCounter{store: store, name: influxDBConfigReloadsFailureName},
+	}
+}
+
+func initInfluxDBTicker(config *types.InfluxDB, store *influxDBStore) *time.Ticker {
+	pushInterval, err := time.ParseDuration(config.PushInterval)
+	if err != nil {
+		log.Warnf("Unable to parse %s into pushInterval, using 10s as default value", config.PushInterval)
+		pushInterval = 10 * time.Second
+	}
+	writer := newInfluxDBWriter(config)
+
+	report := time.NewTicker(pushInterval)
+
+	safe.Go(func() {
+		for range report.C {
+			if err := writer.write(store.flush(time.Now())); err != nil {
+				log.Warnf("Error pushing metrics to InfluxDB: %v", err)
+			}
+		}
+	})
+
+	return report
+}
+
+// StopInfluxDB stops internal influxDBTicker which controls the pushing of metrics to InfluxDB and resets it to `nil`.
+func StopInfluxDB() {
+	if influxDBTicker != nil {
+		influxDBTicker.Stop()
+	}
+	influxDBTicker = nil
+}
+
+// influxDBWriter writes the points in batches, to the UDP or the HTTP API of InfluxDB,
+// the batches sent over UDP being limited to maxBytes to fit in a datagram
+type influxDBWriter struct {
+	protocol  string
+	address   string
+	writeURL  string
+	username  string
+	password  string
+	batchSize int
+	maxBytes  int
+	client    *http.Client
+}
+
+func newInfluxDBWriter(config *types.InfluxDB) *influxDBWriter {
+	writer := &influxDBWriter{
+		protocol:  strings.ToLower(config.Protocol),
+		address:   config.Address,
+		username:  config.Username,
+		password:  config.Password,
+		batchSize: config.BatchSize,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if writer.protocol == "" {
+		writer.protocol = "udp"
+	}
+	if writer.batchSize <= 0 {
+		writer.batchSize = defaultInfluxDBBatchSize
+	}
+	if writer.protocol == "http" {
+		if writer.address == "" {
+			writer.address = "http://localhost:8086"
+		} else if !strings.Contains(writer.address, "://") {
+			writer.address = "http://" + writer.address
+		}
+		query := url.Values{"db": {config.Database}, "precision": {"ns"}}
+		if config.RetentionPolicy != "" {
+			query.Set("rp", config.RetentionPolicy)
+		}
+		writer.writeURL = strings.TrimSuffix(writer.address, "/") + "/write?" + query.Encode()
+	} else {
+		if writer.address == "" {
+			writer.address = "localhost:8089"
+		}
+		writer.maxBytes = maxInfluxDBUDPPayload
+	}
+	return writer
+}
+
+func (w *influxDBWriter) write(lines []string) error {
+	for len(lines) > 0 {
+		var batch bytes.Buffer
+		size := 0
+		for size < len(lines) && size < w.batchSize {
+			if size > 0 && w.maxBytes > 0 && batch.Len()+len(lines[size])+1 > w.maxBytes {
+				break
+			}
+			batch.WriteString(lines[size])
+			batch.WriteString("\n")
+			size++
+		}
+		lines = lines[size:]
+
+		var err error
+		if w.protocol == "http" {
+			err = w.writeHTTP(batch.Bytes())
+		} else {
+			err = w.writeUDP(batch.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *influxDBWriter) writeUDP(batch []byte) error {
+	conn, err := net.Dial("udp", w.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(batch)
+	return err
+}
+
+func (w *influxDBWriter) writeHTTP(batch []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.writeURL, bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d writing to %s", resp.StatusCode, w.address)
+	}
+	return nil
+}
+
+// influxDBStore aggregates the values of the series between two pushes
+type influxDBStore struct {
+	mutex  sync.Mutex
+	series map[string]*influxDBSeries
+}
+
+const (
+	influxDBCounterKind = iota
+	influxDBGaugeKind
+	influxDBHistogramKind
+)
+
+// influxDBSeries holds the total of a counter, the last value of a gauge, or the observations of a histogram
+// since the last push.
+type influxDBSeries struct {
+	kind    int
+	line    string
+	updated bool
+	value   float64
+	count   int
+	sum     float64
+	min     float64
+	max     float64
+}
+
+func newInfluxDBStore() *influxDBStore {
+	return &influxDBStore{series: make(map[string]*influxDBSeries)}
+}
+
+func (s *influxDBStore) update(kind int, name string, labelValues []string, value float64) {
+	line := influxDBSeriesKey(name, labelValues)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	series, ok := s.series[line]
+	if !ok {
+		series = &influxDBSeries{kind: kind, line: line, min: math.Inf(1), max: math.Inf(-1)}
+		s.series[line] = series
+	}
+	series.updated = true
+	switch kind {
+	case influxDBCounterKind:
+		series.value += value
+	case influxDBGaugeKind:
+		series.value = value
+	case influxDBHistogramKind:
+		series.count++
+		series.sum += value
+		series.min = math.Min(series.min, value)
+		series.max = math.Max(series.max, value)
+	}
+}
+
+// flush returns the points of the series updated since the last push in the line protocol, and resets the histograms
+func (s *influxDBStore) flush(now time.Time) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+	var lines []string
+	for _, series := range s.series {
+		if !series.updated {
+			continue
+		}
+		series.updated = false
+
+		var fields string
+		switch series.kind {
+		case influxDBCounterKind, influxDBGaugeKind:
+			fields = "value=" + formatInfluxDBFloat(series.value)
+		case influxDBHistogramKind:
+			fields = fmt.Sprintf("count=%di,sum=%s,min=%s,max=%s,mean=%s", series.count, formatInfluxDBFloat(series.sum),
+				formatInfluxDBFloat(series.min), formatInfluxDBFloat(series.max), formatInfluxDBFloat(series.sum/float64(series.count)))
+			series.count, series.sum, series.min, series.max = 0, 0, math.Inf(1), math.Inf(-1)
+		}
+		lines = append(lines, series.line+" "+fields+" "+timestamp)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// influxDBSeriesKey returns the measurement and the sorted tags of a series, escaped for the line protocol
+func influxDBSeriesKey(name string, labelValues []string) string {
+	tags := make([]string, 0, len(labelValues)/2)
+	for i := 0; i+1 < len(labelValues); i += 2 {
+		if len(labelValues[i]) > 0 && len(labelValues[i+1]) > 0 {
+			tags = append(tags, escapeInfluxDB(labelValues[i], true)+"="+escapeInfluxDB(labelValues[i+1], true))
+		}
+	}
+	sort.Strings(tags)
+
+	var key bytes.Buffer
+	key.WriteString(escapeInfluxDB(name, false))
+	for _, tag := range tags {
+		key.WriteString(",")
+		key.WriteString(tag)
+	}
+	return key.String()
+}
+
+func escapeInfluxDB(value string, tag bool) string {
+	replacements := []string{",", `\,`, " ", `\ `}
+	if tag {
+		replacements = append(replacements, "=", `\=`)
+	}
+	return strings.NewReplacer(replacements...).Replace(value)
+}
+
+func formatInfluxDBFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+type influxDBCounter struct {
+	store       *influxDBStore
+	name        string
+	labelValues []string
+}
+
+func (c *influxDBCounter) With(labelValues ...string) metrics.Counter {
+	return &influxDBCounter{store: c.store, name: c.name, labelValues: append(append([]string{}, c.labelValues...), labelValues...)}
+}
+
+func (c *influxDBCounter) Add(delta float64) {
+	c.store.update(influxDBCounterKind, c.name, c.labelValues, delta)
+}
+
+type influxDBGauge struct {
+	store       *influxDBStore
+	name        string
+	labelValues []string
+}
+
+func (g *influxDBGauge) With(labelValues ...string) metrics.Gauge {
+	return &influxDBGauge{store: g.store, name: g.name, labelValues: append(append([]string{}, g.labelValues...), labelValues...)}
+}
+
+func (g *influxDBGauge) Set(value float64) {
+	g.store.update(influxDBGaugeKind, g.name, g.labelValues, value)
+}
+
+type influxDBHistogram struct {
+	store       *influxDBStore
+	name        string
+	labelValues []string
+}
+
+func (h *influxDBHistogram) With(labelValues ...string) metrics.Histogram {
+	return &influxDBHistogram{store: h.store, name: h.name, labelValues: append(append([]string{}, h.labelValues...), labelValues...)}
+}
+
+func (h *influxDBHistogram) Observe(value float64) {
+	h.store.update(influxDBHistogramKind, h.name, h.labelValues, value)
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stvp/go-udp-testing"
+)
+
+func TestInfluxDB(t *testing.T) {
+	udp.SetAddr(":18089")
+	// This is needed to make sure that UDP Listener listens for data a bit longer, otherwise it will quit after a millisecond
+	udp.Timeout = 5 * time.Second
+
+	influxDBRegistry := RegisterInfluxDB(&types.InfluxDB{Address: ":18089", PushInterval: "1s"})
+	defer StopInfluxDB()
+
+	if !influxDBRegistry.IsEnabled() {
+		t.Errorf("InfluxDBRegistry should return true for IsEnabled()")
+	}
+
+	expected := []string{
+		"traefik.requests.total,code=200,method=GET,service=test value=2 ",
+		"traefik.requests.total,code=404,method=GET,service=test value=1 ",
+		"traefik.backend.retries.total,service=test value=1 ",
+		"traefik.request.duration,code=200,service=test count=2i,sum=30,min=10,max=20,mean=15 ",
+		"traefik.entrypoint.requests.total,code=200,entrypoint=http,method=GET value=1 ",
+		"traefik.entrypoint.request.duration,code=200,entrypoint=http count=1i,sum=10,min=10,max=10,mean=10 ",
+		"traefik.entrypoint.connections.open,entrypoint=http value=3 ",
+	}
+
+	udp.ShouldReceiveAll(t, expected, func() {
+		influxDBRegistry.ReqsCounter().With("service", "test", "code", strconv.Itoa(http.StatusOK), "method", http.MethodGet).Add(1)
+		influxDBRegistry.ReqsCounter().With("service", "test", "code", strconv.Itoa(http.StatusOK), "method", http.MethodGet).Add(1)
+		influxDBRegistry.ReqsCounter().With("service", "test", "code", strconv.Itoa(http.StatusNotFound), "method", http.MethodGet).Add(1)
+		influxDBRegistry.RetriesCounter().With("service", "test").Add(1)
+		influxDBRegistry.ReqDurationHistogram().With("service", "test", "code", strconv.Itoa(http.StatusOK)).Observe(10)
+		influxDBRegistry.ReqDurationHistogram().With("service", "test", "code", strconv.Itoa(http.StatusOK)).Observe(20)
+		influxDBRegistry.EntryPointReqsCounter().With("entrypoint", "http", "code", strconv.Itoa(http.StatusOK), "method", http.MethodGet).Add(1)
+		influxDBRegistry.EntryPointReqDurationHistogram().With("entrypoint", "http", "code", strconv.Itoa(http.StatusOK)).Observe(10)
+		influxDBRegistry.OpenConnectionsGauge().With("entrypoint", "http").Set(3)
+	})
+}
+
+func TestInfluxDBHTTPWriter(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		requests = append(requests, req)
+		bodies = append(bodies, string(body))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	writer := newInfluxDBWriter(&types.InfluxDB{
+		Address:         server.URL,
+		Protocol:        "http",
+		Database:        "traefik",
+		RetentionPolicy: "autogen",
+		Username:        "user",
+		Password:        "secret",
+		BatchSize:       2,
+	})
+	err := writer.write([]string{"a value=1 1", "b value=2 1", "c value=3 1"})
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/write", requests[0].URL.Path)
+	assert.Equal(t, "traefik", requests[0].URL.Query().Get("db"))
+	assert.Equal(t, "autogen", requests[0].URL.Query().Get("rp"))
+	username, password, ok := requests[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+	assert.Equal(t, []string{"a value=1 1\nb value=2 1\n", "c value=3 1\n"}, bodies)
+}
+
+func TestInfluxDBHTTPWriterAddressWithoutScheme(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	writer := newInfluxDBWriter(&types.InfluxDB{
+		Address:  server.Listener.Addr().String(),
+		Protocol: "http",
+		Database: "traefik",
+	})
+	err := writer.write([]string{"a value=1 1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/write"}, paths)
+}
+
+func TestInfluxDBUDPWriterBatchBytes(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	writer := newInfluxDBWriter(&types.InfluxDB{Address: conn.LocalAddr().String()})
+	line := "a value=" + strings.Repeat("1", 40000) + " 1"
+	err = writer.write([]string{line, line, "b value=2 1"})
+	require.NoError(t, err)
+
+	var datagrams []string
+	buffer := make([]byte, 2*maxInfluxDBUDPPayload)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buffer)
+		require.NoError(t, err)
+		assert.True(t, n <= maxInfluxDBUDPPayload, "datagram of %d bytes", n)
+		datagrams = append(datagrams, string(buffer[:n]))
+	}
+	assert.Equal(t, []string{line + "\n", line + "\nb value=2 1\n"}, datagrams)
+}
+
+func TestInfluxDBStore(t *testing.T) {
+	store := newInfluxDBStore()
+	counter := &influxDBCounter{store: store, name: "requests total"}
+	histogram := &influxDBHistogram{store: store, name: "duration"}
+
+	counter.With("frontend", "my frontend", "path", "a=b,c").Add(1)
+	histogram.With("code", "200").Observe(1)
+	now := time.Unix(0, 42)
+
+	assert.Equal(t, []string{
+		"duration,code=200 count=1i,sum=1,min=1,max=1,mean=1 42",
+		`requests\ total,frontend=my\ frontend,path=a\=b\,c value=1 42`,
+	}, store.flush(now))
+
+	// only the series updated since the last push are written, the counters being cumulative
+	assert.Empty(t, store.flush(now))
+	counter.With("frontend", "my frontend", "path", "a=b,c").Add(2)
+	assert.Equal(t, []string{`requests\ total,frontend=my\ frontend,path=a\=b\,c value=3 42`}, store.flush(now))
+	assert.Equal(t, "duration", influxDBSeriesKey("duration", []string{"empty", ""}))
+}
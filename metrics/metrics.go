@@ -12,6 +12,13 @@ type Registry interface {
 	ReqsCounter() metrics.Counter
 	ReqDurationHistogram() metrics.Histogram
 	RetriesCounter() metrics.Counter
+	FrontendReqsCounter() metrics.Counter
+	FrontendReqDurationHistogram() metrics.Histogram
+	EntryPointReqsCounter() metrics.Counter
+	EntryPointReqDurationHistogram() metrics.Histogram
+	OpenConnectionsGauge() metrics.Gauge
+	ConfigReloadsCounter() metrics.Counter
+	ConfigReloadsFailureCounter() metrics.Counter
 }
 
 // NewMultiRegistry creates a new standardRegistry that wraps multiple Registries.
@@ -19,26 +26,54 @@ func NewMultiRegistry(registries []Registry) Registry {
 	reqsCounters := []metrics.Counter{}
 	reqDurationHistograms := []metrics.Histogram{}
 	retriesCounters := []metrics.Counter{}
+	frontendReqsCounters := []metrics.Counter{}
+	frontendReqDurationHistograms := []metrics.Histogram{}
+	entryPointReqsCounters := []metrics.Counter{}
+	entryPointReqDurationHistograms := []metrics.Histogram{}
+	openConnectionsGauges := []metrics.Gauge{}
+	configReloadsCounters := []metrics.Counter{}
+	configReloadsFailureCounters := []metrics.Counter{}
 
 	for _, r := range registries {
 		reqsCounters = append(reqsCounters, r.ReqsCounter())
 		reqDurationHistograms = append(reqDurationHistograms, r.ReqDurationHistogram())
 		retriesCounters = append(retriesCounters, r.RetriesCounter())
+		frontendReqsCounters = append(frontendReqsCounters, r.FrontendReqsCounter())
+		frontendReqDurationHistograms = append(frontendReqDurationHistograms, r.FrontendReqDurationHistogram())
+		entryPointReqsCounters = append(entryPointReqsCounters, r.EntryPointReqsCounter())
+		entryPointReqDurationHistograms = append(entryPointReqDurationHistograms, r.EntryPointReqDurationHistogram())
+		openConnectionsGauges = append(openConnectionsGauges, r.OpenConnectionsGauge())
+		configReloadsCounters = append(configReloadsCounters, r.ConfigReloadsCounter())
+		configReloadsFailureCounters = append(configReloadsFailureCounters, r.ConfigReloadsFailureCounter())
 	}
 
 	return &standardRegistry{
-		enabled:              true,
-		reqsCounter:          multi.NewCounter(reqsCounters...),
-		reqDurationHistogram: multi.NewHistogram(reqDurationHistograms...),
-		retriesCounter:       multi.NewCounter(retriesCounters...),
+		enabled:                        true,
+		reqsCounter:                    multi.NewCounter(reqsCounters...),
+		reqDurationHistogram:           multi.NewHistogram(reqDurationHistograms...),
+		retriesCounter:                 multi.NewCounter(retriesCounters...),
+		frontendReqsCounter:            multi.NewCounter(frontendReqsCounters...),
+		frontendReqDurationHistogram:   multi.NewHistogram(frontendReqDurationHistograms...),
+		entryPointReqsCounter:          multi.NewCounter(entryPointReqsCounters...),
+		entryPointReqDurationHistogram: multi.NewHistogram(entryPointReqDurationHistograms...),
+		openConnectionsGauge:           multi.NewGauge(openConnectionsGauges...),
+		configReloadsCounter:           multi.NewCounter(configReloadsCounters...),
+		configReloadsFailureCounter:    multi.NewCounter(configReloadsFailureCounters...),
 	}
 }
 
 type standardRegistry struct {
-	enabled              bool
-	reqsCounter          metrics.Counter
-	reqDurationHistogram metrics.Histogram
-	retriesCounter       metrics.Counter
+	enabled                        bool
+	reqsCounter                    metrics.Counter
+	reqDurationHistogram           metrics.Histogram
+	retriesCounter                 metrics.Counter
+	frontendReqsCounter            metrics.Counter
+	frontendReqDurationHistogram   metrics.Histogram
+	entryPointReqsCounter          metrics.Counter
+	entryPointReqDurationHistogram metrics.Histogram
+	openConnectionsGauge           metrics.Gauge
+	configReloadsCounter           metrics.Counter
+	configReloadsFailureCounter    metrics.Counter
 }
 
 func (r *standardRegistry) IsEnabled() bool {
@@ -57,14 +92,49 @@ func (r *standardRegistry) RetriesCounter() metrics.Counter {
 	return r.retriesCounter
 }
 
+func (r *standardRegistry) FrontendReqsCounter() metrics.Counter {
+	return r.frontendReqsCounter
+}
+
+func (r *standardRegistry) FrontendReqDurationHistogram() metrics.Histogram {
+	return r.frontendReqDurationHistogram
+}
+
+func (r *standardRegistry) EntryPointReqsCounter() metrics.Counter {
+	return r.entryPointReqsCounter
+}
+
+func (r *standardRegistry) EntryPointReqDurationHistogram() metrics.Histogram {
+	return r.entryPointReqDurationHistogram
+}
+
+func (r *standardRegistry) OpenConnectionsGauge() metrics.Gauge {
+	return r.openConnectionsGauge
+}
+
+func (r *standardRegistry) ConfigReloadsCounter() metrics.Counter {
+	return r.configReloadsCounter
+}
+
+func (r *standardRegistry) ConfigReloadsFailureCounter() metrics.Counter {
+	return r.configReloadsFailureCounter
+}
+
 // NewVoidRegistry is a noop implementation of metrics.Registry.
 // It is used to avoid nil checking in components that do metric collections.
 func NewVoidRegistry() Registry {
 	return &standardRegistry{
-		enabled:              false,
-		reqsCounter:          &voidCounter{},
-		reqDurationHistogram: &voidHistogram{},
-		retriesCounter:       &voidCounter{},
+		enabled:                        false,
+		reqsCounter:                    &voidCounter{},
+		reqDurationHistogram:           &voidHistogram{},
+		retriesCounter:                 &voidCounter{},
+		frontendReqsCounter:            &voidCounter{},
+		frontendReqDurationHistogram:   &voidHistogram{},
+		entryPointReqsCounter:          &voidCounter{},
+		entryPointReqDurationHistogram: &voidHistogram{},
+		openConnectionsGauge:           &voidGauge{},
+		configReloadsCounter:           &voidCounter{},
+		configReloadsFailureCounter:    &voidCounter{},
 	}
 }
 
@@ -73,6 +143,11 @@ type voidCounter struct{}
 func (v *voidCounter) With(labelValues ...string) metrics.Counter { return v }
 func (v *voidCounter) Add(delta float64)                          {}
 
+type voidGauge struct{}
+
+func (g *voidGauge) With(labelValues ...string) metrics.Gauge { return g }
+func (g *voidGauge) Set(value float64)                        {}
+
 type voidHistogram struct{}
 
 func (h *voidHistogram) With(labelValues ...string) metrics.Histogram { return h }
@@ -16,6 +16,13 @@ func TestNewVoidRegistry(t *testing.T) {
 	registry.ReqsCounter().With("some", "value").Add(1)
 	registry.ReqDurationHistogram().With("some", "value").Observe(1)
 	registry.RetriesCounter().With("some", "value").Add(1)
+	registry.FrontendReqsCounter().With("some", "value").Add(1)
+	registry.FrontendReqDurationHistogram().With("some", "value").Observe(1)
+	registry.EntryPointReqsCounter().With("some", "value").Add(1)
+	registry.EntryPointReqDurationHistogram().With("some", "value").Observe(1)
+	registry.OpenConnectionsGauge().With("some", "value").Set(1)
+	registry.ConfigReloadsCounter().Add(1)
+	registry.ConfigReloadsFailureCounter().Add(1)
 }
 
 func TestNewMultiRegistry(t *testing.T) {
@@ -25,11 +32,13 @@ func TestNewMultiRegistry(t *testing.T) {
 	registry.ReqsCounter().With("key", "requests").Add(1)
 	registry.ReqDurationHistogram().With("key", "durations").Observe(2)
 	registry.RetriesCounter().With("key", "retries").Add(3)
+	registry.OpenConnectionsGauge().With("key", "connections").Set(4)
 
 	for _, collectingRegistry := range registries {
 		cReqsCounter := collectingRegistry.ReqsCounter().(*counterMock)
 		cReqDurationHistogram := collectingRegistry.ReqDurationHistogram().(*histogramMock)
 		cRetriesCounter := collectingRegistry.RetriesCounter().(*counterMock)
+		cOpenConnectionsGauge := collectingRegistry.OpenConnectionsGauge().(*gaugeMock)
 
 		wantCounterValue := float64(1)
 		if cReqsCounter.counterValue != wantCounterValue {
@@ -47,6 +56,8 @@ func TestNewMultiRegistry(t *testing.T) {
 		assert.Equal(t, []string{"key", "requests"}, cReqsCounter.lastLabelValues)
 		assert.Equal(t, []string{"key", "durations"}, cReqDurationHistogram.lastLabelValues)
 		assert.Equal(t, []string{"key", "retries"}, cRetriesCounter.lastLabelValues)
+		assert.Equal(t, float64(4), cOpenConnectionsGauge.gaugeValue)
+		assert.Equal(t, []string{"key", "connections"}, cOpenConnectionsGauge.lastLabelValues)
 	}
 }
 
@@ -55,6 +66,7 @@ func newCollectingRetryMetrics() Registry {
 		reqsCounter:          &counterMock{},
 		reqDurationHistogram: &histogramMock{},
 		retriesCounter:       &counterMock{},
+		openConnectionsGauge: &gaugeMock{},
 	}
 }
 
@@ -72,6 +84,20 @@ func (c *counterMock) Add(delta float64) {
 	c.counterValue += delta
 }
 
+type gaugeMock struct {
+	gaugeValue      float64
+	lastLabelValues []string
+}
+
+func (g *gaugeMock) With(labelValues ...string) metrics.Gauge {
+	g.lastLabelValues = labelValues
+	return g
+}
+
+func (g *gaugeMock) Set(value float64) {
+	g.gaugeValue = value
+}
+
 type histogramMock struct {
 	lastHistogramValue float64
 	lastLabelValues    []string
@@ -12,6 +12,14 @@ const (
 	reqsTotalName    = metricNamePrefix + "requests_total"
 	reqDurationName  = metricNamePrefix + "request_duration_seconds"
 	retriesTotalName = metricNamePrefix + "backend_retries_total"
+
+	frontendReqsTotalName     = metricNamePrefix + "frontend_requests_total"
+	frontendReqDurationName   = metricNamePrefix + "frontend_request_duration_seconds"
+	entryPointReqsTotalName   = metricNamePrefix + "entrypoint_requests_total"
+	entryPointReqDurationName = metricNamePrefix + "entrypoint_request_duration_seconds"
+	openConnectionsName       = metricNamePrefix + "entrypoint_open_connections"
+	configReloadsTotalName    = metricNamePrefix + "config_reloads_total"
+	configReloadsFailureName  = metricNamePrefix + "config_reloads_failure_total"
 )
 
 // RegisterPrometheus registers all Prometheus metrics.
@@ -36,10 +44,48 @@ func RegisterPrometheus(config *types.Prometheus) Registry {
 		Help: "How many request retries happened in total.",
 	}, []string{"service"})
 
+	frontendReqCounter := prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: frontendReqsTotalName,
+		Help: "How many HTTP requests processed by a frontend, partitioned by status code and method.",
+	}, []string{"frontend", "code", "method"})
+	frontendReqDurationHistogram := prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    frontendReqDurationName,
+		Help:    "How long it took a frontend to process the request.",
+		Buckets: buckets,
+	}, []string{"frontend", "code"})
+	entryPointReqCounter := prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: entryPointReqsTotalName,
+		Help: "How many HTTP requests received by an entrypoint, partitioned by status code and method.",
+	}, []string{"entrypoint", "code", "method"})
+	entryPointReqDurationHistogram := prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Name:    entryPointReqDurationName,
+		Help:    "How long it took an entrypoint to process the request.",
+		Buckets: buckets,
+	}, []string{"entrypoint", "code"})
+	openConnectionsGauge := prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Name: openConnectionsName,
+		Help: "How many connections are open on an entrypoint.",
+	}, []string{"entrypoint"})
+	configReloadsCounter := prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: configReloadsTotalName,
+		Help: "How many configuration reloads succeeded.",
+	}, []string{})
+	configReloadsFailureCounter := prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Name: configReloadsFailureName,
+		Help: "How many configuration reloads failed.",
+	}, []string{})
+
 	return &standardRegistry{
-		enabled:              true,
-		reqsCounter:          reqCounter,
-		reqDurationHistogram: reqDurationHistogram,
-		retriesCounter:       retryCounter,
+		enabled:                        true,
+		reqsCounter:                    reqCounter,
+		reqDurationHistogram:           reqDurationHistogram,
+		retriesCounter:                 retryCounter,
+		frontendReqsCounter:            frontendReqCounter,
+		frontendReqDurationHistogram:   frontendReqDurationHistogram,
+		entryPointReqsCounter:          entryPointReqCounter,
+		entryPointReqDurationHistogram: entryPointReqDurationHistogram,
+		openConnectionsGauge:           openConnectionsGauge,
+		configReloadsCounter:           configReloadsCounter,
+		configReloadsFailureCounter:    configReloadsFailureCounter,
 	}
 }
@@ -21,6 +21,13 @@ func TestPrometheus(t *testing.T) {
 	prometheusRegistry.ReqDurationHistogram().With("service", "test", "code", strconv.Itoa(http.StatusOK)).Observe(10000)
 	prometheusRegistry.ReqDurationHistogram().With("service", "test", "code", strconv.Itoa(http.StatusOK)).Observe(10000)
 	prometheusRegistry.RetriesCounter().With("service", "test").Add(1)
+	prometheusRegistry.FrontendReqsCounter().With("frontend", "test", "code", strconv.Itoa(http.StatusOK), "method", http.MethodGet).Add(1)
+	prometheusRegistry.FrontendReqDurationHistogram().With("frontend", "test", "code", strconv.Itoa(http.StatusOK)).Observe(10000)
+	prometheusRegistry.EntryPointReqsCounter().With("entrypoint", "http", "code", strconv.Itoa(http.StatusOK), "method", http.MethodGet).Add(1)
+	prometheusRegistry.EntryPointReqDurationHistogram().With("entrypoint", "http", "code", strconv.Itoa(http.StatusOK)).Observe(10000)
+	prometheusRegistry.OpenConnectionsGauge().With("entrypoint", "http").Set(3)
+	prometheusRegistry.ConfigReloadsCounter().Add(1)
+	prometheusRegistry.ConfigReloadsFailureCounter().Add(1)
 
 	metricsFamilies, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
@@ -74,6 +81,99 @@ func TestPrometheus(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: frontendReqsTotalName,
+			labels: map[string]string{
+				"code":     "200",
+				"method":   http.MethodGet,
+				"frontend": "test",
+			},
+			assert: func(family *dto.MetricFamily) {
+				cv := family.Metric[0].Counter.GetValue()
+				expectedCv := float64(1)
+				if cv != expectedCv {
+					t.Errorf("gathered metrics do not contain correct value for total frontend requests, got %f expected %f", cv, expectedCv)
+				}
+			},
+		},
+		{
+			name: frontendReqDurationName,
+			labels: map[string]string{
+				"frontend": "test",
+				"code":     "200",
+			},
+			assert: func(family *dto.MetricFamily) {
+				sc := family.Metric[0].Histogram.GetSampleCount()
+				expectedSc := uint64(1)
+				if sc != expectedSc {
+					t.Errorf("gathered metrics do not contain correct sample count for frontend request duration, got %d expected %d", sc, expectedSc)
+				}
+			},
+		},
+		{
+			name: entryPointReqsTotalName,
+			labels: map[string]string{
+				"code":       "200",
+				"method":     http.MethodGet,
+				"entrypoint": "http",
+			},
+			assert: func(family *dto.MetricFamily) {
+				cv := family.Metric[0].Counter.GetValue()
+				expectedCv := float64(1)
+				if cv != expectedCv {
+					t.Errorf("gathered metrics do not contain correct value for total entrypoint requests, got %f expected %f", cv, expectedCv)
+				}
+			},
+		},
+		{
+			name: entryPointReqDurationName,
+			labels: map[string]string{
+				"entrypoint": "http",
+				"code":       "200",
+			},
+			assert: func(family *dto.MetricFamily) {
+				sc := family.Metric[0].Histogram.GetSampleCount()
+				expectedSc := uint64(1)
+				if sc != expectedSc {
+					t.Errorf("gathered metrics do not contain correct sample count for entrypoint request duration, got %d expected %d", sc, expectedSc)
+				}
+			},
+		},
+		{
+			name: openConnectionsName,
+			labels: map[string]string{
+				"entrypoint": "http",
+			},
+			assert: func(family *dto.MetricFamily) {
+				gv := family.Metric[0].Gauge.GetValue()
+				expectedGv := float64(3)
+				if gv != expectedGv {
+					t.Errorf("gathered metrics do not contain correct value for open connections, got %f expected %f", gv, expectedGv)
+				}
+			},
+		},
+		{
+			name:   configReloadsTotalName,
+			labels: map[string]string{},
+			assert: func(family *dto.MetricFamily) {
+				cv := family.Metric[0].Counter.GetValue()
+				expectedCv := float64(1)
+				if cv != expectedCv {
+					t.Errorf("gathered metrics do not contain correct value for config reloads, got %f expected %f", cv, expectedCv)
+				}
+			},
+		},
+		{
+			name:   configReloadsFailureName,
+			labels: map[string]string{},
+			assert: func(family *dto.MetricFamily) {
+				cv := family.Metric[0].Counter.GetValue()
+				expectedCv := float64(1)
+				if cv != expectedCv {
+					t.Errorf("gathered metrics do not contain correct value for config reload failures, got %f expected %f", cv, expectedCv)
+				}
+			},
+		},
 	}
 
 	for _, test := range tests {
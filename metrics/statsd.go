@@ -10,27 +10,46 @@ import (
 	"github.com/go-kit/kit/metrics/statsd"
 )
 
-var statsdClient = statsd.New("traefik.", kitlog.LoggerFunc(func(keyvals ...interface{}) error {
-	log.Info(keyvals)
-	return nil
-}))
+// defaultStatsdPrefix is the prefix of the metric names, when none is configured
+const defaultStatsdPrefix = "traefik"
+
+var statsdClient = newStatsdClient(defaultStatsdPrefix)
 
 var statsdTicker *time.Ticker
 
 // RegisterStatsd registers the metrics pusher if this didn't happen yet and creates a statsd Registry instance.
 func RegisterStatsd(config *types.Statsd) Registry {
 	if statsdTicker == nil {
+		prefix := config.Prefix
+		if len(prefix) == 0 {
+			prefix = defaultStatsdPrefix
+		}
+		statsdClient = newStatsdClient(prefix)
 		statsdTicker = initStatsdTicker(config)
 	}
 
 	return &standardRegistry{
-		enabled:              true,
-		reqsCounter:          statsdClient.NewCounter(ddMetricsReqsName, 1.0),
-		reqDurationHistogram: statsdClient.NewTiming(ddMetricsLatencyName, 1.0),
-		retriesCounter:       statsdClient.NewCounter(ddRetriesTotalName, 1.0),
+		enabled:                        true,
+		reqsCounter:                    statsdClient.NewCounter(ddMetricsReqsName, 1.0),
+		reqDurationHistogram:           statsdClient.NewTiming(ddMetricsLatencyName, 1.0),
+		retriesCounter:                 statsdClient.NewCounter(ddRetriesTotalName, 1.0),
+		frontendReqsCounter:            statsdClient.NewCounter(ddFrontendReqsName, 1.0),
+		frontendReqDurationHistogram:   statsdClient.NewTiming(ddFrontendLatencyName, 1.0),
+		entryPointReqsCounter:          statsdClient.NewCounter(ddEntryPointReqsName, 1.0),
+		entryPointReqDurationHistogram: statsdClient.NewTiming(ddEntryPointLatencyName, 1.0),
+		openConnectionsGauge:           statsdClient.NewGauge(ddOpenConnectionsName),
+		configReloadsCounter:           statsdClient.NewCounter(ddConfigReloadsName, 1.0),
+		configReloadsFailureCounter:    statsdClient.NewCounter(ddConfigReloadsFailureName, 1.0),
 	}
 }
 
+func newStatsdClient(prefix string) *statsd.Statsd {
+	return statsd.New(prefix+".", kitlog.LoggerFunc(func(keyvals ...interface{}) error {
+		log.Info(keyvals)
+		return nil
+	}))
+}
+
 // initStatsdTicker initializes metrics pusher and creates a statsdClient if not created already
 func initStatsdTicker(config *types.Statsd) *time.Ticker {
 	address := config.Address
@@ -36,3 +36,24 @@ func TestStatsD(t *testing.T) {
 		statsdRegistry.ReqDurationHistogram().With("service", "test", "code", string(http.StatusOK)).Observe(10000)
 	})
 }
+
+func TestStatsDWithPrefix(t *testing.T) {
+	udp.SetAddr(":18125")
+	// This is needed to make sure that UDP Listener listens for data a bit longer, otherwise it will quit after a millisecond
+	udp.Timeout = 5 * time.Second
+
+	statsdRegistry := RegisterStatsd(&types.Statsd{Address: ":18125", PushInterval: "1s", Prefix: "proxy"})
+	defer StopStatsd()
+
+	expected := []string{
+		"proxy.requests.total:1.000000|c\n",
+		"proxy.frontend.requests.total:1.000000|c\n",
+		"proxy.config.reload.total:1.000000|c\n",
+	}
+
+	udp.ShouldReceiveAll(t, expected, func() {
+		statsdRegistry.ReqsCounter().With("service", "test", "code", "200", "method", http.MethodGet).Add(1)
+		statsdRegistry.FrontendReqsCounter().With("frontend", "test", "code", "200", "method", http.MethodGet).Add(1)
+		statsdRegistry.ConfigReloadsCounter().Add(1)
+	})
+}
@@ -70,6 +70,8 @@ const (
 	Overhead = "Overhead"
 	// RetryAttempts is the map key used for the amount of attempts the request was retried.
 	RetryAttempts = "RetryAttempts"
+	// RequestID is the map key used for the ID of the request, given by its X-Request-Id header.
+	RequestID = "RequestID"
 )
 
 // These are written out in the default case when no config is provided to specify keys of interest.
@@ -113,6 +115,7 @@ func init() {
 	allCoreKeys[StartLocal] = struct{}{}
 	allCoreKeys[Overhead] = struct{}{}
 	allCoreKeys[RetryAttempts] = struct{}{}
+	allCoreKeys[RequestID] = struct{}{}
 }
 
 // CoreLogData holds the fields computed from the request/response.
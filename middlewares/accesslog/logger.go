@@ -3,16 +3,20 @@ package accesslog
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares"
 	"github.com/containous/traefik/types"
 )
 
@@ -28,14 +32,25 @@ const (
 
 	// JSONFormat is the JSON logging format
 	JSONFormat = "json"
+
+	// CombinedFormat is the Combined Log Format, the CLF with the referer and the user agent
+	CombinedFormat = "combined"
+
+	// TemplateFormat is the logging format given by a Go template
+	TemplateFormat = "template"
 )
 
 // LogHandler will write each request and its response to the access log.
 type LogHandler struct {
-	logger   *logrus.Logger
-	file     *os.File
-	filePath string
-	mu       sync.Mutex
+	logger            *logrus.Logger
+	file              *os.File
+	filePath          string
+	mu                sync.Mutex
+	httpCodeRanges    middlewares.HTTPCodeRanges
+	minDuration       time.Duration
+	excludePaths      []string
+	hasKeepingFilters bool
+	syslogHook        *log.SyslogHook
 }
 
 // NewLogHandler creates a new LogHandler
@@ -55,7 +70,15 @@ func NewLogHandler(config *types.AccessLog) (*LogHandler, error) {
 	case CommonFormat:
 		formatter = new(CommonLogFormatter)
 	case JSONFormat:
-		formatter = new(logrus.JSONFormatter)
+		formatter = new(JSONLogFormatter)
+	case CombinedFormat:
+		formatter = new(CombinedLogFormatter)
+	case TemplateFormat:
+		templateFormatter, err := NewTemplateLogFormatter(config.Template)
+		if err != nil {
+			return nil, err
+		}
+		formatter = templateFormatter
 	default:
 		return nil, fmt.Errorf("unsupported access log format: %s", config.Format)
 	}
@@ -66,7 +89,33 @@ func NewLogHandler(config *types.AccessLog) (*LogHandler, error) {
 		Hooks:     make(logrus.LevelHooks),
 		Level:     logrus.InfoLevel,
 	}
-	return &LogHandler{logger: logger, file: file, filePath: config.FilePath}, nil
+	logHandler := &LogHandler{logger: logger, file: file, filePath: config.FilePath}
+
+	if config.Syslog != nil {
+		syslogHook, err := config.Syslog.CreateHook()
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to syslog: %s", err)
+		}
+		logger.Hooks.Add(syslogHook)
+		if len(config.FilePath) == 0 {
+			logger.Out = ioutil.Discard
+		}
+		logHandler.syslogHook = syslogHook
+	}
+
+	if config.Filters != nil {
+		httpCodeRanges, err := middlewares.NewHTTPCodeRanges(config.Filters.StatusCodes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access log status codes filter: %s", err)
+		}
+		logHandler.httpCodeRanges = httpCodeRanges
+		logHandler.minDuration = time.Duration(config.Filters.MinDuration)
+		logHandler.hasKeepingFilters = len(httpCodeRanges) > 0 || logHandler.minDuration > 0
+		for _, path := range config.Filters.ExcludePaths {
+			logHandler.excludePaths = append(logHandler.excludePaths, strings.TrimSuffix(path, "/"))
+		}
+	}
+	return logHandler, nil
 }
 
 func openAccessLogFile(filePath string) (*os.File, error) {
@@ -137,32 +186,65 @@ func (l *LogHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, next h
 
 	next.ServeHTTP(crw, reqWithDataTable)
 
+	// the path of the request may have been rewritten by the middlewares of the frontend
+	if l.isExcludedPath(urlCopy.Path) {
+		return
+	}
 	logDataTable.DownstreamResponse = crw.Header()
 	l.logTheRoundTrip(logDataTable, crr, crw)
 }
 
+// isExcludedPath returns whether the path is one of the excluded paths, or one of their sub-paths
+func (l *LogHandler) isExcludedPath(path string) bool {
+	for _, excludePath := range l.excludePaths {
+		if path == excludePath || strings.HasPrefix(path, excludePath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// keepAccessLog returns whether the request matches the status codes or the minimum duration filters, if any
+func (l *LogHandler) keepAccessLog(statusCode int, duration time.Duration) bool {
+	if !l.hasKeepingFilters {
+		return true
+	}
+	if l.httpCodeRanges.Contains(statusCode) {
+		return true
+	}
+	return l.minDuration > 0 && duration >= l.minDuration
+}
+
 // Close closes the Logger (i.e. the file etc).
 func (l *LogHandler) Close() error {
+	if l.syslogHook != nil {
+		l.syslogHook.Close()
+	}
 	return l.file.Close()
 }
 
 // Rotate closes and reopens the log file to allow for rotation
-// by an external source.
+// by an external source. If the log isn't backed by a file then
+// it does nothing.
 func (l *LogHandler) Rotate() error {
-	var err error
-
-	if l.file != nil {
-		defer func(f *os.File) {
-			f.Close()
-		}(l.file)
+	if len(l.filePath) == 0 {
+		return nil
 	}
 
-	l.file, err = os.OpenFile(l.filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0664)
+	file, err := openAccessLogFile(l.filePath)
 	if err != nil {
+		// keep on writing to the current file
 		return err
 	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.file != nil {
+		defer func(f *os.File) {
+			f.Close()
+		}(l.file)
+	}
+	l.file = file
 	l.logger.Out = l.file
 	return nil
 }
@@ -195,6 +277,9 @@ func (l *LogHandler) logTheRoundTrip(logDataTable *LogData, crr *captureRequestR
 	if crr != nil {
 		core[RequestContentSize] = crr.count
 	}
+	if requestID := logDataTable.Request.Get("X-Request-Id"); requestID != "" {
+		core[RequestID] = requestID
+	}
 
 	core[DownstreamStatus] = crw.Status()
 	core[DownstreamStatusLine] = fmt.Sprintf("%03d %s", crw.Status(), http.StatusText(crw.Status()))
@@ -215,6 +300,10 @@ func (l *LogHandler) logTheRoundTrip(logDataTable *LogData, crr *captureRequestR
 		core[Overhead] = total
 	}
 
+	if !l.keepAccessLog(crw.Status(), total) {
+		return
+	}
+
 	fields := logrus.Fields{}
 
 	for k, v := range logDataTable.Core {
@@ -3,6 +3,8 @@ package accesslog
 import (
 	"bytes"
 	"fmt"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -43,6 +45,125 @@ func (f *CommonLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	return b.Bytes(), err
 }
 
+// CombinedLogFormatter provides formatting in the Combined Log Format
+type CombinedLogFormatter struct{}
+
+// Format formats the log entry in the Combined Log Format, with the status and the size of the response sent to the client
+func (f *CombinedLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b := &bytes.Buffer{}
+
+	timestamp := entry.Data[StartUTC].(time.Time).Format(commonLogTimeFormat)
+	size := toLog(entry.Data[DownstreamContentSize])
+	if size == int64(0) {
+		size = defaultValue
+	}
+
+	_, err := fmt.Fprintf(b, "%s - %s [%s] \"%s %s %s\" %v %v %s %s\n",
+		entry.Data[ClientHost],
+		entry.Data[ClientUsername],
+		timestamp,
+		entry.Data[RequestMethod],
+		entry.Data[RequestPath],
+		entry.Data[RequestProtocol],
+		toLog(entry.Data[DownstreamStatus]),
+		size,
+		quoted(toString(entry.Data["request_Referer"]), `"-"`),
+		quoted(toString(entry.Data["request_User-Agent"]), `"-"`))
+
+	return b.Bytes(), err
+}
+
+// TemplateLogFormatter provides formatting with a Go template, executed on the fields of the log entry
+// e.g. {{.ClientHost}}, {{.DownstreamStatus}}, or {{index . "request_User-Agent"}} for the headers.
+type TemplateLogFormatter struct {
+	template *template.Template
+}
+
+// NewTemplateLogFormatter parses the template of the access log lines
+func NewTemplateLogFormatter(text string) (*TemplateLogFormatter, error) {
+	if len(strings.TrimSpace(text)) == 0 {
+		return nil, fmt.Errorf("the %s access log format requires a template", TemplateFormat)
+	}
+	tmpl, err := template.New("accesslog").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access log template: %v", err)
+	}
+	return &TemplateLogFormatter{template: tmpl}, nil
+}
+
+// Format formats the log entry with the template, the missing core fields being written as "-"
+// and the missing headers as empty strings.
+func (f *TemplateLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(map[string]string, len(allCoreKeys)+len(entry.Data))
+	for k := range allCoreKeys {
+		data[k] = defaultValue
+	}
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case nil:
+		case time.Time:
+			data[k] = value.Format(commonLogTimeFormat)
+		case time.Duration:
+			data[k] = fmt.Sprintf("%dms", value.Nanoseconds()/1000000)
+		default:
+			if s := toString(value); len(s) > 0 || !isCoreKey(k) {
+				data[k] = s
+			}
+		}
+	}
+
+	b := &bytes.Buffer{}
+	if err := f.template.Execute(b, data); err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+func isCoreKey(key string) bool {
+	_, ok := allCoreKeys[key]
+	return ok
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// JSONLogFormatter provides formatting of each request as a JSON object on a single line
+type JSONLogFormatter struct {
+	logrus.JSONFormatter
+}
+
+// Format formats the log entry in JSON, the values such as the backend URL being written as strings
+func (f *JSONLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case time.Time, time.Duration:
+			data[k] = value
+		case fmt.Stringer:
+			data[k] = value.String()
+		default:
+			data[k] = value
+		}
+	}
+
+	jsonEntry := *entry
+	jsonEntry.Data = data
+	return f.JSONFormatter.Format(&jsonEntry)
+}
+
 func toLog(v interface{}) interface{} {
 	if v == nil {
 		return defaultValue
@@ -1,12 +1,16 @@
 package accesslog
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCommonLogFormatter_Format(t *testing.T) {
@@ -112,3 +116,162 @@ func Test_toLog(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONLogFormatter_Format(t *testing.T) {
+	jlf := JSONLogFormatter{}
+
+	entry := &logrus.Entry{
+		Time: time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
+		Data: map[string]interface{}{
+			StartUTC:             time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
+			Duration:             123 * time.Millisecond,
+			ClientHost:           "10.0.0.1",
+			DownstreamStatus:     http.StatusOK,
+			FrontendName:         "foo",
+			BackendURL:           &url.URL{Scheme: "http", Host: "10.0.0.2", Path: "/toto"},
+			"request_User-Agent": "agent",
+		},
+	}
+
+	log, err := jlf.Format(entry)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasSuffix(string(log), "}\n"))
+	assert.Equal(t, 1, strings.Count(string(log), "\n"))
+
+	jsonData := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(log, &jsonData))
+	assert.Equal(t, "http://10.0.0.2/toto", jsonData[BackendURL])
+	assert.Equal(t, float64(123*time.Millisecond), jsonData[Duration])
+	assert.Equal(t, "2009-11-10T23:00:00Z", jsonData[StartUTC])
+	assert.Equal(t, "10.0.0.1", jsonData[ClientHost])
+	assert.Equal(t, float64(http.StatusOK), jsonData[DownstreamStatus])
+	assert.Equal(t, "foo", jsonData[FrontendName])
+	assert.Equal(t, "agent", jsonData["request_User-Agent"])
+	// the original entry is kept as is
+	assert.IsType(t, &url.URL{}, entry.Data[BackendURL])
+}
+
+func TestCombinedLogFormatter_Format(t *testing.T) {
+	clf := CombinedLogFormatter{}
+
+	testCases := []struct {
+		name        string
+		data        map[string]interface{}
+		expectedLog string
+	}{
+		{
+			name: "without referer and user agent",
+			data: map[string]interface{}{
+				StartUTC:              time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
+				ClientHost:            "10.0.0.1",
+				ClientUsername:        "-",
+				RequestMethod:         http.MethodGet,
+				RequestPath:           "/foo",
+				RequestProtocol:       "HTTP/1.1",
+				DownstreamStatus:      304,
+				DownstreamContentSize: int64(0),
+			},
+			expectedLog: `10.0.0.1 - - [10/Nov/2009:23:00:00 +0000] "GET /foo HTTP/1.1" 304 - "-" "-"
+`,
+		},
+		{
+			name: "all data",
+			data: map[string]interface{}{
+				StartUTC:              time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
+				ClientHost:            "10.0.0.1",
+				ClientUsername:        "Client",
+				RequestMethod:         http.MethodGet,
+				RequestPath:           "/foo",
+				RequestProtocol:       "HTTP/1.1",
+				DownstreamStatus:      200,
+				DownstreamContentSize: int64(132),
+				"request_Referer":     "http://referer/",
+				"request_User-Agent":  "agent",
+			},
+			expectedLog: `10.0.0.1 - Client [10/Nov/2009:23:00:00 +0000] "GET /foo HTTP/1.1" 200 132 "http://referer/" "agent"
+`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry := &logrus.Entry{Data: test.data}
+
+			raw, err := clf.Format(entry)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedLog, string(raw))
+		})
+	}
+}
+
+func TestTemplateLogFormatter_Format(t *testing.T) {
+	testCases := []struct {
+		name        string
+		template    string
+		data        map[string]interface{}
+		expectedLog string
+	}{
+		{
+			name:     "core fields",
+			template: `{{.ClientHost}} [{{.StartUTC}}] "{{.RequestMethod}} {{.RequestPath}}" {{.DownstreamStatus}} {{.Duration}} {{.BackendURL}}`,
+			data: map[string]interface{}{
+				StartUTC:         time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
+				Duration:         123 * time.Millisecond,
+				ClientHost:       "10.0.0.1",
+				RequestMethod:    http.MethodGet,
+				RequestPath:      "/foo",
+				DownstreamStatus: 200,
+				BackendURL:       &url.URL{Scheme: "http", Host: "10.0.0.2", Path: "/foo"},
+			},
+			expectedLog: `10.0.0.1 [10/Nov/2009:23:00:00 +0000] "GET /foo" 200 123ms http://10.0.0.2/foo
+`,
+		},
+		{
+			name:     "headers",
+			template: `"{{index . "request_User-Agent"}}" "{{index . "request_X-Custom"}}" "{{index . "downstream_Content-Type"}}"` + "\n",
+			data: map[string]interface{}{
+				"request_User-Agent":      "agent",
+				"downstream_Content-Type": "text/plain",
+			},
+			expectedLog: `"agent" "" "text/plain"
+`,
+		},
+		{
+			name:     "missing core fields",
+			template: `{{.FrontendName}} {{.BackendName}} {{.OriginStatus}}`,
+			data: map[string]interface{}{
+				FrontendName: "",
+			},
+			expectedLog: `- - -
+`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			formatter, err := NewTemplateLogFormatter(test.template)
+			require.NoError(t, err)
+
+			raw, err := formatter.Format(&logrus.Entry{Data: test.data})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedLog, string(raw))
+		})
+	}
+}
+
+func TestNewTemplateLogFormatterWithInvalidTemplate(t *testing.T) {
+	_, err := NewTemplateLogFormatter("")
+	assert.Error(t, err)
+
+	_, err = NewTemplateLogFormatter("{{.ClientHost")
+	assert.Error(t, err)
+}
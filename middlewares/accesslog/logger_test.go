@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -14,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/containous/flaeg"
 	"github.com/containous/traefik/types"
 	shellwords "github.com/mattn/go-shellwords"
 	"github.com/stretchr/testify/assert"
@@ -244,6 +246,24 @@ func TestLoggerJSON(t *testing.T) {
 	assert.Equal(t, len(jsonData), assertCount, string(logData))
 }
 
+func TestLoggerTemplate(t *testing.T) {
+	tmpDir := createTempDir(t, TemplateFormat)
+	defer os.RemoveAll(tmpDir)
+
+	logFilePath := filepath.Join(tmpDir, logFileNameSuffix)
+	config := &types.AccessLog{
+		FilePath: logFilePath,
+		Format:   TemplateFormat,
+		Template: `{{.ClientHost}} "{{.FrontendName}}" {{.DownstreamStatus}} "{{index . "request_User-Agent"}}"`,
+	}
+	doLogging(t, config)
+
+	logData, err := ioutil.ReadFile(logFilePath)
+	require.NoError(t, err)
+
+	assert.Equal(t, fmt.Sprintf("%s %q %d %q\n", testHostname, testFrontendName, testStatus, testUserAgent), string(logData))
+}
+
 func TestNewLogHandlerOutputStdout(t *testing.T) {
 	file, restoreStdout := captureStdout(t)
 	defer restoreStdout()
@@ -361,3 +381,195 @@ func logWriterTestHandlerFunc(rw http.ResponseWriter, r *http.Request) {
 	logDataTable.Core[OriginContentSize] = testContentSize
 	logDataTable.Core[RetryAttempts] = testRetryAttempts
 }
+
+func TestLogRotationWithStdout(t *testing.T) {
+	file, restoreStdout := captureStdout(t)
+	defer restoreStdout()
+	defer os.Remove(file.Name())
+
+	logHandler, err := NewLogHandler(&types.AccessLog{Format: CommonFormat})
+	require.NoError(t, err)
+
+	require.NoError(t, logHandler.Rotate())
+
+	logHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost", nil), func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	assert.Equal(t, 1, lineCount(t, file.Name()))
+}
+
+func TestLogRotationFailure(t *testing.T) {
+	tempDir := createTempDir(t, "rotation")
+	defer os.RemoveAll(tempDir)
+
+	fileName := filepath.Join(tempDir, "access", "traefik.log")
+	logHandler, err := NewLogHandler(&types.AccessLog{FilePath: fileName, Format: CommonFormat})
+	require.NoError(t, err)
+	defer logHandler.Close()
+
+	// the log directory can not be created anymore
+	require.NoError(t, os.Rename(filepath.Dir(fileName), filepath.Join(tempDir, "rotated")))
+	require.NoError(t, ioutil.WriteFile(filepath.Dir(fileName), nil, 0644))
+	assert.Error(t, logHandler.Rotate())
+
+	logHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost", nil), func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	assert.Equal(t, 1, lineCount(t, filepath.Join(tempDir, "rotated", "traefik.log")))
+}
+
+func TestLoggerFilters(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		filters       *types.AccessLogFilters
+		path          string
+		rewrittenPath string
+		status        int
+		duration      time.Duration
+		expectedLog   bool
+	}{
+		{
+			desc:        "no filters",
+			filters:     &types.AccessLogFilters{},
+			path:        "/foo",
+			status:      http.StatusOK,
+			expectedLog: true,
+		},
+		{
+			desc:        "status code in the ranges",
+			filters:     &types.AccessLogFilters{StatusCodes: types.StatusCodes{"200", "300-302"}},
+			path:        "/foo",
+			status:      http.StatusFound,
+			expectedLog: true,
+		},
+		{
+			desc:        "status code out of the ranges",
+			filters:     &types.AccessLogFilters{StatusCodes: types.StatusCodes{"200", "300-302"}},
+			path:        "/foo",
+			status:      http.StatusNotFound,
+			expectedLog: false,
+		},
+		{
+			desc:        "request slower than the min duration",
+			filters:     &types.AccessLogFilters{MinDuration: flaeg.Duration(10 * time.Millisecond)},
+			path:        "/foo",
+			status:      http.StatusOK,
+			duration:    20 * time.Millisecond,
+			expectedLog: true,
+		},
+		{
+			desc:        "request faster than the min duration",
+			filters:     &types.AccessLogFilters{MinDuration: flaeg.Duration(time.Hour)},
+			path:        "/foo",
+			status:      http.StatusOK,
+			expectedLog: false,
+		},
+		{
+			desc:        "slow request out of the status code ranges",
+			filters:     &types.AccessLogFilters{StatusCodes: types.StatusCodes{"500-599"}, MinDuration: flaeg.Duration(10 * time.Millisecond)},
+			path:        "/foo",
+			status:      http.StatusOK,
+			duration:    20 * time.Millisecond,
+			expectedLog: true,
+		},
+		{
+			desc:        "excluded path",
+			filters:     &types.AccessLogFilters{ExcludePaths: types.ExcludePaths{"/health"}},
+			path:        "/health",
+			status:      http.StatusOK,
+			expectedLog: false,
+		},
+		{
+			desc:        "sub-path of an excluded path",
+			filters:     &types.AccessLogFilters{ExcludePaths: types.ExcludePaths{"/health/"}},
+			path:        "/health/live",
+			status:      http.StatusOK,
+			expectedLog: false,
+		},
+		{
+			desc:        "path sharing the prefix of an excluded path",
+			filters:     &types.AccessLogFilters{ExcludePaths: types.ExcludePaths{"/health"}},
+			path:        "/healthz",
+			status:      http.StatusOK,
+			expectedLog: true,
+		},
+		{
+			desc:        "excluded path matching the status code ranges",
+			filters:     &types.AccessLogFilters{StatusCodes: types.StatusCodes{"200"}, ExcludePaths: types.ExcludePaths{"/health"}},
+			path:        "/health",
+			status:      http.StatusOK,
+			expectedLog: false,
+		},
+		{
+			desc:          "excluded path rewritten",
+			filters:       &types.AccessLogFilters{ExcludePaths: types.ExcludePaths{"/health"}},
+			path:          "/health",
+			rewrittenPath: "/",
+			status:        http.StatusOK,
+			expectedLog:   false,
+		},
+		{
+			desc:          "path rewritten to an excluded path",
+			filters:       &types.AccessLogFilters{ExcludePaths: types.ExcludePaths{"/health"}},
+			path:          "/foo",
+			rewrittenPath: "/health",
+			status:        http.StatusOK,
+			expectedLog:   true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := createTempDir(t, "filters")
+			defer os.RemoveAll(tmpDir)
+
+			logFilePath := filepath.Join(tmpDir, "access.log")
+			logHandler, err := NewLogHandler(&types.AccessLog{FilePath: logFilePath, Format: CommonFormat, Filters: test.filters})
+			require.NoError(t, err)
+
+			logHandler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost"+test.path, nil), func(rw http.ResponseWriter, req *http.Request) {
+				if len(test.rewrittenPath) > 0 {
+					req.URL.Path = test.rewrittenPath
+				}
+				time.Sleep(test.duration)
+				rw.WriteHeader(test.status)
+			})
+			require.NoError(t, logHandler.Close())
+
+			logData, err := ioutil.ReadFile(logFilePath)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedLog, len(logData) > 0)
+		})
+	}
+}
+
+func TestLoggerSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	config := &types.AccessLog{
+		Format: CommonFormat,
+		Syslog: &types.Syslog{Network: "udp", Address: conn.LocalAddr().String(), Facility: "local0"},
+	}
+	doLogging(t, config)
+
+	buffer := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buffer)
+	require.NoError(t, err)
+
+	message := string(buffer[:n])
+	assert.True(t, strings.HasPrefix(message, "<134>1 "), message)
+	parts := strings.SplitN(message, " - - ", 2)
+	require.Len(t, parts, 2)
+	assertValidLogData(t, []byte(parts[1]+"\n"))
+}
+
+func TestNewLogHandlerInvalidStatusCodes(t *testing.T) {
+	_, err := NewLogHandler(&types.AccessLog{Format: CommonFormat, Filters: &types.AccessLogFilters{StatusCodes: types.StatusCodes{"foo"}}})
+	assert.Error(t, err)
+}
@@ -4,14 +4,14 @@ import (
 	"net/http"
 )
 
-// AddPrefix is a middleware used to add prefix to an URL request
+// AddPrefix is a middleware used to add prefix to an URL request, the prefix being able to reference the route variables
 type AddPrefix struct {
 	Handler http.Handler
 	Prefix  string
 }
 
 func (s *AddPrefix) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	r.URL.Path = s.Prefix + r.URL.Path
+	r.URL.Path = ExpandRouteVariables(s.Prefix, r) + r.URL.Path
 	r.RequestURI = r.URL.RequestURI()
 	s.Handler.ServeHTTP(w, r)
 }
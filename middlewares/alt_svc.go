@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AltSvc is a middleware advertising the HTTP/3 listener of the entry point in the responses to the HTTP/1 and HTTP/2
+// requests, for the clients to switch to it (RFC 7838)
+type AltSvc struct {
+	value string
+}
+
+// NewAltSvc returns a new AltSvc instance advertising HTTP/3 on the UDP port
+func NewAltSvc(port int) *AltSvc {
+	return &AltSvc{value: fmt.Sprintf(`h3=":%d"; ma=86400`, port)}
+}
+
+func (a *AltSvc) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.ProtoMajor < 3 {
+		rw.Header().Set("Alt-Svc", a.value)
+	}
+	next.ServeHTTP(rw, r)
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAltSvc(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		protoMajor int
+		expected   string
+	}{
+		{
+			desc:       "HTTP/1.1 request",
+			protoMajor: 1,
+			expected:   `h3=":8443"; ma=86400`,
+		},
+		{
+			desc:       "HTTP/2 request",
+			protoMajor: 2,
+			expected:   `h3=":8443"; ma=86400`,
+		},
+		{
+			desc:       "HTTP/3 request",
+			protoMajor: 3,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "https://foo.com/", nil)
+			req.ProtoMajor = test.protoMajor
+
+			called := false
+			NewAltSvc(8443).ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+
+			assert.True(t, called)
+			assert.Equal(t, test.expected, recorder.Header().Get("Alt-Svc"))
+		})
+	}
+}
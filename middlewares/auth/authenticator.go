@@ -12,7 +12,7 @@ import (
 	"github.com/urfave/negroni"
 )
 
-// Authenticator is a middleware that provides HTTP basic and digest authentication
+// Authenticator is a middleware that provides HTTP basic, digest, forward, JWT and OIDC authentication
 type Authenticator struct {
 	handler negroni.Handler
 	users   map[string]string
@@ -65,6 +65,20 @@ func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
 		authenticator.handler = negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 			Forward(authConfig.Forward, w, r, next)
 		})
+	} else if authConfig.JWT != nil {
+		validator, err := newJWTValidator(authConfig.JWT, authConfig.HeaderField)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.handler = validator
+	} else if authConfig.OIDC != nil {
+		oidcAuthenticator, err := newOIDCAuthenticator(authConfig.OIDC, authConfig.HeaderField)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.handler = oidcAuthenticator
+	} else {
+		return nil, fmt.Errorf("Error creating Authenticator: no basic, digest, forward, JWT or OIDC authentication")
 	}
 	return &authenticator, nil
 }
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/md5"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"testing"
 
+	goauth "github.com/abbot/go-http-auth"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/containous/traefik/types"
 	"github.com/stretchr/testify/assert"
@@ -156,6 +158,50 @@ func TestDigestAuthFail(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "they should be equal")
 }
 
+func TestDigestAuthSuccess(t *testing.T) {
+	ha1 := fmt.Sprintf("%x", md5.Sum([]byte("test:traefik:test")))
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Digest: &types.Digest{
+			Users: []string{"test:traefik:" + ha1},
+		},
+		HeaderField: "X-Webauth-User",
+	})
+	assert.NoError(t, err, "there should be no error")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, r.Header.Get("X-Webauth-User"))
+	})
+	n := negroni.New(authMiddleware)
+	n.UseHandler(handler)
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	client := &http.Client{}
+	res, err := client.Do(testhelpers.MustNewRequest(http.MethodGet, ts.URL+"/", nil))
+	assert.NoError(t, err, "there should be no error")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "they should be equal")
+
+	challenge := goauth.DigestAuthParams(res.Header.Get("WWW-Authenticate"))
+	ha2 := fmt.Sprintf("%x", md5.Sum([]byte("GET:/")))
+	response := fmt.Sprintf("%x", md5.Sum([]byte(ha1+":"+challenge["nonce"]+":00000001:cnonce:auth:"+ha2)))
+
+	req := testhelpers.MustNewRequest(http.MethodGet, ts.URL+"/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf(`Digest username="test", realm="traefik", nonce="%s", uri="/", qop=auth, nc=00000001, cnonce="cnonce", response="%s", opaque="%s", algorithm="MD5"`,
+		challenge["nonce"], response, challenge["opaque"]))
+	res, err = client.Do(req)
+	assert.NoError(t, err, "there should be no error")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "they should be equal")
+
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err, "there should be no error")
+	assert.Equal(t, "test\n", string(body), "they should be equal")
+}
+
+func TestNewAuthenticatorWithoutAuthentication(t *testing.T) {
+	_, err := NewAuthenticator(&types.Auth{HeaderField: "X-Webauth-User"})
+	assert.Error(t, err)
+}
+
 func TestBasicAuthUserHeader(t *testing.T) {
 	middleware, err := NewAuthenticator(&types.Auth{
 		Basic: &types.Basic{
@@ -14,7 +14,12 @@ import (
 
 // Forward the authentication to a external server
 func Forward(config *types.Forward, w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	httpClient := http.Client{}
+	// Ensure our request client does not follow redirects
+	httpClient := http.Client{
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
 
 	if config.TLS != nil {
 		tlsConfig, err := config.TLS.CreateTLSConfig()
@@ -54,11 +59,22 @@ func Forward(config *types.Forward, w http.ResponseWriter, r *http.Request, next
 
 	if forwardResponse.StatusCode < http.StatusOK || forwardResponse.StatusCode >= http.StatusMultipleChoices {
 		log.Debugf("Remote error %s. StatusCode: %d", config.Address, forwardResponse.StatusCode)
+		utils.CopyHeaders(w.Header(), forwardResponse.Header)
+		utils.RemoveHeaders(w.Header(), forward.HopHeaders...)
+		w.Header().Del("Content-Length")
 		w.WriteHeader(forwardResponse.StatusCode)
 		w.Write(body)
 		return
 	}
 
+	for _, headerName := range config.AuthResponseHeaders {
+		if values, ok := forwardResponse.Header[http.CanonicalHeaderKey(headerName)]; ok {
+			r.Header[http.CanonicalHeaderKey(headerName)] = append([]string(nil), values...)
+		} else {
+			r.Header.Del(headerName)
+		}
+	}
+
 	r.RequestURI = r.URL.RequestURI()
 	next(w, r)
 }
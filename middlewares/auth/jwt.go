@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	jwt "github.com/dgrijalva/jwt-go"
+	jose "gopkg.in/square/go-jose.v1"
+)
+
+// jwksMinRefreshInterval is the minimum duration between two fetches of the JSON Web Key Set
+const jwksMinRefreshInterval = 10 * time.Second
+
+// jwtValidator validates the Bearer JSON Web Token of the requests
+type jwtValidator struct {
+	config      *types.JWT
+	headerField string
+	publicKey   interface{}
+	httpClient  *http.Client
+	mutex       sync.Mutex
+	jwks        *jose.JsonWebKeySet
+	jwksFetched time.Time  // the start of the last fetch of the JWKS
+	jwksFetch   *jwksFetch // the fetch in progress, nil if none
+}
+
+// jwksFetch is a fetch of the JSON Web Key Set, shared by the requests waiting for it
+type jwksFetch struct {
+	done chan struct{} // closed once the fetch is done
+	err  error
+}
+
+func newJWTValidator(config *types.JWT, headerField string) (*jwtValidator, error) {
+	if len(config.Secret) == 0 && len(config.PublicKey) == 0 && len(config.JWKSURL) == 0 {
+		return nil, fmt.Errorf("Error creating JWT Authenticator: a secret, a public key or a JWKS URL is required")
+	}
+
+	validator := &jwtValidator{
+		config:      config,
+		headerField: headerField,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if len(config.PublicKey) > 0 {
+		content := []byte(config.PublicKey)
+		if _, err := os.Stat(config.PublicKey); err == nil {
+			if content, err = ioutil.ReadFile(config.PublicKey); err != nil {
+				return nil, err
+			}
+		}
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(content); err == nil {
+			validator.publicKey = key
+		} else if key, err := jwt.ParseECPublicKeyFromPEM(content); err == nil {
+			validator.publicKey = key
+		} else {
+			return nil, fmt.Errorf("Error creating JWT Authenticator: invalid public key: %v", err)
+		}
+	}
+
+	return validator, nil
+}
+
+func (v *jwtValidator) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	claims, err := v.validate(r)
+	if err != nil {
+		log.Debugf("JWT auth failed: %v", err)
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	log.Debug("JWT auth success...")
+
+	forwardClaims(r, claims, v.headerField, v.config.ClaimsHeaders)
+	next.ServeHTTP(w, r)
+}
+
+// forwardClaims sets the subject in the headerField header, and the claims in their headers,
+// removing the headers of the missing claims, as well as the headerField header sent by the client
+func forwardClaims(r *http.Request, claims map[string]interface{}, headerField string, claimsHeaders map[string]string) {
+	if headerField != "" {
+		r.Header.Del(headerField)
+		if subject, ok := claims["sub"].(string); ok {
+			r.Header[headerField] = []string{subject}
+		}
+	}
+	for claim, header := range claimsHeaders {
+		if value, ok := claims[claim]; ok {
+			r.Header.Set(header, claimString(value))
+		} else {
+			r.Header.Del(header)
+		}
+	}
+}
+
+func (v *jwtValidator) validate(r *http.Request) (jwt.MapClaims, error) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errors.New("no Bearer token")
+	}
+
+	return v.validateToken(strings.TrimPrefix(authorization, "Bearer "))
+}
+
+// validateToken validates the signature, the validity period, the issuer and the audience of the token
+func (v *jwtValidator) validateToken(token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+	if len(v.config.Issuer) > 0 && !claims.VerifyIssuer(v.config.Issuer, true) {
+		return nil, fmt.Errorf("invalid issuer %v", claims["iss"])
+	}
+	if len(v.config.Audience) > 0 && !verifyAudience(claims["aud"], v.config.Audience) {
+		return nil, fmt.Errorf("invalid audience %v", claims["aud"])
+	}
+	return claims, nil
+}
+
+// key returns the key verifying the token, according to its signing method:
+// an HMAC secret is never mistaken for a public key
+func (v *jwtValidator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(v.config.Secret) == 0 {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return []byte(v.config.Secret), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err := v.publicKeyFor(token)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("no RSA key for signing method %s", token.Method.Alg())
+		}
+		return key, nil
+	case *jwt.SigningMethodECDSA:
+		key, err := v.publicKeyFor(token)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(*ecdsa.PublicKey); !ok {
+			return nil, fmt.Errorf("no ECDSA key for signing method %s", token.Method.Alg())
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// publicKeyFor returns the configured public key, or the JWKS key whose ID is the one of the token
+func (v *jwtValidator) publicKeyFor(token *jwt.Token) (interface{}, error) {
+	if len(v.config.JWKSURL) == 0 {
+		if v.publicKey == nil {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return v.publicKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	v.mutex.Lock()
+	if key := v.jwksKey(kid); key != nil {
+		v.mutex.Unlock()
+		return key, nil
+	}
+	// the keys may have been rotated: they are fetched again at most once per jwksMinRefreshInterval,
+	// the requests with unknown keys waiting for the fetch in progress, if any
+	fetch := v.jwksFetch
+	if fetch == nil {
+		if time.Since(v.jwksFetched) < jwksMinRefreshInterval {
+			v.mutex.Unlock()
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		fetch = &jwksFetch{done: make(chan struct{})}
+		v.jwksFetch = fetch
+		v.jwksFetched = time.Now()
+		v.mutex.Unlock()
+
+		// the other requests are not blocked while the keys are fetched
+		jwks, err := v.fetchJWKS()
+
+		v.mutex.Lock()
+		if err == nil {
+			v.jwks = jwks
+		}
+		v.jwksFetch = nil
+		fetch.err = err
+		close(fetch.done)
+	} else {
+		v.mutex.Unlock()
+		<-fetch.done
+		v.mutex.Lock()
+	}
+	defer v.mutex.Unlock()
+
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	if key := v.jwksKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// jwksKey returns the public key of the fetched JWKS having the given ID, v.mutex being held
+func (v *jwtValidator) jwksKey(kid string) interface{} {
+	if v.jwks == nil {
+		return nil
+	}
+	for _, key := range v.jwks.Keys {
+		if key.KeyID == kid && key.IsPublic() && key.Use != "enc" {
+			return key.Key
+		}
+	}
+	return nil
+}
+
+// fetchJWKS fetches the JSON Web Key Set
+func (v *jwtValidator) fetchJWKS() (*jose.JsonWebKeySet, error) {
+	resp, err := v.httpClient.Get(v.config.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JWKS %s: %v", v.config.JWKSURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching JWKS %s: status code %d", v.config.JWKSURL, resp.StatusCode)
+	}
+
+	jwks := &jose.JsonWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(jwks); err != nil {
+		return nil, fmt.Errorf("error decoding JWKS %s: %v", v.config.JWKSURL, err)
+	}
+	return jwks, nil
+}
+
+// verifyAudience checks the audience claim, a string or an array of strings, contains the expected audience
+func verifyAudience(aud interface{}, expected string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == expected
+	case []interface{}:
+		for _, a := range aud {
+			if a == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func claimString(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}:
+		values := make([]string, len(value))
+		for i, v := range value {
+			values[i] = claimString(v)
+		}
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+	jose "gopkg.in/square/go-jose.v1"
+)
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecPublicKey, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	ecPublicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPublicKey}))
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JsonWebKeySet{Keys: []jose.JsonWebKey{
+			{Key: &rsaKey.PublicKey, KeyID: "rsa1", Algorithm: "RS256", Use: "sig"},
+		}})
+	}))
+	defer jwksServer.Close()
+
+	validClaims := jwt.MapClaims{
+		"sub":    "user",
+		"iss":    "issuer",
+		"aud":    []string{"other", "traefik"},
+		"groups": []string{"admin", "dev"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+	sign := func(method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	testCases := []struct {
+		desc               string
+		config             *types.JWT
+		authorization      string
+		expectedStatusCode int
+	}{
+		{
+			desc:               "valid HMAC token",
+			config:             &types.JWT{Secret: "secret", Issuer: "issuer", Audience: "traefik"},
+			authorization:      "Bearer " + sign(jwt.SigningMethodHS256, []byte("secret"), "", validClaims),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "no token",
+			config:             &types.JWT{Secret: "secret"},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "wrong secret",
+			config:             &types.JWT{Secret: "secret"},
+			authorization:      "Bearer " + sign(jwt.SigningMethodHS256, []byte("other"), "", validClaims),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "expired token",
+			config:             &types.JWT{Secret: "secret"},
+			authorization:      "Bearer " + sign(jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "wrong issuer",
+			config:             &types.JWT{Secret: "secret", Issuer: "other"},
+			authorization:      "Bearer " + sign(jwt.SigningMethodHS256, []byte("secret"), "", validClaims),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "wrong audience",
+			config:             &types.JWT{Secret: "secret", Audience: "unknown"},
+			authorization:      "Bearer " + sign(jwt.SigningMethodHS256, []byte("secret"), "", validClaims),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "HMAC token signed with the public key",
+			config:             &types.JWT{PublicKey: ecPublicKeyPEM},
+			authorization:      "Bearer " + sign(jwt.SigningMethodHS256, []byte(ecPublicKeyPEM), "", validClaims),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "valid ECDSA token",
+			config:             &types.JWT{PublicKey: ecPublicKeyPEM},
+			authorization:      "Bearer " + sign(jwt.SigningMethodES256, ecKey, "", validClaims),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "valid RSA token from JWKS",
+			config:             &types.JWT{JWKSURL: jwksServer.URL},
+			authorization:      "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "rsa1", validClaims),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "unknown JWKS key",
+			config:             &types.JWT{JWKSURL: jwksServer.URL},
+			authorization:      "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "rsa2", validClaims),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			test.config.ClaimsHeaders = map[string]string{"groups": "X-Auth-Groups", "email": "X-Auth-Email"}
+			authMiddleware, err := NewAuthenticator(&types.Auth{JWT: test.config, HeaderField: "X-Webauth-User"})
+			require.NoError(t, err)
+
+			n := negroni.New(authMiddleware)
+			n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "user", r.Header.Get("X-Webauth-User"))
+				assert.Equal(t, "admin,dev", r.Header.Get("X-Auth-Groups"))
+				assert.Empty(t, r.Header.Get("X-Auth-Email"))
+			}))
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Auth-Email", "spoofed@example.com")
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			n.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer error="invalid_token"`, recorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestJWTAuthJWKSFetch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches int32
+	var rotated atomic.Value
+	rotated.Store(false)
+	release := make(chan struct{})
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		keys := []jose.JsonWebKey{{Key: &rsaKey.PublicKey, KeyID: "rsa1", Algorithm: "RS256", Use: "sig"}}
+		if rotated.Load().(bool) {
+			<-release
+			keys = append(keys, jose.JsonWebKey{Key: &rsaKey.PublicKey, KeyID: "rsa2", Algorithm: "RS256", Use: "sig"})
+		}
+		json.NewEncoder(w).Encode(jose.JsonWebKeySet{Keys: keys})
+	}))
+	defer jwksServer.Close()
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(rsaKey)
+		require.NoError(t, err)
+		return signed
+	}
+	rsa1Token, rsa2Token, rsa3Token := sign("rsa1"), sign("rsa2"), sign("rsa3")
+
+	validator, err := newJWTValidator(&types.JWT{JWKSURL: jwksServer.URL}, "")
+	require.NoError(t, err)
+	_, err = validator.validateToken(rsa1Token)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt32(&fetches))
+
+	// the keys are rotated after the minimum refresh interval
+	rotated.Store(true)
+	validator.mutex.Lock()
+	validator.jwksFetched = time.Time{}
+	validator.mutex.Unlock()
+
+	results := make(chan error, 10)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			_, err := validator.validateToken(rsa2Token)
+			results <- err
+		}()
+	}
+	for start := time.Now(); atomic.LoadInt32(&fetches) < 2; time.Sleep(10 * time.Millisecond) {
+		require.True(t, time.Since(start) < 5*time.Second, "JWKS not fetched again")
+	}
+
+	// the known keys are used while the JWKS is fetched
+	_, err = validator.validateToken(rsa1Token)
+	require.NoError(t, err)
+
+	close(release)
+	for i := 0; i < cap(results); i++ {
+		assert.NoError(t, <-results)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&fetches))
+
+	// the unknown keys do not fetch the JWKS more than once per minimum refresh interval
+	for i := 0; i < 3; i++ {
+		_, err = validator.validateToken(rsa3Token)
+		assert.Error(t, err)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&fetches))
+}
+
+func TestForwardClaims(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		claims          map[string]interface{}
+		expectedSubject string
+	}{
+		{
+			desc:            "subject",
+			claims:          map[string]interface{}{"sub": "user"},
+			expectedSubject: "user",
+		},
+		{
+			desc:   "no subject",
+			claims: map[string]interface{}{"groups": []string{"admin"}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Webauth-User", "spoofed")
+			forwardClaims(req, test.claims, "X-Webauth-User", nil)
+
+			assert.Equal(t, test.expectedSubject, req.Header.Get("X-Webauth-User"))
+		})
+	}
+}
+
+func TestJWTAuthConfiguration(t *testing.T) {
+	_, err := NewAuthenticator(&types.Auth{JWT: &types.JWT{}})
+	assert.Error(t, err)
+
+	_, err = NewAuthenticator(&types.Auth{JWT: &types.JWT{PublicKey: "invalid"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"golang.org/x/oauth2"
+)
+
+// DefaultOIDCCookieName is the default name of the OpenID Connect session cookie
+const DefaultOIDCCookieName = "_traefik_oidc"
+
+// oidcLoginTimeout is the maximum duration of a login at the issuer
+const oidcLoginTimeout = 10 * time.Minute
+
+// oidcAuthenticator logs the users in with the OpenID Connect authorization code flow,
+// and keeps their identity in a signed session cookie
+type oidcAuthenticator struct {
+	config       *types.OIDC
+	headerField  string
+	callbackPath string
+	cookieName   string
+	secure       bool
+	httpClient   *http.Client
+	mutex        sync.Mutex
+	oauth2Config *oauth2.Config
+	validator    *jwtValidator
+}
+
+// oidcDiscovery is the part of the OpenID Connect discovery document used by the authenticator
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcSession is the content of the session cookie
+type oidcSession struct {
+	Claims map[string]interface{} `json:"claims"`
+	Expiry int64                  `json:"exp"`
+}
+
+// subject returns the subject of the session, empty if it has none
+func (s *oidcSession) subject() string {
+	subject, _ := s.Claims["sub"].(string)
+	return subject
+}
+
+// oidcLogin is the content of the cookie set during the login at the issuer
+type oidcLogin struct {
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	RedirectURI string `json:"redirect_uri"`
+	Expiry      int64  `json:"login_exp"`
+}
+
+func newOIDCAuthenticator(config *types.OIDC, headerField string) (*oidcAuthenticator, error) {
+	if len(config.Issuer) == 0 || len(config.ClientID) == 0 || len(config.RedirectURL) == 0 || len(config.SessionSecret) == 0 {
+		return nil, fmt.Errorf("Error creating OIDC Authenticator: issuer, clientID, redirectURL and sessionSecret are required")
+	}
+	redirectURL, err := url.Parse(config.RedirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating OIDC Authenticator: invalid redirect URL %s: %v", config.RedirectURL, err)
+	}
+
+	cookieName := config.CookieName
+	if len(cookieName) == 0 {
+		cookieName = DefaultOIDCCookieName
+	}
+
+	return &oidcAuthenticator{
+		config:       config,
+		headerField:  headerField,
+		callbackPath: redirectURL.Path,
+		cookieName:   cookieName,
+		secure:       redirectURL.Scheme == "https",
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (a *oidcAuthenticator) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.URL.Path == a.callbackPath {
+		a.callback(w, r)
+		return
+	}
+
+	session := &oidcSession{}
+	if err := a.readCookie(r, a.cookieName, session); err == nil && time.Now().Unix() < session.Expiry && len(session.subject()) > 0 {
+		log.Debug("OIDC auth success...")
+		forwardClaims(r, session.Claims, a.headerField, a.config.ClaimsHeaders)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	a.login(w, r)
+}
+
+// login redirects the user to the issuer, the requests which cannot be redirected being rejected
+func (a *oidcAuthenticator) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	oauth2Config, _, err := a.provider()
+	if err != nil {
+		log.Errorf("Error discovering OIDC issuer %s: %v", a.config.Issuer, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	login := &oidcLogin{
+		State:       randomString(),
+		Nonce:       randomString(),
+		RedirectURI: r.URL.RequestURI(),
+		Expiry:      time.Now().Add(oidcLoginTimeout).Unix(),
+	}
+	if err := a.setCookie(w, a.cookieName+"_login", login, time.Unix(login.Expiry, 0)); err != nil {
+		log.Errorf("Error creating OIDC login cookie: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	log.Debug("OIDC auth required, redirecting to the issuer...")
+	http.Redirect(w, r, oauth2Config.AuthCodeURL(login.State, oauth2.SetAuthURLParam("nonce", login.Nonce)), http.StatusFound)
+}
+
+// callback exchanges the authorization code given by the issuer for an ID token, and opens the session
+func (a *oidcAuthenticator) callback(w http.ResponseWriter, r *http.Request) {
+	login := &oidcLogin{}
+	if err := a.readCookie(r, a.cookieName+"_login", login); err != nil || time.Now().Unix() >= login.Expiry {
+		log.Debugf("OIDC auth failed: no pending login")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("state") != login.State || len(query.Get("code")) == 0 {
+		log.Debugf("OIDC auth failed: invalid callback, error %q", query.Get("error"))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	oauth2Config, validator, err := a.provider()
+	if err != nil {
+		log.Errorf("Error discovering OIDC issuer %s: %v", a.config.Issuer, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	claims, err := a.exchange(r, oauth2Config, validator, query.Get("code"), login.Nonce)
+	if err != nil {
+		log.Debugf("OIDC auth failed: %v", err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if subject, _ := claims["sub"].(string); len(subject) == 0 {
+		log.Debugf("OIDC auth failed: no subject")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	session := &oidcSession{Claims: map[string]interface{}{"sub": claims["sub"]}}
+	for claim := range a.config.ClaimsHeaders {
+		if value, ok := claims[claim]; ok {
+			session.Claims[claim] = value
+		}
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		session.Expiry = int64(exp)
+	}
+	if err := a.setCookie(w, a.cookieName, session, time.Unix(session.Expiry, 0)); err != nil {
+		log.Errorf("Error creating OIDC session cookie: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: a.cookieName + "_login", Value: "", Path: "/", MaxAge: -1})
+
+	redirectURI := login.RedirectURI
+	if !strings.HasPrefix(redirectURI, "/") || strings.HasPrefix(redirectURI, "//") {
+		redirectURI = "/"
+	}
+	http.Redirect(w, r, redirectURI, http.StatusFound)
+}
+
+func (a *oidcAuthenticator) exchange(r *http.Request, oauth2Config *oauth2.Config, validator *jwtValidator, code, nonce string) (map[string]interface{}, error) {
+	token, err := oauth2Config.Exchange(r.Context(), code)
+	if err != nil {
+		return nil, err
+	}
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no ID token")
+	}
+	claims, err := validator.validateToken(idToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("invalid nonce")
+	}
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, errors.New("no expiration time")
+	}
+	return claims, nil
+}
+
+// provider returns the OAuth2 configuration and the ID token validator of the issuer, discovering them on first use
+func (a *oidcAuthenticator) provider() (*oauth2.Config, *jwtValidator, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.oauth2Config != nil {
+		return a.oauth2Config, a.validator, nil
+	}
+
+	resp, err := a.httpClient.Get(strings.TrimSuffix(a.config.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	discovery := &oidcDiscovery{}
+	if err := json.NewDecoder(resp.Body).Decode(discovery); err != nil {
+		return nil, nil, err
+	}
+
+	validator, err := newJWTValidator(&types.JWT{JWKSURL: discovery.JWKSURI, Issuer: discovery.Issuer, Audience: a.config.ClientID}, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	a.validator = validator
+	a.oauth2Config = &oauth2.Config{
+		ClientID:     a.config.ClientID,
+		ClientSecret: a.config.ClientSecret,
+		RedirectURL:  a.config.RedirectURL,
+		Scopes:       append([]string{"openid"}, a.config.Scopes...),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+	return a.oauth2Config, a.validator, nil
+}
+
+// setCookie sets a cookie holding the value, signed with the session secret along with the name of the cookie,
+// for the value of a cookie not to be accepted as the value of another one
+func (a *oidcAuthenticator) setCookie(w http.ResponseWriter, name string, value interface{}, expires time.Time) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(content)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    payload + "." + a.sign(name, payload),
+		Path:     "/",
+		Expires:  expires,
+		Secure:   a.secure,
+		HttpOnly: true,
+	})
+	return nil
+}
+
+// readCookie reads the value of a cookie, checking its signature
+func (a *oidcAuthenticator) readCookie(r *http.Request, name string, value interface{}) error {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(a.sign(name, parts[0]))) {
+		return errors.New("invalid cookie signature")
+	}
+	content, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, value)
+}
+
+func (a *oidcAuthenticator) sign(name, payload string) string {
+	mac := hmac.New(sha256.New, []byte(a.config.SessionSecret))
+	mac.Write([]byte(name + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("Error generating random string: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
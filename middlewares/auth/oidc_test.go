@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+	jose "gopkg.in/square/go-jose.v1"
+)
+
+func TestOIDCAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// the issuer gives an ID token for the nonce of the last authorization request
+	var nonce string
+	mux := http.NewServeMux()
+	issuer := httptest.NewServer(mux)
+	defer issuer.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			JWKSURI:               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JsonWebKeySet{Keys: []jose.JsonWebKey{
+			{Key: &key.PublicKey, KeyID: "key1", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   issuer.URL,
+			"aud":   "traefik",
+			"sub":   "user",
+			"email": "user@example.com",
+			"nonce": nonce,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "key1"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		OIDC: &types.OIDC{
+			Issuer:        issuer.URL,
+			ClientID:      "traefik",
+			ClientSecret:  "secret",
+			RedirectURL:   "http://app.example.com/_oidc/callback",
+			SessionSecret: "session secret",
+			ClaimsHeaders: map[string]string{"email": "X-Auth-Email"},
+		},
+		HeaderField: "X-Webauth-User",
+	})
+	require.NoError(t, err)
+
+	n := negroni.New(authMiddleware)
+	n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Webauth-User") + " " + r.Header.Get("X-Auth-Email")))
+	}))
+	serve := func(method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		n.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// the anonymous requests are redirected to the issuer, or rejected if they cannot be redirected
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "http://app.example.com/app").Code)
+
+	recorder := serve(http.MethodGet, "http://app.example.com/app?page=1")
+	require.Equal(t, http.StatusFound, recorder.Code)
+	authorizeURL, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, issuer.URL+"/authorize", authorizeURL.Scheme+"://"+authorizeURL.Host+authorizeURL.Path)
+	assert.Equal(t, "traefik", authorizeURL.Query().Get("client_id"))
+	assert.Equal(t, "openid", authorizeURL.Query().Get("scope"))
+	state := authorizeURL.Query().Get("state")
+	nonce = authorizeURL.Query().Get("nonce")
+	loginCookie := responseCookie(t, recorder, DefaultOIDCCookieName+"_login")
+
+	// the login cookie is not accepted as a session
+	replayed := &http.Cookie{Name: DefaultOIDCCookieName, Value: loginCookie.Value}
+	assert.Equal(t, http.StatusFound, serve(http.MethodGet, "http://app.example.com/app", replayed).Code)
+
+	// the callback checks the state
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "http://app.example.com/_oidc/callback?code=code&state=other", loginCookie).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "http://app.example.com/_oidc/callback?code=code&state="+state).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "http://app.example.com/_oidc/callback?code=invalid&state="+state, loginCookie).Code)
+
+	recorder = serve(http.MethodGet, "http://app.example.com/_oidc/callback?code=code&state="+state, loginCookie)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "/app?page=1", recorder.Header().Get("Location"))
+	sessionCookie := responseCookie(t, recorder, DefaultOIDCCookieName)
+	assert.True(t, sessionCookie.HttpOnly)
+
+	// the session identifies the user
+	recorder = serve(http.MethodGet, "http://app.example.com/app", sessionCookie)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "user user@example.com", recorder.Body.String())
+
+	// a tampered session is not trusted
+	sessionCookie.Value = "e30." + sessionCookie.Value[len(sessionCookie.Value)-10:]
+	assert.Equal(t, http.StatusFound, serve(http.MethodGet, "http://app.example.com/app", sessionCookie).Code)
+}
+
+func TestOIDCSessionWithoutSubject(t *testing.T) {
+	authenticator, err := newOIDCAuthenticator(&types.OIDC{
+		Issuer:        "https://idp.example.com",
+		ClientID:      "traefik",
+		RedirectURL:   "http://app.example.com/_oidc/callback",
+		SessionSecret: "session secret",
+	}, "")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc          string
+		claims        map[string]interface{}
+		expectedValid bool
+	}{
+		{
+			desc:          "subject",
+			claims:        map[string]interface{}{"sub": "user"},
+			expectedValid: true,
+		},
+		{
+			desc:   "empty subject",
+			claims: map[string]interface{}{"sub": ""},
+		},
+		{
+			desc: "no subject",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			expiry := time.Now().Add(time.Hour)
+			recorder := httptest.NewRecorder()
+			require.NoError(t, authenticator.setCookie(recorder, DefaultOIDCCookieName, &oidcSession{Claims: test.claims, Expiry: expiry.Unix()}, expiry))
+
+			req := httptest.NewRequest(http.MethodPost, "http://app.example.com/app", nil)
+			req.AddCookie(responseCookie(t, recorder, DefaultOIDCCookieName))
+			served := false
+			authenticator.ServeHTTP(httptest.NewRecorder(), req, func(w http.ResponseWriter, r *http.Request) {
+				served = true
+			})
+			assert.Equal(t, test.expectedValid, served)
+		})
+	}
+}
+
+func TestOIDCAuthConfiguration(t *testing.T) {
+	_, err := NewAuthenticator(&types.Auth{OIDC: &types.OIDC{Issuer: "https://idp.example.com", ClientID: "traefik"}})
+	assert.Error(t, err)
+}
+
+func responseCookie(t *testing.T, recorder *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	require.FailNow(t, "missing cookie "+name)
+	return nil
+}
@@ -0,0 +1,281 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/utils"
+)
+
+// Compile time validation bufferingResponseWriter implements http interfaces correctly.
+var (
+	_ Stateful = &bufferingResponseWriter{}
+)
+
+// DefaultBufferingMemBodyBytes is the default size of a body buffered in memory, the excess being buffered to disk
+const DefaultBufferingMemBodyBytes = 1 << 20
+
+var errResponseBodyTooLarge = errors.New("response body too large")
+
+// Buffering is a middleware reading the whole request before forwarding it, and the whole response before
+// sending it to the client.
+// The buffered request bodies can be replayed by the retries, and the backends are not held by slow clients.
+// The bodies bigger than the memory thresholds are buffered to temporary files, and the ones bigger than
+// the maximum sizes are rejected, a maximum size of 0 meaning no limit.
+type Buffering struct {
+	next                 http.Handler
+	memRequestBodyBytes  int64
+	maxRequestBodyBytes  int64
+	memResponseBodyBytes int64
+	maxResponseBodyBytes int64
+}
+
+// NewBuffering returns a new Buffering instance, a memory threshold of 0 meaning DefaultBufferingMemBodyBytes
+func NewBuffering(next http.Handler, memRequestBodyBytes, maxRequestBodyBytes, memResponseBodyBytes, maxResponseBodyBytes int64) (*Buffering, error) {
+	if memRequestBodyBytes < 0 || maxRequestBodyBytes < 0 || memResponseBodyBytes < 0 || maxResponseBodyBytes < 0 {
+		return nil, fmt.Errorf("negative buffering sizes %d, %d, %d, %d", memRequestBodyBytes, maxRequestBodyBytes, memResponseBodyBytes, maxResponseBodyBytes)
+	}
+	if memRequestBodyBytes == 0 {
+		memRequestBodyBytes = DefaultBufferingMemBodyBytes
+	}
+	if memResponseBodyBytes == 0 {
+		memResponseBodyBytes = DefaultBufferingMemBodyBytes
+	}
+
+	return &Buffering{
+		next:                 next,
+		memRequestBodyBytes:  memRequestBodyBytes,
+		maxRequestBodyBytes:  maxRequestBodyBytes,
+		memResponseBodyBytes: memResponseBodyBytes,
+		maxResponseBodyBytes: maxResponseBodyBytes,
+	}, nil
+}
+
+func (b *Buffering) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	// the upgraded connections, e.g. websockets, are streamed
+	if len(r.Header.Get("Upgrade")) > 0 {
+		b.next.ServeHTTP(rw, r)
+		return
+	}
+
+	if r.Body != nil {
+		if b.maxRequestBodyBytes > 0 && r.ContentLength > b.maxRequestBodyBytes {
+			log.Debugf("Request body of %d bytes bigger than %d bytes - rejecting", r.ContentLength, b.maxRequestBodyBytes)
+			http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		body := &bodyBuffer{memLimit: b.memRequestBodyBytes}
+		defer body.Close()
+
+		var reader io.Reader = r.Body
+		if b.maxRequestBodyBytes > 0 {
+			reader = io.LimitReader(r.Body, b.maxRequestBodyBytes+1)
+		}
+		_, err := io.Copy(body, reader)
+		r.Body.Close()
+		if err != nil {
+			log.Errorf("Error buffering the request body: %v", err)
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if b.maxRequestBodyBytes > 0 && body.size > b.maxRequestBodyBytes {
+			log.Debugf("Request body bigger than %d bytes - rejecting", b.maxRequestBodyBytes)
+			http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.Body = body
+		if body.size == 0 {
+			r.Body = http.NoBody
+		}
+		r.ContentLength = body.size
+		r.TransferEncoding = nil
+	}
+
+	recorder := &bufferingResponseWriter{
+		responseWriter: rw,
+		header:         make(http.Header),
+		code:           http.StatusOK,
+		body:           &bodyBuffer{memLimit: b.memResponseBodyBytes},
+		maxBodyBytes:   b.maxResponseBodyBytes,
+	}
+	defer recorder.body.Close()
+
+	b.next.ServeHTTP(recorder, r)
+
+	if recorder.hijacked {
+		return
+	}
+	if recorder.err != nil {
+		log.Errorf("Error buffering the response body: %v", recorder.err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	utils.CopyHeaders(rw.Header(), recorder.header)
+	if recorder.body.size > 0 {
+		rw.Header().Del("Transfer-Encoding")
+		rw.Header().Set("Content-Length", strconv.FormatInt(recorder.body.size, 10))
+	}
+	rw.WriteHeader(recorder.code)
+	if _, err := io.Copy(rw, recorder.body); err != nil {
+		log.Debugf("Error writing the buffered response: %v", err)
+	}
+}
+
+// bufferingResponseWriter buffers the response, until the handler returns
+type bufferingResponseWriter struct {
+	responseWriter http.ResponseWriter
+	header         http.Header
+	code           int
+	body           *bodyBuffer
+	maxBodyBytes   int64
+	err            error
+	hijacked       bool
+}
+
+func (rw *bufferingResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *bufferingResponseWriter) WriteHeader(code int) {
+	rw.code = code
+}
+
+func (rw *bufferingResponseWriter) Write(b []byte) (int, error) {
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	if rw.maxBodyBytes > 0 && rw.body.size+int64(len(b)) > rw.maxBodyBytes {
+		rw.err = errResponseBodyTooLarge
+		return 0, rw.err
+	}
+	n, err := rw.body.Write(b)
+	if err != nil {
+		rw.err = err
+	}
+	return n, err
+}
+
+// Hijack hijacks the connection
+func (rw *bufferingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.responseWriter.(http.Hijacker); ok {
+		conn, brw, err := h.Hijack()
+		if err == nil {
+			// the response is now handled by the owner of the connection
+			rw.hijacked = true
+		}
+		return conn, brw, err
+	}
+	// HTTP/2 response writers can not be hijacked
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.responseWriter)
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *bufferingResponseWriter) CloseNotify() <-chan bool {
+	if c, ok := rw.responseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush does nothing, the response being sent once complete.
+func (rw *bufferingResponseWriter) Flush() {}
+
+// bodyBuffer buffers a body in memory up to memLimit bytes, and in a temporary file beyond.
+// Once written, it can be read and rewound any number of times.
+type bodyBuffer struct {
+	memLimit int64
+	mem      bytes.Buffer
+	file     *os.File
+	size     int64
+	reader   io.ReadSeeker
+	closed   bool
+}
+
+func (b *bodyBuffer) Write(p []byte) (int, error) {
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+	if b.file == nil && b.size+int64(len(p)) > b.memLimit {
+		file, err := ioutil.TempFile("", "traefik-buffer-")
+		if err != nil {
+			return 0, err
+		}
+		b.file = file
+		if _, err := b.mem.WriteTo(file); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *bodyBuffer) Read(p []byte) (int, error) {
+	if err := b.initReader(); err != nil {
+		return 0, err
+	}
+	return b.reader.Read(p)
+}
+
+// Seek rewinds the body, before a replay of the request
+func (b *bodyBuffer) Seek(offset int64, whence int) (int64, error) {
+	if err := b.initReader(); err != nil {
+		return 0, err
+	}
+	return b.reader.Seek(offset, whence)
+}
+
+func (b *bodyBuffer) initReader() error {
+	if b.closed {
+		return os.ErrClosed
+	}
+	if b.reader != nil {
+		return nil
+	}
+	if b.file == nil {
+		b.reader = bytes.NewReader(b.mem.Bytes())
+		return nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	b.reader = b.file
+	return nil
+}
+
+// Close removes the temporary file, if any
+func (b *bodyBuffer) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if removeErr := os.Remove(b.file.Name()); removeErr != nil && !os.IsNotExist(removeErr) {
+		log.Errorf("Error removing buffer file %s: %v", b.file.Name(), removeErr)
+	}
+	return err
+}
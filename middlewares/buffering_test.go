@@ -0,0 +1,123 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffering(t *testing.T) {
+	testCases := []struct {
+		desc                 string
+		memRequestBodyBytes  int64
+		maxRequestBodyBytes  int64
+		memResponseBodyBytes int64
+		maxResponseBodyBytes int64
+		body                 string
+		response             string
+		expectedStatusCode   int
+	}{
+		{
+			desc:               "bodies buffered in memory",
+			body:               "request",
+			response:           "response",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:                 "bodies buffered to disk",
+			memRequestBodyBytes:  4,
+			memResponseBodyBytes: 4,
+			body:                 strings.Repeat("request", 100),
+			response:             strings.Repeat("response", 100),
+			expectedStatusCode:   http.StatusOK,
+		},
+		{
+			desc:                "request body too large",
+			memRequestBodyBytes: 4,
+			maxRequestBodyBytes: 10,
+			body:                "request body",
+			response:            "response",
+			expectedStatusCode:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:                 "response body too large",
+			memResponseBodyBytes: 4,
+			maxResponseBodyBytes: 10,
+			body:                 "request",
+			response:             "response body",
+			expectedStatusCode:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				assert.EqualValues(t, len(test.body), r.ContentLength)
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(body))
+
+				rw.Header().Set("X-Foo", "bar")
+				rw.WriteHeader(http.StatusOK)
+				for _, b := range []byte(test.response) {
+					rw.Write([]byte{b})
+				}
+			})
+			buffering, err := NewBuffering(next, test.memRequestBodyBytes, test.maxRequestBodyBytes, test.memResponseBodyBytes, test.maxResponseBodyBytes)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://foo.com/", ioutil.NopCloser(strings.NewReader(test.body)))
+			buffering.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, test.response, recorder.Body.String())
+				assert.Equal(t, "bar", recorder.Header().Get("X-Foo"))
+				assert.Equal(t, len(test.response), int(recorder.Result().ContentLength))
+			}
+		})
+	}
+}
+
+func TestBufferingReplaysRequestBody(t *testing.T) {
+	var bodies []string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if len(bodies) == 0 {
+			// the first backend fails in the middle of the request
+			partial := make([]byte, 4)
+			r.Body.Read(partial)
+			bodies = append(bodies, string(partial))
+			DefaultNetErrorRecorder{}.Record(r.Context())
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	buffering, err := NewBuffering(NewRetry(2, nil, false, next, &countingRetryListener{}), 4, 0, 0, 0)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://foo.com/", ioutil.NopCloser(strings.NewReader("request body")))
+	buffering.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{"requ", "request body"}, bodies)
+}
+
+func TestNewBufferingWithNegativeSize(t *testing.T) {
+	_, err := NewBuffering(http.NotFoundHandler(), 0, -1, 0, 0)
+	assert.Error(t, err)
+}
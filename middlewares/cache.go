@@ -0,0 +1,561 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/utils"
+)
+
+// Compile time validation cacheResponseWriter implements http interfaces correctly.
+var (
+	_ Stateful = &cacheResponseWriter{}
+)
+
+const (
+	// DefaultCacheMaxObjectBytes is the default maximum size of a cached response body
+	DefaultCacheMaxObjectBytes = 1 << 20
+	// DefaultCacheMaxSizeBytes is the default maximum size of the cached response bodies of a frontend
+	DefaultCacheMaxSizeBytes = 64 << 20
+	// CacheStatusHeader is the header telling whether a response was served from the cache
+	CacheStatusHeader = "X-Cache"
+)
+
+// cacheableStatusCodes are the status codes cacheable by default, according to RFC 7231 section 6.1
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// CacheRegistry holds the response caches of the frontends, by provider and frontend, so that they survive
+// the configuration reloads and can be purged through the API.
+type CacheRegistry struct {
+	mutex  sync.Mutex
+	caches map[string]*Cache
+}
+
+// NewCacheRegistry returns a new, empty, CacheRegistry
+func NewCacheRegistry() *CacheRegistry {
+	return &CacheRegistry{caches: make(map[string]*Cache)}
+}
+
+func cacheRegistryKey(providerName, frontendName string) string {
+	return providerName + "/" + frontendName
+}
+
+// Get returns the cache of a frontend of a provider, which is kept as long as its configuration does not change
+func (r *CacheRegistry) Get(providerName, frontendName string, config types.Cache) (*Cache, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := cacheRegistryKey(providerName, frontendName)
+	if cache, ok := r.caches[key]; ok && reflect.DeepEqual(cache.config, config) {
+		return cache, nil
+	}
+	cache, err := NewCache(config)
+	if err != nil {
+		return nil, err
+	}
+	r.caches[key] = cache
+	return cache, nil
+}
+
+// Retain removes the caches of the frontends which are not in the configurations anymore, or have no cache,
+// freeing their responses
+func (r *CacheRegistry) Retain(configurations types.Configurations) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	retained := make(map[string]bool)
+	for providerName, config := range configurations {
+		for frontendName, frontend := range config.Frontends {
+			if frontend.Cache != nil {
+				retained[cacheRegistryKey(providerName, frontendName)] = true
+			}
+		}
+	}
+	for key := range r.caches {
+		if !retained[key] {
+			delete(r.caches, key)
+		}
+	}
+}
+
+// Purge removes the cached responses of a frontend of a provider whose path starts with pathPrefix,
+// returning their number, and false if the frontend has no cache.
+func (r *CacheRegistry) Purge(providerName, frontendName, pathPrefix string) (int, bool) {
+	r.mutex.Lock()
+	cache, ok := r.caches[cacheRegistryKey(providerName, frontendName)]
+	r.mutex.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return cache.Purge(pathPrefix), true
+}
+
+// Cache is an in-memory shared cache of the responses of a frontend, following RFC 7234.
+// Only the GET and HEAD responses with an explicit freshness lifetime are cached, without any revalidation,
+// and the least recently used ones are evicted once the cache is full.
+type Cache struct {
+	config         types.Cache
+	ttl            time.Duration
+	maxObjectBytes int64
+	maxSizeBytes   int64
+
+	mutex    sync.Mutex
+	variants map[string]*cacheVariants
+	lru      *list.List
+	size     int64
+}
+
+// cacheVariants holds the cached responses of a request key, by the values of the request headers they vary on
+type cacheVariants struct {
+	varyNames []string
+	elements  map[string]*list.Element
+}
+
+type cacheEntry struct {
+	primaryKey string
+	key        string
+	host       string
+	path       string
+	code       int
+	header     http.Header
+	body       []byte
+	stored     time.Time
+	age        time.Duration
+	expires    time.Time
+}
+
+// NewCache returns a new Cache instance, a TTL overriding the freshness lifetime of the responses
+func NewCache(config types.Cache) (*Cache, error) {
+	cache := &Cache{
+		config:         config,
+		maxObjectBytes: config.MaxObjectBytes,
+		maxSizeBytes:   config.MaxSizeBytes,
+		variants:       make(map[string]*cacheVariants),
+		lru:            list.New(),
+	}
+	if config.TTL != "" {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache TTL %q: %v", config.TTL, err)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("invalid cache TTL %q: must be positive", config.TTL)
+		}
+		cache.ttl = ttl
+	}
+	if cache.maxObjectBytes < 0 || cache.maxSizeBytes < 0 {
+		return nil, fmt.Errorf("negative cache sizes %d, %d", cache.maxObjectBytes, cache.maxSizeBytes)
+	}
+	if cache.maxObjectBytes == 0 {
+		cache.maxObjectBytes = DefaultCacheMaxObjectBytes
+	}
+	if cache.maxSizeBytes == 0 {
+		cache.maxSizeBytes = DefaultCacheMaxSizeBytes
+	}
+	return cache, nil
+}
+
+// Handler returns a http.Handler serving the requests from the cache, and the cache misses from next
+func (c *Cache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c.serveHTTP(rw, r, next)
+	})
+}
+
+func (c *Cache) serveHTTP(rw http.ResponseWriter, r *http.Request, next http.Handler) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		recorder := &cacheResponseWriter{responseWriter: rw}
+		next.ServeHTTP(recorder, r)
+		// the successful unsafe requests invalidate the cached responses of their URL (RFC 7234 section 4.4)
+		if recorder.code < http.StatusBadRequest {
+			c.invalidate(r.Host, r.URL.Path)
+		}
+		return
+	}
+	if len(r.Header.Get("Upgrade")) > 0 {
+		next.ServeHTTP(rw, r)
+		return
+	}
+
+	requestDirectives := parseCacheControl(r.Header)
+	if _, ok := requestDirectives["no-store"]; ok {
+		next.ServeHTTP(rw, r)
+		return
+	}
+	if _, ok := requestDirectives["no-cache"]; !ok && strings.Contains(r.Header.Get("Pragma"), "no-cache") {
+		requestDirectives["no-cache"] = ""
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	primaryKey := r.Method + " " + scheme + "://" + r.Host + r.URL.RequestURI()
+	authorized := len(r.Header.Get("Authorization")) > 0
+	now := time.Now()
+
+	if _, noCache := requestDirectives["no-cache"]; !noCache && !authorized {
+		if entry := c.lookup(primaryKey, r, now); entry != nil && entry.acceptable(requestDirectives, now) {
+			log.Debugf("Serving %s from the cache", primaryKey)
+			entry.serve(rw, r, now)
+			return
+		}
+	}
+
+	var forwardErrorOccurred bool
+	recorder := &cacheResponseWriter{responseWriter: rw, maxBodyBytes: c.maxObjectBytes, recording: true}
+	next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), forwardErrorCtxKey{}, &forwardErrorOccurred)))
+	if !recorder.recording || forwardErrorOccurred || !recorder.complete(r.Method) {
+		return
+	}
+
+	lifetime, ok := c.lifetime(recorder.code, recorder.header, authorized, now)
+	if !ok {
+		return
+	}
+	varyNames, ok := parseVary(recorder.header)
+	if !ok {
+		return
+	}
+	age, _ := strconv.Atoi(recorder.header.Get("Age"))
+	entry := &cacheEntry{
+		primaryKey: primaryKey,
+		key:        secondaryKey(primaryKey, varyNames, r),
+		host:       r.Host,
+		path:       r.URL.Path,
+		code:       recorder.code,
+		header:     recorder.header,
+		body:       recorder.body.Bytes(),
+		stored:     now,
+		age:        time.Duration(age) * time.Second,
+		expires:    now.Add(lifetime - time.Duration(age)*time.Second),
+	}
+	if !entry.expires.After(now) {
+		return
+	}
+	c.store(varyNames, entry)
+}
+
+// lifetime returns the freshness lifetime of a response, and false if it can not be stored
+func (c *Cache) lifetime(code int, header http.Header, authorized bool, now time.Time) (time.Duration, bool) {
+	if !cacheableStatusCodes[code] || len(header.Get("Set-Cookie")) > 0 {
+		return 0, false
+	}
+	directives := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+	if _, ok := directives["public"]; authorized && !ok {
+		return 0, false
+	}
+
+	if c.ttl > 0 {
+		return c.ttl, true
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if expires := header.Get("Expires"); len(expires) > 0 {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, false
+		}
+		date := now
+		if dateTime, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = dateTime
+		}
+		return expiresTime.Sub(date), expiresTime.After(date)
+	}
+	return 0, false
+}
+
+func (c *Cache) lookup(primaryKey string, r *http.Request, now time.Time) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	variants, ok := c.variants[primaryKey]
+	if !ok {
+		return nil
+	}
+	element, ok := variants.elements[secondaryKey(primaryKey, variants.varyNames, r)]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.expires.After(now) {
+		c.remove(element)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+func (c *Cache) store(varyNames []string, entry *cacheEntry) {
+	size := entry.size()
+	if size > c.maxSizeBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	variants, ok := c.variants[entry.primaryKey]
+	if ok && !reflect.DeepEqual(variants.varyNames, varyNames) {
+		// the response now varies on other headers, the previous variants can not be selected anymore
+		for _, element := range variants.elements {
+			c.remove(element)
+		}
+		ok = false
+	}
+	if !ok {
+		variants = &cacheVariants{varyNames: varyNames, elements: make(map[string]*list.Element)}
+		c.variants[entry.primaryKey] = variants
+	}
+	if element, ok := variants.elements[entry.key]; ok {
+		c.remove(element)
+	}
+	variants.elements[entry.key] = c.lru.PushFront(entry)
+	c.size += size
+	for c.size > c.maxSizeBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Purge removes the cached responses whose path starts with pathPrefix, returning their number
+func (c *Cache) Purge(pathPrefix string) int {
+	return c.removeIf(func(entry *cacheEntry) bool {
+		return strings.HasPrefix(entry.path, pathPrefix)
+	})
+}
+
+func (c *Cache) invalidate(host, path string) {
+	c.removeIf(func(entry *cacheEntry) bool {
+		return entry.host == host && entry.path == path
+	})
+}
+
+func (c *Cache) removeIf(match func(*cacheEntry) bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var removed int
+	for element := c.lru.Front(); element != nil; {
+		nextElement := element.Next()
+		if match(element.Value.(*cacheEntry)) {
+			c.remove(element)
+			removed++
+		}
+		element = nextElement
+	}
+	return removed
+}
+
+func (c *Cache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	c.size -= entry.size()
+	if variants, ok := c.variants[entry.primaryKey]; ok {
+		delete(variants.elements, entry.key)
+		if len(variants.elements) == 0 {
+			delete(c.variants, entry.primaryKey)
+		}
+	}
+}
+
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.body))
+}
+
+func (e *cacheEntry) currentAge(now time.Time) time.Duration {
+	return e.age + now.Sub(e.stored)
+}
+
+// acceptable tells whether the entry satisfies the max-age directive of the request
+func (e *cacheEntry) acceptable(requestDirectives map[string]string, now time.Time) bool {
+	value, ok := requestDirectives["max-age"]
+	if !ok {
+		return true
+	}
+	seconds, err := strconv.Atoi(value)
+	return err == nil && e.currentAge(now) <= time.Duration(seconds)*time.Second
+}
+
+func (e *cacheEntry) serve(rw http.ResponseWriter, r *http.Request, now time.Time) {
+	utils.CopyHeaders(rw.Header(), e.header)
+	rw.Header().Set("Age", strconv.Itoa(int(e.currentAge(now).Seconds())))
+	rw.Header().Set(CacheStatusHeader, "HIT")
+	rw.WriteHeader(e.code)
+	if r.Method != http.MethodHead {
+		rw.Write(e.body)
+	}
+}
+
+// secondaryKey adds to the key of a request the values of the request headers the response varies on
+func secondaryKey(primaryKey string, varyNames []string, r *http.Request) string {
+	if len(varyNames) == 0 {
+		return primaryKey
+	}
+	key := primaryKey
+	for _, name := range varyNames {
+		key += "\n" + name + ": " + strings.Join(r.Header[name], ",")
+	}
+	return key
+}
+
+// parseVary returns the sorted canonical names of the Vary header, and false if the response varies on anything
+func parseVary(header http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if len(name) > 0 {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// parseCacheControl returns the directives of the Cache-Control header, by lower case name
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if len(directive) == 0 {
+				continue
+			}
+			name, argument := directive, ""
+			if index := strings.Index(directive, "="); index >= 0 {
+				name, argument = directive[:index], strings.Trim(directive[index+1:], `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = argument
+		}
+	}
+	return directives
+}
+
+// forwardErrorCtxKey is the key of the context value recording that the forwarding of a request failed
+type forwardErrorCtxKey struct{}
+
+// RecordForwardError records that forwarding a request to its backend failed, so that its response,
+// possibly sent partially, is not cached
+func RecordForwardError(ctx context.Context) {
+	if forwardErrorOccurred, ok := ctx.Value(forwardErrorCtxKey{}).(*bool); ok {
+		*forwardErrorOccurred = true
+	}
+}
+
+// cacheResponseWriter sends the response to the client while recording it, until it gets bigger than maxBodyBytes
+type cacheResponseWriter struct {
+	responseWriter http.ResponseWriter
+	header         http.Header
+	code           int
+	body           bytes.Buffer
+	maxBodyBytes   int64
+	recording      bool
+}
+
+func (rw *cacheResponseWriter) Header() http.Header {
+	return rw.responseWriter.Header()
+}
+
+func (rw *cacheResponseWriter) WriteHeader(code int) {
+	if rw.code != 0 {
+		return
+	}
+	rw.code = code
+	if rw.recording {
+		rw.header = make(http.Header)
+		utils.CopyHeaders(rw.header, rw.responseWriter.Header())
+		rw.responseWriter.Header().Set(CacheStatusHeader, "MISS")
+	}
+	rw.responseWriter.WriteHeader(code)
+}
+
+func (rw *cacheResponseWriter) Write(b []byte) (int, error) {
+	if rw.code == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.recording {
+		if int64(rw.body.Len()+len(b)) > rw.maxBodyBytes {
+			rw.recording = false
+			rw.body = bytes.Buffer{}
+		} else {
+			rw.body.Write(b)
+		}
+	}
+	return rw.responseWriter.Write(b)
+}
+
+// complete tells whether the recorded body is the whole response, of the length given by its Content-Length header
+func (rw *cacheResponseWriter) complete(method string) bool {
+	contentLength := rw.header.Get("Content-Length")
+	if len(contentLength) == 0 || method == http.MethodHead {
+		return true
+	}
+	length, err := strconv.ParseInt(contentLength, 10, 64)
+	return err == nil && length == int64(rw.body.Len())
+}
+
+// Hijack hijacks the connection
+func (rw *cacheResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw.recording = false
+	if h, ok := rw.responseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.responseWriter)
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *cacheResponseWriter) CloseNotify() <-chan bool {
+	if c, ok := rw.responseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client.
+func (rw *cacheResponseWriter) Flush() {
+	if f, ok := rw.responseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
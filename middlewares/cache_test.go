@@ -0,0 +1,322 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		config          types.Cache
+		method          string
+		requestHeaders  map[string]string
+		responseHeaders map[string]string
+		responseCode    int
+		forwardError    bool
+		expectedHits    bool
+	}{
+		{
+			desc:            "max-age",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+			expectedHits:    true,
+		},
+		{
+			desc:            "s-maxage",
+			responseHeaders: map[string]string{"Cache-Control": "s-maxage=60"},
+			expectedHits:    true,
+		},
+		{
+			desc:            "expires",
+			responseHeaders: map[string]string{"Expires": "Sun, 17 Jan 2100 00:00:00 GMT"},
+			expectedHits:    true,
+		},
+		{
+			desc:            "complete response",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60", "Content-Length": "8"},
+			expectedHits:    true,
+		},
+		{
+			desc:         "TTL override",
+			config:       types.Cache{TTL: "1m"},
+			expectedHits: true,
+		},
+		{
+			desc:            "HEAD request",
+			method:          http.MethodHead,
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+			expectedHits:    true,
+		},
+		{
+			desc:            "cacheable not found",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+			responseCode:    http.StatusNotFound,
+			expectedHits:    true,
+		},
+		{
+			desc:            "public authorized response",
+			requestHeaders:  map[string]string{"Authorization": "Basic Zm9vOmJhcg=="},
+			responseHeaders: map[string]string{"Cache-Control": "public, max-age=60"},
+		},
+		{
+			desc: "no freshness lifetime",
+		},
+		{
+			desc:            "expired response",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60", "Age": "120"},
+		},
+		{
+			desc:            "no-store response",
+			config:          types.Cache{TTL: "1m"},
+			responseHeaders: map[string]string{"Cache-Control": "no-store"},
+		},
+		{
+			desc:            "private response",
+			responseHeaders: map[string]string{"Cache-Control": "private, max-age=60"},
+		},
+		{
+			desc:            "response setting a cookie",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "foo=bar"},
+		},
+		{
+			desc:            "response varying on anything",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60", "Vary": "*"},
+		},
+		{
+			desc:            "not cacheable status code",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+			responseCode:    http.StatusInternalServerError,
+		},
+		{
+			desc:            "response too large",
+			config:          types.Cache{MaxObjectBytes: 4},
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+		},
+		{
+			desc:            "authorized request",
+			requestHeaders:  map[string]string{"Authorization": "Basic Zm9vOmJhcg=="},
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+		},
+		{
+			desc:            "no-cache request",
+			requestHeaders:  map[string]string{"Cache-Control": "no-cache"},
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+		},
+		{
+			desc:            "no-store request",
+			requestHeaders:  map[string]string{"Cache-Control": "no-store"},
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+		},
+		{
+			desc:            "truncated response",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60", "Content-Length": "16"},
+		},
+		{
+			desc:            "forwarding error",
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+			forwardError:    true,
+		},
+		{
+			desc:            "POST request",
+			method:          http.MethodPost,
+			responseHeaders: map[string]string{"Cache-Control": "max-age=60"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			if test.method == "" {
+				test.method = http.MethodGet
+			}
+			if test.responseCode == 0 {
+				test.responseCode = http.StatusOK
+			}
+
+			var calls int
+			next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				calls++
+				for name, value := range test.responseHeaders {
+					rw.Header().Set(name, value)
+				}
+				rw.WriteHeader(test.responseCode)
+				fmt.Fprint(rw, "response")
+				if test.forwardError {
+					RecordForwardError(r.Context())
+				}
+			})
+			cache, err := NewCache(test.config)
+			require.NoError(t, err)
+			handler := cache.Handler(next)
+
+			for i := 0; i < 2; i++ {
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(test.method, "http://foo.com/bar?baz=1", nil)
+				for name, value := range test.requestHeaders {
+					req.Header.Set(name, value)
+				}
+				handler.ServeHTTP(recorder, req)
+
+				assert.Equal(t, test.responseCode, recorder.Code)
+				if test.method != http.MethodHead {
+					assert.Equal(t, "response", recorder.Body.String())
+				}
+				if i == 1 && test.expectedHits {
+					assert.Equal(t, "HIT", recorder.Header().Get(CacheStatusHeader))
+					assert.NotEmpty(t, recorder.Header().Get("Age"))
+				}
+			}
+
+			if test.expectedHits {
+				assert.Equal(t, 1, calls)
+			} else {
+				assert.Equal(t, 2, calls)
+			}
+		})
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	var calls int
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Header().Set("Cache-Control", "max-age=60")
+		rw.Header().Set("Vary", "Accept-Language")
+		fmt.Fprint(rw, r.Header.Get("Accept-Language"))
+	})
+	cache, err := NewCache(types.Cache{})
+	require.NoError(t, err)
+	handler := cache.Handler(next)
+
+	for _, language := range []string{"en", "fr", "en", "fr"} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://foo.com/", nil)
+		req.Header.Set("Accept-Language", language)
+		handler.ServeHTTP(recorder, req)
+
+		assert.Equal(t, language, recorder.Body.String())
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, cache.lru.Len())
+}
+
+func TestCachePurge(t *testing.T) {
+	var calls int
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Header().Set("Cache-Control", "max-age=60")
+		rw.WriteHeader(http.StatusOK)
+	})
+	registry := NewCacheRegistry()
+	cache, err := registry.Get("file", "frontend", types.Cache{})
+	require.NoError(t, err)
+	handler := cache.Handler(next)
+
+	serve := func(method, url string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, url, nil))
+	}
+	serve(http.MethodGet, "http://foo.com/static/a")
+	serve(http.MethodGet, "http://foo.com/static/b")
+	serve(http.MethodGet, "http://foo.com/api/c")
+	assert.Equal(t, 3, calls)
+
+	purged, ok := registry.Purge("file", "frontend", "/static")
+	assert.True(t, ok)
+	assert.Equal(t, 2, purged)
+
+	serve(http.MethodGet, "http://foo.com/static/a")
+	serve(http.MethodGet, "http://foo.com/api/c")
+	assert.Equal(t, 4, calls)
+
+	// an unsafe request invalidates the cached responses of its URL
+	serve(http.MethodPut, "http://foo.com/api/c")
+	serve(http.MethodGet, "http://foo.com/api/c")
+	assert.Equal(t, 6, calls)
+
+	_, ok = registry.Purge("file", "unknown", "")
+	assert.False(t, ok)
+
+	// the cache is kept as long as the configuration does not change
+	sameCache, err := registry.Get("file", "frontend", types.Cache{})
+	require.NoError(t, err)
+	assert.True(t, cache == sameCache)
+	otherCache, err := registry.Get("file", "frontend", types.Cache{TTL: "1m"})
+	require.NoError(t, err)
+	assert.False(t, cache == otherCache)
+}
+
+func TestCacheScheme(t *testing.T) {
+	var calls int
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.Header().Set("Cache-Control", "max-age=60")
+		rw.WriteHeader(http.StatusOK)
+	})
+	cache, err := NewCache(types.Cache{})
+	require.NoError(t, err)
+	handler := cache.Handler(next)
+
+	for _, url := range []string{"http://foo.com/", "https://foo.com/", "http://foo.com/", "https://foo.com/"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheRegistryRetain(t *testing.T) {
+	registry := NewCacheRegistry()
+	for _, frontendName := range []string{"frontend", "removed", "uncached"} {
+		_, err := registry.Get("file", frontendName, types.Cache{})
+		require.NoError(t, err)
+	}
+	_, err := registry.Get("docker", "frontend", types.Cache{})
+	require.NoError(t, err)
+
+	registry.Retain(types.Configurations{
+		"file": &types.Configuration{Frontends: map[string]*types.Frontend{
+			"frontend": {Cache: &types.Cache{}},
+			"uncached": {},
+		}},
+	})
+
+	_, ok := registry.Purge("file", "frontend", "")
+	assert.True(t, ok)
+	for _, key := range [][2]string{{"file", "removed"}, {"file", "uncached"}, {"docker", "frontend"}} {
+		_, ok := registry.Purge(key[0], key[1], "")
+		assert.False(t, ok, "%s/%s", key[0], key[1])
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(rw, "0123456789")
+	})
+	cache, err := NewCache(types.Cache{MaxSizeBytes: 64})
+	require.NoError(t, err)
+	handler := cache.Handler(next)
+
+	for i := 0; i < 10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://foo.com/%d", i), nil))
+	}
+	assert.True(t, cache.size <= 64)
+	assert.Equal(t, 2, cache.lru.Len())
+}
+
+func TestNewCacheWithInvalidConfig(t *testing.T) {
+	_, err := NewCache(types.Cache{TTL: "foo"})
+	assert.Error(t, err)
+
+	_, err = NewCache(types.Cache{TTL: "-1s"})
+	assert.Error(t, err)
+
+	_, err = NewCache(types.Cache{MaxSizeBytes: -1})
+	assert.Error(t, err)
+}
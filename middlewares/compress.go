@@ -1,27 +1,278 @@
 package middlewares
 
 import (
+	"bufio"
 	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 
-	"github.com/NYTimes/gziphandler"
+	"github.com/containous/traefik/compress/brotli"
+	"github.com/containous/traefik/compress/zstd"
 	"github.com/containous/traefik/log"
 )
 
-// Compress is a middleware that allows redirection
-type Compress struct{}
+const (
+	// CompressMinSize is the size of the response bodies from which they are compressed
+	CompressMinSize = 512
 
-// ServerHTTP is a function used by Negroni
+	brotliEncoding = "br"
+	zstdEncoding   = "zstd"
+	gzipEncoding   = "gzip"
+)
+
+// DefaultCompressEncodings are the encodings of the compressed responses, in the order they are preferred in
+// when the clients accept several ones equally
+var DefaultCompressEncodings = []string{brotliEncoding, zstdEncoding, gzipEncoding}
+
+var defaultCompress, _ = NewCompress(nil, nil)
+
+// Compress is a middleware compressing the responses in the encoding the client prefers among the configured ones.
+// Its zero value compresses them in the default encodings, with their default levels.
+type Compress struct {
+	encoders []*compressEncoder
+}
+
+// compressWriter is a writer of a compressed format
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressEncoder holds the writers of an encoding, at the level it is configured with
+type compressEncoder struct {
+	name    string
+	writers sync.Pool
+}
+
+// NewCompress returns a middleware compressing the responses in the encodings, in the order they are preferred in,
+// with their levels, the encodings without level being compressed with their default one
+func NewCompress(encodings []string, levels map[string]int) (*Compress, error) {
+	if len(encodings) == 0 {
+		encodings = DefaultCompressEncodings
+	}
+	compress := &Compress{}
+	for _, encoding := range encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		newWriter, err := compressWriterFactory(encoding, levels[encoding])
+		if err != nil {
+			return nil, err
+		}
+		encoder := &compressEncoder{name: encoding}
+		encoder.writers.New = func() interface{} {
+			return newWriter()
+		}
+		compress.encoders = append(compress.encoders, encoder)
+	}
+	return compress, nil
+}
+
+// compressWriterFactory returns the function creating the writers of the encoding at the level, 0 being the default one
+func compressWriterFactory(encoding string, level int) (func() compressWriter, error) {
+	var newWriter func() (compressWriter, error)
+	switch encoding {
+	case brotliEncoding:
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		newWriter = func() (compressWriter, error) {
+			return brotli.NewWriterLevel(nil, level)
+		}
+	case zstdEncoding:
+		if level == 0 {
+			level = zstd.DefaultCompression
+		}
+		newWriter = func() (compressWriter, error) {
+			return zstd.NewWriterLevel(nil, level)
+		}
+	case gzipEncoding:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		newWriter = func() (compressWriter, error) {
+			return gzip.NewWriterLevel(nil, level)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression encoding %q", encoding)
+	}
+
+	// the level is checked once, the writers of the pools being created afterwards
+	if _, err := newWriter(); err != nil {
+		return nil, fmt.Errorf("invalid %s compression level %d", encoding, level)
+	}
+	return func() compressWriter {
+		w, _ := newWriter()
+		return w
+	}, nil
+}
+
+// ServeHTTP is a function used by Negroni
 func (c *Compress) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	gzipHandler(next).ServeHTTP(rw, r)
+	rw.Header().Add("Vary", "Accept-Encoding")
+
+	encoder := c.negotiate(r.Header.Get("Accept-Encoding"))
+	if encoder == nil {
+		next(rw, r)
+		return
+	}
+
+	crw := &compressResponseWriter{ResponseWriter: rw, encoder: encoder}
+	defer func() {
+		if err := crw.Close(); err != nil {
+			log.Debugf("Error while closing the %s response writer: %v", encoder.name, err)
+		}
+	}()
+	next(crw, r)
+}
+
+// negotiate returns the encoder of the encoding accepted by the client with the greatest quality value,
+// the first configured one among the ones accepted equally, or nil if the client accepts none
+func (c *Compress) negotiate(acceptEncoding string) *compressEncoder {
+	encoders := c.encoders
+	if len(encoders) == 0 {
+		encoders = defaultCompress.encoders
+	}
+	accepted := parseAcceptEncoding(acceptEncoding)
+
+	var negotiated *compressEncoder
+	negotiatedQuality := 0.0
+	for _, encoder := range encoders {
+		quality, ok := accepted[encoder.name]
+		if !ok {
+			quality = accepted["*"]
+		}
+		if quality > negotiatedQuality {
+			negotiated, negotiatedQuality = encoder, quality
+		}
+	}
+	return negotiated
+}
+
+// parseAcceptEncoding returns the quality values of the codings of the Accept-Encoding header, 1 by default,
+// the malformed ones being ignored
+func parseAcceptEncoding(acceptEncoding string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(coding, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(name) == 0 {
+			continue
+		}
+		quality := 1.0
+		for _, parameter := range parts[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if !strings.HasPrefix(parameter, "q=") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimPrefix(parameter, "q="), 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		accepted[name] = quality
+	}
+	return accepted
+}
+
+// compressResponseWriter compresses the response body once it reaches CompressMinSize, when it is not already encoded
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoder *compressEncoder
+	writer  compressWriter
+	code    int
+	buf     []byte
+}
+
+// WriteHeader saves the status code, written with the header of the compressed response or on Close
+func (rw *compressResponseWriter) WriteHeader(code int) {
+	rw.code = code
+}
+
+// Write compresses the body, buffered until it reaches CompressMinSize
+func (rw *compressResponseWriter) Write(b []byte) (int, error) {
+	if _, ok := rw.Header()["Content-Type"]; !ok {
+		rw.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	if rw.writer != nil {
+		return rw.writer.Write(b)
+	}
+
+	rw.buf = append(rw.buf, b...)
+	if len(rw.buf) >= CompressMinSize && len(rw.Header().Get("Content-Encoding")) == 0 {
+		if err := rw.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start writes the header of the compressed response, and the body buffered so far to the writer of the encoding
+func (rw *compressResponseWriter) start() error {
+	rw.Header().Set("Content-Encoding", rw.encoder.name)
+	rw.Header().Del("Content-Length")
+	if rw.code != 0 {
+		rw.ResponseWriter.WriteHeader(rw.code)
+	}
+
+	rw.writer = rw.encoder.writers.Get().(compressWriter)
+	rw.writer.Reset(rw.ResponseWriter)
+	n, err := rw.writer.Write(rw.buf)
+	if err == nil && n < len(rw.buf) {
+		err = io.ErrShortWrite
+	}
+	rw.buf = nil
+	return err
+}
+
+// Close writes the end of the compressed body, or the body as is when it was not compressed
+func (rw *compressResponseWriter) Close() error {
+	if rw.writer == nil {
+		if rw.code != 0 {
+			rw.ResponseWriter.WriteHeader(rw.code)
+		}
+		if rw.buf != nil {
+			_, err := rw.ResponseWriter.Write(rw.buf)
+			return err
+		}
+		return nil
+	}
+
+	err := rw.writer.Close()
+	rw.encoder.writers.Put(rw.writer)
+	rw.writer = nil
+	return err
+}
+
+// Flush writes the body compressed so far, for the client to decompress it
+func (rw *compressResponseWriter) Flush() {
+	if rw.writer != nil {
+		rw.writer.Flush()
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection
+func (rw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.ResponseWriter)
 }
 
-func gzipHandler(h http.Handler) http.Handler {
-	wrapper, err := gziphandler.GzipHandlerWithOpts(
-		gziphandler.CompressionLevel(gzip.DefaultCompression),
-		gziphandler.MinSize(gziphandler.DefaultMinSize))
-	if err != nil {
-		log.Error(err)
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *compressResponseWriter) CloseNotify() <-chan bool {
+	if c, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
 	}
-	return wrapper(h)
+	return nil
 }
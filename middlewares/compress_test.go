@@ -1,12 +1,12 @@
 package middlewares
 
 import (
+	"compress/gzip"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	"github.com/NYTimes/gziphandler"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,7 +26,7 @@ func TestShouldCompressWhenNoContentEncodingHeader(t *testing.T) {
 	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
 	req.Header.Add(acceptEncodingHeader, gzipValue)
 
-	baseBody := generateBytes(gziphandler.DefaultMinSize)
+	baseBody := generateBytes(CompressMinSize)
 	next := func(rw http.ResponseWriter, r *http.Request) {
 		rw.Write(baseBody)
 	}
@@ -48,7 +48,7 @@ func TestShouldNotCompressWhenContentEncodingHeader(t *testing.T) {
 	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
 	req.Header.Add(acceptEncodingHeader, gzipValue)
 
-	fakeCompressedBody := generateBytes(gziphandler.DefaultMinSize)
+	fakeCompressedBody := generateBytes(CompressMinSize)
 	next := func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Add(contentEncodingHeader, gzipValue)
 		rw.Header().Add(varyHeader, acceptEncodingHeader)
@@ -69,7 +69,7 @@ func TestShouldNotCompressWhenNoAcceptEncodingHeader(t *testing.T) {
 
 	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
 
-	fakeBody := generateBytes(gziphandler.DefaultMinSize)
+	fakeBody := generateBytes(CompressMinSize)
 	next := func(rw http.ResponseWriter, r *http.Request) {
 		rw.Write(fakeBody)
 	}
@@ -192,6 +192,138 @@ func TestIntegrationShouldCompress(t *testing.T) {
 	}
 }
 
+func TestCompressNegotiation(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		encodings        []string
+		acceptEncoding   string
+		expectedEncoding string
+	}{
+		{
+			desc:             "brotli preferred among the defaults",
+			acceptEncoding:   "gzip, deflate, br, zstd",
+			expectedEncoding: "br",
+		},
+		{
+			desc:             "zstd accepted alone",
+			acceptEncoding:   "zstd",
+			expectedEncoding: "zstd",
+		},
+		{
+			desc:             "greatest quality value",
+			acceptEncoding:   "br;q=0.5, zstd;q=0.8, gzip;q=0.9",
+			expectedEncoding: "gzip",
+		},
+		{
+			desc:             "configured order among equal quality values",
+			encodings:        []string{"zstd", "br"},
+			acceptEncoding:   "br, zstd",
+			expectedEncoding: "zstd",
+		},
+		{
+			desc:             "wildcard",
+			acceptEncoding:   "gzip;q=0.5, *",
+			expectedEncoding: "br",
+		},
+		{
+			desc:           "refused encodings",
+			acceptEncoding: "br;q=0, zstd;q=0, gzip;q=0",
+		},
+		{
+			desc:           "encoding not configured",
+			encodings:      []string{"gzip"},
+			acceptEncoding: "br",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewCompress(test.encodings, nil)
+			require.NoError(t, err)
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
+			req.Header.Add(acceptEncodingHeader, test.acceptEncoding)
+
+			baseBody := generateBytes(CompressMinSize)
+			next := func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write(baseBody)
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req, next)
+
+			assert.Equal(t, test.expectedEncoding, rw.Header().Get(contentEncodingHeader))
+			assert.Equal(t, acceptEncodingHeader, rw.Header().Get(varyHeader))
+			if len(test.expectedEncoding) == 0 {
+				assert.Equal(t, baseBody, rw.Body.Bytes())
+			} else {
+				assert.NotEqual(t, baseBody, rw.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	handler, err := NewCompress([]string{gzipValue}, map[string]int{gzipValue: gzip.BestSpeed})
+	require.NoError(t, err)
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
+	req.Header.Add(acceptEncodingHeader, gzipValue)
+
+	baseBody := generateBytes(100000)
+	next := func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(baseBody)
+	}
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req, next)
+
+	assert.Equal(t, gzipValue, rw.Header().Get(contentEncodingHeader))
+	reader, err := gzip.NewReader(rw.Body)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, baseBody, body)
+}
+
+func TestNewCompressErrors(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		encodings []string
+		levels    map[string]int
+	}{
+		{
+			desc:      "unsupported encoding",
+			encodings: []string{"deflate"},
+		},
+		{
+			desc:   "invalid brotli level",
+			levels: map[string]int{"br": 12},
+		},
+		{
+			desc:   "invalid zstd level",
+			levels: map[string]int{"zstd": 20},
+		},
+		{
+			desc:   "invalid gzip level",
+			levels: map[string]int{"gzip": 10},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewCompress(test.encodings, test.levels)
+			assert.Error(t, err)
+		})
+	}
+}
+
 func generateBytes(len int) []byte {
 	var value []byte
 	for i := 0; i < len; i++ {
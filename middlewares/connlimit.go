@@ -0,0 +1,133 @@
+package middlewares
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/utils"
+)
+
+// DefaultConnLimitQueueTimeout is the default duration a request waits in the queue for a free connection
+const DefaultConnLimitQueueTimeout = 10 * time.Second
+
+// ConnLimiter limits the number of requests in progress per source, as categorized by the extractor.
+// The excess requests wait in a queue for a request to complete, and are rejected with statusCode
+// if the queue is full or the queue timeout is reached.
+type ConnLimiter struct {
+	next         http.Handler
+	extractor    utils.SourceExtractor
+	maxConns     int64
+	queueSize    int64
+	queueTimeout time.Duration
+	statusCode   int
+	mutex        sync.Mutex
+	sources      map[string]*connSource
+}
+
+// connSource holds the connection slots of a source, users counting the requests using or waiting for a slot
+type connSource struct {
+	slots   chan struct{}
+	users   int64
+	waiting int64
+}
+
+// NewConnLimiter returns a new ConnLimiter instance
+func NewConnLimiter(next http.Handler, extractor utils.SourceExtractor, maxConns int64, queueSize int64, queueTimeout time.Duration, statusCode int) *ConnLimiter {
+	if queueTimeout <= 0 {
+		queueTimeout = DefaultConnLimitQueueTimeout
+	}
+	if statusCode == 0 {
+		statusCode = http.StatusTooManyRequests
+	}
+	return &ConnLimiter{
+		next:         next,
+		extractor:    extractor,
+		maxConns:     maxConns,
+		queueSize:    queueSize,
+		queueTimeout: queueTimeout,
+		statusCode:   statusCode,
+		sources:      make(map[string]*connSource),
+	}
+}
+
+func (cl *ConnLimiter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	token, _, err := cl.extractor.Extract(req)
+	if err != nil {
+		log.Errorf("Error extracting the connection limit source: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	source, ok := cl.acquire(req, token)
+	if !ok {
+		log.Debugf("Maximum connections reached for %s", token)
+		http.Error(rw, http.StatusText(cl.statusCode), cl.statusCode)
+		return
+	}
+	defer cl.release(token, source)
+
+	cl.next.ServeHTTP(rw, req)
+}
+
+func (cl *ConnLimiter) acquire(req *http.Request, token string) (*connSource, bool) {
+	cl.mutex.Lock()
+	source, ok := cl.sources[token]
+	if !ok {
+		source = &connSource{slots: make(chan struct{}, cl.maxConns)}
+		cl.sources[token] = source
+	}
+
+	select {
+	case source.slots <- struct{}{}:
+		source.users++
+		cl.mutex.Unlock()
+		return source, true
+	default:
+	}
+
+	if source.waiting >= cl.queueSize {
+		cl.mutex.Unlock()
+		return nil, false
+	}
+	source.waiting++
+	source.users++
+	cl.mutex.Unlock()
+
+	timer := time.NewTimer(cl.queueTimeout)
+	defer timer.Stop()
+
+	acquired := false
+	select {
+	case source.slots <- struct{}{}:
+		acquired = true
+	case <-timer.C:
+	case <-req.Context().Done():
+	}
+
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	source.waiting--
+	if !acquired {
+		cl.removeUser(token, source)
+		return nil, false
+	}
+	return source, true
+}
+
+func (cl *ConnLimiter) release(token string, source *connSource) {
+	<-source.slots
+
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	cl.removeUser(token, source)
+}
+
+// removeUser forgets the source once it has no user left, cl.mutex being held
+func (cl *ConnLimiter) removeUser(token string, source *connSource) {
+	source.users--
+	if source.users == 0 {
+		delete(cl.sources, token)
+	}
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/utils"
+)
+
+func TestConnLimiter(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		queueSize          int64
+		queueTimeout       time.Duration
+		statusCode         int
+		expectedStatusCode int
+	}{
+		{
+			desc:               "rejected with default status code",
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			desc:               "rejected with service unavailable",
+			statusCode:         http.StatusServiceUnavailable,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc:               "queue timeout",
+			queueSize:          1,
+			queueTimeout:       10 * time.Millisecond,
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			desc:               "queued until a connection is released",
+			queueSize:          1,
+			queueTimeout:       time.Second,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// the first request is blocked until release is closed
+			release := make(chan struct{})
+			started := make(chan struct{})
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/blocking" {
+					close(started)
+					<-release
+				}
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			extractor, err := utils.NewExtractor("request.host")
+			require.NoError(t, err)
+			limiter := NewConnLimiter(next, extractor, 1, test.queueSize, test.queueTimeout, test.statusCode)
+
+			blockingDone := make(chan struct{})
+			go func() {
+				defer close(blockingDone)
+				limiter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://foo/blocking", nil))
+			}()
+			<-started
+
+			// another source is not limited
+			recorder := httptest.NewRecorder()
+			limiter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://bar/", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			if test.expectedStatusCode == http.StatusOK {
+				time.AfterFunc(10*time.Millisecond, func() { close(release) })
+			} else {
+				defer close(release)
+			}
+
+			recorder = httptest.NewRecorder()
+			limiter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo/", nil))
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+
+			if test.expectedStatusCode == http.StatusOK {
+				<-blockingDone
+				limiter.mutex.Lock()
+				assert.Empty(t, limiter.sources)
+				limiter.mutex.Unlock()
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/forward"
+)
+
+// DefaultBackend is a http.Handler answering the requests matching no frontend
+type DefaultBackend struct {
+	url        *url.URL
+	forwarder  *forward.Forwarder
+	statusCode int
+	body       string
+}
+
+// NewDefaultBackend creates a DefaultBackend forwarding the requests to the server of the configuration if any,
+// and answering with its status code and body otherwise.
+func NewDefaultBackend(config *types.DefaultBackend) (*DefaultBackend, error) {
+	defaultBackend := &DefaultBackend{statusCode: config.StatusCode, body: config.Body}
+	if defaultBackend.statusCode == 0 {
+		defaultBackend.statusCode = http.StatusNotFound
+	}
+	if defaultBackend.statusCode < 100 || defaultBackend.statusCode > 599 {
+		return nil, fmt.Errorf("invalid default backend status code %d", config.StatusCode)
+	}
+
+	if len(config.URL) > 0 {
+		u, err := url.Parse(config.URL)
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return nil, fmt.Errorf("invalid default backend URL %q", config.URL)
+		}
+		fwd, err := forward.New(forward.PassHostHeader(true))
+		if err != nil {
+			return nil, err
+		}
+		defaultBackend.url = u
+		defaultBackend.forwarder = fwd
+	}
+	return defaultBackend, nil
+}
+
+func (d *DefaultBackend) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if d.forwarder != nil {
+		r.URL.Scheme = d.url.Scheme
+		r.URL.Host = d.url.Host
+		d.forwarder.ServeHTTP(rw, r)
+		return
+	}
+
+	body := d.body
+	if len(body) == 0 {
+		body = http.StatusText(d.statusCode)
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(d.statusCode)
+	fmt.Fprintln(rw, body)
+}
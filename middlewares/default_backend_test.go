@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultBackend(t *testing.T) {
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Legacy", "true")
+		rw.WriteHeader(http.StatusAccepted)
+		rw.Write([]byte("legacy " + r.Host + r.URL.Path))
+	}))
+	defer legacyServer.Close()
+
+	testCases := []struct {
+		desc               string
+		config             types.DefaultBackend
+		expectedStatusCode int
+		expectedBody       string
+		expectedHeader     string
+	}{
+		{
+			desc:               "not found by default",
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       "Not Found\n",
+		},
+		{
+			desc:               "status code and body",
+			config:             types.DefaultBackend{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       "maintenance\n",
+		},
+		{
+			desc:               "legacy server",
+			config:             types.DefaultBackend{URL: legacyServer.URL},
+			expectedStatusCode: http.StatusAccepted,
+			expectedBody:       "legacy foo.bar/unknown",
+			expectedHeader:     "true",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			defaultBackend, err := NewDefaultBackend(&test.config)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			defaultBackend.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/unknown", nil))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+			assert.Equal(t, test.expectedHeader, recorder.Header().Get("X-Legacy"))
+		})
+	}
+}
+
+func TestNewDefaultBackendInvalid(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config types.DefaultBackend
+	}{
+		{
+			desc:   "invalid status code",
+			config: types.DefaultBackend{StatusCode: 1000},
+		},
+		{
+			desc:   "URL without scheme",
+			config: types.DefaultBackend{URL: "legacy:8080"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewDefaultBackend(&test.config)
+			assert.Error(t, err)
+		})
+	}
+}
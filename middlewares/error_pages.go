@@ -1,6 +1,9 @@
 package middlewares
 
 import (
+	"bytes"
+	"fmt"
+	"html/template"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,38 +19,41 @@ type ErrorPagesHandler struct {
 	HTTPCodeRanges     [][2]int
 	BackendURL         string
 	errorPageForwarder *forward.Forwarder
+	template           *template.Template
 }
 
-//NewErrorPagesHandler initializes the utils.ErrorHandler for the custom error pages
+// errorPageData is the data of the error page templates
+type errorPageData struct {
+	StatusCode int
+	StatusText string
+}
+
+//NewErrorPagesHandler initializes the utils.ErrorHandler for the custom error pages,
+//served by the error backend, or rendered from the template file of the error page if any
 func NewErrorPagesHandler(errorPage types.ErrorPage, backendURL string) (*ErrorPagesHandler, error) {
 	fwd, err := forward.New()
 	if err != nil {
 		return nil, err
 	}
 
-	//Break out the http status code ranges into a low int and high int
-	//for ease of use at runtime
-	var blocks [][2]int
-	for _, block := range errorPage.Status {
-		codes := strings.Split(block, "-")
-		//if only a single HTTP code was configured, assume the best and create the correct configuration on the user's behalf
-		if len(codes) == 1 {
-			codes = append(codes, codes[0])
-		}
-		lowCode, err := strconv.Atoi(codes[0])
-		if err != nil {
-			return nil, err
-		}
-		highCode, err := strconv.Atoi(codes[1])
+	blocks, err := NewHTTPCodeRanges(errorPage.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpl *template.Template
+	if len(errorPage.File) > 0 {
+		tmpl, err = template.ParseFiles(errorPage.File)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error parsing error page template %s: %v", errorPage.File, err)
 		}
-		blocks = append(blocks, [2]int{lowCode, highCode})
 	}
+
 	return &ErrorPagesHandler{
 			HTTPCodeRanges:     blocks,
 			BackendURL:         backendURL + errorPage.Query,
-			errorPageForwarder: fwd},
+			errorPageForwarder: fwd,
+			template:           tmpl},
 		nil
 }
 
@@ -56,22 +62,56 @@ func (ep *ErrorPagesHandler) ServeHTTP(w http.ResponseWriter, req *http.Request,
 	recorder.responseWriter = w
 	next.ServeHTTP(recorder, req)
 
-	w.WriteHeader(recorder.Code)
+	// The connection has been hijacked (e.g. websocket) or the response already streamed,
+	// nothing can be written anymore.
+	if recorder.streamingResponseStarted {
+		return
+	}
+
 	//check the recorder code against the configured http status code ranges
 	for _, block := range ep.HTTPCodeRanges {
 		if recorder.Code >= block[0] && recorder.Code <= block[1] {
 			log.Errorf("Caught HTTP Status Code %d, returning error page", recorder.Code)
-			finalURL := strings.Replace(ep.BackendURL, "{status}", strconv.Itoa(recorder.Code), -1)
-			if newReq, err := http.NewRequest(http.MethodGet, finalURL, nil); err != nil {
-				w.Write([]byte(http.StatusText(recorder.Code)))
-			} else {
-				ep.errorPageForwarder.ServeHTTP(w, newReq)
-			}
+			ep.serveErrorPage(w, recorder.Code)
 			return
 		}
 	}
 
 	//did not catch a configured status code so proceed with the request
 	utils.CopyHeaders(w.Header(), recorder.Header())
+	w.WriteHeader(recorder.Code)
 	w.Write(recorder.Body.Bytes())
 }
+
+// serveErrorPage writes the error page of the status code, with the status code
+func (ep *ErrorPagesHandler) serveErrorPage(w http.ResponseWriter, code int) {
+	if ep.template != nil {
+		page := &bytes.Buffer{}
+		if err := ep.template.Execute(page, errorPageData{StatusCode: code, StatusText: http.StatusText(code)}); err != nil {
+			log.Errorf("Error rendering the error page: %v", err)
+			w.WriteHeader(code)
+			w.Write([]byte(http.StatusText(code)))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(code)
+		w.Write(page.Bytes())
+		return
+	}
+
+	finalURL := strings.Replace(ep.BackendURL, "{status}", strconv.Itoa(code), -1)
+	newReq, err := http.NewRequest(http.MethodGet, finalURL, nil)
+	if err != nil {
+		w.WriteHeader(code)
+		w.Write([]byte(http.StatusText(code)))
+		return
+	}
+
+	// the error page is sent with the status code of the response, and its own headers
+	pageRecorder := newRetryResponseRecorder()
+	ep.errorPageForwarder.ServeHTTP(pageRecorder, newReq)
+	utils.CopyHeaders(w.Header(), pageRecorder.Header())
+	w.Header().Del("Content-Length")
+	w.WriteHeader(code)
+	w.Write(pageRecorder.Body.Bytes())
+}
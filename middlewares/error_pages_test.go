@@ -2,8 +2,10 @@ package middlewares
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strconv"
 	"testing"
 
@@ -150,3 +152,46 @@ func TestErrorPageSingleCode(t *testing.T) {
 	assert.Contains(t, recorder.Body.String(), "503 Test Server")
 	assert.NotContains(t, recorder.Body.String(), "oops", "Should not return the oops page")
 }
+
+func TestErrorPageTemplate(t *testing.T) {
+	file, err := ioutil.TempFile("", "error-page")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("<h1>{{.StatusCode}} {{.StatusText}}</h1>")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	testHandler, err := NewErrorPagesHandler(types.ErrorPage{Status: []string{"502-504"}, File: file.Name()}, "")
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "backend")
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(status)
+		fmt.Fprintln(w, "backend error")
+	})
+	n := negroni.New()
+	n.Use(testHandler)
+	n.UseHandler(handler)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/?status=503", nil)
+	n.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "<h1>503 Service Unavailable</h1>", recorder.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/?status=500", nil)
+	n.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "backend error\n", recorder.Body.String())
+	assert.Equal(t, "backend", recorder.Header().Get("X-Backend"))
+}
+
+func TestErrorPageInvalidTemplate(t *testing.T) {
+	_, err := NewErrorPagesHandler(types.ErrorPage{Status: []string{"500"}, File: "/does/not/exist.html"}, "")
+	assert.Error(t, err)
+}
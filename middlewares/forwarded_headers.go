@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/containous/traefik/whitelist"
+	"github.com/vulcand/oxy/forward"
+)
+
+// XRealIP is the header holding the client IP set by some proxies
+const XRealIP = "X-Real-Ip"
+
+// forwardedHeaders are the headers describing the original request, set by the proxies in front of Traefik
+var forwardedHeaders = []string{
+	forward.XForwardedFor,
+	forward.XForwardedProto,
+	forward.XForwardedHost,
+	forward.XForwardedPort,
+	forward.XForwardedServer,
+	XRealIP,
+}
+
+// ForwardedHeaders is a middleware removing the X-Forwarded-* headers of the requests not coming from a trusted proxy,
+// so that the forwarder sets them from the connection: the client IP, the scheme and the host.
+type ForwardedHeaders struct {
+	insecure   bool
+	trustedIPs *whitelist.IP
+}
+
+// NewForwardedHeaders creates the middleware trusting the X-Forwarded-* headers of the requests coming from the
+// trustedIPs, or of all the requests if insecure
+func NewForwardedHeaders(insecure bool, trustedIPs []string) (*ForwardedHeaders, error) {
+	forwarded := &ForwardedHeaders{insecure: insecure}
+	if !insecure && len(trustedIPs) > 0 {
+		ips, err := whitelist.NewIP(trustedIPs)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the trusted IPs %s: %v", trustedIPs, err)
+		}
+		forwarded.trustedIPs = ips
+	}
+	return forwarded, nil
+}
+
+func (f *ForwardedHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if !f.isTrusted(r.RemoteAddr) {
+		for _, header := range forwardedHeaders {
+			r.Header.Del(header)
+		}
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+// isTrusted tells whether the headers of the requests coming from the remote address are trusted
+func (f *ForwardedHeaders) isTrusted(remoteAddr string) bool {
+	if f.insecure {
+		return true
+	}
+	if f.trustedIPs == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	trusted, _, err := f.trustedIPs.Contains(host)
+	return err == nil && trusted
+}
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/forward"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		insecure        bool
+		trustedIPs      []string
+		remoteAddr      string
+		expectedTrusted bool
+	}{
+		{
+			desc:            "insecure",
+			insecure:        true,
+			remoteAddr:      "10.0.0.1:42000",
+			expectedTrusted: true,
+		},
+		{
+			desc:       "no trusted IPs",
+			remoteAddr: "10.0.0.1:42000",
+		},
+		{
+			desc:            "trusted proxy",
+			trustedIPs:      []string{"10.0.0.0/8", "192.168.1.1"},
+			remoteAddr:      "10.0.0.1:42000",
+			expectedTrusted: true,
+		},
+		{
+			desc:       "untrusted client",
+			trustedIPs: []string{"10.0.0.0/8", "192.168.1.1"},
+			remoteAddr: "172.16.0.1:42000",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			forwarded, err := NewForwardedHeaders(test.insecure, test.trustedIPs)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set(forward.XForwardedFor, "1.2.3.4")
+			req.Header.Set(forward.XForwardedProto, "https")
+			req.Header.Set(forward.XForwardedHost, "spoofed.com")
+			req.Header.Set(XRealIP, "1.2.3.4")
+
+			var headers http.Header
+			forwarded.ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, req *http.Request) {
+				headers = req.Header
+			})
+
+			if test.expectedTrusted {
+				assert.Equal(t, "1.2.3.4", headers.Get(forward.XForwardedFor))
+				assert.Equal(t, "https", headers.Get(forward.XForwardedProto))
+				assert.Equal(t, "spoofed.com", headers.Get(forward.XForwardedHost))
+				assert.Equal(t, "1.2.3.4", headers.Get(XRealIP))
+			} else {
+				for _, header := range []string{forward.XForwardedFor, forward.XForwardedProto, forward.XForwardedHost, XRealIP} {
+					assert.Empty(t, headers.Get(header), header)
+				}
+			}
+		})
+	}
+}
+
+func TestNewForwardedHeadersInvalidTrustedIPs(t *testing.T) {
+	_, err := NewForwardedHeaders(false, []string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
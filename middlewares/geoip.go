@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/containous/traefik/geoip"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/whitelist"
+	"github.com/pkg/errors"
+	"github.com/vulcand/oxy/forward"
+)
+
+// Headers holding the location of the client, toward the backend
+const (
+	XGeoIPCountry   = "X-GeoIP-Country"
+	XGeoIPContinent = "X-GeoIP-Continent"
+	XGeoIPCity      = "X-GeoIP-City"
+)
+
+// GeoIPHeaders is a middleware setting the location of the client in the X-GeoIP-* headers of the requests,
+// so that they can be routed and filtered by country or continent. The headers sent by the clients are removed.
+type GeoIPHeaders struct {
+	database       *geoip.Database
+	insecure       bool
+	trustedProxies *whitelist.IP
+}
+
+// NewGeoIPHeaders creates the middleware locating the clients in the database. The client is the remote address
+// of the requests, or the first address of X-Forwarded-For if insecure, or the last address of X-Forwarded-For
+// which is not one of the trustedProxies.
+func NewGeoIPHeaders(database *geoip.Database, insecure bool, trustedProxies []string) (*GeoIPHeaders, error) {
+	geoIPHeaders := &GeoIPHeaders{database: database, insecure: insecure}
+	if !insecure && len(trustedProxies) > 0 {
+		ips, err := whitelist.NewIP(trustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the trusted proxies %s: %v", trustedProxies, err)
+		}
+		geoIPHeaders.trustedProxies = ips
+	}
+	return geoIPHeaders, nil
+}
+
+func (g *GeoIPHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	r.Header.Del(XGeoIPCountry)
+	r.Header.Del(XGeoIPContinent)
+	r.Header.Del(XGeoIPCity)
+
+	if ip := net.ParseIP(g.clientIP(r)); ip != nil {
+		location, err := g.database.Lookup(ip)
+		if err != nil {
+			log.Debug(err)
+		} else if location != nil {
+			setGeoIPHeader(r.Header, XGeoIPCountry, location.Country)
+			setGeoIPHeader(r.Header, XGeoIPContinent, location.Continent)
+			setGeoIPHeader(r.Header, XGeoIPCity, location.City)
+		}
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+func (g *GeoIPHeaders) clientIP(r *http.Request) string {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	if !g.insecure && g.trustedProxies == nil {
+		return remoteIP
+	}
+
+	var forwardedIPs []string
+	for _, values := range r.Header[forward.XForwardedFor] {
+		for _, value := range strings.Split(values, ",") {
+			forwardedIPs = append(forwardedIPs, strings.TrimSpace(value))
+		}
+	}
+	chain := append(forwardedIPs, remoteIP)
+	if g.insecure {
+		return chain[0]
+	}
+	for i := len(chain) - 1; i > 0; i-- {
+		if trusted, _, err := g.trustedProxies.Contains(chain[i]); err != nil || !trusted {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+func setGeoIPHeader(header http.Header, name string, value string) {
+	if len(value) > 0 {
+		header.Set(name, value)
+	}
+}
+
+// GeoIPFilter is a middleware rejecting the requests whose X-GeoIP-* headers are not allowed
+type GeoIPFilter struct {
+	allowedCountries  map[string]bool
+	blockedCountries  map[string]bool
+	allowedContinents map[string]bool
+	blockedContinents map[string]bool
+}
+
+// NewGeoIPFilter creates the middleware filtering the requests by the country and continent of their client.
+// The blocked locations are rejected, and the other ones are allowed when no locations are allowed.
+func NewGeoIPFilter(config *types.GeoIP) (*GeoIPFilter, error) {
+	if len(config.AllowedCountries) == 0 && len(config.BlockedCountries) == 0 &&
+		len(config.AllowedContinents) == 0 && len(config.BlockedContinents) == 0 {
+		return nil, errors.New("no allowed nor blocked countries or continents provided")
+	}
+
+	return &GeoIPFilter{
+		allowedCountries:  geoIPCodes(config.AllowedCountries),
+		blockedCountries:  geoIPCodes(config.BlockedCountries),
+		allowedContinents: geoIPCodes(config.AllowedContinents),
+		blockedContinents: geoIPCodes(config.BlockedContinents),
+	}, nil
+}
+
+func (g *GeoIPFilter) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	country := strings.ToUpper(r.Header.Get(XGeoIPCountry))
+	continent := strings.ToUpper(r.Header.Get(XGeoIPContinent))
+
+	if g.blockedCountries[country] || g.blockedContinents[continent] {
+		log.Debugf("country %q, continent %q blocked - rejecting", country, continent)
+		reject(rw)
+		return
+	}
+	if len(g.allowedCountries) > 0 || len(g.allowedContinents) > 0 {
+		if !g.allowedCountries[country] && !g.allowedContinents[continent] {
+			log.Debugf("country %q, continent %q not allowed - rejecting", country, continent)
+			reject(rw)
+			return
+		}
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+// geoIPCodes returns the set of the upper-cased codes, the empty code of the unknown locations being never in the set
+func geoIPCodes(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if len(code) > 0 {
+			set[strings.ToUpper(code)] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,130 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/forward"
+)
+
+func TestGeoIPFilter(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		config             types.GeoIP
+		country            string
+		continent          string
+		expectedStatusCode int
+	}{
+		{
+			desc:               "allowed country",
+			config:             types.GeoIP{AllowedCountries: []string{"fr", "DE"}},
+			country:            "FR",
+			continent:          "EU",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "not allowed country",
+			config:             types.GeoIP{AllowedCountries: []string{"FR", "DE"}},
+			country:            "US",
+			continent:          "NA",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "unknown location not allowed",
+			config:             types.GeoIP{AllowedContinents: []string{"EU"}},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "allowed continent",
+			config:             types.GeoIP{AllowedCountries: []string{"US"}, AllowedContinents: []string{"EU"}},
+			country:            "DE",
+			continent:          "EU",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "blocked country of an allowed continent",
+			config:             types.GeoIP{AllowedContinents: []string{"EU"}, BlockedCountries: []string{"DE"}},
+			country:            "DE",
+			continent:          "EU",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "unknown location not blocked",
+			config:             types.GeoIP{BlockedContinents: []string{"AS"}},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := NewGeoIPFilter(&test.config)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if len(test.country) > 0 {
+				req.Header.Set(XGeoIPCountry, test.country)
+				req.Header.Set(XGeoIPContinent, test.continent)
+			}
+			recorder := httptest.NewRecorder()
+			filter.ServeHTTP(recorder, req, func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestNewGeoIPFilterEmpty(t *testing.T) {
+	_, err := NewGeoIPFilter(&types.GeoIP{})
+	assert.Error(t, err)
+}
+
+func TestGeoIPHeadersClientIP(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		insecure       bool
+		trustedProxies []string
+		forwardedFor   string
+		expected       string
+	}{
+		{
+			desc:         "remote address",
+			forwardedFor: "1.2.3.4",
+			expected:     "10.0.0.1",
+		},
+		{
+			desc:         "insecure",
+			insecure:     true,
+			forwardedFor: "1.2.3.4, 10.0.0.2",
+			expected:     "1.2.3.4",
+		},
+		{
+			desc:           "last untrusted address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			forwardedFor:   "1.2.3.4, 5.6.7.8, 10.0.0.2",
+			expected:       "5.6.7.8",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			geoIPHeaders, err := NewGeoIPHeaders(nil, test.insecure, test.trustedProxies)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = "10.0.0.1:42000"
+			req.Header.Set(forward.XForwardedFor, test.forwardedFor)
+			assert.Equal(t, test.expected, geoIPHeaders.clientIP(req))
+		})
+	}
+}
@@ -3,15 +3,24 @@ package middlewares
 //Middleware based on https://github.com/unrolled/secure
 
 import (
-	"github.com/containous/traefik/types"
+	"bufio"
+	"fmt"
+	"net"
 	"net/http"
+
+	"github.com/containous/traefik/types"
+)
+
+// Compile time validation headerResponseWriter implements http interfaces correctly.
+var (
+	_ Stateful = &headerResponseWriter{}
 )
 
 // HeaderOptions is a struct for specifying configuration options for the headers middleware.
 type HeaderOptions struct {
-	// If Custom request headers are set, these will be added to the request
+	// If Custom request headers are set, these will be set on the request, or removed if their value is empty
 	CustomRequestHeaders map[string]string
-	// If Custom response headers are set, these will be added to the ResponseWriter
+	// If Custom response headers are set, these will be set on the response, or removed if their value is empty
 	CustomResponseHeaders map[string]string
 }
 
@@ -52,28 +61,93 @@ func NewHeader(options ...HeaderOptions) *HeaderStruct {
 func (s *HeaderStruct) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Let headers process the request.
-		s.Process(w, r)
-		h.ServeHTTP(w, r)
+		h.ServeHTTP(s.Process(w, r), r)
 	})
 }
 
 func (s *HeaderStruct) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	s.Process(w, r)
+	rw := s.Process(w, r)
 	// If there is a next, call it.
 	if next != nil {
-		next(w, r)
+		next(rw, r)
 	}
 }
 
-// Process runs the actual checks and returns an error if the middleware chain should stop.
-func (s *HeaderStruct) Process(w http.ResponseWriter, r *http.Request) {
+// Process sets the custom request headers, and returns the ResponseWriter setting the custom response headers.
+// A custom header with an empty value is removed.
+func (s *HeaderStruct) Process(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
 	// Loop through Custom request headers
 	for header, value := range s.opt.CustomRequestHeaders {
-		r.Header.Set(header, value)
+		if value == "" {
+			r.Header.Del(header)
+		} else {
+			r.Header.Set(header, value)
+		}
 	}
 
+	if len(s.opt.CustomResponseHeaders) == 0 {
+		return w
+	}
+	return &headerResponseWriter{ResponseWriter: w, headers: s.opt.CustomResponseHeaders}
+}
+
+// headerResponseWriter sets the custom response headers when the response headers are written,
+// overriding the ones of the backend
+type headerResponseWriter struct {
+	http.ResponseWriter
+	headers     map[string]string
+	wroteHeader bool
+}
+
+func (rw *headerResponseWriter) setHeaders() {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
 	// Loop through Custom response headers
-	for header, value := range s.opt.CustomResponseHeaders {
-		w.Header().Add(header, value)
+	for header, value := range rw.headers {
+		if value == "" {
+			rw.Header().Del(header)
+		} else {
+			rw.Header().Set(header, value)
+		}
+	}
+}
+
+func (rw *headerResponseWriter) WriteHeader(code int) {
+	rw.setHeaders()
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *headerResponseWriter) Write(b []byte) (int, error) {
+	rw.setHeaders()
+	return rw.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection
+func (rw *headerResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	// HTTP/2 response writers can not be hijacked
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.ResponseWriter)
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *headerResponseWriter) CloseNotify() <-chan bool {
+	if c, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client.
+func (rw *headerResponseWriter) Flush() {
+	rw.setHeaders()
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
 	}
 }
@@ -57,3 +57,34 @@ func TestCustomRequestHeader(t *testing.T) {
 	assert.Equal(t, http.StatusOK, res.Code, "Status not OK")
 	assert.Equal(t, "test_request", req.Header.Get("X-Custom-Request-Header"), "Did not get expected header")
 }
+
+func TestCustomHeadersOverrideAndRemove(t *testing.T) {
+	s := NewHeader(HeaderOptions{
+		CustomRequestHeaders: map[string]string{
+			"X-Env":           "production",
+			"X-Forwarded-Foo": "",
+		},
+		CustomResponseHeaders: map[string]string{
+			"Cache-Control": "no-store",
+			"Server":        "",
+		},
+	})
+
+	res := httptest.NewRecorder()
+	req := testhelpers.MustNewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("X-Env", "spoofed")
+	req.Header.Set("X-Forwarded-Foo", "bar")
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "production", r.Header.Get("X-Env"))
+		assert.NotContains(t, r.Header, "X-Forwarded-Foo")
+		w.Header().Set("Server", "backend")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("bar"))
+	})
+	s.Handler(backend).ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code, "Status not OK")
+	assert.Equal(t, []string{"no-store"}, res.Header()["Cache-Control"])
+	assert.NotContains(t, res.Header(), "Server")
+}
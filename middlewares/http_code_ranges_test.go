@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPCodeRanges(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		strBlocks     []string
+		expected      HTTPCodeRanges
+		expectedError bool
+	}{
+		{
+			desc:      "ranges and single codes",
+			strBlocks: []string{"502-504", "429"},
+			expected:  HTTPCodeRanges{{502, 504}, {429, 429}},
+		},
+		{
+			desc:          "invalid code",
+			strBlocks:     []string{"50x"},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ranges, err := NewHTTPCodeRanges(test.strBlocks)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, ranges)
+			assert.True(t, ranges.Contains(503))
+			assert.True(t, ranges.Contains(429))
+			assert.False(t, ranges.Contains(500))
+		})
+	}
+}
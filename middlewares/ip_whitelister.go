@@ -4,48 +4,99 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
 	"github.com/containous/traefik/whitelist"
 	"github.com/pkg/errors"
 	"github.com/urfave/negroni"
+	"github.com/vulcand/oxy/forward"
 )
 
-// IPWhiteLister is a middleware that provides Checks of the Requesting IP against a set of Whitelists
+// IPWhiteLister is a middleware that provides Checks of the Requesting IP against a set of Whitelists and Blacklists
 type IPWhiteLister struct {
-	handler     negroni.Handler
-	whiteLister *whitelist.IP
+	handler        negroni.Handler
+	whiteLister    *whitelist.IP
+	blackLister    *whitelist.IP
+	depth          int
+	trustedProxies *whitelist.IP
 }
 
 // NewIPWhitelister builds a new IPWhiteLister given a list of CIDR-Strings to whitelist
 func NewIPWhitelister(whitelistStrings []string) (*IPWhiteLister, error) {
-
 	if len(whitelistStrings) == 0 {
 		return nil, errors.New("no whitelists provided")
 	}
 
+	return NewIPFilter(whitelistStrings, nil, nil)
+}
+
+// NewIPFilter builds a new IPWhiteLister given lists of CIDR-Strings to whitelist and to blacklist,
+// the client IP being determined according to the ipStrategy if any.
+// The blacklisted IPs are rejected, even if they are whitelisted, and an empty whitelist allows all the other IPs.
+func NewIPFilter(whitelistStrings []string, blacklistStrings []string, ipStrategy *types.IPStrategy) (*IPWhiteLister, error) {
+	if len(whitelistStrings) == 0 && len(blacklistStrings) == 0 {
+		return nil, errors.New("no whitelists nor blacklists provided")
+	}
+
 	whiteLister := IPWhiteLister{}
 
-	ip, err := whitelist.NewIP(whitelistStrings)
-	if err != nil {
-		return nil, fmt.Errorf("parsing CIDR whitelist %s: %v", whitelistStrings, err)
+	if len(whitelistStrings) > 0 {
+		ip, err := whitelist.NewIP(whitelistStrings)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CIDR whitelist %s: %v", whitelistStrings, err)
+		}
+		whiteLister.whiteLister = ip
+	}
+
+	if len(blacklistStrings) > 0 {
+		ip, err := whitelist.NewIP(blacklistStrings)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CIDR blacklist %s: %v", blacklistStrings, err)
+		}
+		whiteLister.blackLister = ip
+	}
+
+	if ipStrategy != nil {
+		whiteLister.depth = ipStrategy.Depth
+		if len(ipStrategy.TrustedProxies) > 0 {
+			ip, err := whitelist.NewIP(ipStrategy.TrustedProxies)
+			if err != nil {
+				return nil, fmt.Errorf("parsing CIDR trusted proxies %s: %v", ipStrategy.TrustedProxies, err)
+			}
+			whiteLister.trustedProxies = ip
+		}
 	}
-	whiteLister.whiteLister = ip
 
 	whiteLister.handler = negroni.HandlerFunc(whiteLister.handle)
-	log.Debugf("configured %u IP whitelists: %s", len(whitelistStrings), whitelistStrings)
+	log.Debugf("configured %d IP whitelists: %s, %d IP blacklists: %s", len(whitelistStrings), whitelistStrings, len(blacklistStrings), blacklistStrings)
 
 	return &whiteLister, nil
 }
 
 func (wl *IPWhiteLister) handle(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	ipAddress, _, err := net.SplitHostPort(r.RemoteAddr)
+	ipAddress, err := wl.clientIP(r)
 	if err != nil {
-		log.Warnf("unable to parse remote-address from header: %s - rejecting", r.RemoteAddr)
+		log.Warnf("unable to determine the client IP: %v - rejecting", err)
 		reject(w)
 		return
 	}
 
+	if wl.blackLister != nil {
+		blacklisted, _, err := wl.blackLister.Contains(ipAddress)
+		if err != nil || blacklisted {
+			log.Debugf("source-IP %s matched blacklist %s - rejecting", ipAddress, wl.blackLister)
+			reject(w)
+			return
+		}
+	}
+
+	if wl.whiteLister == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+
 	allowed, ip, err := wl.whiteLister.Contains(ipAddress)
 	if err != nil {
 		log.Debugf("source-IP %s matched none of the whitelists - rejecting", ipAddress)
@@ -63,6 +114,41 @@ func (wl *IPWhiteLister) handle(w http.ResponseWriter, r *http.Request, next htt
 	reject(w)
 }
 
+// clientIP returns the remote address of the request or, with an IP strategy, the address
+// of the client in the X-Forwarded-For header
+func (wl *IPWhiteLister) clientIP(r *http.Request) (string, error) {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse remote-address %s", r.RemoteAddr)
+	}
+	if wl.depth <= 0 && wl.trustedProxies == nil {
+		return remoteIP, nil
+	}
+
+	var forwardedIPs []string
+	for _, values := range r.Header[forward.XForwardedFor] {
+		for _, value := range strings.Split(values, ",") {
+			forwardedIPs = append(forwardedIPs, strings.TrimSpace(value))
+		}
+	}
+
+	if wl.depth > 0 {
+		if len(forwardedIPs) < wl.depth {
+			return "", fmt.Errorf("less than %d addresses in %s %v", wl.depth, forward.XForwardedFor, forwardedIPs)
+		}
+		return forwardedIPs[len(forwardedIPs)-wl.depth], nil
+	}
+
+	// the client is the last address which is not a trusted proxy
+	chain := append(forwardedIPs, remoteIP)
+	for i := len(chain) - 1; i > 0; i-- {
+		if trusted, _, err := wl.trustedProxies.Contains(chain[i]); err != nil || !trusted {
+			return chain[i], nil
+		}
+	}
+	return chain[0], nil
+}
+
 func (wl *IPWhiteLister) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	wl.handler.ServeHTTP(rw, r, next)
 }
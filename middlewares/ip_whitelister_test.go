@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilter(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		whitelist          []string
+		blacklist          []string
+		ipStrategy         *types.IPStrategy
+		remoteAddr         string
+		xForwardedFor      string
+		expectedStatusCode int
+	}{
+		{
+			desc:               "whitelisted IP",
+			whitelist:          []string{"10.0.0.0/8"},
+			remoteAddr:         "10.1.2.3:1234",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "not whitelisted IP",
+			whitelist:          []string{"10.0.0.0/8"},
+			remoteAddr:         "20.1.2.3:1234",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "blacklisted IP",
+			blacklist:          []string{"10.0.0.0/8"},
+			remoteAddr:         "10.1.2.3:1234",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "not blacklisted IP",
+			blacklist:          []string{"10.0.0.0/8"},
+			remoteAddr:         "20.1.2.3:1234",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "whitelisted and blacklisted IP",
+			whitelist:          []string{"10.0.0.0/8"},
+			blacklist:          []string{"10.1.0.0/16"},
+			remoteAddr:         "10.1.2.3:1234",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "forwarded IP at depth",
+			whitelist:          []string{"20.0.0.0/8"},
+			ipStrategy:         &types.IPStrategy{Depth: 2},
+			remoteAddr:         "10.1.2.3:1234",
+			xForwardedFor:      "30.1.2.3, 20.1.2.3, 10.2.3.4",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "not enough forwarded IPs for depth",
+			blacklist:          []string{"20.0.0.0/8"},
+			ipStrategy:         &types.IPStrategy{Depth: 2},
+			remoteAddr:         "10.1.2.3:1234",
+			xForwardedFor:      "30.1.2.3",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "forwarded IP after trusted proxies",
+			blacklist:          []string{"20.0.0.0/8"},
+			ipStrategy:         &types.IPStrategy{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr:         "10.1.2.3:1234",
+			xForwardedFor:      "30.1.2.3, 20.1.2.3, 10.2.3.4",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "untrusted remote address",
+			whitelist:          []string{"30.0.0.0/8"},
+			ipStrategy:         &types.IPStrategy{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr:         "20.1.2.3:1234",
+			xForwardedFor:      "30.1.2.3",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "spoofed forwarded IP without IP strategy",
+			whitelist:          []string{"30.0.0.0/8"},
+			remoteAddr:         "20.1.2.3:1234",
+			xForwardedFor:      "30.1.2.3",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ipFilter, err := NewIPFilter(test.whitelist, test.blacklist, test.ipStrategy)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			}
+			ipFilter.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {})
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestNewIPFilterWithoutSourceRanges(t *testing.T) {
+	_, err := NewIPFilter(nil, nil, &types.IPStrategy{Depth: 1})
+	assert.Error(t, err)
+
+	_, err = NewIPFilter(nil, []string{"foo"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/containous/traefik/types"
+)
+
+// Maintenance is a middleware answering the requests of a frontend in maintenance, without forwarding them
+type Maintenance struct {
+	statusCode int
+	body       string
+	retryAfter string
+}
+
+// NewMaintenance creates the middleware answering with the status code, 503 by default, and the body of the configuration
+func NewMaintenance(config *types.Maintenance) (*Maintenance, error) {
+	maintenance := &Maintenance{statusCode: config.StatusCode, body: config.Body, retryAfter: config.RetryAfter}
+	if maintenance.statusCode == 0 {
+		maintenance.statusCode = http.StatusServiceUnavailable
+	}
+	if maintenance.statusCode < 100 || maintenance.statusCode > 599 {
+		return nil, fmt.Errorf("invalid maintenance status code %d", config.StatusCode)
+	}
+	if len(maintenance.body) == 0 {
+		maintenance.body = http.StatusText(maintenance.statusCode)
+	}
+	return maintenance, nil
+}
+
+func (m *Maintenance) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if len(m.retryAfter) > 0 {
+		rw.Header().Set("Retry-After", m.retryAfter)
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(m.statusCode)
+	fmt.Fprintln(rw, m.body)
+}
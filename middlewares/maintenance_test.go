@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		config             types.Maintenance
+		expectedStatusCode int
+		expectedBody       string
+		expectedRetryAfter string
+	}{
+		{
+			desc:               "default",
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       "Service Unavailable\n",
+		},
+		{
+			desc:               "custom response",
+			config:             types.Maintenance{StatusCode: http.StatusOK, Body: "Back at 10:00", RetryAfter: "3600"},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Back at 10:00\n",
+			expectedRetryAfter: "3600",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			maintenance, err := NewMaintenance(&test.config)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			maintenance.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com/", nil), func(rw http.ResponseWriter, req *http.Request) {
+				t.Error("the request must not be forwarded")
+			})
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+			assert.Equal(t, test.expectedRetryAfter, recorder.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestNewMaintenanceInvalidStatusCode(t *testing.T) {
+	_, err := NewMaintenance(&types.Maintenance{StatusCode: 1000})
+	assert.Error(t, err)
+}
@@ -41,6 +41,62 @@ func (m *MetricsWrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request, next
 	m.registry.ReqDurationHistogram().With(reqDurationLabels...).Observe(time.Since(start).Seconds())
 }
 
+// FrontendMetricsWrapper is a Negroni compatible Handler recording the requests of a frontend,
+// whichever backend it forwards them to.
+type FrontendMetricsWrapper struct {
+	registry     metrics.Registry
+	frontendName string
+}
+
+// NewFrontendMetricsWrapper return a FrontendMetricsWrapper struct with
+// a given Metrics implementation
+func NewFrontendMetricsWrapper(registry metrics.Registry, frontendName string) *FrontendMetricsWrapper {
+	return &FrontendMetricsWrapper{
+		registry:     registry,
+		frontendName: frontendName,
+	}
+}
+
+func (m *FrontendMetricsWrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := time.Now()
+	prw := &responseRecorder{rw, http.StatusOK}
+	next(prw, r)
+
+	reqLabels := []string{"frontend", m.frontendName, "code", strconv.Itoa(prw.statusCode), "method", getMethod(r)}
+	m.registry.FrontendReqsCounter().With(reqLabels...).Add(1)
+
+	reqDurationLabels := []string{"frontend", m.frontendName, "code", strconv.Itoa(prw.statusCode)}
+	m.registry.FrontendReqDurationHistogram().With(reqDurationLabels...).Observe(time.Since(start).Seconds())
+}
+
+// EntryPointMetricsWrapper is a Negroni compatible Handler recording the requests received by an entry point,
+// whichever frontend handles them.
+type EntryPointMetricsWrapper struct {
+	registry       metrics.Registry
+	entryPointName string
+}
+
+// NewEntryPointMetricsWrapper return an EntryPointMetricsWrapper struct with
+// a given Metrics implementation
+func NewEntryPointMetricsWrapper(registry metrics.Registry, entryPointName string) *EntryPointMetricsWrapper {
+	return &EntryPointMetricsWrapper{
+		registry:       registry,
+		entryPointName: entryPointName,
+	}
+}
+
+func (m *EntryPointMetricsWrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := time.Now()
+	prw := &responseRecorder{rw, http.StatusOK}
+	next(prw, r)
+
+	reqLabels := []string{"entrypoint", m.entryPointName, "code", strconv.Itoa(prw.statusCode), "method", getMethod(r)}
+	m.registry.EntryPointReqsCounter().With(reqLabels...).Add(1)
+
+	reqDurationLabels := []string{"entrypoint", m.entryPointName, "code", strconv.Itoa(prw.statusCode)}
+	m.registry.EntryPointReqDurationHistogram().With(reqDurationLabels...).Observe(time.Since(start).Seconds())
+}
+
 type retryMetrics interface {
 	RetriesCounter() gokitmetrics.Counter
 }
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"testing"
 
+	traefikmetrics "github.com/containous/traefik/metrics"
 	"github.com/go-kit/kit/metrics"
 )
 
@@ -27,6 +28,66 @@ func TestMetricsRetryListener(t *testing.T) {
 	}
 }
 
+func TestFrontendMetricsWrapper(t *testing.T) {
+	registry := &collectingFrontendMetrics{Registry: traefikmetrics.NewVoidRegistry(), reqsCounter: &collectingCounter{}}
+	wrapper := NewFrontendMetricsWrapper(registry, "frontendName")
+
+	next := func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}
+	wrapper.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil), next)
+
+	wantCounterValue := float64(1)
+	if registry.reqsCounter.counterValue != wantCounterValue {
+		t.Errorf("got counter value of %f, want %f", registry.reqsCounter.counterValue, wantCounterValue)
+	}
+
+	wantLabelValues := []string{"frontend", "frontendName", "code", "418", "method", http.MethodPost}
+	if !reflect.DeepEqual(registry.reqsCounter.lastLabelValues, wantLabelValues) {
+		t.Errorf("wrong label values %v used, want %v", registry.reqsCounter.lastLabelValues, wantLabelValues)
+	}
+}
+
+func TestEntryPointMetricsWrapper(t *testing.T) {
+	registry := &collectingEntryPointMetrics{Registry: traefikmetrics.NewVoidRegistry(), reqsCounter: &collectingCounter{}}
+	wrapper := NewEntryPointMetricsWrapper(registry, "http")
+
+	next := func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}
+	wrapper.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), next)
+
+	wantCounterValue := float64(1)
+	if registry.reqsCounter.counterValue != wantCounterValue {
+		t.Errorf("got counter value of %f, want %f", registry.reqsCounter.counterValue, wantCounterValue)
+	}
+
+	wantLabelValues := []string{"entrypoint", "http", "code", "404", "method", http.MethodGet}
+	if !reflect.DeepEqual(registry.reqsCounter.lastLabelValues, wantLabelValues) {
+		t.Errorf("wrong label values %v used, want %v", registry.reqsCounter.lastLabelValues, wantLabelValues)
+	}
+}
+
+// collectingEntryPointMetrics is a Registry collecting the entry point requests.
+type collectingEntryPointMetrics struct {
+	traefikmetrics.Registry
+	reqsCounter *collectingCounter
+}
+
+func (metrics *collectingEntryPointMetrics) EntryPointReqsCounter() metrics.Counter {
+	return metrics.reqsCounter
+}
+
+// collectingFrontendMetrics is a Registry collecting the frontend requests.
+type collectingFrontendMetrics struct {
+	traefikmetrics.Registry
+	reqsCounter *collectingCounter
+}
+
+func (metrics *collectingFrontendMetrics) FrontendReqsCounter() metrics.Counter {
+	return metrics.reqsCounter
+}
+
 // collectingRetryMetrics is an implementation of the retryMetrics interface that can be used inside tests to collect the times Add() was called.
 type collectingRetryMetrics struct {
 	retryCounter *collectingCounter
@@ -0,0 +1,129 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+)
+
+// MirrorMaxBodySize is the maximum size of the body of a mirrored request.
+// The requests having a bigger body, or a body of unknown size, are not mirrored.
+const MirrorMaxBodySize = 1 << 20
+
+// MirrorMaxInFlight is the maximum number of mirrored requests in flight for a frontend.
+// The requests to mirror beyond it are not mirrored, instead of piling up while the mirror backend is slow.
+const MirrorMaxInFlight = 100
+
+// Mirror is a middleware sending a copy of a percentage of the requests to a mirror handler,
+// whose responses are discarded.
+type Mirror struct {
+	next     http.Handler
+	mirror   http.Handler
+	percent  int
+	inFlight chan struct{}
+	mutex    sync.Mutex
+	total    uint64
+	mirrored uint64
+}
+
+// NewMirror returns a new Mirror instance
+func NewMirror(next http.Handler, mirror http.Handler, percent int) *Mirror {
+	return &Mirror{
+		next:     next,
+		mirror:   mirror,
+		percent:  percent,
+		inFlight: make(chan struct{}, MirrorMaxInFlight),
+	}
+}
+
+func (m *Mirror) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if m.shouldMirror(req) {
+		select {
+		case m.inFlight <- struct{}{}:
+			m.serveMirror(req)
+		default:
+			log.Debugf("Not mirroring request %s: %d mirrored requests in flight", req.URL, MirrorMaxInFlight)
+		}
+	}
+
+	m.next.ServeHTTP(rw, req)
+}
+
+// serveMirror sends the copy of the request to the mirror handler, releasing its place among the requests in flight once done
+func (m *Mirror) serveMirror(req *http.Request) {
+	mirrorReq, err := m.mirrorRequest(req)
+	if err != nil {
+		<-m.inFlight
+		log.Errorf("Error mirroring request %s: %v", req.URL, err)
+		return
+	}
+	safe.Go(func() {
+		defer func() { <-m.inFlight }()
+		m.mirror.ServeHTTP(newDiscardResponseWriter(), mirrorReq)
+	})
+}
+
+// shouldMirror spreads the mirrored requests evenly, so that percent of the requests are mirrored
+func (m *Mirror) shouldMirror(req *http.Request) bool {
+	if req.ContentLength < 0 || req.ContentLength > MirrorMaxBodySize {
+		return false
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.total++
+	if m.mirrored*100 >= m.total*uint64(m.percent) {
+		return false
+	}
+	m.mirrored++
+	return true
+}
+
+// mirrorRequest copies the request, its body included, with a context independent of the one of the original request
+func (m *Mirror) mirrorRequest(req *http.Request) (*http.Request, error) {
+	mirrorReq := req.WithContext(context.Background())
+	mirrorURL := *req.URL
+	mirrorReq.URL = &mirrorURL
+	mirrorReq.Header = make(http.Header)
+	for key, values := range req.Header {
+		mirrorReq.Header[key] = append([]string(nil), values...)
+	}
+
+	if req.Body == nil || req.ContentLength == 0 {
+		return mirrorReq, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	mirrorReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return mirrorReq, nil
+}
+
+// discardResponseWriter is an http.ResponseWriter discarding the response
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func newDiscardResponseWriter() *discardResponseWriter {
+	return &discardResponseWriter{header: make(http.Header)}
+}
+
+func (rw *discardResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (rw *discardResponseWriter) WriteHeader(code int) {}
@@ -0,0 +1,146 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		percent          int
+		requests         int
+		expectedMirrored int
+	}{
+		{
+			desc:             "no request mirrored",
+			percent:          0,
+			requests:         10,
+			expectedMirrored: 0,
+		},
+		{
+			desc:             "some requests mirrored",
+			percent:          30,
+			requests:         10,
+			expectedMirrored: 3,
+		},
+		{
+			desc:             "all requests mirrored",
+			percent:          100,
+			requests:         10,
+			expectedMirrored: 10,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var wg sync.WaitGroup
+			wg.Add(test.expectedMirrored)
+			var mutex sync.Mutex
+			var mirroredBodies []string
+			mirror := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				defer wg.Done()
+				body, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				mutex.Lock()
+				mirroredBodies = append(mirroredBodies, string(body))
+				mutex.Unlock()
+				rw.WriteHeader(http.StatusInternalServerError)
+			})
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				body, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				rw.Write(body)
+			})
+			handler := NewMirror(next, mirror, test.percent)
+
+			for i := 0; i < test.requests; i++ {
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body")))
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, "body", recorder.Body.String())
+			}
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("mirrored requests not received in time")
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			assert.Len(t, mirroredBodies, test.expectedMirrored)
+			for _, body := range mirroredBodies {
+				assert.Equal(t, "body", body)
+			}
+		})
+	}
+}
+
+func TestMirrorBodyOfUnknownSize(t *testing.T) {
+	mirror := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		t.Error("request with a body of unknown size mirrored")
+	})
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	handler := NewMirror(next, mirror, 100)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("body"))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestMirrorMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(MirrorMaxInFlight)
+	var mutex sync.Mutex
+	var mirrored int
+	mirror := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		mirrored++
+		mutex.Unlock()
+		wg.Done()
+		<-release
+	})
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	handler := NewMirror(next, mirror, 100)
+
+	// the requests beyond the ones in flight are dropped while the mirror is blocked
+	for i := 0; i < MirrorMaxInFlight+10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	wg.Wait()
+	mutex.Lock()
+	assert.Equal(t, MirrorMaxInFlight, mirrored)
+	mutex.Unlock()
+
+	// the places are released once the mirrored requests are done
+	wg.Add(MirrorMaxInFlight)
+	close(release)
+	for i := 0; i < MirrorMaxInFlight; i++ {
+		for len(handler.inFlight) == cap(handler.inFlight) {
+			time.Sleep(time.Millisecond)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	wg.Wait()
+	mutex.Lock()
+	assert.Equal(t, 2*MirrorMaxInFlight, mirrored)
+	mutex.Unlock()
+}
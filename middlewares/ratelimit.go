@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vulcand/oxy/ratelimit"
+)
+
+// RateLimitErrorHandler answers the rate limited requests like the oxy rate limiter does,
+// with a Retry-After header giving the number of seconds to wait before retrying.
+type RateLimitErrorHandler struct{}
+
+func (e *RateLimitErrorHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, err error) {
+	new(ratelimit.RateErrHandler).ServeHTTP(&retryAfterResponseWriter{ResponseWriter: rw}, req, err)
+}
+
+// retryAfterResponseWriter sets the Retry-After header from the X-Retry-In header of the rate limited responses
+type retryAfterResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (rw *retryAfterResponseWriter) WriteHeader(code int) {
+	if code == http.StatusTooManyRequests {
+		if delay, err := time.ParseDuration(rw.Header().Get("X-Retry-In")); err == nil {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(delay.Seconds())))))
+		}
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/ratelimit"
+	"github.com/vulcand/oxy/utils"
+)
+
+func TestRateLimitErrorHandler(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	extractor, err := utils.NewExtractor("request.header.X-Api-Key")
+	require.NoError(t, err)
+	rateSet := ratelimit.NewRateSet()
+	require.NoError(t, rateSet.Add(10*time.Second, 1, 1))
+	limiter, err := ratelimit.New(next, extractor, rateSet, ratelimit.ErrorHandler(&RateLimitErrorHandler{}))
+	require.NoError(t, err)
+
+	serve := func(key string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", key)
+		limiter.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, serve("foo").Code)
+
+	recorder := serve("foo")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "10", recorder.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, serve("bar").Code)
+}
@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/vulcand/plugin/rewrite"
+)
+
+// Redirect is a middleware redirecting the requests whose URL matches a regular expression
+type Redirect struct {
+	regex       *regexp.Regexp
+	replacement string
+	statusCode  int
+}
+
+// NewRedirect creates a Redirect middleware. The replacement may reference the capturing groups of the regex
+// and the route variables, e.g. {subdomain}, and is a template of the request, e.g. {{.Request.Host}}.
+// The status code is 302 by default, and can be 301, 302, 307 or 308.
+func NewRedirect(regex, replacement string, statusCode int) (*Redirect, error) {
+	exp, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+
+	switch statusCode {
+	case 0:
+		statusCode = http.StatusFound
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, fmt.Errorf("invalid redirect status code %d, must be 301, 302, 307 or 308", statusCode)
+	}
+
+	return &Redirect{
+		regex:       exp,
+		replacement: replacement,
+		statusCode:  statusCode,
+	}, nil
+}
+
+func (m *Redirect) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	oldURL := rawURL(r)
+
+	// only redirect if the regex matches the URL
+	if !m.regex.MatchString(oldURL) {
+		next.ServeHTTP(rw, r)
+		return
+	}
+
+	// replace the variables of the template
+	newURL := &bytes.Buffer{}
+	if err := rewrite.ApplyString(ExpandRouteVariables(m.regex.ReplaceAllString(oldURL, m.replacement), r), newURL, r); err != nil {
+		log.Errorf("Error in redirect middleware: %v", err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// a redirection to the same URL would loop
+	if newURL.String() == oldURL {
+		next.ServeHTTP(rw, r)
+		return
+	}
+
+	location, err := url.Parse(newURL.String())
+	if err != nil {
+		log.Errorf("Error in redirect middleware: invalid URL %s: %v", newURL.String(), err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Location", location.String())
+	rw.WriteHeader(m.statusCode)
+	rw.Write([]byte(http.StatusText(m.statusCode)))
+}
+
+// rawURL returns the URL of the request as sent by the client
+func rawURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return strings.Join([]string{scheme, "://", r.Host, r.URL.RequestURI()}, "")
+}
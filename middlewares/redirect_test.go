@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirect(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		regex              string
+		replacement        string
+		statusCode         int
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{
+			desc:               "default status code",
+			regex:              `^http://foo.com/old/(.*)$`,
+			replacement:        "http://foo.com/new/$1",
+			url:                "http://foo.com/old/page?id=1",
+			expectedStatusCode: http.StatusFound,
+			expectedLocation:   "http://foo.com/new/page?id=1",
+		},
+		{
+			desc:               "permanent redirect",
+			regex:              `^http://foo.com/blog/([0-9]+)/([a-z-]+)$`,
+			replacement:        "http://blog.foo.com/$1-$2",
+			statusCode:         http.StatusPermanentRedirect,
+			url:                "http://foo.com/blog/2017/hello-world",
+			expectedStatusCode: http.StatusPermanentRedirect,
+			expectedLocation:   "http://blog.foo.com/2017-hello-world",
+		},
+		{
+			desc:               "templated replacement",
+			regex:              `^https?://[^/]+/legacy/(.*)$`,
+			replacement:        `https://{{.Request.Host}}/$1`,
+			statusCode:         http.StatusMovedPermanently,
+			url:                "http://foo.com/legacy/page",
+			expectedStatusCode: http.StatusMovedPermanently,
+			expectedLocation:   "https://foo.com/page",
+		},
+		{
+			desc:               "no match",
+			regex:              `^http://foo.com/old/(.*)$`,
+			replacement:        "http://foo.com/new/$1",
+			url:                "http://foo.com/other",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "same URL",
+			regex:              `^(http://foo.com/.*)$`,
+			replacement:        "$1",
+			url:                "http://foo.com/page",
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			redirect, err := NewRedirect(test.regex, test.replacement, test.statusCode)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			redirect.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {})
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
+
+func TestNewRedirectWithInvalidConfiguration(t *testing.T) {
+	_, err := NewRedirect(`^(`, "/", 0)
+	assert.Error(t, err)
+
+	_, err = NewRedirect(`^/`, "/", http.StatusOK)
+	assert.Error(t, err)
+}
@@ -7,7 +7,7 @@ import (
 // ReplacedPathHeader is the default header to set the old path to
 const ReplacedPathHeader = "X-Replaced-Path"
 
-// ReplacePath is a middleware used to replace the path of a URL request
+// ReplacePath is a middleware used to replace the path of a URL request, the path being able to reference the route variables
 type ReplacePath struct {
 	Handler http.Handler
 	Path    string
@@ -15,7 +15,7 @@ type ReplacePath struct {
 
 func (s *ReplacePath) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	r.Header.Add(ReplacedPathHeader, r.URL.Path)
-	r.URL.Path = s.Path
+	r.URL.Path = ExpandRouteVariables(s.Path, r)
 	r.RequestURI = r.URL.RequestURI()
 	s.Handler.ServeHTTP(w, r)
 }
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// ReplacePathRegex is a middleware used to replace the path of a URL request with a regular expression
+type ReplacePathRegex struct {
+	Handler     http.Handler
+	Regexp      *regexp.Regexp
+	Replacement string
+}
+
+// NewReplacePathRegex builds a new ReplacePathRegex given a handler, a regular expression and a replacement,
+// which may reference the capturing groups of the regular expression
+func NewReplacePathRegex(handler http.Handler, regex string, replacement string) (*ReplacePathRegex, error) {
+	exp, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReplacePathRegex{
+		Handler:     handler,
+		Regexp:      exp,
+		Replacement: replacement,
+	}, nil
+}
+
+func (s *ReplacePathRegex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Regexp.MatchString(r.URL.Path) {
+		r.Header.Add(ReplacedPathHeader, r.URL.Path)
+		r.URL.Path = s.Regexp.ReplaceAllString(r.URL.Path, s.Replacement)
+		r.RequestURI = r.URL.RequestURI()
+	}
+	s.Handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplacePathRegex(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		path           string
+		regex          string
+		replacement    string
+		expectedPath   string
+		expectedHeader string
+	}{
+		{
+			desc:           "simple regex",
+			path:           "/whoami/and/whoami",
+			regex:          `/whoami`,
+			replacement:    "/who-am-i",
+			expectedPath:   "/who-am-i/and/who-am-i",
+			expectedHeader: "/whoami/and/whoami",
+		},
+		{
+			desc:           "regex with capturing groups",
+			path:           "/api/v1/users",
+			regex:          `^/api/v(\d+)/(.*)`,
+			replacement:    "/$2/version/$1",
+			expectedPath:   "/users/version/1",
+			expectedHeader: "/api/v1/users",
+		},
+		{
+			desc:           "no match",
+			path:           "/whoami",
+			regex:          `^/api/(.*)`,
+			replacement:    "/v1/$1",
+			expectedPath:   "/whoami",
+			expectedHeader: "",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var actualPath, actualHeader, requestURI string
+			handler, err := NewReplacePathRegex(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actualPath = r.URL.Path
+				actualHeader = r.Header.Get(ReplacedPathHeader)
+				requestURI = r.RequestURI
+			}), test.regex, test.replacement)
+			require.NoError(t, err)
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost"+test.path, nil)
+
+			handler.ServeHTTP(nil, req)
+
+			assert.Equal(t, test.expectedPath, actualPath, "Unexpected path.")
+			assert.Equal(t, test.expectedHeader, actualHeader, "Unexpected '%s' header.", ReplacedPathHeader)
+			if test.expectedHeader != "" {
+				assert.Equal(t, req.URL.RequestURI(), requestURI, "Unexpected request URI.")
+			}
+		})
+	}
+}
+
+func TestNewReplacePathRegexWithInvalidRegex(t *testing.T) {
+	_, err := NewReplacePathRegex(http.NotFoundHandler(), `/(`, "/")
+	assert.Error(t, err)
+}
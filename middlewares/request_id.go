@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/satori/go.uuid"
+)
+
+// RequestIDHeader is the header holding the ID of a request, toward the backend, in the response and in the access logs
+const RequestIDHeader = "X-Request-Id"
+
+// requestIDMaxLength is the maximum length of an incoming request ID
+const requestIDMaxLength = 200
+
+// RequestID is a middleware giving an ID to each request: the ID of the incoming request if any,
+// or a generated one.
+type RequestID struct{}
+
+// NewRequestID returns a new RequestID instance
+func NewRequestID() *RequestID {
+	return &RequestID{}
+}
+
+func (m *RequestID) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	id := r.Header.Get(RequestIDHeader)
+	if !isValidRequestID(id) {
+		id = uuid.NewV4().String()
+	}
+
+	r.Header.Set(RequestIDHeader, id)
+	rw.Header().Set(RequestIDHeader, id)
+
+	next.ServeHTTP(rw, r)
+}
+
+// isValidRequestID tells whether an incoming request ID can be kept: a not too long string of printable ASCII characters
+func isValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > requestIDMaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
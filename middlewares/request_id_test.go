@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		incomingID string
+		expectedID string
+	}{
+		{
+			desc:       "incoming request ID",
+			incomingID: "4f8e6a3c-correlation",
+			expectedID: "4f8e6a3c-correlation",
+		},
+		{
+			desc: "no incoming request ID",
+		},
+		{
+			desc:       "invalid incoming request ID",
+			incomingID: "foo bar",
+		},
+		{
+			desc:       "too long incoming request ID",
+			incomingID: strings.Repeat("a", requestIDMaxLength+1),
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://foo.com/", nil)
+			if test.incomingID != "" {
+				req.Header.Set(RequestIDHeader, test.incomingID)
+			}
+
+			var backendID string
+			NewRequestID().ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				backendID = r.Header.Get(RequestIDHeader)
+			})
+
+			assert.NotEmpty(t, backendID)
+			assert.Equal(t, backendID, recorder.Header().Get(RequestIDHeader))
+			if test.expectedID != "" {
+				assert.Equal(t, test.expectedID, backendID)
+			} else {
+				assert.NotEqual(t, test.incomingID, backendID)
+				assert.Len(t, backendID, 36)
+			}
+		})
+	}
+}
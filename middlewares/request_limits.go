@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/containous/traefik/log"
+)
+
+// RequestLimits is a middleware rejecting the requests whose headers or body are bigger than the limits,
+// before forwarding them.
+// The bodies of unknown size (chunked) are read in memory, up to the limit, to be checked.
+type RequestLimits struct {
+	maxBodyBytes   int64
+	maxHeaderBytes int64
+}
+
+// NewRequestLimits creates a RequestLimits middleware, a limit of 0 disabling the corresponding check
+func NewRequestLimits(maxBodyBytes, maxHeaderBytes int64) *RequestLimits {
+	return &RequestLimits{
+		maxBodyBytes:   maxBodyBytes,
+		maxHeaderBytes: maxHeaderBytes,
+	}
+}
+
+func (l *RequestLimits) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if l.maxHeaderBytes > 0 && headerSize(r) > l.maxHeaderBytes {
+		log.Debugf("Request headers bigger than %d bytes - rejecting", l.maxHeaderBytes)
+		http.Error(rw, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+		return
+	}
+
+	if l.maxBodyBytes > 0 && r.Body != nil {
+		if r.ContentLength > l.maxBodyBytes {
+			log.Debugf("Request body of %d bytes bigger than %d bytes - rejecting", r.ContentLength, l.maxBodyBytes)
+			http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if r.ContentLength < 0 {
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, l.maxBodyBytes+1))
+			r.Body.Close()
+			if err != nil {
+				log.Debugf("Error reading the request body: %v", err)
+				http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > l.maxBodyBytes {
+				log.Debugf("Request body bigger than %d bytes - rejecting", l.maxBodyBytes)
+				http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.TransferEncoding = nil
+		}
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+// headerSize returns the size of the request line and headers, as sent by the client
+func headerSize(r *http.Request) int64 {
+	size := int64(len(r.Method) + len(r.URL.RequestURI()) + len(r.Proto) + 4)
+	size += int64(len("Host: ") + len(r.Host) + 2)
+	for name, values := range r.Header {
+		for _, value := range values {
+			size += int64(len(name) + len(value) + 4)
+		}
+	}
+	return size
+}
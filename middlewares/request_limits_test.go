@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimits(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		maxBodyBytes       int64
+		maxHeaderBytes     int64
+		body               string
+		chunked            bool
+		header             string
+		expectedStatusCode int
+	}{
+		{
+			desc:               "no limits",
+			body:               strings.Repeat("a", 100),
+			header:             strings.Repeat("b", 100),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "body smaller than the limit",
+			maxBodyBytes:       10,
+			body:               "0123456789",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "body bigger than the limit",
+			maxBodyBytes:       10,
+			body:               "0123456789a",
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:               "chunked body smaller than the limit",
+			maxBodyBytes:       10,
+			body:               "0123456789",
+			chunked:            true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "chunked body bigger than the limit",
+			maxBodyBytes:       10,
+			body:               "0123456789a",
+			chunked:            true,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:               "headers smaller than the limit",
+			maxHeaderBytes:     1024,
+			header:             strings.Repeat("b", 100),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "headers bigger than the limit",
+			maxHeaderBytes:     1024,
+			header:             strings.Repeat("b", 1024),
+			expectedStatusCode: http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			limits := NewRequestLimits(test.maxBodyBytes, test.maxHeaderBytes)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://foo.com/", strings.NewReader(test.body))
+			if test.chunked {
+				req.ContentLength = -1
+			}
+			if test.header != "" {
+				req.Header.Set("X-Foo", test.header)
+			}
+
+			limits.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(body))
+				assert.EqualValues(t, len(test.body), r.ContentLength)
+			})
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// requestDurationsSize is the number of most recent durations the latency percentiles are computed on
+	requestDurationsSize = 1024
+	// requestRateWindow is the number of seconds the request rate is averaged over
+	requestRateWindow = 60
+)
+
+// RequestStatistics records the requests handled by a frontend, a backend or a server:
+// their count by status class, their rate and the one of the 5xx, the latencies of the most recent ones, and the requests in flight.
+type RequestStatistics struct {
+	mutex              sync.Mutex
+	now                func() time.Time
+	count              int64
+	currentRequests    int64
+	statusClassCount   map[string]int64
+	durations          [requestDurationsSize]time.Duration
+	durationsCount     int
+	rateBuckets        [requestRateWindow]int64
+	errorRateBuckets   [requestRateWindow]int64
+	rateBucketsSeconds [requestRateWindow]int64
+}
+
+func newRequestStatistics(now func() time.Time) *RequestStatistics {
+	return &RequestStatistics{now: now, statusClassCount: make(map[string]int64)}
+}
+
+// start records a request in flight
+func (s *RequestStatistics) start() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.currentRequests++
+	return s.now()
+}
+
+// done records the end of the request started at start
+func (s *RequestStatistics) done(start time.Time, statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.currentRequests--
+	s.count++
+	s.statusClassCount[strconv.Itoa(statusCode/100)+"xx"]++
+
+	s.durations[s.durationsCount%requestDurationsSize] = now.Sub(start)
+	s.durationsCount++
+
+	second := now.Unix()
+	bucket := second % requestRateWindow
+	if s.rateBucketsSeconds[bucket] != second {
+		s.rateBucketsSeconds[bucket] = second
+		s.rateBuckets[bucket] = 0
+		s.errorRateBuckets[bucket] = 0
+	}
+	s.rateBuckets[bucket]++
+	if statusCode >= http.StatusInternalServerError {
+		s.errorRateBuckets[bucket]++
+	}
+}
+
+// RequestStatisticsData is a snapshot of RequestStatistics
+type RequestStatisticsData struct {
+	Count            int64            `json:"count"`
+	CurrentRequests  int64            `json:"current_requests"`
+	RequestRate      float64          `json:"request_rate"`
+	ErrorRate        float64          `json:"error_rate"`
+	StatusClassCount map[string]int64 `json:"status_class_count"`
+	P50ResponseTime  float64          `json:"p50_response_time_sec"`
+	P95ResponseTime  float64          `json:"p95_response_time_sec"`
+	P99ResponseTime  float64          `json:"p99_response_time_sec"`
+}
+
+// Data returns a snapshot of the statistics, the request and error rates being in requests per second
+func (s *RequestStatistics) Data() *RequestStatisticsData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := &RequestStatisticsData{
+		Count:            s.count,
+		CurrentRequests:  s.currentRequests,
+		StatusClassCount: make(map[string]int64, len(s.statusClassCount)),
+	}
+	for statusClass, count := range s.statusClassCount {
+		data.StatusClassCount[statusClass] = count
+	}
+
+	second := s.now().Unix()
+	var lastRequests, lastErrors int64
+	for bucket, bucketSecond := range s.rateBucketsSeconds {
+		if second-bucketSecond < requestRateWindow {
+			lastRequests += s.rateBuckets[bucket]
+			lastErrors += s.errorRateBuckets[bucket]
+		}
+	}
+	data.RequestRate = float64(lastRequests) / requestRateWindow
+	data.ErrorRate = float64(lastErrors) / requestRateWindow
+
+	size := s.durationsCount
+	if size > requestDurationsSize {
+		size = requestDurationsSize
+	}
+	durations := make([]time.Duration, size)
+	copy(durations, s.durations[:size])
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	data.P50ResponseTime = percentile(durations, 0.50)
+	data.P95ResponseTime = percentile(durations, 0.95)
+	data.P99ResponseTime = percentile(durations, 0.99)
+	return data
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations, in seconds
+func percentile(sortedDurations []time.Duration, p float64) float64 {
+	if len(sortedDurations) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sortedDurations)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sortedDurations[rank].Seconds()
+}
+
+// StatisticsRegistry holds the RequestStatistics of the frontends, backends and servers
+type StatisticsRegistry struct {
+	mutex     sync.Mutex
+	now       func() time.Time
+	frontends map[string]*RequestStatistics
+	backends  map[string]*RequestStatistics
+	servers   map[string]map[string]*RequestStatistics
+}
+
+// NewStatisticsRegistry returns an empty StatisticsRegistry
+func NewStatisticsRegistry() *StatisticsRegistry {
+	return &StatisticsRegistry{
+		now:       time.Now,
+		frontends: make(map[string]*RequestStatistics),
+		backends:  make(map[string]*RequestStatistics),
+		servers:   make(map[string]map[string]*RequestStatistics),
+	}
+}
+
+func (r *StatisticsRegistry) frontend(frontendName string) *RequestStatistics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.frontends[frontendName]; !ok {
+		r.frontends[frontendName] = newRequestStatistics(r.now)
+	}
+	return r.frontends[frontendName]
+}
+
+func (r *StatisticsRegistry) backend(backendName string) *RequestStatistics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.backends[backendName]; !ok {
+		r.backends[backendName] = newRequestStatistics(r.now)
+	}
+	return r.backends[backendName]
+}
+
+func (r *StatisticsRegistry) server(backendName, serverURL string) *RequestStatistics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.servers[backendName]; !ok {
+		r.servers[backendName] = make(map[string]*RequestStatistics)
+	}
+	if _, ok := r.servers[backendName][serverURL]; !ok {
+		r.servers[backendName][serverURL] = newRequestStatistics(r.now)
+	}
+	return r.servers[backendName][serverURL]
+}
+
+// StatisticsData is a snapshot of the statistics of the frontends and backends
+type StatisticsData struct {
+	Frontends map[string]*RequestStatisticsData        `json:"frontends"`
+	Backends  map[string]*BackendRequestStatisticsData `json:"backends"`
+}
+
+// BackendRequestStatisticsData is a snapshot of the statistics of a backend and of its servers, indexed by URL
+type BackendRequestStatisticsData struct {
+	*RequestStatisticsData
+	Servers map[string]*RequestStatisticsData `json:"servers"`
+}
+
+// Data returns a snapshot of the statistics of the frontends and backends
+func (r *StatisticsRegistry) Data() *StatisticsData {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data := &StatisticsData{
+		Frontends: make(map[string]*RequestStatisticsData, len(r.frontends)),
+		Backends:  make(map[string]*BackendRequestStatisticsData, len(r.backends)),
+	}
+	for frontendName, frontend := range r.frontends {
+		data.Frontends[frontendName] = frontend.Data()
+	}
+	for backendName, backend := range r.backends {
+		backendData := &BackendRequestStatisticsData{
+			RequestStatisticsData: backend.Data(),
+			Servers:               make(map[string]*RequestStatisticsData, len(r.servers[backendName])),
+		}
+		for serverURL, server := range r.servers[backendName] {
+			backendData.Servers[serverURL] = server.Data()
+		}
+		data.Backends[backendName] = backendData
+	}
+	return data
+}
+
+// FrontendStatistics is a Negroni compatible Handler recording the requests of a frontend
+type FrontendStatistics struct {
+	statistics *RequestStatistics
+}
+
+// NewFrontendStatistics returns the FrontendStatistics of the frontend, recording in the registry
+func NewFrontendStatistics(registry *StatisticsRegistry, frontendName string) *FrontendStatistics {
+	return &FrontendStatistics{statistics: registry.frontend(frontendName)}
+}
+
+func (f *FrontendStatistics) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := f.statistics.start()
+	recorder := &responseRecorder{rw, http.StatusOK}
+	defer func() {
+		f.statistics.done(start, recorder.statusCode)
+	}()
+	next(recorder, r)
+}
+
+// BackendStatistics is a http.RoundTripper recording the requests forwarded to a backend and to each of its servers
+type BackendStatistics struct {
+	registry    *StatisticsRegistry
+	statistics  *RequestStatistics
+	backendName string
+	next        http.RoundTripper
+}
+
+// NewBackendStatistics returns the BackendStatistics of the backend, forwarding the requests with next
+func NewBackendStatistics(registry *StatisticsRegistry, backendName string, next http.RoundTripper) *BackendStatistics {
+	return &BackendStatistics{
+		registry:    registry,
+		statistics:  registry.backend(backendName),
+		backendName: backendName,
+		next:        next,
+	}
+}
+
+// RoundTrip forwards the request, the failed round trips being recorded as bad gateways
+func (b *BackendStatistics) RoundTrip(req *http.Request) (*http.Response, error) {
+	server := b.registry.server(b.backendName, req.URL.Scheme+"://"+req.URL.Host)
+	backendStart := b.statistics.start()
+	serverStart := server.start()
+
+	statusCode := http.StatusBadGateway
+	defer func() {
+		server.done(serverStart, statusCode)
+		b.statistics.done(backendStart, statusCode)
+	}()
+
+	resp, err := b.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	statusCode = resp.StatusCode
+	return resp, nil
+}
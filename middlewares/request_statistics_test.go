@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.current
+}
+
+func TestRequestStatistics(t *testing.T) {
+	clock := &fakeClock{current: time.Unix(1000, 0)}
+	statistics := newRequestStatistics(clock.now)
+
+	// 100 requests from 1ms to 100ms, one every 100ms
+	for i := 1; i <= 100; i++ {
+		start := statistics.start()
+		clock.current = clock.current.Add(time.Duration(i) * time.Millisecond)
+		statusCode := http.StatusOK
+		if i%10 == 0 {
+			statusCode = http.StatusInternalServerError
+		}
+		statistics.done(start, statusCode)
+		clock.current = clock.current.Add(100*time.Millisecond - time.Duration(i)*time.Millisecond)
+	}
+	statistics.start()
+
+	data := statistics.Data()
+	assert.Equal(t, int64(100), data.Count)
+	assert.Equal(t, int64(1), data.CurrentRequests)
+	assert.Equal(t, map[string]int64{"2xx": 90, "5xx": 10}, data.StatusClassCount)
+	assert.InDelta(t, 100.0/60, data.RequestRate, 0.001)
+	assert.InDelta(t, 10.0/60, data.ErrorRate, 0.001)
+	assert.InDelta(t, 0.050, data.P50ResponseTime, 1e-9)
+	assert.InDelta(t, 0.095, data.P95ResponseTime, 1e-9)
+	assert.InDelta(t, 0.099, data.P99ResponseTime, 1e-9)
+
+	// the requests of more than a minute ago are out of the rate
+	clock.current = clock.current.Add(time.Minute)
+	data = statistics.Data()
+	assert.Equal(t, 0.0, data.RequestRate)
+	assert.Equal(t, 0.0, data.ErrorRate)
+	assert.Equal(t, int64(100), data.Count)
+}
+
+func TestRequestStatisticsDurationsWindow(t *testing.T) {
+	clock := &fakeClock{current: time.Unix(1000, 0)}
+	statistics := newRequestStatistics(clock.now)
+
+	for i := 0; i < requestDurationsSize; i++ {
+		start := statistics.start()
+		clock.current = clock.current.Add(time.Second)
+		statistics.done(start, http.StatusOK)
+	}
+	// only the most recent durations are kept
+	for i := 0; i < requestDurationsSize; i++ {
+		start := statistics.start()
+		clock.current = clock.current.Add(time.Millisecond)
+		statistics.done(start, http.StatusOK)
+	}
+
+	data := statistics.Data()
+	assert.Equal(t, int64(2*requestDurationsSize), data.Count)
+	assert.InDelta(t, 0.001, data.P99ResponseTime, 1e-9)
+}
+
+func TestRequestStatisticsEmpty(t *testing.T) {
+	data := newRequestStatistics(time.Now).Data()
+
+	assert.Equal(t, &RequestStatisticsData{StatusClassCount: map[string]int64{}}, data)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestStatisticsRegistry(t *testing.T) {
+	registry := NewStatisticsRegistry()
+
+	frontend := NewFrontendStatistics(registry, "frontend")
+	backend := NewBackendStatistics(registry, "backend", roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "10.0.0.2:80" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusNotFound}, nil
+	}))
+
+	recorder := httptest.NewRecorder()
+	frontend.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil), func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	})
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	_, err := backend.RoundTrip(httptest.NewRequest(http.MethodGet, "http://10.0.0.1:80/", nil))
+	require.NoError(t, err)
+	_, err = backend.RoundTrip(httptest.NewRequest(http.MethodGet, "http://10.0.0.2:80/", nil))
+	require.Error(t, err)
+
+	data := registry.Data()
+	require.Contains(t, data.Frontends, "frontend")
+	assert.Equal(t, map[string]int64{"4xx": 1}, data.Frontends["frontend"].StatusClassCount)
+
+	require.Contains(t, data.Backends, "backend")
+	assert.Equal(t, int64(2), data.Backends["backend"].Count)
+	assert.Equal(t, int64(0), data.Backends["backend"].CurrentRequests)
+	require.Len(t, data.Backends["backend"].Servers, 2)
+	assert.Equal(t, map[string]int64{"4xx": 1}, data.Backends["backend"].Servers["http://10.0.0.1:80"].StatusClassCount)
+	assert.Equal(t, map[string]int64{"5xx": 1}, data.Backends["backend"].Servers["http://10.0.0.2:80"].StatusClassCount)
+}
@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/containous/traefik/log"
 	"github.com/vulcand/oxy/utils"
@@ -17,27 +20,38 @@ var (
 	_ Stateful = &retryResponseRecorder{}
 )
 
+// RetryAttemptHeader is the header giving the attempt number of a retried request to the backend
+const RetryAttemptHeader = "X-Retry-Attempt"
+
 // Retry is a middleware that retries requests
 type Retry struct {
-	attempts int
-	next     http.Handler
-	listener RetryListener
+	attempts       int
+	httpCodeRanges HTTPCodeRanges
+	nonIdempotent  bool
+	next           http.Handler
+	listener       RetryListener
 }
 
-// NewRetry returns a new Retry instance
-func NewRetry(attempts int, next http.Handler, listener RetryListener) *Retry {
+// NewRetry returns a new Retry instance.
+// Requests are retried on network errors and, if they are idempotent or nonIdempotent is set, on the responses
+// whose status code is in httpCodeRanges, as long as their body is empty or buffered to be sent again.
+func NewRetry(attempts int, httpCodeRanges HTTPCodeRanges, nonIdempotent bool, next http.Handler, listener RetryListener) *Retry {
 	return &Retry{
-		attempts: attempts,
-		next:     next,
-		listener: listener,
+		attempts:       attempts,
+		httpCodeRanges: httpCodeRanges,
+		nonIdempotent:  nonIdempotent,
+		next:           next,
+		listener:       listener,
 	}
 }
 
 func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	// if we might make multiple attempts, swap the body for an ioutil.NopCloser
 	// cf https://github.com/containous/traefik/issues/1008
+	body := r.Body
+	_, rewindable := body.(io.Seeker)
+	statusRetryable := (isIdempotent(r.Method) || retry.nonIdempotent) && (hasNoBody(r) || rewindable)
 	if retry.attempts > 1 {
-		body := r.Body
 		defer body.Close()
 		r.Body = ioutil.NopCloser(body)
 	}
@@ -59,18 +73,44 @@ func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		if !netErrorOccurred || attempts >= retry.attempts {
+		retryStatus := statusRetryable && retry.httpCodeRanges.Contains(recorder.Code)
+		if !(netErrorOccurred || retryStatus) || attempts >= retry.attempts {
 			utils.CopyHeaders(rw.Header(), recorder.Header())
 			rw.WriteHeader(recorder.Code)
 			rw.Write(recorder.Body.Bytes())
 			break
 		}
+		// a buffered body is rewound to be sent again
+		if rewindable {
+			if _, err := body.(io.Seeker).Seek(0, io.SeekStart); err != nil {
+				log.Errorf("Error rewinding the request body: %v", err)
+				utils.CopyHeaders(rw.Header(), recorder.Header())
+				rw.WriteHeader(recorder.Code)
+				rw.Write(recorder.Body.Bytes())
+				break
+			}
+		}
 		attempts++
 		log.Debugf("New attempt %d for request: %v", attempts, r.URL)
+		r.Header.Set(RetryAttemptHeader, strconv.Itoa(attempts))
 		retry.listener.Retried(r, attempts)
 	}
 }
 
+// isIdempotent tells whether a request can be replayed safely, according to its method
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// hasNoBody tells whether a request has no body, which does not have to be rewound to be sent again
+func hasNoBody(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+}
+
 // netErrorCtxKey is a custom type that is used as key for the context.
 type netErrorCtxKey string
 
@@ -160,14 +200,26 @@ func (rw *retryResponseRecorder) WriteHeader(code int) {
 
 // Hijack hijacks the connection
 func (rw *retryResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return rw.responseWriter.(http.Hijacker).Hijack()
+	if h, ok := rw.responseWriter.(http.Hijacker); ok {
+		conn, brw, err := h.Hijack()
+		if err == nil {
+			// the response is now handled by the owner of the connection
+			rw.streamingResponseStarted = true
+		}
+		return conn, brw, err
+	}
+	// HTTP/2 response writers can not be hijacked
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.responseWriter)
 }
 
 // CloseNotify returns a channel that receives at most a
 // single value (true) when the client connection has gone
 // away.
 func (rw *retryResponseRecorder) CloseNotify() <-chan bool {
-	return rw.responseWriter.(http.CloseNotifier).CloseNotify()
+	if c, ok := rw.responseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
 }
 
 // Flush sends any buffered data to the client.
@@ -3,9 +3,12 @@ package middlewares
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -42,7 +45,7 @@ func TestRetry(t *testing.T) {
 			t.Parallel()
 
 			var httpHandler http.Handler = &networkFailingHTTPHandler{failAtCalls: tc.failAtCalls, netErrorRecorder: &DefaultNetErrorRecorder{}}
-			httpHandler = NewRetry(tc.attempts, httpHandler, tc.listener)
+			httpHandler = NewRetry(tc.attempts, nil, false, httpHandler, tc.listener)
 
 			recorder := httptest.NewRecorder()
 			req, err := http.NewRequest("GET", "http://localhost:3000/ok", ioutil.NopCloser(nil))
@@ -62,6 +65,116 @@ func TestRetry(t *testing.T) {
 	}
 }
 
+func TestRetryOnStatus(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		method           string
+		body             io.Reader
+		nonIdempotent    bool
+		attempts         int
+		expectedStatus   int
+		expectedCalls    int
+		expectedAttempts []string
+	}{
+		{
+			desc:             "idempotent request retried until success",
+			method:           http.MethodGet,
+			attempts:         3,
+			expectedStatus:   http.StatusOK,
+			expectedCalls:    3,
+			expectedAttempts: []string{"", "2", "3"},
+		},
+		{
+			desc:             "idempotent request retried until max attempts",
+			method:           http.MethodPut,
+			attempts:         2,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedCalls:    2,
+			expectedAttempts: []string{"", "2"},
+		},
+		{
+			desc:             "non idempotent request not retried",
+			method:           http.MethodPost,
+			attempts:         3,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedCalls:    1,
+			expectedAttempts: []string{""},
+		},
+		{
+			desc:             "non idempotent request retried when enabled",
+			method:           http.MethodPost,
+			nonIdempotent:    true,
+			attempts:         3,
+			expectedStatus:   http.StatusOK,
+			expectedCalls:    3,
+			expectedAttempts: []string{"", "2", "3"},
+		},
+		{
+			desc:             "idempotent request with a body not retried",
+			method:           http.MethodPut,
+			body:             strings.NewReader("request body"),
+			attempts:         3,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedCalls:    1,
+			expectedAttempts: []string{""},
+		},
+		{
+			desc:             "idempotent request with a rewindable body retried",
+			method:           http.MethodPut,
+			body:             &seekableBody{Reader: strings.NewReader("request body")},
+			attempts:         3,
+			expectedStatus:   http.StatusOK,
+			expectedCalls:    3,
+			expectedAttempts: []string{"", "2", "3"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// answers 503 twice, then 200
+			var attempts []string
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				attempts = append(attempts, req.Header.Get(RetryAttemptHeader))
+				if len(attempts) <= 2 {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			httpCodeRanges, err := NewHTTPCodeRanges([]string{"502-504"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			retry := NewRetry(test.attempts, httpCodeRanges, test.nonIdempotent, next, &countingRetryListener{})
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, "/", nil)
+			if test.body != nil {
+				req.Body = ioutil.NopCloser(test.body)
+				if seeker, ok := test.body.(*seekableBody); ok {
+					req.Body = seeker
+				}
+				req.ContentLength = -1
+			}
+			retry.ServeHTTP(recorder, req)
+
+			if recorder.Code != test.expectedStatus {
+				t.Errorf("wrong status code %d, want %d", recorder.Code, test.expectedStatus)
+			}
+			if len(attempts) != test.expectedCalls {
+				t.Errorf("backend called %d times, want %d times", len(attempts), test.expectedCalls)
+			}
+			if !reflect.DeepEqual(attempts, test.expectedAttempts) {
+				t.Errorf("got attempt headers %q, want %q", attempts, test.expectedAttempts)
+			}
+		})
+	}
+}
+
 func TestDefaultNetErrorRecorderSuccess(t *testing.T) {
 	boolNetErrorOccurred := false
 	recorder := DefaultNetErrorRecorder{}
@@ -104,6 +217,20 @@ func TestRetryListeners(t *testing.T) {
 	}
 }
 
+func TestRetryResponseRecorderHTTP2(t *testing.T) {
+	// httptest.ResponseRecorder, like the HTTP/2 response writers, can not be hijacked
+	recorder := newRetryResponseRecorder()
+	recorder.responseWriter = httptest.NewRecorder()
+
+	_, _, err := recorder.Hijack()
+	if err == nil {
+		t.Error("got no error hijacking a response writer which is not a hijacker")
+	}
+	if ch := recorder.CloseNotify(); ch != nil {
+		t.Error("got a close notification channel from a response writer which is not a close notifier")
+	}
+}
+
 // networkFailingHTTPHandler is an http.Handler implementation you can use to test retries.
 type networkFailingHTTPHandler struct {
 	netErrorRecorder NetErrorRecorder
@@ -126,6 +253,15 @@ func (handler *networkFailingHTTPHandler) ServeHTTP(w http.ResponseWriter, r *ht
 	w.WriteHeader(http.StatusOK)
 }
 
+// seekableBody is a request body which can be rewound, like the ones buffered by the buffering middleware
+type seekableBody struct {
+	*strings.Reader
+}
+
+func (seekableBody) Close() error {
+	return nil
+}
+
 // countingRetryListener is a RetryListener implementation to count the times the Retried fn is called.
 type countingRetryListener struct {
 	timesCalled int
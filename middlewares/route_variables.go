@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/log"
+)
+
+// routeVariableRegexp matches the {name} placeholders of the route variables
+var routeVariableRegexp = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// HasRouteVariables returns true if the template has {name} placeholders
+func HasRouteVariables(template string) bool {
+	return routeVariableRegexp.MatchString(template)
+}
+
+// RouteVariablesRegexp returns the regular expression matching the strings the template can be expanded to,
+// whatever the values of the route variables
+func RouteVariablesRegexp(template string) *regexp.Regexp {
+	expr := "^"
+	last := 0
+	for _, placeholder := range routeVariableRegexp.FindAllStringIndex(template, -1) {
+		expr += regexp.QuoteMeta(template[last:placeholder[0]]) + ".*"
+		last = placeholder[1]
+	}
+	return regexp.MustCompile(expr + regexp.QuoteMeta(template[last:]) + "$")
+}
+
+// ExpandRouteVariables replaces the {name} placeholders of the template by the variables captured by the
+// matched route, e.g. the {subdomain} of HostRegexp:{subdomain:[a-z]+}.example.com.
+// The placeholders of unknown variables are kept.
+func ExpandRouteVariables(template string, r *http.Request) string {
+	if !HasRouteVariables(template) {
+		return template
+	}
+	vars := mux.Vars(r)
+	return routeVariableRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, ok := vars[placeholder[1:len(placeholder)-1]]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// BackendTemplate is a http.Handler forwarding the requests to the backend named by a template of the route variables
+type BackendTemplate struct {
+	template string
+	backends map[string]http.Handler
+}
+
+// NewBackendTemplate creates a BackendTemplate choosing among the backends, indexed by name
+func NewBackendTemplate(template string, backends map[string]http.Handler) *BackendTemplate {
+	return &BackendTemplate{template: template, backends: backends}
+}
+
+func (b *BackendTemplate) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	backendName := ExpandRouteVariables(b.template, r)
+	backend, ok := b.backends[backendName]
+	if !ok {
+		log.Debugf("No backend %s for template %s", backendName, b.template)
+		http.NotFound(rw, r)
+		return
+	}
+	backend.ServeHTTP(rw, r)
+}
@@ -0,0 +1,147 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandRouteVariables(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		template string
+		expected string
+	}{
+		{
+			desc:     "no placeholder",
+			template: "/users",
+			expected: "/users",
+		},
+		{
+			desc:     "variables",
+			template: "/{tenant}/users/{id}",
+			expected: "/acme/users/42",
+		},
+		{
+			desc:     "unknown variable",
+			template: "/{tenant}/{unknown}",
+			expected: "/acme/{unknown}",
+		},
+		{
+			desc:     "request template",
+			template: "https://{{.Request.Host}}/{tenant}",
+			expected: "https://{{.Request.Host}}/acme",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var expanded string
+			router := mux.NewRouter()
+			router.Path("/{tenant}/users/{id}").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				expanded = ExpandRouteVariables(test.template, r)
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/acme/users/42", nil))
+
+			assert.Equal(t, test.expected, expanded)
+		})
+	}
+}
+
+func TestRouteVariablesRegexp(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		template string
+		value    string
+		expected bool
+	}{
+		{
+			desc:     "expansion",
+			template: "backend-{tenant}.{env}",
+			value:    "backend-acme.prod",
+			expected: true,
+		},
+		{
+			desc:     "other prefix",
+			template: "backend-{tenant}.{env}",
+			value:    "other-acme.prod",
+		},
+		{
+			desc:     "literal dot",
+			template: "backend-{tenant}.{env}",
+			value:    "backend-acmeprod",
+		},
+		{
+			desc:     "no placeholder",
+			template: "backend",
+			value:    "backend",
+			expected: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, RouteVariablesRegexp(test.template).MatchString(test.value))
+		})
+	}
+}
+
+func TestBackendTemplate(t *testing.T) {
+	newBackend := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte(name))
+		})
+	}
+	backendTemplate := NewBackendTemplate("backend-{subdomain}", map[string]http.Handler{
+		"backend-foo": newBackend("foo"),
+		"backend-bar": newBackend("bar"),
+	})
+
+	router := mux.NewRouter()
+	router.Host("{subdomain:[a-z]+}.example.com").Handler(backendTemplate)
+
+	testCases := []struct {
+		host               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			host:               "foo.example.com",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "foo",
+		},
+		{
+			host:               "bar.example.com",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "bar",
+		},
+		{
+			host:               "baz.example.com",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.host, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://"+test.host+"/", nil))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSecure(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		headers            types.Headers
+		url                string
+		secure             bool
+		expectedStatusCode int
+		expectedHeaders    map[string]string
+	}{
+		{
+			desc: "HSTS with subdomains and preload",
+			headers: types.Headers{
+				STSSeconds:           31536000,
+				STSIncludeSubdomains: true,
+				STSPreload:           true,
+			},
+			url:                "https://example.com/foo",
+			secure:             true,
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubdomains; preload",
+			},
+		},
+		{
+			desc: "no HSTS over HTTP",
+			headers: types.Headers{
+				STSSeconds: 31536000,
+			},
+			url:                "http://example.com/foo",
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			desc: "forced HSTS over HTTP",
+			headers: types.Headers{
+				STSSeconds:     315360,
+				ForceSTSHeader: true,
+			},
+			url:                "http://example.com/foo",
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=315360",
+			},
+		},
+		{
+			desc: "browser headers",
+			headers: types.Headers{
+				FrameDeny:             true,
+				ContentTypeNosniff:    true,
+				BrowserXSSFilter:      true,
+				ReferrerPolicy:        "same-origin",
+				ContentSecurityPolicy: "default-src 'self'",
+			},
+			url:                "http://example.com/foo",
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"X-Frame-Options":         "DENY",
+				"X-Content-Type-Options":  "nosniff",
+				"X-Xss-Protection":        "1; mode=block",
+				"Referrer-Policy":         "same-origin",
+				"Content-Security-Policy": "default-src 'self'",
+			},
+		},
+		{
+			desc: "custom frame options",
+			headers: types.Headers{
+				CustomFrameOptionsValue: "SAMEORIGIN",
+			},
+			url:                "http://example.com/foo",
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"X-Frame-Options": "SAMEORIGIN",
+			},
+		},
+		{
+			desc: "SSL redirect",
+			headers: types.Headers{
+				SSLRedirect: true,
+			},
+			url:                "http://example.com/foo?bar=1",
+			expectedStatusCode: http.StatusMovedPermanently,
+			expectedHeaders: map[string]string{
+				"Location": "https://example.com/foo?bar=1",
+			},
+		},
+		{
+			desc: "SSL temporary redirect to the SSL host",
+			headers: types.Headers{
+				SSLRedirect:          true,
+				SSLTemporaryRedirect: true,
+				SSLHost:              "secure.example.com",
+			},
+			url:                "http://example.com/foo",
+			expectedStatusCode: http.StatusTemporaryRedirect,
+			expectedHeaders: map[string]string{
+				"Location": "https://secure.example.com/foo",
+			},
+		},
+		{
+			desc: "no SSL redirect over HTTPS",
+			headers: types.Headers{
+				SSLRedirect: true,
+			},
+			url:                "https://example.com/foo",
+			secure:             true,
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Location": "",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+			req := testhelpers.MustNewRequest(http.MethodGet, test.url, nil)
+			if test.secure {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			NewSecure(test.headers).Handler(myHandler).ServeHTTP(res, req)
+
+			assert.Equal(t, test.expectedStatusCode, res.Code)
+			for header, value := range test.expectedHeaders {
+				assert.Equal(t, value, res.Header().Get(header), header)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"bufio"
+	"fmt"
 	"net"
 	"net/http"
 	"sync"
@@ -59,19 +60,28 @@ func (r *responseRecorder) WriteHeader(status int) {
 
 // Hijack hijacks the connection
 func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return r.ResponseWriter.(http.Hijacker).Hijack()
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	// HTTP/2 response writers can not be hijacked
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", r.ResponseWriter)
 }
 
 // CloseNotify returns a channel that receives at most a
 // single value (true) when the client connection has gone
 // away.
 func (r *responseRecorder) CloseNotify() <-chan bool {
-	return r.ResponseWriter.(http.CloseNotifier).CloseNotify()
+	if c, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
 }
 
 // Flush sends any buffered data to the client.
 func (r *responseRecorder) Flush() {
-	r.ResponseWriter.(http.Flusher).Flush()
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 // ServeHTTP silently extracts information from the request and response as it
@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Compile time validation stickyCookieResponseWriter implements http interfaces correctly.
+var (
+	_ Stateful = &stickyCookieResponseWriter{}
+)
+
+// StickyCookie is a middleware adding the Secure, HttpOnly and SameSite attributes
+// to the sticky session cookie set by the load balancer.
+type StickyCookie struct {
+	next       http.Handler
+	cookieName string
+	attributes string
+}
+
+// NewStickyCookie returns a new StickyCookie instance
+func NewStickyCookie(next http.Handler, cookieName string, secure, httpOnly bool, sameSite string) *StickyCookie {
+	var attributes string
+	if secure {
+		attributes += "; Secure"
+	}
+	if httpOnly {
+		attributes += "; HttpOnly"
+	}
+	if len(sameSite) > 0 {
+		attributes += "; SameSite=" + sameSite
+	}
+
+	return &StickyCookie{
+		next:       next,
+		cookieName: cookieName,
+		attributes: attributes,
+	}
+}
+
+func (s *StickyCookie) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.next.ServeHTTP(&stickyCookieResponseWriter{ResponseWriter: rw, stickyCookie: s}, r)
+}
+
+type stickyCookieResponseWriter struct {
+	http.ResponseWriter
+	stickyCookie *StickyCookie
+	wroteHeader  bool
+}
+
+// updateCookie completes the sticky cookie before the headers are sent
+func (rw *stickyCookieResponseWriter) updateCookie() {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
+	cookies := rw.Header()["Set-Cookie"]
+	for i, cookie := range cookies {
+		if strings.HasPrefix(cookie, rw.stickyCookie.cookieName+"=") {
+			cookies[i] = cookie + rw.stickyCookie.attributes
+		}
+	}
+}
+
+func (rw *stickyCookieResponseWriter) WriteHeader(code int) {
+	rw.updateCookie()
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *stickyCookieResponseWriter) Write(b []byte) (int, error) {
+	rw.updateCookie()
+	return rw.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection
+func (rw *stickyCookieResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	// HTTP/2 response writers can not be hijacked
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.ResponseWriter)
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *stickyCookieResponseWriter) CloseNotify() <-chan bool {
+	if c, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client.
+func (rw *stickyCookieResponseWriter) Flush() {
+	rw.updateCookie()
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
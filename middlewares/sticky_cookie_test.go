@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStickyCookie(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "_sticky", Value: "http://10.0.0.1:80", Path: "/"})
+		http.SetCookie(rw, &http.Cookie{Name: "other", Value: "value"})
+		rw.Write([]byte("ok"))
+	})
+
+	testCases := []struct {
+		desc     string
+		secure   bool
+		httpOnly bool
+		sameSite string
+		expected string
+	}{
+		{
+			desc:     "no attribute",
+			expected: "_sticky=http://10.0.0.1:80; Path=/",
+		},
+		{
+			desc:     "all attributes",
+			secure:   true,
+			httpOnly: true,
+			sameSite: "Strict",
+			expected: "_sticky=http://10.0.0.1:80; Path=/; Secure; HttpOnly; SameSite=Strict",
+		},
+		{
+			desc:     "SameSite only",
+			sameSite: "Lax",
+			expected: "_sticky=http://10.0.0.1:80; Path=/; SameSite=Lax",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			handler := NewStickyCookie(next, "_sticky", test.secure, test.httpOnly, test.sameSite)
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, []string{test.expected, "other=value"}, recorder.Header()["Set-Cookie"])
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// XForwardedTLSClientCert holds the URL encoded PEM of the certificate presented by the client
+	XForwardedTLSClientCert = "X-Forwarded-Tls-Client-Cert"
+	// XForwardedTLSClientCertSubject holds the distinguished name of the certificate presented by the client
+	XForwardedTLSClientCertSubject = "X-Forwarded-Tls-Client-Cert-Subject"
+)
+
+// TLSClientHeaders is a middleware that forwards the certificate presented by the client to the backend
+type TLSClientHeaders struct{}
+
+// NewTLSClientHeaders builds a new TLSClientHeaders
+func NewTLSClientHeaders() *TLSClientHeaders {
+	return &TLSClientHeaders{}
+}
+
+func (t *TLSClientHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	// never trust the headers sent by the client
+	r.Header.Del(XForwardedTLSClientCert)
+	r.Header.Del(XForwardedTLSClientCertSubject)
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cert := r.TLS.PeerCertificates[0]
+		r.Header.Set(XForwardedTLSClientCert, url.QueryEscape(getCertificatePEM(cert)))
+		r.Header.Set(XForwardedTLSClientCertSubject, getDistinguishedName(cert.Subject))
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+func getCertificatePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func getDistinguishedName(name pkix.Name) string {
+	var parts []string
+	for _, attribute := range []struct {
+		key    string
+		values []string
+	}{
+		{key: "C", values: name.Country},
+		{key: "ST", values: name.Province},
+		{key: "L", values: name.Locality},
+		{key: "O", values: name.Organization},
+		{key: "OU", values: name.OrganizationalUnit},
+		{key: "CN", values: []string{name.CommonName}},
+	} {
+		for _, value := range attribute.values {
+			if len(value) > 0 {
+				parts = append(parts, attribute.key+"="+value)
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
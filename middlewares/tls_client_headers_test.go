@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSClientHeaders(t *testing.T) {
+	peerCert := &x509.Certificate{
+		Raw: []byte("raw certificate"),
+		Subject: pkix.Name{
+			Country:      []string{"FR"},
+			Organization: []string{"Containous"},
+			CommonName:   "client.traefik.io",
+		},
+	}
+
+	tests := []struct {
+		desc        string
+		tlsState    *tls.ConnectionState
+		wantCert    string
+		wantSubject string
+	}{
+		{
+			desc: "no TLS",
+		},
+		{
+			desc:     "no client certificate",
+			tlsState: &tls.ConnectionState{},
+		},
+		{
+			desc:        "client certificate",
+			tlsState:    &tls.ConnectionState{PeerCertificates: []*x509.Certificate{peerCert}},
+			wantCert:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: peerCert.Raw})),
+			wantSubject: "C=FR,O=Containous,CN=client.traefik.io",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://example.com/foo", nil)
+			req.Header.Set(XForwardedTLSClientCert, "spoofed")
+			req.Header.Set(XForwardedTLSClientCertSubject, "spoofed")
+			req.TLS = test.tlsState
+
+			var gotCert, gotSubject string
+			next := func(rw http.ResponseWriter, r *http.Request) {
+				gotCert = r.Header.Get(XForwardedTLSClientCert)
+				gotSubject = r.Header.Get(XForwardedTLSClientCertSubject)
+			}
+
+			NewTLSClientHeaders().ServeHTTP(httptest.NewRecorder(), req, next)
+
+			unescapedCert, err := url.QueryUnescape(gotCert)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCert, unescapedCert)
+			assert.Equal(t, test.wantSubject, gotSubject)
+		})
+	}
+}
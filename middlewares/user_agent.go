@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/pkg/errors"
+)
+
+// XBotHeader is the header holding, toward the backend, the part of the User-Agent identifying a bot
+const XBotHeader = "X-Bot"
+
+// Actions for the known bots of a user-agent filter
+const (
+	UserAgentActionBlock = "block"
+	UserAgentActionTag   = "tag"
+)
+
+// knownBots matches the User-Agent of the usual crawlers, SEO tools, scrapers and HTTP libraries
+var knownBots = regexp.MustCompile(`(?i)` + strings.Join([]string{
+	`googlebot`, `bingbot`, `slurp`, `duckduckbot`, `baiduspider`, `yandex(bot)?`, `sogou`, `exabot`, `facebot`,
+	`facebookexternalhit`, `ia_archiver`, `ahrefsbot`, `semrushbot`, `mj12bot`, `dotbot`, `petalbot`, `applebot`,
+	`twitterbot`, `linkedinbot`, `slackbot`, `discordbot`, `telegrambot`, `whatsapp`, `python-requests`,
+	`python-urllib`, `go-http-client`, `curl`, `wget`, `libwww-perl`, `java/`, `okhttp`, `scrapy`, `headlesschrome`,
+	`phantomjs`, `[a-z0-9_-]*(bot|crawler|spider)`,
+}, "|"))
+
+// UserAgentFilter is a middleware rejecting the requests whose User-Agent matches the blocked patterns,
+// and telling the backend whether the others come from a bot in the X-Bot header
+type UserAgentFilter struct {
+	blocked   []*regexp.Regexp
+	bots      []*regexp.Regexp
+	knownBots string
+}
+
+// NewUserAgentFilter creates the middleware filtering the requests of a frontend by User-Agent
+func NewUserAgentFilter(config *types.UserAgent) (*UserAgentFilter, error) {
+	if len(config.Blocked) == 0 && len(config.Bots) == 0 && len(config.KnownBots) == 0 {
+		return nil, errors.New("no blocked nor bot user agents provided")
+	}
+	if config.KnownBots != "" && config.KnownBots != UserAgentActionBlock && config.KnownBots != UserAgentActionTag {
+		return nil, fmt.Errorf("invalid known bots action %q, expected %s or %s", config.KnownBots, UserAgentActionBlock, UserAgentActionTag)
+	}
+
+	blocked, err := compileUserAgentPatterns(config.Blocked)
+	if err != nil {
+		return nil, err
+	}
+	bots, err := compileUserAgentPatterns(config.Bots)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserAgentFilter{blocked: blocked, bots: bots, knownBots: config.KnownBots}, nil
+}
+
+func compileUserAgentPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var expressions []*regexp.Regexp
+	for _, pattern := range patterns {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user agent pattern %q: %v", pattern, err)
+		}
+		expressions = append(expressions, expression)
+	}
+	return expressions, nil
+}
+
+func (u *UserAgentFilter) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	userAgent := r.UserAgent()
+	r.Header.Del(XBotHeader)
+
+	for _, expression := range u.blocked {
+		if expression.MatchString(userAgent) {
+			log.Debugf("user agent %q matched %s - rejecting", userAgent, expression)
+			reject(rw)
+			return
+		}
+	}
+
+	bot := matchUserAgent(u.bots, userAgent)
+	if len(bot) == 0 && len(u.knownBots) > 0 {
+		bot = knownBots.FindString(userAgent)
+		if len(bot) > 0 && u.knownBots == UserAgentActionBlock {
+			log.Debugf("user agent %q is the known bot %s - rejecting", userAgent, bot)
+			reject(rw)
+			return
+		}
+	}
+	if len(bot) > 0 {
+		r.Header.Set(XBotHeader, bot)
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+// matchUserAgent returns the part of the User-Agent matching the first matching expression, empty if none matches
+func matchUserAgent(expressions []*regexp.Regexp, userAgent string) string {
+	for _, expression := range expressions {
+		if bot := expression.FindString(userAgent); len(bot) > 0 {
+			return bot
+		}
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAgentFilter(t *testing.T) {
+	const browser = "Mozilla/5.0 (X11; Linux x86_64; rv:56.0) Gecko/20100101 Firefox/56.0"
+	const googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+	testCases := []struct {
+		desc               string
+		config             types.UserAgent
+		userAgent          string
+		expectedStatusCode int
+		expectedBot        string
+	}{
+		{
+			desc:               "blocked pattern",
+			config:             types.UserAgent{Blocked: []string{`(?i)masscan|nikto`}},
+			userAgent:          "Mozilla/5.00 (Nikto/2.1.6)",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "browser",
+			config:             types.UserAgent{Blocked: []string{`(?i)masscan|nikto`}, KnownBots: UserAgentActionBlock},
+			userAgent:          browser,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "known bot tagged",
+			config:             types.UserAgent{KnownBots: UserAgentActionTag},
+			userAgent:          googlebot,
+			expectedStatusCode: http.StatusOK,
+			expectedBot:        "Googlebot",
+		},
+		{
+			desc:               "known bot blocked",
+			config:             types.UserAgent{KnownBots: UserAgentActionBlock},
+			userAgent:          "curl/7.55.1",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "bot pattern before the known bots",
+			config:             types.UserAgent{Bots: []string{`Googlebot/[0-9.]+`}, KnownBots: UserAgentActionBlock},
+			userAgent:          googlebot,
+			expectedStatusCode: http.StatusOK,
+			expectedBot:        "Googlebot/2.1",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := NewUserAgentFilter(&test.config)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.Header.Set("User-Agent", test.userAgent)
+			req.Header.Set(XBotHeader, "spoofed")
+
+			var bot string
+			recorder := httptest.NewRecorder()
+			filter.ServeHTTP(recorder, req, func(rw http.ResponseWriter, req *http.Request) {
+				bot = req.Header.Get(XBotHeader)
+				rw.WriteHeader(http.StatusOK)
+			})
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedBot, bot)
+		})
+	}
+}
+
+func TestNewUserAgentFilterInvalid(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config types.UserAgent
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc:   "invalid pattern",
+			config: types.UserAgent{Blocked: []string{`(`}},
+		},
+		{
+			desc:   "invalid known bots action",
+			config: types.UserAgent{KnownBots: "drop"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewUserAgentFilter(&test.config)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/pkg/errors"
+	"github.com/vulcand/oxy/forward"
+	"github.com/vulcand/oxy/utils"
+)
+
+// DefaultWAFTimeout is the default duration to wait for the verdict of the WAF engine
+const DefaultWAFTimeout = 5 * time.Second
+
+// DefaultWAFMaxBodyBytes is the default size of the beginning of the request bodies inspected by the WAF engine
+const DefaultWAFMaxBodyBytes = 64 * 1024
+
+// errWAFBodyTooLarge is returned when the body of a request is bigger than the size inspected by the WAF engine
+var errWAFBodyTooLarge = errors.New("request body too large to be inspected")
+
+// wafTransports holds the transports to the WAF engines, shared by the frontends and kept across the configurations
+// instead of leaving the idle connections of a new transport open on each reload
+var wafTransports = struct {
+	sync.Mutex
+	transports map[wafTransportKey]*http.Transport
+}{transports: make(map[wafTransportKey]*http.Transport)}
+
+type wafTransportKey struct {
+	tls       bool
+	clientTLS types.ClientTLS
+}
+
+// WAF is a middleware asking a web application firewall engine, e.g. ModSecurity with the OWASP Core Rule Set,
+// whether the requests are attacks: the requests are replayed to the engine, and rejected when it answers
+// 403 Forbidden, as well as the requests whose body is too large to be inspected. In detection-only mode,
+// the attacks are only logged, and the requests too large to be inspected are forwarded.
+type WAF struct {
+	frontend      string
+	address       *url.URL
+	detectionOnly bool
+	failOpen      bool
+	maxBodyBytes  int64
+	client        *http.Client
+}
+
+// NewWAF creates the middleware inspecting the requests of the frontend with the WAF engine
+func NewWAF(frontend string, config *types.WAF) (*WAF, error) {
+	if len(config.Address) == 0 {
+		return nil, errors.New("missing WAF engine address")
+	}
+	address, err := url.Parse(config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WAF engine address %s: %v", config.Address, err)
+	}
+
+	timeout := DefaultWAFTimeout
+	if len(config.Timeout) > 0 {
+		timeout, err = time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid WAF timeout %q", config.Timeout)
+		}
+	}
+
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = DefaultWAFMaxBodyBytes
+	}
+
+	transport, err := wafTransport(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WAF{
+		frontend:      frontend,
+		address:       address,
+		detectionOnly: config.DetectionOnly,
+		failOpen:      config.FailOpen,
+		maxBodyBytes:  maxBodyBytes,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(r *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// wafTransport returns the transport to the WAF engines with the TLS configuration, creating it the first time
+func wafTransport(clientTLS *types.ClientTLS) (*http.Transport, error) {
+	key := wafTransportKey{}
+	if clientTLS != nil {
+		key = wafTransportKey{tls: true, clientTLS: *clientTLS}
+	}
+
+	wafTransports.Lock()
+	defer wafTransports.Unlock()
+
+	if transport, ok := wafTransports.transports[key]; ok {
+		return transport, nil
+	}
+	transport := &http.Transport{}
+	if clientTLS != nil {
+		tlsConfig, err := clientTLS.CreateTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid WAF TLS configuration: %v", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	wafTransports.transports[key] = transport
+	return transport, nil
+}
+
+func (w *WAF) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	blocked, err := w.inspect(r)
+	if err == errWAFBodyTooLarge && w.detectionOnly {
+		log.Warnf("WAF forwarded request %s %s of frontend %s from %s uninspected (detection only): %v", r.Method, r.URL.RequestURI(), w.frontend, r.RemoteAddr, err)
+		next.ServeHTTP(rw, r)
+		return
+	}
+	if err == errWAFBodyTooLarge {
+		log.Warnf("WAF rejected request %s %s of frontend %s from %s: %v", r.Method, r.URL.RequestURI(), w.frontend, r.RemoteAddr, err)
+		rw.WriteHeader(http.StatusRequestEntityTooLarge)
+		rw.Write([]byte(http.StatusText(http.StatusRequestEntityTooLarge)))
+		return
+	}
+	if err != nil {
+		log.Errorf("Error inspecting request %s %s of frontend %s with the WAF engine: %v", r.Method, r.URL.RequestURI(), w.frontend, err)
+		if !w.detectionOnly && !w.failOpen {
+			rw.WriteHeader(http.StatusBadGateway)
+			rw.Write([]byte(http.StatusText(http.StatusBadGateway)))
+			return
+		}
+	}
+
+	if blocked {
+		if w.detectionOnly {
+			log.Warnf("WAF detected an attack in request %s %s of frontend %s from %s (detection only)", r.Method, r.URL.RequestURI(), w.frontend, r.RemoteAddr)
+		} else {
+			log.Warnf("WAF blocked request %s %s of frontend %s from %s", r.Method, r.URL.RequestURI(), w.frontend, r.RemoteAddr)
+			reject(rw)
+			return
+		}
+	}
+
+	next.ServeHTTP(rw, r)
+}
+
+// inspect replays the request to the WAF engine, with its body, and tells whether it is blocked. The bodies bigger
+// than maxBodyBytes are not inspected, errWAFBodyTooLarge being returned instead, with the body left to be forwarded.
+func (w *WAF) inspect(r *http.Request) (bool, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody && w.maxBodyBytes > 0 {
+		if r.ContentLength > w.maxBodyBytes {
+			return false, errWAFBodyTooLarge
+		}
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, w.maxBodyBytes+1))
+		if err != nil {
+			return false, fmt.Errorf("unable to read the request body: %v", err)
+		}
+		// the body is forwarded entirely to the backend
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		if int64(len(body)) > w.maxBodyBytes {
+			return false, errWAFBodyTooLarge
+		}
+	}
+
+	// the engine gets the URI as sent by the client, not decoded, so that the rules see the encodings used by the attacks
+	requestURI := r.RequestURI
+	if !strings.HasPrefix(requestURI, "/") {
+		requestURI = r.URL.RequestURI()
+	}
+	address := *w.address
+	address.RawQuery = ""
+	inspectURL := strings.TrimSuffix(address.String(), "/") + requestURI
+
+	inspectReq, err := http.NewRequest(r.Method, inspectURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	utils.CopyHeaders(inspectReq.Header, r.Header)
+	utils.RemoveHeaders(inspectReq.Header, forward.HopHeaders...)
+	inspectReq.Header.Del("Content-Length")
+	inspectReq.Host = r.Host
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Get(forward.XForwardedFor); len(prior) > 0 {
+			clientIP = prior + ", " + clientIP
+		}
+		inspectReq.Header.Set(forward.XForwardedFor, clientIP)
+	}
+
+	resp, err := w.client.Do(inspectReq)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return false, fmt.Errorf("the WAF engine answered %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return resp.StatusCode == http.StatusForbidden, nil
+}
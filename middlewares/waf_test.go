@@ -0,0 +1,188 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAF(t *testing.T) {
+	// the engine blocks the requests whose query or body contain a script, or whose path encodes a parent directory,
+	// and fails on the requests of the failing path
+	engine := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if strings.Contains(req.RequestURI, "%2e%2e") {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if req.URL.Path == "/app/failing" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if req.Host != "example.com" || req.URL.Path != "/app/search" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.Contains(req.URL.RawQuery, "script") || strings.Contains(string(body), "script") {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer engine.Close()
+
+	testCases := []struct {
+		desc               string
+		config             types.WAF
+		target             string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			desc:               "allowed",
+			config:             types.WAF{Address: engine.URL + "/app"},
+			target:             "/search?q=traefik",
+			body:               "q=traefik",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "attack in the query",
+			config:             types.WAF{Address: engine.URL + "/app"},
+			target:             "/search?q=<script>",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "attack in the body",
+			config:             types.WAF{Address: engine.URL + "/app"},
+			target:             "/search",
+			body:               "q=<script>",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "encoded attack in the path",
+			config:             types.WAF{Address: engine.URL + "/app"},
+			target:             "/search/%2e%2e/admin",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "body beyond the inspected size",
+			config:             types.WAF{Address: engine.URL + "/app", MaxBodyBytes: 4},
+			target:             "/search",
+			body:               "q=<script>",
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:               "body of the inspected size",
+			config:             types.WAF{Address: engine.URL + "/app", MaxBodyBytes: 9},
+			target:             "/search",
+			body:               "q=traefik",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "attack beyond the inspected body in detection only",
+			config:             types.WAF{Address: engine.URL + "/app", MaxBodyBytes: 4, DetectionOnly: true},
+			target:             "/search",
+			body:               "q=<script>",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "body beyond the inspected size in detection only",
+			config:             types.WAF{Address: engine.URL + "/app", MaxBodyBytes: 4, DetectionOnly: true},
+			target:             "/search",
+			body:               "q=traefik",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "detection only",
+			config:             types.WAF{Address: engine.URL + "/app", DetectionOnly: true},
+			target:             "/search?q=<script>",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "unreachable engine",
+			config:             types.WAF{Address: "http://127.0.0.1:1", Timeout: "1s"},
+			target:             "/search",
+			expectedStatusCode: http.StatusBadGateway,
+		},
+		{
+			desc:               "failing engine",
+			config:             types.WAF{Address: engine.URL + "/app"},
+			target:             "/failing",
+			expectedStatusCode: http.StatusBadGateway,
+		},
+		{
+			desc:               "unreachable engine failing open",
+			config:             types.WAF{Address: "http://127.0.0.1:1", Timeout: "1s", FailOpen: true},
+			target:             "/search",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "failing engine failing open",
+			config:             types.WAF{Address: engine.URL + "/app", FailOpen: true},
+			target:             "/failing",
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			waf, err := NewWAF("frontend", &test.config)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com"+test.target, strings.NewReader(test.body))
+			req.RequestURI = test.target
+			recorder := httptest.NewRecorder()
+			waf.ServeHTTP(recorder, req, func(rw http.ResponseWriter, req *http.Request) {
+				// the backend gets the whole body
+				body, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(body))
+				rw.WriteHeader(http.StatusOK)
+			})
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestNewWAFSharedTransport(t *testing.T) {
+	waf, err := NewWAF("frontend", &types.WAF{Address: "http://waf:8080"})
+	require.NoError(t, err)
+	other, err := NewWAF("other", &types.WAF{Address: "http://waf:8080", Timeout: "1s"})
+	require.NoError(t, err)
+	insecure, err := NewWAF("frontend", &types.WAF{Address: "https://waf:8443", TLS: &types.ClientTLS{InsecureSkipVerify: true}})
+	require.NoError(t, err)
+
+	assert.True(t, waf.client.Transport == other.client.Transport)
+	assert.False(t, waf.client.Transport == insecure.client.Transport)
+}
+
+func TestNewWAFInvalid(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config types.WAF
+	}{
+		{
+			desc: "missing address",
+		},
+		{
+			desc:   "invalid timeout",
+			config: types.WAF{Address: "http://waf:8080", Timeout: "5"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewWAF("frontend", &test.config)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,555 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/wasm"
+	"github.com/vulcand/oxy/utils"
+)
+
+// WASMHostModule is the name of the module of the host functions imported by the WebAssembly filters
+const WASMHostModule = "traefik"
+
+// DefaultWASMMaxBodyBytes is the default size of the bodies the WebAssembly filters can read
+const DefaultWASMMaxBodyBytes = 1024 * 1024
+
+const (
+	// wasmIdleInstances is the number of idle instances kept by a WebAssembly filter for the next requests
+	wasmIdleInstances = 16
+	// wasmCachedModules is the number of compiled modules kept across the configurations
+	wasmCachedModules = 64
+)
+
+// The kinds of messages of the host functions
+const (
+	wasmRequest  = 0
+	wasmResponse = 1
+)
+
+var errWASMOutOfBounds = &wasm.Trap{Reason: "out of bounds memory access"}
+
+// wasmModules holds the compiled modules by the hash of their binary, kept across the configurations
+// instead of compiling the modules again on each reload
+var wasmModules = struct {
+	sync.Mutex
+	modules map[[sha256.Size]byte]*wasm.Module
+}{modules: make(map[[sha256.Size]byte]*wasm.Module)}
+
+// WASM is a middleware filtering the requests, and optionally the responses, with a WebAssembly module: the
+// handle_request function exported by the module reads and changes the request with the host functions, and either
+// forwards it or answers it. The handle_response function, when exported, reads and changes the responses, which are
+// buffered. The instances of the module run sandboxed: they only access the exchange they are filtering, with
+// a limited memory and number of instructions per call.
+type WASM struct {
+	name           string
+	module         *wasm.Module
+	config         []byte
+	limits         wasm.Config
+	maxBodyBytes   int64
+	handleResponse bool
+	instances      chan *wasmInstance
+}
+
+// wasmInstance is an instance of the module of a filter, with the exchange it is filtering
+type wasmInstance struct {
+	instance *wasm.Instance
+	exchange *wasmExchange
+}
+
+// wasmExchange is a request being filtered, with its response: the one built by the filter when handling
+// the request, or the response of the next handler when handling the response
+type wasmExchange struct {
+	request         *http.Request
+	requestBody     []byte
+	requestBodyRead bool
+	requestBodySet  bool
+	tooLarge        bool
+	header          http.Header
+	statusCode      int
+	body            []byte
+	bodySet         bool
+}
+
+// NewWASM creates the middleware filtering the requests with the WebAssembly module of the configuration
+func NewWASM(name string, config *types.WASM) (*WASM, error) {
+	var binary []byte
+	var err error
+	switch {
+	case len(config.File) > 0 && len(config.Module) > 0:
+		return nil, errors.New("both a WASM module file and content")
+	case len(config.File) > 0:
+		binary, err = ioutil.ReadFile(config.File)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the WASM module: %v", err)
+		}
+	case len(config.Module) > 0:
+		binary, err = base64.StdEncoding.DecodeString(config.Module)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 WASM module: %v", err)
+		}
+	default:
+		return nil, errors.New("missing WASM module")
+	}
+
+	module, err := compileWASM(binary)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = DefaultWASMMaxBodyBytes
+	}
+	w := &WASM{
+		name:         name,
+		module:       module,
+		config:       []byte(config.Config),
+		limits:       wasm.Config{MaxMemoryPages: config.MaxMemoryPages, MaxInstructions: config.MaxInstructions},
+		maxBodyBytes: maxBodyBytes,
+		instances:    make(chan *wasmInstance, wasmIdleInstances),
+	}
+
+	// the first instance checks the imports and the exports of the module
+	instance, err := w.newInstance()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := instance.instance.ExportedFunction("handle_request")
+	if !ok {
+		return nil, errors.New("WASM module without handle_request function")
+	}
+	if len(t.Params) != 0 || len(t.Results) != 1 || t.Results[0] != wasm.I32 {
+		return nil, fmt.Errorf("WASM handle_request function of type %v instead of [] -> [i32]", t)
+	}
+	if t, ok := instance.instance.ExportedFunction("handle_response"); ok {
+		if len(t.Params) != 0 || len(t.Results) != 0 {
+			return nil, fmt.Errorf("WASM handle_response function of type %v instead of [] -> []", t)
+		}
+		w.handleResponse = true
+	}
+	w.instances <- instance
+	return w, nil
+}
+
+// compileWASM compiles the module, or returns the module compiled for a previous configuration
+func compileWASM(binary []byte) (*wasm.Module, error) {
+	key := sha256.Sum256(binary)
+
+	wasmModules.Lock()
+	defer wasmModules.Unlock()
+
+	if module, ok := wasmModules.modules[key]; ok {
+		return module, nil
+	}
+	module, err := wasm.Compile(binary)
+	if err != nil {
+		return nil, err
+	}
+	if len(wasmModules.modules) >= wasmCachedModules {
+		wasmModules.modules = make(map[[sha256.Size]byte]*wasm.Module)
+	}
+	wasmModules.modules[key] = module
+	return module, nil
+}
+
+// newInstance instantiates the module, and runs the _initialize function exported by the modules compiled as
+// WASI reactors
+func (w *WASM) newInstance() (*wasmInstance, error) {
+	i := &wasmInstance{}
+	imports := wasm.Imports{
+		WASMHostModule:  w.hostFunctions(i),
+		wasm.WASIModule: wasm.WASI(wasmLog{middleware: w.name}, wasmLog{middleware: w.name, errors: true}),
+	}
+	instance, err := w.module.Instantiate(imports, w.limits)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := instance.ExportedFunction("_initialize"); ok {
+		if _, err := instance.Call("_initialize"); err != nil {
+			return nil, err
+		}
+	}
+	i.instance = instance
+	return i, nil
+}
+
+// instance returns an idle instance, or a new one when they are all filtering other requests
+func (w *WASM) instance() (*wasmInstance, error) {
+	select {
+	case i := <-w.instances:
+		return i, nil
+	default:
+		return w.newInstance()
+	}
+}
+
+// release keeps the instance for the next requests, unless there are enough idle instances
+func (w *WASM) release(i *wasmInstance) {
+	i.exchange = nil
+	select {
+	case w.instances <- i:
+	default:
+	}
+}
+
+func (w *WASM) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	i, err := w.instance()
+	if err != nil {
+		log.Errorf("Error instantiating the WASM module of middleware %s: %v", w.name, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+
+	exchange := &wasmExchange{request: r, header: rw.Header(), statusCode: http.StatusOK}
+	i.exchange = exchange
+	results, err := i.instance.Call("handle_request")
+	if err != nil {
+		// the instance is left in an undefined state by the trap, and is not reused
+		log.Errorf("Error filtering request %s %s with WASM middleware %s: %v", r.Method, r.URL.RequestURI(), w.name, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	if exchange.requestBodySet {
+		r.Body = ioutil.NopCloser(bytes.NewReader(exchange.requestBody))
+		r.ContentLength = int64(len(exchange.requestBody))
+		r.TransferEncoding = nil
+		r.Header.Set("Content-Length", strconv.Itoa(len(exchange.requestBody)))
+	}
+
+	if uint32(results[0]) == 0 {
+		w.release(i)
+		if len(exchange.body) > 0 {
+			rw.Header().Set("Content-Length", strconv.Itoa(len(exchange.body)))
+		}
+		rw.WriteHeader(exchange.statusCode)
+		rw.Write(exchange.body)
+		return
+	}
+
+	if !w.handleResponse {
+		w.release(i)
+		next.ServeHTTP(rw, r)
+		return
+	}
+
+	// the same instance handles the response, so that it keeps the state of the request
+	recorder := newRetryResponseRecorder()
+	recorder.responseWriter = rw
+	next.ServeHTTP(recorder, r)
+	if recorder.streamingResponseStarted {
+		w.release(i)
+		return
+	}
+	i.exchange = &wasmExchange{request: r, header: recorder.HeaderMap, statusCode: recorder.Code, body: recorder.Body.Bytes()}
+	if _, err := i.instance.Call("handle_response"); err != nil {
+		log.Errorf("Error filtering response of request %s %s with WASM middleware %s: %v", r.Method, r.URL.RequestURI(), w.name, err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	exchange = i.exchange
+	w.release(i)
+
+	utils.CopyHeaders(rw.Header(), exchange.header)
+	if exchange.bodySet {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(exchange.body)))
+	}
+	rw.WriteHeader(exchange.statusCode)
+	rw.Write(exchange.body)
+}
+
+// readRequestBody reads the body of the request, unless it is bigger than maxBodyBytes, the body being left to be
+// forwarded either way
+func (w *WASM) readRequestBody(exchange *wasmExchange) error {
+	exchange.requestBodyRead = true
+	r := exchange.request
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.ContentLength > w.maxBodyBytes {
+		exchange.tooLarge = true
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, w.maxBodyBytes+1))
+	if err != nil {
+		return fmt.Errorf("unable to read the request body: %v", err)
+	}
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if int64(len(body)) > w.maxBodyBytes {
+		exchange.tooLarge = true
+		return nil
+	}
+	exchange.requestBody = body
+	return nil
+}
+
+// hostFunctions returns the functions of the host imported by the instance, which all take and return i32 values:
+// the functions getting a value copy it at a pointer when it fits in a limit, and return its length. The headers
+// and bodies are the ones of the request or of the response, depending on their kind argument.
+func (w *WASM) hostFunctions(i *wasmInstance) map[string]wasm.HostFunction {
+	getString := func(get func(exchange *wasmExchange) string) wasm.HostFunction {
+		return wasmFunction(2, 1, func(instance *wasm.Instance, stack []uint64) (err error) {
+			stack[0], err = wasmCopy(instance, stack[0], stack[1], []byte(get(i.exchange)))
+			return err
+		})
+	}
+	setString := func(set func(exchange *wasmExchange, value string) error) wasm.HostFunction {
+		return wasmFunction(2, 0, func(instance *wasm.Instance, stack []uint64) error {
+			value, err := wasmRead(instance, stack[0], stack[1])
+			if err != nil {
+				return err
+			}
+			return set(i.exchange, string(value))
+		})
+	}
+	header := func(kind uint64) (http.Header, error) {
+		switch kind {
+		case wasmRequest:
+			return i.exchange.request.Header, nil
+		case wasmResponse:
+			return i.exchange.header, nil
+		}
+		return nil, fmt.Errorf("invalid message kind %d", kind)
+	}
+	setHeader := func(set func(header http.Header, name, value string)) wasm.HostFunction {
+		return wasmFunction(5, 0, func(instance *wasm.Instance, stack []uint64) error {
+			h, err := header(uint64(uint32(stack[0])))
+			if err != nil {
+				return err
+			}
+			name, err := wasmRead(instance, stack[1], stack[2])
+			if err != nil {
+				return err
+			}
+			value, err := wasmRead(instance, stack[3], stack[4])
+			if err != nil {
+				return err
+			}
+			set(h, string(name), string(value))
+			return nil
+		})
+	}
+
+	return map[string]wasm.HostFunction{
+		"log": wasmFunction(3, 0, func(instance *wasm.Instance, stack []uint64) error {
+			message, err := wasmRead(instance, stack[1], stack[2])
+			if err != nil {
+				return err
+			}
+			wasmLogf(uint32(stack[0]), "WASM middleware %s: %s", w.name, message)
+			return nil
+		}),
+		"get_config": wasmFunction(2, 1, func(instance *wasm.Instance, stack []uint64) (err error) {
+			stack[0], err = wasmCopy(instance, stack[0], stack[1], w.config)
+			return err
+		}),
+		"get_method": getString(func(exchange *wasmExchange) string {
+			return exchange.request.Method
+		}),
+		"set_method": setString(func(exchange *wasmExchange, method string) error {
+			exchange.request.Method = method
+			return nil
+		}),
+		"get_uri": getString(func(exchange *wasmExchange) string {
+			return exchange.request.URL.RequestURI()
+		}),
+		"set_uri": setString(func(exchange *wasmExchange, uri string) error {
+			u, err := url.ParseRequestURI(uri)
+			if err != nil {
+				return fmt.Errorf("invalid request URI %q", uri)
+			}
+			exchange.request.URL.Path, exchange.request.URL.RawPath, exchange.request.URL.RawQuery = u.Path, u.RawPath, u.RawQuery
+			exchange.request.RequestURI = uri
+			return nil
+		}),
+		"get_host": getString(func(exchange *wasmExchange) string {
+			return exchange.request.Host
+		}),
+		"set_host": setString(func(exchange *wasmExchange, host string) error {
+			exchange.request.Host = host
+			return nil
+		}),
+		"get_source_addr": getString(func(exchange *wasmExchange) string {
+			return exchange.request.RemoteAddr
+		}),
+		"get_header_names": wasmFunction(3, 1, func(instance *wasm.Instance, stack []uint64) error {
+			h, err := header(uint64(uint32(stack[0])))
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(h))
+			for name := range h {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			stack[0], err = wasmCopy(instance, stack[1], stack[2], []byte(strings.Join(names, "\x00")))
+			return err
+		}),
+		"get_header": wasmFunction(5, 1, func(instance *wasm.Instance, stack []uint64) error {
+			h, err := header(uint64(uint32(stack[0])))
+			if err != nil {
+				return err
+			}
+			name, err := wasmRead(instance, stack[1], stack[2])
+			if err != nil {
+				return err
+			}
+			values, ok := h[http.CanonicalHeaderKey(string(name))]
+			if !ok {
+				stack[0] = math.MaxUint32
+				return nil
+			}
+			stack[0], err = wasmCopy(instance, stack[3], stack[4], []byte(strings.Join(values, "\x00")))
+			return err
+		}),
+		"set_header": setHeader(func(header http.Header, name, value string) {
+			header.Set(name, value)
+		}),
+		"add_header": setHeader(func(header http.Header, name, value string) {
+			header.Add(name, value)
+		}),
+		"remove_header": wasmFunction(3, 0, func(instance *wasm.Instance, stack []uint64) error {
+			h, err := header(uint64(uint32(stack[0])))
+			if err != nil {
+				return err
+			}
+			name, err := wasmRead(instance, stack[1], stack[2])
+			if err != nil {
+				return err
+			}
+			h.Del(string(name))
+			return nil
+		}),
+		"get_body": wasmFunction(3, 1, func(instance *wasm.Instance, stack []uint64) (err error) {
+			exchange := i.exchange
+			var body []byte
+			switch uint32(stack[0]) {
+			case wasmRequest:
+				if !exchange.requestBodyRead {
+					if err := w.readRequestBody(exchange); err != nil {
+						return err
+					}
+				}
+				if exchange.tooLarge && !exchange.requestBodySet {
+					stack[0] = math.MaxUint32
+					return nil
+				}
+				body = exchange.requestBody
+			case wasmResponse:
+				if int64(len(exchange.body)) > w.maxBodyBytes {
+					stack[0] = math.MaxUint32
+					return nil
+				}
+				body = exchange.body
+			default:
+				return fmt.Errorf("invalid message kind %d", uint32(stack[0]))
+			}
+			stack[0], err = wasmCopy(instance, stack[1], stack[2], body)
+			return err
+		}),
+		"set_body": wasmFunction(3, 0, func(instance *wasm.Instance, stack []uint64) error {
+			b, err := wasmRead(instance, stack[1], stack[2])
+			if err != nil {
+				return err
+			}
+			body := append([]byte(nil), b...)
+			switch uint32(stack[0]) {
+			case wasmRequest:
+				i.exchange.requestBody, i.exchange.requestBodySet = body, true
+			case wasmResponse:
+				i.exchange.body, i.exchange.bodySet = body, true
+			default:
+				return fmt.Errorf("invalid message kind %d", uint32(stack[0]))
+			}
+			return nil
+		}),
+		"get_status_code": wasmFunction(0, 1, func(instance *wasm.Instance, stack []uint64) error {
+			stack[0] = uint64(i.exchange.statusCode)
+			return nil
+		}),
+		"set_status_code": wasmFunction(1, 0, func(instance *wasm.Instance, stack []uint64) error {
+			statusCode := int(uint32(stack[0]))
+			if statusCode < 100 || statusCode > 999 {
+				return fmt.Errorf("invalid status code %d", statusCode)
+			}
+			i.exchange.statusCode = statusCode
+			return nil
+		}),
+	}
+}
+
+// wasmFunction returns a host function taking and returning i32 values
+func wasmFunction(params, results int, call func(instance *wasm.Instance, stack []uint64) error) wasm.HostFunction {
+	t := wasm.FunctionType{}
+	for i := 0; i < params; i++ {
+		t.Params = append(t.Params, wasm.I32)
+	}
+	for i := 0; i < results; i++ {
+		t.Results = append(t.Results, wasm.I32)
+	}
+	return wasm.HostFunction{Type: t, Call: call}
+}
+
+// wasmRead returns the bytes of the memory of the instance at the pointer and length arguments
+func wasmRead(instance *wasm.Instance, pointer, length uint64) ([]byte, error) {
+	b, ok := instance.Read(uint32(pointer), uint32(length))
+	if !ok {
+		return nil, errWASMOutOfBounds
+	}
+	return b, nil
+}
+
+// wasmCopy copies the value to the memory of the instance at the pointer when it fits in the limit,
+// and returns its length
+func wasmCopy(instance *wasm.Instance, pointer, limit uint64, value []byte) (uint64, error) {
+	if uint64(len(value)) <= uint64(uint32(limit)) && !instance.Write(uint32(pointer), value) {
+		return 0, errWASMOutOfBounds
+	}
+	return uint64(len(value)), nil
+}
+
+// wasmLogf logs the message of a filter at its level: 0 for debug, 1 for info, 2 for warning and 3 for error
+func wasmLogf(level uint32, format string, args ...interface{}) {
+	switch level {
+	case 0:
+		log.Debugf(format, args...)
+	case 1:
+		log.Infof(format, args...)
+	case 2:
+		log.Warnf(format, args...)
+	default:
+		log.Errorf(format, args...)
+	}
+}
+
+// wasmLog logs what the filters write to their standard output, as info, and to their standard error, as errors
+type wasmLog struct {
+	middleware string
+	errors     bool
+}
+
+func (l wasmLog) Write(b []byte) (int, error) {
+	level := uint32(1)
+	if l.errors {
+		level = 3
+	}
+	wasmLogf(level, "WASM middleware %s: %s", l.middleware, strings.TrimRight(string(b), "\n"))
+	return len(b), nil
+}
@@ -0,0 +1,401 @@
+package middlewares
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/wasm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+// wasmTestModule is a filter encoded by the tests, importing the host functions named by imports
+type wasmTestModule struct {
+	imports   []string
+	data      []byte
+	functions []wasmTestFunction
+}
+
+// wasmTestFunction is a function of i32 values, its body without the final end
+type wasmTestFunction struct {
+	export  string
+	results int
+	locals  int
+	body    []byte
+}
+
+// str adds the string to the data of the module, and returns the instructions pushing its pointer and length
+func (m *wasmTestModule) str(s string) []byte {
+	offset := len(m.data)
+	m.data = append(m.data, s...)
+	return append(wasmI32(int32(offset)), wasmI32(int32(len(s)))...)
+}
+
+// call returns the instruction calling the host function
+func (m *wasmTestModule) call(name string) []byte {
+	for i, n := range m.imports {
+		if n == name {
+			return []byte{0x10, byte(i)}
+		}
+	}
+	panic(name)
+}
+
+func (m *wasmTestModule) encode() []byte {
+	hostFunctions := (&WASM{}).hostFunctions(nil)
+	var types [][]byte
+	typeIndex := func(t wasm.FunctionType) byte {
+		b := append([]byte{0x60, byte(len(t.Params))}, wasmValueTypes(t.Params)...)
+		b = append(append(b, byte(len(t.Results))), wasmValueTypes(t.Results)...)
+		for i, e := range types {
+			if string(e) == string(b) {
+				return byte(i)
+			}
+		}
+		types = append(types, b)
+		return byte(len(types) - 1)
+	}
+
+	imports := []byte{byte(len(m.imports))}
+	for _, name := range m.imports {
+		imports = append(imports, wasmName(WASMHostModule)...)
+		imports = append(imports, wasmName(name)...)
+		imports = append(imports, 0, typeIndex(hostFunctions[name].Type))
+	}
+	functions := []byte{byte(len(m.functions))}
+	exports := []byte{byte(len(m.functions))}
+	code := []byte{byte(len(m.functions))}
+	for i, f := range m.functions {
+		t := wasm.FunctionType{}
+		for j := 0; j < f.results; j++ {
+			t.Results = append(t.Results, wasm.I32)
+		}
+		functions = append(functions, typeIndex(t))
+		exports = append(exports, wasmName(f.export)...)
+		exports = append(exports, 0, byte(len(m.imports)+i))
+		body := []byte{0}
+		if f.locals > 0 {
+			body = []byte{1, byte(f.locals), byte(wasm.I32)}
+		}
+		body = append(append(body, f.body...), 0x0B)
+		code = append(code, wasmVector(body)...)
+	}
+	typesSection := []byte{byte(len(types))}
+	for _, t := range types {
+		typesSection = append(typesSection, t...)
+	}
+	data := append([]byte{1, 0}, wasmI32(0)...)
+	data = append(append(data, 0x0B), wasmVector(m.data)...)
+
+	binary := []byte{0, 'a', 's', 'm', 1, 0, 0, 0}
+	for _, section := range []struct {
+		id       byte
+		contents []byte
+	}{
+		{1, typesSection},
+		{2, imports},
+		{3, functions},
+		{5, []byte{1, 0, 1}},
+		{7, exports},
+		{10, code},
+		{11, data},
+	} {
+		binary = append(append(binary, section.id), wasmVector(section.contents)...)
+	}
+	return binary
+}
+
+func wasmI32(v int32) []byte {
+	b := []byte{0x41}
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func wasmVector(b []byte) []byte {
+	var length []byte
+	for n := len(b); ; n >>= 7 {
+		if n < 0x80 {
+			length = append(length, byte(n))
+			break
+		}
+		length = append(length, byte(n&0x7F|0x80))
+	}
+	return append(length, b...)
+}
+
+func wasmName(s string) []byte {
+	return wasmVector([]byte(s))
+}
+
+func wasmValueTypes(types []wasm.ValueType) []byte {
+	var b []byte
+	for _, t := range types {
+		b = append(b, byte(t))
+	}
+	return b
+}
+
+func wasmCode(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestWASM(t *testing.T) {
+	answer := &wasmTestModule{imports: []string{"set_status_code", "set_header", "set_body"}}
+	answer.functions = []wasmTestFunction{{
+		export:  "handle_request",
+		results: 1,
+		body: wasmCode(
+			wasmI32(http.StatusForbidden), answer.call("set_status_code"),
+			wasmI32(wasmResponse), answer.str("X-Blocked"), answer.str("yes"), answer.call("set_header"),
+			wasmI32(wasmResponse), answer.str("denied"), answer.call("set_body"),
+			wasmI32(0),
+		),
+	}}
+
+	forward := &wasmTestModule{imports: []string{"get_config", "set_header", "get_method", "set_uri"}}
+	forward.functions = []wasmTestFunction{{
+		export:  "handle_request",
+		results: 1,
+		locals:  1,
+		body: wasmCode(
+			wasmI32(1024), wasmI32(100), forward.call("get_config"), []byte{0x21, 0},
+			wasmI32(wasmRequest), forward.str("X-Config"), wasmI32(1024), []byte{0x20, 0}, forward.call("set_header"),
+			wasmI32(2048), wasmI32(100), forward.call("get_method"), []byte{0x21, 0},
+			wasmI32(wasmRequest), forward.str("X-Method"), wasmI32(2048), []byte{0x20, 0}, forward.call("set_header"),
+			forward.str("/rewritten?tenant=a"), forward.call("set_uri"),
+			wasmI32(1),
+		),
+	}}
+
+	echo := &wasmTestModule{imports: []string{"get_body", "set_body", "set_status_code"}}
+	echo.functions = []wasmTestFunction{{
+		export:  "handle_request",
+		results: 1,
+		locals:  1,
+		body: wasmCode(
+			wasmI32(wasmRequest), wasmI32(1024), wasmI32(4096), echo.call("get_body"), []byte{0x22, 0},
+			wasmI32(-1), []byte{0x46, 0x04, 0x40},
+			wasmI32(http.StatusRequestEntityTooLarge), echo.call("set_status_code"),
+			[]byte{0x05},
+			wasmI32(wasmResponse), wasmI32(1024), []byte{0x20, 0}, echo.call("set_body"),
+			[]byte{0x0B},
+			wasmI32(0),
+		),
+	}}
+
+	response := &wasmTestModule{imports: []string{"get_status_code", "set_status_code", "remove_header", "set_body"}}
+	response.functions = []wasmTestFunction{
+		{export: "handle_request", results: 1, body: wasmI32(1)},
+		{
+			export: "handle_response",
+			body: wasmCode(
+				response.call("get_status_code"), wasmI32(1), []byte{0x6A}, response.call("set_status_code"),
+				wasmI32(wasmResponse), response.str("X-Backend"), response.call("remove_header"),
+				wasmI32(wasmResponse), response.str("filtered"), response.call("set_body"),
+			),
+		},
+	}
+
+	trap := &wasmTestModule{}
+	trap.functions = []wasmTestFunction{{export: "handle_request", results: 1, body: []byte{0x00}}}
+
+	loop := &wasmTestModule{}
+	loop.functions = []wasmTestFunction{{export: "handle_request", results: 1, body: []byte{0x03, 0x40, 0x0C, 0, 0x0B, 0x00}}}
+
+	outOfBounds := &wasmTestModule{imports: []string{"set_body"}}
+	outOfBounds.functions = []wasmTestFunction{{
+		export:  "handle_request",
+		results: 1,
+		body:    wasmCode(wasmI32(wasmResponse), wasmI32(wasm.PageSize-1), wasmI32(2), outOfBounds.call("set_body"), wasmI32(0)),
+	}}
+
+	testCases := []struct {
+		desc               string
+		module             *wasmTestModule
+		config             types.WASM
+		method             string
+		body               string
+		expectedStatusCode int
+		expectedHeaders    map[string]string
+		expectedBody       string
+	}{
+		{
+			desc:               "filter answering the request",
+			module:             answer,
+			expectedStatusCode: http.StatusForbidden,
+			expectedHeaders:    map[string]string{"X-Blocked": "yes", "X-Backend": ""},
+			expectedBody:       "denied",
+		},
+		{
+			desc:               "filter changing the request",
+			module:             forward,
+			config:             types.WASM{Config: "tenant-a"},
+			method:             http.MethodPost,
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders:    map[string]string{"X-Backend": "yes", "X-Config": "tenant-a", "X-Method": "POST"},
+			expectedBody:       "/rewritten?tenant=a",
+		},
+		{
+			desc:               "filter reading the request body",
+			module:             echo,
+			method:             http.MethodPost,
+			body:               "hello",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "hello",
+		},
+		{
+			desc:               "request body too large",
+			module:             echo,
+			config:             types.WASM{MaxBodyBytes: 4},
+			method:             http.MethodPost,
+			body:               "hello",
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:               "filter changing the response",
+			module:             response,
+			expectedStatusCode: http.StatusCreated,
+			expectedHeaders:    map[string]string{"X-Backend": "", "Content-Length": "8"},
+			expectedBody:       "filtered",
+		},
+		{
+			desc:               "trap",
+			module:             trap,
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedHeaders:    map[string]string{"X-Backend": ""},
+		},
+		{
+			desc:               "instruction limit",
+			module:             loop,
+			config:             types.WASM{MaxInstructions: 1000},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			desc:               "out of bounds host function argument",
+			module:             outOfBounds,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			config := test.config
+			config.Module = base64.StdEncoding.EncodeToString(test.module.encode())
+			filter, err := NewWASM("test", &config)
+			require.NoError(t, err)
+
+			n := negroni.New(filter)
+			n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("X-Backend", "yes")
+				rw.Header().Set("X-Config", r.Header.Get("X-Config"))
+				rw.Header().Set("X-Method", r.Header.Get("X-Method"))
+				if r.ContentLength > 0 {
+					body, _ := ioutil.ReadAll(r.Body)
+					rw.Write(body)
+					return
+				}
+				rw.WriteHeader(http.StatusOK)
+				rw.Write([]byte(r.URL.RequestURI()))
+			})
+
+			method := test.method
+			if len(method) == 0 {
+				method = http.MethodGet
+			}
+			// the requests are served several times, by the same instance, to check it is reset
+			for i := 0; i < 3; i++ {
+				recorder := httptest.NewRecorder()
+				n.ServeHTTP(recorder, httptest.NewRequest(method, "http://localhost/path", strings.NewReader(test.body)))
+
+				assert.Equal(t, test.expectedStatusCode, recorder.Code)
+				for name, value := range test.expectedHeaders {
+					assert.Equal(t, value, recorder.Header().Get(name), name)
+				}
+				if len(test.expectedBody) > 0 {
+					assert.Equal(t, test.expectedBody, recorder.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNewWASMErrors(t *testing.T) {
+	empty := base64.StdEncoding.EncodeToString((&wasmTestModule{}).encode())
+	unknownImport := base64.StdEncoding.EncodeToString((&wasmTestModule{imports: []string{"unknown"}}).encode())
+	noResult := base64.StdEncoding.EncodeToString((&wasmTestModule{functions: []wasmTestFunction{{export: "handle_request"}}}).encode())
+
+	testCases := []struct {
+		desc          string
+		config        types.WASM
+		expectedError string
+	}{
+		{
+			desc:          "missing module",
+			expectedError: "missing WASM module",
+		},
+		{
+			desc:          "file and content",
+			config:        types.WASM{File: "filter.wasm", Module: empty},
+			expectedError: "both a WASM module file and content",
+		},
+		{
+			desc:          "missing file",
+			config:        types.WASM{File: "/nonexistent/filter.wasm"},
+			expectedError: "unable to read the WASM module: open /nonexistent/filter.wasm: no such file or directory",
+		},
+		{
+			desc:          "invalid base64",
+			config:        types.WASM{Module: "not base64!"},
+			expectedError: "invalid base64 WASM module: illegal base64 data at input byte 3",
+		},
+		{
+			desc:          "invalid module",
+			config:        types.WASM{Module: base64.StdEncoding.EncodeToString([]byte("<html>"))},
+			expectedError: "wasm: not a WebAssembly 1.0 binary module",
+		},
+		{
+			desc:          "without handle_request",
+			config:        types.WASM{Module: empty},
+			expectedError: "WASM module without handle_request function",
+		},
+		{
+			desc:          "handle_request without result",
+			config:        types.WASM{Module: noResult},
+			expectedError: "WASM handle_request function of type [] -> [] instead of [] -> [i32]",
+		},
+		{
+			desc:          "unknown import",
+			config:        types.WASM{Module: unknownImport},
+			expectedError: "wasm: unknown import traefik.unknown",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewWASM("test", &test.config)
+			assert.EqualError(t, err, test.expectedError)
+		})
+	}
+}
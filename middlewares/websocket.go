@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Compile time validation websocketResponseWriter implements http interfaces correctly.
+var (
+	_ Stateful = &websocketResponseWriter{}
+)
+
+// WebsocketDeadlines is a middleware managing the deadlines of the websocket connections.
+// Once hijacked, a connection keeps the deadlines set by the HTTP server for the upgrade request,
+// so they are cleared and replaced by the configured websocket read and write timeouts.
+type WebsocketDeadlines struct {
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// NewWebsocketDeadlines returns a new WebsocketDeadlines instance.
+// A zero timeout means no deadline is set.
+func NewWebsocketDeadlines(readTimeout, writeTimeout time.Duration) *WebsocketDeadlines {
+	return &WebsocketDeadlines{
+		readTimeout:  readTimeout,
+		writeTimeout: writeTimeout,
+	}
+}
+
+func (wd *WebsocketDeadlines) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if !isWebsocketRequest(r) {
+		next(rw, r)
+		return
+	}
+
+	next(&websocketResponseWriter{
+		ResponseWriter: rw,
+		readTimeout:    wd.readTimeout,
+		writeTimeout:   wd.writeTimeout,
+	}, r)
+}
+
+// isWebsocketRequest determines if the specified HTTP request is a websocket handshake request.
+func isWebsocketRequest(r *http.Request) bool {
+	containsHeader := func(name, value string) bool {
+		for _, item := range strings.Split(r.Header.Get(name), ",") {
+			if strings.EqualFold(strings.TrimSpace(item), value) {
+				return true
+			}
+		}
+		return false
+	}
+	return containsHeader("Connection", "upgrade") && containsHeader("Upgrade", "websocket")
+}
+
+type websocketResponseWriter struct {
+	http.ResponseWriter
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// Hijack hijacks the connection and wraps it to apply the websocket deadlines.
+func (rw *websocketResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		// HTTP/2 response writers can not be hijacked
+		return nil, nil, fmt.Errorf("Not a hijacker: %T", rw.ResponseWriter)
+	}
+
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// clear the deadlines inherited from the HTTP server
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return &websocketConn{
+		Conn:         conn,
+		readTimeout:  rw.readTimeout,
+		writeTimeout: rw.writeTimeout,
+	}, brw, nil
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (rw *websocketResponseWriter) CloseNotify() <-chan bool {
+	if c, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client.
+func (rw *websocketResponseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// websocketConn is a net.Conn extending its read and write deadlines before each operation.
+type websocketConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *websocketConn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *websocketConn) Write(b []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(b)
+}
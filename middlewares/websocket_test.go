@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWebsocketRequest(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		connection string
+		upgrade    string
+		expected   bool
+	}{
+		{
+			desc:       "websocket handshake",
+			connection: "Upgrade",
+			upgrade:    "websocket",
+			expected:   true,
+		},
+		{
+			desc:       "multiple connection tokens",
+			connection: "keep-alive, Upgrade",
+			upgrade:    "WebSocket",
+			expected:   true,
+		},
+		{
+			desc:       "missing upgrade",
+			connection: "Upgrade",
+			expected:   false,
+		},
+		{
+			desc:       "other protocol",
+			connection: "Upgrade",
+			upgrade:    "h2c",
+			expected:   false,
+		},
+		{
+			desc:     "plain request",
+			expected: false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/ws", nil)
+			req.Header.Set("Connection", test.connection)
+			req.Header.Set("Upgrade", test.upgrade)
+
+			assert.Equal(t, test.expected, isWebsocketRequest(req))
+		})
+	}
+}
+
+func TestWebsocketDeadlines(t *testing.T) {
+	conn := &deadlineRecorderConn{}
+	rw := &hijackerRecorder{ResponseRecorder: httptest.NewRecorder(), conn: conn}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	wd := NewWebsocketDeadlines(10*time.Second, 5*time.Second)
+	wd.ServeHTTP(rw, req, func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		require.True(t, ok)
+
+		c, _, err := hijacker.Hijack()
+		require.NoError(t, err)
+		assert.True(t, conn.deadline.IsZero(), "inherited deadlines should be cleared")
+		assert.True(t, conn.deadlineSet)
+
+		before := time.Now()
+		c.Read(nil)
+		c.Write(nil)
+		assert.WithinDuration(t, before.Add(10*time.Second), conn.readDeadline, time.Second)
+		assert.WithinDuration(t, before.Add(5*time.Second), conn.writeDeadline, time.Second)
+	})
+}
+
+type hijackerRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (h *hijackerRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, nil, nil
+}
+
+type deadlineRecorderConn struct {
+	net.Conn
+	deadlineSet   bool
+	deadline      time.Time
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func (c *deadlineRecorderConn) Read(b []byte) (int, error)  { return 0, nil }
+func (c *deadlineRecorderConn) Write(b []byte) (int, error) { return len(b), nil }
+
+func (c *deadlineRecorderConn) SetDeadline(t time.Time) error {
+	c.deadlineSet = true
+	c.deadline = t
+	return nil
+}
+
+func (c *deadlineRecorderConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline = t
+	return nil
+}
+
+func (c *deadlineRecorderConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return nil
+}
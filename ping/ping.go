@@ -0,0 +1,36 @@
+package ping
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/containous/mux"
+)
+
+// Handler serves the /ping health check on an entrypoint,
+// answering 503 once Traefik is shutting down so that the load-balancers stop sending it requests.
+type Handler struct {
+	EntryPoint  string `description:"Ping entryPoint" export:"true"`
+	terminating int32
+}
+
+// AddRoutes adds the /ping route to the router of the entrypoint, before the routes of the frontends
+func (h *Handler) AddRoutes(router *mux.Router) {
+	router.Methods(http.MethodGet, http.MethodHead).Path("/ping").HandlerFunc(h.ServeHTTP).Priority(math.MaxInt32)
+}
+
+// SetTerminating makes the health check fail while Traefik drains its connections
+func (h *Handler) SetTerminating() {
+	atomic.StoreInt32(&h.terminating, 1)
+}
+
+func (h *Handler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	statusCode := http.StatusOK
+	if atomic.LoadInt32(&h.terminating) == 1 {
+		statusCode = http.StatusServiceUnavailable
+	}
+	response.WriteHeader(statusCode)
+	fmt.Fprint(response, http.StatusText(statusCode))
+}
@@ -0,0 +1,66 @@
+package ping
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		terminating        bool
+		method             string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			desc:               "serving",
+			method:             http.MethodGet,
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "OK",
+		},
+		{
+			desc:               "serving with HEAD",
+			method:             http.MethodHead,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "terminating",
+			terminating:        true,
+			method:             http.MethodGet,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       "Service Unavailable",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler := &Handler{}
+			if test.terminating {
+				handler.SetTerminating()
+			}
+
+			router := mux.NewRouter()
+			router.PathPrefix("/").HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(http.StatusTeapot)
+			})
+			handler.AddRoutes(router)
+			router.SortRoutes()
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(test.method, "/ping", nil))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.method != http.MethodHead {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+// +build linux,cgo
+
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/containous/traefik/log"
+)
+
+// Load opens a Go plugin, built with -buildmode=plugin against the same Træfik sources,
+// whose init functions register its middlewares.
+func Load(path string) error {
+	registered := len(Names())
+	if _, err := plugin.Open(path); err != nil {
+		return fmt.Errorf("error loading plugin %s: %v", path, err)
+	}
+	log.Infof("Loaded plugin %s, registering %d middlewares", path, len(Names())-registered)
+	return nil
+}
@@ -0,0 +1,11 @@
+// +build !linux !cgo
+
+package plugins
+
+import "fmt"
+
+// Load opens a Go plugin, which is only supported on Linux with cgo enabled:
+// the middlewares must then be registered by compile-time extensions.
+func Load(path string) error {
+	return fmt.Errorf("error loading plugin %s: Go plugins are not supported on this platform", path)
+}
@@ -0,0 +1,54 @@
+package plugins
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Constructor creates a middleware handling the requests before next, from the settings given by the dynamic configuration.
+type Constructor func(next http.Handler, settings map[string]string) (http.Handler, error)
+
+var (
+	mutex        sync.RWMutex
+	constructors = make(map[string]Constructor)
+)
+
+// Register makes a middleware available under name to the plugin middlewares of the dynamic configuration.
+// It is meant to be called by the init function of a compile-time extension, or of a Go plugin loaded at startup,
+// and panics if the name is empty or already registered.
+func Register(name string, constructor Constructor) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if name == "" || constructor == nil {
+		panic("plugins: Register called with an empty name or a nil constructor")
+	}
+	if _, ok := constructors[name]; ok {
+		panic(fmt.Sprintf("plugins: Register called twice for middleware %s", name))
+	}
+	constructors[name] = constructor
+}
+
+// Get returns the constructor of the middleware registered under name
+func Get(name string) (Constructor, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	constructor, ok := constructors[name]
+	return constructor, ok
+}
+
+// Names returns the sorted names of the registered middlewares
+func Names() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
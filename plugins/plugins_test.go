@@ -0,0 +1,36 @@
+package plugins
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	constructor := func(next http.Handler, settings map[string]string) (http.Handler, error) {
+		return next, nil
+	}
+
+	Register("test-register", constructor)
+	defer func() {
+		mutex.Lock()
+		delete(constructors, "test-register")
+		mutex.Unlock()
+	}()
+
+	_, ok := Get("test-register")
+	assert.True(t, ok)
+	assert.Contains(t, Names(), "test-register")
+
+	_, ok = Get("test-unregistered")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register("test-register", constructor) })
+	assert.Panics(t, func() { Register("", constructor) })
+	assert.Panics(t, func() { Register("test-nil", nil) })
+}
+
+func TestLoadMissingPlugin(t *testing.T) {
+	assert.Error(t, Load("missing.so"))
+}
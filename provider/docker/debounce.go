@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/safe"
+)
+
+// debouncer calls its function once no trigger happened during its delay,
+// merging the triggers of a burst in a single call.
+// The calls are made one at a time by a single worker, the triggers completed during a call
+// being merged in a single call made after it, so that the last call is always the latest one.
+type debouncer struct {
+	delay    time.Duration
+	function func()
+	lock     sync.Mutex
+	timer    *time.Timer
+	pending  chan struct{}
+	stopped  chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newDebouncer(delay time.Duration, function func()) *debouncer {
+	d := &debouncer{
+		delay:    delay,
+		function: function,
+		pending:  make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	safe.Go(d.run)
+	return d
+}
+
+func (d *debouncer) run() {
+	defer close(d.done)
+	for {
+		select {
+		case <-d.stopped:
+			return
+		case <-d.pending:
+			d.function()
+		}
+	}
+}
+
+// trigger postpones the call of the function to the end of the delay,
+// or requests it right away without delay.
+func (d *debouncer) trigger() {
+	if d.delay <= 0 {
+		d.request()
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.delay, d.request)
+}
+
+// request asks the worker for a call, unless one is already pending
+func (d *debouncer) request() {
+	select {
+	case d.pending <- struct{}{}:
+	default:
+	}
+}
+
+// stop cancels the pending call of the function if any, and waits for the call in progress to complete.
+func (d *debouncer) stop() {
+	d.lock.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.lock.Unlock()
+
+	d.stopOnce.Do(func() {
+		close(d.stopped)
+	})
+	<-d.done
+}
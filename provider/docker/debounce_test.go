@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebouncer(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		delay         time.Duration
+		triggers      int
+		interval      time.Duration
+		expectedCalls int32
+	}{
+		{
+			desc:          "burst merged in a single call",
+			delay:         50 * time.Millisecond,
+			triggers:      10,
+			expectedCalls: 1,
+		},
+		{
+			desc:          "no delay",
+			triggers:      3,
+			interval:      50 * time.Millisecond,
+			expectedCalls: 3,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			debouncer := newDebouncer(test.delay, func() {
+				atomic.AddInt32(&calls, 1)
+			})
+			defer debouncer.stop()
+			for i := 0; i < test.triggers; i++ {
+				debouncer.trigger()
+				time.Sleep(test.interval)
+			}
+
+			time.Sleep(test.delay + 100*time.Millisecond)
+			assert.Equal(t, test.expectedCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	var calls int32
+	debouncer := newDebouncer(50*time.Millisecond, func() {
+		atomic.AddInt32(&calls, 1)
+	})
+	debouncer.trigger()
+	debouncer.stop()
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestDebouncerSerialCalls(t *testing.T) {
+	var calls, running, overlaps int32
+	release := make(chan struct{})
+	debouncer := newDebouncer(10*time.Millisecond, func() {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		atomic.AddInt32(&running, -1)
+	})
+	defer debouncer.stop()
+
+	debouncer.trigger()
+	time.Sleep(50 * time.Millisecond)
+	// the triggers during the first call lead to a single call after it
+	for i := 0; i < 3; i++ {
+		debouncer.trigger()
+		time.Sleep(30 * time.Millisecond)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	close(release)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlaps))
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/BurntSushi/ty/fun"
 	"github.com/cenk/backoff"
+	"github.com/containous/flaeg"
 	"github.com/containous/traefik/job"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/provider"
@@ -35,6 +38,8 @@ const (
 	SwarmAPIVersion string = "1.24"
 	// SwarmDefaultWatchTime is the duration of the interval when polling docker
 	SwarmDefaultWatchTime = 15 * time.Second
+	// DefaultEventsDebounce is the delay without container events before refreshing the configuration, when none is set
+	DefaultEventsDebounce = 100 * time.Millisecond
 
 	labelDockerNetwork            = "traefik.docker.network"
 	labelBackendLoadbalancerSwarm = "traefik.backend.loadbalancer.swarm"
@@ -53,6 +58,7 @@ type Provider struct {
 	ExposedByDefault      bool             `description:"Expose containers by default" export:"true"`
 	UseBindPortIP         bool             `description:"Use the ip address from the bound port, rather than from the inner network" export:"true"`
 	SwarmMode             bool             `description:"Use Docker on Swarm Mode" export:"true"`
+	EventsDebounce        flaeg.Duration   `description:"Delay without container events before refreshing the configuration, merging the events of a burst" export:"true"`
 }
 
 // dockerData holds the need data to the Provider p
@@ -85,8 +91,12 @@ func (p *Provider) createClient() (client.APIClient, error) {
 	httpHeaders := map[string]string{
 		"User-Agent": "Traefik " + version.Version,
 	}
-	if p.TLS != nil {
-		config, err := p.TLS.CreateTLSConfig()
+	clientTLS := p.TLS
+	if clientTLS == nil && strings.HasPrefix(p.Endpoint, "tcp://") {
+		clientTLS = clientTLSFromEnv()
+	}
+	if clientTLS != nil {
+		config, err := clientTLS.CreateTLSConfig()
 		if err != nil {
 			return nil, err
 		}
@@ -115,6 +125,21 @@ func (p *Provider) createClient() (client.APIClient, error) {
 
 }
 
+// clientTLSFromEnv returns the TLS configuration of the DOCKER_CERT_PATH and DOCKER_TLS_VERIFY
+// environment variables of the docker client, nil without DOCKER_CERT_PATH
+func clientTLSFromEnv() *types.ClientTLS {
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if len(certPath) == 0 {
+		return nil
+	}
+	return &types.ClientTLS{
+		CA:                 filepath.Join(certPath, "ca.pem"),
+		Cert:               filepath.Join(certPath, "cert.pem"),
+		Key:                filepath.Join(certPath, "key.pem"),
+		InsecureSkipVerify: len(os.Getenv("DOCKER_TLS_VERIFY")) == 0,
+	}
+}
+
 // Provide allows the docker provider to provide configurations to traefik
 // using the given configuration channel.
 func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
@@ -210,8 +235,7 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 						Filters: f,
 					}
 
-					startStopHandle := func(m eventtypes.Message) {
-						log.Debugf("Provider event received %+v", m)
+					refreshConfiguration := func() {
 						containers, err := listContainers(ctx, dockerClient)
 						if err != nil {
 							log.Errorf("Failed to list containers for docker, error %s", err)
@@ -228,14 +252,19 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 						}
 					}
 
+					eventsDebounce := time.Duration(p.EventsDebounce)
+					if eventsDebounce <= 0 {
+						eventsDebounce = DefaultEventsDebounce
+					}
+					refresh := newDebouncer(eventsDebounce, refreshConfiguration)
 					eventsc, errc := dockerClient.Events(ctx, options)
 					for event := range eventsc {
-						if event.Action == "start" ||
-							event.Action == "die" ||
-							strings.HasPrefix(event.Action, "health_status") {
-							startStopHandle(event)
+						if isRefreshEvent(event) {
+							log.Debugf("Provider event received %+v", event)
+							refresh.trigger()
 						}
 					}
+					refresh.stop()
 					if err := <-errc; err != nil {
 						return err
 					}
@@ -255,41 +284,68 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	return nil
 }
 
+// isRefreshEvent returns whether the container event changes the configuration
+func isRefreshEvent(event eventtypes.Message) bool {
+	return event.Action == "start" ||
+		event.Action == "stop" ||
+		event.Action == "die" ||
+		strings.HasPrefix(event.Action, "health_status")
+}
+
 func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Configuration {
 	var DockerFuncMap = template.FuncMap{
-		"getBackend":                  p.getBackend,
-		"getIPAddress":                p.getIPAddress,
-		"getPort":                     p.getPort,
-		"getWeight":                   p.getWeight,
-		"getDomain":                   p.getDomain,
-		"getProtocol":                 p.getProtocol,
-		"getPassHostHeader":           p.getPassHostHeader,
-		"getPriority":                 p.getPriority,
-		"getEntryPoints":              p.getEntryPoints,
-		"getBasicAuth":                p.getBasicAuth,
-		"getFrontendRule":             p.getFrontendRule,
-		"hasCircuitBreakerLabel":      p.hasCircuitBreakerLabel,
-		"getCircuitBreakerExpression": p.getCircuitBreakerExpression,
-		"hasLoadBalancerLabel":        p.hasLoadBalancerLabel,
-		"getLoadBalancerMethod":       p.getLoadBalancerMethod,
-		"hasMaxConnLabels":            p.hasMaxConnLabels,
-		"getMaxConnAmount":            p.getMaxConnAmount,
-		"getMaxConnExtractorFunc":     p.getMaxConnExtractorFunc,
-		"getStickinessCookieName":     p.getStickinessCookieName,
-		"hasStickinessLabel":          p.hasStickinessLabel,
-		"getIsBackendLBSwarm":         p.getIsBackendLBSwarm,
-		"hasServices":                 p.hasServices,
-		"getServiceNames":             p.getServiceNames,
-		"getServicePort":              p.getServicePort,
-		"getServiceWeight":            p.getServiceWeight,
-		"getServiceProtocol":          p.getServiceProtocol,
-		"getServiceEntryPoints":       p.getServiceEntryPoints,
-		"getServiceBasicAuth":         p.getServiceBasicAuth,
-		"getServiceFrontendRule":      p.getServiceFrontendRule,
-		"getServicePassHostHeader":    p.getServicePassHostHeader,
-		"getServicePriority":          p.getServicePriority,
-		"getServiceBackend":           p.getServiceBackend,
-		"getWhitelistSourceRange":     p.getWhitelistSourceRange,
+		"getBackend":                     p.getBackend,
+		"getIPAddress":                   p.getIPAddress,
+		"getPort":                        p.getPort,
+		"getWeight":                      p.getWeight,
+		"getDomain":                      p.getDomain,
+		"getProtocol":                    p.getProtocol,
+		"getPassHostHeader":              p.getPassHostHeader,
+		"getPriority":                    p.getPriority,
+		"getEntryPoints":                 p.getEntryPoints,
+		"getBasicAuth":                   p.getBasicAuth,
+		"getFrontendRule":                p.getFrontendRule,
+		"hasCircuitBreakerLabel":         p.hasCircuitBreakerLabel,
+		"getCircuitBreakerExpression":    p.getCircuitBreakerExpression,
+		"hasLoadBalancerLabel":           p.hasLoadBalancerLabel,
+		"getLoadBalancerMethod":          p.getLoadBalancerMethod,
+		"hasMaxConnLabels":               p.hasMaxConnLabels,
+		"getMaxConnAmount":               p.getMaxConnAmount,
+		"getMaxConnExtractorFunc":        p.getMaxConnExtractorFunc,
+		"getStickinessCookieName":        p.getStickinessCookieName,
+		"hasStickinessLabel":             p.hasStickinessLabel,
+		"getIsBackendLBSwarm":            p.getIsBackendLBSwarm,
+		"hasServices":                    p.hasServices,
+		"getServiceNames":                p.getServiceNames,
+		"getServicePort":                 p.getServicePort,
+		"getServiceWeight":               p.getServiceWeight,
+		"getServiceProtocol":             p.getServiceProtocol,
+		"getServiceEntryPoints":          p.getServiceEntryPoints,
+		"getServiceBasicAuth":            p.getServiceBasicAuth,
+		"getServiceFrontendRule":         p.getServiceFrontendRule,
+		"getServicePassHostHeader":       p.getServicePassHostHeader,
+		"getServicePriority":             p.getServicePriority,
+		"getServiceBackend":              p.getServiceBackend,
+		"getWhitelistSourceRange":        p.getWhitelistSourceRange,
+		"getServiceWhitelistSourceRange": p.getServiceWhitelistSourceRange,
+		"getServicePassTLSCert":          p.getServicePassTLSCert,
+		"hasServiceRedirect":             p.hasServiceRedirect,
+		"getServiceRedirectRegex":        p.getServiceRedirectRegex,
+		"getServiceRedirectReplacement":  p.getServiceRedirectReplacement,
+		"getServiceRedirectStatusCode":   p.getServiceRedirectStatusCode,
+		"getServiceRequestHeaders":       p.getServiceRequestHeaders,
+		"getServiceResponseHeaders":      p.getServiceResponseHeaders,
+		"getPassTLSCert":                 p.getPassTLSCert,
+		"hasRedirect":                    p.hasRedirect,
+		"getRedirectRegex":               p.getRedirectRegex,
+		"getRedirectReplacement":         p.getRedirectReplacement,
+		"getRedirectStatusCode":          p.getRedirectStatusCode,
+		"getRequestHeaders":              p.getRequestHeaders,
+		"getResponseHeaders":             p.getResponseHeaders,
+		"hasTLSCertificateLabels":        p.hasTLSCertificateLabels,
+		"getTLSEntryPoints":              p.getTLSEntryPoints,
+		"getTLSCertificateCertFile":      p.getTLSCertificateCertFile,
+		"getTLSCertificateKeyFile":       p.getTLSCertificateKeyFile,
 	}
 	// filter containers
 	filteredContainers := fun.Filter(func(container dockerData) bool {
@@ -462,6 +518,75 @@ func (p *Provider) getServiceProtocol(container dockerData, serviceName string)
 	return p.getProtocol(container)
 }
 
+// Gets the entry of the label for a given service, prefixed with the service name, from the labels of the given container
+func getServiceLabel(container dockerData, serviceName string, label string) (string, bool) {
+	return getContainerServiceLabel(container, serviceName, strings.TrimPrefix(label, types.LabelPrefix))
+}
+
+// Extract whitelistSourceRange from labels for a given service and a given docker container
+func (p *Provider) getServiceWhitelistSourceRange(container dockerData, serviceName string) []string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelTraefikFrontendWhitelistSourceRange); ok {
+		return provider.SplitAndTrimString(value)
+	}
+	return p.getWhitelistSourceRange(container)
+}
+
+// Extract passTLSCert from labels for a given service and a given docker container
+func (p *Provider) getServicePassTLSCert(container dockerData, serviceName string) string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelFrontendPassTLSCert); ok {
+		return value
+	}
+	return p.getPassTLSCert(container)
+}
+
+// Check the redirection regex label for a given service and a given docker container
+func (p *Provider) hasServiceRedirect(container dockerData, serviceName string) bool {
+	if _, ok := getServiceLabel(container, serviceName, types.LabelFrontendRedirectRegex); ok {
+		return true
+	}
+	return p.hasRedirect(container)
+}
+
+// Extract the redirection regex from labels for a given service and a given docker container
+func (p *Provider) getServiceRedirectRegex(container dockerData, serviceName string) string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelFrontendRedirectRegex); ok {
+		return value
+	}
+	return p.getRedirectRegex(container)
+}
+
+// Extract the redirection replacement from labels for a given service and a given docker container
+func (p *Provider) getServiceRedirectReplacement(container dockerData, serviceName string) string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelFrontendRedirectReplacement); ok {
+		return value
+	}
+	return p.getRedirectReplacement(container)
+}
+
+// Extract the redirection status code from labels for a given service and a given docker container
+func (p *Provider) getServiceRedirectStatusCode(container dockerData, serviceName string) string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelFrontendRedirectStatusCode); ok {
+		return value
+	}
+	return p.getRedirectStatusCode(container)
+}
+
+// Extract the custom request headers from labels for a given service and a given docker container
+func (p *Provider) getServiceRequestHeaders(container dockerData, serviceName string) map[string]string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelFrontendRequestHeaders); ok {
+		return parseHeaders(value)
+	}
+	return p.getRequestHeaders(container)
+}
+
+// Extract the custom response headers from labels for a given service and a given docker container
+func (p *Provider) getServiceResponseHeaders(container dockerData, serviceName string) map[string]string {
+	if value, ok := getServiceLabel(container, serviceName, types.LabelFrontendResponseHeaders); ok {
+		return parseHeaders(value)
+	}
+	return p.getResponseHeaders(container)
+}
+
 func (p *Provider) hasLoadBalancerLabel(container dockerData) bool {
 	_, errMethod := getLabel(container, types.LabelBackendLoadbalancerMethod)
 	_, errSticky := getLabel(container, types.LabelBackendLoadbalancerSticky)
@@ -527,7 +652,7 @@ func (p *Provider) containerFilter(container dockerData) bool {
 	}
 
 	constraintTags := strings.Split(container.Labels[types.LabelTags], ",")
-	if ok, failingConstraint := p.MatchConstraints(constraintTags); !ok {
+	if ok, failingConstraint := p.MatchConstraintsWithLabels(constraintTags, container.Labels); !ok {
 		if failingConstraint != nil {
 			log.Debugf("Container %v pruned by '%v' constraint", container.Name, failingConstraint.String())
 		}
@@ -699,6 +824,67 @@ func (p *Provider) getWhitelistSourceRange(container dockerData) []string {
 	return whitelistSourceRange
 }
 
+func (p *Provider) getPassTLSCert(container dockerData) string {
+	if passTLSCert, err := getLabel(container, types.LabelFrontendPassTLSCert); err == nil {
+		return passTLSCert
+	}
+	return "false"
+}
+
+func (p *Provider) hasRedirect(container dockerData) bool {
+	_, err := getLabel(container, types.LabelFrontendRedirectRegex)
+	return err == nil
+}
+
+func (p *Provider) getRedirectRegex(container dockerData) string {
+	if regex, err := getLabel(container, types.LabelFrontendRedirectRegex); err == nil {
+		return regex
+	}
+	return ""
+}
+
+func (p *Provider) getRedirectReplacement(container dockerData) string {
+	if replacement, err := getLabel(container, types.LabelFrontendRedirectReplacement); err == nil {
+		return replacement
+	}
+	return ""
+}
+
+func (p *Provider) getRedirectStatusCode(container dockerData) string {
+	if statusCode, err := getLabel(container, types.LabelFrontendRedirectStatusCode); err == nil {
+		return statusCode
+	}
+	return "0"
+}
+
+func (p *Provider) getRequestHeaders(container dockerData) map[string]string {
+	if headers, err := getLabel(container, types.LabelFrontendRequestHeaders); err == nil {
+		return parseHeaders(headers)
+	}
+	return nil
+}
+
+func (p *Provider) getResponseHeaders(container dockerData) map[string]string {
+	if headers, err := getLabel(container, types.LabelFrontendResponseHeaders); err == nil {
+		return parseHeaders(headers)
+	}
+	return nil
+}
+
+// parseHeaders parses the headers of a label, formatted as "Name:value||Name2:value2"
+func parseHeaders(label string) map[string]string {
+	headers := map[string]string{}
+	for _, header := range strings.Split(label, "||") {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			log.Warnf("Ignoring the invalid header %q of the label, expected Name:value", header)
+			continue
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers
+}
+
 func (p *Provider) getPriority(container dockerData) string {
 	if priority, err := getLabel(container, types.LabelFrontendPriority); err == nil {
 		return priority
@@ -713,6 +899,39 @@ func (p *Provider) getEntryPoints(container dockerData) []string {
 	return []string{}
 }
 
+// hasTLSCertificateLabels returns whether the container provides a certificate, with its key
+func (p *Provider) hasTLSCertificateLabels(container dockerData) bool {
+	if _, err := getLabel(container, types.LabelTLSCertificateCertFile); err != nil {
+		return false
+	}
+	if _, err := getLabel(container, types.LabelTLSCertificateKeyFile); err != nil {
+		return false
+	}
+	return true
+}
+
+// getTLSEntryPoints returns the entry points serving the certificate of the container, the default ones when empty
+func (p *Provider) getTLSEntryPoints(container dockerData) []string {
+	if entryPoints, err := getLabel(container, types.LabelTLSEntryPoints); err == nil {
+		return strings.Split(entryPoints, ",")
+	}
+	return []string{}
+}
+
+func (p *Provider) getTLSCertificateCertFile(container dockerData) string {
+	if certFile, err := getLabel(container, types.LabelTLSCertificateCertFile); err == nil {
+		return certFile
+	}
+	return ""
+}
+
+func (p *Provider) getTLSCertificateKeyFile(container dockerData) string {
+	if keyFile, err := getLabel(container, types.LabelTLSCertificateKeyFile); err == nil {
+		return keyFile
+	}
+	return ""
+}
+
 func (p *Provider) getBasicAuth(container dockerData) []string {
 	if basicAuth, err := getLabel(container, types.LabelFrontendAuthBasic); err == nil {
 		return strings.Split(basicAuth, ",")
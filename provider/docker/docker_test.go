@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -462,6 +464,98 @@ func TestDockerGetWhitelistSourceRange(t *testing.T) {
 	}
 }
 
+func TestDockerParseHeaders(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		label    string
+		expected map[string]string
+	}{
+		{
+			desc:     "single header",
+			label:    "X-Tenant:acme",
+			expected: map[string]string{"X-Tenant": "acme"},
+		},
+		{
+			desc:  "multiple headers",
+			label: "X-Tenant: acme || X-Forwarded-Proto:https",
+			expected: map[string]string{
+				"X-Tenant":          "acme",
+				"X-Forwarded-Proto": "https",
+			},
+		},
+		{
+			desc:     "value with a colon",
+			label:    "X-Origin:http://foo.bar:8080",
+			expected: map[string]string{"X-Origin": "http://foo.bar:8080"},
+		},
+		{
+			desc:     "invalid headers",
+			label:    "X-Tenant||:acme",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			actual := parseHeaders(test.label)
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDockerClientTLSFromEnv(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		certPath  string
+		tlsVerify string
+		expected  *types.ClientTLS
+	}{
+		{
+			desc: "no cert path",
+		},
+		{
+			desc:      "cert path with verification",
+			certPath:  "/etc/docker/certs",
+			tlsVerify: "1",
+			expected: &types.ClientTLS{
+				CA:   "/etc/docker/certs/ca.pem",
+				Cert: "/etc/docker/certs/cert.pem",
+				Key:  "/etc/docker/certs/key.pem",
+			},
+		},
+		{
+			desc:     "cert path without verification",
+			certPath: "/etc/docker/certs",
+			expected: &types.ClientTLS{
+				CA:                 "/etc/docker/certs/ca.pem",
+				Cert:               "/etc/docker/certs/cert.pem",
+				Key:                "/etc/docker/certs/key.pem",
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	defer os.Unsetenv("DOCKER_CERT_PATH")
+	defer os.Unsetenv("DOCKER_TLS_VERIFY")
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			os.Setenv("DOCKER_CERT_PATH", test.certPath)
+			os.Setenv("DOCKER_TLS_VERIFY", test.tlsVerify)
+
+			actual := clientTLSFromEnv()
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
 func TestDockerGetLabel(t *testing.T) {
 	containers := []struct {
 		container docker.ContainerJSON
@@ -1024,6 +1118,63 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			containers: []docker.ContainerJSON{
+				containerJSON(
+					name("test1"),
+					labels(map[string]string{
+						types.LabelFrontendPassTLSCert:         "true",
+						types.LabelFrontendRedirectRegex:       `^http://(.*)\.docker\.localhost/(.*)`,
+						types.LabelFrontendRedirectReplacement: "https://$1.example.com/$2",
+						types.LabelFrontendRedirectStatusCode:  "301",
+						types.LabelFrontendRequestHeaders:      "X-Tenant:acme || X-Quote:say \"hello\"",
+						types.LabelFrontendResponseHeaders:     "X-Powered-By:docker",
+					}),
+					ports(nat.PortMap{
+						"80/tcp": {},
+					}),
+					withNetwork("bridge", ipv4("127.0.0.1")),
+				),
+			},
+			expectedFrontends: map[string]*types.Frontend{
+				"frontend-Host-test1-docker-localhost": {
+					Backend:        "backend-test1",
+					PassHostHeader: true,
+					PassTLSCert:    true,
+					EntryPoints:    []string{},
+					BasicAuth:      []string{},
+					Redirect: &types.Redirect{
+						Regex:       `^http://(.*)\.docker\.localhost/(.*)`,
+						Replacement: "https://$1.example.com/$2",
+						StatusCode:  301,
+					},
+					Headers: types.Headers{
+						CustomRequestHeaders: map[string]string{
+							"X-Tenant": "acme",
+							"X-Quote":  `say "hello"`,
+						},
+						CustomResponseHeaders: map[string]string{
+							"X-Powered-By": "docker",
+						},
+					},
+					Routes: map[string]types.Route{
+						"route-frontend-Host-test1-docker-localhost": {
+							Rule: "Host:test1.docker.localhost",
+						},
+					},
+				},
+			},
+			expectedBackends: map[string]*types.Backend{
+				"backend-test1": {
+					Servers: map[string]types.Server{
+						"server-test1": {
+							URL:    "http://127.0.0.1:80",
+							Weight: 0,
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for caseID, c := range cases {
@@ -1051,3 +1202,102 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDockerLoadDockerConfigExtendingDefaultTemplate(t *testing.T) {
+	templateFile, err := ioutil.TempFile("", "docker-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(templateFile.Name())
+
+	data := []byte(`{{template "default" .}}
+{{range $frontend, $containers := .Frontends}}
+  {{$container := index $containers 0}}
+  [frontends."legacy-{{getBackend $container}}"]
+  backend = "backend-{{getBackend $container}}"
+    [frontends."legacy-{{getBackend $container}}".routes.legacy]
+    rule = "PathPrefix:/legacy/{{getBackend $container}}"
+{{end}}`)
+	if err := ioutil.WriteFile(templateFile.Name(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+	provider.Filename = templateFile.Name()
+
+	container := containerJSON(
+		name("test"),
+		ports(nat.PortMap{
+			"80/tcp": {},
+		}),
+		withNetwork("bridge", ipv4("127.0.0.1")),
+	)
+	configuration := provider.loadDockerConfig([]dockerData{parseContainer(container)})
+	if configuration == nil {
+		t.Fatal("expected a configuration")
+	}
+
+	if _, ok := configuration.Frontends["frontend-Host-test-docker-localhost"]; !ok {
+		t.Errorf("expected the frontend of the default template, got %v", configuration.Frontends)
+	}
+	legacy, ok := configuration.Frontends["legacy-test"]
+	if !ok {
+		t.Fatalf("expected the frontend of the custom template, got %v", configuration.Frontends)
+	}
+	if legacy.Routes["legacy"].Rule != "PathPrefix:/legacy/test" {
+		t.Errorf("expected the legacy rule, got %q", legacy.Routes["legacy"].Rule)
+	}
+}
+
+func TestDockerLoadDockerConfigTLSCertificate(t *testing.T) {
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	container := containerJSON(
+		name("test"),
+		labels(map[string]string{
+			types.LabelTLSEntryPoints:         "https,admin",
+			types.LabelTLSCertificateCertFile: "-----BEGIN CERTIFICATE-----\ncert\n-----END CERTIFICATE-----",
+			types.LabelTLSCertificateKeyFile:  "/certs/test.key",
+		}),
+		ports(nat.PortMap{
+			"80/tcp": {},
+		}),
+		withNetwork("bridge", ipv4("127.0.0.1")),
+	)
+	withoutKey := containerJSON(
+		name("other"),
+		labels(map[string]string{
+			types.LabelTLSCertificateCertFile: "/certs/other.cert",
+		}),
+		ports(nat.PortMap{
+			"80/tcp": {},
+		}),
+		withNetwork("bridge", ipv4("127.0.0.2")),
+	)
+	configuration := provider.loadDockerConfig([]dockerData{parseContainer(container), parseContainer(withoutKey)})
+	if configuration == nil {
+		t.Fatal("expected a configuration")
+	}
+
+	expected := []*types.TLSConfiguration{
+		{
+			EntryPoints: []string{"https", "admin"},
+			Certificate: &types.TLSCertificate{
+				CertFile: "-----BEGIN CERTIFICATE-----\ncert\n-----END CERTIFICATE-----",
+				KeyFile:  "/certs/test.key",
+			},
+		},
+	}
+	if !reflect.DeepEqual(configuration.TLSConfiguration, expected) {
+		t.Errorf("expected %+v, got %+v", expected[0].Certificate, configuration.TLSConfiguration)
+	}
+	if _, ok := configuration.Frontends["frontend-Host-test-docker-localhost"]; !ok {
+		t.Errorf("expected the frontend of the container, got %v", configuration.Frontends)
+	}
+}
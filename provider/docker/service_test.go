@@ -444,6 +444,86 @@ func TestDockerLoadDockerServiceConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			containers: []docker.ContainerJSON{
+				containerJSON(
+					name("app"),
+					labels(map[string]string{
+						types.LabelTraefikFrontendWhitelistSourceRange:         "10.0.0.0/8",
+						types.LabelFrontendResponseHeaders:                     "X-App:app",
+						"traefik.public.port":                                  "80",
+						"traefik.public.frontend.rule":                         "Host:app.example.com",
+						"traefik.public.frontend.whitelistSourceRange":         "",
+						"traefik.admin.port":                                   "9090",
+						"traefik.admin.frontend.rule":                          "Host:admin.example.com",
+						"traefik.admin.frontend.passTLSCert":                   "true",
+						"traefik.admin.frontend.redirect.regex":                "^http://admin.example.com/(.*)",
+						"traefik.admin.frontend.redirect.replacement":          "https://admin.example.com/$1",
+						"traefik.admin.frontend.headers.customRequestHeaders":  "X-Admin:true",
+						"traefik.admin.frontend.headers.customResponseHeaders": "X-App:admin",
+					}),
+					ports(nat.PortMap{
+						"80/tcp": {},
+					}),
+					withNetwork("bridge", ipv4("127.0.0.1")),
+				),
+			},
+			expectedFrontends: map[string]*types.Frontend{
+				"frontend-app-public": {
+					Backend:        "backend-app-public",
+					PassHostHeader: true,
+					EntryPoints:    []string{},
+					BasicAuth:      []string{},
+					Headers: types.Headers{
+						CustomResponseHeaders: map[string]string{"X-App": "app"},
+					},
+					Routes: map[string]types.Route{
+						"service-public": {
+							Rule: "Host:app.example.com",
+						},
+					},
+				},
+				"frontend-app-admin": {
+					Backend:              "backend-app-admin",
+					PassHostHeader:       true,
+					PassTLSCert:          true,
+					WhitelistSourceRange: []string{"10.0.0.0/8"},
+					EntryPoints:          []string{},
+					BasicAuth:            []string{},
+					Redirect: &types.Redirect{
+						Regex:       "^http://admin.example.com/(.*)",
+						Replacement: "https://admin.example.com/$1",
+					},
+					Headers: types.Headers{
+						CustomRequestHeaders:  map[string]string{"X-Admin": "true"},
+						CustomResponseHeaders: map[string]string{"X-App": "admin"},
+					},
+					Routes: map[string]types.Route{
+						"service-admin": {
+							Rule: "Host:admin.example.com",
+						},
+					},
+				},
+			},
+			expectedBackends: map[string]*types.Backend{
+				"backend-app-public": {
+					Servers: map[string]types.Server{
+						"service": {
+							URL:    "http://127.0.0.1:80",
+							Weight: 0,
+						},
+					},
+				},
+				"backend-app-admin": {
+					Servers: map[string]types.Server{
+						"service": {
+							URL:    "http://127.0.0.1:9090",
+							Weight: 0,
+						},
+					},
+				},
+			},
+		},
 	}
 
 	provider := &Provider{
@@ -131,6 +131,52 @@ func loadFileConfigFromDirectory(directory string) (*types.Configuration, error)
 				configuration.Frontends[frontendName] = frontend
 			}
 		}
+
+		configuration.TLSConfiguration = append(configuration.TLSConfiguration, c.TLSConfiguration...)
+
+		for backendName, backend := range c.TCPBackends {
+			if configuration.TCPBackends == nil {
+				configuration.TCPBackends = make(map[string]*types.TCPBackend)
+			}
+			if _, exists := configuration.TCPBackends[backendName]; exists {
+				log.Warnf("TCP backend %s already configured, skipping", backendName)
+			} else {
+				configuration.TCPBackends[backendName] = backend
+			}
+		}
+
+		for frontendName, frontend := range c.TCPFrontends {
+			if configuration.TCPFrontends == nil {
+				configuration.TCPFrontends = make(map[string]*types.TCPFrontend)
+			}
+			if _, exists := configuration.TCPFrontends[frontendName]; exists {
+				log.Warnf("TCP frontend %s already configured, skipping", frontendName)
+			} else {
+				configuration.TCPFrontends[frontendName] = frontend
+			}
+		}
+
+		for backendName, backend := range c.UDPBackends {
+			if configuration.UDPBackends == nil {
+				configuration.UDPBackends = make(map[string]*types.UDPBackend)
+			}
+			if _, exists := configuration.UDPBackends[backendName]; exists {
+				log.Warnf("UDP backend %s already configured, skipping", backendName)
+			} else {
+				configuration.UDPBackends[backendName] = backend
+			}
+		}
+
+		for frontendName, frontend := range c.UDPFrontends {
+			if configuration.UDPFrontends == nil {
+				configuration.UDPFrontends = make(map[string]*types.UDPFrontend)
+			}
+			if _, exists := configuration.UDPFrontends[frontendName]; exists {
+				log.Warnf("UDP frontend %s already configured, skipping", frontendName)
+			} else {
+				configuration.UDPFrontends[frontendName] = frontend
+			}
+		}
 	}
 
 	return configuration, nil
@@ -463,3 +463,42 @@ func TestKVLoadConfig(t *testing.T) {
 		t.Fatalf("expected %+v, got %+v", expected.Frontends, actual.Frontends)
 	}
 }
+
+func TestKVLoadConfigTLSConfigurations(t *testing.T) {
+	provider := &Provider{
+		Prefix: "traefik",
+		kvclient: &Mock{
+			KVPairs: []*store.KVPair{
+				{
+					Key:   "traefik/tlsconfigurations/snitest",
+					Value: []byte(""),
+				},
+				{
+					Key:   "traefik/tlsconfigurations/snitest/entrypoints",
+					Value: []byte("https,https2"),
+				},
+				{
+					Key:   "traefik/tlsconfigurations/snitest/certificate/certfile",
+					Value: []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"),
+				},
+				{
+					Key:   "traefik/tlsconfigurations/snitest/certificate/keyfile",
+					Value: []byte("/path/to/snitest.key"),
+				},
+			},
+		},
+	}
+	actual := provider.loadConfig()
+	expected := []*types.TLSConfiguration{
+		{
+			EntryPoints: []string{"https", "https2"},
+			Certificate: &types.TLSCertificate{
+				CertFile: "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----",
+				KeyFile:  "/path/to/snitest.key",
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual.TLSConfiguration, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, actual.TLSConfiguration)
+	}
+}
@@ -0,0 +1,188 @@
+package marathon
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// dcosLoginPath is the path of the DC/OS ACS login endpoint, on the host of the Marathon endpoint
+	dcosLoginPath = "/acs/api/v1/auth/login"
+	// dcosLoginTokenLifetime is the lifetime of the token signed to log in with the service account
+	dcosLoginTokenLifetime = 5 * time.Minute
+	// dcosTokenRefreshMargin is the duration before the expiration of the ACS token when it is refreshed
+	dcosTokenRefreshMargin = 5 * time.Minute
+)
+
+// DCOSServiceAccount holds the DC/OS service account logging in to get, and refresh, the ACS token of the provider
+type DCOSServiceAccount struct {
+	UID           string `description:"UID of the service account"`
+	PrivateKey    string `description:"RSA private key of the service account, or the path of its PEM file"`
+	LoginEndpoint string `description:"DC/OS ACS login endpoint, on the host of the Marathon endpoint by default"`
+}
+
+// dcosTokenTransport is a http.RoundTripper authenticating the requests with the ACS token of a service account,
+// logging in again before the token expires or when Marathon rejects it
+type dcosTokenTransport struct {
+	next          http.RoundTripper
+	uid           string
+	privateKey    *rsa.PrivateKey
+	loginEndpoint string
+	lock          sync.Mutex
+	token         string
+	expiration    time.Time
+}
+
+func newDCOSTokenTransport(account *DCOSServiceAccount, marathonEndpoint string, next http.RoundTripper) (*dcosTokenTransport, error) {
+	if len(account.UID) == 0 {
+		return nil, fmt.Errorf("the UID of the DC/OS service account is required")
+	}
+
+	content := []byte(account.PrivateKey)
+	if _, err := os.Stat(account.PrivateKey); err == nil {
+		if content, err = ioutil.ReadFile(account.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key of the DC/OS service account %s: %v", account.UID, err)
+	}
+
+	loginEndpoint := account.LoginEndpoint
+	if len(loginEndpoint) == 0 {
+		endpoint, err := url.Parse(strings.Split(marathonEndpoint, ",")[0])
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive the DC/OS login endpoint from %s: %v", marathonEndpoint, err)
+		}
+		loginEndpoint = endpoint.Scheme + "://" + endpoint.Host + dcosLoginPath
+	}
+
+	return &dcosTokenTransport{
+		next:          next,
+		uid:           account.UID,
+		privateKey:    privateKey,
+		loginEndpoint: loginEndpoint,
+	}, nil
+}
+
+func (t *dcosTokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	token, err := t.getToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// a RoundTripper must not modify the request
+	authenticated := new(http.Request)
+	*authenticated = *request
+	authenticated.Header = make(http.Header, len(request.Header)+1)
+	for name, values := range request.Header {
+		authenticated.Header[name] = values
+	}
+	authenticated.Header.Set("Authorization", "token="+token)
+
+	response, err := t.next.RoundTrip(authenticated)
+	if err == nil && response.StatusCode == http.StatusUnauthorized {
+		t.resetToken(token)
+	}
+	return response, err
+}
+
+// getToken returns the current ACS token, logging in first without token or when it is about to expire
+func (t *dcosTokenTransport) getToken() (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.token) > 0 && time.Now().Before(t.expiration.Add(-dcosTokenRefreshMargin)) {
+		return t.token, nil
+	}
+
+	token, expiration, err := t.login()
+	if err != nil {
+		return "", err
+	}
+	log.Debugf("Logged in to DC/OS with the service account %s, token valid until %s", t.uid, expiration)
+	t.token = token
+	t.expiration = expiration
+	return token, nil
+}
+
+// resetToken discards the rejected token, unless it was already refreshed
+func (t *dcosTokenTransport) resetToken(token string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+// login exchanges a token signed with the private key of the service account for an ACS token
+func (t *dcosTokenTransport) login() (string, time.Time, error) {
+	loginToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"uid": t.uid,
+		"exp": time.Now().Add(dcosLoginTokenLifetime).Unix(),
+	}).SignedString(t.privateKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	body, err := json.Marshal(map[string]string{"uid": t.uid, "token": loginToken})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	request, err := http.NewRequest(http.MethodPost, t.loginEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to log in to DC/OS with the service account %s: %v", t.uid, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("unable to log in to DC/OS with the service account %s: status code %d", t.uid, response.StatusCode)
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&login); err != nil || len(login.Token) == 0 {
+		return "", time.Time{}, fmt.Errorf("invalid DC/OS login response for the service account %s: %v", t.uid, err)
+	}
+	return login.Token, tokenExpiration(login.Token), nil
+}
+
+// tokenExpiration returns the expiration of the ACS token, read from its exp claim without verifying it,
+// as Marathon verifies it
+func tokenExpiration(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err == nil {
+			var claims struct {
+				ExpiresAt int64 `json:"exp"`
+			}
+			if err := json.Unmarshal(payload, &claims); err == nil && claims.ExpiresAt > 0 {
+				return time.Unix(claims.ExpiresAt, 0)
+			}
+		}
+	}
+	// without exp claim, the token is kept until Marathon rejects it
+	return time.Now().Add(100 * 365 * 24 * time.Hour)
+}
@@ -0,0 +1,131 @@
+package marathon
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDCOSTokenTransport(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	privateKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}))
+
+	testCases := []struct {
+		desc           string
+		tokenLifetime  time.Duration
+		rejectedTokens int32
+		expectedLogins int32
+	}{
+		{
+			desc:           "token reused",
+			tokenLifetime:  time.Hour,
+			expectedLogins: 1,
+		},
+		{
+			desc:           "token about to expire refreshed",
+			tokenLifetime:  time.Minute,
+			expectedLogins: 3,
+		},
+		{
+			desc:           "rejected token refreshed",
+			tokenLifetime:  time.Hour,
+			rejectedTokens: 1,
+			expectedLogins: 2,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var logins, rejections int32
+			mux := http.NewServeMux()
+			mux.HandleFunc(dcosLoginPath, func(rw http.ResponseWriter, r *http.Request) {
+				var login struct {
+					UID   string `json:"uid"`
+					Token string `json:"token"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&login))
+				_, err := jwt.Parse(login.Token, func(*jwt.Token) (interface{}, error) {
+					return &privateKey.PublicKey, nil
+				})
+				if err != nil || login.UID != "traefik" {
+					rw.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				count := atomic.AddInt32(&logins, 1)
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"uid": fmt.Sprintf("traefik-%d", count),
+					"exp": time.Now().Add(test.tokenLifetime).Unix(),
+				}).SignedString([]byte("secret"))
+				require.NoError(t, err)
+				json.NewEncoder(rw).Encode(map[string]string{"token": token})
+			})
+			mux.HandleFunc("/v2/apps", func(rw http.ResponseWriter, r *http.Request) {
+				if len(r.Header.Get("Authorization")) == 0 || atomic.AddInt32(&rejections, 1) <= test.rejectedTokens {
+					rw.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				rw.WriteHeader(http.StatusOK)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			transport, err := newDCOSTokenTransport(&DCOSServiceAccount{UID: "traefik", PrivateKey: privateKeyPEM}, server.URL+"/marathon", http.DefaultTransport)
+			require.NoError(t, err)
+			client := &http.Client{Transport: transport}
+
+			var statusCodes []int
+			for i := 0; i < 3; i++ {
+				response, err := client.Get(server.URL + "/v2/apps")
+				require.NoError(t, err)
+				response.Body.Close()
+				statusCodes = append(statusCodes, response.StatusCode)
+			}
+
+			assert.Equal(t, test.expectedLogins, atomic.LoadInt32(&logins))
+			assert.Equal(t, http.StatusOK, statusCodes[len(statusCodes)-1])
+		})
+	}
+}
+
+func TestNewDCOSTokenTransportInvalid(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		account DCOSServiceAccount
+	}{
+		{
+			desc:    "missing UID",
+			account: DCOSServiceAccount{PrivateKey: "key"},
+		},
+		{
+			desc:    "invalid private key",
+			account: DCOSServiceAccount{UID: "traefik", PrivateKey: "key"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newDCOSTokenTransport(&test.account, "http://dcos.example.com/marathon", http.DefaultTransport)
+			assert.Error(t, err)
+		})
+	}
+}
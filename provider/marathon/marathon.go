@@ -53,18 +53,20 @@ var servicesPropertiesRegexp = regexp.MustCompile(`^traefik\.(?P<service_name>.+
 // Provider holds configuration of the provider.
 type Provider struct {
 	provider.BaseProvider
-	Endpoint                string           `description:"Marathon server endpoint. You can also specify multiple endpoint for Marathon" export:"true"`
-	Domain                  string           `description:"Default domain used" export:"true"`
-	ExposedByDefault        bool             `description:"Expose Marathon apps by default" export:"true"`
-	GroupsAsSubDomains      bool             `description:"Convert Marathon groups to subdomains" export:"true"`
-	DCOSToken               string           `description:"DCOSToken for DCOS environment, This will override the Authorization header" export:"true"`
-	MarathonLBCompatibility bool             `description:"Add compatibility with marathon-lb labels" export:"true"`
-	TLS                     *types.ClientTLS `description:"Enable Docker TLS support" export:"true"`
-	DialerTimeout           flaeg.Duration   `description:"Set a non-default connection timeout for Marathon" export:"true"`
-	KeepAlive               flaeg.Duration   `description:"Set a non-default TCP Keep Alive time in seconds" export:"true"`
-	ForceTaskHostname       bool             `description:"Force to use the task's hostname." export:"true"`
-	Basic                   *Basic           `description:"Enable basic authentication" export:"true"`
-	RespectReadinessChecks  bool             `description:"Filter out tasks with non-successful readiness checks during deployments" export:"true"`
+	Endpoint                string              `description:"Marathon server endpoint. You can also specify multiple endpoint for Marathon" export:"true"`
+	Domain                  string              `description:"Default domain used" export:"true"`
+	ExposedByDefault        bool                `description:"Expose Marathon apps by default" export:"true"`
+	GroupsAsSubDomains      bool                `description:"Convert Marathon groups to subdomains" export:"true"`
+	DCOSToken               string              `description:"DCOSToken for DCOS environment, This will override the Authorization header" export:"true"`
+	DCOSServiceAccount      *DCOSServiceAccount `description:"DC/OS service account logging in to get and refresh the ACS token, overriding DCOSToken" export:"true"`
+	MarathonLBCompatibility bool                `description:"Add compatibility with marathon-lb labels" export:"true"`
+	TLS                     *types.ClientTLS    `description:"Enable Docker TLS support" export:"true"`
+	DialerTimeout           flaeg.Duration      `description:"Set a non-default connection timeout for Marathon" export:"true"`
+	KeepAlive               flaeg.Duration      `description:"Set a non-default TCP Keep Alive time in seconds" export:"true"`
+	ForceTaskHostname       bool                `description:"Force to use the task's hostname." export:"true"`
+	Basic                   *Basic              `description:"Enable basic authentication" export:"true"`
+	RespectReadinessChecks  bool                `description:"Filter out tasks with non-successful readiness checks during deployments" export:"true"`
+	PollInterval            flaeg.Duration      `description:"Interval of the polls of the applications besides the events, catching up with the events missed while reconnecting" export:"true"`
 	readyChecker            *readinessChecker
 	marathonClient          marathon.Marathon
 }
@@ -117,6 +119,14 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 				TLSClientConfig: TLSConfig,
 			},
 		}
+		if p.DCOSServiceAccount != nil {
+			transport, err := newDCOSTokenTransport(p.DCOSServiceAccount, p.Endpoint, config.HTTPClient.Transport)
+			if err != nil {
+				log.Errorf("Failed to authenticate with the DC/OS service account, error: %s", err)
+				return err
+			}
+			config.HTTPClient.Transport = transport
+		}
 		client, err := marathon.NewClient(config)
 		if err != nil {
 			log.Errorf("Failed to create a client for marathon, error: %s", err)
@@ -132,18 +142,27 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 			}
 			pool.Go(func(stop chan bool) {
 				defer close(update)
+				var poll <-chan time.Time
+				if p.PollInterval > 0 {
+					ticker := time.NewTicker(time.Duration(p.PollInterval))
+					defer ticker.Stop()
+					poll = ticker.C
+				}
 				for {
 					select {
 					case <-stop:
 						return
 					case event := <-update:
 						log.Debugf("Received provider event %s", event)
-						configuration := p.loadMarathonConfig()
-						if configuration != nil {
-							configurationChan <- types.ConfigMessage{
-								ProviderName:  "marathon",
-								Configuration: configuration,
-							}
+						drainEvents(update)
+					case <-poll:
+						log.Debug("Polling the Marathon applications")
+					}
+					configuration := p.loadMarathonConfig()
+					if configuration != nil {
+						configurationChan <- types.ConfigMessage{
+							ProviderName:  "marathon",
+							Configuration: configuration,
 						}
 					}
 				}
@@ -167,6 +186,17 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	return nil
 }
 
+// drainEvents discards the events already received, covered by the configuration loaded next
+func drainEvents(update marathon.EventsChannel) {
+	for {
+		select {
+		case <-update:
+		default:
+			return
+		}
+	}
+}
+
 func (p *Provider) loadMarathonConfig() *types.Configuration {
 	var MarathonFuncMap = template.FuncMap{
 		"getBackend":                  p.getBackend,
@@ -250,7 +280,7 @@ func (p *Provider) applicationFilter(app marathon.Application) bool {
 			constraintTags = append(constraintTags, label)
 		}
 	}
-	if ok, failingConstraint := p.MatchConstraints(constraintTags); !ok {
+	if ok, failingConstraint := p.MatchConstraintsWithLabels(constraintTags, *app.Labels); !ok {
 		if failingConstraint != nil {
 			log.Debugf("Filtering Marathon application %v pruned by '%v' constraint", app.ID, failingConstraint.String())
 		}
@@ -265,7 +295,7 @@ func (p *Provider) taskFilter(task marathon.Task, application marathon.Applicati
 		return false
 	}
 
-	// Filter task with existing, bad health check results.
+	// Filter task with existing, bad health check results, or still warming up without any result.
 	if application.HasHealthChecks() {
 		if task.HasHealthCheckResults() {
 			for _, healthcheck := range task.HealthCheckResults {
@@ -274,6 +304,9 @@ func (p *Provider) taskFilter(task marathon.Task, application marathon.Applicati
 					return false
 				}
 			}
+		} else if isTaskWarmingUp(task, application) {
+			log.Debugf("Filtering Marathon task %s from application %s without health check result during its grace period", task.ID, application.ID)
+			return false
 		}
 	}
 
@@ -285,6 +318,25 @@ func (p *Provider) taskFilter(task marathon.Task, application marathon.Applicati
 	return true
 }
 
+// isTaskWarmingUp returns whether the task started within the grace period and first interval of
+// the health checks of its application. Past them, a task without health check results is kept,
+// as Marathon loses the results on leader failover.
+func isTaskWarmingUp(task marathon.Task, application marathon.Application) bool {
+	startTime, err := time.Parse(time.RFC3339, task.StartedAt)
+	if err != nil {
+		return false
+	}
+
+	var warmUp time.Duration
+	for _, healthCheck := range *application.HealthChecks {
+		checkWarmUp := time.Duration(healthCheck.GracePeriodSeconds+healthCheck.IntervalSeconds) * time.Second
+		if checkWarmUp > warmUp {
+			warmUp = checkWarmUp
+		}
+	}
+	return time.Since(startTime) < warmUp
+}
+
 func isApplicationEnabled(application marathon.Application, exposedByDefault bool) bool {
 	return exposedByDefault && (*application.Labels)[types.LabelEnable] != "false" || (*application.Labels)[types.LabelEnable] == "true"
 }
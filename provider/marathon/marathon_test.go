@@ -6,6 +6,7 @@ import (
 	"math"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/containous/traefik/provider/marathon/mocks"
 	"github.com/containous/traefik/testhelpers"
@@ -452,6 +453,52 @@ func TestMarathonTaskFilter(t *testing.T) {
 			),
 			expected: true,
 		},
+		{
+			desc: "task killing",
+			task: task(
+				taskPorts(80),
+				state("TASK_KILLING"),
+			),
+			application: application(appPorts(80)),
+			expected:    false,
+		},
+		{
+			desc: "healthcheck without result during grace period",
+			task: task(
+				taskPorts(80),
+				startedAtFromNow(5*time.Second),
+			),
+			application: application(
+				appPorts(80),
+				healthChecks(marathon.NewDefaultHealthCheck()),
+			),
+			expected: false,
+		},
+		{
+			desc: "healthcheck without result past grace period",
+			task: task(
+				taskPorts(80),
+				startedAtFromNow(time.Hour),
+			),
+			application: application(
+				appPorts(80),
+				healthChecks(marathon.NewDefaultHealthCheck()),
+			),
+			expected: true,
+		},
+		{
+			desc: "healthcheck result true during grace period",
+			task: task(
+				taskPorts(80),
+				startedAtFromNow(5*time.Second),
+				healthCheckResultLiveness(true),
+			),
+			application: application(
+				appPorts(80),
+				healthChecks(marathon.NewDefaultHealthCheck()),
+			),
+			expected: true,
+		},
 		{
 			desc: "healthcheck result false",
 			task: task(
@@ -1500,3 +1547,14 @@ func TestMarathonGetBasicAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestMarathonDrainEvents(t *testing.T) {
+	update := make(marathon.EventsChannel, 5)
+	for i := 0; i < 3; i++ {
+		update <- &marathon.Event{ID: marathon.EventIDStatusUpdate}
+	}
+
+	drainEvents(update)
+
+	assert.Len(t, update, 0)
+}
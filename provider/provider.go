@@ -34,14 +34,26 @@ type BaseProvider struct {
 // MatchConstraints must match with EVERY single contraint
 // returns first constraint that do not match or nil
 func (p *BaseProvider) MatchConstraints(tags []string) (bool, *types.Constraint) {
+	return p.MatchConstraintsWithLabels(tags, nil)
+}
+
+// MatchConstraintsWithLabels must match with EVERY single contraint, the label-based ones against the labels
+// returns first constraint that do not match or nil
+func (p *BaseProvider) MatchConstraintsWithLabels(tags []string, labels map[string]string) (bool, *types.Constraint) {
 	// if there is no tags and no constraints, filtering is disabled
 	if len(tags) == 0 && len(p.Constraints) == 0 {
 		return true, nil
 	}
 
 	for _, constraint := range p.Constraints {
+		ok := false
+		if constraint.IsLabelBased() {
+			ok = constraint.MatchConstraintWithLabels(labels)
+		} else {
+			ok = constraint.MatchConstraintWithAtLeastOneTag(tags)
+		}
 		// xor: if ok and constraint.MustMatch are equal, then no tag is currently matching with the constraint
-		if ok := constraint.MatchConstraintWithAtLeastOneTag(tags); ok != constraint.MustMatch {
+		if ok != constraint.MustMatch {
 			return false, constraint
 		}
 	}
@@ -50,6 +62,9 @@ func (p *BaseProvider) MatchConstraints(tags []string) (bool, *types.Constraint)
 	return true, nil
 }
 
+// defaultTemplateName is the name of the default template of the provider in the custom templates
+const defaultTemplateName = "default"
+
 // GetConfiguration return the provider configuration using templating
 func (p *BaseProvider) GetConfiguration(defaultTemplateFile string, funcMap template.FuncMap, templateObjects interface{}) (*types.Configuration, error) {
 	var (
@@ -73,6 +88,12 @@ func (p *BaseProvider) GetConfiguration(defaultTemplateFile string, funcMap temp
 		if err != nil {
 			return nil, err
 		}
+		// the custom template can extend the default one, rendered with {{template "default" .}}
+		if defaultTemplate, err := autogen.Asset(defaultTemplateFile); err == nil {
+			if _, err = tmpl.New(defaultTemplateName).Parse(string(defaultTemplate)); err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		buf, err = autogen.Asset(defaultTemplateFile)
 		if err != nil {
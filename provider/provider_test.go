@@ -329,6 +329,87 @@ func TestMatchingConstraints(t *testing.T) {
 	}
 }
 
+func TestMatchingConstraintsWithLabels(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		constraints []string
+		tags        []string
+		labels      map[string]string
+		expected    bool
+	}{
+		{
+			desc:        "label must match",
+			constraints: []string{"label:environment==prod"},
+			labels:      map[string]string{"environment": "prod"},
+			expected:    true,
+		},
+		{
+			desc:        "label must match but does not match",
+			constraints: []string{"label:environment==prod"},
+			labels:      map[string]string{"environment": "staging"},
+			expected:    false,
+		},
+		{
+			desc:        "label must match but is missing",
+			constraints: []string{"label:environment==prod"},
+			expected:    false,
+		},
+		{
+			desc:        "label must not match with globbing",
+			constraints: []string{"label:network!=internal*"},
+			labels:      map[string]string{"network": "internal-eu"},
+			expected:    false,
+		},
+		{
+			desc:        "label must not match and is missing",
+			constraints: []string{"label:network!=internal*"},
+			expected:    true,
+		},
+		{
+			desc:        "tag and label",
+			constraints: []string{"tag==api", "label:environment==prod"},
+			tags:        []string{"api"},
+			labels:      map[string]string{"environment": "prod"},
+			expected:    true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			constraints := types.Constraints{}
+			for _, expression := range test.constraints {
+				constraint, err := types.NewConstraint(expression)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				constraints = append(constraints, constraint)
+			}
+			provider := myProvider{
+				BaseProvider{
+					Constraints: constraints,
+				},
+				nil,
+			}
+
+			actual, _ := provider.MatchConstraintsWithLabels(test.tags, test.labels)
+			if actual != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewConstraintInvalidKey(t *testing.T) {
+	for _, expression := range []string{"name==api", "label:==prod"} {
+		if _, err := types.NewConstraint(expression); err == nil {
+			t.Errorf("expected an error for %s", expression)
+		}
+	}
+}
+
 func TestDefaultFuncMap(t *testing.T) {
 	templateFile, err := ioutil.TempFile("", "provider-configuration")
 	if err != nil {
@@ -297,7 +297,7 @@ func (p *Provider) serviceFilter(service rancherData) bool {
 	}
 
 	constraintTags := strings.Split(service.Labels[types.LabelTags], ",")
-	if ok, failingConstraint := p.MatchConstraints(constraintTags); !ok {
+	if ok, failingConstraint := p.MatchConstraintsWithLabels(constraintTags, service.Labels); !ok {
 		if failingConstraint != nil {
 			log.Debugf("Filtering service %s with constraint %s", service.Name, failingConstraint.String())
 		}
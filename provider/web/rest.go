@@ -0,0 +1,141 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares"
+	"github.com/containous/traefik/types"
+)
+
+// RestProviderName is the name of the provider of the configurations pushed on the REST API
+const RestProviderName = "rest"
+
+// putRestConfigurationHandler validates the configuration pushed in the body of the request,
+// and sends it as the configuration of the rest provider.
+func (provider *Provider) putRestConfigurationHandler(configurationChan chan<- types.ConfigMessage) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		if provider.ReadOnly {
+			response.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(response, "REST API is in read-only mode")
+			return
+		}
+
+		configuration := new(types.Configuration)
+		body, _ := ioutil.ReadAll(request.Body)
+		if err := json.Unmarshal(body, configuration); err != nil {
+			log.Errorf("Error parsing configuration %+v", err)
+			http.Error(response, fmt.Sprintf("%+v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateConfiguration(configuration, provider.validateRule); err != nil {
+			log.Errorf("Invalid configuration pushed on the REST API: %v", err)
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Infof("Configuration of %d frontends and %d backends pushed on the REST API", len(configuration.Frontends), len(configuration.Backends))
+		configurationChan <- types.ConfigMessage{ProviderName: RestProviderName, Configuration: configuration}
+		templatesRenderer.JSON(response, http.StatusOK, configuration.Redacted())
+	}
+}
+
+// validateConfiguration checks that the backends and the middlewares of the frontends are defined, that the rules
+// of their routes are valid when validateRule is set, and that the servers of the backends have valid URLs,
+// the frontends and backends being checked in their name order.
+func validateConfiguration(configuration *types.Configuration, validateRule func(rule string) error) error {
+	for _, backendName := range sortedBackendNames(configuration.Backends) {
+		backend := configuration.Backends[backendName]
+		if backend == nil {
+			return fmt.Errorf("backend %s is empty", backendName)
+		}
+		for serverName, server := range backend.Servers {
+			if !validServerURL(server.URL) {
+				return fmt.Errorf("invalid URL %q of server %s of backend %s", server.URL, serverName, backendName)
+			}
+			if server.Weight < 0 {
+				return fmt.Errorf("invalid weight %d of server %s of backend %s", server.Weight, serverName, backendName)
+			}
+		}
+	}
+
+	for _, frontendName := range sortedFrontendNames(configuration.Frontends) {
+		frontend := configuration.Frontends[frontendName]
+		if frontend == nil {
+			return fmt.Errorf("frontend %s is empty", frontendName)
+		}
+		if len(frontend.Backend) == 0 && len(frontend.WeightedBackends) == 0 {
+			return fmt.Errorf("no backend defined for frontend %s", frontendName)
+		}
+		if len(frontend.Backend) > 0 && configuration.Backends[frontend.Backend] == nil && !middlewares.HasRouteVariables(frontend.Backend) {
+			return fmt.Errorf("undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
+		}
+		for backendName := range frontend.WeightedBackends {
+			if configuration.Backends[backendName] == nil {
+				return fmt.Errorf("undefined backend '%s' for frontend %s", backendName, frontendName)
+			}
+		}
+		for _, routeName := range sortedRouteNames(frontend.Routes) {
+			route := frontend.Routes[routeName]
+			if len(route.Rule) == 0 {
+				return fmt.Errorf("empty rule of route %s of frontend %s", routeName, frontendName)
+			}
+			if validateRule != nil {
+				if err := validateRule(route.Rule); err != nil {
+					return fmt.Errorf("invalid rule of route %s of frontend %s: %v", routeName, frontendName, err)
+				}
+			}
+		}
+		for _, middlewareName := range frontend.Middlewares {
+			if configuration.Middlewares[middlewareName] == nil {
+				return fmt.Errorf("undefined middleware '%s' for frontend %s", middlewareName, frontendName)
+			}
+		}
+	}
+	return nil
+}
+
+// validServerURL returns whether the URL of a server has a scheme and a host, or is the unix:///path/to/app.sock URL
+// of a Unix socket
+func validServerURL(rawURL string) bool {
+	serverURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if serverURL.Scheme == "unix" {
+		return len(serverURL.Path) > 0
+	}
+	return len(serverURL.Scheme) > 0 && len(serverURL.Host) > 0
+}
+
+func sortedBackendNames(backends map[string]*types.Backend) []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedFrontendNames(frontends map[string]*types.Frontend) []string {
+	names := make([]string, 0, len(frontends))
+	for name := range frontends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedRouteNames(routes map[string]types.Route) []string {
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
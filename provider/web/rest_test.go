@@ -0,0 +1,161 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutRestConfigurationHandler(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		readOnly           bool
+		method             string
+		body               string
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{
+			desc:               "put",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{"servers":{"server":{"url":"http://10.0.0.1:80","weight":1}}}},"frontends":{"frontend":{"backend":"backend","routes":{"route":{"rule":"Host:foo.bar"}}}}}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "post",
+			method:             http.MethodPost,
+			body:               `{"backends":{"backend":{"servers":{"server":{"url":"http://10.0.0.1:80","weight":1}}}},"frontends":{"frontend":{"backend":"backend"}}}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "backend template",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"backend":"{subdomain}","routes":{"route":{"rule":"HostRegexp:{subdomain:[a-z]+}.foo.bar"}}}}}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "read only",
+			readOnly:           true,
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"backend":"backend"}}}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "invalid body",
+			method:             http.MethodPut,
+			body:               `{`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:               "undefined backend",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"backend":"unknown"}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "undefined backend 'unknown' for frontend frontend",
+		},
+		{
+			desc:               "undefined weighted backend",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"weightedBackends":{"backend":1,"unknown":1}}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "undefined backend 'unknown' for frontend frontend",
+		},
+		{
+			desc:               "frontend without backend",
+			method:             http.MethodPut,
+			body:               `{"frontends":{"frontend":{"routes":{"route":{"rule":"Host:foo.bar"}}}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "no backend defined for frontend frontend",
+		},
+		{
+			desc:               "invalid server URL",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{"servers":{"server":{"url":"10.0.0.1"}}}},"frontends":{"frontend":{"backend":"backend"}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      `invalid URL "10.0.0.1" of server server of backend backend`,
+		},
+		{
+			desc:               "Unix socket server URL",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{"servers":{"server":{"url":"unix:///var/run/app.sock"}}}},"frontends":{"frontend":{"backend":"backend"}}}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "Unix socket server URL without path",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{"servers":{"server":{"url":"unix://"}}}},"frontends":{"frontend":{"backend":"backend"}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      `invalid URL "unix://" of server server of backend backend`,
+		},
+		{
+			desc:               "empty rule",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"backend":"backend","routes":{"route":{}}}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "empty rule of route route of frontend frontend",
+		},
+		{
+			desc:               "invalid rule",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"backend":"backend","routes":{"route":{"rule":"Hots:foo.bar"}}}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "invalid rule of route route of frontend frontend: unknown rule 'Hots'",
+		},
+		{
+			desc:               "undefined middleware",
+			method:             http.MethodPut,
+			body:               `{"backends":{"backend":{}},"frontends":{"frontend":{"backend":"backend","middlewares":["strip"]}}}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "undefined middleware 'strip' for frontend frontend",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				ReadOnly:              test.readOnly,
+				CurrentConfigurations: safe.New(types.Configurations{}),
+			}
+			provider.SetRuleValidator(func(rule string) error {
+				if strings.HasPrefix(rule, "Hots:") {
+					return errors.New("unknown rule 'Hots'")
+				}
+				return nil
+			})
+			configurationChan := make(chan types.ConfigMessage, 1)
+
+			router := mux.NewRouter()
+			router.Methods("PUT", "POST").Path("/api/providers/" + RestProviderName).HandlerFunc(provider.putRestConfigurationHandler(configurationChan))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(test.method, "/api/providers/rest", strings.NewReader(test.body))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode != http.StatusOK {
+				assert.Len(t, configurationChan, 0)
+				if len(test.expectedError) > 0 {
+					assert.Equal(t, test.expectedError, strings.TrimSpace(recorder.Body.String()))
+				}
+				return
+			}
+
+			require.Len(t, configurationChan, 1)
+			message := <-configurationChan
+			assert.Equal(t, RestProviderName, message.ProviderName)
+			assert.Contains(t, message.Configuration.Frontends, "frontend")
+			assert.Contains(t, message.Configuration.Backends, "backend")
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/types"
+)
+
+// The health statuses of the servers in the runtime configuration
+const (
+	serverStatusUp        = "up"
+	serverStatusDown      = "down"
+	serverStatusUnchecked = "unchecked"
+)
+
+// runtimeConfiguration is the configuration of a provider actually loaded by Traefik,
+// with the middlewares applied to the frontends and the health of the servers.
+type runtimeConfiguration struct {
+	Frontends map[string]*runtimeFrontend `json:"frontends,omitempty"`
+	Backends  map[string]*runtimeBackend  `json:"backends,omitempty"`
+}
+
+type runtimeFrontend struct {
+	*types.Frontend
+	AppliedMiddlewares []string `json:"appliedMiddlewares"`
+}
+
+type runtimeBackend struct {
+	*types.Backend
+	Servers map[string]runtimeServer `json:"servers,omitempty"`
+}
+
+type runtimeServer struct {
+	types.Server
+	Status string `json:"status"`
+}
+
+func (provider *Provider) getRuntimeHandler(response http.ResponseWriter, request *http.Request) {
+	currentConfigurations := provider.exposedConfigurations()
+	templatesRenderer.JSON(response, http.StatusOK, newRuntimeConfigurations(currentConfigurations, healthcheck.GetHealthCheck().Status()))
+}
+
+func newRuntimeConfigurations(configurations types.Configurations, healthStatus map[string]*healthcheck.BackendStatus) map[string]*runtimeConfiguration {
+	runtimeConfigurations := make(map[string]*runtimeConfiguration, len(configurations))
+	for providerName, config := range configurations {
+		runtimeConfig := &runtimeConfiguration{
+			Frontends: make(map[string]*runtimeFrontend, len(config.Frontends)),
+			Backends:  make(map[string]*runtimeBackend, len(config.Backends)),
+		}
+		for frontendName, frontend := range config.Frontends {
+			runtimeConfig.Frontends[frontendName] = &runtimeFrontend{Frontend: frontend, AppliedMiddlewares: appliedMiddlewares(config, frontend)}
+		}
+		for backendName, backend := range config.Backends {
+			runtimeConfig.Backends[backendName] = newRuntimeBackend(providerName, config, backendName, backend, healthStatus)
+		}
+		runtimeConfigurations[providerName] = runtimeConfig
+	}
+	return runtimeConfigurations
+}
+
+// newRuntimeBackend gives the status of the servers of the backend, according to the health checks
+// of the backend on the entrypoints of its frontends.
+func newRuntimeBackend(providerName string, config *types.Configuration, backendName string, backend *types.Backend, healthStatus map[string]*healthcheck.BackendStatus) *runtimeBackend {
+	up := make(map[string]bool)
+	down := make(map[string]bool)
+	for _, frontend := range config.Frontends {
+		if _, ok := frontend.WeightedBackends[backendName]; frontend.Backend != backendName && !ok {
+			continue
+		}
+		for _, entryPointName := range frontend.EntryPoints {
+			status, ok := healthStatus[healthcheck.BackendID(providerName, entryPointName, backendName)]
+			if !ok {
+				continue
+			}
+			for _, serverURL := range status.Up {
+				up[serverURL] = true
+			}
+			for _, serverURL := range status.Down {
+				down[serverURL] = true
+			}
+		}
+	}
+
+	runtimeBackend := &runtimeBackend{Backend: backend, Servers: make(map[string]runtimeServer, len(backend.Servers))}
+	for serverName, server := range backend.Servers {
+		status := serverStatusUnchecked
+		if down[server.URL] {
+			status = serverStatusDown
+		} else if up[server.URL] {
+			status = serverStatusUp
+		}
+		runtimeBackend.Servers[serverName] = runtimeServer{Server: server, Status: status}
+	}
+	return runtimeBackend
+}
+
+// appliedMiddlewares returns the middlewares the requests of the frontend go through, in their order
+func appliedMiddlewares(config *types.Configuration, frontend *types.Frontend) []string {
+	applied := append([]string{}, frontend.Middlewares...)
+	if len(frontend.Errors) > 0 {
+		applied = append(applied, "errors")
+	}
+	if len(frontend.WhitelistSourceRange) > 0 || len(frontend.BlacklistSourceRange) > 0 {
+		applied = append(applied, "ipFilter")
+	}
+	if frontend.Redirect != nil {
+		applied = append(applied, "redirect")
+	}
+	if len(frontend.BasicAuth) > 0 || len(frontend.BasicAuthUsersFile) > 0 {
+		applied = append(applied, "basicAuth")
+	}
+	if frontend.Auth != nil {
+		applied = append(applied, "auth")
+	}
+	if frontend.Limits != nil && (frontend.Limits.MaxRequestBodyBytes > 0 || frontend.Limits.MaxRequestHeaderBytes > 0) {
+		applied = append(applied, "limits")
+	}
+	if frontend.PassTLSCert {
+		applied = append(applied, "passTLSCert")
+	}
+	if frontend.Headers.HasCustomHeadersDefined() {
+		applied = append(applied, "headers")
+	}
+	if frontend.Headers.HasSecureHeadersDefined() {
+		applied = append(applied, "secureHeaders")
+	}
+
+	backend := config.Backends[frontend.Backend]
+	if backend != nil && backend.CircuitBreaker != nil {
+		applied = append(applied, "circuitBreaker")
+	}
+	if frontend.Cache != nil {
+		applied = append(applied, "cache")
+	}
+	if frontend.Mirror != nil {
+		applied = append(applied, "mirror")
+	}
+	if backend != nil && backend.Buffering != nil {
+		applied = append(applied, "buffering")
+	}
+	if frontend.Retry != nil {
+		applied = append(applied, "retry")
+	}
+	if backend != nil && backend.MaxConn != nil && backend.MaxConn.Amount != 0 {
+		applied = append(applied, "maxConn")
+	}
+	if frontend.RateLimit != nil && len(frontend.RateLimit.RateSet) > 0 {
+		applied = append(applied, "rateLimit")
+	}
+	return applied
+}
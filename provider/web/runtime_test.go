@@ -0,0 +1,152 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppliedMiddlewares(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		frontend *types.Frontend
+		backend  *types.Backend
+		expected []string
+	}{
+		{
+			desc:     "no middleware",
+			frontend: &types.Frontend{Backend: "backend"},
+			backend:  &types.Backend{},
+			expected: []string{},
+		},
+		{
+			desc: "named middlewares first",
+			frontend: &types.Frontend{
+				Backend:     "backend",
+				Middlewares: []string{"strip", "compress"},
+				BasicAuth:   []string{"test:test"},
+			},
+			backend:  &types.Backend{},
+			expected: []string{"strip", "compress", "basicAuth"},
+		},
+		{
+			desc: "frontend and backend middlewares",
+			frontend: &types.Frontend{
+				Backend:              "backend",
+				WhitelistSourceRange: []string{"10.0.0.0/8"},
+				Headers:              types.Headers{CustomRequestHeaders: map[string]string{"X-Foo": "bar"}, SSLRedirect: true},
+				RateLimit:            &types.RateLimit{RateSet: map[string]*types.Rate{"rate": {Average: 10}}},
+				Retry:                &types.Retry{},
+			},
+			backend: &types.Backend{
+				CircuitBreaker: &types.CircuitBreaker{Expression: "NetworkErrorRatio() > 0.5"},
+				MaxConn:        &types.MaxConn{Amount: 10},
+			},
+			expected: []string{"ipFilter", "headers", "secureHeaders", "circuitBreaker", "retry", "maxConn", "rateLimit"},
+		},
+		{
+			desc: "undefined backend",
+			frontend: &types.Frontend{
+				Backend:  "unknown",
+				Redirect: &types.Redirect{Regex: "^http://(.*)", Replacement: "https://$1"},
+			},
+			backend:  &types.Backend{CircuitBreaker: &types.CircuitBreaker{Expression: "NetworkErrorRatio() > 0.5"}},
+			expected: []string{"redirect"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			config := &types.Configuration{
+				Backends:  map[string]*types.Backend{"backend": test.backend},
+				Frontends: map[string]*types.Frontend{"frontend": test.frontend},
+			}
+			assert.Equal(t, test.expected, appliedMiddlewares(config, test.frontend))
+		})
+	}
+}
+
+func TestNewRuntimeBackend(t *testing.T) {
+	config := &types.Configuration{
+		Backends: map[string]*types.Backend{
+			"backend": {
+				Servers: map[string]types.Server{
+					"server1": {URL: "http://10.0.0.1:80", Weight: 1},
+					"server2": {URL: "http://10.0.0.2:80", Weight: 1},
+				},
+			},
+			"other": {
+				Servers: map[string]types.Server{
+					"server1": {URL: "http://10.0.0.3:80", Weight: 1},
+				},
+			},
+		},
+		Frontends: map[string]*types.Frontend{
+			"frontend": {Backend: "backend", EntryPoints: []string{"http"}},
+			"unused":   {Backend: "other", EntryPoints: []string{"https"}},
+		},
+	}
+	healthStatus := map[string]*healthcheck.BackendStatus{
+		"file/http/backend": {Up: []string{"http://10.0.0.1:80"}, Down: []string{"http://10.0.0.2:80"}},
+		"file/http/other":   {Up: []string{"http://10.0.0.3:80"}},
+	}
+
+	runtimeConfigurations := newRuntimeConfigurations(types.Configurations{"file": config}, healthStatus)
+
+	backends := runtimeConfigurations["file"].Backends
+	assert.Equal(t, serverStatusUp, backends["backend"].Servers["server1"].Status)
+	assert.Equal(t, serverStatusDown, backends["backend"].Servers["server2"].Status)
+	// the health check of the backend on another entrypoint is ignored
+	assert.Equal(t, serverStatusUnchecked, backends["other"].Servers["server1"].Status)
+}
+
+func TestGetRuntimeHandler(t *testing.T) {
+	config := &types.Configuration{
+		Backends: map[string]*types.Backend{
+			"backend": {
+				Servers: map[string]types.Server{
+					"server1": {URL: "http://10.0.0.1:80", Weight: 1},
+				},
+			},
+		},
+		Frontends: map[string]*types.Frontend{
+			"frontend": {Backend: "backend", EntryPoints: []string{"http"}, Auth: &types.Auth{}},
+		},
+	}
+	provider := &Provider{CurrentConfigurations: safe.New(types.Configurations{"file": config})}
+
+	recorder := httptest.NewRecorder()
+	provider.getRuntimeHandler(recorder, httptest.NewRequest(http.MethodGet, "/api/runtime", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"file": {
+			"frontends": {
+				"frontend": {
+					"entryPoints": ["http"],
+					"backend": "backend",
+					"priority": 0,
+					"basicAuth": null,
+					"headers": {},
+					"auth": {},
+					"appliedMiddlewares": ["auth"]
+				}
+			},
+			"backends": {
+				"backend": {
+					"servers": {
+						"server1": {"url": "http://10.0.0.1:80", "weight": 1, "status": "unchecked"}
+					}
+				}
+			}
+		}
+	}`, recorder.Body.String())
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/unrolled/render"
+)
+
+// notFoundTemplate is the name of the template of the page answering the unknown paths
+const notFoundTemplate = "404"
+
+// notFoundData is the data of the template of the not found page
+type notFoundData struct {
+	Path string
+}
+
+// newTemplatesRenderer creates a renderer of the *.tmpl templates of the directory
+func newTemplatesRenderer(directory string) (renderer *render.Render, err error) {
+	info, err := os.Stat(directory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid templates directory: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid templates directory: %s is not a directory", directory)
+	}
+
+	// the renderer panics on the templates it fails to parse
+	defer func() {
+		if r := recover(); r != nil {
+			renderer = nil
+			err = fmt.Errorf("error parsing the templates of %s: %v", directory, r)
+		}
+	}()
+	return render.New(render.Options{
+		Directory: directory,
+	}), nil
+}
+
+// templatesFileSystem is a http.FileSystem serving the files of the directory,
+// and the bundled assets for the files missing from it
+type templatesFileSystem struct {
+	directory http.FileSystem
+	assets    http.FileSystem
+}
+
+func (fs templatesFileSystem) Open(name string) (http.File, error) {
+	file, err := fs.directory.Open(name)
+	if os.IsNotExist(err) {
+		return fs.assets.Open(name)
+	}
+	return file, err
+}
+
+func notFoundHandler(response http.ResponseWriter, request *http.Request) {
+	if templatesRenderer.TemplateLookup(notFoundTemplate) == nil {
+		http.NotFound(response, request)
+		return
+	}
+	templatesRenderer.HTML(response, http.StatusNotFound, notFoundTemplate, notFoundData{Path: request.URL.Path})
+}
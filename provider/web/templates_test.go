@@ -0,0 +1,94 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardTemplatesDir(t *testing.T) {
+	defaultRenderer := templatesRenderer
+	defer func() { templatesRenderer = defaultRenderer }()
+
+	templatesDir, err := ioutil.TempDir("", "traefik-templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(templatesDir)
+
+	require.NoError(t, os.Mkdir(filepath.Join(templatesDir, "dashboard"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templatesDir, "dashboard", "index.html"), []byte("<h1>ACME</h1>"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templatesDir, "404.tmpl"), []byte("<h1>ACME: {{.Path}} not found</h1>"), 0644))
+
+	provider := &Provider{Path: "/", TemplatesDir: templatesDir}
+	router := mux.NewRouter()
+	require.NoError(t, provider.addDashboardRoutes(router))
+
+	testCases := []struct {
+		desc               string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			desc:               "overridden dashboard file",
+			url:                "/dashboard/",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "<h1>ACME</h1>",
+		},
+		{
+			desc:               "not found page",
+			url:                "/unknown",
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       "<h1>ACME: /unknown not found</h1>",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.url, nil))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestNewTemplatesRendererInvalid(t *testing.T) {
+	templatesDir, err := ioutil.TempDir("", "traefik-templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(templatesDir)
+
+	invalidTemplatesDir := filepath.Join(templatesDir, "invalid")
+	require.NoError(t, os.Mkdir(invalidTemplatesDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(invalidTemplatesDir, "404.tmpl"), []byte("{{.Path"), 0644))
+
+	testCases := []struct {
+		desc      string
+		directory string
+	}{
+		{
+			desc:      "missing directory",
+			directory: filepath.Join(templatesDir, "missing"),
+		},
+		{
+			desc:      "invalid template",
+			directory: invalidTemplatesDir,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := newTemplatesRenderer(test.directory)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -6,10 +6,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/pprof"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/containous/mux"
 	"github.com/containous/traefik/autogen"
+	"github.com/containous/traefik/healthcheck"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/middlewares"
 	mauth "github.com/containous/traefik/middlewares/auth"
@@ -23,6 +31,12 @@ import (
 	"github.com/urfave/negroni"
 )
 
+// DefaultStatisticsStreamInterval is the default interval between the statistics sent by the statistics stream
+const DefaultStatisticsStreamInterval = time.Second
+
+// minStatisticsStreamInterval is the shortest interval between the statistics sent by the statistics stream
+const minStatisticsStreamInterval = 100 * time.Millisecond
+
 // Provider is a provider.Provider implementation that provides the UI
 type Provider struct {
 	Address               string            `description:"Web administration port" export:"true"`
@@ -33,10 +47,29 @@ type Provider struct {
 	Metrics               *types.Metrics    `description:"Enable a metrics exporter" export:"true"`
 	Path                  string            `description:"Root path for dashboard and API"`
 	Auth                  *types.Auth       `export:"true"`
-	Debug                 bool              `export:"true"`
+	Debug                 bool              `description:"Enable the pprof and expvar debug endpoints" export:"true"`
+	TemplatesDir          string            `description:"Directory of the templates and dashboard files overriding the bundled ones" export:"true"`
+	EntryPoint            string            `description:"Entrypoint serving the API and the dashboard instead of the web address" export:"true"`
 	CurrentConfigurations *safe.Safe
+	EntryPoints           map[string]*EntryPoint
 	Stats                 *thoas_stats.Stats
 	StatsRecorder         *middlewares.StatsRecorder
+	StatisticsRegistry    *middlewares.StatisticsRegistry
+	Caches                *middlewares.CacheRegistry
+	handler               atomic.Value
+	configurationMutex    sync.Mutex
+	sentConfiguration     *types.Configuration // last configuration sent, which may not be loaded yet
+	validateRule          func(rule string) error
+}
+
+// EntryPoint is the summary of an entrypoint given by the API
+type EntryPoint struct {
+	Address  string `json:"address"`
+	Protocol string `json:"protocol"`
+	TLS      bool   `json:"tls"`
+	Redirect string `json:"redirect,omitempty"`
+	Auth     bool   `json:"auth"`
+	Compress bool   `json:"compress"`
 }
 
 var (
@@ -85,8 +118,14 @@ func (provider *Provider) Provide(configurationChan chan<- types.ConfigMessage,
 	// API routes
 	systemRouter.Methods("GET").Path(provider.Path + "api").HandlerFunc(provider.getConfigHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/version").HandlerFunc(provider.getVersionHandler)
+	systemRouter.Methods("GET", "PUT").Path(provider.Path + "api/loglevel").HandlerFunc(provider.logLevelHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/runtime").HandlerFunc(provider.getRuntimeHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/entrypoints").HandlerFunc(provider.getEntryPointsHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/statistics").HandlerFunc(provider.getStatisticsHandler)
+	systemRouter.Methods("GET").Path(provider.Path + "api/statistics/stream").HandlerFunc(provider.getStatisticsStreamHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers").HandlerFunc(provider.getConfigHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}").HandlerFunc(provider.getProviderHandler)
+	systemRouter.Methods("PUT", "POST").Path(provider.Path + "api/providers/" + RestProviderName).HandlerFunc(provider.putRestConfigurationHandler(configurationChan))
 	systemRouter.Methods("PUT").Path(provider.Path + "api/providers/{provider}").HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
 		if provider.ReadOnly {
 			response.WriteHeader(http.StatusForbidden)
@@ -104,7 +143,9 @@ func (provider *Provider) Provide(configurationChan chan<- types.ConfigMessage,
 		body, _ := ioutil.ReadAll(request.Body)
 		err := json.Unmarshal(body, configuration)
 		if err == nil {
-			configurationChan <- types.ConfigMessage{ProviderName: "web", Configuration: configuration}
+			provider.configurationMutex.Lock()
+			provider.sendConfiguration(configurationChan, configuration)
+			provider.configurationMutex.Unlock()
 			provider.getConfigHandler(response, request)
 		} else {
 			log.Errorf("Error parsing configuration %+v", err)
@@ -117,40 +158,51 @@ func (provider *Provider) Provide(configurationChan chan<- types.ConfigMessage,
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/backends/{backend}/servers/{server}").HandlerFunc(provider.getServerHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends").HandlerFunc(provider.getFrontendsHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}").HandlerFunc(provider.getFrontendHandler)
+	systemRouter.Methods("PUT").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/backend").HandlerFunc(provider.switchFrontendBackendHandler(configurationChan))
+	systemRouter.Methods("PUT", "DELETE").Path(provider.Path + "api/providers/{provider}/backends/{backend}/servers/{server}/weight").HandlerFunc(provider.serverWeightHandler)
+	systemRouter.Methods("PUT", "DELETE").Path(provider.Path + "api/providers/{provider}/backends/{backend}/servers/{server}/disabled").HandlerFunc(provider.serverDisabledHandler)
+	systemRouter.Methods("PUT", "DELETE").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/maintenance").HandlerFunc(provider.maintenanceHandler(configurationChan))
+	systemRouter.Methods("DELETE").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/cache").HandlerFunc(provider.purgeFrontendCacheHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/routes").HandlerFunc(provider.getRoutesHandler)
 	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/routes/{route}").HandlerFunc(provider.getRouteHandler)
 
 	// Expose dashboard
-	systemRouter.Methods("GET").Path(provider.Path).HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
-		http.Redirect(response, request, provider.Path+"dashboard/", 302)
-	})
-	systemRouter.Methods("GET").PathPrefix(provider.Path + "dashboard/").
-		Handler(http.StripPrefix(provider.Path+"dashboard/", http.FileServer(&assetfs.AssetFS{Asset: autogen.Asset, AssetInfo: autogen.AssetInfo, AssetDir: autogen.AssetDir, Prefix: "static"})))
+	if err := provider.addDashboardRoutes(systemRouter); err != nil {
+		return err
+	}
 
-	// expvars
+	// expvars and pprof
 	if provider.Debug {
 		systemRouter.Methods("GET").Path(provider.Path + "debug/vars").HandlerFunc(expVarHandler)
+		addPprofRoutes(systemRouter, provider.Path)
 	}
 
-	safe.Go(func() {
-		var err error
-		var negroniInstance = negroni.New()
-		if provider.Auth != nil {
-			authMiddleware, err := mauth.NewAuthenticator(provider.Auth)
-			if err != nil {
-				log.Fatal("Error creating Auth: ", err)
-			}
-			authMiddlewareWrapper := negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-				if r.URL.Path == "/ping" {
-					next.ServeHTTP(w, r)
-				} else {
-					authMiddleware.ServeHTTP(w, r, next)
-				}
-			})
-			negroniInstance.Use(authMiddlewareWrapper)
+	var negroniInstance = negroni.New()
+	if provider.Auth != nil {
+		authMiddleware, err := mauth.NewAuthenticator(provider.Auth)
+		if err != nil {
+			log.Fatal("Error creating Auth: ", err)
 		}
-		negroniInstance.UseHandler(systemRouter)
+		authMiddlewareWrapper := negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			if r.URL.Path == "/ping" {
+				next.ServeHTTP(w, r)
+			} else {
+				authMiddleware.ServeHTTP(w, r, next)
+			}
+		})
+		negroniInstance.Use(authMiddlewareWrapper)
+	}
+	negroniInstance.UseHandler(systemRouter)
 
+	// served by the entrypoint, with its address, TLS, authentication and whitelist
+	if len(provider.EntryPoint) > 0 {
+		log.Infof("Serving the API and the dashboard on entrypoint %s", provider.EntryPoint)
+		provider.handler.Store(negroniInstance)
+		return nil
+	}
+
+	safe.Go(func() {
+		var err error
 		if len(provider.CertFile) > 0 && len(provider.KeyFile) > 0 {
 			err = http.ListenAndServeTLS(provider.Address, provider.CertFile, provider.KeyFile, negroniInstance)
 		} else {
@@ -169,25 +221,98 @@ func (provider *Provider) Provide(configurationChan chan<- types.ConfigMessage,
 type healthResponse struct {
 	*thoas_stats.Data
 	*middlewares.Stats
+	HealthChecks map[string]*healthcheck.BackendStatus `json:"health_checks,omitempty"`
+}
+
+// ServeHTTP serves the API and the dashboard on the entrypoint of the provider, once the provider is started
+func (provider *Provider) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	handler, ok := provider.handler.Load().(http.Handler)
+	if !ok {
+		http.Error(response, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	handler.ServeHTTP(response, request)
 }
 
 func (provider *Provider) getHealthHandler(response http.ResponseWriter, request *http.Request) {
-	health := &healthResponse{Data: provider.Stats.Data()}
+	health := &healthResponse{Data: provider.Stats.Data(), HealthChecks: healthcheck.GetHealthCheck().Status()}
 	if provider.StatsRecorder != nil {
 		health.Stats = provider.StatsRecorder.Data()
 	}
 	templatesRenderer.JSON(response, http.StatusOK, health)
 }
 
+func (provider *Provider) getStatisticsHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.StatisticsRegistry == nil {
+		http.NotFound(response, request)
+		return
+	}
+	templatesRenderer.JSON(response, http.StatusOK, provider.StatisticsRegistry.Data())
+}
+
+// getStatisticsStreamHandler streams the statistics as server-sent events, at the interval of the interval parameter,
+// until the client goes away
+func (provider *Provider) getStatisticsStreamHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.StatisticsRegistry == nil {
+		http.NotFound(response, request)
+		return
+	}
+	interval := DefaultStatisticsStreamInterval
+	if rawInterval := request.URL.Query().Get("interval"); len(rawInterval) > 0 {
+		var err error
+		interval, err = time.ParseDuration(rawInterval)
+		if err != nil || interval < minStatisticsStreamInterval {
+			http.Error(response, fmt.Sprintf("Invalid interval %q, must be a duration of at least %s", rawInterval, minStatisticsStreamInterval), http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		http.Error(response, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		data, err := json.Marshal(provider.StatisticsRegistry.Data())
+		if err != nil {
+			log.Errorf("Error encoding the statistics: %v", err)
+			return
+		}
+		if _, err := fmt.Fprintf(response, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (provider *Provider) getPingHandler(response http.ResponseWriter, request *http.Request) {
 	fmt.Fprint(response, "OK")
 }
 
 func (provider *Provider) getConfigHandler(response http.ResponseWriter, request *http.Request) {
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	templatesRenderer.JSON(response, http.StatusOK, currentConfigurations)
 }
 
+func (provider *Provider) getEntryPointsHandler(response http.ResponseWriter, request *http.Request) {
+	entryPoints := provider.EntryPoints
+	if entryPoints == nil {
+		entryPoints = map[string]*EntryPoint{}
+	}
+	templatesRenderer.JSON(response, http.StatusOK, entryPoints)
+}
+
 func (provider *Provider) getVersionHandler(response http.ResponseWriter, request *http.Request) {
 	v := struct {
 		Version  string
@@ -199,10 +324,37 @@ func (provider *Provider) getVersionHandler(response http.ResponseWriter, reques
 	templatesRenderer.JSON(response, http.StatusOK, v)
 }
 
+// logLevelHandler answers with the level of the Traefik logs, and sets it on PUT
+func (provider *Provider) logLevelHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method == http.MethodPut {
+		if provider.ReadOnly {
+			response.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(response, "REST API is in read-only mode")
+			return
+		}
+		var levelRequest struct {
+			Level string `json:"level"`
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		if err := json.Unmarshal(body, &levelRequest); err != nil {
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level, err := logrus.ParseLevel(strings.ToLower(levelRequest.Level))
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Infof("Setting the log level to %s", level)
+		log.SetLevel(level)
+	}
+	templatesRenderer.JSON(response, http.StatusOK, map[string]string{"level": log.GetLevel().String()})
+}
+
 func (provider *Provider) getProviderHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		templatesRenderer.JSON(response, http.StatusOK, provider)
 	} else {
@@ -213,7 +365,7 @@ func (provider *Provider) getProviderHandler(response http.ResponseWriter, reque
 func (provider *Provider) getBackendsHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		templatesRenderer.JSON(response, http.StatusOK, provider.Backends)
 	} else {
@@ -225,7 +377,7 @@ func (provider *Provider) getBackendHandler(response http.ResponseWriter, reques
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
 	backendID := vars["backend"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if backend, ok := provider.Backends[backendID]; ok {
 			templatesRenderer.JSON(response, http.StatusOK, backend)
@@ -239,7 +391,7 @@ func (provider *Provider) getServersHandler(response http.ResponseWriter, reques
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
 	backendID := vars["backend"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if backend, ok := provider.Backends[backendID]; ok {
 			templatesRenderer.JSON(response, http.StatusOK, backend.Servers)
@@ -254,7 +406,7 @@ func (provider *Provider) getServerHandler(response http.ResponseWriter, request
 	providerID := vars["provider"]
 	backendID := vars["backend"]
 	serverID := vars["server"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if backend, ok := provider.Backends[backendID]; ok {
 			if server, ok := backend.Servers[serverID]; ok {
@@ -266,10 +418,78 @@ func (provider *Provider) getServerHandler(response http.ResponseWriter, request
 	http.NotFound(response, request)
 }
 
+// serverWeightHandler sets the weight of a server at runtime, 0 draining it, or restores its configured weight on DELETE
+func (provider *Provider) serverWeightHandler(response http.ResponseWriter, request *http.Request) {
+	provider.updateServer(response, request, func(providerName, backendName, serverName string) (*healthcheck.ServerState, error) {
+		if request.Method == http.MethodDelete {
+			log.Infof("Restoring the configured weight of server %s of backend %s", serverName, backendName)
+			return healthcheck.GetServersAdmin().SetWeight(providerName, backendName, serverName, nil), nil
+		}
+		var weightRequest struct {
+			Weight *int `json:"weight"`
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		if err := json.Unmarshal(body, &weightRequest); err != nil {
+			return nil, err
+		}
+		if weightRequest.Weight == nil || *weightRequest.Weight < 0 {
+			return nil, fmt.Errorf("Invalid weight of server %s, must be a positive or zero integer", serverName)
+		}
+		log.Infof("Setting the weight of server %s of backend %s to %d", serverName, backendName, *weightRequest.Weight)
+		return healthcheck.GetServersAdmin().SetWeight(providerName, backendName, serverName, weightRequest.Weight), nil
+	})
+}
+
+// serverDisabledHandler disables a server at runtime, or enables it again on DELETE
+func (provider *Provider) serverDisabledHandler(response http.ResponseWriter, request *http.Request) {
+	provider.updateServer(response, request, func(providerName, backendName, serverName string) (*healthcheck.ServerState, error) {
+		disabled := request.Method != http.MethodDelete
+		if disabled {
+			log.Infof("Disabling server %s of backend %s", serverName, backendName)
+		} else {
+			log.Infof("Enabling server %s of backend %s", serverName, backendName)
+		}
+		return healthcheck.GetServersAdmin().SetDisabled(providerName, backendName, serverName, disabled), nil
+	})
+}
+
+// updateServer updates the runtime state of a server of the current configuration, and answers with the state
+func (provider *Provider) updateServer(response http.ResponseWriter, request *http.Request,
+	update func(providerName, backendName, serverName string) (*healthcheck.ServerState, error)) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+	vars := mux.Vars(request)
+	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	current, ok := currentConfigurations[vars["provider"]]
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	backend, ok := current.Backends[vars["backend"]]
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	if _, ok := backend.Servers[vars["server"]]; !ok {
+		http.NotFound(response, request)
+		return
+	}
+
+	state, err := update(vars["provider"], vars["backend"], vars["server"])
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	templatesRenderer.JSON(response, http.StatusOK, state)
+}
+
 func (provider *Provider) getFrontendsHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		templatesRenderer.JSON(response, http.StatusOK, provider.Frontends)
 	} else {
@@ -281,7 +501,7 @@ func (provider *Provider) getFrontendHandler(response http.ResponseWriter, reque
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
 	frontendID := vars["frontend"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if frontend, ok := provider.Frontends[frontendID]; ok {
 			templatesRenderer.JSON(response, http.StatusOK, frontend)
@@ -291,11 +511,162 @@ func (provider *Provider) getFrontendHandler(response http.ResponseWriter, reque
 	http.NotFound(response, request)
 }
 
+// switchFrontendBackendHandler repoints a frontend of the web provider to another backend in a single configuration update.
+// The requests in flight on the previous backend are completed by its handlers.
+func (provider *Provider) switchFrontendBackendHandler(configurationChan chan<- types.ConfigMessage) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		var switchRequest struct {
+			Backend string `json:"backend"`
+		}
+		provider.updateFrontend(configurationChan, response, request, &switchRequest, func(current *types.Configuration, frontendName string, frontend *types.Frontend) error {
+			if _, ok := current.Backends[switchRequest.Backend]; !ok {
+				return fmt.Errorf("Undefined backend '%s'", switchRequest.Backend)
+			}
+			log.Infof("Switching frontend %s from backend %s to backend %s", frontendName, frontend.Backend, switchRequest.Backend)
+			frontend.Backend = switchRequest.Backend
+			frontend.WeightedBackends = nil
+			return nil
+		})
+	}
+}
+
+func (provider *Provider) maintenanceHandler(configurationChan chan<- types.ConfigMessage) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		maintenance := &types.Maintenance{}
+		provider.updateFrontend(configurationChan, response, request, maintenance, func(current *types.Configuration, frontendName string, frontend *types.Frontend) error {
+			if request.Method == http.MethodDelete {
+				log.Infof("Ending the maintenance of frontend %s", frontendName)
+				frontend.Maintenance = nil
+				return nil
+			}
+			if maintenance.StatusCode != 0 && (maintenance.StatusCode < 100 || maintenance.StatusCode > 599) {
+				return fmt.Errorf("Invalid maintenance status code %d", maintenance.StatusCode)
+			}
+			log.Infof("Starting the maintenance of frontend %s", frontendName)
+			frontend.Maintenance = maintenance
+			return nil
+		})
+	}
+}
+
+// updateFrontend sends the configuration of the web provider with a copy of the frontend of the request updated,
+// with the JSON body of the request, if any, decoded in updateRequest; it answers with the updated frontend
+func (provider *Provider) updateFrontend(configurationChan chan<- types.ConfigMessage, response http.ResponseWriter, request *http.Request,
+	updateRequest interface{}, update func(current *types.Configuration, frontendName string, frontend *types.Frontend) error) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+	vars := mux.Vars(request)
+	if vars["provider"] != "web" {
+		response.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(response, "Only 'web' provider can be updated through the REST API")
+		return
+	}
+
+	if body, _ := ioutil.ReadAll(request.Body); len(body) > 0 {
+		if err := json.Unmarshal(body, updateRequest); err != nil {
+			log.Errorf("Error parsing frontend update %+v", err)
+			http.Error(response, fmt.Sprintf("%+v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	provider.configurationMutex.Lock()
+	defer provider.configurationMutex.Unlock()
+
+	current := provider.latestConfiguration()
+	if current == nil {
+		http.NotFound(response, request)
+		return
+	}
+	frontend, ok := current.Frontends[vars["frontend"]]
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+
+	updatedFrontend := *frontend
+	if err := update(current, vars["frontend"], &updatedFrontend); err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	configuration := *current
+	configuration.Frontends = make(map[string]*types.Frontend, len(current.Frontends))
+	for frontendName, currentFrontend := range current.Frontends {
+		configuration.Frontends[frontendName] = currentFrontend
+	}
+	configuration.Frontends[vars["frontend"]] = &updatedFrontend
+
+	provider.sendConfiguration(configurationChan, &configuration)
+	templatesRenderer.JSON(response, http.StatusOK, configuration.Redacted().Frontends[vars["frontend"]])
+}
+
+// SetRuleValidator sets the function checking the rules of the routes of the configurations pushed on the REST API,
+// for them to be parsed as the server does before being accepted
+func (provider *Provider) SetRuleValidator(validateRule func(rule string) error) {
+	provider.validateRule = validateRule
+}
+
+// exposedConfigurations returns the current configurations without their secrets, for the API to expose them
+func (provider *Provider) exposedConfigurations() types.Configurations {
+	return provider.CurrentConfigurations.Get().(types.Configurations).Redacted()
+}
+
+// latestConfiguration returns the configuration of the web provider the updates apply to: the last one sent,
+// the configurations being loaded after a while, for the updates sent in a row not to override each other,
+// or the current one. It is called with the configuration lock held.
+func (provider *Provider) latestConfiguration() *types.Configuration {
+	if provider.sentConfiguration != nil {
+		return provider.sentConfiguration
+	}
+	return provider.CurrentConfigurations.Get().(types.Configurations)["web"]
+}
+
+// sendConfiguration sends the configuration of the web provider, with the configuration lock held
+func (provider *Provider) sendConfiguration(configurationChan chan<- types.ConfigMessage, configuration *types.Configuration) {
+	provider.sentConfiguration = configuration
+	configurationChan <- types.ConfigMessage{ProviderName: "web", Configuration: configuration}
+}
+
+func (provider *Provider) purgeFrontendCacheHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+	vars := mux.Vars(request)
+	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	current, ok := currentConfigurations[vars["provider"]]
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	if _, ok := current.Frontends[vars["frontend"]]; !ok {
+		http.NotFound(response, request)
+		return
+	}
+	if provider.Caches == nil {
+		http.NotFound(response, request)
+		return
+	}
+	purged, ok := provider.Caches.Purge(vars["provider"], vars["frontend"], request.URL.Query().Get("path"))
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+
+	log.Infof("Purged %d cached responses of frontend %s", purged, vars["frontend"])
+	templatesRenderer.JSON(response, http.StatusOK, map[string]int{"purged": purged})
+}
+
 func (provider *Provider) getRoutesHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
 	frontendID := vars["frontend"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if frontend, ok := provider.Frontends[frontendID]; ok {
 			templatesRenderer.JSON(response, http.StatusOK, frontend.Routes)
@@ -311,7 +682,7 @@ func (provider *Provider) getRouteHandler(response http.ResponseWriter, request
 	providerID := vars["provider"]
 	frontendID := vars["frontend"]
 	routeID := vars["route"]
-	currentConfigurations := provider.CurrentConfigurations.Get().(types.Configurations)
+	currentConfigurations := provider.exposedConfigurations()
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if frontend, ok := provider.Frontends[frontendID]; ok {
 			if route, ok := frontend.Routes[routeID]; ok {
@@ -323,6 +694,42 @@ func (provider *Provider) getRouteHandler(response http.ResponseWriter, request
 	http.NotFound(response, request)
 }
 
+// addDashboardRoutes exposes the dashboard, with the files and templates of the templates directory if any
+// in place of the bundled ones
+func (provider *Provider) addDashboardRoutes(router *mux.Router) error {
+	var dashboardFileSystem http.FileSystem = &assetfs.AssetFS{Asset: autogen.Asset, AssetInfo: autogen.AssetInfo, AssetDir: autogen.AssetDir, Prefix: "static"}
+	if len(provider.TemplatesDir) > 0 {
+		renderer, err := newTemplatesRenderer(provider.TemplatesDir)
+		if err != nil {
+			return err
+		}
+		templatesRenderer = renderer
+		dashboardFileSystem = templatesFileSystem{
+			directory: http.Dir(filepath.Join(provider.TemplatesDir, "dashboard")),
+			assets:    dashboardFileSystem,
+		}
+		router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	}
+
+	router.Methods("GET").Path(provider.Path).HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		http.Redirect(response, request, provider.Path+"dashboard/", 302)
+	})
+	router.Methods("GET").PathPrefix(provider.Path + "dashboard/").
+		Handler(http.StripPrefix(provider.Path+"dashboard/", http.FileServer(dashboardFileSystem)))
+	return nil
+}
+
+// addPprofRoutes serves the profiles of net/http/pprof under the debug/pprof/ path
+func addPprofRoutes(router *mux.Router, path string) {
+	// the pprof handlers expect the /debug/pprof/ path
+	stripPrefix := strings.TrimSuffix(path, "/")
+	router.Methods("GET").Path(path + "debug/pprof/cmdline").Handler(http.StripPrefix(stripPrefix, http.HandlerFunc(pprof.Cmdline)))
+	router.Methods("GET").Path(path + "debug/pprof/profile").Handler(http.StripPrefix(stripPrefix, http.HandlerFunc(pprof.Profile)))
+	router.Methods("GET", "POST").Path(path + "debug/pprof/symbol").Handler(http.StripPrefix(stripPrefix, http.HandlerFunc(pprof.Symbol)))
+	router.Methods("GET").Path(path + "debug/pprof/trace").Handler(http.StripPrefix(stripPrefix, http.HandlerFunc(pprof.Trace)))
+	router.Methods("GET").PathPrefix(path + "debug/pprof/").Handler(http.StripPrefix(stripPrefix, http.HandlerFunc(pprof.Index)))
+}
+
 func expVarHandler(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	fmt.Fprint(w, "{\n")
@@ -0,0 +1,643 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/containous/mux"
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwitchFrontendBackendHandler(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		readOnly           bool
+		provider           string
+		frontend           string
+		body               string
+		expectedStatusCode int
+		expectedBackend    string
+	}{
+		{
+			desc:               "switch to green",
+			provider:           "web",
+			frontend:           "frontend",
+			body:               `{"backend":"green"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedBackend:    "green",
+		},
+		{
+			desc:               "read only",
+			readOnly:           true,
+			provider:           "web",
+			frontend:           "frontend",
+			body:               `{"backend":"green"}`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "other provider",
+			provider:           "file",
+			frontend:           "frontend",
+			body:               `{"backend":"green"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:               "undefined frontend",
+			provider:           "web",
+			frontend:           "unknown",
+			body:               `{"backend":"green"}`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			desc:               "undefined backend",
+			provider:           "web",
+			frontend:           "frontend",
+			body:               `{"backend":"unknown"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:               "invalid body",
+			provider:           "web",
+			frontend:           "frontend",
+			body:               `{`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			current := &types.Configuration{
+				Backends: map[string]*types.Backend{
+					"blue":  {},
+					"green": {},
+				},
+				Frontends: map[string]*types.Frontend{
+					"frontend": {Backend: "blue", WeightedBackends: map[string]int{"blue": 1}},
+					"other":    {Backend: "blue"},
+				},
+			}
+			provider := &Provider{
+				ReadOnly:              test.readOnly,
+				CurrentConfigurations: safe.New(types.Configurations{"web": current}),
+			}
+			configurationChan := make(chan types.ConfigMessage, 1)
+
+			router := mux.NewRouter()
+			router.Methods("PUT").Path("/api/providers/{provider}/frontends/{frontend}/backend").HandlerFunc(provider.switchFrontendBackendHandler(configurationChan))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPut, "/api/providers/"+test.provider+"/frontends/"+test.frontend+"/backend", strings.NewReader(test.body))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode != http.StatusOK {
+				assert.Len(t, configurationChan, 0)
+				return
+			}
+
+			require.Len(t, configurationChan, 1)
+			message := <-configurationChan
+			assert.Equal(t, "web", message.ProviderName)
+			assert.Equal(t, test.expectedBackend, message.Configuration.Frontends["frontend"].Backend)
+			assert.Nil(t, message.Configuration.Frontends["frontend"].WeightedBackends)
+			assert.Equal(t, "blue", message.Configuration.Frontends["other"].Backend)
+
+			// the current configuration is left untouched
+			assert.Equal(t, "blue", current.Frontends["frontend"].Backend)
+		})
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		method              string
+		frontend            string
+		body                string
+		expectedStatusCode  int
+		expectedMaintenance *types.Maintenance
+	}{
+		{
+			desc:                "start with the default response",
+			method:              http.MethodPut,
+			frontend:            "frontend",
+			expectedStatusCode:  http.StatusOK,
+			expectedMaintenance: &types.Maintenance{},
+		},
+		{
+			desc:                "start with a custom response",
+			method:              http.MethodPut,
+			frontend:            "frontend",
+			body:                `{"body":"Back soon","retryAfter":"600"}`,
+			expectedStatusCode:  http.StatusOK,
+			expectedMaintenance: &types.Maintenance{Body: "Back soon", RetryAfter: "600"},
+		},
+		{
+			desc:               "end",
+			method:             http.MethodDelete,
+			frontend:           "maintained",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "invalid status code",
+			method:             http.MethodPut,
+			frontend:           "frontend",
+			body:               `{"statusCode":1000}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:               "undefined frontend",
+			method:             http.MethodPut,
+			frontend:           "unknown",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			current := &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					"frontend":   {Backend: "backend"},
+					"maintained": {Backend: "backend", Maintenance: &types.Maintenance{}},
+				},
+			}
+			provider := &Provider{CurrentConfigurations: safe.New(types.Configurations{"web": current})}
+			configurationChan := make(chan types.ConfigMessage, 1)
+
+			router := mux.NewRouter()
+			router.Methods("PUT", "DELETE").Path("/api/providers/{provider}/frontends/{frontend}/maintenance").HandlerFunc(provider.maintenanceHandler(configurationChan))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(test.method, "/api/providers/web/frontends/"+test.frontend+"/maintenance", strings.NewReader(test.body))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode != http.StatusOK {
+				assert.Len(t, configurationChan, 0)
+				return
+			}
+
+			require.Len(t, configurationChan, 1)
+			message := <-configurationChan
+			assert.Equal(t, test.expectedMaintenance, message.Configuration.Frontends[test.frontend].Maintenance)
+			assert.Equal(t, "backend", message.Configuration.Frontends[test.frontend].Backend)
+		})
+	}
+}
+
+func TestFrontendUpdatesBeforeLoad(t *testing.T) {
+	current := &types.Configuration{
+		Backends: map[string]*types.Backend{
+			"blue":  {},
+			"green": {},
+		},
+		Frontends: map[string]*types.Frontend{
+			"frontend": {Backend: "blue"},
+		},
+	}
+	provider := &Provider{CurrentConfigurations: safe.New(types.Configurations{"web": current})}
+	configurationChan := make(chan types.ConfigMessage, 2)
+
+	router := mux.NewRouter()
+	router.Methods("PUT").Path("/api/providers/{provider}/frontends/{frontend}/backend").HandlerFunc(provider.switchFrontendBackendHandler(configurationChan))
+	router.Methods("PUT", "DELETE").Path("/api/providers/{provider}/frontends/{frontend}/maintenance").HandlerFunc(provider.maintenanceHandler(configurationChan))
+
+	// the second update is made before the first configuration is loaded
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/api/providers/web/frontends/frontend/backend", strings.NewReader(`{"backend":"green"}`)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/api/providers/web/frontends/frontend/maintenance", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	require.Len(t, configurationChan, 2)
+	<-configurationChan
+	message := <-configurationChan
+	assert.Equal(t, "green", message.Configuration.Frontends["frontend"].Backend)
+	assert.NotNil(t, message.Configuration.Frontends["frontend"].Maintenance)
+}
+
+func TestServerStateHandlers(t *testing.T) {
+	weight := 0
+
+	testCases := []struct {
+		desc               string
+		readOnly           bool
+		method             string
+		path               string
+		body               string
+		expectedStatusCode int
+		expectedState      *healthcheck.ServerState
+	}{
+		{
+			desc:               "drain",
+			method:             http.MethodPut,
+			path:               "/weight",
+			body:               `{"weight":0}`,
+			expectedStatusCode: http.StatusOK,
+			expectedState:      &healthcheck.ServerState{Weight: &weight},
+		},
+		{
+			desc:               "disable",
+			method:             http.MethodPut,
+			path:               "/disabled",
+			expectedStatusCode: http.StatusOK,
+			expectedState:      &healthcheck.ServerState{Weight: &weight, Disabled: true},
+		},
+		{
+			desc:               "restore the configured weight",
+			method:             http.MethodDelete,
+			path:               "/weight",
+			expectedStatusCode: http.StatusOK,
+			expectedState:      &healthcheck.ServerState{Disabled: true},
+		},
+		{
+			desc:               "enable",
+			method:             http.MethodDelete,
+			path:               "/disabled",
+			expectedStatusCode: http.StatusOK,
+			expectedState:      &healthcheck.ServerState{},
+		},
+		{
+			desc:               "invalid weight",
+			method:             http.MethodPut,
+			path:               "/weight",
+			body:               `{"weight":-1}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:               "missing weight",
+			method:             http.MethodPut,
+			path:               "/weight",
+			body:               `{}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			desc:               "undefined server",
+			method:             http.MethodPut,
+			path:               "/disabled",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			desc:               "read-only",
+			readOnly:           true,
+			method:             http.MethodPut,
+			path:               "/disabled",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	// the states of the servers are global, the test cases run in sequence
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			current := &types.Configuration{
+				Backends: map[string]*types.Backend{
+					"backend": {Servers: map[string]types.Server{"server": {URL: "http://10.0.0.1:80"}}},
+				},
+			}
+			provider := &Provider{ReadOnly: test.readOnly, CurrentConfigurations: safe.New(types.Configurations{"file": current})}
+
+			router := mux.NewRouter()
+			router.Methods("PUT", "DELETE").Path("/api/providers/{provider}/backends/{backend}/servers/{server}/weight").HandlerFunc(provider.serverWeightHandler)
+			router.Methods("PUT", "DELETE").Path("/api/providers/{provider}/backends/{backend}/servers/{server}/disabled").HandlerFunc(provider.serverDisabledHandler)
+
+			serverName := "server"
+			if test.expectedStatusCode == http.StatusNotFound {
+				serverName = "unknown"
+			}
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(test.method, "/api/providers/file/backends/backend/servers/"+serverName+test.path, strings.NewReader(test.body))
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			state := &healthcheck.ServerState{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), state))
+			assert.Equal(t, test.expectedState, state)
+		})
+	}
+
+	assert.Nil(t, healthcheck.GetServersAdmin().State("file", "backend", "server"))
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		readOnly           bool
+		method             string
+		body               string
+		expectedStatusCode int
+		expectedLevel      string
+	}{
+		{
+			desc:               "get",
+			method:             http.MethodGet,
+			expectedStatusCode: http.StatusOK,
+			expectedLevel:      "warning",
+		},
+		{
+			desc:               "set",
+			method:             http.MethodPut,
+			body:               `{"level":"DEBUG"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedLevel:      "debug",
+		},
+		{
+			desc:               "invalid level",
+			method:             http.MethodPut,
+			body:               `{"level":"verbose"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedLevel:      "warning",
+		},
+		{
+			desc:               "read-only",
+			readOnly:           true,
+			method:             http.MethodPut,
+			body:               `{"level":"debug"}`,
+			expectedStatusCode: http.StatusForbidden,
+			expectedLevel:      "warning",
+		},
+	}
+
+	defer log.SetLevel(log.GetLevel())
+
+	// the log level is global, the test cases run in sequence
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			log.SetLevel(logrus.WarnLevel)
+			provider := &Provider{ReadOnly: test.readOnly}
+
+			recorder := httptest.NewRecorder()
+			provider.logLevelHandler(recorder, httptest.NewRequest(test.method, "/api/loglevel", strings.NewReader(test.body)))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedLevel, log.GetLevel().String())
+			if test.expectedStatusCode != http.StatusOK {
+				return
+			}
+			level := map[string]string{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &level))
+			assert.Equal(t, test.expectedLevel, level["level"])
+		})
+	}
+}
+
+func TestGetStatisticsStreamHandler(t *testing.T) {
+	registry := middlewares.NewStatisticsRegistry()
+	handler := middlewares.NewFrontendStatistics(registry, "frontend")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+	})
+
+	provider := &Provider{StatisticsRegistry: registry}
+	router := mux.NewRouter()
+	router.Methods("GET").Path("/api/statistics/stream").HandlerFunc(provider.getStatisticsStreamHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/statistics/stream?interval=100ms")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// two events, the first one sent at once
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(line, "data: "), line)
+		data := &middlewares.StatisticsData{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), data))
+		require.Contains(t, data.Frontends, "frontend")
+		assert.Equal(t, int64(1), data.Frontends["frontend"].Count)
+		assert.Equal(t, map[string]int64{"5xx": 1}, data.Frontends["frontend"].StatusClassCount)
+
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "\n", line)
+	}
+
+	resp, err = http.Get(server.URL + "/api/statistics/stream?interval=1ms")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPurgeFrontendCacheHandler(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		readOnly           bool
+		frontend           string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			desc:               "purge",
+			frontend:           "frontend",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"purged":1}`,
+		},
+		{
+			desc:               "read only",
+			readOnly:           true,
+			frontend:           "frontend",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			desc:               "undefined frontend",
+			frontend:           "unknown",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			desc:               "frontend without cache",
+			frontend:           "other",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			caches := middlewares.NewCacheRegistry()
+			cache, err := caches.Get("file", "frontend", types.Cache{TTL: "1m"})
+			require.NoError(t, err)
+			cache.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static/foo", nil))
+
+			current := &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					"frontend": {Backend: "blue", Cache: &types.Cache{TTL: "1m"}},
+					"other":    {Backend: "blue"},
+				},
+			}
+			provider := &Provider{
+				ReadOnly:              test.readOnly,
+				CurrentConfigurations: safe.New(types.Configurations{"file": current}),
+				Caches:                caches,
+			}
+
+			router := mux.NewRouter()
+			router.Methods("DELETE").Path("/api/providers/{provider}/frontends/{frontend}/cache").HandlerFunc(provider.purgeFrontendCacheHandler)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodDelete, "/api/providers/file/frontends/"+test.frontend+"/cache?path=/static", nil)
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetEntryPointsHandler(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		entryPoints  map[string]*EntryPoint
+		expectedBody string
+	}{
+		{
+			desc:         "no entrypoints",
+			expectedBody: `{}`,
+		},
+		{
+			desc: "entrypoints",
+			entryPoints: map[string]*EntryPoint{
+				"http":  {Address: ":80", Protocol: "http", Redirect: "https"},
+				"https": {Address: ":443", Protocol: "http", TLS: true},
+			},
+			expectedBody: `{
+				"http": {"address": ":80", "protocol": "http", "tls": false, "redirect": "https", "auth": false, "compress": false},
+				"https": {"address": ":443", "protocol": "http", "tls": true, "auth": false, "compress": false}
+			}`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{EntryPoints: test.entryPoints}
+
+			recorder := httptest.NewRecorder()
+			provider.getEntryPointsHandler(recorder, httptest.NewRequest(http.MethodGet, "/api/entrypoints", nil))
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.JSONEq(t, test.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestGetConfigHandlerRedactsSecrets(t *testing.T) {
+	current := &types.Configuration{
+		Backends: map[string]*types.Backend{
+			"backend": {TLS: &types.BackendTLS{Cert: "/certs/client.cert", Key: "/certs/client.key"}},
+		},
+		Frontends: map[string]*types.Frontend{
+			"frontend": {
+				Auth: &types.Auth{JWT: &types.JWT{Secret: "secret", Issuer: "issuer"}},
+				WAF:  &types.WAF{Address: "https://waf:8443", TLS: &types.ClientTLS{Cert: "/certs/waf.cert", Key: "/certs/waf.key"}},
+			},
+		},
+		Middlewares: map[string]*types.Middleware{
+			"auth": {Auth: &types.Auth{JWT: &types.JWT{Secret: "secret", Issuer: "issuer"}}},
+			"oidc": {Auth: &types.Auth{OIDC: &types.OIDC{Issuer: "issuer", ClientSecret: "client secret", SessionSecret: "session secret"}}},
+		},
+		TLSConfiguration: []*types.TLSConfiguration{
+			{Certificate: &types.TLSCertificate{CertFile: "/certs/test.cert", KeyFile: "/certs/test.key"}},
+		},
+	}
+	provider := &Provider{CurrentConfigurations: safe.New(types.Configurations{"file": current})}
+
+	recorder := httptest.NewRecorder()
+	provider.getConfigHandler(recorder, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	exposed := types.Configurations{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &exposed))
+	require.NotNil(t, exposed["file"])
+	assert.Equal(t, &types.BackendTLS{Cert: "/certs/client.cert"}, exposed["file"].Backends["backend"].TLS)
+	assert.Equal(t, &types.JWT{Issuer: "issuer"}, exposed["file"].Frontends["frontend"].Auth.JWT)
+	assert.Equal(t, &types.ClientTLS{Cert: "/certs/waf.cert"}, exposed["file"].Frontends["frontend"].WAF.TLS)
+	assert.Equal(t, &types.JWT{Issuer: "issuer"}, exposed["file"].Middlewares["auth"].Auth.JWT)
+	assert.Equal(t, &types.OIDC{Issuer: "issuer"}, exposed["file"].Middlewares["oidc"].Auth.OIDC)
+	assert.Equal(t, &types.TLSCertificate{CertFile: "/certs/test.cert"}, exposed["file"].TLSConfiguration[0].Certificate)
+
+	// the current configuration keeps its secrets
+	assert.Equal(t, "/certs/client.key", current.Backends["backend"].TLS.Key)
+	assert.Equal(t, "secret", current.Frontends["frontend"].Auth.JWT.Secret)
+	assert.Equal(t, "/certs/waf.key", current.Frontends["frontend"].WAF.TLS.Key)
+	assert.Equal(t, "secret", current.Middlewares["auth"].Auth.JWT.Secret)
+	assert.Equal(t, "client secret", current.Middlewares["oidc"].Auth.OIDC.ClientSecret)
+	assert.Equal(t, "session secret", current.Middlewares["oidc"].Auth.OIDC.SessionSecret)
+	assert.Equal(t, "/certs/test.key", current.TLSConfiguration[0].Certificate.KeyFile)
+}
+
+func TestPprofRoutes(t *testing.T) {
+	testCases := []struct {
+		desc string
+		path string
+		url  string
+	}{
+		{
+			desc: "index",
+			path: "/",
+			url:  "/debug/pprof/",
+		},
+		{
+			desc: "named profile",
+			path: "/",
+			url:  "/debug/pprof/heap?debug=1",
+		},
+		{
+			desc: "command line",
+			path: "/",
+			url:  "/debug/pprof/cmdline",
+		},
+		{
+			desc: "named profile under a custom path",
+			path: "/traefik/",
+			url:  "/traefik/debug/pprof/goroutine?debug=1",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			router := mux.NewRouter()
+			addPprofRoutes(router, test.path)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.url, nil))
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.NotEmpty(t, recorder.Body.String())
+		})
+	}
+}
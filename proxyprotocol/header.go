@@ -0,0 +1,249 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxV1HeaderLength is the maximum length of a version 1 header, CRLF included
+	maxV1HeaderLength = 107
+
+	v2HeaderLength = 16
+	v2CommandLocal = 0x20
+	v2CommandProxy = 0x21
+	v2FamilyTCP4   = 0x11
+	v2FamilyTCP6   = 0x21
+)
+
+var (
+	v1Signature = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errNoHeader = errors.New("no PROXY protocol header")
+)
+
+// header holds the addresses carried by a PROXY protocol header.
+// They are nil when the header does not carry any (UNKNOWN or LOCAL).
+type header struct {
+	source      *net.TCPAddr
+	destination *net.TCPAddr
+}
+
+// readHeader reads a version 1 or 2 header at the start of the reader.
+// errNoHeader is returned, and nothing is consumed, if the data does not start with a header.
+func readHeader(r *bufio.Reader) (*header, error) {
+	ok, err := hasPrefix(r, v1Signature)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return readV1Header(r)
+	}
+	ok, err = hasPrefix(r, v2Signature)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return readV2Header(r)
+	}
+	return nil, errNoHeader
+}
+
+// hasPrefix checks the prefix byte by byte, so the check does not block on shorter data.
+// The data ending before the prefix does not start with it, the other read errors, e.g. timeouts, are returned.
+func hasPrefix(r *bufio.Reader, prefix []byte) (bool, error) {
+	for i := 1; i <= len(prefix); i++ {
+		peeked, err := r.Peek(i)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(peeked, prefix[:i]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func readV1Header(r *bufio.Reader) (*header, error) {
+	var line []byte
+	for len(line) < maxV1HeaderLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("invalid PROXY protocol header: missing CRLF")
+	}
+
+	// PROXY <protocol> <source address> <destination address> <source port> <destination port>
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return &header{}, nil
+	}
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("invalid PROXY protocol header: %q", line)
+	}
+	if parts[1] != "TCP4" && parts[1] != "TCP6" {
+		return nil, fmt.Errorf("unhandled PROXY protocol address type: %s", parts[1])
+	}
+
+	source, err := parseV1Address(parts[2], parts[4])
+	if err != nil {
+		return nil, err
+	}
+	destination, err := parseV1Address(parts[3], parts[5])
+	if err != nil {
+		return nil, err
+	}
+	if parts[1] == "TCP4" && (source.IP.To4() == nil || destination.IP.To4() == nil) {
+		return nil, fmt.Errorf("invalid PROXY protocol TCP4 addresses: %s %s", parts[2], parts[3])
+	}
+
+	return &header{source: source, destination: destination}, nil
+}
+
+func parseV1Address(rawIP, rawPort string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(rawIP)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid PROXY protocol IP: %s", rawIP)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid PROXY protocol port: %s", rawPort)
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+func readV2Header(r *bufio.Reader) (*header, error) {
+	buf := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	command := buf[12]
+	family := buf[13]
+	payload := make([]byte, binary.BigEndian.Uint16(buf[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	switch command {
+	case v2CommandLocal:
+		return &header{}, nil
+	case v2CommandProxy:
+	default:
+		return nil, fmt.Errorf("invalid PROXY protocol version 2 command: %#x", command)
+	}
+
+	// the TLVs following the addresses are ignored
+	switch family {
+	case v2FamilyTCP4:
+		if len(payload) < 12 {
+			return nil, errors.New("invalid PROXY protocol version 2 IPv4 addresses")
+		}
+		return &header{
+			source:      &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			destination: &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))},
+		}, nil
+	case v2FamilyTCP6:
+		if len(payload) < 36 {
+			return nil, errors.New("invalid PROXY protocol version 2 IPv6 addresses")
+		}
+		return &header{
+			source:      &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			destination: &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))},
+		}, nil
+	default:
+		// unsupported families (UDP, UNIX sockets...) are handled as LOCAL
+		return &header{}, nil
+	}
+}
+
+// WriteHeader writes a PROXY protocol header of the given version (1 or 2) carrying the source and destination addresses.
+// Addresses which are not TCP ones are sent as unknown.
+func WriteHeader(w io.Writer, version int, source, destination net.Addr) error {
+	var buf []byte
+	switch version {
+	case 1:
+		buf = buildV1Header(source, destination)
+	case 2:
+		buf = buildV2Header(source, destination)
+	default:
+		return fmt.Errorf("invalid PROXY protocol version: %d", version)
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func tcpAddresses(source, destination net.Addr) (*net.TCPAddr, *net.TCPAddr, bool) {
+	src, ok := source.(*net.TCPAddr)
+	if !ok {
+		return nil, nil, false
+	}
+	dst, ok := destination.(*net.TCPAddr)
+	if !ok {
+		return nil, nil, false
+	}
+	return src, dst, true
+}
+
+func buildV1Header(source, destination net.Addr) []byte {
+	src, dst, ok := tcpAddresses(source, destination)
+	if !ok {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	protocol := "TCP4"
+	if src.IP.To4() == nil || dst.IP.To4() == nil {
+		protocol = "TCP6"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", protocol, src.IP, dst.IP, src.Port, dst.Port))
+}
+
+func buildV2Header(source, destination net.Addr) []byte {
+	buf := append([]byte{}, v2Signature...)
+
+	src, dst, ok := tcpAddresses(source, destination)
+	if !ok {
+		return append(buf, v2CommandLocal, 0, 0, 0)
+	}
+
+	var addresses []byte
+	family := byte(v2FamilyTCP4)
+	if src.IP.To4() != nil && dst.IP.To4() != nil {
+		addresses = append(addresses, src.IP.To4()...)
+		addresses = append(addresses, dst.IP.To4()...)
+	} else {
+		family = v2FamilyTCP6
+		addresses = append(addresses, src.IP.To16()...)
+		addresses = append(addresses, dst.IP.To16()...)
+	}
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(ports[2:4], uint16(dst.Port))
+	addresses = append(addresses, ports...)
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(addresses)))
+
+	buf = append(buf, v2CommandProxy, family)
+	buf = append(buf, length...)
+	return append(buf, addresses...)
+}
@@ -0,0 +1,186 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHeader(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		data                string
+		expectedSource      string
+		expectedDestination string
+		expectedErr         bool
+		expectedRemaining   string
+	}{
+		{
+			desc:                "version 1 TCP4",
+			data:                "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nGET / HTTP/1.1\r\n",
+			expectedSource:      "192.168.0.1:56324",
+			expectedDestination: "192.168.0.11:443",
+			expectedRemaining:   "GET / HTTP/1.1\r\n",
+		},
+		{
+			desc:                "version 1 TCP6",
+			data:                "PROXY TCP6 ::1 ::2 56324 443\r\ndata",
+			expectedSource:      "[::1]:56324",
+			expectedDestination: "[::2]:443",
+			expectedRemaining:   "data",
+		},
+		{
+			desc:              "version 1 UNKNOWN",
+			data:              "PROXY UNKNOWN\r\ndata",
+			expectedRemaining: "data",
+		},
+		{
+			desc:        "version 1 invalid",
+			data:        "PROXY TCP4 192.168.0.1\r\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:                "version 2 TCP4",
+			data:                string(buildV2Header(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}, &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 80})) + "data",
+			expectedSource:      "10.0.0.1:1234",
+			expectedDestination: "10.0.0.2:80",
+			expectedRemaining:   "data",
+		},
+		{
+			desc:                "version 2 TCP6",
+			data:                string(buildV2Header(&net.TCPAddr{IP: net.ParseIP("::1"), Port: 1234}, &net.TCPAddr{IP: net.ParseIP("::2"), Port: 80})) + "data",
+			expectedSource:      "[::1]:1234",
+			expectedDestination: "[::2]:80",
+			expectedRemaining:   "data",
+		},
+		{
+			desc:              "version 2 LOCAL",
+			data:              string(v2Signature) + "\x20\x00\x00\x00data",
+			expectedRemaining: "data",
+		},
+		{
+			desc:        "version 2 invalid command",
+			data:        string(v2Signature) + "\x2f\x11\x00\x00data",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 without CRLF",
+			data:        "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 too long",
+			data:        "PROXY TCP6 " + strings.Repeat("0", maxV1HeaderLength) + "\r\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 truncated",
+			data:        "PROXY TCP4 192.168.0.1",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 unhandled protocol",
+			data:        "PROXY UDP4 192.168.0.1 192.168.0.11 56324 443\r\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 invalid IP",
+			data:        "PROXY TCP4 192.168.0 192.168.0.11 56324 443\r\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 IPv6 address in TCP4",
+			data:        "PROXY TCP4 ::1 192.168.0.11 56324 443\r\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 1 invalid port",
+			data:        "PROXY TCP4 192.168.0.1 192.168.0.11 65536 443\r\ndata",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 2 truncated header",
+			data:        string(v2Signature) + "\x21\x11",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 2 truncated addresses",
+			data:        string(v2Signature) + "\x21\x11\x00\x0c\x0a\x00\x00\x01",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 2 IPv4 addresses too short",
+			data:        string(v2Signature) + "\x21\x11\x00\x04\x0a\x00\x00\x01data",
+			expectedErr: true,
+		},
+		{
+			desc:        "version 2 IPv6 addresses too short",
+			data:        string(v2Signature) + "\x21\x21\x00\x0c" + strings.Repeat("\x00", 12) + "data",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := bufio.NewReader(strings.NewReader(test.data))
+			h, err := readHeader(r)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if len(test.expectedSource) > 0 {
+				assert.Equal(t, test.expectedSource, h.source.String())
+				assert.Equal(t, test.expectedDestination, h.destination.String())
+			} else {
+				assert.Nil(t, h.source)
+				assert.Nil(t, h.destination)
+			}
+
+			remaining, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedRemaining, string(remaining))
+		})
+	}
+}
+
+func TestReadHeaderWithoutHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXIED"))
+	_, err := readHeader(r)
+	assert.Equal(t, errNoHeader, err)
+
+	remaining, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "PROXIED", string(remaining))
+}
+
+func TestWriteHeader(t *testing.T) {
+	source := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}
+	destination := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 80}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteHeader(buf, 1, source, destination))
+	assert.Equal(t, "PROXY TCP4 10.0.0.1 10.0.0.2 1234 80\r\n", buf.String())
+
+	for _, version := range []int{1, 2} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, WriteHeader(buf, version, source, destination))
+
+		h, err := readHeader(bufio.NewReader(buf))
+		require.NoError(t, err)
+		assert.Equal(t, source.String(), h.source.String())
+		assert.Equal(t, destination.String(), h.destination.String())
+	}
+
+	assert.Error(t, WriteHeader(new(bytes.Buffer), 3, source, destination))
+}
@@ -0,0 +1,165 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// DefaultHeaderTimeout is the default maximum duration to read the PROXY protocol header of a connection
+const DefaultHeaderTimeout = 10 * time.Second
+
+// SourceChecker tells whether the PROXY protocol header sent by a peer can be trusted.
+// The header of an untrusted peer is discarded and the address of the peer is used.
+type SourceChecker func(net.Addr) (bool, error)
+
+// Listener wraps a listener whose connections may start with a PROXY protocol header (version 1 or 2).
+// RemoteAddr and LocalAddr of the connections then return the addresses carried by the header.
+// The connections whose header is not read within HeaderTimeout, if positive, are closed.
+type Listener struct {
+	net.Listener
+	SourceCheck   SourceChecker
+	HeaderTimeout time.Duration
+}
+
+// Accept waits for and returns the next connection to the listener
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		trusted := true
+		if l.SourceCheck != nil {
+			trusted, err = l.SourceCheck(conn.RemoteAddr())
+			if err != nil {
+				log.Errorf("Error checking PROXY protocol source %s: %v", conn.RemoteAddr(), err)
+				conn.Close()
+				continue
+			}
+		}
+
+		return NewConn(conn, trusted, l.HeaderTimeout), nil
+	}
+}
+
+// Conn is a connection which may start with a PROXY protocol header.
+// The header is read on the first call to Read, RemoteAddr or LocalAddr.
+type Conn struct {
+	net.Conn
+	reader        *bufio.Reader
+	trusted       bool
+	headerTimeout time.Duration
+	once          sync.Once
+	header        *header
+	err           error
+
+	deadlineMutex  sync.Mutex
+	readDeadline   time.Time // the read deadline set on the connection, restored once the header is read
+	headerDeadline time.Time // the deadline to read the header, zero once it is read
+}
+
+// NewConn wraps a connection which may start with a PROXY protocol header.
+// The addresses of the header are only used if trusted is true.
+func NewConn(conn net.Conn, trusted bool, headerTimeout time.Duration) *Conn {
+	return &Conn{
+		Conn:          conn,
+		reader:        bufio.NewReader(conn),
+		trusted:       trusted,
+		headerTimeout: headerTimeout,
+	}
+}
+
+func (c *Conn) readHeader() {
+	if c.headerTimeout > 0 {
+		c.deadlineMutex.Lock()
+		c.headerDeadline = time.Now().Add(c.headerTimeout)
+		c.Conn.SetReadDeadline(c.earliestReadDeadline())
+		c.deadlineMutex.Unlock()
+		defer func() {
+			c.deadlineMutex.Lock()
+			c.headerDeadline = time.Time{}
+			c.Conn.SetReadDeadline(c.readDeadline)
+			c.deadlineMutex.Unlock()
+		}()
+	}
+
+	h, err := readHeader(c.reader)
+	if err == errNoHeader {
+		return
+	}
+	if err != nil {
+		log.Errorf("Error reading PROXY protocol header from %s: %v", c.Conn.RemoteAddr(), err)
+		c.err = err
+		c.Conn.Close()
+		return
+	}
+	if c.trusted {
+		c.header = h
+	}
+}
+
+// earliestReadDeadline returns the read deadline set on the connection, or the one to read the header if earlier
+func (c *Conn) earliestReadDeadline() time.Time {
+	if c.headerDeadline.IsZero() || !c.readDeadline.IsZero() && c.readDeadline.Before(c.headerDeadline) {
+		return c.readDeadline
+	}
+	return c.headerDeadline
+}
+
+// SetDeadline sets the read and write deadlines of the connection, the header being still read before the read one
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the connection, the header being still read before it
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(c.earliestReadDeadline())
+}
+
+// Read reads the data following the PROXY protocol header
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the source address of the PROXY protocol header, or the address of the peer
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.header != nil && c.header.source != nil {
+		return c.header.source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address of the PROXY protocol header, or the local address
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.header != nil && c.header.destination != nil {
+		return c.header.destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// CloseWrite closes the write side of the underlying connection, if supported
+func (c *Conn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface {
+		CloseWrite() error
+	}); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,164 @@
+package proxyprotocol
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		trusted            bool
+		expectedRemoteAddr string
+	}{
+		{
+			desc:               "trusted source",
+			trusted:            true,
+			expectedRemoteAddr: "10.0.0.1:1234",
+		},
+		{
+			desc:               "untrusted source",
+			expectedRemoteAddr: "127.0.0.1",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			listener := &Listener{
+				Listener: tcpListener,
+				SourceCheck: func(addr net.Addr) (bool, error) {
+					return test.trusted, nil
+				},
+			}
+			defer listener.Close()
+
+			go func() {
+				conn, err := net.Dial("tcp", listener.Addr().String())
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				conn.Write([]byte("PROXY TCP4 10.0.0.1 10.0.0.2 1234 80\r\ndata"))
+			}()
+
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			remoteAddr := conn.RemoteAddr().String()
+			if test.trusted {
+				assert.Equal(t, test.expectedRemoteAddr, remoteAddr)
+			} else {
+				host, _, err := net.SplitHostPort(remoteAddr)
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedRemoteAddr, host)
+			}
+
+			data, err := ioutil.ReadAll(conn)
+			require.NoError(t, err)
+			assert.Equal(t, "data", string(data))
+		})
+	}
+}
+
+func TestListenerHeaderTimeout(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener := &Listener{Listener: tcpListener, HeaderTimeout: 50 * time.Millisecond}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the client sends nothing
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("PROXY protocol header read not timed out")
+	}
+}
+
+func TestConnReadDeadline(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		sent         string
+		expectedData string
+	}{
+		{
+			desc: "deadline while reading the header",
+		},
+		{
+			desc:         "deadline after the header",
+			sent:         "PROXY TCP4 10.0.0.1 10.0.0.2 1234 80\r\ndata",
+			expectedData: "data",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			listener := &Listener{Listener: tcpListener, HeaderTimeout: time.Minute}
+			defer listener.Close()
+
+			client, err := net.Dial("tcp", listener.Addr().String())
+			require.NoError(t, err)
+			defer client.Close()
+			_, err = client.Write([]byte(test.sent))
+			require.NoError(t, err)
+
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+
+			done := make(chan error, 1)
+			go func() {
+				data := make([]byte, len(test.expectedData))
+				if _, err := io.ReadFull(conn, data); err != nil || string(data) != test.expectedData {
+					done <- fmt.Errorf("unexpected data %q: %v", data, err)
+					return
+				}
+				_, err := conn.Read(make([]byte, 1))
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				require.Error(t, err)
+				netErr, ok := err.(net.Error)
+				require.True(t, ok, err.Error())
+				assert.True(t, netErr.Timeout())
+			case <-time.After(5 * time.Second):
+				t.Fatal("read deadline not applied")
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package quic
+
+// sendBuffer holds the data of a stream, or of the CRYPTO frames of an encryption level, until it is acknowledged
+type sendBuffer struct {
+	data  []byte // the data from offset base
+	base  uint64 // the data below base has been acknowledged
+	sent  uint64 // the data from sent has never been sent
+	acked rangeSet
+	lost  rangeSet // the data sent and lost, to send again
+
+	fin      bool // the end of the data has been written
+	finSent  bool
+	finLost  bool
+	finAcked bool
+}
+
+func (b *sendBuffer) write(data []byte) {
+	b.data = append(b.data, data...)
+}
+
+// end returns the offset of the end of the data written
+func (b *sendBuffer) end() uint64 {
+	return b.base + uint64(len(b.data))
+}
+
+// buffered returns the amount of data written and not yet acknowledged
+func (b *sendBuffer) buffered() int {
+	return len(b.data)
+}
+
+// hasData returns whether there is data to send, the new data being limited by the flow control limit
+func (b *sendBuffer) hasData(limit uint64) bool {
+	return len(b.lost) > 0 || b.finLost || (b.sent < b.end() && b.sent < limit) || (b.fin && !b.finSent && b.sent == b.end())
+}
+
+// done returns whether all the data and the end of the data have been acknowledged
+func (b *sendBuffer) done() bool {
+	return b.finAcked && b.base == b.end()
+}
+
+// next returns the next data to send, lost data first, at most maxLength bytes and new data below limit.
+// isNew tells whether the data is sent for the first time.
+func (b *sendBuffer) next(maxLength int, limit uint64) (offset uint64, data []byte, fin, isNew, ok bool) {
+	if r, ok := b.lost.first(); ok {
+		end := r.end
+		if end-r.start > uint64(maxLength) {
+			end = r.start + uint64(maxLength)
+		}
+		b.lost.remove(r.start, end)
+		fin = b.finLost && end == b.end()
+		if fin {
+			b.finLost = false
+		}
+		return r.start, b.data[r.start-b.base : end-b.base], fin, false, true
+	}
+
+	if b.sent < b.end() && b.sent < limit {
+		end := b.end()
+		if end > limit {
+			end = limit
+		}
+		if end-b.sent > uint64(maxLength) {
+			end = b.sent + uint64(maxLength)
+		}
+		offset = b.sent
+		b.sent = end
+		fin = b.fin && end == b.end()
+		if fin {
+			b.finSent = true
+		}
+		return offset, b.data[offset-b.base : end-b.base], fin, true, true
+	}
+
+	if b.fin && b.sent == b.end() && (!b.finSent || b.finLost) {
+		b.finSent = true
+		b.finLost = false
+		return b.end(), nil, true, false, true
+	}
+	return 0, nil, false, false, false
+}
+
+func (b *sendBuffer) ack(offset, length uint64, fin bool) {
+	b.acked.add(offset, offset+length)
+	b.lost.remove(offset, offset+length)
+	if fin {
+		b.finAcked = true
+		b.finLost = false
+	}
+	if end := b.acked.prefix(b.base); end > b.base {
+		b.data = b.data[end-b.base:]
+		b.acked.remove(b.base, end)
+		b.base = end
+	}
+}
+
+func (b *sendBuffer) lose(offset, length uint64, fin bool) {
+	start, end := offset, offset+length
+	if start < b.base {
+		start = b.base
+	}
+	if start < end {
+		b.lost.add(start, end)
+		for _, r := range b.acked {
+			b.lost.remove(r.start, r.end)
+		}
+	}
+	if fin && !b.finAcked {
+		b.finLost = true
+	}
+}
+
+// recvBuffer reassembles the data of a stream, or of the CRYPTO frames of an encryption level
+type recvBuffer struct {
+	data      []byte // the data from offset read, received or not
+	read      uint64 // the data below read has been consumed
+	received  rangeSet
+	highest   uint64 // the highest offset received
+	finalSize uint64
+	hasFinal  bool
+}
+
+// push stores the data received at offset, returning the increase of the highest offset received
+func (b *recvBuffer) push(offset uint64, data []byte, fin bool) (uint64, error) {
+	end := offset + uint64(len(data))
+	if end > MaxVarint {
+		return 0, transportError(errorFlowControl, "stream data beyond the maximum offset")
+	}
+	if b.hasFinal && (end > b.finalSize || fin && end != b.finalSize) {
+		return 0, transportError(errorFinalSize, "stream data beyond its final size")
+	}
+	if fin && end < b.highest {
+		return 0, transportError(errorFinalSize, "final size below the data received")
+	}
+	if fin {
+		b.finalSize = end
+		b.hasFinal = true
+	}
+
+	increase := uint64(0)
+	if end > b.highest {
+		increase = end - b.highest
+		b.highest = end
+	}
+	if end <= b.read {
+		return increase, nil
+	}
+	if offset < b.read {
+		data = data[b.read-offset:]
+		offset = b.read
+	}
+	if needed := int(end - b.read); len(b.data) < needed {
+		if cap(b.data) >= needed {
+			b.data = b.data[:needed]
+		} else {
+			data := make([]byte, needed, 2*needed)
+			copy(data, b.data)
+			b.data = data
+		}
+	}
+	copy(b.data[offset-b.read:], data)
+	b.received.add(offset, end)
+	return increase, nil
+}
+
+// readable returns the contiguous data received and not yet consumed
+func (b *recvBuffer) readable() []byte {
+	return b.data[:b.received.prefix(b.read)-b.read]
+}
+
+func (b *recvBuffer) consume(n int) {
+	b.data = b.data[n:]
+	b.read += uint64(n)
+	b.received.remove(0, b.read)
+}
+
+// finished returns whether all the data has been consumed
+func (b *recvBuffer) finished() bool {
+	return b.hasFinal && b.read == b.finalSize
+}
+
+// complete returns whether all the data has been received
+func (b *recvBuffer) complete() bool {
+	return b.hasFinal && b.received.prefix(b.read) == b.finalSize
+}
@@ -0,0 +1,116 @@
+package quic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecvBuffer(t *testing.T) {
+	var b recvBuffer
+
+	increase, err := b.push(5, []byte("world"), true)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), increase)
+	assert.Empty(t, b.readable())
+	assert.False(t, b.complete())
+
+	increase, err = b.push(0, []byte("hello"), false)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), increase)
+	assert.Equal(t, "helloworld", string(b.readable()))
+	assert.True(t, b.complete())
+
+	b.consume(7)
+	assert.Equal(t, "rld", string(b.readable()))
+	assert.False(t, b.finished())
+
+	// the data received again is ignored
+	_, err = b.push(0, []byte("hello"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "rld", string(b.readable()))
+
+	b.consume(3)
+	assert.True(t, b.finished())
+}
+
+func TestRecvBufferFinalSize(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		offset uint64
+		data   string
+		fin    bool
+	}{
+		{
+			desc:   "data beyond the final size",
+			offset: 8,
+			data:   "abc",
+		},
+		{
+			desc:   "different final size",
+			offset: 4,
+			data:   "abc",
+			fin:    true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var b recvBuffer
+			_, err := b.push(0, []byte("0123456789"), true)
+			require.NoError(t, err)
+
+			_, err = b.push(test.offset, []byte(test.data), test.fin)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSendBuffer(t *testing.T) {
+	var b sendBuffer
+	b.write([]byte("helloworld"))
+	b.fin = true
+
+	offset, data, fin, isNew, ok := b.next(5, 100)
+	require.True(t, ok)
+	assert.Equal(t, uint64(0), offset)
+	assert.Equal(t, "hello", string(data))
+	assert.False(t, fin)
+	assert.True(t, isNew)
+
+	// the new data is limited by the flow control limit
+	offset, data, fin, _, ok = b.next(100, 8)
+	require.True(t, ok)
+	assert.Equal(t, uint64(5), offset)
+	assert.Equal(t, "wor", string(data))
+	assert.False(t, fin)
+
+	// the lost data is sent again first
+	b.lose(0, 5, false)
+	offset, data, _, isNew, ok = b.next(100, 100)
+	require.True(t, ok)
+	assert.Equal(t, uint64(0), offset)
+	assert.Equal(t, "hello", string(data))
+	assert.False(t, isNew)
+
+	offset, data, fin, _, ok = b.next(100, 100)
+	require.True(t, ok)
+	assert.Equal(t, uint64(8), offset)
+	assert.Equal(t, "ld", string(data))
+	assert.True(t, fin)
+
+	_, _, _, _, ok = b.next(100, 100)
+	assert.False(t, ok)
+
+	b.ack(5, 5, true)
+	assert.Equal(t, 10, b.buffered())
+	assert.False(t, b.done())
+
+	b.ack(0, 5, false)
+	assert.Equal(t, 0, b.buffered())
+	assert.True(t, b.done())
+}
@@ -0,0 +1,1248 @@
+package quic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// maxCryptoBuffer is the maximum amount of handshake data buffered at an encryption level
+const maxCryptoBuffer = 64 << 10
+
+// maxDatagramsPerFlush is the maximum number of datagrams sent at once by a connection
+const maxDatagramsPerFlush = 64
+
+// maxCloseResends is the maximum number of times the CONNECTION_CLOSE frame is sent again to a peer still sending packets
+const maxCloseResends = 8
+
+var errStreamLimit = errors.New("quic: stream limit reached")
+
+type datagram struct {
+	data []byte
+	addr net.Addr
+}
+
+// Conn is a QUIC connection accepted by a Listener
+type Conn struct {
+	listener *Listener
+	config   *Config
+
+	mu         sync.Mutex
+	remoteAddr net.Addr
+
+	originalDestinationID []byte // the connection ID chosen by the client for its first Initial packet
+	sourceID              []byte // the connection ID of the server
+	destinationID         []byte // the connection ID of the client
+
+	spaces           [levelCount]*packetSpace
+	handshake        *serverHandshake
+	tlsState         tls.ConnectionState
+	peerParams       *transportParameters
+	keyPhase         bool
+	keyPhaseStart    uint64
+	previousReadKeys *packetKeys
+	nextReadKeys     *packetKeys
+
+	rtt              *rttEstimator
+	congestion       *congestionController
+	ptoCount         uint
+	addressValidated bool
+	bytesReceived    int
+	bytesSent        int
+
+	handshakeComplete bool
+	sendHandshakeDone bool
+
+	streams            map[uint64]*Stream
+	nextStreamBidi     uint64 // the ID of the next bidirectional stream of the client
+	nextStreamUni      uint64 // the ID of the next unidirectional stream of the client
+	maxStreamsBidi     uint64 // the number of bidirectional streams the client is allowed to open
+	maxStreamsUni      uint64 // the number of unidirectional streams the client is allowed to open
+	sendMaxStreamsBidi bool
+	sendMaxStreamsUni  bool
+	nextServerStream   uint64
+	peerMaxStreamsUni  uint64
+	acceptBidi         []*Stream
+	acceptUni          []*Stream
+	acceptCond         *sync.Cond
+
+	peerMaxData   uint64 // the flow control limit of the peer
+	dataSent      uint64
+	maxData       uint64 // the flow control limit advertised to the peer
+	dataReceived  uint64
+	dataConsumed  uint64
+	sendMaxData   bool
+	pathResponses [][]byte
+
+	idleTimeout  time.Duration
+	idleDeadline time.Time
+
+	closeErr      error
+	closing       bool
+	closePending  bool
+	closeResends  int
+	closeDeadline time.Time
+	closeSent     chan struct{}
+	closeSentOnce sync.Once
+	terminated    bool
+
+	incoming chan *datagram
+	wake     chan struct{}
+}
+
+func newConn(l *Listener, h *header, addr net.Addr, now time.Time) *Conn {
+	c := &Conn{
+		listener:              l,
+		config:                &l.config,
+		remoteAddr:            addr,
+		originalDestinationID: h.destinationID,
+		sourceID:              newConnectionID(),
+		destinationID:         h.sourceID,
+		rtt:                   newRTTEstimator(),
+		congestion:            newCongestionController(),
+		streams:               make(map[uint64]*Stream),
+		nextStreamUni:         2,
+		maxStreamsBidi:        l.config.MaxIncomingStreams,
+		maxStreamsUni:         l.config.MaxIncomingUniStreams,
+		nextServerStream:      3,
+		maxData:               connectionReceiveWindow,
+		idleDeadline:          now.Add(l.config.HandshakeTimeout),
+		closeSent:             make(chan struct{}),
+		incoming:              make(chan *datagram, 64),
+		wake:                  make(chan struct{}, 1),
+	}
+	c.acceptCond = sync.NewCond(&c.mu)
+	for lvl := range c.spaces {
+		c.spaces[lvl] = newPacketSpace()
+	}
+
+	clientSecret, serverSecret := initialSecrets(h.destinationID)
+	c.spaces[levelInitial].readKeys = newPacketKeys(cipherSuites[0], clientSecret)
+	c.spaces[levelInitial].writeKeys = newPacketKeys(cipherSuites[0], serverSecret)
+
+	params := &transportParameters{
+		originalDestinationConnectionID: c.originalDestinationID,
+		initialSourceConnectionID:       c.sourceID,
+		maxIdleTimeout:                  l.config.MaxIdleTimeout,
+		initialMaxData:                  connectionReceiveWindow,
+		initialMaxStreamDataBidiRemote:  streamReceiveWindow,
+		initialMaxStreamDataUni:         streamReceiveWindow,
+		initialMaxStreamsBidi:           l.config.MaxIncomingStreams,
+		initialMaxStreamsUni:            l.config.MaxIncomingUniStreams,
+		disableActiveMigration:          true,
+	}
+	c.handshake = newServerHandshake(l.tlsConfig, params.encode())
+	return c
+}
+
+// AcceptStream waits for and returns the next bidirectional stream opened by the client
+func (c *Conn) AcceptStream() (*Stream, error) {
+	return c.accept(&c.acceptBidi)
+}
+
+// AcceptUniStream waits for and returns the next unidirectional stream opened by the client
+func (c *Conn) AcceptUniStream() (*Stream, error) {
+	return c.accept(&c.acceptUni)
+}
+
+func (c *Conn) accept(queue *[]*Stream) (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if len(*queue) > 0 {
+			s := (*queue)[0]
+			*queue = (*queue)[1:]
+			return s, nil
+		}
+		if c.closeErr != nil {
+			return nil, c.closeErr
+		}
+		c.acceptCond.Wait()
+	}
+}
+
+// OpenUniStream opens a unidirectional stream, sending data to the client
+func (c *Conn) OpenUniStream() (*Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeErr != nil {
+		return nil, c.closeErr
+	}
+	if c.nextServerStream/4 >= c.peerMaxStreamsUni {
+		return nil, errStreamLimit
+	}
+	s := newStream(c, c.nextServerStream, false, true, c.peerParams.initialMaxStreamDataUni)
+	c.streams[s.id] = s
+	c.nextServerStream += 4
+	return s, nil
+}
+
+// CloseWithError closes the connection with the application error code, once the CONNECTION_CLOSE frame is sent
+func (c *Conn) CloseWithError(code uint64, reason string) error {
+	c.mu.Lock()
+	c.close(&ApplicationError{Code: code, Reason: reason}, time.Now())
+	c.wakeLocked()
+	c.mu.Unlock()
+	<-c.closeSent
+	return nil
+}
+
+// LocalAddr returns the local address of the listener of the connection
+func (c *Conn) LocalAddr() net.Addr {
+	return c.listener.Addr()
+}
+
+// RemoteAddr returns the address of the client
+func (c *Conn) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remoteAddr
+}
+
+// ConnectionState returns the state of the TLS handshake of the connection
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tlsState
+}
+
+// wakeLocked wakes the loop of the connection up, for it to send the pending data
+func (c *Conn) wakeLocked() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the loop of the connection, processing the datagrams received and the timers, and sending the packets
+func (c *Conn) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		var datagrams []*datagram
+		select {
+		case d := <-c.incoming:
+			datagrams = append(datagrams, d)
+			for len(datagrams) < cap(c.incoming) && len(c.incoming) > 0 {
+				datagrams = append(datagrams, <-c.incoming)
+			}
+		case <-timer.C:
+		case <-c.wake:
+		}
+
+		now := time.Now()
+		c.mu.Lock()
+		for _, d := range datagrams {
+			c.handleDatagram(d, now)
+		}
+		c.handleTimers(now)
+		out, closeSent := c.flush(now)
+		addr := c.remoteAddr
+		terminated := c.terminated
+		next := c.nextTimer(now)
+		c.mu.Unlock()
+
+		for _, d := range out {
+			if _, err := c.listener.conn.WriteTo(d, addr); err != nil {
+				log.Debugf("Error sending QUIC datagram to %s: %v", addr, err)
+			}
+		}
+		if closeSent || terminated {
+			c.closeSentOnce.Do(func() { close(c.closeSent) })
+		}
+		if terminated {
+			c.listener.remove(c)
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(next.Sub(now))
+	}
+}
+
+// close starts closing the connection, the CONNECTION_CLOSE frame being sent by the next flush
+func (c *Conn) close(err error, now time.Time) {
+	if c.closing || c.terminated {
+		return
+	}
+	if e, ok := err.(*TransportError); ok && e.Code != errorNone {
+		log.Debugf("Closing QUIC connection from %s: %v", c.remoteAddr, err)
+	}
+	c.closing = true
+	c.closePending = true
+	c.closeDeadline = now.Add(3 * c.rtt.pto(levelApplication))
+	c.abort(err)
+}
+
+// terminate discards the connection at once
+func (c *Conn) terminate(err error) {
+	if c.terminated {
+		return
+	}
+	c.terminated = true
+	c.abort(err)
+}
+
+// abort fails the pending and future operations on the connection and its streams with the error
+func (c *Conn) abort(err error) {
+	if c.closeErr == nil {
+		c.closeErr = err
+	}
+	for _, s := range c.streams {
+		s.cancel()
+		s.cond.Broadcast()
+	}
+	c.acceptCond.Broadcast()
+}
+
+func (c *Conn) handleTimers(now time.Time) {
+	if c.terminated {
+		return
+	}
+	if c.closing {
+		if !now.Before(c.closeDeadline) {
+			c.terminate(c.closeErr)
+		}
+		return
+	}
+	if !now.Before(c.idleDeadline) {
+		c.terminate(ErrIdleTimeout)
+		return
+	}
+
+	for lvl, space := range c.spaces {
+		if !space.lossTime.IsZero() && !now.Before(space.lossTime) {
+			c.detectLostPackets(level(lvl), now)
+			return
+		}
+	}
+	if deadline, lvl, ok := c.ptoDeadline(); ok && !now.Before(deadline) {
+		c.onProbeTimeout(lvl)
+	}
+}
+
+// nextTimer returns the time at which the loop must handle the timers
+func (c *Conn) nextTimer(now time.Time) time.Time {
+	if c.closing {
+		return c.closeDeadline
+	}
+	next := c.idleDeadline
+	earliest := func(t time.Time) {
+		if !t.IsZero() && t.Before(next) {
+			next = t
+		}
+	}
+	for _, space := range c.spaces {
+		earliest(space.lossTime)
+		if space.ackElicitingPending > 0 && !space.discarded {
+			earliest(space.ackDeadline)
+		}
+	}
+	if deadline, _, ok := c.ptoDeadline(); ok {
+		earliest(deadline)
+	}
+	if next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// ptoDeadline returns the earliest probe timeout of the packet number spaces with ack-eliciting packets in flight
+func (c *Conn) ptoDeadline() (time.Time, level, bool) {
+	var deadline time.Time
+	var deadlineLevel level
+	backoff := c.ptoCount
+	if backoff > maxPTOBackoff {
+		backoff = maxPTOBackoff
+	}
+	for lvl, space := range c.spaces {
+		if space.discarded || !space.ackElicitingInFlight() || level(lvl) == levelApplication && !c.handshakeComplete {
+			continue
+		}
+		t := space.lastAckElicitingSent.Add(c.rtt.pto(level(lvl)) << backoff)
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline, deadlineLevel = t, level(lvl)
+		}
+	}
+	return deadline, deadlineLevel, !deadline.IsZero()
+}
+
+// onProbeTimeout sends probe packets, with the data of the oldest packets in flight if any
+func (c *Conn) onProbeTimeout(lvl level) {
+	c.ptoCount++
+	space := c.spaces[lvl]
+	space.probes = 2
+	probes := 0
+	for _, packet := range space.sent {
+		if !packet.ackEliciting {
+			continue
+		}
+		for _, frame := range packet.frames {
+			c.onFrameLost(lvl, frame)
+		}
+		if probes++; probes == space.probes {
+			break
+		}
+	}
+}
+
+func (c *Conn) handleDatagram(d *datagram, now time.Time) {
+	if c.terminated {
+		return
+	}
+	if c.closing {
+		if c.closeResends < maxCloseResends {
+			c.closeResends++
+			c.closePending = true
+		}
+		return
+	}
+
+	c.bytesReceived += len(d.data)
+	data := d.data
+	for len(data) > 0 && !c.closing && !c.terminated {
+		h, err := parseHeader(data)
+		if err != nil || h.unsupportedVersion {
+			return
+		}
+		packet := data[:h.length]
+		data = data[h.length:]
+		if err := c.handlePacket(h, packet, d.addr, now); err != nil {
+			c.close(toTransportError(err), now)
+		}
+	}
+}
+
+func (c *Conn) handlePacket(h *header, packet []byte, addr net.Addr, now time.Time) error {
+	if !h.hasPacketNumberSpace {
+		return nil
+	}
+	if !bytes.Equal(h.destinationID, c.sourceID) && !(h.long && h.packetType == packetTypeInitial && bytes.Equal(h.destinationID, c.originalDestinationID)) {
+		return nil
+	}
+	if h.long && !bytes.Equal(h.sourceID, c.destinationID) {
+		return nil
+	}
+
+	lvl := h.level()
+	space := c.spaces[lvl]
+	if space.discarded || space.readKeys == nil || lvl == levelApplication && !c.handshakeComplete {
+		return nil
+	}
+	if h.packetNumberOffset+4+16 > len(packet) {
+		return nil
+	}
+
+	keys := space.readKeys
+	pnLen := keys.unprotectHeader(packet, h.packetNumberOffset)
+	headerLen := h.packetNumberOffset + pnLen
+	truncated := uint64(0)
+	for _, b := range packet[h.packetNumberOffset:headerLen] {
+		truncated = truncated<<8 | uint64(b)
+	}
+	number := decodePacketNumber(space.largestReceived, truncated, pnLen)
+
+	updatingKeys := false
+	if lvl == levelApplication && (packet[0]&0x04 != 0) != c.keyPhase {
+		if c.previousReadKeys != nil && number < c.keyPhaseStart {
+			keys = c.previousReadKeys
+		} else {
+			if c.nextReadKeys == nil {
+				c.nextReadKeys = space.readKeys.next()
+			}
+			keys = c.nextReadKeys
+			updatingKeys = true
+		}
+	}
+
+	payload, err := keys.aead.Open(packet[headerLen:headerLen], keys.nonce(number), packet[headerLen:], packet[:headerLen])
+	if err != nil {
+		return nil
+	}
+	if h.long && packet[0]&0x0c != 0 || !h.long && packet[0]&0x18 != 0 {
+		return transportError(errorProtocolViolation, "reserved bits set")
+	}
+	if space.received.contains(number, number+1) || len(space.received) > 0 && number < space.received[0].start {
+		return nil
+	}
+
+	if updatingKeys {
+		c.previousReadKeys = space.readKeys
+		space.readKeys = c.nextReadKeys
+		space.writeKeys = space.writeKeys.next()
+		c.nextReadKeys = nil
+		c.keyPhase = !c.keyPhase
+		c.keyPhaseStart = number
+	}
+	if lvl == levelApplication && int64(number) > space.largestReceived {
+		c.remoteAddr = addr
+	}
+
+	ackEliciting, err := c.handleFrames(lvl, payload, now)
+	if err != nil {
+		return err
+	}
+	space.recordReceived(number, ackEliciting, now)
+
+	if lvl == levelHandshake && !c.addressValidated {
+		// the client proves its address by using the handshake keys, the Initial keys being discarded
+		c.addressValidated = true
+		c.discardSpace(levelInitial)
+	}
+	if c.handshakeComplete {
+		c.idleDeadline = now.Add(c.idleTimeout)
+	}
+	return nil
+}
+
+// handleFrames processes the frames of a packet, returning whether it is ack-eliciting
+func (c *Conn) handleFrames(lvl level, payload []byte, now time.Time) (bool, error) {
+	p := &parser{data: payload}
+	ackEliciting := false
+	for !p.empty() {
+		typ := p.varint()
+		if p.err != nil {
+			break
+		}
+		if lvl != levelApplication {
+			switch typ {
+			case framePadding, framePing, frameAck, frameAckECN, frameCrypto, frameConnectionClose:
+			default:
+				return false, transportError(errorProtocolViolation, "frame 0x%x in %s packet", typ, lvl)
+			}
+		}
+		switch typ {
+		case framePadding, frameAck, frameAckECN, frameConnectionClose, frameApplicationClose:
+		default:
+			ackEliciting = true
+		}
+
+		var err error
+		switch {
+		case typ == framePadding:
+			for len(p.data) > 0 && p.data[0] == 0 {
+				p.data = p.data[1:]
+			}
+		case typ == framePing:
+		case typ == frameAck || typ == frameAckECN:
+			err = c.handleAck(lvl, p, typ == frameAckECN, now)
+		case typ == frameResetStream:
+			id, code, finalSize := p.varint(), p.varint(), p.varint()
+			if p.err == nil {
+				err = c.handleResetStream(id, code, finalSize)
+			}
+		case typ == frameStopSending:
+			id, code := p.varint(), p.varint()
+			if p.err == nil {
+				err = c.handleStopSending(id, code)
+			}
+		case typ == frameCrypto:
+			offset := p.varint()
+			data := p.bytes(int(p.varint()))
+			if p.err == nil {
+				err = c.handleCrypto(lvl, offset, data, now)
+			}
+		case typ&^0x07 == frameStream:
+			id := p.varint()
+			offset := uint64(0)
+			if typ&streamFrameOffset != 0 {
+				offset = p.varint()
+			}
+			var data []byte
+			if typ&streamFrameLength != 0 {
+				data = p.bytes(int(p.varint()))
+			} else {
+				data = p.bytes(len(p.data))
+			}
+			if p.err == nil {
+				err = c.handleStream(id, offset, data, typ&streamFrameFin != 0)
+			}
+		case typ == frameMaxData:
+			if limit := p.varint(); limit > c.peerMaxData {
+				c.peerMaxData = limit
+			}
+		case typ == frameMaxStreamData:
+			id, limit := p.varint(), p.varint()
+			if p.err == nil {
+				err = c.handleMaxStreamData(id, limit)
+			}
+		case typ == frameMaxStreamsBidi || typ == frameMaxStreamsUni:
+			limit := p.varint()
+			if limit > 1<<60 {
+				err = transportError(errorFrameEncoding, "invalid MAX_STREAMS")
+			} else if typ == frameMaxStreamsUni && limit > c.peerMaxStreamsUni {
+				c.peerMaxStreamsUni = limit
+			}
+		case typ == frameDataBlocked, typ == frameStreamsBlockedBidi, typ == frameStreamsBlockedUni:
+			p.varint()
+		case typ == frameStreamDataBlocked:
+			p.varint()
+			p.varint()
+		case typ == frameNewConnectionID:
+			// the connection keeps using the connection ID of the client, the migrations not being supported
+			p.varint()
+			p.varint()
+			id := p.vector(1)
+			p.bytes(16)
+			if p.err == nil && (len(id) == 0 || len(id) > maxConnectionIDLen) {
+				err = transportError(errorFrameEncoding, "invalid NEW_CONNECTION_ID")
+			}
+		case typ == frameRetireConnectionID:
+			p.varint()
+		case typ == framePathChallenge:
+			if data := p.bytes(8); p.err == nil {
+				c.pathResponses = append(c.pathResponses, data)
+			}
+		case typ == framePathResponse:
+			p.bytes(8)
+		case typ == frameConnectionClose || typ == frameApplicationClose:
+			code := p.varint()
+			if typ == frameConnectionClose {
+				p.varint()
+			}
+			reason := string(p.bytes(int(p.varint())))
+			if p.err == nil {
+				var closeErr error = &TransportError{Code: code, Reason: reason, Remote: true}
+				if typ == frameApplicationClose {
+					closeErr = &ApplicationError{Code: code, Reason: reason, Remote: true}
+				}
+				c.terminate(closeErr)
+				return false, nil
+			}
+		case typ == frameNewToken, typ == frameHandshakeDone:
+			err = transportError(errorProtocolViolation, "frame 0x%x sent by the client", typ)
+		default:
+			err = transportError(errorFrameEncoding, "unknown frame 0x%x", typ)
+		}
+		if err != nil {
+			return false, err
+		}
+		if p.err != nil {
+			break
+		}
+	}
+	if p.err != nil {
+		return false, transportError(errorFrameEncoding, "truncated frame")
+	}
+	return ackEliciting, nil
+}
+
+func (c *Conn) handleAck(lvl level, p *parser, ecn bool, now time.Time) error {
+	largest, delay, count, first := p.varint(), p.varint(), p.varint(), p.varint()
+	if p.err != nil {
+		return nil
+	}
+	if first > largest {
+		return transportError(errorFrameEncoding, "invalid ACK range")
+	}
+	ranges := []byteRange{{largest - first, largest + 1}}
+	smallest := largest - first
+	for i := uint64(0); i < count && p.err == nil; i++ {
+		gap, length := p.varint(), p.varint()
+		if smallest < gap+2 || smallest-gap-2 < length {
+			return transportError(errorFrameEncoding, "invalid ACK range")
+		}
+		end := smallest - gap - 2
+		smallest = end - length
+		ranges = append(ranges, byteRange{smallest, end + 1})
+	}
+	if ecn {
+		p.varint()
+		p.varint()
+		p.varint()
+	}
+	if p.err != nil {
+		return nil
+	}
+
+	space := c.spaces[lvl]
+	if largest >= space.nextPacketNumber {
+		return transportError(errorProtocolViolation, "acknowledgment of the unsent packet %d", largest)
+	}
+
+	var largestAcked *sentPacket
+	ackEliciting := false
+	kept := space.sent[:0]
+	for _, packet := range space.sent {
+		acked := false
+		for _, r := range ranges {
+			if r.start <= packet.number && packet.number < r.end {
+				acked = true
+				break
+			}
+		}
+		if !acked {
+			kept = append(kept, packet)
+			continue
+		}
+		c.congestion.onAcked(packet)
+		for _, frame := range packet.frames {
+			c.onFrameAcked(lvl, frame)
+		}
+		if packet.ackEliciting {
+			ackEliciting = true
+		}
+		if largestAcked == nil || packet.number > largestAcked.number {
+			largestAcked = packet
+		}
+	}
+	for i := len(kept); i < len(space.sent); i++ {
+		space.sent[i] = nil
+	}
+	space.sent = kept
+	if largestAcked == nil {
+		return nil
+	}
+
+	if int64(largest) > space.largestAcked {
+		space.largestAcked = int64(largest)
+	}
+	if largestAcked.number == largest && ackEliciting {
+		ackDelay := time.Duration(0)
+		if lvl == levelApplication && c.peerParams != nil {
+			ackDelay = time.Duration(delay<<c.peerParams.ackDelayExponent) * time.Microsecond
+		}
+		c.rtt.update(now.Sub(largestAcked.time), ackDelay, c.handshakeComplete)
+	}
+	c.ptoCount = 0
+	c.detectLostPackets(lvl, now)
+	return nil
+}
+
+// detectLostPackets declares lost the packets sent long enough before an acknowledged one (RFC 9002 section 6.1)
+func (c *Conn) detectLostPackets(lvl level, now time.Time) {
+	space := c.spaces[lvl]
+	space.lossTime = time.Time{}
+	if space.largestAcked < 0 {
+		return
+	}
+
+	lossDelay := c.rtt.lossDelay()
+	lostSendTime := now.Add(-lossDelay)
+	var kept []*sentPacket
+	for _, packet := range space.sent {
+		if int64(packet.number) > space.largestAcked {
+			kept = append(kept, packet)
+			continue
+		}
+		if !packet.time.After(lostSendTime) || space.largestAcked >= int64(packet.number)+packetThreshold {
+			c.congestion.onLost(packet, now)
+			for _, frame := range packet.frames {
+				c.onFrameLost(lvl, frame)
+			}
+			continue
+		}
+		if lossTime := packet.time.Add(lossDelay); space.lossTime.IsZero() || lossTime.Before(space.lossTime) {
+			space.lossTime = lossTime
+		}
+		kept = append(kept, packet)
+	}
+	space.sent = kept
+}
+
+// discardSpace discards the keys and the packets in flight of an encryption level
+func (c *Conn) discardSpace(lvl level) {
+	space := c.spaces[lvl]
+	if space.discarded {
+		return
+	}
+	for _, packet := range space.sent {
+		c.congestion.onDiscarded(packet)
+	}
+	*space = packetSpace{discarded: true, largestAcked: -1, largestReceived: -1}
+	c.ptoCount = 0
+}
+
+func (c *Conn) onFrameAcked(lvl level, frame sentFrame) {
+	s := frame.stream
+	switch frame.typ {
+	case frameCrypto:
+		c.spaces[lvl].cryptoSend.ack(frame.offset, frame.length, false)
+	case frameStream:
+		if s.writeErr != nil {
+			return
+		}
+		s.send.ack(frame.offset, frame.length, frame.fin)
+		s.cond.Broadcast()
+		if s.send.done() && !s.sendDone {
+			s.sendDone = true
+			c.checkStreamDone(s)
+		}
+	case frameResetStream:
+		if !s.sendDone {
+			s.sendDone = true
+			s.cond.Broadcast()
+			c.checkStreamDone(s)
+		}
+	}
+}
+
+func (c *Conn) onFrameLost(lvl level, frame sentFrame) {
+	s := frame.stream
+	switch frame.typ {
+	case frameCrypto:
+		c.spaces[lvl].cryptoSend.lose(frame.offset, frame.length, false)
+	case frameStream:
+		if s.writeErr == nil && !s.removed {
+			s.send.lose(frame.offset, frame.length, frame.fin)
+		}
+	case frameResetStream:
+		if !s.sendDone && !s.removed {
+			s.resetPending = true
+		}
+	case frameStopSending:
+		if !s.removed {
+			s.stopSending = true
+		}
+	case frameMaxStreamData:
+		if !s.recvDone && !s.removed {
+			s.sendMaxStreamData = true
+		}
+	case frameMaxData:
+		c.sendMaxData = true
+	case frameMaxStreamsBidi:
+		c.sendMaxStreamsBidi = true
+	case frameMaxStreamsUni:
+		c.sendMaxStreamsUni = true
+	case frameHandshakeDone:
+		c.sendHandshakeDone = true
+	}
+}
+
+func (c *Conn) handleCrypto(lvl level, offset uint64, data []byte, now time.Time) error {
+	space := c.spaces[lvl]
+	if offset+uint64(len(data)) > space.cryptoRecv.read+maxCryptoBuffer {
+		return transportError(errorCryptoBufferExceeded, "too much handshake data buffered")
+	}
+	if _, err := space.cryptoRecv.push(offset, data, false); err != nil {
+		return err
+	}
+
+	for {
+		buffer := space.cryptoRecv.readable()
+		if len(buffer) < 4 {
+			return nil
+		}
+		length := 4 + (int(buffer[1])<<16 | int(buffer[2])<<8 | int(buffer[3]))
+		if length > maxCryptoBuffer {
+			return transportError(errorCryptoBufferExceeded, "handshake message too large")
+		}
+		if len(buffer) < length {
+			return nil
+		}
+		message := append([]byte(nil), buffer[:length]...)
+		space.cryptoRecv.consume(length)
+		if err := c.handshake.handleMessage(lvl, message); err != nil {
+			return err
+		}
+		if err := c.onHandshakeProgress(now); err != nil {
+			return err
+		}
+		if space.discarded {
+			return nil
+		}
+	}
+}
+
+// onHandshakeProgress sends the handshake messages written by the handshake, and installs the keys it derived
+func (c *Conn) onHandshakeProgress(now time.Time) error {
+	h := c.handshake
+	for lvl, output := range h.output {
+		if len(output) > 0 {
+			c.spaces[lvl].cryptoSend.write(output)
+			h.output[lvl] = nil
+		}
+	}
+
+	if h.serverHandshakeSecret != nil && c.spaces[levelHandshake].writeKeys == nil {
+		params, err := parseClientTransportParameters(h.hello.transportParameters, c.destinationID)
+		if err != nil {
+			return err
+		}
+		c.peerParams = params
+		c.peerMaxData = params.initialMaxData
+		c.peerMaxStreamsUni = params.initialMaxStreamsUni
+		c.idleTimeout = c.config.MaxIdleTimeout
+		if params.maxIdleTimeout > 0 && params.maxIdleTimeout < c.idleTimeout {
+			c.idleTimeout = params.maxIdleTimeout
+		}
+
+		c.spaces[levelHandshake].readKeys = newPacketKeys(h.suite, h.clientHandshakeSecret)
+		c.spaces[levelHandshake].writeKeys = newPacketKeys(h.suite, h.serverHandshakeSecret)
+		c.spaces[levelApplication].readKeys = newPacketKeys(h.suite, h.clientApplicationSecret)
+		c.spaces[levelApplication].writeKeys = newPacketKeys(h.suite, h.serverApplicationSecret)
+	}
+
+	if h.complete && !c.handshakeComplete {
+		c.handshakeComplete = true
+		c.tlsState = h.connectionState()
+		c.sendHandshakeDone = true
+		c.discardSpace(levelHandshake)
+		if minimum := 3 * c.rtt.pto(levelApplication); c.idleTimeout < minimum {
+			c.idleTimeout = minimum
+		}
+		c.idleDeadline = now.Add(c.idleTimeout)
+		if !c.listener.queue(c) {
+			return transportError(errorConnectionRefused, "too many connections waiting to be accepted")
+		}
+	}
+	return nil
+}
+
+// getStream returns the stream of a frame received, opening the streams of the client up to it,
+// or nil if the stream is already closed
+func (c *Conn) getStream(id uint64, receiving bool) (*Stream, error) {
+	serverInitiated := id&0x01 != 0
+	unidirectional := id&0x02 != 0
+	if serverInitiated {
+		if !unidirectional || receiving || id >= c.nextServerStream {
+			return nil, transportError(errorStreamState, "invalid frame for stream %d", id)
+		}
+		return c.streams[id], nil
+	}
+	if unidirectional && !receiving {
+		return nil, transportError(errorStreamState, "invalid frame for the receive-only stream %d", id)
+	}
+
+	next, limit, queue := &c.nextStreamBidi, c.maxStreamsBidi, &c.acceptBidi
+	if unidirectional {
+		next, limit, queue = &c.nextStreamUni, c.maxStreamsUni, &c.acceptUni
+	}
+	if id/4 >= limit {
+		return nil, transportError(errorStreamLimit, "stream %d beyond the stream limit", id)
+	}
+	for ; *next <= id; *next += 4 {
+		sendLimit := uint64(0)
+		if !unidirectional {
+			sendLimit = c.peerParams.initialMaxStreamDataBidiLocal
+		}
+		s := newStream(c, *next, true, !unidirectional, sendLimit)
+		c.streams[s.id] = s
+		*queue = append(*queue, s)
+		c.acceptCond.Broadcast()
+	}
+	return c.streams[id], nil
+}
+
+// checkStreamDone removes a stream once both its directions are done, allowing the client to open another one
+func (c *Conn) checkStreamDone(s *Stream) {
+	if !s.recvDone || !s.sendDone || s.stopSending || s.resetPending || s.removed {
+		return
+	}
+	s.removed = true
+	s.cancel()
+	delete(c.streams, s.id)
+	if s.id&0x01 == 0 {
+		if s.id&0x02 == 0 {
+			c.maxStreamsBidi++
+			c.sendMaxStreamsBidi = true
+		} else {
+			c.maxStreamsUni++
+			c.sendMaxStreamsUni = true
+		}
+		c.wakeLocked()
+	}
+}
+
+func (c *Conn) handleStream(id, offset uint64, data []byte, fin bool) error {
+	s, err := c.getStream(id, true)
+	if err != nil || s == nil {
+		return err
+	}
+	return s.handleStreamFrame(offset, data, fin)
+}
+
+func (c *Conn) handleResetStream(id, code, finalSize uint64) error {
+	s, err := c.getStream(id, true)
+	if err != nil || s == nil {
+		return err
+	}
+	return s.handleResetStream(code, finalSize)
+}
+
+func (c *Conn) handleStopSending(id, code uint64) error {
+	s, err := c.getStream(id, false)
+	if err != nil || s == nil {
+		return err
+	}
+	s.handleStopSending(code)
+	return nil
+}
+
+func (c *Conn) handleMaxStreamData(id, limit uint64) error {
+	s, err := c.getStream(id, false)
+	if err != nil || s == nil {
+		return err
+	}
+	s.handleMaxStreamData(limit)
+	return nil
+}
+
+// onDataReceived checks the connection flow control after the highest offset received on a stream increased
+func (c *Conn) onDataReceived(increase uint64) error {
+	c.dataReceived += increase
+	if c.dataReceived > c.maxData {
+		return transportError(errorFlowControl, "data beyond the connection flow control limit")
+	}
+	return nil
+}
+
+// onDataConsumed extends the connection flow control limit once half of the window is consumed
+func (c *Conn) onDataConsumed(n uint64) {
+	c.dataConsumed += n
+	if c.maxData-c.dataConsumed < connectionReceiveWindow/2 {
+		c.maxData = c.dataConsumed + connectionReceiveWindow
+		c.sendMaxData = true
+		c.wakeLocked()
+	}
+}
+
+// onStreamRead extends the flow control limits of the stream and of the connection once half of their window is read
+func (c *Conn) onStreamRead(s *Stream, n uint64) {
+	if !s.recv.hasFinal && s.recvLimit-s.recv.read < streamReceiveWindow/2 {
+		s.recvLimit = s.recv.read + streamReceiveWindow
+		s.sendMaxStreamData = true
+		c.wakeLocked()
+	}
+	c.onDataConsumed(n)
+}
+
+// flush builds the datagrams to send, returning whether they carry the CONNECTION_CLOSE frame
+func (c *Conn) flush(now time.Time) ([][]byte, bool) {
+	if c.terminated {
+		return nil, false
+	}
+	if c.closing {
+		if !c.closePending {
+			return nil, false
+		}
+		c.closePending = false
+		return [][]byte{c.buildCloseDatagram()}, true
+	}
+
+	var datagrams [][]byte
+	for len(datagrams) < maxDatagramsPerFlush {
+		d := c.buildDatagram(now)
+		if d == nil {
+			break
+		}
+		datagrams = append(datagrams, d)
+	}
+	if len(datagrams) == maxDatagramsPerFlush {
+		c.wakeLocked()
+	}
+	return datagrams, false
+}
+
+func (c *Conn) buildCloseDatagram() []byte {
+	var datagram []byte
+	for lvl, space := range c.spaces {
+		if space.discarded || space.writeKeys == nil || level(lvl) == levelApplication && !c.handshakeComplete {
+			continue
+		}
+		packet := newOutPacket(level(lvl), c.destinationID, c.sourceID, c.keyPhase)
+		packet.payload = appendConnectionCloseFrame(nil, level(lvl), c.closeErr)
+		datagram = packet.seal(datagram, space.writeKeys, space.nextPacketNumber)
+		space.nextPacketNumber++
+	}
+	return datagram
+}
+
+// buildDatagram builds a datagram coalescing the packets of the encryption levels having frames to send
+func (c *Conn) buildDatagram(now time.Time) []byte {
+	if !c.addressValidated && amplificationFactor*c.bytesReceived-c.bytesSent < minInitialDatagramSize {
+		return nil
+	}
+
+	var packets []*outPacket
+	remaining := minInitialDatagramSize
+	pad := false
+	for lvl, space := range c.spaces {
+		if space.discarded || space.writeKeys == nil {
+			continue
+		}
+		packet := c.buildPacket(level(lvl), remaining, now)
+		if packet == nil {
+			continue
+		}
+		packets = append(packets, packet)
+		remaining -= packet.size(space.writeKeys.aead.Overhead())
+		if level(lvl) == levelInitial && packet.sent != nil {
+			pad = true
+		}
+	}
+	if len(packets) == 0 {
+		return nil
+	}
+	if pad && remaining > 0 {
+		// the datagrams carrying ack-eliciting Initial packets are expanded to the minimum size (RFC 9000 section 14.1)
+		last := packets[len(packets)-1]
+		last.payload = append(last.payload, make([]byte, remaining)...)
+	}
+
+	var datagram []byte
+	for _, packet := range packets {
+		space := c.spaces[packet.lvl]
+		start := len(datagram)
+		number := space.nextPacketNumber
+		space.nextPacketNumber++
+		datagram = packet.seal(datagram, space.writeKeys, number)
+		if sent := packet.sent; sent != nil {
+			sent.number = number
+			sent.time = now
+			sent.size = len(datagram) - start
+			space.sent = append(space.sent, sent)
+			space.lastAckElicitingSent = now
+			c.congestion.onSent(sent.size)
+		}
+	}
+	c.bytesSent += len(datagram)
+	return datagram
+}
+
+// buildPacket builds the packet of an encryption level carrying its frames to send, if any, in at most maxSize bytes
+func (c *Conn) buildPacket(lvl level, maxSize int, now time.Time) *outPacket {
+	space := c.spaces[lvl]
+	packet := newOutPacket(lvl, c.destinationID, c.sourceID, c.keyPhase)
+	budget := maxSize - packet.overhead(space.writeKeys.aead.Overhead())
+	if budget < 32 {
+		return nil
+	}
+
+	var ack []byte
+	if space.ackElicitingPending > 0 && len(space.received) > 0 {
+		ack = appendAckFrame(nil, space.received, uint64(now.Sub(space.largestReceivedTime)/time.Microsecond))
+		budget -= len(ack)
+	}
+
+	sent := &sentPacket{}
+	if space.probes > 0 || c.congestion.canSend(minInitialDatagramSize) {
+		packet.payload = c.appendFrames(lvl, packet.payload, sent, budget)
+		if space.probes > 0 && !sent.ackEliciting {
+			packet.payload = append(packet.payload, framePing)
+			sent.ackEliciting = true
+		}
+	}
+	if len(packet.payload) == 0 && (ack == nil || !space.ackNeeded(lvl, now)) {
+		return nil
+	}
+
+	if ack != nil {
+		packet.payload = append(ack, packet.payload...)
+		space.ackElicitingPending = 0
+		space.ackDeadline = time.Time{}
+	}
+	if sent.ackEliciting {
+		packet.sent = sent
+		if space.probes > 0 {
+			space.probes--
+		}
+	}
+	return packet
+}
+
+// appendFrames appends the frames to send at the encryption level, within budget bytes
+func (c *Conn) appendFrames(lvl level, b []byte, sent *sentPacket, budget int) []byte {
+	start := len(b)
+	space := c.spaces[lvl]
+	record := func(frame sentFrame) {
+		sent.frames = append(sent.frames, frame)
+		sent.ackEliciting = true
+	}
+	room := func() int {
+		return budget - (len(b) - start)
+	}
+
+	if lvl == levelApplication && c.sendHandshakeDone && room() >= 1 {
+		b = append(b, frameHandshakeDone)
+		record(sentFrame{typ: frameHandshakeDone})
+		c.sendHandshakeDone = false
+	}
+
+	for room() > cryptoFrameOverhead(MaxVarint) && space.cryptoSend.hasData(MaxVarint) {
+		offset, data, _, _, _ := space.cryptoSend.next(room()-cryptoFrameOverhead(MaxVarint), MaxVarint)
+		b = appendCryptoFrame(b, offset, data)
+		record(sentFrame{typ: frameCrypto, offset: offset, length: uint64(len(data))})
+	}
+	if lvl != levelApplication {
+		return b
+	}
+
+	for len(c.pathResponses) > 0 && room() >= 9 {
+		b = append(append(b, framePathResponse), c.pathResponses[0]...)
+		c.pathResponses = c.pathResponses[1:]
+		sent.ackEliciting = true
+	}
+	if c.sendMaxData && room() >= 9 {
+		b = AppendVarint(append(b, frameMaxData), c.maxData)
+		record(sentFrame{typ: frameMaxData})
+		c.sendMaxData = false
+	}
+	if c.sendMaxStreamsBidi && room() >= 9 {
+		b = AppendVarint(append(b, frameMaxStreamsBidi), c.maxStreamsBidi)
+		record(sentFrame{typ: frameMaxStreamsBidi})
+		c.sendMaxStreamsBidi = false
+	}
+	if c.sendMaxStreamsUni && room() >= 9 {
+		b = AppendVarint(append(b, frameMaxStreamsUni), c.maxStreamsUni)
+		record(sentFrame{typ: frameMaxStreamsUni})
+		c.sendMaxStreamsUni = false
+	}
+
+	for _, s := range c.streams {
+		if s.stopSending && room() >= 17 {
+			b = append(b, frameStopSending)
+			b = AppendVarint(AppendVarint(b, s.id), s.stopSendingCode)
+			record(sentFrame{typ: frameStopSending, stream: s})
+			s.stopSending = false
+		}
+		if s.sendMaxStreamData && room() >= 17 {
+			b = append(b, frameMaxStreamData)
+			b = AppendVarint(AppendVarint(b, s.id), s.recvLimit)
+			record(sentFrame{typ: frameMaxStreamData, stream: s})
+			s.sendMaxStreamData = false
+		}
+		if s.resetPending && room() >= 25 {
+			b = append(b, frameResetStream)
+			b = AppendVarint(AppendVarint(AppendVarint(b, s.id), s.resetCode), s.send.sent)
+			record(sentFrame{typ: frameResetStream, stream: s})
+			s.resetPending = false
+		}
+		if !s.stopSending && !s.resetPending {
+			c.checkStreamDone(s)
+		}
+
+		for s.writeErr == nil {
+			limit := s.sendLimit
+			if credit := s.send.sent + c.peerMaxData - c.dataSent; credit < limit {
+				limit = credit
+			}
+			overhead := streamFrameOverhead(s.id, MaxVarint)
+			if room() <= overhead || !s.send.hasData(limit) {
+				break
+			}
+			offset, data, fin, isNew, _ := s.send.next(room()-overhead, limit)
+			if isNew {
+				c.dataSent += uint64(len(data))
+			}
+			b = appendStreamFrame(b, s.id, offset, data, fin)
+			record(sentFrame{typ: frameStream, stream: s, offset: offset, length: uint64(len(data)), fin: fin})
+		}
+		if room() < 32 {
+			break
+		}
+	}
+	return b
+}
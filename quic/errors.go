@@ -0,0 +1,80 @@
+package quic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// transport error codes (RFC 9000 section 20.1)
+const (
+	errorNone                 = 0x00
+	errorInternal             = 0x01
+	errorConnectionRefused    = 0x02
+	errorFlowControl          = 0x03
+	errorStreamLimit          = 0x04
+	errorStreamState          = 0x05
+	errorFinalSize            = 0x06
+	errorFrameEncoding        = 0x07
+	errorTransportParameter   = 0x08
+	errorProtocolViolation    = 0x0a
+	errorApplication          = 0x0c
+	errorCryptoBufferExceeded = 0x0d
+	errorCrypto               = 0x100
+)
+
+// ErrIdleTimeout is the error of the connections closed after being idle for too long
+var ErrIdleTimeout = errors.New("quic: idle timeout")
+
+// ErrListenerClosed is returned by Accept once the listener is closed
+var ErrListenerClosed = errors.New("quic: listener closed")
+
+// TransportError is a QUIC transport error closing a connection
+type TransportError struct {
+	Code   uint64
+	Reason string
+	Remote bool // whether the error has been sent by the peer
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("quic: transport error 0x%x: %s", e.Code, e.Reason)
+}
+
+func transportError(code uint64, format string, args ...interface{}) *TransportError {
+	return &TransportError{Code: code, Reason: fmt.Sprintf(format, args...)}
+}
+
+// ApplicationError is an error of the application protocol closing a connection
+type ApplicationError struct {
+	Code   uint64
+	Reason string
+	Remote bool // whether the error has been sent by the peer
+}
+
+func (e *ApplicationError) Error() string {
+	return fmt.Sprintf("quic: application error 0x%x: %s", e.Code, e.Reason)
+}
+
+// StreamError is an error of the application protocol aborting one direction of a stream
+type StreamError struct {
+	Code   uint64
+	Remote bool // whether the stream has been aborted by the peer
+}
+
+func (e *StreamError) Error() string {
+	if e.Remote {
+		return fmt.Sprintf("quic: stream aborted by the peer with error 0x%x", e.Code)
+	}
+	return fmt.Sprintf("quic: stream aborted with error 0x%x", e.Code)
+}
+
+// toTransportError converts the errors raised while processing the packets of a connection to the error closing it
+func toTransportError(err error) *TransportError {
+	switch e := err.(type) {
+	case *TransportError:
+		return e
+	case *alertError:
+		return &TransportError{Code: errorCrypto + uint64(e.alert), Reason: e.reason}
+	default:
+		return &TransportError{Code: errorInternal, Reason: err.Error()}
+	}
+}
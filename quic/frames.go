@@ -0,0 +1,128 @@
+package quic
+
+// frame types (RFC 9000 section 19)
+const (
+	framePadding            = 0x00
+	framePing               = 0x01
+	frameAck                = 0x02
+	frameAckECN             = 0x03
+	frameResetStream        = 0x04
+	frameStopSending        = 0x05
+	frameCrypto             = 0x06
+	frameNewToken           = 0x07
+	frameStream             = 0x08 // to 0x0f, with the OFF, LEN and FIN bits
+	frameMaxData            = 0x10
+	frameMaxStreamData      = 0x11
+	frameMaxStreamsBidi     = 0x12
+	frameMaxStreamsUni      = 0x13
+	frameDataBlocked        = 0x14
+	frameStreamDataBlocked  = 0x15
+	frameStreamsBlockedBidi = 0x16
+	frameStreamsBlockedUni  = 0x17
+	frameNewConnectionID    = 0x18
+	frameRetireConnectionID = 0x19
+	framePathChallenge      = 0x1a
+	framePathResponse       = 0x1b
+	frameConnectionClose    = 0x1c
+	frameApplicationClose   = 0x1d
+	frameHandshakeDone      = 0x1e
+)
+
+// STREAM frame bits
+const (
+	streamFrameFin    = 0x01
+	streamFrameLength = 0x02
+	streamFrameOffset = 0x04
+)
+
+// maxAckRanges is the maximum number of ranges of received packet numbers acknowledged
+const maxAckRanges = 32
+
+// appendAckFrame appends an ACK frame acknowledging the ranges of packet numbers, the largest being received ackDelay
+// microseconds ago, encoded with the default ACK delay exponent of 3
+func appendAckFrame(b []byte, received rangeSet, ackDelay uint64) []byte {
+	last := received[len(received)-1]
+	b = append(b, frameAck)
+	b = AppendVarint(b, last.end-1)
+	b = AppendVarint(b, ackDelay>>3)
+	b = AppendVarint(b, uint64(len(received)-1))
+	b = AppendVarint(b, last.end-1-last.start)
+	for i := len(received) - 2; i >= 0; i-- {
+		b = AppendVarint(b, received[i+1].start-received[i].end-1)
+		b = AppendVarint(b, received[i].end-1-received[i].start)
+	}
+	return b
+}
+
+// sentFrame records a frame sent in a packet, to send it again or release its data once the packet is lost or acknowledged
+type sentFrame struct {
+	typ    uint8
+	stream *Stream
+	offset uint64
+	length uint64
+	fin    bool
+}
+
+// streamFrameOverhead returns the maximum size of a STREAM frame header, for a frame carrying less than 16383 bytes
+func streamFrameOverhead(id, offset uint64) int {
+	return 1 + VarintLen(id) + VarintLen(offset) + 2
+}
+
+func appendStreamFrame(b []byte, id, offset uint64, data []byte, fin bool) []byte {
+	typ := byte(frameStream | streamFrameLength)
+	if offset > 0 {
+		typ |= streamFrameOffset
+	}
+	if fin {
+		typ |= streamFrameFin
+	}
+	b = append(b, typ)
+	b = AppendVarint(b, id)
+	if offset > 0 {
+		b = AppendVarint(b, offset)
+	}
+	b = AppendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// cryptoFrameOverhead returns the maximum size of a CRYPTO frame header, for a frame carrying less than 16383 bytes
+func cryptoFrameOverhead(offset uint64) int {
+	return 1 + VarintLen(offset) + 2
+}
+
+func appendCryptoFrame(b []byte, offset uint64, data []byte) []byte {
+	b = append(b, frameCrypto)
+	b = AppendVarint(b, offset)
+	b = AppendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendConnectionCloseFrame(b []byte, lvl level, err error) []byte {
+	var code uint64
+	var reason string
+	application := false
+	switch e := err.(type) {
+	case *ApplicationError:
+		code, reason, application = e.Code, e.Reason, true
+	case *TransportError:
+		code, reason = e.Code, e.Reason
+	}
+	if len(reason) > 256 {
+		reason = reason[:256]
+	}
+
+	if application && lvl != levelApplication {
+		// the application errors are not sent before the handshake is complete, not to reveal the application state
+		code, reason, application = errorApplication, "", false
+	}
+	if application {
+		b = append(b, frameApplicationClose)
+		b = AppendVarint(b, code)
+	} else {
+		b = append(b, frameConnectionClose)
+		b = AppendVarint(b, code)
+		b = AppendVarint(b, 0) // frame type
+	}
+	b = AppendVarint(b, uint64(len(reason)))
+	return append(b, reason...)
+}
@@ -0,0 +1,652 @@
+package quic
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// versionTLS13 is the version of TLS securing the QUIC connections
+const versionTLS13 = 0x0304
+
+// TLS handshake message types
+const (
+	typeClientHello         = 1
+	typeServerHello         = 2
+	typeEncryptedExtensions = 8
+	typeCertificate         = 11
+	typeCertificateVerify   = 15
+	typeFinished            = 20
+	typeMessageHash         = 254
+)
+
+// TLS extensions
+const (
+	extensionServerName          = 0
+	extensionStatusRequest       = 5
+	extensionSupportedGroups     = 10
+	extensionSignatureAlgorithms = 13
+	extensionALPN                = 16
+	extensionSupportedVersions   = 43
+	extensionKeyShare            = 51
+	extensionTransportParameters = 57
+)
+
+// TLS alerts
+const (
+	alertUnexpectedMessage     = 10
+	alertHandshakeFailure      = 40
+	alertIllegalParameter      = 47
+	alertDecodeError           = 50
+	alertDecryptError          = 51
+	alertProtocolVersion       = 70
+	alertInternalError         = 80
+	alertMissingExtension      = 109
+	alertNoApplicationProtocol = 120
+)
+
+// key exchange groups
+const (
+	groupP256   = 23
+	groupX25519 = 29
+)
+
+// signature schemes
+const (
+	signatureECDSAP256SHA256 = 0x0403
+	signatureECDSAP384SHA384 = 0x0503
+	signatureECDSAP521SHA512 = 0x0603
+	signatureRSAPSSSHA256    = 0x0804
+	signatureRSAPSSSHA384    = 0x0805
+	signatureRSAPSSSHA512    = 0x0806
+)
+
+// helloRetryRequestRandom is the random of a ServerHello message making it a HelloRetryRequest
+var helloRetryRequestRandom = []byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11, 0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e, 0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
+// alertError is a TLS alert closing the connection with the CRYPTO_ERROR transport error of the alert
+type alertError struct {
+	alert  uint8
+	reason string
+}
+
+func (e *alertError) Error() string {
+	return fmt.Sprintf("TLS alert %d: %s", e.alert, e.reason)
+}
+
+func newAlert(alert uint8, format string, args ...interface{}) error {
+	return &alertError{alert: alert, reason: fmt.Sprintf(format, args...)}
+}
+
+type keyShare struct {
+	group uint16
+	data  []byte
+}
+
+type clientHello struct {
+	sessionID              []byte
+	cipherSuites           []uint16
+	serverName             string
+	statusRequest          bool
+	supportedGroups        []uint16
+	signatureAlgorithms    []uint16
+	protocols              []string
+	supportedVersions      []uint16
+	keyShares              []keyShare
+	transportParameters    []byte
+	hasTransportParameters bool
+}
+
+func parseClientHello(data []byte) (*clientHello, error) {
+	p := &parser{data: data}
+	hello := &clientHello{}
+	p.uint16() // legacy version
+	p.bytes(32)
+	hello.sessionID = p.vector(1)
+	for suites := p.sub(2); !suites.empty(); {
+		hello.cipherSuites = append(hello.cipherSuites, suites.uint16())
+	}
+	p.vector(1) // legacy compression methods
+	extensions := p.sub(2)
+	if p.err != nil || !p.empty() {
+		return nil, newAlert(alertDecodeError, "invalid ClientHello")
+	}
+
+	seen := make(map[uint16]bool)
+	for !extensions.empty() {
+		typ := extensions.uint16()
+		ext := extensions.sub(2)
+		if extensions.err != nil {
+			break
+		}
+		if seen[typ] {
+			return nil, newAlert(alertIllegalParameter, "duplicate extension %d", typ)
+		}
+		seen[typ] = true
+
+		switch typ {
+		case extensionServerName:
+			for names := ext.sub(2); !names.empty(); {
+				nameType, name := names.uint8(), names.vector(2)
+				if nameType == 0 && names.err == nil {
+					hello.serverName = strings.TrimSuffix(string(name), ".")
+				}
+				ext.err = names.err
+			}
+		case extensionStatusRequest:
+			hello.statusRequest = ext.uint8() == 1
+			ext.data = nil
+		case extensionSupportedGroups:
+			for groups := ext.sub(2); !groups.empty(); {
+				hello.supportedGroups = append(hello.supportedGroups, groups.uint16())
+			}
+		case extensionSignatureAlgorithms:
+			for algorithms := ext.sub(2); !algorithms.empty(); {
+				hello.signatureAlgorithms = append(hello.signatureAlgorithms, algorithms.uint16())
+			}
+		case extensionALPN:
+			for protocols := ext.sub(2); !protocols.empty(); {
+				protocol := protocols.vector(1)
+				if len(protocol) == 0 {
+					return nil, newAlert(alertDecodeError, "empty ALPN protocol")
+				}
+				hello.protocols = append(hello.protocols, string(protocol))
+			}
+		case extensionSupportedVersions:
+			for versions := ext.sub(1); !versions.empty(); {
+				hello.supportedVersions = append(hello.supportedVersions, versions.uint16())
+			}
+		case extensionKeyShare:
+			for shares := ext.sub(2); !shares.empty(); {
+				group, data := shares.uint16(), shares.vector(2)
+				hello.keyShares = append(hello.keyShares, keyShare{group: group, data: data})
+				ext.err = shares.err
+			}
+		case extensionTransportParameters:
+			hello.transportParameters = ext.data
+			hello.hasTransportParameters = true
+			ext.data = nil
+		default:
+			ext.data = nil
+		}
+		if ext.err != nil || !ext.empty() {
+			return nil, newAlert(alertDecodeError, "invalid extension %d", typ)
+		}
+	}
+	if extensions.err != nil {
+		return nil, newAlert(alertDecodeError, "invalid ClientHello extensions")
+	}
+	return hello, nil
+}
+
+// serverHandshake is the server side of the TLS 1.3 handshake of a QUIC connection (RFC 9001).
+// The handshake messages to send at each encryption level are appended to output,
+// and the traffic secrets are set as soon as they are known.
+type serverHandshake struct {
+	config              *tls.Config
+	transportParameters []byte
+
+	output [levelCount][]byte
+
+	suite      *cipherSuite
+	transcript hash.Hash
+	retried    bool
+	group      uint16
+
+	hello          *clientHello
+	protocol       string
+	certificate    *tls.Certificate
+	masterSecret   []byte
+	clientFinished []byte
+
+	clientHandshakeSecret, serverHandshakeSecret     []byte
+	clientApplicationSecret, serverApplicationSecret []byte
+	complete                                         bool
+}
+
+func newServerHandshake(config *tls.Config, transportParameters []byte) *serverHandshake {
+	return &serverHandshake{config: config, transportParameters: transportParameters}
+}
+
+// handleMessage processes a handshake message received at the given encryption level
+func (h *serverHandshake) handleMessage(lvl level, message []byte) error {
+	switch {
+	case message[0] == typeClientHello && lvl == levelInitial && h.serverHandshakeSecret == nil:
+		return h.handleClientHello(message)
+	case message[0] == typeFinished && lvl == levelHandshake && h.clientFinished != nil && !h.complete:
+		return h.handleFinished(message)
+	default:
+		return newAlert(alertUnexpectedMessage, "unexpected handshake message %d", message[0])
+	}
+}
+
+func (h *serverHandshake) handleClientHello(message []byte) error {
+	hello, err := parseClientHello(message[4:])
+	if err != nil {
+		return err
+	}
+
+	if !containsUint16(hello.supportedVersions, versionTLS13) {
+		return newAlert(alertProtocolVersion, "TLS 1.3 is not supported by the client")
+	}
+	if !hello.hasTransportParameters {
+		return newAlert(alertMissingExtension, "missing QUIC transport parameters")
+	}
+
+	suite := h.suite
+	if suite == nil {
+		for _, id := range hello.cipherSuites {
+			if suite = cipherSuiteByID(id); suite != nil {
+				break
+			}
+		}
+		if suite == nil {
+			return newAlert(alertHandshakeFailure, "no supported cipher suite")
+		}
+	} else if !containsUint16(hello.cipherSuites, suite.id) {
+		return newAlert(alertIllegalParameter, "cipher suite changed after HelloRetryRequest")
+	}
+
+	// the protocol is negotiated before sending a HelloRetryRequest in order not to retry a handshake bound to fail
+	protocol := ""
+	for _, supported := range h.config.NextProtos {
+		if containsString(hello.protocols, supported) {
+			protocol = supported
+			break
+		}
+	}
+	if len(protocol) == 0 {
+		return newAlert(alertNoApplicationProtocol, "no supported application protocol in %v", hello.protocols)
+	}
+
+	var share *keyShare
+	for i := range hello.keyShares {
+		group := hello.keyShares[i].group
+		if (group == groupX25519 || group == groupP256) && (!h.retried || group == h.group) {
+			share = &hello.keyShares[i]
+			break
+		}
+	}
+	if share == nil {
+		if h.retried {
+			return newAlert(alertIllegalParameter, "no key share for the group selected by the HelloRetryRequest")
+		}
+		for _, group := range hello.supportedGroups {
+			if group == groupX25519 || group == groupP256 {
+				return h.sendHelloRetryRequest(message, suite, hello, group)
+			}
+		}
+		return newAlert(alertHandshakeFailure, "no supported key exchange group")
+	}
+
+	if h.transcript == nil {
+		h.transcript = suite.hash.New()
+	}
+	h.suite = suite
+	h.hello = hello
+	h.protocol = protocol
+	h.transcript.Write(message)
+
+	h.certificate, err = h.getCertificate()
+	if err != nil {
+		return newAlert(alertHandshakeFailure, "no certificate: %v", err)
+	}
+	signer, ok := h.certificate.PrivateKey.(crypto.Signer)
+	if !ok {
+		return newAlert(alertInternalError, "unsupported private key %T", h.certificate.PrivateKey)
+	}
+	algorithm, signatureHash := signatureAlgorithm(signer.Public(), hello.signatureAlgorithms)
+	if algorithm == 0 {
+		return newAlert(alertHandshakeFailure, "no supported signature algorithm")
+	}
+
+	public, shared, err := keyExchange(share)
+	if err != nil {
+		return err
+	}
+
+	h.writeMessage(levelInitial, typeServerHello, func(b []byte) []byte {
+		return h.appendServerHello(b, serverRandom(), func(b []byte) []byte {
+			b = appendUint(b, uint64(share.group), 2)
+			return appendVector(b, 2, func(b []byte) []byte {
+				return append(b, public...)
+			})
+		})
+	})
+
+	hashLen := suite.hash.Size()
+	earlySecret := hkdfExtract(suite.hash, make([]byte, hashLen), nil)
+	handshakeSecret := hkdfExtract(suite.hash, shared, h.deriveSecret(earlySecret, "derived", true))
+	h.clientHandshakeSecret = h.deriveSecret(handshakeSecret, "c hs traffic", false)
+	h.serverHandshakeSecret = h.deriveSecret(handshakeSecret, "s hs traffic", false)
+	h.masterSecret = hkdfExtract(suite.hash, make([]byte, hashLen), h.deriveSecret(handshakeSecret, "derived", true))
+
+	h.writeMessage(levelHandshake, typeEncryptedExtensions, func(b []byte) []byte {
+		return appendVector(b, 2, func(b []byte) []byte {
+			b = appendUint(b, extensionALPN, 2)
+			b = appendVector(b, 2, func(b []byte) []byte {
+				return appendVector(b, 2, func(b []byte) []byte {
+					return appendVector(b, 1, func(b []byte) []byte {
+						return append(b, protocol...)
+					})
+				})
+			})
+			b = appendUint(b, extensionTransportParameters, 2)
+			return appendVector(b, 2, func(b []byte) []byte {
+				return append(b, h.transportParameters...)
+			})
+		})
+	})
+
+	h.writeMessage(levelHandshake, typeCertificate, func(b []byte) []byte {
+		b = append(b, 0) // certificate request context
+		return appendVector(b, 3, func(b []byte) []byte {
+			for i, certificate := range h.certificate.Certificate {
+				b = appendVector(b, 3, func(b []byte) []byte {
+					return append(b, certificate...)
+				})
+				b = appendVector(b, 2, func(b []byte) []byte {
+					if i > 0 || !hello.statusRequest || len(h.certificate.OCSPStaple) == 0 {
+						return b
+					}
+					b = appendUint(b, extensionStatusRequest, 2)
+					return appendVector(b, 2, func(b []byte) []byte {
+						b = append(b, 1) // OCSP
+						return appendVector(b, 3, func(b []byte) []byte {
+							return append(b, h.certificate.OCSPStaple...)
+						})
+					})
+				})
+			}
+			return b
+		})
+	})
+
+	signed := bytes.Repeat([]byte{0x20}, 64)
+	signed = append(signed, "TLS 1.3, server CertificateVerify\x00"...)
+	signed = append(signed, h.transcript.Sum(nil)...)
+	digest := signatureHash.New()
+	digest.Write(signed)
+	var options crypto.SignerOpts = signatureHash
+	if _, ok := signer.Public().(*rsa.PublicKey); ok {
+		options = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: signatureHash}
+	}
+	signature, err := signer.Sign(rand.Reader, digest.Sum(nil), options)
+	if err != nil {
+		return newAlert(alertInternalError, "signing the handshake: %v", err)
+	}
+	h.writeMessage(levelHandshake, typeCertificateVerify, func(b []byte) []byte {
+		b = appendUint(b, uint64(algorithm), 2)
+		return appendVector(b, 2, func(b []byte) []byte {
+			return append(b, signature...)
+		})
+	})
+
+	h.writeMessage(levelHandshake, typeFinished, func(b []byte) []byte {
+		return append(b, h.finished(h.serverHandshakeSecret)...)
+	})
+
+	h.clientApplicationSecret = h.deriveSecret(h.masterSecret, "c ap traffic", false)
+	h.serverApplicationSecret = h.deriveSecret(h.masterSecret, "s ap traffic", false)
+	h.clientFinished = h.finished(h.clientHandshakeSecret)
+	return nil
+}
+
+// sendHelloRetryRequest asks the client to send a key share for the group, replacing the ClientHello
+// in the transcript by its hash (RFC 8446 section 4.4.1)
+func (h *serverHandshake) sendHelloRetryRequest(message []byte, suite *cipherSuite, hello *clientHello, group uint16) error {
+	helloHash := suite.hash.New()
+	helloHash.Write(message)
+	h.transcript = suite.hash.New()
+	h.transcript.Write([]byte{typeMessageHash, 0, 0, byte(suite.hash.Size())})
+	h.transcript.Write(helloHash.Sum(nil))
+
+	h.suite = suite
+	h.hello = hello
+	h.retried = true
+	h.group = group
+	h.writeMessage(levelInitial, typeServerHello, func(b []byte) []byte {
+		return h.appendServerHello(b, helloRetryRequestRandom, func(b []byte) []byte {
+			return appendUint(b, uint64(group), 2)
+		})
+	})
+	h.hello = nil
+	return nil
+}
+
+func (h *serverHandshake) appendServerHello(b []byte, random []byte, appendKeyShare func([]byte) []byte) []byte {
+	b = appendUint(b, tls.VersionTLS12, 2)
+	b = append(b, random...)
+	b = appendVector(b, 1, func(b []byte) []byte {
+		return append(b, h.hello.sessionID...)
+	})
+	b = appendUint(b, uint64(h.suite.id), 2)
+	b = append(b, 0) // compression method
+	return appendVector(b, 2, func(b []byte) []byte {
+		b = appendUint(b, extensionSupportedVersions, 2)
+		b = appendUint(b, 2, 2)
+		b = appendUint(b, versionTLS13, 2)
+		b = appendUint(b, extensionKeyShare, 2)
+		return appendVector(b, 2, appendKeyShare)
+	})
+}
+
+func (h *serverHandshake) handleFinished(message []byte) error {
+	if !hmac.Equal(message[4:], h.clientFinished) {
+		return newAlert(alertDecryptError, "invalid client Finished")
+	}
+	h.transcript.Write(message)
+	h.complete = true
+	return nil
+}
+
+// writeMessage appends the handshake message to the output of the level, and to the transcript
+func (h *serverHandshake) writeMessage(lvl level, typ uint8, fill func([]byte) []byte) {
+	message := appendVector([]byte{typ}, 3, fill)
+	h.transcript.Write(message)
+	h.output[lvl] = append(h.output[lvl], message...)
+}
+
+// deriveSecret is the Derive-Secret function of TLS 1.3, using the hash of the transcript or of an empty one
+func (h *serverHandshake) deriveSecret(secret []byte, label string, empty bool) []byte {
+	transcript := h.transcript
+	if empty {
+		transcript = h.suite.hash.New()
+	}
+	return hkdfExpandLabel(h.suite.hash, secret, label, transcript.Sum(nil), h.suite.hash.Size())
+}
+
+// finished returns the verify data of the Finished message sent with the traffic secret
+func (h *serverHandshake) finished(secret []byte) []byte {
+	key := hkdfExpandLabel(h.suite.hash, secret, "finished", nil, h.suite.hash.Size())
+	mac := hmac.New(h.suite.hash.New, key)
+	mac.Write(h.transcript.Sum(nil))
+	return mac.Sum(nil)
+}
+
+// getCertificate selects the certificate of the server name like crypto/tls
+func (h *serverHandshake) getCertificate() (*tls.Certificate, error) {
+	hello := h.hello
+	config := h.config
+	if config.GetCertificate != nil && (len(config.Certificates) == 0 || len(hello.serverName) > 0) {
+		curves := make([]tls.CurveID, len(hello.supportedGroups))
+		for i, group := range hello.supportedGroups {
+			curves[i] = tls.CurveID(group)
+		}
+		schemes := make([]tls.SignatureScheme, len(hello.signatureAlgorithms))
+		for i, algorithm := range hello.signatureAlgorithms {
+			schemes[i] = tls.SignatureScheme(algorithm)
+		}
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{
+			CipherSuites:      hello.cipherSuites,
+			ServerName:        hello.serverName,
+			SupportedCurves:   curves,
+			SignatureSchemes:  schemes,
+			SupportedProtos:   hello.protocols,
+			SupportedVersions: hello.supportedVersions,
+		})
+		if cert != nil || err != nil {
+			return cert, err
+		}
+	}
+
+	if len(config.Certificates) == 0 {
+		return nil, errors.New("no certificate configured")
+	}
+	if len(config.Certificates) == 1 || config.NameToCertificate == nil {
+		return &config.Certificates[0], nil
+	}
+	name := strings.ToLower(hello.serverName)
+	if cert, ok := config.NameToCertificate[name]; ok {
+		return cert, nil
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) > 1 {
+		labels[0] = "*"
+		if cert, ok := config.NameToCertificate[strings.Join(labels, ".")]; ok {
+			return cert, nil
+		}
+	}
+	return &config.Certificates[0], nil
+}
+
+// connectionState returns the state of the TLS connection once the handshake is complete
+func (h *serverHandshake) connectionState() tls.ConnectionState {
+	state := tls.ConnectionState{
+		Version:                    versionTLS13,
+		HandshakeComplete:          true,
+		CipherSuite:                h.suite.id,
+		NegotiatedProtocol:         h.protocol,
+		NegotiatedProtocolIsMutual: true,
+		ServerName:                 h.hello.serverName,
+	}
+	if h.hello.statusRequest {
+		state.OCSPResponse = h.certificate.OCSPStaple
+	}
+	return state
+}
+
+// signatureAlgorithm returns the signature algorithm of the key supported by the client, and its hash
+func signatureAlgorithm(public crypto.PublicKey, supported []uint16) (uint16, crypto.Hash) {
+	var candidates []uint16
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		candidates = []uint16{signatureRSAPSSSHA256, signatureRSAPSSSHA384, signatureRSAPSSSHA512}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			candidates = []uint16{signatureECDSAP256SHA256}
+		case elliptic.P384():
+			candidates = []uint16{signatureECDSAP384SHA384}
+		case elliptic.P521():
+			candidates = []uint16{signatureECDSAP521SHA512}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if !containsUint16(supported, candidate) {
+			continue
+		}
+		switch candidate {
+		case signatureECDSAP256SHA256, signatureRSAPSSSHA256:
+			return candidate, crypto.SHA256
+		case signatureECDSAP384SHA384, signatureRSAPSSSHA384:
+			return candidate, crypto.SHA384
+		default:
+			return candidate, crypto.SHA512
+		}
+	}
+	return 0, 0
+}
+
+// keyExchange returns the public key of the server and the shared secret of the key share of the client
+func keyExchange(share *keyShare) (public, shared []byte, err error) {
+	switch share.group {
+	case groupX25519:
+		if len(share.data) != 32 {
+			return nil, nil, newAlert(alertIllegalParameter, "invalid X25519 key share")
+		}
+		var private, peer, publicKey, sharedKey [32]byte
+		if _, err := io.ReadFull(rand.Reader, private[:]); err != nil {
+			return nil, nil, newAlert(alertInternalError, "generating the key: %v", err)
+		}
+		copy(peer[:], share.data)
+		curve25519.ScalarBaseMult(&publicKey, &private)
+		curve25519.ScalarMult(&sharedKey, &private, &peer)
+		if sharedKey == [32]byte{} {
+			return nil, nil, newAlert(alertIllegalParameter, "invalid X25519 key share")
+		}
+		return publicKey[:], sharedKey[:], nil
+	default:
+		curve := elliptic.P256()
+		x, y := elliptic.Unmarshal(curve, share.data)
+		if x == nil || !curve.IsOnCurve(x, y) {
+			return nil, nil, newAlert(alertIllegalParameter, "invalid P-256 key share")
+		}
+		private, publicX, publicY, err := elliptic.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, newAlert(alertInternalError, "generating the key: %v", err)
+		}
+		sharedX, _ := curve.ScalarMult(x, y, private)
+		return elliptic.Marshal(curve, publicX, publicY), padBigInt(sharedX, 32), nil
+	}
+}
+
+func padBigInt(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func serverRandom() []byte {
+	random := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		panic(err)
+	}
+	return random
+}
+
+func cipherSuiteByID(id uint16) *cipherSuite {
+	for _, suite := range cipherSuites {
+		if suite.id == id {
+			return suite
+		}
+	}
+	return nil
+}
+
+func containsUint16(values []uint16, value uint16) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package quic
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	_ "crypto/sha256" // registers SHA-256 for the TLS_AES_128_GCM_SHA256 cipher suite
+	_ "crypto/sha512" // registers SHA-384 for the TLS_AES_256_GCM_SHA384 cipher suite
+)
+
+// initialSalt is the salt deriving the secrets of the Initial packets of QUIC version 1 (RFC 9001 section 5.2)
+var initialSalt = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+
+// cipherSuite is a TLS 1.3 cipher suite protecting the QUIC packets
+type cipherSuite struct {
+	id     uint16
+	hash   crypto.Hash
+	keyLen int
+}
+
+// cipherSuites are the supported TLS 1.3 cipher suites, in order of preference
+var cipherSuites = []*cipherSuite{
+	{id: 0x1301, hash: crypto.SHA256, keyLen: 16}, // TLS_AES_128_GCM_SHA256
+	{id: 0x1302, hash: crypto.SHA384, keyLen: 32}, // TLS_AES_256_GCM_SHA384
+}
+
+func hkdfExtract(hash crypto.Hash, secret, salt []byte) []byte {
+	if salt == nil {
+		salt = make([]byte, hash.Size())
+	}
+	mac := hmac.New(hash.New, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(hash crypto.Hash, secret, info []byte, length int) []byte {
+	var out, block []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		mac := hmac.New(hash.New, secret)
+		mac.Write(block)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		block = mac.Sum(nil)
+		out = append(out, block...)
+	}
+	return out[:length]
+}
+
+// hkdfExpandLabel is the HKDF-Expand-Label function of TLS 1.3 (RFC 8446 section 7.1)
+func hkdfExpandLabel(hash crypto.Hash, secret []byte, label string, context []byte, length int) []byte {
+	info := appendUint(nil, uint64(length), 2)
+	info = appendVector(info, 1, func(b []byte) []byte {
+		return append(append(b, "tls13 "...), label...)
+	})
+	info = appendVector(info, 1, func(b []byte) []byte {
+		return append(b, context...)
+	})
+	return hkdfExpand(hash, secret, info, length)
+}
+
+// initialSecrets returns the secrets of the Initial packets sent by the client and by the server
+func initialSecrets(destinationConnectionID []byte) (client, server []byte) {
+	secret := hkdfExtract(crypto.SHA256, destinationConnectionID, initialSalt)
+	client = hkdfExpandLabel(crypto.SHA256, secret, "client in", nil, crypto.SHA256.Size())
+	server = hkdfExpandLabel(crypto.SHA256, secret, "server in", nil, crypto.SHA256.Size())
+	return client, server
+}
+
+// packetKeys protect the packets sent in one direction at one encryption level
+type packetKeys struct {
+	suite  *cipherSuite
+	secret []byte
+	aead   cipher.AEAD
+	iv     []byte
+	hp     cipher.Block
+}
+
+func newPacketKeys(suite *cipherSuite, secret []byte) *packetKeys {
+	hp, err := aes.NewCipher(hkdfExpandLabel(suite.hash, secret, "quic hp", nil, suite.keyLen))
+	if err != nil {
+		panic(err)
+	}
+	return newPacketKeysWithHeaderProtection(suite, secret, hp)
+}
+
+func newPacketKeysWithHeaderProtection(suite *cipherSuite, secret []byte, hp cipher.Block) *packetKeys {
+	block, err := aes.NewCipher(hkdfExpandLabel(suite.hash, secret, "quic key", nil, suite.keyLen))
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &packetKeys{
+		suite:  suite,
+		secret: secret,
+		aead:   aead,
+		iv:     hkdfExpandLabel(suite.hash, secret, "quic iv", nil, aead.NonceSize()),
+		hp:     hp,
+	}
+}
+
+// next returns the keys of the next key phase, the header protection key being unchanged (RFC 9001 section 6)
+func (k *packetKeys) next() *packetKeys {
+	secret := hkdfExpandLabel(k.suite.hash, k.secret, "quic ku", nil, k.suite.hash.Size())
+	return newPacketKeysWithHeaderProtection(k.suite, secret, k.hp)
+}
+
+func (k *packetKeys) nonce(packetNumber uint64) []byte {
+	nonce := make([]byte, len(k.iv))
+	copy(nonce, k.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(packetNumber >> (8 * uint(i)))
+	}
+	return nonce
+}
+
+// mask returns the header protection mask computed from the sample of the packet ciphertext
+func (k *packetKeys) mask(sample []byte) []byte {
+	mask := make([]byte, aes.BlockSize)
+	k.hp.Encrypt(mask, sample)
+	return mask
+}
+
+// protectHeader applies the header protection of a packet whose packet number starts at pnOffset
+func (k *packetKeys) protectHeader(packet []byte, pnOffset int) {
+	mask := k.mask(packet[pnOffset+4 : pnOffset+4+aes.BlockSize])
+	pnLen := int(packet[0]&0x03) + 1
+	packet[0] ^= mask[0] & headerProtectionBits(packet[0])
+	for i := 0; i < pnLen; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+}
+
+// unprotectHeader removes the header protection of a packet whose packet number starts at pnOffset,
+// returning the length of the packet number
+func (k *packetKeys) unprotectHeader(packet []byte, pnOffset int) int {
+	mask := k.mask(packet[pnOffset+4 : pnOffset+4+aes.BlockSize])
+	packet[0] ^= mask[0] & headerProtectionBits(packet[0])
+	pnLen := int(packet[0]&0x03) + 1
+	for i := 0; i < pnLen; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+	return pnLen
+}
+
+// headerProtectionBits returns the bits of the first byte of a packet protected by the header protection
+func headerProtectionBits(first byte) byte {
+	if first&0x80 != 0 {
+		return 0x0f
+	}
+	return 0x1f
+}
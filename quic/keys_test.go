@@ -0,0 +1,98 @@
+package quic
+
+import (
+	"crypto"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// the test vectors of RFC 9001 appendix A
+func TestInitialKeys(t *testing.T) {
+	clientSecret, serverSecret := initialSecrets(mustDecodeHex(t, "8394c8f03e515708"))
+
+	testCases := []struct {
+		desc           string
+		secret         []byte
+		expectedSecret string
+		expectedKey    string
+		expectedIV     string
+		sample         string
+		expectedMask   string
+	}{
+		{
+			desc:           "client",
+			secret:         clientSecret,
+			expectedSecret: "c00cf151ca5be075ed0ebfb5c80323c42d6b7db67881289af4008f1f6c357aea",
+			expectedKey:    "1f369613dd76d5467730efcbe3b1a22d",
+			expectedIV:     "fa044b2f42a3fd3b46fb255c",
+			sample:         "d1b1c98dd7689fb8ec11d242b123dc9b",
+			expectedMask:   "437b9aec36",
+		},
+		{
+			desc:           "server",
+			secret:         serverSecret,
+			expectedSecret: "3c199828fd139efd216c155ad844cc81fb82fa8d7446fa7d78be803acdda951b",
+			expectedKey:    "cf3a5331653c364c88f0f379b6067e37",
+			expectedIV:     "0ac1493ca1905853b0bba03e",
+			sample:         "2cd0991cd25b0aac406a5816b6394100",
+			expectedMask:   "2ec0d8356a",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedSecret, hex.EncodeToString(test.secret))
+			assert.Equal(t, test.expectedKey, hex.EncodeToString(hkdfExpandLabel(crypto.SHA256, test.secret, "quic key", nil, 16)))
+
+			keys := newPacketKeys(cipherSuites[0], test.secret)
+			assert.Equal(t, test.expectedIV, hex.EncodeToString(keys.iv))
+			assert.Equal(t, test.expectedMask, hex.EncodeToString(keys.mask(mustDecodeHex(t, test.sample))[:5]))
+		})
+	}
+}
+
+func TestHeaderProtection(t *testing.T) {
+	clientSecret, _ := initialSecrets(mustDecodeHex(t, "8394c8f03e515708"))
+	keys := newPacketKeys(cipherSuites[0], clientSecret)
+
+	// the header of the Initial packet of the client of RFC 9001 appendix A.2, followed by the sample of its payload
+	header := "c300000001088394c8f03e5157080000449e00000002"
+	packet := mustDecodeHex(t, header+"d1b1c98dd7689fb8ec11d242b123dc9b")
+	pnOffset := 18
+
+	keys.protectHeader(packet, pnOffset)
+	assert.Equal(t, "c000000001088394c8f03e5157080000449e7b9aec34", hex.EncodeToString(packet[:len(header)/2]))
+
+	pnLen := keys.unprotectHeader(packet, pnOffset)
+	assert.Equal(t, 4, pnLen)
+	assert.Equal(t, header, hex.EncodeToString(packet[:len(header)/2]))
+}
+
+func TestPacketKeysNonce(t *testing.T) {
+	clientSecret, _ := initialSecrets(mustDecodeHex(t, "8394c8f03e515708"))
+	keys := newPacketKeys(cipherSuites[0], clientSecret)
+
+	assert.Equal(t, "fa044b2f42a3fd3b46fb255e", hex.EncodeToString(keys.nonce(2)))
+}
+
+// the key update test vector of RFC 9001 appendix A.5
+func TestPacketKeysNext(t *testing.T) {
+	secret := mustDecodeHex(t, "9ac312a7f877468ebe69422748ad00a15443f18203a07d6060f688f30f21632b")
+	keys := newPacketKeys(cipherSuites[0], secret)
+
+	next := keys.next()
+	assert.Equal(t, "1223504755036d556342ee9361d253421a826c9ecdf3c7148684b36b714881f9", hex.EncodeToString(next.secret))
+	assert.Equal(t, keys.hp, next.hp)
+}
@@ -0,0 +1,202 @@
+package quic
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// maxPendingConns is the maximum number of connections established and waiting to be accepted
+const maxPendingConns = 128
+
+// Config holds the options of the connections of a Listener, the zero values falling back to the defaults
+type Config struct {
+	MaxIdleTimeout        time.Duration // defaults to 30s
+	HandshakeTimeout      time.Duration // defaults to 10s
+	MaxIncomingStreams    uint64        // the number of concurrent bidirectional streams of a client, defaults to 100
+	MaxIncomingUniStreams uint64        // the number of concurrent unidirectional streams of a client, defaults to 16
+}
+
+// Listener is a QUIC server accepting connections on a UDP socket
+type Listener struct {
+	conn      net.PacketConn
+	tlsConfig *tls.Config
+	config    Config
+
+	mu      sync.Mutex
+	conns   map[string]*Conn // the connections by their connection ID, and the original one chosen by the client
+	pending chan *Conn
+	closed  bool
+	done    chan struct{}
+}
+
+// Listen starts accepting QUIC connections on the socket, the TLS handshake using tlsConfig.
+// The client certificates are not supported.
+func Listen(conn net.PacketConn, tlsConfig *tls.Config, config *Config) (*Listener, error) {
+	if tlsConfig == nil || len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil {
+		return nil, errors.New("quic: no certificate in the TLS configuration")
+	}
+	if tlsConfig.ClientAuth > tls.NoClientCert {
+		return nil, errors.New("quic: client certificates are not supported")
+	}
+	if len(tlsConfig.NextProtos) == 0 {
+		return nil, errors.New("quic: no application protocol in the TLS configuration")
+	}
+
+	l := &Listener{
+		conn:      conn,
+		tlsConfig: tlsConfig,
+		conns:     make(map[string]*Conn),
+		pending:   make(chan *Conn, maxPendingConns),
+		done:      make(chan struct{}),
+	}
+	if config != nil {
+		l.config = *config
+	}
+	if l.config.MaxIdleTimeout <= 0 {
+		l.config.MaxIdleTimeout = 30 * time.Second
+	}
+	if l.config.HandshakeTimeout <= 0 {
+		l.config.HandshakeTimeout = 10 * time.Second
+	}
+	if l.config.MaxIncomingStreams == 0 {
+		l.config.MaxIncomingStreams = 100
+	}
+	if l.config.MaxIncomingUniStreams == 0 {
+		l.config.MaxIncomingUniStreams = 16
+	}
+
+	go l.serve()
+	return l, nil
+}
+
+// Accept waits for and returns the next connection whose handshake is complete
+func (l *Listener) Accept() (*Conn, error) {
+	select {
+	case c := <-l.pending:
+		return c, nil
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+// Close closes the connections and the socket of the listener
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.done)
+	var conns []*Conn
+	for id, c := range l.conns {
+		if id == string(c.sourceID) {
+			conns = append(conns, c)
+		}
+	}
+	l.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *Conn) {
+			defer wg.Done()
+			c.CloseWithError(0, "")
+		}(c)
+	}
+	wg.Wait()
+	return l.conn.Close()
+}
+
+// Addr returns the local address of the socket of the listener
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// serve reads the datagrams of the socket, dispatching them to the connections by their destination connection ID
+func (l *Listener) serve() {
+	buffer := make([]byte, 1<<16)
+	for {
+		n, addr, err := l.conn.ReadFrom(buffer)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			select {
+			case <-l.done:
+			default:
+				log.Errorf("Error reading from the QUIC socket %s: %v", l.Addr(), err)
+			}
+			return
+		}
+
+		data := append([]byte(nil), buffer[:n]...)
+		h, err := parseHeader(data)
+		if err != nil {
+			continue
+		}
+
+		l.mu.Lock()
+		c := l.conns[string(h.destinationID)]
+		if c == nil && !l.closed {
+			c = l.newConn(h, data, addr)
+		}
+		l.mu.Unlock()
+
+		if c != nil {
+			select {
+			case c.incoming <- &datagram{data: data, addr: addr}:
+			default:
+				// the connection is overloaded, the datagram is dropped as if it were lost
+			}
+		}
+	}
+}
+
+// newConn creates the connection of a datagram which does not belong to any, if it starts one
+func (l *Listener) newConn(h *header, data []byte, addr net.Addr) *Conn {
+	if len(data) < minInitialDatagramSize || !h.long {
+		return nil
+	}
+	if h.unsupportedVersion {
+		if _, err := l.conn.WriteTo(appendVersionNegotiation(nil, h), addr); err != nil {
+			log.Debugf("Error sending QUIC version negotiation to %s: %v", addr, err)
+		}
+		return nil
+	}
+	if h.packetType != packetTypeInitial || len(h.destinationID) < connectionIDLen {
+		return nil
+	}
+
+	c := newConn(l, h, addr, time.Now())
+	l.conns[string(c.sourceID)] = c
+	l.conns[string(c.originalDestinationID)] = c
+	go c.run()
+	return c
+}
+
+// remove forgets a terminated connection
+func (l *Listener) remove(c *Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range [][]byte{c.sourceID, c.originalDestinationID} {
+		if l.conns[string(id)] == c {
+			delete(l.conns, string(id))
+		}
+	}
+}
+
+// queue makes a connection whose handshake is complete available to Accept, returning false if too many are waiting
+func (l *Listener) queue(c *Conn) bool {
+	select {
+	case l.pending <- c:
+		return true
+	default:
+		return false
+	}
+}
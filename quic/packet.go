@@ -0,0 +1,234 @@
+package quic
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// version1 is the supported version of QUIC (RFC 9000)
+const version1 = 0x00000001
+
+// connectionIDLen is the length of the connection IDs chosen by the server
+const connectionIDLen = 8
+
+// minInitialDatagramSize is the minimum size of the datagrams carrying Initial packets, also the maximum size
+// of the datagrams sent, which avoids discovering the path MTU
+const minInitialDatagramSize = 1200
+
+// maxConnectionIDLen is the maximum length of the connection IDs of QUIC version 1
+const maxConnectionIDLen = 20
+
+// packetNumberLen is the length of the packet numbers sent, large enough for any gap with the acknowledged ones
+const packetNumberLen = 4
+
+// long header packet types
+const (
+	packetTypeInitial   = 0
+	packetTypeZeroRTT   = 1
+	packetTypeHandshake = 2
+	packetTypeRetry     = 3
+)
+
+// level is an encryption level, each having its own packet number space
+type level int
+
+const (
+	levelInitial level = iota
+	levelHandshake
+	levelApplication
+	levelCount
+)
+
+func (l level) String() string {
+	switch l {
+	case levelInitial:
+		return "Initial"
+	case levelHandshake:
+		return "Handshake"
+	default:
+		return "1-RTT"
+	}
+}
+
+var errInvalidPacket = errors.New("invalid packet")
+
+// header is the part of a packet header not protected by the header protection
+type header struct {
+	long                 bool
+	packetType           uint8
+	version              uint32
+	destinationID        []byte
+	sourceID             []byte
+	token                []byte
+	packetNumberOffset   int
+	length               int // length of the packet in the datagram
+	unsupportedVersion   bool
+	hasPacketNumberSpace bool
+}
+
+// parseHeader parses the header of the first packet of a datagram, the short headers carrying
+// a destination connection ID chosen by the server
+func parseHeader(data []byte) (*header, error) {
+	p := &parser{data: data}
+	first := p.uint8()
+	if p.err != nil {
+		return nil, errInvalidPacket
+	}
+
+	if first&0x80 == 0 {
+		h := &header{destinationID: p.bytes(connectionIDLen), hasPacketNumberSpace: true}
+		if p.err != nil || first&0x40 == 0 {
+			return nil, errInvalidPacket
+		}
+		h.packetNumberOffset = 1 + connectionIDLen
+		h.length = len(data)
+		return h, nil
+	}
+
+	h := &header{long: true, version: uint32(p.uint(4))}
+	h.destinationID = p.vector(1)
+	h.sourceID = p.vector(1)
+	if p.err != nil {
+		return nil, errInvalidPacket
+	}
+	if h.version != version1 {
+		h.unsupportedVersion = true
+		h.length = len(data)
+		return h, nil
+	}
+	if first&0x40 == 0 || len(h.destinationID) > maxConnectionIDLen || len(h.sourceID) > maxConnectionIDLen {
+		return nil, errInvalidPacket
+	}
+
+	h.packetType = first >> 4 & 0x03
+	switch h.packetType {
+	case packetTypeRetry:
+		return nil, errInvalidPacket
+	case packetTypeInitial:
+		h.token = p.bytes(int(p.varint()))
+	}
+	length := p.varint()
+	h.packetNumberOffset = len(data) - len(p.data)
+	if p.err != nil || length > uint64(len(p.data)) {
+		return nil, errInvalidPacket
+	}
+	h.length = h.packetNumberOffset + int(length)
+	h.hasPacketNumberSpace = h.packetType != packetTypeZeroRTT
+	return h, nil
+}
+
+func (h *header) level() level {
+	switch {
+	case !h.long:
+		return levelApplication
+	case h.packetType == packetTypeInitial:
+		return levelInitial
+	default:
+		return levelHandshake
+	}
+}
+
+// decodePacketNumber reconstructs a packet number from its truncated encoding (RFC 9000 appendix A.3)
+func decodePacketNumber(largest int64, truncated uint64, length int) uint64 {
+	expected := uint64(largest + 1)
+	window := uint64(1) << (8 * uint(length))
+	halfWindow := window / 2
+	mask := window - 1
+	candidate := expected&^mask | truncated
+	if candidate+halfWindow <= expected && candidate < 1<<62-window {
+		return candidate + window
+	}
+	if candidate > expected+halfWindow && candidate >= window {
+		return candidate - window
+	}
+	return candidate
+}
+
+// outPacket is a packet being built, sealed once its payload is complete
+type outPacket struct {
+	lvl     level
+	header  []byte // the header up to the packet number, without the Length field of the long headers
+	payload []byte
+	sent    *sentPacket
+}
+
+func newOutPacket(lvl level, destinationID, sourceID []byte, keyPhase bool) *outPacket {
+	var header []byte
+	switch lvl {
+	case levelApplication:
+		first := byte(0x40 | (packetNumberLen - 1))
+		if keyPhase {
+			first |= 0x04
+		}
+		header = append([]byte{first}, destinationID...)
+	default:
+		packetType := byte(packetTypeInitial)
+		if lvl == levelHandshake {
+			packetType = packetTypeHandshake
+		}
+		header = []byte{0xc0 | packetType<<4 | (packetNumberLen - 1)}
+		header = appendUint(header, version1, 4)
+		header = appendVector(header, 1, func(b []byte) []byte {
+			return append(b, destinationID...)
+		})
+		header = appendVector(header, 1, func(b []byte) []byte {
+			return append(b, sourceID...)
+		})
+		if lvl == levelInitial {
+			header = append(header, 0) // token length
+		}
+	}
+	return &outPacket{lvl: lvl, header: header}
+}
+
+// overhead returns the size of the packet without its payload
+func (p *outPacket) overhead(aeadOverhead int) int {
+	size := len(p.header) + packetNumberLen + aeadOverhead
+	if p.lvl != levelApplication {
+		size += 2 // Length field
+	}
+	return size
+}
+
+// size returns the size of the sealed packet
+func (p *outPacket) size(aeadOverhead int) int {
+	return p.overhead(aeadOverhead) + len(p.payload)
+}
+
+// seal appends the protected packet to the datagram
+func (p *outPacket) seal(datagram []byte, keys *packetKeys, packetNumber uint64) []byte {
+	start := len(datagram)
+	datagram = append(datagram, p.header...)
+	if p.lvl != levelApplication {
+		length := packetNumberLen + len(p.payload) + keys.aead.Overhead()
+		datagram = append(datagram, 0x40|byte(length>>8), byte(length))
+	}
+	packetNumberOffset := len(datagram) - start
+	datagram = appendUint(datagram, packetNumber, packetNumberLen)
+	datagram = keys.aead.Seal(datagram, keys.nonce(packetNumber), p.payload, datagram[start:])
+	keys.protectHeader(datagram[start:], packetNumberOffset)
+	return datagram
+}
+
+// appendVersionNegotiation appends a Version Negotiation packet replying to a packet of an unsupported version
+func appendVersionNegotiation(b []byte, h *header) []byte {
+	random := make([]byte, 1)
+	rand.Read(random)
+	b = append(b, 0x80|random[0])
+	b = appendUint(b, 0, 4)
+	b = appendVector(b, 1, func(b []byte) []byte {
+		return append(b, h.sourceID...)
+	})
+	b = appendVector(b, 1, func(b []byte) []byte {
+		return append(b, h.destinationID...)
+	})
+	return appendUint(b, version1, 4)
+}
+
+func newConnectionID() []byte {
+	id := make([]byte, connectionIDLen)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return id
+}
@@ -0,0 +1,87 @@
+package quic
+
+// byteRange is the range of offsets or packet numbers [start, end)
+type byteRange struct {
+	start, end uint64
+}
+
+// rangeSet is a set of disjoint and non-adjacent ranges, sorted by increasing offsets
+type rangeSet []byteRange
+
+func (s *rangeSet) add(start, end uint64) {
+	if start >= end {
+		return
+	}
+	ranges := *s
+	i := 0
+	for i < len(ranges) && ranges[i].end < start {
+		i++
+	}
+	j := i
+	for j < len(ranges) && ranges[j].start <= end {
+		if ranges[j].start < start {
+			start = ranges[j].start
+		}
+		if ranges[j].end > end {
+			end = ranges[j].end
+		}
+		j++
+	}
+	if i == j {
+		ranges = append(ranges, byteRange{})
+		copy(ranges[i+1:], ranges[i:])
+		ranges[i] = byteRange{start, end}
+	} else {
+		ranges[i] = byteRange{start, end}
+		ranges = append(ranges[:i+1], ranges[j:]...)
+	}
+	*s = ranges
+}
+
+func (s *rangeSet) remove(start, end uint64) {
+	if start >= end {
+		return
+	}
+	var ranges rangeSet
+	for _, r := range *s {
+		if r.end <= start || r.start >= end {
+			ranges = append(ranges, r)
+			continue
+		}
+		if r.start < start {
+			ranges = append(ranges, byteRange{r.start, start})
+		}
+		if r.end > end {
+			ranges = append(ranges, byteRange{end, r.end})
+		}
+	}
+	*s = ranges
+}
+
+// contains returns whether [start, end) is entirely in the set
+func (s rangeSet) contains(start, end uint64) bool {
+	for _, r := range s {
+		if r.start <= start && end <= r.end {
+			return true
+		}
+	}
+	return start >= end
+}
+
+// first returns the range with the lowest offsets, if any
+func (s rangeSet) first() (byteRange, bool) {
+	if len(s) == 0 {
+		return byteRange{}, false
+	}
+	return s[0], true
+}
+
+// prefix returns the end of the range starting at start, or start if there is none
+func (s rangeSet) prefix(start uint64) uint64 {
+	for _, r := range s {
+		if r.start <= start && start < r.end {
+			return r.end
+		}
+	}
+	return start
+}
@@ -0,0 +1,83 @@
+package quic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeSet(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		added    []byteRange
+		removed  []byteRange
+		expected rangeSet
+	}{
+		{
+			desc:     "disjoint ranges",
+			added:    []byteRange{{10, 20}, {0, 5}, {30, 40}},
+			expected: rangeSet{{0, 5}, {10, 20}, {30, 40}},
+		},
+		{
+			desc:     "adjacent ranges",
+			added:    []byteRange{{0, 5}, {10, 20}, {5, 10}},
+			expected: rangeSet{{0, 20}},
+		},
+		{
+			desc:     "overlapping ranges",
+			added:    []byteRange{{10, 20}, {30, 40}, {15, 35}},
+			expected: rangeSet{{10, 40}},
+		},
+		{
+			desc:     "empty range",
+			added:    []byteRange{{10, 20}, {25, 25}},
+			expected: rangeSet{{10, 20}},
+		},
+		{
+			desc:     "removed from the middle",
+			added:    []byteRange{{0, 20}},
+			removed:  []byteRange{{5, 10}},
+			expected: rangeSet{{0, 5}, {10, 20}},
+		},
+		{
+			desc:     "removed across ranges",
+			added:    []byteRange{{0, 10}, {20, 30}},
+			removed:  []byteRange{{5, 25}},
+			expected: rangeSet{{0, 5}, {25, 30}},
+		},
+		{
+			desc:    "removed entirely",
+			added:   []byteRange{{0, 10}},
+			removed: []byteRange{{0, 10}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var s rangeSet
+			for _, r := range test.added {
+				s.add(r.start, r.end)
+			}
+			for _, r := range test.removed {
+				s.remove(r.start, r.end)
+			}
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
+
+func TestRangeSetLookup(t *testing.T) {
+	s := rangeSet{{0, 5}, {10, 20}}
+
+	assert.True(t, s.contains(10, 15))
+	assert.False(t, s.contains(4, 11))
+	assert.Equal(t, uint64(20), s.prefix(12))
+	assert.Equal(t, uint64(7), s.prefix(7))
+
+	first, ok := s.first()
+	assert.True(t, ok)
+	assert.Equal(t, byteRange{0, 5}, first)
+}
@@ -0,0 +1,200 @@
+package quic
+
+import "time"
+
+// loss detection and congestion control constants (RFC 9002)
+const (
+	initialRTT            = 333 * time.Millisecond
+	timerGranularity      = time.Millisecond
+	packetThreshold       = 3
+	maxAckDelay           = 25 * time.Millisecond
+	initialWindow         = 10 * minInitialDatagramSize
+	minimumWindow         = 2 * minInitialDatagramSize
+	amplificationFactor   = 3
+	maxPTOBackoff         = 16
+	immediateAckThreshold = 2
+)
+
+// sentPacket is a packet sent and not yet acknowledged nor declared lost
+type sentPacket struct {
+	number       uint64
+	time         time.Time
+	size         int
+	ackEliciting bool
+	frames       []sentFrame
+}
+
+// packetSpace holds the state of a packet number space, and of the CRYPTO frames of its encryption level
+type packetSpace struct {
+	readKeys, writeKeys *packetKeys
+	discarded           bool
+
+	nextPacketNumber     uint64
+	sent                 []*sentPacket
+	largestAcked         int64
+	lossTime             time.Time
+	lastAckElicitingSent time.Time
+	probes               int // number of packets to send ignoring the congestion window, when the PTO expires
+
+	received            rangeSet
+	largestReceived     int64
+	largestReceivedTime time.Time
+	ackElicitingPending int
+	ackDeadline         time.Time
+
+	cryptoSend sendBuffer
+	cryptoRecv recvBuffer
+}
+
+func newPacketSpace() *packetSpace {
+	return &packetSpace{largestAcked: -1, largestReceived: -1}
+}
+
+// ackElicitingInFlight returns whether ack-eliciting packets sent in the space are unacknowledged
+func (s *packetSpace) ackElicitingInFlight() bool {
+	for _, packet := range s.sent {
+		if packet.ackEliciting {
+			return true
+		}
+	}
+	return false
+}
+
+// recordReceived records the packet number of a packet received, for it to be acknowledged
+func (s *packetSpace) recordReceived(number uint64, ackEliciting bool, now time.Time) {
+	s.received.add(number, number+1)
+	if len(s.received) > maxAckRanges {
+		s.received = s.received[len(s.received)-maxAckRanges:]
+	}
+	if int64(number) > s.largestReceived {
+		s.largestReceived = int64(number)
+		s.largestReceivedTime = now
+	}
+	if ackEliciting {
+		s.ackElicitingPending++
+		if s.ackDeadline.IsZero() {
+			s.ackDeadline = now.Add(maxAckDelay)
+		}
+	}
+}
+
+// ackNeeded returns whether an ACK frame must be sent now
+func (s *packetSpace) ackNeeded(lvl level, now time.Time) bool {
+	if s.ackElicitingPending == 0 {
+		return false
+	}
+	return lvl != levelApplication || s.ackElicitingPending >= immediateAckThreshold || !now.Before(s.ackDeadline)
+}
+
+// rttEstimator estimates the round-trip time of a connection (RFC 9002 section 5)
+type rttEstimator struct {
+	latest, smoothed, variance, min time.Duration
+	hasSample                       bool
+}
+
+func newRTTEstimator() *rttEstimator {
+	return &rttEstimator{smoothed: initialRTT, variance: initialRTT / 2}
+}
+
+func (r *rttEstimator) update(latest, ackDelay time.Duration, handshakeConfirmed bool) {
+	r.latest = latest
+	if !r.hasSample {
+		r.hasSample = true
+		r.min = latest
+		r.smoothed = latest
+		r.variance = latest / 2
+		return
+	}
+	if latest < r.min {
+		r.min = latest
+	}
+	if handshakeConfirmed && ackDelay > maxAckDelay {
+		ackDelay = maxAckDelay
+	}
+	adjusted := latest
+	if latest >= r.min+ackDelay {
+		adjusted = latest - ackDelay
+	}
+	deviation := r.smoothed - adjusted
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	r.variance = (3*r.variance + deviation) / 4
+	r.smoothed = (7*r.smoothed + adjusted) / 8
+}
+
+// pto returns the probe timeout, without backoff
+func (r *rttEstimator) pto(lvl level) time.Duration {
+	variance := 4 * r.variance
+	if variance < timerGranularity {
+		variance = timerGranularity
+	}
+	pto := r.smoothed + variance
+	if lvl == levelApplication {
+		pto += maxAckDelay
+	}
+	return pto
+}
+
+// lossDelay returns the time after which a packet sent before an acknowledged one is deemed lost
+func (r *rttEstimator) lossDelay() time.Duration {
+	delay := r.smoothed
+	if r.latest > delay {
+		delay = r.latest
+	}
+	delay = delay * 9 / 8
+	if delay < timerGranularity {
+		delay = timerGranularity
+	}
+	return delay
+}
+
+// congestionController is the NewReno congestion controller (RFC 9002 section 7)
+type congestionController struct {
+	window             int
+	slowStartThreshold int
+	bytesInFlight      int
+	recoveryStart      time.Time
+}
+
+func newCongestionController() *congestionController {
+	return &congestionController{window: initialWindow, slowStartThreshold: 1<<31 - 1}
+}
+
+func (c *congestionController) canSend(size int) bool {
+	return c.bytesInFlight+size <= c.window
+}
+
+func (c *congestionController) onSent(size int) {
+	c.bytesInFlight += size
+}
+
+func (c *congestionController) onAcked(packet *sentPacket) {
+	c.bytesInFlight -= packet.size
+	if !packet.time.After(c.recoveryStart) {
+		return
+	}
+	if c.window < c.slowStartThreshold {
+		c.window += packet.size
+	} else {
+		c.window += minInitialDatagramSize * packet.size / c.window
+	}
+}
+
+func (c *congestionController) onLost(packet *sentPacket, now time.Time) {
+	c.bytesInFlight -= packet.size
+	if !packet.time.After(c.recoveryStart) {
+		return
+	}
+	c.recoveryStart = now
+	c.window /= 2
+	if c.window < minimumWindow {
+		c.window = minimumWindow
+	}
+	c.slowStartThreshold = c.window
+}
+
+// onDiscarded removes the packets of a discarded packet number space from the bytes in flight
+func (c *congestionController) onDiscarded(packet *sentPacket) {
+	c.bytesInFlight -= packet.size
+}
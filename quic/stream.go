@@ -0,0 +1,326 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// flow control windows and send buffer size of the streams
+const (
+	streamReceiveWindow     = 1 << 20
+	connectionReceiveWindow = 4 << 20
+	streamSendBuffer        = 256 << 10
+)
+
+var (
+	errWriteOnClosedStream = errors.New("quic: write on closed stream")
+	errWrongDirection      = errors.New("quic: operation not allowed in the direction of the stream")
+)
+
+// Stream is a stream of a QUIC connection. The streams opened by the client are bidirectional or receive-only,
+// the ones opened by the server are send-only.
+type Stream struct {
+	conn *Conn
+	id   uint64
+	cond *sync.Cond
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	hasRecv           bool
+	recv              recvBuffer
+	recvLimit         uint64 // the flow control limit advertised to the peer
+	sendMaxStreamData bool
+	readErr           error
+	readDiscarded     bool // the data received is discarded, the read side having been canceled or reset
+	stopSending       bool
+	stopSendingCode   uint64
+	recvDone          bool
+
+	hasSend      bool
+	send         sendBuffer
+	sendLimit    uint64 // the flow control limit of the peer
+	writeErr     error
+	resetPending bool
+	resetCode    uint64
+	sendDone     bool
+
+	removed bool
+}
+
+func newStream(c *Conn, id uint64, hasRecv, hasSend bool, sendLimit uint64) *Stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Stream{
+		conn:      c,
+		id:        id,
+		cond:      sync.NewCond(&c.mu),
+		ctx:       ctx,
+		cancel:    cancel,
+		hasRecv:   hasRecv,
+		recvLimit: streamReceiveWindow,
+		recvDone:  !hasRecv,
+		hasSend:   hasSend,
+		sendLimit: sendLimit,
+		sendDone:  !hasSend,
+	}
+}
+
+// ID returns the ID of the stream
+func (s *Stream) ID() uint64 {
+	return s.id
+}
+
+// Context returns a context canceled once the stream is aborted by the peer, or the connection is closed
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Read reads the data of the stream, returning io.EOF at its end. A read of no bytes does not block,
+// telling whether the data is all read.
+func (s *Stream) Read(p []byte) (int, error) {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !s.hasRecv {
+		return 0, errWrongDirection
+	}
+	for {
+		if s.readErr != nil {
+			return 0, s.readErr
+		}
+		if data := s.recv.readable(); len(data) > 0 {
+			n := copy(p, data)
+			s.recv.consume(n)
+			c.onStreamRead(s, uint64(n))
+			return n, nil
+		}
+		if s.recv.finished() {
+			if !s.recvDone {
+				s.recvDone = true
+				c.checkStreamDone(s)
+			}
+			return 0, io.EOF
+		}
+		if c.closeErr != nil {
+			return 0, c.closeErr
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+		s.cond.Wait()
+	}
+}
+
+// Write writes data to the stream, blocking while too much data is waiting to be acknowledged
+func (s *Stream) Write(p []byte) (int, error) {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !s.hasSend {
+		return 0, errWrongDirection
+	}
+	written := 0
+	for len(p) > 0 {
+		if s.writeErr != nil {
+			return written, s.writeErr
+		}
+		if c.closeErr != nil {
+			return written, c.closeErr
+		}
+		if s.send.fin {
+			return written, errWriteOnClosedStream
+		}
+		room := streamSendBuffer - s.send.buffered()
+		if room <= 0 {
+			s.cond.Wait()
+			continue
+		}
+		if room > len(p) {
+			room = len(p)
+		}
+		s.send.write(p[:room])
+		written += room
+		p = p[room:]
+		c.wakeLocked()
+	}
+	return written, nil
+}
+
+// Close ends the data sent on the stream
+func (s *Stream) Close() error {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !s.hasSend {
+		return errWrongDirection
+	}
+	if s.writeErr == nil && !s.send.fin {
+		s.send.fin = true
+		c.wakeLocked()
+	}
+	return nil
+}
+
+// WaitSent waits for the peer to acknowledge the data written to the stream, or its reset
+func (s *Stream) WaitSent() error {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !s.hasSend {
+		return errWrongDirection
+	}
+	for !s.sendDone {
+		if c.closeErr != nil {
+			return c.closeErr
+		}
+		s.cond.Wait()
+	}
+	return nil
+}
+
+// CancelWrite aborts the data sent on the stream with the application error code
+func (s *Stream) CancelWrite(code uint64) {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !s.hasSend || s.writeErr != nil || s.sendDone {
+		return
+	}
+	s.reset(&StreamError{Code: code})
+}
+
+// CancelRead asks the peer to stop sending data on the stream with the application error code,
+// unless all the data of the stream has already been received
+func (s *Stream) CancelRead(code uint64) {
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !s.hasRecv || s.recvDone {
+		return
+	}
+	if !s.recv.complete() {
+		s.stopSending = true
+		s.stopSendingCode = code
+	}
+	s.readErr = &StreamError{Code: code}
+	s.discardReceived()
+	s.recvDone = true
+	s.cond.Broadcast()
+	c.checkStreamDone(s)
+	c.wakeLocked()
+}
+
+// reset aborts the data sent on the stream, sending a RESET_STREAM frame with the final size of the data sent
+func (s *Stream) reset(err *StreamError) {
+	s.writeErr = err
+	s.resetPending = true
+	s.resetCode = err.Code
+	s.send.data = nil
+	s.send.base = s.send.sent
+	s.send.lost = nil
+	s.send.acked = nil
+	s.cond.Broadcast()
+	s.conn.wakeLocked()
+}
+
+// discardReceived discards the data received and not read, counting it as consumed for the connection flow control
+func (s *Stream) discardReceived() {
+	s.readDiscarded = true
+	s.conn.onDataConsumed(s.recv.highest - s.recv.read)
+	s.recv.read = s.recv.highest
+	s.recv.data = nil
+	s.recv.received = nil
+}
+
+func (s *Stream) handleStreamFrame(offset uint64, data []byte, fin bool) error {
+	c := s.conn
+	if offset+uint64(len(data)) > s.recvLimit {
+		return transportError(errorFlowControl, "stream %d data beyond the flow control limit", s.id)
+	}
+	if s.readDiscarded {
+		// the data is not stored, only its final size and flow control are checked
+		end := offset + uint64(len(data))
+		if s.recv.hasFinal && (end > s.recv.finalSize || fin && end != s.recv.finalSize) || fin && end < s.recv.highest {
+			return transportError(errorFinalSize, "stream %d data beyond its final size", s.id)
+		}
+		if fin {
+			s.recv.hasFinal = true
+			s.recv.finalSize = end
+		}
+		if end <= s.recv.highest {
+			return nil
+		}
+		increase := end - s.recv.highest
+		s.recv.highest = end
+		s.recv.read = end
+		if err := c.onDataReceived(increase); err != nil {
+			return err
+		}
+		c.onDataConsumed(increase)
+		return nil
+	}
+
+	increase, err := s.recv.push(offset, data, fin)
+	if err != nil {
+		return err
+	}
+	if err := c.onDataReceived(increase); err != nil {
+		return err
+	}
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Stream) handleResetStream(code, finalSize uint64) error {
+	c := s.conn
+	if finalSize < s.recv.highest || s.recv.hasFinal && finalSize != s.recv.finalSize {
+		return transportError(errorFinalSize, "stream %d reset with an invalid final size", s.id)
+	}
+	if finalSize > s.recvLimit {
+		return transportError(errorFlowControl, "stream %d reset beyond the flow control limit", s.id)
+	}
+	if err := c.onDataReceived(finalSize - s.recv.highest); err != nil {
+		return err
+	}
+	s.recv.highest = finalSize
+	s.recv.hasFinal = true
+	s.recv.finalSize = finalSize
+	if s.recvDone {
+		if s.readDiscarded {
+			c.onDataConsumed(finalSize - s.recv.read)
+			s.recv.read = finalSize
+		}
+		return nil
+	}
+
+	s.readErr = &StreamError{Code: code, Remote: true}
+	s.discardReceived()
+	s.recvDone = true
+	s.stopSending = false
+	s.cancel()
+	s.cond.Broadcast()
+	c.checkStreamDone(s)
+	return nil
+}
+
+func (s *Stream) handleStopSending(code uint64) {
+	if s.writeErr != nil || s.sendDone {
+		return
+	}
+	s.reset(&StreamError{Code: code, Remote: true})
+	s.cancel()
+}
+
+func (s *Stream) handleMaxStreamData(limit uint64) {
+	if limit > s.sendLimit {
+		s.sendLimit = limit
+	}
+}
@@ -0,0 +1,154 @@
+package quic
+
+import (
+	"bytes"
+	"time"
+)
+
+// transport parameters (RFC 9000 section 18.2)
+const (
+	parameterOriginalDestinationConnectionID = 0x00
+	parameterMaxIdleTimeout                  = 0x01
+	parameterStatelessResetToken             = 0x02
+	parameterMaxUDPPayloadSize               = 0x03
+	parameterInitialMaxData                  = 0x04
+	parameterInitialMaxStreamDataBidiLocal   = 0x05
+	parameterInitialMaxStreamDataBidiRemote  = 0x06
+	parameterInitialMaxStreamDataUni         = 0x07
+	parameterInitialMaxStreamsBidi           = 0x08
+	parameterInitialMaxStreamsUni            = 0x09
+	parameterAckDelayExponent                = 0x0a
+	parameterMaxAckDelay                     = 0x0b
+	parameterDisableActiveMigration          = 0x0c
+	parameterPreferredAddress                = 0x0d
+	parameterInitialSourceConnectionID       = 0x0f
+	parameterRetrySourceConnectionID         = 0x10
+)
+
+// transportParameters are the transport parameters sent by an endpoint during the handshake
+type transportParameters struct {
+	originalDestinationConnectionID []byte
+	initialSourceConnectionID       []byte
+	maxIdleTimeout                  time.Duration
+	maxUDPPayloadSize               uint64
+	initialMaxData                  uint64
+	initialMaxStreamDataBidiLocal   uint64
+	initialMaxStreamDataBidiRemote  uint64
+	initialMaxStreamDataUni         uint64
+	initialMaxStreamsBidi           uint64
+	initialMaxStreamsUni            uint64
+	ackDelayExponent                uint64
+	maxAckDelay                     time.Duration
+	disableActiveMigration          bool
+}
+
+func (p *transportParameters) encode() []byte {
+	var b []byte
+	appendBytes := func(id uint64, value []byte) {
+		b = AppendVarint(b, id)
+		b = AppendVarint(b, uint64(len(value)))
+		b = append(b, value...)
+	}
+	appendInteger := func(id, value uint64) {
+		if value > 0 {
+			appendBytes(id, AppendVarint(nil, value))
+		}
+	}
+
+	appendBytes(parameterOriginalDestinationConnectionID, p.originalDestinationConnectionID)
+	appendBytes(parameterInitialSourceConnectionID, p.initialSourceConnectionID)
+	appendInteger(parameterMaxIdleTimeout, uint64(p.maxIdleTimeout/time.Millisecond))
+	appendInteger(parameterInitialMaxData, p.initialMaxData)
+	appendInteger(parameterInitialMaxStreamDataBidiLocal, p.initialMaxStreamDataBidiLocal)
+	appendInteger(parameterInitialMaxStreamDataBidiRemote, p.initialMaxStreamDataBidiRemote)
+	appendInteger(parameterInitialMaxStreamDataUni, p.initialMaxStreamDataUni)
+	appendInteger(parameterInitialMaxStreamsBidi, p.initialMaxStreamsBidi)
+	appendInteger(parameterInitialMaxStreamsUni, p.initialMaxStreamsUni)
+	if p.disableActiveMigration {
+		appendBytes(parameterDisableActiveMigration, nil)
+	}
+	return b
+}
+
+// parseClientTransportParameters parses the transport parameters of a client, checking that it did not send
+// the ones reserved to the servers
+func parseClientTransportParameters(data []byte, sourceConnectionID []byte) (*transportParameters, error) {
+	params := &transportParameters{
+		maxUDPPayloadSize: 65527,
+		ackDelayExponent:  3,
+		maxAckDelay:       25 * time.Millisecond,
+	}
+
+	p := &parser{data: data}
+	seen := make(map[uint64]bool)
+	hasSourceConnectionID := false
+	for !p.empty() {
+		id := p.varint()
+		value := &parser{data: p.bytes(int(p.varint()))}
+		if p.err != nil {
+			break
+		}
+		if seen[id] {
+			return nil, transportError(errorTransportParameter, "duplicate transport parameter %d", id)
+		}
+		seen[id] = true
+
+		switch id {
+		case parameterOriginalDestinationConnectionID, parameterStatelessResetToken, parameterPreferredAddress, parameterRetrySourceConnectionID:
+			return nil, transportError(errorTransportParameter, "transport parameter %d sent by the client", id)
+		case parameterInitialSourceConnectionID:
+			if !bytes.Equal(value.data, sourceConnectionID) {
+				return nil, transportError(errorTransportParameter, "initial source connection ID mismatch")
+			}
+			hasSourceConnectionID = true
+			value.data = nil
+		case parameterMaxIdleTimeout:
+			params.maxIdleTimeout = time.Duration(value.varint()) * time.Millisecond
+		case parameterMaxUDPPayloadSize:
+			params.maxUDPPayloadSize = value.varint()
+			if params.maxUDPPayloadSize < minInitialDatagramSize {
+				return nil, transportError(errorTransportParameter, "invalid max UDP payload size %d", params.maxUDPPayloadSize)
+			}
+		case parameterInitialMaxData:
+			params.initialMaxData = value.varint()
+		case parameterInitialMaxStreamDataBidiLocal:
+			params.initialMaxStreamDataBidiLocal = value.varint()
+		case parameterInitialMaxStreamDataBidiRemote:
+			params.initialMaxStreamDataBidiRemote = value.varint()
+		case parameterInitialMaxStreamDataUni:
+			params.initialMaxStreamDataUni = value.varint()
+		case parameterInitialMaxStreamsBidi:
+			params.initialMaxStreamsBidi = value.varint()
+		case parameterInitialMaxStreamsUni:
+			params.initialMaxStreamsUni = value.varint()
+		case parameterAckDelayExponent:
+			params.ackDelayExponent = value.varint()
+			if params.ackDelayExponent > 20 {
+				return nil, transportError(errorTransportParameter, "invalid ACK delay exponent %d", params.ackDelayExponent)
+			}
+		case parameterMaxAckDelay:
+			maxAckDelay := value.varint()
+			if maxAckDelay >= 1<<14 {
+				return nil, transportError(errorTransportParameter, "invalid max ACK delay %d", maxAckDelay)
+			}
+			params.maxAckDelay = time.Duration(maxAckDelay) * time.Millisecond
+		case parameterDisableActiveMigration:
+			params.disableActiveMigration = true
+		default:
+			value.data = nil
+		}
+		if value.err != nil || !value.empty() {
+			return nil, transportError(errorTransportParameter, "invalid transport parameter %d", id)
+		}
+	}
+	if p.err != nil {
+		return nil, transportError(errorTransportParameter, "invalid transport parameters")
+	}
+	if !hasSourceConnectionID {
+		return nil, transportError(errorTransportParameter, "missing initial source connection ID")
+	}
+	if params.initialMaxStreamsBidi > 1<<60 || params.initialMaxStreamsUni > 1<<60 {
+		return nil, transportError(errorTransportParameter, "invalid initial max streams")
+	}
+	return params, nil
+}
@@ -0,0 +1,149 @@
+package quic
+
+import (
+	"errors"
+	"io"
+)
+
+// MaxVarint is the largest value of a variable-length integer
+const MaxVarint = 1<<62 - 1
+
+var errTruncated = errors.New("truncated data")
+
+// AppendVarint appends the variable-length integer encoding of v to b
+func AppendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, byte(v>>8)|0x40, byte(v))
+	case v < 1<<30:
+		return append(b, byte(v>>24)|0x80, byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, byte(v>>56)|0xc0, byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// VarintLen returns the length of the variable-length integer encoding of v
+func VarintLen(v uint64) int {
+	switch {
+	case v < 1<<6:
+		return 1
+	case v < 1<<14:
+		return 2
+	case v < 1<<30:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// ReadVarint reads a variable-length integer
+func ReadVarint(r io.ByteReader) (uint64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	v := uint64(first & 0x3f)
+	for n := 1<<(first>>6) - 1; n > 0; n-- {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// parser reads the fields of packets, frames and TLS messages, its error being set by the first truncated read
+type parser struct {
+	data []byte
+	err  error
+}
+
+func (p *parser) empty() bool {
+	return len(p.data) == 0
+}
+
+func (p *parser) bytes(n int) []byte {
+	if p.err != nil || n < 0 || n > len(p.data) {
+		p.fail()
+		return nil
+	}
+	b := p.data[:n:n]
+	p.data = p.data[n:]
+	return b
+}
+
+func (p *parser) fail() {
+	if p.err == nil {
+		p.err = errTruncated
+	}
+	p.data = nil
+}
+
+func (p *parser) uint(n int) uint64 {
+	var v uint64
+	for _, b := range p.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (p *parser) uint8() uint8 {
+	return uint8(p.uint(1))
+}
+
+func (p *parser) uint16() uint16 {
+	return uint16(p.uint(2))
+}
+
+func (p *parser) varint() uint64 {
+	if p.err != nil || len(p.data) == 0 {
+		p.fail()
+		return 0
+	}
+	b := p.bytes(1 << (p.data[0] >> 6))
+	if b == nil {
+		return 0
+	}
+	v := uint64(b[0] & 0x3f)
+	for _, c := range b[1:] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// vector reads a field prefixed by its length on lengthSize bytes
+func (p *parser) vector(lengthSize int) []byte {
+	return p.bytes(int(p.uint(lengthSize)))
+}
+
+// sub reads a field prefixed by its length on lengthSize bytes, to be parsed by the returned parser
+func (p *parser) sub(lengthSize int) *parser {
+	sub := &parser{data: p.vector(lengthSize)}
+	if p.err != nil {
+		sub.err = p.err
+	}
+	return sub
+}
+
+func appendUint(b []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*uint(i))))
+	}
+	return b
+}
+
+// appendVector appends the field written by fill, prefixed by its length on lengthSize bytes
+func appendVector(b []byte, lengthSize int, fill func([]byte) []byte) []byte {
+	start := len(b)
+	b = append(b, make([]byte, lengthSize)...)
+	b = fill(b)
+	length := uint64(len(b) - start - lengthSize)
+	appendUint(b[start:start], length, lengthSize)
+	return b
+}
@@ -0,0 +1,63 @@
+package quic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the examples of RFC 9000 appendix A.1
+func TestVarint(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		encoding string
+		value    uint64
+	}{
+		{
+			desc:     "one byte",
+			encoding: "25",
+			value:    37,
+		},
+		{
+			desc:     "two bytes",
+			encoding: "7bbd",
+			value:    15293,
+		},
+		{
+			desc:     "four bytes",
+			encoding: "9d7f3e7d",
+			value:    494878333,
+		},
+		{
+			desc:     "eight bytes",
+			encoding: "c2197c5eff14e88c",
+			value:    151288809941952652,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.encoding, hex.EncodeToString(AppendVarint(nil, test.value)))
+			assert.Equal(t, len(test.encoding)/2, VarintLen(test.value))
+
+			value, err := ReadVarint(bytes.NewReader(mustDecodeHex(t, test.encoding)))
+			require.NoError(t, err)
+			assert.Equal(t, test.value, value)
+		})
+	}
+}
+
+func TestReadVarintTruncated(t *testing.T) {
+	_, err := ReadVarint(bytes.NewReader([]byte{0x9d, 0x7f}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = ReadVarint(bytes.NewReader(nil))
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,88 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// HostsResolver resolves periodically the host names of the servers of a backend, and calls its listener when the
+// addresses of a host change, e.g. to close the idle connections to the previous addresses. The listener is called
+// again on the following resolutions as long as it reports that connections to the previous addresses remain.
+type HostsResolver struct {
+	hosts      []string
+	interval   time.Duration
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	addresses  map[string]string // sorted addresses of each host, joined
+	stale      map[string]bool   // hosts for which the listener reported connections to previous addresses
+	onChange   func(host string, addresses []string) bool
+}
+
+// NewHostsResolver creates the resolver of the host names of the servers, nil if they are all IP addresses
+func NewHostsResolver(serverURLs []string, interval time.Duration, onChange func(host string, addresses []string) bool) *HostsResolver {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, serverURL := range serverURLs {
+		u, err := url.Parse(serverURL)
+		if err != nil {
+			continue
+		}
+		host := u.Hostname()
+		if len(host) == 0 || net.ParseIP(host) != nil || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	sort.Strings(hosts)
+
+	return &HostsResolver{
+		hosts:      hosts,
+		interval:   interval,
+		lookupHost: net.DefaultResolver.LookupHost,
+		addresses:  make(map[string]string),
+		stale:      make(map[string]bool),
+		onChange:   onChange,
+	}
+}
+
+// Refresh resolves the host names, calling the listener for the hosts whose addresses changed since the previous
+// resolution, or which still have connections to previous addresses. The hosts whose resolution fails keep their
+// previous addresses.
+func (h *HostsResolver) Refresh(ctx context.Context) error {
+	var lastErr error
+	for _, host := range h.hosts {
+		lookupCtx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+		addresses, err := h.lookupHost(lookupCtx, host)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		sort.Strings(addresses)
+		joined := strings.Join(addresses, ",")
+
+		previous, ok := h.addresses[host]
+		h.addresses[host] = joined
+		if ok && previous != joined {
+			log.Infof("Addresses of server host %s changed from %s to %s", host, previous, joined)
+			h.stale[host] = h.onChange(host, addresses)
+		} else if h.stale[host] {
+			h.stale[host] = h.onChange(host, addresses)
+		}
+	}
+	return lastErr
+}
+
+// RefreshInterval returns the interval between the resolutions of the host names
+func (h *HostsResolver) RefreshInterval() time.Duration {
+	return h.interval
+}
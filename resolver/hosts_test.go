@@ -0,0 +1,110 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHostsResolver(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		serverURLs    []string
+		expectedHosts []string
+	}{
+		{
+			desc:       "IP addresses only",
+			serverURLs: []string{"http://10.0.0.1:8080", "http://[::1]:8080", "unix:///var/run/app.sock"},
+		},
+		{
+			desc:          "host names",
+			serverURLs:    []string{"http://app2.local:8080", "http://10.0.0.1:8080", "https://app1.local", "http://app2.local:8081"},
+			expectedHosts: []string{"app1.local", "app2.local"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			hostsResolver := NewHostsResolver(test.serverURLs, time.Second, func(string, []string) bool { return false })
+			if len(test.expectedHosts) == 0 {
+				assert.Nil(t, hostsResolver)
+				return
+			}
+			require.NotNil(t, hostsResolver)
+			assert.Equal(t, test.expectedHosts, hostsResolver.hosts)
+		})
+	}
+}
+
+func TestHostsResolverRefresh(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		addresses       [][]string
+		lookupError     error
+		stale           int // number of calls of the listener reporting connections to previous addresses
+		expectedChanged []string
+	}{
+		{
+			desc:      "same addresses in another order",
+			addresses: [][]string{{"10.0.0.1", "10.0.0.2"}, {"10.0.0.2", "10.0.0.1"}},
+		},
+		{
+			desc:            "addresses changed",
+			addresses:       [][]string{{"10.0.0.1"}, {"10.0.0.1", "10.0.0.2"}, {"10.0.0.3"}},
+			expectedChanged: []string{"app.local", "app.local"},
+		},
+		{
+			desc:            "listener called again while connections to previous addresses remain",
+			addresses:       [][]string{{"10.0.0.1"}, {"10.0.0.2"}, {"10.0.0.2"}, {"10.0.0.2"}, {"10.0.0.2"}},
+			stale:           2,
+			expectedChanged: []string{"app.local", "app.local", "app.local"},
+		},
+		{
+			desc:        "addresses kept when the resolution fails",
+			addresses:   [][]string{{"10.0.0.1"}, nil},
+			lookupError: errors.New("no such host"),
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var changed []string
+			hostsResolver := NewHostsResolver([]string{"http://app.local:8080"}, time.Second, func(host string, addresses []string) bool {
+				changed = append(changed, host)
+				return len(changed) <= test.stale
+			})
+			require.NotNil(t, hostsResolver)
+
+			for i, addresses := range test.addresses {
+				addresses := addresses
+				var lookupError error
+				if i > 0 {
+					lookupError = test.lookupError
+				}
+				hostsResolver.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+					assert.Equal(t, "app.local", host)
+					return addresses, lookupError
+				}
+				err := hostsResolver.Refresh(context.Background())
+				if lookupError != nil {
+					assert.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+
+			assert.Equal(t, test.expectedChanged, changed)
+			assert.Equal(t, "10.0.0", hostsResolver.addresses["app.local"][:6])
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// DefaultRefreshInterval is the default interval between the resolutions of the servers of a backend
+const DefaultRefreshInterval = 30 * time.Second
+
+// DefaultTimeout is the default duration to wait for a DNS resolution
+const DefaultTimeout = 5 * time.Second
+
+var singleton *Resolver
+var once sync.Once
+
+// GetResolver returns the resolver which is guaranteed to be a singleton.
+func GetResolver() *Resolver {
+	once.Do(func() {
+		singleton = &Resolver{}
+	})
+	return singleton
+}
+
+// Refresher is a resolution run periodically for a backend
+type Refresher interface {
+	Refresh(ctx context.Context) error
+	RefreshInterval() time.Duration
+}
+
+// Resolver runs the resolutions of the servers of the backends of the current configuration
+type Resolver struct {
+	mutex      sync.Mutex
+	cancel     context.CancelFunc
+	waitGroup  sync.WaitGroup
+	refreshers map[Refresher]bool
+}
+
+// SetBackendsConfiguration replaces the resolutions of the previous configuration by the ones of the backends.
+// The previous resolutions are stopped before the new ones are run, the refreshers kept from the previous
+// configuration being refreshed at their next interval, and the other ones at once.
+func (r *Resolver) SetBackendsConfiguration(parentCtx context.Context, backends map[string]Refresher) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.waitGroup.Wait()
+	ctx, cancel := context.WithCancel(parentCtx)
+	r.cancel = cancel
+
+	refreshers := make(map[Refresher]bool)
+	for backendID, backend := range backends {
+		currentBackendID := backendID
+		currentBackend := backend
+		refreshAtOnce := !r.refreshers[currentBackend]
+		refreshers[currentBackend] = true
+		r.waitGroup.Add(1)
+		safe.Go(func() {
+			defer r.waitGroup.Done()
+			run(ctx, currentBackendID, currentBackend, refreshAtOnce)
+		})
+	}
+	r.refreshers = refreshers
+}
+
+// BackendResolver keeps the servers of the load-balancer of a backend in sync with the DNS SRV records of its name:
+// the records of the lowest priority are the servers, weighted by the weights of the records.
+type BackendResolver struct {
+	name      string
+	scheme    string
+	interval  time.Duration
+	lb        healthcheck.LoadBalancer
+	lookupSRV func(ctx context.Context, name string) ([]*net.SRV, error)
+	servers   map[string]int // weights of the servers added to the load-balancer by the resolver
+}
+
+// NewBackendResolver creates the resolver of the servers of the load-balancer from the DNS SRV records
+func NewBackendResolver(lb healthcheck.LoadBalancer, dnsSRV *types.DNSSRV) (*BackendResolver, error) {
+	if len(dnsSRV.Name) == 0 {
+		return nil, fmt.Errorf("missing DNS SRV records name")
+	}
+
+	interval := DefaultRefreshInterval
+	if len(dnsSRV.RefreshInterval) > 0 {
+		var err error
+		interval, err = time.ParseDuration(dnsSRV.RefreshInterval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid refresh interval %q of the DNS SRV records %s", dnsSRV.RefreshInterval, dnsSRV.Name)
+		}
+	}
+
+	scheme := dnsSRV.Scheme
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+
+	return &BackendResolver{
+		name:      dnsSRV.Name,
+		scheme:    scheme,
+		interval:  interval,
+		lb:        lb,
+		lookupSRV: lookupSRV,
+		servers:   make(map[string]int),
+	}, nil
+}
+
+func lookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	return records, err
+}
+
+// Refresh resolves the DNS SRV records, and updates the servers of the load-balancer accordingly.
+// The servers are kept when the resolution fails.
+func (b *BackendResolver) Refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	records, err := b.lookupSRV(ctx, b.name)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the DNS SRV records %s: %v", b.name, err)
+	}
+
+	servers := b.recordsServers(records)
+	for serverURL, weight := range servers {
+		if currentWeight, ok := b.servers[serverURL]; ok && currentWeight == weight {
+			continue
+		}
+		u, err := url.Parse(serverURL)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Adding server %s with weight %d from the DNS SRV records %s", serverURL, weight, b.name)
+		if err := b.lb.UpsertServer(u, roundrobin.Weight(weight)); err != nil {
+			return err
+		}
+	}
+	for serverURL := range b.servers {
+		if _, ok := servers[serverURL]; ok {
+			continue
+		}
+		u, err := url.Parse(serverURL)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Removing server %s no more in the DNS SRV records %s", serverURL, b.name)
+		if err := b.lb.RemoveServer(u); err != nil {
+			log.Debugf("Server %s already removed: %v", serverURL, err)
+		}
+	}
+	b.servers = servers
+	return nil
+}
+
+// recordsServers returns the URLs and weights of the servers of the records of the lowest priority
+func (b *BackendResolver) recordsServers(records []*net.SRV) map[string]int {
+	servers := make(map[string]int)
+	if len(records) == 0 {
+		return servers
+	}
+
+	priority := records[0].Priority
+	for _, record := range records {
+		if record.Priority < priority {
+			priority = record.Priority
+		}
+	}
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+		weight := int(record.Weight)
+		if weight == 0 {
+			weight = 1
+		}
+		host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+		servers[b.scheme+"://"+host] = weight
+	}
+	return servers
+}
+
+// RefreshInterval returns the interval between the resolutions of the DNS SRV records
+func (b *BackendResolver) RefreshInterval() time.Duration {
+	return b.interval
+}
+
+// run refreshes the resolution, at once if refreshAtOnce is true, then periodically until the context is done
+func run(ctx context.Context, backendID string, refresher Refresher, refreshAtOnce bool) {
+	ticker := time.NewTicker(refresher.RefreshInterval())
+	defer ticker.Stop()
+	if !refreshAtOnce {
+		if !wait(ctx, backendID, ticker) {
+			return
+		}
+	}
+	for {
+		if err := refresher.Refresh(ctx); err != nil {
+			log.Warnf("Error refreshing the resolution of backend %s: %v", backendID, err)
+		}
+		if !wait(ctx, backendID, ticker) {
+			return
+		}
+	}
+}
+
+// wait waits for the next tick, and returns false if the context is done first
+func wait(ctx context.Context, backendID string, ticker *time.Ticker) bool {
+	select {
+	case <-ctx.Done():
+		log.Debugf("Stopping the resolution of backend %s", backendID)
+		return false
+	case <-ticker.C:
+		return true
+	}
+}
@@ -0,0 +1,228 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+type testLoadBalancer struct {
+	servers map[string]int
+}
+
+func (lb *testLoadBalancer) RemoveServer(u *url.URL) error {
+	delete(lb.servers, u.String())
+	return nil
+}
+
+func (lb *testLoadBalancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	// the options cannot be read from outside oxy, the weights are checked on the resolver
+	lb.servers[u.String()] = 0
+	return nil
+}
+
+func (lb *testLoadBalancer) Servers() []*url.URL {
+	var servers []*url.URL
+	for server := range lb.servers {
+		u, _ := url.Parse(server)
+		servers = append(servers, u)
+	}
+	return servers
+}
+
+func TestBackendResolverRefresh(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		records         [][]*net.SRV
+		lookupError     error
+		expectedServers map[string]int
+	}{
+		{
+			desc: "servers of the lowest priority",
+			records: [][]*net.SRV{{
+				{Target: "app1.service.consul.", Port: 8080, Priority: 1, Weight: 10},
+				{Target: "app2.service.consul.", Port: 8081, Priority: 1, Weight: 0},
+				{Target: "backup.service.consul.", Port: 8080, Priority: 2, Weight: 10},
+			}},
+			expectedServers: map[string]int{
+				"http://app1.service.consul:8080": 10,
+				"http://app2.service.consul:8081": 1,
+			},
+		},
+		{
+			desc: "servers updated",
+			records: [][]*net.SRV{
+				{
+					{Target: "app1.service.consul.", Port: 8080, Weight: 10},
+					{Target: "app2.service.consul.", Port: 8080, Weight: 10},
+				},
+				{
+					{Target: "app2.service.consul.", Port: 8080, Weight: 20},
+					{Target: "app3.service.consul.", Port: 8080, Weight: 10},
+				},
+			},
+			expectedServers: map[string]int{
+				"http://app2.service.consul:8080": 20,
+				"http://app3.service.consul:8080": 10,
+			},
+		},
+		{
+			desc: "servers kept when the resolution fails",
+			records: [][]*net.SRV{
+				{{Target: "app1.service.consul.", Port: 8080, Weight: 10}},
+				nil,
+			},
+			lookupError: errors.New("no such host"),
+			expectedServers: map[string]int{
+				"http://app1.service.consul:8080": 10,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			lb := &testLoadBalancer{servers: make(map[string]int)}
+			backendResolver, err := NewBackendResolver(lb, &types.DNSSRV{Name: "_http._tcp.app.service.consul"})
+			require.NoError(t, err)
+
+			for i, records := range test.records {
+				records := records
+				var lookupError error
+				if i > 0 {
+					lookupError = test.lookupError
+				}
+				backendResolver.lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+					assert.Equal(t, "_http._tcp.app.service.consul", name)
+					return records, lookupError
+				}
+				err = backendResolver.Refresh(context.Background())
+				if lookupError != nil {
+					assert.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+
+			assert.Equal(t, test.expectedServers, backendResolver.servers)
+			assert.Len(t, lb.servers, len(test.expectedServers))
+			for server := range test.expectedServers {
+				assert.Contains(t, lb.servers, server)
+			}
+		})
+	}
+}
+
+func TestNewBackendResolverInvalid(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		dnsSRV types.DNSSRV
+	}{
+		{
+			desc: "missing name",
+		},
+		{
+			desc:   "invalid refresh interval",
+			dnsSRV: types.DNSSRV{Name: "_http._tcp.app.service.consul", RefreshInterval: "30"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewBackendResolver(&testLoadBalancer{}, &test.dnsSRV)
+			assert.Error(t, err)
+		})
+	}
+}
+
+type testRefresher struct {
+	interval  time.Duration
+	delay     time.Duration
+	mutex     sync.Mutex
+	running   int
+	overlaps  int
+	refreshes int
+}
+
+func (r *testRefresher) Refresh(ctx context.Context) error {
+	r.mutex.Lock()
+	r.running++
+	if r.running > 1 {
+		r.overlaps++
+	}
+	r.refreshes++
+	r.mutex.Unlock()
+
+	time.Sleep(r.delay)
+
+	r.mutex.Lock()
+	r.running--
+	r.mutex.Unlock()
+	return nil
+}
+
+func (r *testRefresher) RefreshInterval() time.Duration {
+	return r.interval
+}
+
+func (r *testRefresher) counts() (int, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.refreshes, r.overlaps
+}
+
+func waitRefreshes(t *testing.T, refresher *testRefresher, expected int) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if refreshes, _ := refresher.counts(); refreshes == expected {
+			return
+		}
+	}
+	t.Fatalf("refresher not refreshed %d times", expected)
+}
+
+func TestResolverSetBackendsConfiguration(t *testing.T) {
+	resolver := &Resolver{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kept := &testRefresher{interval: time.Hour}
+	resolver.SetBackendsConfiguration(ctx, map[string]Refresher{"kept": kept})
+	waitRefreshes(t, kept, 1)
+
+	added := &testRefresher{interval: time.Hour}
+	resolver.SetBackendsConfiguration(ctx, map[string]Refresher{"kept": kept, "added": added})
+	waitRefreshes(t, added, 1)
+	refreshes, _ := kept.counts()
+	assert.Equal(t, 1, refreshes, "kept refresher refreshed at once")
+}
+
+func TestResolverSetBackendsConfigurationStopsPreviousRuns(t *testing.T) {
+	resolver := &Resolver{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	refresher := &testRefresher{interval: time.Millisecond, delay: 20 * time.Millisecond}
+	for i := 0; i < 10; i++ {
+		resolver.SetBackendsConfiguration(ctx, map[string]Refresher{"backend": refresher})
+		time.Sleep(5 * time.Millisecond)
+	}
+	resolver.SetBackendsConfiguration(ctx, nil)
+
+	refreshes, overlaps := refresher.counts()
+	assert.NotZero(t, refreshes)
+	assert.Zero(t, overlaps)
+}
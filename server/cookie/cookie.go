@@ -55,3 +55,12 @@ func sanitizeName(backend string) string {
 
 	return strings.Map(sanitizer, backend)
 }
+
+// IsValidSameSite checks the SameSite attribute of a cookie: Lax, Strict, None or empty
+func IsValidSameSite(sameSite string) bool {
+	switch sameSite {
+	case "", "Lax", "Strict", "None":
+		return true
+	}
+	return false
+}
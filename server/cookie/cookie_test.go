@@ -81,3 +81,26 @@ func TestGenerateName(t *testing.T) {
 	assert.Len(t, "_8a7bc", 6)
 	assert.Equal(t, "_8a7bc", cookieName)
 }
+
+func TestIsValidSameSite(t *testing.T) {
+	testCases := []struct {
+		sameSite string
+		expected bool
+	}{
+		{sameSite: "", expected: true},
+		{sameSite: "Lax", expected: true},
+		{sameSite: "Strict", expected: true},
+		{sameSite: "None", expected: true},
+		{sameSite: "lax", expected: false},
+		{sameSite: "foo", expected: false},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.sameSite, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, IsValidSameSite(test.sameSite))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// resolvedHostsConns tracks the connections dialed to the host names of the servers, when they are resolved
+// periodically, for the resolvers to tell whether connections to their previous addresses are still open
+var resolvedHostsConns = newDialedConns()
+
+// dialedConns holds the open connections dialed to host names, with the addresses they are connected to
+type dialedConns struct {
+	mutex sync.Mutex
+	conns map[*trackedConn]struct{}
+}
+
+type trackedConn struct {
+	net.Conn
+	host    string
+	address string
+	conns   *dialedConns
+	once    sync.Once
+}
+
+func newDialedConns() *dialedConns {
+	return &dialedConns{conns: make(map[*trackedConn]struct{})}
+}
+
+// dial returns the function dialing with dial and tracking the connections to host names until they are closed
+func (d *dialedConns) dial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return conn, nil
+		}
+		remoteAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+		if !ok {
+			return conn, nil
+		}
+
+		tracked := &trackedConn{Conn: conn, host: host, address: remoteAddr.IP.String(), conns: d}
+		d.mutex.Lock()
+		d.conns[tracked] = struct{}{}
+		d.mutex.Unlock()
+		return tracked, nil
+	}
+}
+
+// stale reports whether connections to the host are open to other addresses than the given ones
+func (d *dialedConns) stale(host string, addresses []string) bool {
+	current := make(map[string]bool)
+	for _, address := range addresses {
+		if ip := net.ParseIP(address); ip != nil {
+			current[ip.String()] = true
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for conn := range d.conns {
+		if conn.host == host && !current[conn.address] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.conns.mutex.Lock()
+		delete(c.conns.conns, c)
+		c.conns.mutex.Unlock()
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialedConnsStale(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	var dialer net.Dialer
+	conns := newDialedConns()
+	dial := conns.dial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, listener.Addr().String())
+	})
+
+	ipConn, err := dial(context.Background(), "tcp", "127.0.0.1:8080")
+	require.NoError(t, err)
+	defer ipConn.Close()
+	hostConn, err := dial(context.Background(), "tcp", "app.local:8080")
+	require.NoError(t, err)
+
+	assert.False(t, conns.stale("app.local", []string{"127.0.0.1"}))
+	assert.True(t, conns.stale("app.local", []string{"10.0.0.1"}))
+	assert.False(t, conns.stale("other.local", []string{"10.0.0.1"}))
+
+	require.NoError(t, hostConn.Close())
+	assert.False(t, conns.stale("app.local", []string{"10.0.0.1"}))
+}
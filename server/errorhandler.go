@@ -9,7 +9,8 @@ import (
 )
 
 // RecordingErrorHandler is an error handler, implementing the vulcand/oxy
-// error handler interface, which is recording network errors by using the netErrorRecorder.
+// error handler interface, which is recording network errors by using the netErrorRecorder,
+// and all the errors for the cache not to store the responses.
 // In addition it sets a proper HTTP status code and body, depending on the type of error occurred.
 type RecordingErrorHandler struct {
 	netErrorRecorder middlewares.NetErrorRecorder
@@ -21,6 +22,7 @@ func NewRecordingErrorHandler(recorder middlewares.NetErrorRecorder) *RecordingE
 }
 
 func (eh *RecordingErrorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request, err error) {
+	middlewares.RecordForwardError(req.Context())
 	statusCode := http.StatusInternalServerError
 
 	if e, ok := err.(net.Error); ok {
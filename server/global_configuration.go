@@ -0,0 +1,354 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider"
+	"github.com/containous/traefik/proxyprotocol"
+	"github.com/containous/traefik/types"
+)
+
+// reloadedGlobalConfiguration are the settings of the global configuration applied without a restart
+var reloadedGlobalConfiguration = map[string]bool{
+	"EntryPoints":        true,
+	"DefaultEntryPoints": true,
+	"LogLevel":           true,
+	"HealthCheck":        true,
+}
+
+// reloadedProviders are the providers of the global configuration restarted with their new settings when they change.
+// The web provider, serving the API, requires a restart.
+var reloadedProviders = []string{
+	"Docker",
+	"Marathon",
+	"File",
+	"Consul",
+	"ConsulCatalog",
+	"Etcd",
+	"Zookeeper",
+	"Boltdb",
+	"Kubernetes",
+	"Mesos",
+	"Eureka",
+	"ECS",
+	"Rancher",
+	"DynamoDB",
+}
+
+const (
+	// entryPointStopTimeout is the longest duration to wait for the listener of a changed entry point to be closed
+	entryPointStopTimeout = 5 * time.Second
+	// providerStopTimeout is the longest duration to wait for the goroutines of a changed provider to end
+	providerStopTimeout = 5 * time.Second
+)
+
+// SetGlobalConfigurationLoader sets the loader of the global configuration, reloaded on SIGHUP.
+// The configuration it loads at once is the one the reloaded configurations are compared to.
+func (server *Server) SetGlobalConfigurationLoader(loader func() (*configuration.GlobalConfiguration, error)) {
+	loaded, err := loader()
+	if err != nil {
+		log.Errorf("Error loading the global configuration, it will not be reloaded: %v", err)
+		return
+	}
+	server.globalConfigurationLoader = loader
+	server.loadedGlobalConfiguration = loaded
+}
+
+// reloadGlobalConfiguration loads the global configuration again, to be applied with the configurations of the providers
+func (server *Server) reloadGlobalConfiguration() {
+	if server.globalConfigurationLoader == nil {
+		log.Warn("The global configuration cannot be reloaded")
+		return
+	}
+	loaded, err := server.globalConfigurationLoader()
+	if err != nil {
+		log.Errorf("Error reloading the global configuration: %v", err)
+		return
+	}
+	server.globalConfigurationChan <- loaded
+}
+
+// applyGlobalConfiguration applies the changes of the reloaded global configuration. The entry points added or changed
+// are set up first, taking over the sockets of the running entry points on the same addresses: if one of them cannot
+// be set up, the reloaded configuration is discarded and the running entry points are kept. Otherwise they are
+// started, the ones removed or changed are stopped, their connections being drained, and the other entry points are
+// left untouched. The providers changed are then started again with their new settings. The changes of the settings
+// which are not reloaded are logged.
+func (server *Server) applyGlobalConfiguration(loaded *configuration.GlobalConfiguration) {
+	previous := server.loadedGlobalConfiguration
+
+	isReloadedProvider := make(map[string]bool)
+	for _, name := range reloadedProviders {
+		isReloadedProvider[name] = true
+	}
+	var restartRequired []string
+	previousValue := reflect.ValueOf(*previous)
+	loadedValue := reflect.ValueOf(*loaded)
+	for i := 0; i < previousValue.NumField(); i++ {
+		name := previousValue.Type().Field(i).Name
+		if !reloadedGlobalConfiguration[name] && !isReloadedProvider[name] && !reflect.DeepEqual(previousValue.Field(i).Interface(), loadedValue.Field(i).Interface()) {
+			restartRequired = append(restartRequired, name)
+		}
+	}
+
+	runningGlobalConfiguration := server.globalConfiguration
+	globalConfiguration := server.globalConfiguration
+	globalConfiguration.DefaultEntryPoints = loaded.DefaultEntryPoints
+	globalConfiguration.HealthCheck = loaded.HealthCheck
+	globalConfiguration.LogLevel = loaded.LogLevel
+
+	// the unchanged entry points are kept as they run
+	var changedEntryPoints []string
+	globalConfiguration.EntryPoints = make(configuration.EntryPoints, len(loaded.EntryPoints))
+	for entryPointName, entryPoint := range loaded.EntryPoints {
+		if previousEntryPoint, ok := previous.EntryPoints[entryPointName]; ok && reflect.DeepEqual(previousEntryPoint, entryPoint) {
+			if runningEntryPoint, ok := server.globalConfiguration.EntryPoints[entryPointName]; ok {
+				globalConfiguration.EntryPoints[entryPointName] = runningEntryPoint
+				continue
+			}
+		}
+		globalConfiguration.EntryPoints[entryPointName] = entryPoint
+		changedEntryPoints = append(changedEntryPoints, entryPointName)
+	}
+	for entryPointName := range server.globalConfiguration.EntryPoints {
+		if _, ok := loaded.EntryPoints[entryPointName]; !ok {
+			changedEntryPoints = append(changedEntryPoints, entryPointName)
+		}
+	}
+	sort.Strings(changedEntryPoints)
+
+	// the new entry points are set up while the previous ones still run on the same addresses
+	for _, entryPointName := range changedEntryPoints {
+		if serverEntryPoint, ok := server.serverEntryPoints[entryPointName]; ok {
+			server.takenOverSockets.add(runningGlobalConfiguration.EntryPoints[entryPointName].Address, serverEntryPoint)
+		}
+	}
+	server.globalConfiguration = globalConfiguration
+	newServerEntryPoints, err := server.setupChangedEntryPoints(changedEntryPoints)
+	server.takenOverSockets.close()
+	if err != nil {
+		server.globalConfiguration = runningGlobalConfiguration
+		log.Errorf("Error reloading the global configuration, the running entrypoints are kept: %v", err)
+		return
+	}
+	server.loadedGlobalConfiguration = loaded
+
+	if len(restartRequired) > 0 {
+		log.Warnf("Restart Traefik to apply the changes of the global configuration: %s", strings.Join(restartRequired, ", "))
+	}
+
+	if previous.LogLevel != loaded.LogLevel {
+		level, err := logrus.ParseLevel(strings.ToLower(loaded.LogLevel))
+		if err != nil {
+			log.Errorf("Error getting level: %v", err)
+		} else {
+			log.Infof("Setting the log level to %s", level)
+			log.SetLevel(level)
+		}
+	}
+
+	for _, entryPointName := range changedEntryPoints {
+		serverEntryPoint, ok := server.serverEntryPoints[entryPointName]
+		if !ok {
+			continue
+		}
+		log.Infof("Stopping entrypoint %s", entryPointName)
+		go server.stopServerEntryPoint(entryPointName, serverEntryPoint)
+		select {
+		case <-serverEntryPoint.served:
+		case <-time.After(entryPointStopTimeout):
+			log.Warnf("Timeout while closing entrypoint %s", entryPointName)
+		}
+		delete(server.serverEntryPoints, entryPointName)
+	}
+	for _, entryPointName := range changedEntryPoints {
+		newServerEntryPoint, ok := newServerEntryPoints[entryPointName]
+		if !ok {
+			continue
+		}
+		log.Infof("Starting entrypoint %s", entryPointName)
+		server.serverEntryPoints[entryPointName] = newServerEntryPoint
+		go server.startServer(newServerEntryPoint, server.globalConfiguration)
+	}
+
+	server.reloadProviders(previous, loaded)
+}
+
+// setupChangedEntryPoints sets up the changed entry points of the global configuration, the ones set up being closed
+// if one of them fails
+func (server *Server) setupChangedEntryPoints(changedEntryPoints []string) (serverEntryPoints, error) {
+	builtServerEntryPoints := server.buildEntryPoints(server.globalConfiguration)
+	newServerEntryPoints := make(serverEntryPoints)
+	for _, entryPointName := range changedEntryPoints {
+		builtServerEntryPoint, ok := builtServerEntryPoints[entryPointName]
+		if !ok {
+			continue
+		}
+		newServerEntryPoint, err := server.setupServerEntryPoint(entryPointName, builtServerEntryPoint)
+		if err != nil {
+			for _, newServerEntryPoint := range newServerEntryPoints {
+				newServerEntryPoint.close()
+			}
+			return nil, fmt.Errorf("entrypoint %s: %v", entryPointName, err)
+		}
+		newServerEntryPoints[entryPointName] = newServerEntryPoint
+	}
+	return newServerEntryPoints, nil
+}
+
+// close closes the socket of an entry point set up but not served
+func (s *serverEntryPoint) close() {
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.http3Conn != nil {
+		s.http3Conn.Close()
+	}
+}
+
+// reloadProviders stops the providers whose settings changed, and starts them again with their new settings.
+// The configurations of the providers removed are dropped.
+func (server *Server) reloadProviders(previous, loaded *configuration.GlobalConfiguration) {
+	previousValue := reflect.ValueOf(previous).Elem()
+	loadedValue := reflect.ValueOf(loaded).Elem()
+	runningValue := reflect.ValueOf(&server.globalConfiguration).Elem()
+	removedProviderNames := make(map[string]bool)
+	for _, name := range reloadedProviders {
+		loadedProvider := loadedValue.FieldByName(name)
+		if reflect.DeepEqual(previousValue.FieldByName(name).Interface(), loadedProvider.Interface()) {
+			continue
+		}
+
+		runningProvider := runningValue.FieldByName(name)
+		if !runningProvider.IsNil() {
+			providerNames := server.stopProvider(runningProvider.Interface().(provider.Provider))
+			if loadedProvider.IsNil() {
+				for _, providerName := range providerNames {
+					removedProviderNames[providerName] = true
+				}
+			}
+		}
+		if loadedProvider.IsNil() {
+			runningProvider.Set(loadedProvider)
+			continue
+		}
+
+		// the loaded settings are copied, to be compared to the next reloaded ones as the provider changes its own
+		newProvider := reflect.New(loadedProvider.Type().Elem())
+		newProvider.Elem().Set(loadedProvider.Elem())
+		runningProvider.Set(newProvider)
+		server.providers = append(server.providers, newProvider.Interface().(provider.Provider))
+		server.startProvider(newProvider.Interface().(provider.Provider))
+	}
+
+	if len(removedProviderNames) == 0 {
+		return
+	}
+	newConfigurations := make(types.Configurations)
+	for providerName, configuration := range server.currentConfigurations.Get().(types.Configurations) {
+		if !removedProviderNames[providerName] {
+			newConfigurations[providerName] = configuration
+		}
+	}
+	server.currentConfigurations.Set(newConfigurations)
+}
+
+// takenOverSockets are the duplicated sockets of the running entry points, by network and address, for the entry
+// points replacing them to be set up before they are stopped
+type takenOverSockets map[string]*os.File
+
+// add duplicates the socket of the running entry point. The sockets which cannot be duplicated, as on Windows, are not
+// taken over, the address being unavailable to the new entry point until the previous one is stopped.
+func (t *takenOverSockets) add(address string, serverEntryPoint *serverEntryPoint) {
+	if serverEntryPoint.http3Conn != nil {
+		t.addSocket("udp", address, serverEntryPoint.http3Conn)
+	}
+	if serverEntryPoint.udpConn != nil {
+		t.addSocket("udp", address, serverEntryPoint.udpConn)
+		return
+	}
+	t.addSocket("tcp", address, serverEntryPoint.listener)
+}
+
+func (t *takenOverSockets) addSocket(network, address string, socket interface{}) {
+	if proxyProtocolListener, ok := socket.(*proxyprotocol.Listener); ok {
+		socket = proxyProtocolListener.Listener
+	}
+	fileSocket, ok := socket.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return
+	}
+	file, err := fileSocket.File()
+	if err != nil {
+		log.Debugf("Unable to take over the socket %s %s: %v", network, address, err)
+		return
+	}
+	if *t == nil {
+		*t = make(takenOverSockets)
+	}
+	if previous, ok := (*t)[network+" "+address]; ok {
+		previous.Close()
+	}
+	(*t)[network+" "+address] = file
+}
+
+// listener opens the stream socket taken over on the address, nil if none
+func (t takenOverSockets) listener(address string) net.Listener {
+	file := t.take("tcp", address)
+	if file == nil {
+		return nil
+	}
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		log.Errorf("Error taking over the socket tcp %s: %v", address, err)
+		return nil
+	}
+	return listener
+}
+
+// packetConn opens the datagram socket taken over on the address, nil if none
+func (t takenOverSockets) packetConn(address string) net.PacketConn {
+	file := t.take("udp", address)
+	if file == nil {
+		return nil
+	}
+	defer file.Close()
+	packetConn, err := net.FilePacketConn(file)
+	if err != nil {
+		log.Errorf("Error taking over the socket udp %s: %v", address, err)
+		return nil
+	}
+	return packetConn
+}
+
+func (t takenOverSockets) take(network, address string) *os.File {
+	file, ok := t[network+" "+address]
+	if !ok {
+		return nil
+	}
+	delete(t, network+" "+address)
+	return file
+}
+
+// close closes the sockets taken over by no entry point
+func (t *takenOverSockets) close() {
+	for _, file := range *t {
+		file.Close()
+	}
+	*t = nil
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/provider"
+	"github.com/containous/traefik/provider/file"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyGlobalConfiguration(t *testing.T) {
+	globalConfiguration := func(entryPoints configuration.EntryPoints) *configuration.GlobalConfiguration {
+		return &configuration.GlobalConfiguration{
+			EntryPoints: entryPoints,
+			LifeCycle:   &configuration.LifeCycle{},
+		}
+	}
+
+	initial := globalConfiguration(configuration.EntryPoints{
+		"http":    &configuration.EntryPoint{Address: "127.0.0.1:0"},
+		"changed": &configuration.EntryPoint{Address: "127.0.0.1:0"},
+		"removed": &configuration.EntryPoint{Address: "127.0.0.1:0"},
+	})
+	srv := NewServer(*initial)
+	srv.SetGlobalConfigurationLoader(func() (*configuration.GlobalConfiguration, error) {
+		return globalConfiguration(configuration.EntryPoints{
+			"http":    &configuration.EntryPoint{Address: "127.0.0.1:0"},
+			"changed": &configuration.EntryPoint{Address: "127.0.0.1:0"},
+			"removed": &configuration.EntryPoint{Address: "127.0.0.1:0"},
+		}), nil
+	})
+	srv.startHTTPServers()
+	http := srv.serverEntryPoints["http"]
+	changed := srv.serverEntryPoints["changed"]
+	removed := srv.serverEntryPoints["removed"]
+
+	srv.applyGlobalConfiguration(globalConfiguration(configuration.EntryPoints{
+		"http":    &configuration.EntryPoint{Address: "127.0.0.1:0"},
+		"changed": &configuration.EntryPoint{Address: "127.0.0.1:0", Compress: true},
+		"added":   &configuration.EntryPoint{Address: "127.0.0.1:0"},
+	}))
+
+	require.Len(t, srv.serverEntryPoints, 3)
+	assert.Equal(t, http, srv.serverEntryPoints["http"])
+	assert.NotEqual(t, changed, srv.serverEntryPoints["changed"])
+	assert.NotNil(t, srv.serverEntryPoints["added"].listener)
+	assert.True(t, srv.globalConfiguration.EntryPoints["changed"].Compress)
+	for _, stopped := range []*serverEntryPoint{changed, removed} {
+		select {
+		case <-stopped.served:
+		default:
+			t.Error("entrypoint not stopped")
+		}
+	}
+	select {
+	case <-http.served:
+		t.Error("unchanged entrypoint stopped")
+	default:
+	}
+
+	for serverEntryPointName, serverEntryPoint := range srv.serverEntryPoints {
+		srv.stopServerEntryPoint(serverEntryPointName, serverEntryPoint)
+	}
+}
+
+func TestApplyGlobalConfigurationInvalidEntryPoint(t *testing.T) {
+	initial := &configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{Address: "127.0.0.1:0"},
+		},
+		LifeCycle: &configuration.LifeCycle{},
+	}
+	srv := NewServer(*initial)
+	srv.SetGlobalConfigurationLoader(func() (*configuration.GlobalConfiguration, error) {
+		return initial, nil
+	})
+	srv.startHTTPServers()
+	http := srv.serverEntryPoints["http"]
+	defer srv.stopServerEntryPoint("http", http)
+
+	srv.applyGlobalConfiguration(&configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"added": &configuration.EntryPoint{Address: "127.0.0.1:0"},
+			"http":  &configuration.EntryPoint{Address: "127.0.0.1:0", WhitelistSourceRange: []string{"invalid"}},
+		},
+		LogLevel:  "DEBUG",
+		LifeCycle: &configuration.LifeCycle{},
+	})
+
+	require.Len(t, srv.serverEntryPoints, 1)
+	assert.Equal(t, http, srv.serverEntryPoints["http"])
+	assert.Empty(t, srv.globalConfiguration.EntryPoints["http"].WhitelistSourceRange)
+	assert.NotContains(t, srv.globalConfiguration.EntryPoints, "added")
+	assert.Equal(t, initial, srv.loadedGlobalConfiguration)
+	select {
+	case <-http.served:
+		t.Error("running entrypoint stopped")
+	default:
+	}
+
+	conn, err := net.Dial("tcp", http.listener.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestApplyGlobalConfigurationSameAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	initial := &configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{Address: address},
+		},
+		LifeCycle: &configuration.LifeCycle{},
+	}
+	srv := NewServer(*initial)
+	srv.SetGlobalConfigurationLoader(func() (*configuration.GlobalConfiguration, error) {
+		return initial, nil
+	})
+	srv.startHTTPServers()
+	http := srv.serverEntryPoints["http"]
+
+	srv.applyGlobalConfiguration(&configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{Address: address, Compress: true},
+		},
+		LifeCycle: &configuration.LifeCycle{},
+	})
+
+	require.Len(t, srv.serverEntryPoints, 1)
+	assert.NotEqual(t, http, srv.serverEntryPoints["http"])
+	assert.True(t, srv.globalConfiguration.EntryPoints["http"].Compress)
+	defer srv.stopServerEntryPoint("http", srv.serverEntryPoints["http"])
+	select {
+	case <-http.served:
+	default:
+		t.Error("entrypoint not stopped")
+	}
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestApplyGlobalConfigurationProviders(t *testing.T) {
+	filename := func(content string) string {
+		file, err := ioutil.TempFile("", "traefik-file-provider")
+		require.NoError(t, err)
+		defer file.Close()
+		_, err = file.WriteString(content)
+		require.NoError(t, err)
+		return file.Name()
+	}
+	initialFilename := filename("[backends]\n  [backends.backend1]\n")
+	defer os.Remove(initialFilename)
+	changedFilename := filename("[backends]\n  [backends.backend2]\n")
+	defer os.Remove(changedFilename)
+
+	globalConfiguration := func(fileName string) *configuration.GlobalConfiguration {
+		globalConfiguration := &configuration.GlobalConfiguration{
+			EntryPoints: configuration.EntryPoints{},
+			LifeCycle:   &configuration.LifeCycle{},
+		}
+		if len(fileName) > 0 {
+			globalConfiguration.File = &file.Provider{BaseProvider: provider.BaseProvider{Filename: fileName}}
+		}
+		return globalConfiguration
+	}
+	receiveBackends := func(srv *Server) []string {
+		select {
+		case configMsg := <-srv.configurationChan:
+			assert.Equal(t, "file", configMsg.ProviderName)
+			var backends []string
+			for backendName := range configMsg.Configuration.Backends {
+				backends = append(backends, backendName)
+			}
+			return backends
+		case <-time.After(5 * time.Second):
+			t.Fatal("no configuration received")
+			return nil
+		}
+	}
+
+	srv := NewServer(*globalConfiguration(initialFilename))
+	defer srv.routinesPool.Cleanup()
+	srv.SetGlobalConfigurationLoader(func() (*configuration.GlobalConfiguration, error) {
+		return globalConfiguration(initialFilename), nil
+	})
+	srv.configureProviders()
+	srv.startProviders()
+	assert.Equal(t, []string{"backend1"}, receiveBackends(srv))
+	srv.currentConfigurations.Set(types.Configurations{"file": &types.Configuration{}, "web": &types.Configuration{}})
+
+	srv.applyGlobalConfiguration(globalConfiguration(initialFilename))
+	require.Len(t, srv.runningProviders, 1)
+
+	srv.applyGlobalConfiguration(globalConfiguration(changedFilename))
+	assert.Equal(t, []string{"backend2"}, receiveBackends(srv))
+	require.Len(t, srv.providers, 1)
+	require.Len(t, srv.runningProviders, 1)
+	assert.Equal(t, changedFilename, srv.globalConfiguration.File.Filename)
+	assert.Len(t, srv.currentConfigurations.Get(), 2)
+
+	srv.applyGlobalConfiguration(globalConfiguration(""))
+	assert.Empty(t, srv.providers)
+	assert.Empty(t, srv.runningProviders)
+	assert.Nil(t, srv.globalConfiguration.File)
+	assert.Equal(t, types.Configurations{"web": &types.Configuration{}}, srv.currentConfigurations.Get())
+}
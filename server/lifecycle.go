@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+)
+
+// prepareShutdown makes the /ping health check fail, then runs the pre-shutdown command while Traefik keeps
+// accepting requests during the request accepting grace period, before the graceful shutdown procedure
+func (server *Server) prepareShutdown() {
+	if server.globalConfiguration.Ping != nil {
+		server.globalConfiguration.Ping.SetTerminating()
+	}
+
+	var wg sync.WaitGroup
+	if command := server.globalConfiguration.LifeCycle.PreShutdownCommand; len(strings.TrimSpace(command)) > 0 {
+		wg.Add(1)
+		safe.Go(func() {
+			defer wg.Done()
+			runPreShutdownCommand(command, time.Duration(server.globalConfiguration.LifeCycle.GraceTimeOut))
+		})
+	}
+
+	reqAcceptGraceTimeOut := time.Duration(server.globalConfiguration.LifeCycle.RequestAcceptGraceTimeout)
+	if reqAcceptGraceTimeOut > 0 {
+		log.Infof("Waiting %s for incoming requests to cease", reqAcceptGraceTimeOut)
+		time.Sleep(reqAcceptGraceTimeOut)
+	}
+	wg.Wait()
+}
+
+// runPreShutdownCommand runs the command, killing it if it does not complete within the timeout
+func runPreShutdownCommand(command string, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	args := strings.Fields(command)
+	log.Infof("Running pre-shutdown command %q", command)
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		log.Errorf("Error running pre-shutdown command %q: %v: %s", command, err, output)
+		return err
+	}
+	log.Debugf("Pre-shutdown command %q completed: %s", command, output)
+	return nil
+}
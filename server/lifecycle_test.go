@@ -0,0 +1,62 @@
+// +build !windows
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPreShutdownCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik-lifecycle")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "deregistered")
+
+	testCases := []struct {
+		desc          string
+		command       string
+		timeout       time.Duration
+		expectedError bool
+	}{
+		{
+			desc:    "completed",
+			command: "touch " + marker,
+			timeout: 5 * time.Second,
+		},
+		{
+			desc:          "failed",
+			command:       "false",
+			timeout:       5 * time.Second,
+			expectedError: true,
+		},
+		{
+			desc:          "killed after the timeout",
+			command:       "sleep 10",
+			timeout:       100 * time.Millisecond,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			start := time.Now()
+			err := runPreShutdownCommand(test.command, test.timeout)
+			assert.True(t, time.Since(start) < 5*time.Second)
+			if test.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err = os.Stat(marker)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/resolver"
+	"github.com/containous/traefik/types"
+)
+
+// loadedBackends holds the handlers of the backends loaded for the configurations, indexed by backend ID,
+// with the health checks, resolvers and load-balancers they have registered, the pooled transports they use,
+// and what they have been built from
+type loadedBackends struct {
+	handlers     map[string]http.Handler
+	sources      map[string]*backendSource
+	healthChecks map[string]*healthcheck.BackendHealthCheck
+	resolvers    map[string]resolver.Refresher
+	balancers    map[string]*healthcheck.AdminLoadBalancer
+	transports   map[string][]*http.Transport
+}
+
+func newLoadedBackends() *loadedBackends {
+	return &loadedBackends{
+		handlers:     make(map[string]http.Handler),
+		sources:      make(map[string]*backendSource),
+		healthChecks: make(map[string]*healthcheck.BackendHealthCheck),
+		resolvers:    make(map[string]resolver.Refresher),
+		balancers:    make(map[string]*healthcheck.AdminLoadBalancer),
+		transports:   make(map[string][]*http.Transport),
+	}
+}
+
+// reuse loads the backend as it has been loaded previously, if it has been built from the same source:
+// its load-balancer, with the runtime weights of its servers, and its health check are kept as they are
+func (l *loadedBackends) reuse(previous *loadedBackends, backendID string, source *backendSource) bool {
+	if previous == nil || previous.handlers[backendID] == nil || !previous.sources[backendID].equal(source) {
+		return false
+	}
+	l.handlers[backendID] = previous.handlers[backendID]
+	l.sources[backendID] = source
+	if healthCheck, ok := previous.healthChecks[backendID]; ok {
+		l.healthChecks[backendID] = healthCheck
+	}
+	for _, resolverID := range []string{backendID, backendID + "/hosts"} {
+		if backendResolver, ok := previous.resolvers[resolverID]; ok {
+			l.resolvers[resolverID] = backendResolver
+		}
+	}
+	if balancer, ok := previous.balancers[backendID]; ok {
+		l.balancers[backendID] = balancer
+	}
+	l.transports[backendID] = previous.transports[backendID]
+	return true
+}
+
+// addPrivate adds the health checks, resolvers and load-balancers of the backends loaded for a single frontend,
+// under the ID of the frontend, for them not to replace the ones of the backends shared by the frontends
+func (l *loadedBackends) addPrivate(private *loadedBackends, frontendID string) {
+	for backendID, healthCheck := range private.healthChecks {
+		l.healthChecks[frontendID+"@"+backendID] = healthCheck
+	}
+	for resolverID, backendResolver := range private.resolvers {
+		l.resolvers[frontendID+"@"+resolverID] = backendResolver
+	}
+	for backendID, balancer := range private.balancers {
+		l.balancers[frontendID+"@"+backendID] = balancer
+	}
+}
+
+// backendSource is what the handler of a backend is built from: the frontend building it, the backends it uses,
+// for the servers, the error pages and the mirror, the entry point, and the health checks of the global configuration,
+// the other settings of the global configuration used by the backends not being reloaded
+type backendSource struct {
+	config       *types.Configuration
+	frontendName string
+	frontend     *types.Frontend
+	backends     map[string]*types.Backend
+	entryPoint   *configuration.EntryPoint
+	healthCheck  *configuration.HealthCheckConfig
+}
+
+func newBackendSource(config *types.Configuration, globalConfiguration configuration.GlobalConfiguration, entryPoint *configuration.EntryPoint, frontendName string, frontend *types.Frontend) *backendSource {
+	source := &backendSource{
+		config:       config,
+		frontendName: frontendName,
+		frontend:     frontend,
+		backends:     map[string]*types.Backend{frontend.Backend: config.Backends[frontend.Backend]},
+		entryPoint:   entryPoint,
+		healthCheck:  globalConfiguration.HealthCheck,
+	}
+	for _, errorPage := range frontend.Errors {
+		source.backends[errorPage.Backend] = config.Backends[errorPage.Backend]
+	}
+	if frontend.Mirror != nil {
+		source.backends[frontend.Mirror.Backend] = config.Backends[frontend.Mirror.Backend]
+	}
+	return source
+}
+
+// equal reports whether the sources are the same: the configurations of the providers which have not changed
+// are the same instances, the others are compared
+func (s *backendSource) equal(other *backendSource) bool {
+	if s == nil || other == nil || s.frontendName != other.frontendName {
+		return false
+	}
+	if s.config == other.config && s.entryPoint == other.entryPoint && s.healthCheck == other.healthCheck {
+		return true
+	}
+	return reflect.DeepEqual(s.frontend, other.frontend) && reflect.DeepEqual(s.backends, other.backends) &&
+		reflect.DeepEqual(s.entryPoint, other.entryPoint) && reflect.DeepEqual(s.healthCheck, other.healthCheck)
+}
+
+// loadedFrontends holds the handlers of the frontends loaded for the configurations, before their routes,
+// indexed by provider, entry point and frontend, and what they have been built from
+type loadedFrontends struct {
+	handlers map[string]http.Handler
+	sources  map[string]*frontendSource
+}
+
+func newLoadedFrontends() *loadedFrontends {
+	return &loadedFrontends{
+		handlers: make(map[string]http.Handler),
+		sources:  make(map[string]*frontendSource),
+	}
+}
+
+// reused returns the handler of the frontend loaded previously, if it has been built from the same source
+func (l *loadedFrontends) reused(frontendID string, source *frontendSource) http.Handler {
+	if l == nil || !l.sources[frontendID].equal(source) {
+		return nil
+	}
+	return l.handlers[frontendID]
+}
+
+func frontendID(providerName, entryPointName, frontendName string) string {
+	return providerName + "/" + entryPointName + "/" + frontendName
+}
+
+// frontendSource is what the handler of a frontend is built from: the frontend, its middlewares, the entry point,
+// and the handlers of its backends
+type frontendSource struct {
+	config      *types.Configuration
+	frontend    *types.Frontend
+	middlewares []*types.Middleware
+	entryPoint  *configuration.EntryPoint
+	backends    []http.Handler
+}
+
+func newFrontendSource(config *types.Configuration, entryPoint *configuration.EntryPoint, frontend *types.Frontend) *frontendSource {
+	source := &frontendSource{
+		config:     config,
+		frontend:   frontend,
+		entryPoint: entryPoint,
+	}
+	for _, middlewareName := range frontend.Middlewares {
+		source.middlewares = append(source.middlewares, config.Middlewares[middlewareName])
+	}
+	return source
+}
+
+// equal reports whether the sources are the same, the handlers of the backends being the same instances,
+// as well as the configurations of the providers which have not changed
+func (s *frontendSource) equal(other *frontendSource) bool {
+	if s == nil || other == nil || len(s.backends) != len(other.backends) {
+		return false
+	}
+	for i := range s.backends {
+		if s.backends[i] != other.backends[i] {
+			return false
+		}
+	}
+	if s.config == other.config && s.entryPoint == other.entryPoint {
+		return true
+	}
+	return reflect.DeepEqual(s.frontend, other.frontend) && reflect.DeepEqual(s.middlewares, other.middlewares) && reflect.DeepEqual(s.entryPoint, other.entryPoint)
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -17,17 +17,24 @@ import (
 // Rules holds rule parsing and configuration
 type Rules struct {
 	route *serverRoute
-	err   error
 }
 
 func (r *Rules) host(hosts ...string) *mux.Route {
+	canonicalHosts := make([]string, len(hosts))
+	for i, host := range hosts {
+		canonicalHosts[i] = types.CanonicalDomain(host)
+	}
+	// the request host is matched against all the Host rules of the routes: it is compared without allocations
 	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
-		reqHost, _, err := net.SplitHostPort(req.Host)
-		if err != nil {
-			reqHost = req.Host
+		reqHost := req.Host
+		if strings.IndexByte(reqHost, ':') >= 0 {
+			if host, _, err := net.SplitHostPort(reqHost); err == nil {
+				reqHost = host
+			}
 		}
-		for _, host := range hosts {
-			if types.CanonicalDomain(reqHost) == types.CanonicalDomain(host) {
+		reqHost = strings.TrimSpace(reqHost)
+		for _, host := range canonicalHosts {
+			if strings.EqualFold(reqHost, host) {
 				return true
 			}
 		}
@@ -59,6 +66,27 @@ func (r *Rules) pathPrefix(paths ...string) *mux.Route {
 	return r.route.route
 }
 
+func (r *Rules) pathRegexp(paths ...string) *mux.Route {
+	// the regular expression may contain commas
+	expression := regexp.MustCompile(strings.Join(paths, ","))
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		matches := expression.FindStringSubmatch(req.URL.Path)
+		if matches == nil {
+			return false
+		}
+		// the named capturing groups are route variables
+		if route.Vars == nil {
+			route.Vars = make(map[string]string)
+		}
+		for i, name := range expression.SubexpNames() {
+			if len(name) > 0 {
+				route.Vars[name] = matches[i]
+			}
+		}
+		return true
+	})
+}
+
 type bySize []string
 
 func (a bySize) Len() int           { return len(a) }
@@ -92,6 +120,14 @@ func (r *Rules) replacePath(paths ...string) *mux.Route {
 	return r.route.route
 }
 
+func (r *Rules) replacePathRegex(paths ...string) *mux.Route {
+	// the regular expression may contain commas
+	expression := strings.Fields(strings.Join(paths, ","))
+	r.route.replacePathRegex = expression[0]
+	r.route.replacePathReplacement = expression[1]
+	return r.route.route
+}
+
 func (r *Rules) addPrefix(paths ...string) *mux.Route {
 	for _, path := range paths {
 		r.route.addPrefix = path
@@ -134,30 +170,185 @@ func (r *Rules) headersRegexp(headers ...string) *mux.Route {
 func (r *Rules) query(query ...string) *mux.Route {
 	var queries []string
 	for _, elem := range query {
-		queries = append(queries, strings.Split(elem, "=")...)
+		// the value may contain equal signs
+		queries = append(queries, strings.SplitN(elem, "=", 2)...)
 	}
 
 	return r.route.route.Queries(queries...)
 }
 
-func (r *Rules) parseRules(expression string, onRule func(functionName string, function interface{}, arguments []string) error) error {
-	functions := map[string]interface{}{
-		"Host":                 r.host,
-		"HostRegexp":           r.hostRegexp,
-		"Path":                 r.path,
-		"PathStrip":            r.pathStrip,
-		"PathStripRegex":       r.pathStripRegex,
-		"PathPrefix":           r.pathPrefix,
-		"PathPrefixStrip":      r.pathPrefixStrip,
-		"PathPrefixStripRegex": r.pathPrefixStripRegex,
-		"Method":               r.methods,
-		"Headers":              r.headers,
-		"HeadersRegexp":        r.headersRegexp,
-		"AddPrefix":            r.addPrefix,
-		"ReplacePath":          r.replacePath,
-		"Query":                r.query,
+func (r *Rules) queryRegexp(queries ...string) *mux.Route {
+	expressions := make(map[string]*regexp.Regexp, len(queries))
+	for _, query := range queries {
+		keyValue := strings.SplitN(query, "=", 2)
+		expressions[keyValue[0]] = regexp.MustCompile(keyValue[1])
+	}
+
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		values := req.URL.Query()
+		for key, expression := range expressions {
+			if !matchAnyValue(expression, values[key]) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func matchAnyValue(expression *regexp.Regexp, values []string) bool {
+	for _, value := range values {
+		if expression.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// rule is a matcher or a modifier of the frontend routes, with the validation of its arguments
+type rule struct {
+	apply    func(r *Rules, arguments ...string) *mux.Route
+	validate func(name string, arguments []string) error
+	// modifier is true for the rules modifying the request, which can't be negated nor be an alternative
+	modifier bool
+}
+
+var ruleSet = map[string]rule{
+	"Host":                 {apply: (*Rules).host, validate: validateHosts},
+	"HostRegexp":           {apply: (*Rules).hostRegexp, validate: validateHosts},
+	"Path":                 {apply: (*Rules).path, validate: validatePaths},
+	"PathStrip":            {apply: (*Rules).pathStrip, validate: validatePaths, modifier: true},
+	"PathStripRegex":       {apply: (*Rules).pathStripRegex, validate: validatePaths, modifier: true},
+	"PathRegexp":           {apply: (*Rules).pathRegexp, validate: validateRegexp},
+	"PathPrefix":           {apply: (*Rules).pathPrefix, validate: validatePaths},
+	"PathPrefixStrip":      {apply: (*Rules).pathPrefixStrip, validate: validatePaths, modifier: true},
+	"PathPrefixStripRegex": {apply: (*Rules).pathPrefixStripRegex, validate: validatePaths, modifier: true},
+	"Method":               {apply: (*Rules).methods, validate: validateMethods},
+	"Headers":              {apply: (*Rules).headers, validate: validateHeaders},
+	"HeadersRegexp":        {apply: (*Rules).headersRegexp, validate: validateHeaders},
+	"AddPrefix":            {apply: (*Rules).addPrefix, validate: validateSinglePath, modifier: true},
+	"ReplacePath":          {apply: (*Rules).replacePath, validate: validateSinglePath, modifier: true},
+	"ReplacePathRegex":     {apply: (*Rules).replacePathRegex, validate: validateReplacePathRegex, modifier: true},
+	"Query":                {apply: (*Rules).query, validate: validateQueries},
+	"QueryRegexp":          {apply: (*Rules).queryRegexp, validate: validateQueryRegexps},
+}
+
+// unknownRuleError describes a rule name which is not in ruleSet, suggesting the rule differing only by case
+func unknownRuleError(name string) error {
+	var names []string
+	for known := range ruleSet {
+		if strings.EqualFold(known, name) {
+			return fmt.Errorf("unknown rule '%s', did you mean '%s'?", name, known)
+		}
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown rule '%s', expected one of %s", name, strings.Join(names, ", "))
+}
+
+func validateHosts(name string, hosts []string) error {
+	for _, host := range hosts {
+		if strings.ContainsAny(host, "/ \t") {
+			return fmt.Errorf("%s expects host names, got %q", name, host)
+		}
 	}
+	return nil
+}
 
+func validatePaths(name string, paths []string) error {
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%s expects paths starting with a slash, got %q", name, path)
+		}
+	}
+	return nil
+}
+
+func validateRegexp(name string, parts []string) error {
+	expression := strings.Join(parts, ",")
+	if _, err := regexp.Compile(expression); err != nil {
+		return fmt.Errorf("invalid %s regular expression %q: %v", name, expression, err)
+	}
+	return nil
+}
+
+func validateReplacePathRegex(name string, parts []string) error {
+	expression := strings.Fields(strings.Join(parts, ","))
+	if len(expression) != 2 {
+		return fmt.Errorf("%s expects a regular expression and a replacement separated by a space, got %q", name, strings.Join(parts, ","))
+	}
+	if _, err := regexp.Compile(expression[0]); err != nil {
+		return fmt.Errorf("invalid %s regular expression %q: %v", name, expression[0], err)
+	}
+	return nil
+}
+
+func validateSinglePath(name string, paths []string) error {
+	if len(paths) != 1 {
+		return fmt.Errorf("%s expects a single path, got %q", name, strings.Join(paths, ","))
+	}
+	return validatePaths(name, paths)
+}
+
+func validateMethods(name string, methods []string) error {
+	for _, method := range methods {
+		if !httpMethodRegexp.MatchString(method) {
+			return fmt.Errorf("%s expects HTTP methods, got %q", name, method)
+		}
+	}
+	return nil
+}
+
+var httpMethodRegexp = regexp.MustCompile(`^[A-Za-z]+$`)
+
+func validateHeaders(name string, headers []string) error {
+	if len(headers)%2 != 0 {
+		return fmt.Errorf("%s expects pairs of header name and value, got %q", name, strings.Join(headers, ","))
+	}
+	return nil
+}
+
+func validateQueries(name string, queries []string) error {
+	for _, query := range queries {
+		if !strings.Contains(query, "=") || strings.HasPrefix(query, "=") {
+			return fmt.Errorf("%s expects key=value parameters, got %q", name, query)
+		}
+	}
+	return nil
+}
+
+func validateQueryRegexps(name string, queries []string) error {
+	if err := validateQueries(name, queries); err != nil {
+		return err
+	}
+	for _, query := range queries {
+		expression := strings.SplitN(query, "=", 2)[1]
+		if _, err := regexp.Compile(expression); err != nil {
+			return fmt.Errorf("invalid %s regular expression %q: %v", name, expression, err)
+		}
+	}
+	return nil
+}
+
+// lookupRule returns the rule named name, checking its arguments
+func lookupRule(name string, arguments []string) (rule, error) {
+	function, ok := ruleSet[name]
+	if !ok {
+		return rule{}, unknownRuleError(name)
+	}
+	for _, argument := range arguments {
+		if len(argument) == 0 {
+			return rule{}, fmt.Errorf("empty argument for %s", name)
+		}
+	}
+	if function.validate != nil {
+		if err := function.validate(name, arguments); err != nil {
+			return rule{}, err
+		}
+	}
+	return function, nil
+}
+
+func (r *Rules) parseRules(expression string, onRule func(functionName string, function rule, arguments []string) error) error {
 	if len(expression) == 0 {
 		return errors.New("Empty rule")
 	}
@@ -173,17 +364,13 @@ func (r *Rules) parseRules(expression string, onRule func(functionName string, f
 
 	parsedRules := strings.FieldsFunc(expression, splitRule)
 
-	for _, rule := range parsedRules {
+	for _, expressionRule := range parsedRules {
 		// get function
-		parsedFunctions := strings.FieldsFunc(rule, f)
+		parsedFunctions := strings.FieldsFunc(expressionRule, f)
 		if len(parsedFunctions) == 0 {
-			return fmt.Errorf("error parsing rule: '%s'", rule)
+			return fmt.Errorf("error parsing rule: '%s'", expressionRule)
 		}
 		functionName := strings.TrimSpace(parsedFunctions[0])
-		parsedFunction, ok := functions[functionName]
-		if !ok {
-			return fmt.Errorf("error parsing rule: '%s'. Unknown function: '%s'", rule, parsedFunctions[0])
-		}
 		parsedFunctions = append(parsedFunctions[:0], parsedFunctions[1:]...)
 		fargs := func(c rune) bool {
 			return c == ','
@@ -191,14 +378,25 @@ func (r *Rules) parseRules(expression string, onRule func(functionName string, f
 		// get function
 		parsedArgs := strings.FieldsFunc(strings.Join(parsedFunctions, ":"), fargs)
 		if len(parsedArgs) == 0 {
-			return fmt.Errorf("error parsing args from rule: '%s'", rule)
+			if _, ok := ruleSet[functionName]; !ok {
+				return fmt.Errorf("error parsing rule: '%s': %v", expressionRule, unknownRuleError(functionName))
+			}
+			return fmt.Errorf("error parsing args from rule: '%s'", expressionRule)
 		}
 
 		for i := range parsedArgs {
 			parsedArgs[i] = strings.TrimSpace(parsedArgs[i])
+			if len(parsedArgs[i]) == 0 {
+				return fmt.Errorf("empty argument in rule: '%s'", expressionRule)
+			}
 		}
 
-		err := onRule(functionName, parsedFunction, parsedArgs)
+		parsedFunction, err := lookupRule(functionName, parsedArgs)
+		if err != nil {
+			return fmt.Errorf("error parsing rule: '%s': %v", expressionRule, err)
+		}
+
+		err = onRule(functionName, parsedFunction, parsedArgs)
 		if err != nil {
 			return fmt.Errorf("Parsing error on rule: %v", err)
 		}
@@ -208,26 +406,22 @@ func (r *Rules) parseRules(expression string, onRule func(functionName string, f
 
 // Parse parses rules expressions
 func (r *Rules) Parse(expression string) (*mux.Route, error) {
-	var resultRoute *mux.Route
-	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
-		inputs := make([]reflect.Value, len(arguments))
-		for i := range arguments {
-			inputs[i] = reflect.ValueOf(arguments[i])
-		}
-		method := reflect.ValueOf(function)
-		if method.IsValid() {
-			resultRoute = method.Call(inputs)[0].Interface().(*mux.Route)
-			if r.err != nil {
-				return r.err
-			}
-			if resultRoute.GetError() != nil {
-				return resultRoute.GetError()
-			}
-
-		} else {
-			return fmt.Errorf("Method not found: '%s'", functionName)
+	if isRuleExpression(expression) {
+		tree, err := parseRuleExpression(expression)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rule: %v", err)
 		}
-		return nil
+		route, err := r.applyRuleExpression(tree)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rule: %v", err)
+		}
+		return route, nil
+	}
+
+	var resultRoute *mux.Route
+	err := r.parseRules(expression, func(functionName string, function rule, arguments []string) error {
+		resultRoute = function.apply(r, arguments...)
+		return resultRoute.GetError()
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error parsing rule: %v", err)
@@ -235,10 +429,25 @@ func (r *Rules) Parse(expression string) (*mux.Route, error) {
 	return resultRoute, nil
 }
 
+// validateRule parses the rule on a route of its own, for the rules of the configurations to be checked before they are loaded
+func validateRule(expression string) error {
+	_, err := (&Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}).Parse(expression)
+	return err
+}
+
 // ParseDomains parses rules expressions and returns domains
 func (r *Rules) ParseDomains(expression string) ([]string, error) {
 	domains := []string{}
-	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
+	if isRuleExpression(expression) {
+		tree, err := parseRuleExpression(expression)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing domains: %v", err)
+		}
+		domains = append(domains, ruleExpressionDomains(tree, false)...)
+		return fun.Map(types.CanonicalDomain, domains).([]string), nil
+	}
+
+	err := r.parseRules(expression, func(functionName string, function rule, arguments []string) error {
 		if functionName == "Host" {
 			domains = append(domains, arguments...)
 		}
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/containous/mux"
+)
+
+// ruleExpressionRegexp matches the rules written as boolean expressions, e.g. Host(`foo.bar`) && !Method(`DELETE`)
+var ruleExpressionRegexp = regexp.MustCompile(`^[\s!(]*[A-Za-z]+\s*\(`)
+
+func isRuleExpression(expression string) bool {
+	return ruleExpressionRegexp.MatchString(expression)
+}
+
+// ruleExpression is a node of the predicate tree of a boolean rule expression
+type ruleExpression struct {
+	// operator is "&&", "||" or "!", or empty for a rule
+	operator  string
+	operands  []*ruleExpression
+	name      string
+	arguments []string
+}
+
+type ruleTokenKind int
+
+const (
+	ruleTokenName ruleTokenKind = iota
+	ruleTokenArgument
+	ruleTokenOperator
+	ruleTokenEnd
+)
+
+type ruleToken struct {
+	kind     ruleTokenKind
+	value    string
+	position int
+}
+
+func (t ruleToken) String() string {
+	switch t.kind {
+	case ruleTokenEnd:
+		return "end of rule"
+	case ruleTokenArgument:
+		return fmt.Sprintf("`%s` at position %d", t.value, t.position)
+	default:
+		return fmt.Sprintf("'%s' at position %d", t.value, t.position)
+	}
+}
+
+func tokenizeRuleExpression(expression string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == ',' || c == '!':
+			tokens = append(tokens, ruleToken{kind: ruleTokenOperator, value: string(c), position: i})
+			i++
+		case strings.HasPrefix(expression[i:], "&&") || strings.HasPrefix(expression[i:], "||"):
+			tokens = append(tokens, ruleToken{kind: ruleTokenOperator, value: expression[i : i+2], position: i})
+			i += 2
+		case c == '`':
+			end := strings.IndexByte(expression[i+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated argument at position %d", i)
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenArgument, value: expression[i+1 : i+1+end], position: i})
+			i += end + 2
+		case unicode.IsLetter(c):
+			start := i
+			for i < len(expression) && unicode.IsLetter(rune(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenName, value: expression[start:i], position: start})
+		default:
+			return nil, fmt.Errorf("unexpected '%c' at position %d", c, i)
+		}
+	}
+	return append(tokens, ruleToken{kind: ruleTokenEnd, position: len(expression)}), nil
+}
+
+// ruleExpressionParser is a recursive descent parser of the grammar:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | rule
+//	rule    = name "(" argument { "," argument } ")"
+type ruleExpressionParser struct {
+	tokens []ruleToken
+	next   int
+}
+
+func parseRuleExpression(expression string) (*ruleExpression, error) {
+	tokens, err := tokenizeRuleExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &ruleExpressionParser{tokens: tokens}
+	tree, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := parser.peek(); token.kind != ruleTokenEnd {
+		return nil, fmt.Errorf("unexpected %s", token)
+	}
+	return tree, nil
+}
+
+func (p *ruleExpressionParser) peek() ruleToken {
+	return p.tokens[p.next]
+}
+
+func (p *ruleExpressionParser) accept(operator string) bool {
+	if token := p.peek(); token.kind == ruleTokenOperator && token.value == operator {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *ruleExpressionParser) expect(operator string) error {
+	if !p.accept(operator) {
+		return fmt.Errorf("expected '%s', got %s", operator, p.peek())
+	}
+	return nil
+}
+
+func (p *ruleExpressionParser) parseOr() (*ruleExpression, error) {
+	return p.parseBinary("||", p.parseAnd)
+}
+
+func (p *ruleExpressionParser) parseAnd() (*ruleExpression, error) {
+	return p.parseBinary("&&", p.parseUnary)
+}
+
+func (p *ruleExpressionParser) parseBinary(operator string, parseOperand func() (*ruleExpression, error)) (*ruleExpression, error) {
+	operand, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []*ruleExpression{operand}
+	for p.accept(operator) {
+		operand, err = parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return operand, nil
+	}
+	return &ruleExpression{operator: operator, operands: operands}, nil
+}
+
+func (p *ruleExpressionParser) parseUnary() (*ruleExpression, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleExpression{operator: "!", operands: []*ruleExpression{operand}}, nil
+	}
+	if p.accept("(") {
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return expression, p.expect(")")
+	}
+	return p.parseRule()
+}
+
+func (p *ruleExpressionParser) parseRule() (*ruleExpression, error) {
+	token := p.peek()
+	if token.kind != ruleTokenName {
+		return nil, fmt.Errorf("expected a rule, got %s", token)
+	}
+	p.next++
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	expression := &ruleExpression{name: token.value}
+	for {
+		argument := p.peek()
+		if argument.kind != ruleTokenArgument {
+			return nil, fmt.Errorf("expected a `quoted` argument of %s, got %s", token.value, argument)
+		}
+		p.next++
+		expression.arguments = append(expression.arguments, strings.TrimSpace(argument.value))
+		if !p.accept(",") {
+			break
+		}
+	}
+	return expression, p.expect(")")
+}
+
+// applyRuleExpression adds the expression to the route: the rules ANDed at the top of the expression
+// are applied to the route itself, the other ones being matched by a predicate
+func (r *Rules) applyRuleExpression(expression *ruleExpression) (*mux.Route, error) {
+	switch expression.operator {
+	case "&&":
+		var route *mux.Route
+		for _, operand := range expression.operands {
+			var err error
+			route, err = r.applyRuleExpression(operand)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return route, nil
+	case "":
+		function, err := lookupRule(expression.name, expression.arguments)
+		if err != nil {
+			return nil, err
+		}
+		route := function.apply(r, expression.arguments...)
+		return route, route.GetError()
+	default:
+		matcher, err := ruleExpressionMatcher(expression)
+		if err != nil {
+			return nil, err
+		}
+		return r.route.route.MatcherFunc(matcher), nil
+	}
+}
+
+// ruleExpressionMatcher returns the predicate of the expression, each rule being matched by a route of its own
+func ruleExpressionMatcher(expression *ruleExpression) (mux.MatcherFunc, error) {
+	var matchers []mux.MatcherFunc
+	for _, operand := range expression.operands {
+		matcher, err := ruleExpressionMatcher(operand)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	switch expression.operator {
+	case "!":
+		return func(req *http.Request, match *mux.RouteMatch) bool {
+			return !matchers[0](req, match)
+		}, nil
+	case "&&":
+		return func(req *http.Request, match *mux.RouteMatch) bool {
+			for _, matcher := range matchers {
+				if !matcher(req, match) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "||":
+		return func(req *http.Request, match *mux.RouteMatch) bool {
+			for _, matcher := range matchers {
+				if matcher(req, match) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	function, err := lookupRule(expression.name, expression.arguments)
+	if err != nil {
+		return nil, err
+	}
+	if function.modifier {
+		return nil, fmt.Errorf("modifier rule %s can't be negated nor combined with ||", expression.name)
+	}
+	rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+	route := function.apply(rules, expression.arguments...)
+	if err := route.GetError(); err != nil {
+		return nil, err
+	}
+	return func(req *http.Request, match *mux.RouteMatch) bool {
+		ruleMatch := &mux.RouteMatch{}
+		if !route.Match(req, ruleMatch) {
+			return false
+		}
+		// the variables captured by the rule are the ones of the route
+		if match.Vars == nil {
+			match.Vars = make(map[string]string)
+		}
+		for name, value := range ruleMatch.Vars {
+			match.Vars[name] = value
+		}
+		return true
+	}, nil
+}
+
+// ruleExpressionDomains returns the arguments of the Host rules which are not negated
+func ruleExpressionDomains(expression *ruleExpression, negated bool) []string {
+	if expression.operator == "" {
+		if expression.name == "Host" && !negated {
+			return expression.arguments
+		}
+		return nil
+	}
+	var domains []string
+	for _, operand := range expression.operands {
+		domains = append(domains, ruleExpressionDomains(operand, negated != (expression.operator == "!"))...)
+	}
+	return domains
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/containous/mux"
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRuleExpression(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+		requests   map[string]bool
+	}{
+		{
+			desc:       "and with negation",
+			expression: "Host(`api.example.com`) && PathPrefix(`/v2`) && !Method(`DELETE`)",
+			requests: map[string]bool{
+				"GET http://api.example.com/v2/users":        true,
+				"DELETE http://api.example.com/v2/users":     false,
+				"GET http://api.example.com/v1/users":        false,
+				"GET http://www.example.com/v2/users":        false,
+				"POST http://API.example.com:8080/v2/users/": true,
+			},
+		},
+		{
+			desc:       "or",
+			expression: "Host(`foo.bar`) || Path(`/foo`, `/bar`)",
+			requests: map[string]bool{
+				"GET http://foo.bar/":      true,
+				"GET http://other.bar/":    false,
+				"GET http://other.bar/foo": true,
+				"GET http://other.bar/bar": true,
+			},
+		},
+		{
+			desc:       "precedence of and over or",
+			expression: "Host(`foo.bar`) && Method(`GET`) || Host(`other.bar`)",
+			requests: map[string]bool{
+				"GET http://foo.bar/":    true,
+				"POST http://foo.bar/":   false,
+				"POST http://other.bar/": true,
+			},
+		},
+		{
+			desc:       "parentheses",
+			expression: "Host(`foo.bar`) && (Method(`GET`) || Headers(`X-Foo`, `bar`))",
+			requests: map[string]bool{
+				"GET http://foo.bar/":   true,
+				"POST http://foo.bar/":  false,
+				"GET http://other.bar/": false,
+			},
+		},
+		{
+			desc:       "negated group",
+			expression: "!(PathPrefix(`/admin`) || Query(`debug=true`))",
+			requests: map[string]bool{
+				"GET http://foo.bar/":             true,
+				"GET http://foo.bar/admin/users":  false,
+				"GET http://foo.bar/?debug=true":  false,
+				"GET http://foo.bar/?debug=false": true,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+			routeResult, err := rules.Parse(test.expression)
+			require.NoError(t, err)
+
+			for request, expected := range test.requests {
+				methodURL := strings.SplitN(request, " ", 2)
+				match := routeResult.Match(testhelpers.MustNewRequest(methodURL[0], methodURL[1], nil), &mux.RouteMatch{Route: routeResult})
+				assert.Equal(t, expected, match, request)
+			}
+		})
+	}
+}
+
+func TestParseRuleExpressionModifiers(t *testing.T) {
+	serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+	rules := &Rules{route: serverRoute}
+
+	routeResult, err := rules.Parse("Host(`foo.bar`) && PathPrefixStrip(`/api`) && AddPrefix(`/v1`)")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/api"}, serverRoute.stripPrefixes)
+	assert.Equal(t, "/v1", serverRoute.addPrefix)
+	request := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/api/users", nil)
+	assert.True(t, routeResult.Match(request, &mux.RouteMatch{Route: routeResult}))
+}
+
+func TestParseRuleExpressionVariables(t *testing.T) {
+	rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+	routeResult, err := rules.Parse("HostRegexp(`{subdomain:[a-z]+}.foo.bar`) && (PathRegexp(`^/users/(?P<id>[0-9]+)$`) || Path(`/groups/{id:[0-9]+}`))")
+	require.NoError(t, err)
+
+	match := &mux.RouteMatch{Route: routeResult}
+	require.True(t, routeResult.Match(testhelpers.MustNewRequest(http.MethodGet, "http://api.foo.bar/groups/42", nil), match))
+	assert.Equal(t, map[string]string{"subdomain": "api", "id": "42"}, match.Vars)
+}
+
+func TestParseInvalidRuleExpression(t *testing.T) {
+	testCases := []struct {
+		expression    string
+		expectedError string
+	}{
+		{
+			expression:    "Host(`foo.bar`) &&",
+			expectedError: "expected a rule, got end of rule",
+		},
+		{
+			expression:    "Host(foo)",
+			expectedError: "expected a `quoted` argument of Host, got 'foo' at position 5",
+		},
+		{
+			expression:    "Host(`foo.bar`",
+			expectedError: "expected ')', got end of rule",
+		},
+		{
+			expression:    "Host(`foo.bar)",
+			expectedError: "unterminated argument at position 5",
+		},
+		{
+			expression:    "Host(`foo.bar`) & Method(`GET`)",
+			expectedError: "unexpected '&' at position 16",
+		},
+		{
+			expression:    "(Host(`foo.bar`)) Method(`GET`)",
+			expectedError: "unexpected 'Method' at position 18",
+		},
+		{
+			expression:    "Hots(`foo.bar`)",
+			expectedError: "unknown rule 'Hots'",
+		},
+		{
+			expression:    "!Host(`foo.bar`) && PathPrefix(`api`)",
+			expectedError: `PathPrefix expects paths starting with a slash, got "api"`,
+		},
+		{
+			expression:    "Host(`foo.bar`) || PathPrefixStrip(`/api`)",
+			expectedError: "modifier rule PathPrefixStrip can't be negated nor combined with ||",
+		},
+		{
+			expression:    "!AddPrefix(`/api`)",
+			expectedError: "modifier rule AddPrefix can't be negated nor combined with ||",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+			_, err := rules.Parse(test.expression)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestParseRuleExpressionDomains(t *testing.T) {
+	testCases := []struct {
+		expression string
+		domains    []string
+	}{
+		{
+			expression: "Host(`Foo.Bar`, `test.bar`) && Path(`/test`)",
+			domains:    []string{"foo.bar", "test.bar"},
+		},
+		{
+			expression: "Host(`foo.bar`) || (Host(`other.bar`) && !Host(`excluded.bar`))",
+			domains:    []string{"foo.bar", "other.bar"},
+		},
+		{
+			expression: "PathPrefix(`/test`)",
+			domains:    []string{},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			domains, err := (&Rules{}).ParseDomains(test.expression)
+			require.NoError(t, err)
+			assert.Equal(t, test.domains, domains)
+		})
+	}
+}
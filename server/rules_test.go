@@ -49,6 +49,281 @@ func TestParseTwoRules(t *testing.T) {
 	assert.True(t, routeMatch, "Rule %s don't match.", expression)
 }
 
+func TestParseReplacePathRegex(t *testing.T) {
+	testCases := []struct {
+		expression          string
+		expectedRegex       string
+		expectedReplacement string
+		expectedError       bool
+	}{
+		{
+			expression:          "ReplacePathRegex: ^/api/(.*) /v1/$1",
+			expectedRegex:       "^/api/(.*)",
+			expectedReplacement: "/v1/$1",
+		},
+		{
+			expression:          "ReplacePathRegex: ^/v([0-9]{1,3})/(.*) /$2",
+			expectedRegex:       "^/v([0-9]{1,3})/(.*)",
+			expectedReplacement: "/$2",
+		},
+		{
+			expression:    "ReplacePathRegex: ^/api/(.*)",
+			expectedError: true,
+		},
+		{
+			expression:    "ReplacePathRegex: ^/api/(.* /v1/$1",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			router := mux.NewRouter()
+			route := router.NewRoute()
+			serverRoute := &serverRoute{route: route}
+			rules := &Rules{route: serverRoute}
+
+			_, err := rules.Parse(test.expression)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err, "Error while building route for %s", test.expression)
+			assert.Equal(t, test.expectedRegex, serverRoute.replacePathRegex)
+			assert.Equal(t, test.expectedReplacement, serverRoute.replacePathReplacement)
+		})
+	}
+}
+
+func TestParseInvalidRules(t *testing.T) {
+	testCases := []struct {
+		expression    string
+		expectedError string
+	}{
+		{
+			expression:    "Hots:foo.bar",
+			expectedError: "unknown rule 'Hots', expected one of AddPrefix, Headers,",
+		},
+		{
+			expression:    "host:foo.bar",
+			expectedError: "unknown rule 'host', did you mean 'Host'?",
+		},
+		{
+			expression:    "Host:foo.bar/api",
+			expectedError: `Host expects host names, got "foo.bar/api"`,
+		},
+		{
+			expression:    "PathPrefix:api",
+			expectedError: `PathPrefix expects paths starting with a slash, got "api"`,
+		},
+		{
+			expression:    "AddPrefix:/foo,/bar",
+			expectedError: `AddPrefix expects a single path, got "/foo,/bar"`,
+		},
+		{
+			expression:    "Method:GET,PO ST",
+			expectedError: `Method expects HTTP methods, got "PO ST"`,
+		},
+		{
+			expression:    "Headers:Content-Type",
+			expectedError: `Headers expects pairs of header name and value, got "Content-Type"`,
+		},
+		{
+			expression:    "Query:foo",
+			expectedError: `Query expects key=value parameters, got "foo"`,
+		},
+		{
+			expression:    "PathRegexp:^/users/(?P<id>[0-9]+",
+			expectedError: `invalid PathRegexp regular expression "^/users/(?P<id>[0-9]+"`,
+		},
+		{
+			expression:    "QueryRegexp:version=(beta",
+			expectedError: `invalid QueryRegexp regular expression "(beta"`,
+		},
+		{
+			expression:    "Host:foo.bar, ,test.bar",
+			expectedError: "empty argument in rule: 'Host:foo.bar, ,test.bar'",
+		},
+		{
+			expression:    "ReplacePathRegex: ^/api/(.*)",
+			expectedError: `ReplacePathRegex expects a regular expression and a replacement separated by a space, got "^/api/(.*)"`,
+		},
+		{
+			expression:    "ReplacePathRegex: ^/api/(.* /v1/$1",
+			expectedError: `invalid ReplacePathRegex regular expression "^/api/(.*"`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+
+			_, err := rules.Parse(test.expression)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestParseValidRules(t *testing.T) {
+	testCases := []struct {
+		expression string
+		request    string
+		method     string
+		header     string
+	}{
+		{
+			expression: "HostRegexp:{subdomain:[a-z]+}.bar",
+			request:    "http://foo.bar/",
+		},
+		{
+			expression: "Method:get,POST",
+			request:    "http://foo.bar/",
+			method:     http.MethodPost,
+		},
+		{
+			expression: "Headers:X-Foo,bar",
+			request:    "http://foo.bar/",
+			header:     "bar",
+		},
+		{
+			expression: "Query:foo=bar,baz=",
+			request:    "http://foo.bar/?foo=bar&baz=",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+
+			routeResult, err := rules.Parse(test.expression)
+			require.NoError(t, err)
+
+			method := http.MethodGet
+			if len(test.method) > 0 {
+				method = test.method
+			}
+			request := testhelpers.MustNewRequest(method, test.request, nil)
+			if len(test.header) > 0 {
+				request.Header.Set("X-Foo", test.header)
+			}
+			assert.True(t, routeResult.Match(request, &mux.RouteMatch{Route: routeResult}))
+		})
+	}
+}
+
+func TestParsePathRegexp(t *testing.T) {
+	rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+	routeResult, err := rules.Parse("PathRegexp:^/users/(?P<id>[0-9]{1,4})/(?P<section>[a-z]+)$")
+	require.NoError(t, err)
+
+	match := &mux.RouteMatch{Route: routeResult}
+	require.True(t, routeResult.Match(testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/users/42/posts", nil), match))
+	assert.Equal(t, map[string]string{"id": "42", "section": "posts"}, match.Vars)
+
+	assert.False(t, routeResult.Match(testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/users/12345/posts", nil), &mux.RouteMatch{Route: routeResult}))
+}
+
+func TestParseHostRules(t *testing.T) {
+	testCases := []struct {
+		expression string
+		requests   map[string]bool
+	}{
+		{
+			expression: "Host: Foo.Bar ,test.bar",
+			requests: map[string]bool{
+				"http://foo.bar/":      true,
+				"http://FOO.bar:8080/": true,
+				"http://test.bar/":     true,
+				"http://foo.bar.baz/":  false,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+			routeResult, err := rules.Parse(test.expression)
+			require.NoError(t, err)
+
+			for requestURL, expected := range test.requests {
+				request := testhelpers.MustNewRequest(http.MethodGet, requestURL, nil)
+				assert.Equal(t, expected, routeResult.Match(request, &mux.RouteMatch{Route: routeResult}), requestURL)
+			}
+		})
+	}
+}
+
+func TestParseQueryRules(t *testing.T) {
+	testCases := []struct {
+		expression string
+		requests   map[string]bool
+	}{
+		{
+			expression: "Query:version=beta",
+			requests: map[string]bool{
+				"http://foo.bar/?version=beta":  true,
+				"http://foo.bar/?version=beta2": false,
+				"http://foo.bar/":               false,
+			},
+		},
+		{
+			expression: "Query:token=a=b",
+			requests: map[string]bool{
+				"http://foo.bar/?token=a%3Db": true,
+				"http://foo.bar/?token=a":     false,
+			},
+		},
+		{
+			expression: "QueryRegexp:version=^beta[0-9]*$",
+			requests: map[string]bool{
+				"http://foo.bar/?version=beta":                true,
+				"http://foo.bar/?version=beta2":               true,
+				"http://foo.bar/?version=stable&version=beta": true,
+				"http://foo.bar/?version=stable":              false,
+				"http://foo.bar/":                             false,
+			},
+		},
+		{
+			expression: "QueryRegexp:version=^beta,channel=.+",
+			requests: map[string]bool{
+				"http://foo.bar/?version=beta&channel=canary": true,
+				"http://foo.bar/?version=beta&channel=":       false,
+				"http://foo.bar/?version=beta":                false,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{route: &serverRoute{route: mux.NewRouter().NewRoute()}}
+			routeResult, err := rules.Parse(test.expression)
+			require.NoError(t, err)
+
+			for requestURL, expected := range test.requests {
+				request := testhelpers.MustNewRequest(http.MethodGet, requestURL, nil)
+				assert.Equal(t, expected, routeResult.Match(request, &mux.RouteMatch{Route: routeResult}), requestURL)
+			}
+		})
+	}
+}
+
 func TestParseDomains(t *testing.T) {
 	rules := &Rules{}
 
@@ -87,6 +362,51 @@ func TestParseDomains(t *testing.T) {
 	}
 }
 
+func TestParseDomainsInvalidRules(t *testing.T) {
+	testCases := []string{
+		"Host:foo.bar;Hots:test.bar",
+		"Host:foo.bar;ReplacePathRegex: ^/api/(.* /v1/$1",
+	}
+
+	for _, expression := range testCases {
+		expression := expression
+		t.Run(expression, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Rules{}
+			_, err := rules.ParseDomains(expression)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	testCases := []struct {
+		expression string
+		valid      bool
+	}{
+		{expression: "Host:foo.bar;PathPrefixStrip:/api", valid: true},
+		{expression: "Host(`foo.bar`) && !Method(`DELETE`)", valid: true},
+		{expression: "Host:foo.bar;Hots:test.bar"},
+		{expression: "Host(`foo.bar`) &&"},
+		{expression: "PathRegexp:/api/(.*"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRule(test.expression)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
 func TestPriorites(t *testing.T) {
 	router := mux.NewRouter()
 	router.StrictSlash(true)
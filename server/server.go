@@ -16,29 +16,41 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/armon/go-proxyproto"
+	"github.com/containous/flaeg"
 	"github.com/containous/mux"
 	"github.com/containous/traefik/cluster"
 	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/geoip"
 	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/http3"
+	"github.com/containous/traefik/loadbalancer"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/middlewares"
 	"github.com/containous/traefik/middlewares/accesslog"
 	mauth "github.com/containous/traefik/middlewares/auth"
+	"github.com/containous/traefik/plugins"
 	"github.com/containous/traefik/provider"
+	"github.com/containous/traefik/provider/web"
+	"github.com/containous/traefik/proxyprotocol"
+	"github.com/containous/traefik/resolver"
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/server/cookie"
+	"github.com/containous/traefik/tcp"
+	"github.com/containous/traefik/tracing"
 	"github.com/containous/traefik/types"
+	"github.com/containous/traefik/udp"
 	"github.com/containous/traefik/whitelist"
-	"github.com/streamrail/concurrent-map"
+	gokitmetrics "github.com/go-kit/kit/metrics"
 	thoas_stats "github.com/thoas/stats"
 	"github.com/urfave/negroni"
 	"github.com/vulcand/oxy/cbreaker"
-	"github.com/vulcand/oxy/connlimit"
 	"github.com/vulcand/oxy/forward"
 	"github.com/vulcand/oxy/ratelimit"
 	"github.com/vulcand/oxy/roundrobin"
@@ -54,10 +66,11 @@ var (
 type Server struct {
 	serverEntryPoints             serverEntryPoints
 	configurationChan             chan types.ConfigMessage
-	configurationValidatedChan    chan types.ConfigMessage
+	configurationValidatedChan    chan types.Configurations
 	signals                       chan os.Signal
 	stopChan                      chan bool
 	providers                     []provider.Provider
+	runningProviders              map[provider.Provider]*runningProvider
 	currentConfigurations         safe.Safe
 	globalConfiguration           configuration.GlobalConfiguration
 	accessLoggerMiddleware        *accesslog.LogHandler
@@ -65,22 +78,102 @@ type Server struct {
 	leadership                    *cluster.Leadership
 	defaultForwardingRoundTripper http.RoundTripper
 	metricsRegistry               metrics.Registry
+	caches                        *middlewares.CacheRegistry
+	statistics                    *middlewares.StatisticsRegistry
+	tracer                        *tracing.Tracer
+	defaultBackend                http.Handler
+	geoIPDatabase                 *geoip.Database
+	activatedSockets              *activatedSockets
+	takenOverSockets              takenOverSockets
+	globalConfigurationLoader     func() (*configuration.GlobalConfiguration, error)
+	loadedGlobalConfiguration     *configuration.GlobalConfiguration
+	globalConfigurationChan       chan *configuration.GlobalConfiguration
+	loadedBackends                *loadedBackends
+	loadedFrontends               *loadedFrontends
+	transports                    transportPool
 }
 
 type serverEntryPoints map[string]*serverEntryPoint
 
 type serverEntryPoint struct {
-	httpServer *http.Server
-	listener   net.Listener
-	httpRouter *middlewares.HandlerSwitcher
+	httpServer         *http.Server
+	listener           net.Listener
+	httpRouter         *middlewares.HandlerSwitcher
+	tcpRouter          *tcp.HandlerSwitcher
+	tlsConfig          *tls.Config
+	udpLoadBalancer    *udp.LoadBalancerSwitcher
+	udpConn            net.PacketConn
+	http3Server        *http3.Server
+	http3Conn          net.PacketConn
+	certs              safe.Safe
+	dynamicCerts       safe.Safe
+	acmeGetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	served             chan struct{} // closed once the entry point has stopped serving
+}
+
+// entryPointCertificates holds the certificates served by a TLS entry point,
+// indexed by domain, and the certificate to fall back to when no domain matches.
+type entryPointCertificates struct {
+	domains            map[string]*tls.Certificate
+	defaultCertificate *tls.Certificate
+}
+
+// getCertificate selects the certificate to serve using the SNI sent by the client.
+// The certificates provided dynamically and then the ACME ones are looked up if no
+// configured certificate matches, before falling back to the default certificate.
+func (s *serverEntryPoint) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs, ok := s.certs.Get().(*entryPointCertificates)
+	if !ok {
+		return nil, nil
+	}
+
+	domain := types.CanonicalDomain(clientHello.ServerName)
+	if cert := getCertificateForDomain(certs.domains, domain); cert != nil {
+		return cert, nil
+	}
+
+	if dynamicCerts, ok := s.dynamicCerts.Get().(map[string]*tls.Certificate); ok {
+		if cert := getCertificateForDomain(dynamicCerts, domain); cert != nil {
+			return cert, nil
+		}
+	}
+
+	if s.acmeGetCertificate != nil {
+		cert, err := s.acmeGetCertificate(clientHello)
+		if cert != nil || err != nil {
+			return cert, err
+		}
+	}
+	return certs.defaultCertificate, nil
+}
+
+func getCertificateForDomain(certs map[string]*tls.Certificate, domain string) *tls.Certificate {
+	if len(domain) == 0 {
+		return nil
+	}
+	if cert, ok := certs[domain]; ok {
+		return cert
+	}
+
+	// try replacing the first label of the domain with a wildcard
+	labels := strings.Split(domain, ".")
+	if len(labels) > 1 {
+		labels[0] = "*"
+		if cert, ok := certs[strings.Join(labels, ".")]; ok {
+			return cert
+		}
+	}
+	return nil
 }
 
 type serverRoute struct {
-	route              *mux.Route
-	stripPrefixes      []string
-	stripPrefixesRegex []string
-	addPrefix          string
-	replacePath        string
+	route                  *mux.Route
+	stripPrefixes          []string
+	stripPrefixesRegex     []string
+	addPrefix              string
+	replacePath            string
+	replacePathRegex       string
+	replacePathReplacement string
 }
 
 // NewServer returns an initialized Server.
@@ -89,8 +182,9 @@ func NewServer(globalConfiguration configuration.GlobalConfiguration) *Server {
 
 	server.serverEntryPoints = make(map[string]*serverEntryPoint)
 	server.configurationChan = make(chan types.ConfigMessage, 100)
-	server.configurationValidatedChan = make(chan types.ConfigMessage, 100)
+	server.configurationValidatedChan = make(chan types.Configurations, 100)
 	server.signals = make(chan os.Signal, 1)
+	server.globalConfigurationChan = make(chan *configuration.GlobalConfiguration, 1)
 	server.stopChan = make(chan bool, 1)
 	server.providers = []provider.Provider{}
 	server.configureSignals()
@@ -98,13 +192,62 @@ func NewServer(globalConfiguration configuration.GlobalConfiguration) *Server {
 	server.currentConfigurations.Set(currentConfigurations)
 	server.globalConfiguration = globalConfiguration
 	server.routinesPool = safe.NewPool(context.Background())
-	server.defaultForwardingRoundTripper = createHTTPTransport(globalConfiguration)
+	server.activatedSockets = newActivatedSockets()
+	server.defaultForwardingRoundTripper = createHTTPTransport(globalConfiguration, nil)
+	server.caches = middlewares.NewCacheRegistry()
+	if globalConfiguration.Web != nil {
+		globalConfiguration.Web.Caches = server.caches
+		if globalConfiguration.Web.Statistics != nil {
+			server.statistics = middlewares.NewStatisticsRegistry()
+			globalConfiguration.Web.StatisticsRegistry = server.statistics
+		}
+	}
 
 	server.metricsRegistry = metrics.NewVoidRegistry()
 	if globalConfiguration.Web != nil && globalConfiguration.Web.Metrics != nil {
 		server.registerMetricClients(globalConfiguration.Web.Metrics)
 	}
 
+	if globalConfiguration.Web != nil && len(globalConfiguration.Web.EntryPoint) > 0 {
+		if _, ok := globalConfiguration.EntryPoints[globalConfiguration.Web.EntryPoint]; !ok {
+			log.Errorf("Undefined entrypoint '%s' for the API", globalConfiguration.Web.EntryPoint)
+		}
+	}
+
+	if globalConfiguration.Ping != nil {
+		if _, ok := globalConfiguration.EntryPoints[globalConfiguration.Ping.EntryPoint]; !ok {
+			log.Errorf("Undefined entrypoint '%s' for ping", globalConfiguration.Ping.EntryPoint)
+		}
+	}
+
+	if globalConfiguration.GeoIP != nil && len(globalConfiguration.GeoIP.Database) > 0 {
+		database, err := geoip.Open(globalConfiguration.GeoIP.Database)
+		if err != nil {
+			log.Errorf("Unable to load the GeoIP database %s: %v", globalConfiguration.GeoIP.Database, err)
+		} else {
+			log.Infof("Locating the clients with the GeoIP database %s (%s)", globalConfiguration.GeoIP.Database, database.Type())
+			server.geoIPDatabase = database
+		}
+	}
+
+	if globalConfiguration.DefaultBackend != nil {
+		defaultBackend, err := middlewares.NewDefaultBackend(globalConfiguration.DefaultBackend)
+		if err != nil {
+			log.Errorf("Unable to create the default backend: %v", err)
+		} else {
+			server.defaultBackend = defaultBackend
+		}
+	}
+
+	if globalConfiguration.Tracing != nil {
+		tracer, err := tracing.NewTracer(globalConfiguration.Tracing)
+		if err != nil {
+			log.Errorf("Unable to create tracer: %s", err)
+		} else {
+			server.tracer = tracer
+		}
+	}
+
 	if globalConfiguration.Cluster != nil {
 		// leadership creation if cluster mode
 		server.leadership = cluster.NewLeadership(server.routinesPool.Ctx(), globalConfiguration.Cluster)
@@ -129,26 +272,25 @@ func NewServer(globalConfiguration configuration.GlobalConfiguration) *Server {
 // An exception to this is the MaxIdleConns setting as we only provide the option MaxIdleConnsPerHost
 // in Traefik at this point in time. Setting this value to the default of 100 could lead to confusing
 // behaviour and backwards compatibility issues.
-func createHTTPTransport(globalConfiguration configuration.GlobalConfiguration) *http.Transport {
-	dialer := &net.Dialer{
-		Timeout:   configuration.DefaultDialTimeout,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}
-	if globalConfiguration.ForwardingTimeouts != nil {
-		dialer.Timeout = time.Duration(globalConfiguration.ForwardingTimeouts.DialTimeout)
-	}
-
+func createHTTPTransport(globalConfiguration configuration.GlobalConfiguration, tlsConfig *tls.Config) *http.Transport {
 	transport := &http.Transport{
 		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dialer.DialContext,
+		DialContext:           createDialContext(globalConfiguration),
 		MaxIdleConnsPerHost:   globalConfiguration.MaxIdleConnsPerHost,
-		IdleConnTimeout:       90 * time.Second,
+		IdleConnTimeout:       configuration.DefaultIdleConnTimeout,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	if globalConfiguration.ForwardingTransport != nil {
+		transport.MaxIdleConns = globalConfiguration.ForwardingTransport.MaxIdleConns
+		transport.DisableKeepAlives = globalConfiguration.ForwardingTransport.DisableKeepAlives
+		transport.DisableCompression = globalConfiguration.ForwardingTransport.DisableCompression
+	}
 	if globalConfiguration.ForwardingTimeouts != nil {
 		transport.ResponseHeaderTimeout = time.Duration(globalConfiguration.ForwardingTimeouts.ResponseHeaderTimeout)
+		if globalConfiguration.ForwardingTimeouts.IdleConnTimeout > 0 {
+			transport.IdleConnTimeout = time.Duration(globalConfiguration.ForwardingTimeouts.IdleConnTimeout)
+		}
 	}
 	if globalConfiguration.InsecureSkipVerify {
 		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
@@ -158,11 +300,89 @@ func createHTTPTransport(globalConfiguration configuration.GlobalConfiguration)
 			RootCAs: createRootCACertPool(globalConfiguration.RootCAs),
 		}
 	}
+	if tlsConfig != nil {
+		// the global settings apply to the connections not overriding them
+		if tlsConfig.RootCAs == nil && len(globalConfiguration.RootCAs) > 0 {
+			tlsConfig.RootCAs = createRootCACertPool(globalConfiguration.RootCAs)
+		}
+		if globalConfiguration.InsecureSkipVerify {
+			tlsConfig.InsecureSkipVerify = true
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	// the TLS client configuration must be set beforehand to advertise HTTP/2 to the servers
 	http2.ConfigureTransport(transport)
 
 	return transport
 }
 
+// createH2CTransport creates a transport forwarding the requests with cleartext HTTP/2 (h2c)
+// to the servers using the http scheme.
+func createH2CTransport(globalConfiguration configuration.GlobalConfiguration) *http.Transport {
+	transport := createHTTPTransport(globalConfiguration, nil)
+	transport.RegisterProtocol("http", &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			// the dial function of the transport may be replaced after its creation
+			return transport.DialContext(context.Background(), network, addr)
+		},
+	})
+	return transport
+}
+
+func createDialer(globalConfiguration configuration.GlobalConfiguration) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   configuration.DefaultDialTimeout,
+		KeepAlive: configuration.DefaultKeepAlive,
+		DualStack: true,
+	}
+	if globalConfiguration.ForwardingTimeouts != nil {
+		dialer.Timeout = time.Duration(globalConfiguration.ForwardingTimeouts.DialTimeout)
+	}
+	if globalConfiguration.ForwardingTransport != nil {
+		if keepAlive := time.Duration(globalConfiguration.ForwardingTransport.KeepAlive); keepAlive > 0 {
+			dialer.KeepAlive = keepAlive
+		} else if keepAlive < 0 {
+			dialer.KeepAlive = 0
+		}
+	}
+	return dialer
+}
+
+// createDialContext returns the function dialing the backend servers, setting the sizes of the TCP buffers
+// of the connections if configured, and tracking the connections to host names when they are resolved periodically
+func createDialContext(globalConfiguration configuration.GlobalConfiguration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := createDialer(globalConfiguration)
+	forwardingTransport := globalConfiguration.ForwardingTransport
+	if forwardingTransport == nil {
+		forwardingTransport = &configuration.ForwardingTransport{}
+	}
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			if forwardingTransport.ReadBufferSize > 0 {
+				if err := tcpConn.SetReadBuffer(forwardingTransport.ReadBufferSize); err != nil {
+					log.Warnf("Unable to set the TCP receive buffer of the connection to %s: %v", addr, err)
+				}
+			}
+			if forwardingTransport.WriteBufferSize > 0 {
+				if err := tcpConn.SetWriteBuffer(forwardingTransport.WriteBufferSize); err != nil {
+					log.Warnf("Unable to set the TCP send buffer of the connection to %s: %v", addr, err)
+				}
+			}
+		}
+		return conn, nil
+	}
+	if forwardingTransport.ResolveInterval > 0 {
+		return resolvedHostsConns.dial(dial)
+	}
+	return dial
+}
+
 func createRootCACertPool(rootCAs configuration.RootCAs) *x509.CertPool {
 	roots := x509.NewCertPool()
 
@@ -198,6 +418,11 @@ func (server *Server) Wait() {
 	<-server.stopChan
 }
 
+// Shutdown stops the server gracefully, as on a termination signal
+func (server *Server) Shutdown() {
+	server.signals <- syscall.SIGTERM
+}
+
 // Stop stops the server
 func (server *Server) Stop() {
 	defer log.Info("Server stopped")
@@ -206,21 +431,47 @@ func (server *Server) Stop() {
 		wg.Add(1)
 		go func(serverEntryPointName string, serverEntryPoint *serverEntryPoint) {
 			defer wg.Done()
-			graceTimeOut := time.Duration(server.globalConfiguration.LifeCycle.GraceTimeOut)
-			ctx, cancel := context.WithTimeout(context.Background(), graceTimeOut)
-			log.Debugf("Waiting %s seconds before killing connections on entrypoint %s...", graceTimeOut, serverEntryPointName)
-			if err := serverEntryPoint.httpServer.Shutdown(ctx); err != nil {
-				log.Debugf("Wait is over due to: %s", err)
-				serverEntryPoint.httpServer.Close()
-			}
-			cancel()
-			log.Debugf("Entrypoint %s closed", serverEntryPointName)
+			server.stopServerEntryPoint(serverEntryPointName, serverEntryPoint)
 		}(sepn, sep)
 	}
 	wg.Wait()
 	server.stopChan <- true
 }
 
+// stopServerEntryPoint closes the entry point, waiting up to the grace timeout for its HTTP connections to be drained
+func (server *Server) stopServerEntryPoint(serverEntryPointName string, serverEntryPoint *serverEntryPoint) {
+	if serverEntryPoint.udpConn != nil {
+		serverEntryPoint.udpConn.Close()
+		log.Debugf("Entrypoint %s closed", serverEntryPointName)
+		return
+	}
+	if serverEntryPoint.httpServer == nil {
+		serverEntryPoint.listener.Close()
+		log.Debugf("Entrypoint %s closed", serverEntryPointName)
+		return
+	}
+	graceTimeOut := time.Duration(server.globalConfiguration.LifeCycle.GraceTimeOut)
+	ctx, cancel := context.WithTimeout(context.Background(), graceTimeOut)
+	log.Debugf("Waiting %s seconds before killing connections on entrypoint %s...", graceTimeOut, serverEntryPointName)
+	var wg sync.WaitGroup
+	if serverEntryPoint.http3Server != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serverEntryPoint.http3Server.Shutdown(ctx); err != nil {
+				log.Debugf("Wait is over for HTTP/3 due to: %s", err)
+			}
+		}()
+	}
+	if err := serverEntryPoint.httpServer.Shutdown(ctx); err != nil {
+		log.Debugf("Wait is over due to: %s", err)
+		serverEntryPoint.httpServer.Close()
+	}
+	wg.Wait()
+	cancel()
+	log.Debugf("Entrypoint %s closed", serverEntryPointName)
+}
+
 // Close destroys the server
 func (server *Server) Close() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(server.globalConfiguration.LifeCycle.GraceTimeOut))
@@ -234,8 +485,14 @@ func (server *Server) Close() {
 		}
 	}(ctx)
 	stopMetricsClients()
+	if server.tracer != nil {
+		server.tracer.Close()
+	}
 	server.stopLeadership()
 	server.routinesPool.Cleanup()
+	for p := range server.runningProviders {
+		server.stopProvider(p)
+	}
 	close(server.configurationChan)
 	close(server.configurationValidatedChan)
 	signal.Stop(server.signals)
@@ -265,18 +522,62 @@ func (server *Server) startHTTPServers() {
 	server.serverEntryPoints = server.buildEntryPoints(server.globalConfiguration)
 
 	for newServerEntryPointName, newServerEntryPoint := range server.serverEntryPoints {
-		serverEntryPoint := server.setupServerEntryPoint(newServerEntryPointName, newServerEntryPoint)
+		serverEntryPoint, err := server.setupServerEntryPoint(newServerEntryPointName, newServerEntryPoint)
+		if err != nil {
+			log.Fatal("Error starting server: ", err)
+		}
 		go server.startServer(serverEntryPoint, server.globalConfiguration)
 	}
+	server.activatedSockets.closeUnused()
 }
 
-func (server *Server) setupServerEntryPoint(newServerEntryPointName string, newServerEntryPoint *serverEntryPoint) *serverEntryPoint {
+// setupServerEntryPoint builds the middlewares of the entry point and opens its listener, without serving it yet
+func (server *Server) setupServerEntryPoint(newServerEntryPointName string, newServerEntryPoint *serverEntryPoint) (*serverEntryPoint, error) {
+	if newServerEntryPoint.tcpRouter != nil {
+		return server.setupTCPServerEntryPoint(newServerEntryPointName, newServerEntryPoint)
+	}
+	if newServerEntryPoint.udpLoadBalancer != nil {
+		return server.setupUDPServerEntryPoint(newServerEntryPointName, newServerEntryPoint)
+	}
+
 	serverMiddlewares := []negroni.Handler{middlewares.NegroniRecoverHandler()}
+	if forwardedHeadersConfig := server.globalConfiguration.EntryPoints[newServerEntryPointName].ForwardedHeaders; forwardedHeadersConfig != nil {
+		forwardedHeaders, err := middlewares.NewForwardedHeaders(forwardedHeadersConfig.Insecure, forwardedHeadersConfig.TrustedIPs)
+		if err != nil {
+			return nil, err
+		}
+		serverMiddlewares = append(serverMiddlewares, forwardedHeaders)
+	}
+	if server.geoIPDatabase != nil {
+		var insecure bool
+		var trustedProxies []string
+		if forwardedHeadersConfig := server.globalConfiguration.EntryPoints[newServerEntryPointName].ForwardedHeaders; forwardedHeadersConfig != nil {
+			insecure, trustedProxies = forwardedHeadersConfig.Insecure, forwardedHeadersConfig.TrustedIPs
+		}
+		geoIPHeaders, err := middlewares.NewGeoIPHeaders(server.geoIPDatabase, insecure, trustedProxies)
+		if err != nil {
+			return nil, err
+		}
+		serverMiddlewares = append(serverMiddlewares, geoIPHeaders)
+	}
+	var websocketReadTimeout, websocketWriteTimeout time.Duration
+	if server.globalConfiguration.WebsocketTimeouts != nil {
+		websocketReadTimeout = time.Duration(server.globalConfiguration.WebsocketTimeouts.ReadTimeout)
+		websocketWriteTimeout = time.Duration(server.globalConfiguration.WebsocketTimeouts.WriteTimeout)
+	}
+	serverMiddlewares = append(serverMiddlewares, middlewares.NewWebsocketDeadlines(websocketReadTimeout, websocketWriteTimeout))
+	if server.tracer != nil {
+		serverMiddlewares = append(serverMiddlewares, tracing.NewEntryPointMiddleware(server.tracer, newServerEntryPointName))
+	}
 	if server.accessLoggerMiddleware != nil {
 		serverMiddlewares = append(serverMiddlewares, server.accessLoggerMiddleware)
 	}
+	if server.globalConfiguration.EntryPoints[newServerEntryPointName].RequestID {
+		serverMiddlewares = append(serverMiddlewares, middlewares.NewRequestID())
+	}
 	if server.metricsRegistry.IsEnabled() {
 		serverMiddlewares = append(serverMiddlewares, middlewares.NewMetricsWrapper(server.metricsRegistry, newServerEntryPointName))
+		serverMiddlewares = append(serverMiddlewares, middlewares.NewEntryPointMetricsWrapper(server.metricsRegistry, newServerEntryPointName))
 	}
 	if server.globalConfiguration.Web != nil {
 		server.globalConfiguration.Web.Stats = thoas_stats.New()
@@ -289,73 +590,171 @@ func (server *Server) setupServerEntryPoint(newServerEntryPointName string, newS
 	if server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth != nil {
 		authMiddleware, err := mauth.NewAuthenticator(server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth)
 		if err != nil {
-			log.Fatal("Error starting server: ", err)
+			return nil, err
 		}
 		serverMiddlewares = append(serverMiddlewares, authMiddleware)
 	}
 	if server.globalConfiguration.EntryPoints[newServerEntryPointName].Compress {
-		serverMiddlewares = append(serverMiddlewares, &middlewares.Compress{})
+		compressMiddleware, err := newCompress(server.globalConfiguration.EntryPoints[newServerEntryPointName].Compression)
+		if err != nil {
+			return nil, err
+		}
+		serverMiddlewares = append(serverMiddlewares, compressMiddleware)
 	}
 	if len(server.globalConfiguration.EntryPoints[newServerEntryPointName].WhitelistSourceRange) > 0 {
 		ipWhitelistMiddleware, err := middlewares.NewIPWhitelister(server.globalConfiguration.EntryPoints[newServerEntryPointName].WhitelistSourceRange)
 		if err != nil {
-			log.Fatal("Error starting server: ", err)
+			return nil, err
 		}
 		serverMiddlewares = append(serverMiddlewares, ipWhitelistMiddleware)
 	}
+	var http3Conn net.PacketConn
+	if http3Config := server.globalConfiguration.EntryPoints[newServerEntryPointName].HTTP3; http3Config != nil {
+		if server.globalConfiguration.EntryPoints[newServerEntryPointName].TLS == nil {
+			return nil, fmt.Errorf("HTTP/3 requires TLS on entrypoint %s", newServerEntryPointName)
+		}
+		var err error
+		http3Conn, err = server.buildPacketConn(newServerEntryPointName, server.globalConfiguration.EntryPoints[newServerEntryPointName].Address)
+		if err != nil {
+			return nil, err
+		}
+		advertisedPort := http3Config.AdvertisedPort
+		if advertisedPort == 0 {
+			advertisedPort = http3Conn.LocalAddr().(*net.UDPAddr).Port
+		}
+		serverMiddlewares = append(serverMiddlewares, middlewares.NewAltSvc(advertisedPort))
+	}
 	newSrv, listener, err := server.prepareServer(newServerEntryPointName, server.globalConfiguration.EntryPoints[newServerEntryPointName], newServerEntryPoint.httpRouter, serverMiddlewares...)
 	if err != nil {
-		log.Fatal("Error preparing server: ", err)
+		if http3Conn != nil {
+			http3Conn.Close()
+		}
+		return nil, err
+	}
+	newServerEntryPoint.httpServer = newSrv
+	newServerEntryPoint.listener = listener
+
+	if http3Conn != nil {
+		newServerEntryPoint.http3Conn = http3Conn
+		if newSrv.TLSConfig.ClientAuth > tls.NoClientCert {
+			newServerEntryPoint.close()
+			return nil, fmt.Errorf("HTTP/3 does not support the client certificates of entrypoint %s", newServerEntryPointName)
+		}
+		newServerEntryPoint.http3Server = &http3.Server{
+			Handler:     newSrv.Handler,
+			TLSConfig:   newSrv.TLSConfig,
+			IdleTimeout: newSrv.IdleTimeout,
+		}
+	}
+
+	return newServerEntryPoint, nil
+}
+
+func (server *Server) setupTCPServerEntryPoint(newServerEntryPointName string, newServerEntryPoint *serverEntryPoint) (*serverEntryPoint, error) {
+	entryPoint := server.globalConfiguration.EntryPoints[newServerEntryPointName]
+	log.Infof("Preparing TCP server %s %+v", newServerEntryPointName, entryPoint)
+
+	tlsConfig, err := server.createTLSConfig(newServerEntryPointName, entryPoint.TLS, newServerEntryPoint.httpRouter)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := server.buildListener(newServerEntryPointName, entryPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	newServerEntryPoint.listener = listener
+	newServerEntryPoint.tlsConfig = tlsConfig
+	newServerEntryPoint.tcpRouter.UpdateHandler(tcp.NewRouter(tlsConfig))
+
+	return newServerEntryPoint, nil
+}
+
+func (server *Server) setupUDPServerEntryPoint(newServerEntryPointName string, newServerEntryPoint *serverEntryPoint) (*serverEntryPoint, error) {
+	entryPoint := server.globalConfiguration.EntryPoints[newServerEntryPointName]
+	log.Infof("Preparing UDP server %s %+v", newServerEntryPointName, entryPoint)
+
+	conn, err := server.buildPacketConn(newServerEntryPointName, entryPoint.Address)
+	if err != nil {
+		return nil, err
 	}
-	serverEntryPoint := server.serverEntryPoints[newServerEntryPointName]
-	serverEntryPoint.httpServer = newSrv
-	serverEntryPoint.listener = listener
+	newServerEntryPoint.udpConn = conn
+
+	return newServerEntryPoint, nil
+}
 
-	return serverEntryPoint
+// buildPacketConn opens the UDP socket of an entry point, or takes the one passed by systemd
+func (server *Server) buildPacketConn(entryPointName, address string) (net.PacketConn, error) {
+	conn := server.activatedSockets.packetConn(entryPointName, address)
+	if conn == nil {
+		conn = server.takenOverSockets.packetConn(address)
+	}
+	if conn == nil {
+		var err error
+		conn, err = net.ListenPacket("udp", address)
+		if err != nil {
+			return nil, fmt.Errorf("error opening UDP listener: %v", err)
+		}
+	}
+	return conn, nil
 }
 
+// listenProviders batches the configurations received from the providers: the first one is applied at once,
+// then they are applied together, in their latest state, at most once per providersThrottleDuration
 func (server *Server) listenProviders(stop chan bool) {
-	lastReceivedConfiguration := safe.New(time.Unix(0, 0))
-	lastConfigs := cmap.New()
+	providersThrottleDuration := time.Duration(server.globalConfiguration.ProvidersThrottleDuration)
+	pendingConfigurations := make(types.Configurations)
+	lastApplied := time.Unix(0, 0)
+	var throttle <-chan time.Time
+
+	apply := func() {
+		server.configurationValidatedChan <- pendingConfigurations
+		pendingConfigurations = make(types.Configurations)
+		lastApplied = time.Now()
+		throttle = nil
+	}
+
 	for {
 		select {
 		case <-stop:
 			return
+		case <-throttle:
+			log.Debugf("Applying the configurations received from %d providers in the last %s", len(pendingConfigurations), providersThrottleDuration)
+			apply()
 		case configMsg, ok := <-server.configurationChan:
 			if !ok {
 				return
 			}
 			server.defaultConfigurationValues(configMsg.Configuration)
-			currentConfigurations := server.currentConfigurations.Get().(types.Configurations)
-			jsonConf, _ := json.Marshal(configMsg.Configuration)
+			jsonConf, _ := json.Marshal(configMsg.Configuration.Redacted())
 			log.Debugf("Configuration received from provider %s: %s", configMsg.ProviderName, string(jsonConf))
-			if configMsg.Configuration == nil || configMsg.Configuration.Backends == nil && configMsg.Configuration.Frontends == nil {
+
+			lastConfiguration, pending := pendingConfigurations[configMsg.ProviderName]
+			if !pending {
+				lastConfiguration = server.currentConfigurations.Get().(types.Configurations)[configMsg.ProviderName]
+			}
+			if configMsg.Configuration == nil || configMsg.Configuration.Backends == nil && configMsg.Configuration.Frontends == nil && configMsg.Configuration.TLSConfiguration == nil && configMsg.Configuration.TCPFrontends == nil && configMsg.Configuration.UDPFrontends == nil {
 				log.Infof("Skipping empty Configuration for provider %s", configMsg.ProviderName)
-			} else if reflect.DeepEqual(currentConfigurations[configMsg.ProviderName], configMsg.Configuration) {
+				continue
+			}
+			if reflect.DeepEqual(lastConfiguration, configMsg.Configuration) {
 				log.Infof("Skipping same configuration for provider %s", configMsg.ProviderName)
-			} else {
-				lastConfigs.Set(configMsg.ProviderName, &configMsg)
-				lastReceivedConfigurationValue := lastReceivedConfiguration.Get().(time.Time)
-				providersThrottleDuration := time.Duration(server.globalConfiguration.ProvidersThrottleDuration)
-				if time.Now().After(lastReceivedConfigurationValue.Add(providersThrottleDuration)) {
-					log.Debugf("Last %s config received more than %s, OK", configMsg.ProviderName, server.globalConfiguration.ProvidersThrottleDuration)
-					// last config received more than n s ago
-					server.configurationValidatedChan <- configMsg
-				} else {
-					log.Debugf("Last %s config received less than %s, waiting...", configMsg.ProviderName, server.globalConfiguration.ProvidersThrottleDuration)
-					safe.Go(func() {
-						<-time.After(providersThrottleDuration)
-						lastReceivedConfigurationValue := lastReceivedConfiguration.Get().(time.Time)
-						if time.Now().After(lastReceivedConfigurationValue.Add(time.Duration(providersThrottleDuration))) {
-							log.Debugf("Waited for %s config, OK", configMsg.ProviderName)
-							if lastConfig, ok := lastConfigs.Get(configMsg.ProviderName); ok {
-								server.configurationValidatedChan <- *lastConfig.(*types.ConfigMessage)
-							}
-						}
-					})
-				}
-				lastReceivedConfiguration.Set(time.Now())
+				continue
 			}
+
+			// an intermediate configuration of the provider is replaced by its latest one
+			pendingConfigurations[configMsg.ProviderName] = configMsg.Configuration
+			if throttle != nil {
+				log.Debugf("Configuration of %s received less than %s after the last reload, waiting...", configMsg.ProviderName, providersThrottleDuration)
+				continue
+			}
+			if wait := providersThrottleDuration - time.Since(lastApplied); wait > 0 {
+				log.Debugf("Configuration of %s received less than %s after the last reload, waiting...", configMsg.ProviderName, providersThrottleDuration)
+				throttle = time.After(wait)
+				continue
+			}
+			log.Debugf("Last configuration applied more than %s ago, applying the configuration of %s", providersThrottleDuration, configMsg.ProviderName)
+			apply()
 		}
 	}
 }
@@ -373,7 +772,7 @@ func (server *Server) listenConfigurations(stop chan bool) {
 		select {
 		case <-stop:
 			return
-		case configMsg, ok := <-server.configurationValidatedChan:
+		case configurations, ok := <-server.configurationValidatedChan:
 			if !ok {
 				return
 			}
@@ -384,21 +783,39 @@ func (server *Server) listenConfigurations(stop chan bool) {
 			for k, v := range currentConfigurations {
 				newConfigurations[k] = v
 			}
-			newConfigurations[configMsg.ProviderName] = configMsg.Configuration
-
-			newServerEntryPoints, err := server.loadConfig(newConfigurations, server.globalConfiguration)
-			if err == nil {
-				for newServerEntryPointName, newServerEntryPoint := range newServerEntryPoints {
-					server.serverEntryPoints[newServerEntryPointName].httpRouter.UpdateHandler(newServerEntryPoint.httpRouter.GetHandler())
-					log.Infof("Server configuration reloaded on %s", server.serverEntryPoints[newServerEntryPointName].httpServer.Addr)
-				}
-				server.currentConfigurations.Set(newConfigurations)
-				server.postLoadConfig()
-			} else {
-				log.Error("Error loading new configuration, aborted ", err)
+			for providerName, configuration := range configurations {
+				newConfigurations[providerName] = configuration
 			}
+			server.applyConfigurations(newConfigurations)
+		case globalConfiguration := <-server.globalConfigurationChan:
+			server.applyGlobalConfiguration(globalConfiguration)
+			server.applyConfigurations(server.currentConfigurations.Get().(types.Configurations))
+		}
+	}
+}
+
+// applyConfigurations loads the configurations of the providers, and switches the entry points to them
+func (server *Server) applyConfigurations(newConfigurations types.Configurations) {
+	newServerEntryPoints, err := server.loadConfig(newConfigurations, server.globalConfiguration)
+	if err != nil {
+		server.metricsRegistry.ConfigReloadsFailureCounter().Add(1)
+		log.Error("Error loading new configuration, aborted ", err)
+		return
+	}
+	for newServerEntryPointName, newServerEntryPoint := range newServerEntryPoints {
+		server.serverEntryPoints[newServerEntryPointName].httpRouter.UpdateHandler(newServerEntryPoint.httpRouter.GetHandler())
+		if newServerEntryPoint.tcpRouter != nil {
+			server.serverEntryPoints[newServerEntryPointName].tcpRouter.UpdateHandler(newServerEntryPoint.tcpRouter.GetHandler())
+		}
+		if newServerEntryPoint.udpLoadBalancer != nil {
+			server.serverEntryPoints[newServerEntryPointName].udpLoadBalancer.UpdateLoadBalancer(newServerEntryPoint.udpLoadBalancer.GetLoadBalancer())
 		}
+		server.serverEntryPoints[newServerEntryPointName].dynamicCerts.Set(newServerEntryPoint.dynamicCerts.Get())
+		log.Infof("Server configuration reloaded on %s", server.globalConfiguration.EntryPoints[newServerEntryPointName].Address)
 	}
+	server.currentConfigurations.Set(newConfigurations)
+	server.metricsRegistry.ConfigReloadsCounter().Add(1)
+	server.postLoadConfig()
 }
 
 func (server *Server) postLoadConfig() {
@@ -439,6 +856,45 @@ func (server *Server) postLoadConfig() {
 	}
 }
 
+// newCompress returns the compression middleware of an entry point, with the default encodings and levels
+// when the compression is not configured
+func newCompress(compression *configuration.Compression) (*middlewares.Compress, error) {
+	if compression == nil {
+		return middlewares.NewCompress(nil, nil)
+	}
+	return middlewares.NewCompress(compression.Encodings, map[string]int{
+		"br":   compression.BrotliLevel,
+		"zstd": compression.ZstdLevel,
+		"gzip": compression.GzipLevel,
+	})
+}
+
+// newWebEntryPoints summarizes the entrypoints for the API of the web provider
+func newWebEntryPoints(entryPoints configuration.EntryPoints) map[string]*web.EntryPoint {
+	webEntryPoints := make(map[string]*web.EntryPoint, len(entryPoints))
+	for entryPointName, entryPoint := range entryPoints {
+		webEntryPoint := &web.EntryPoint{
+			Address:  entryPoint.Address,
+			Protocol: entryPoint.Protocol,
+			TLS:      entryPoint.TLS != nil,
+			Auth:     entryPoint.Auth != nil,
+			Compress: entryPoint.Compress,
+		}
+		if len(webEntryPoint.Protocol) == 0 {
+			webEntryPoint.Protocol = "http"
+		}
+		if entryPoint.Redirect != nil {
+			if len(entryPoint.Redirect.EntryPoint) > 0 {
+				webEntryPoint.Redirect = entryPoint.Redirect.EntryPoint
+			} else {
+				webEntryPoint.Redirect = entryPoint.Redirect.Replacement
+			}
+		}
+		webEntryPoints[entryPointName] = webEntryPoint
+	}
+	return webEntryPoints
+}
+
 func (server *Server) configureProviders() {
 	// configure providers
 	if server.globalConfiguration.Docker != nil {
@@ -452,7 +908,9 @@ func (server *Server) configureProviders() {
 	}
 	if server.globalConfiguration.Web != nil {
 		server.globalConfiguration.Web.CurrentConfigurations = &server.currentConfigurations
-		server.globalConfiguration.Web.Debug = server.globalConfiguration.Debug
+		server.globalConfiguration.Web.EntryPoints = newWebEntryPoints(server.globalConfiguration.EntryPoints)
+		server.globalConfiguration.Web.Debug = server.globalConfiguration.Web.Debug || server.globalConfiguration.Debug
+		server.globalConfiguration.Web.SetRuleValidator(validateRule)
 		server.providers = append(server.providers, server.globalConfiguration.Web)
 	}
 	if server.globalConfiguration.Consul != nil {
@@ -493,17 +951,87 @@ func (server *Server) configureProviders() {
 func (server *Server) startProviders() {
 	// start providers
 	for _, p := range server.providers {
-		providerType := reflect.TypeOf(p)
-		jsonConf, _ := json.Marshal(p)
-		log.Infof("Starting provider %v %s", providerType, jsonConf)
-		currentProvider := p
-		safe.Go(func() {
-			err := currentProvider.Provide(server.configurationChan, server.routinesPool, server.globalConfiguration.Constraints)
-			if err != nil {
-				log.Errorf("Error starting provider %v: %s", providerType, err)
+		server.startProvider(p)
+	}
+}
+
+// runningProvider is a started provider, whose goroutines are stopped with its pool when its settings are reloaded
+type runningProvider struct {
+	pool          *safe.Pool
+	mutex         sync.Mutex
+	providerNames map[string]bool // the names of the configurations the provider sent
+}
+
+// startProvider starts the provider with its own pool of goroutines, the configurations it sends being forwarded
+// to the server until it is stopped
+func (server *Server) startProvider(p provider.Provider) {
+	providerType := reflect.TypeOf(p)
+	jsonConf, _ := json.Marshal(p)
+	log.Infof("Starting provider %v %s", providerType, jsonConf)
+
+	running := &runningProvider{pool: safe.NewPool(server.routinesPool.Ctx()), providerNames: make(map[string]bool)}
+	if server.runningProviders == nil {
+		server.runningProviders = make(map[provider.Provider]*runningProvider)
+	}
+	server.runningProviders[p] = running
+
+	configurationChan := make(chan types.ConfigMessage, 100)
+	running.pool.Go(func(stop chan bool) {
+		for {
+			select {
+			case <-stop:
+				return
+			case configMsg := <-configurationChan:
+				running.mutex.Lock()
+				running.providerNames[configMsg.ProviderName] = true
+				running.mutex.Unlock()
+				server.configurationChan <- configMsg
 			}
-		})
+		}
+	})
+	safe.Go(func() {
+		err := p.Provide(configurationChan, running.pool, server.globalConfiguration.Constraints)
+		if err != nil {
+			log.Errorf("Error starting provider %v: %s", providerType, err)
+		}
+	})
+}
+
+// stopProvider stops the goroutines of the provider, waiting up to providerStopTimeout for them to end, and returns
+// the names of the configurations it sent
+func (server *Server) stopProvider(p provider.Provider) []string {
+	for i, serverProvider := range server.providers {
+		if serverProvider == p {
+			server.providers = append(server.providers[:i], server.providers[i+1:]...)
+			break
+		}
+	}
+	running, ok := server.runningProviders[p]
+	if !ok {
+		return nil
 	}
+	delete(server.runningProviders, p)
+
+	log.Infof("Stopping provider %v", reflect.TypeOf(p))
+	stopped := make(chan struct{})
+	go func() {
+		running.pool.Cleanup()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(providerStopTimeout):
+		log.Warnf("Timeout while stopping provider %v", reflect.TypeOf(p))
+	}
+
+	running.mutex.Lock()
+	defer running.mutex.Unlock()
+	var providerNames []string
+	for providerName := range running.providerNames {
+		providerNames = append(providerNames, providerName)
+	}
+	sort.Strings(providerNames)
+	return providerNames
 }
 
 func createClientTLSConfig(tlsOption *configuration.TLS) (*tls.Config, error) {
@@ -544,6 +1072,16 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *configur
 		return nil, err
 	}
 
+	var defaultCertificate *tls.Certificate
+	if tlsOption.DefaultCertificate != nil {
+		defaultCertificate, err = tlsOption.DefaultCertificate.CreateTLSCertificate()
+		if err != nil {
+			return nil, err
+		}
+		// the default certificate comes first so that it is also the fallback of crypto/tls
+		config.Certificates = append([]tls.Certificate{*defaultCertificate}, config.Certificates...)
+	}
+
 	// ensure http2 enabled
 	config.NextProtos = []string{"h2", "http/1.1"}
 
@@ -563,6 +1101,15 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *configur
 		config.ClientAuth = tls.RequireAndVerifyClientCert
 	}
 
+	//Set the client authentication policy if set in the config TOML
+	if len(tlsOption.ClientAuth) > 0 {
+		clientAuth, exists := configuration.ClientAuthTypes[tlsOption.ClientAuth]
+		if !exists {
+			return nil, errors.New("Invalid ClientAuth: " + tlsOption.ClientAuth)
+		}
+		config.ClientAuth = clientAuth
+	}
+
 	if server.globalConfiguration.ACME != nil {
 		if _, ok := server.serverEntryPoints[server.globalConfiguration.ACME.EntryPoint]; ok {
 			if entryPointName == server.globalConfiguration.ACME.EntryPoint {
@@ -592,16 +1139,51 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *configur
 		}
 	}
 	if len(config.Certificates) == 0 {
-		return nil, errors.New("No certificates found for TLS entrypoint " + entryPointName)
+		log.Warnf("No certificates found for TLS entrypoint %s, only the dynamic certificates will be served", entryPointName)
 	}
 	// BuildNameToCertificate parses the CommonName and SubjectAlternateName fields
 	// in each certificate and populates the config.NameToCertificate map.
 	config.BuildNameToCertificate()
+	if defaultCertificate == nil && len(config.Certificates) > 0 {
+		defaultCertificate = &config.Certificates[0]
+	}
+	if serverEntryPoint, ok := server.serverEntryPoints[entryPointName]; ok {
+		domains := make(map[string]*tls.Certificate, len(config.NameToCertificate))
+		for domain, cert := range config.NameToCertificate {
+			domains[domain] = cert
+		}
+		serverEntryPoint.certs.Set(&entryPointCertificates{
+			domains:            domains,
+			defaultCertificate: defaultCertificate,
+		})
+		serverEntryPoint.acmeGetCertificate = config.GetCertificate
+		config.GetCertificate = serverEntryPoint.getCertificate
+	}
 	//Set the minimum TLS version if set in the config TOML
 	if minConst, exists := configuration.MinVersion[server.globalConfiguration.EntryPoints[entryPointName].TLS.MinVersion]; exists {
 		config.PreferServerCipherSuites = true
 		config.MinVersion = minConst
 	}
+	//Set the maximum TLS version if set in the config TOML
+	if len(tlsOption.MaxVersion) > 0 {
+		maxConst, exists := configuration.MaxVersion[tlsOption.MaxVersion]
+		if !exists {
+			return nil, errors.New("Invalid MaxVersion: " + tlsOption.MaxVersion)
+		}
+		config.MaxVersion = maxConst
+	}
+	//Set the list of elliptic curves if set in the config TOML
+	if tlsOption.CurvePreferences != nil {
+		config.CurvePreferences = make([]tls.CurveID, 0)
+		for _, curve := range tlsOption.CurvePreferences {
+			if curveConst, exists := configuration.Curves[curve]; exists {
+				config.CurvePreferences = append(config.CurvePreferences, curveConst)
+			} else {
+				//Curve listed in the toml does not exist in our listed
+				return nil, errors.New("Invalid Curve: " + curve)
+			}
+		}
+	}
 	//Set the list of CipherSuites if set in the config TOML
 	if server.globalConfiguration.EntryPoints[entryPointName].TLS.CipherSuites != nil {
 		//if our list of CipherSuites is defined in the entrypoint config, we can re-initilize the suites list as empty
@@ -620,9 +1202,30 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *configur
 }
 
 func (server *Server) startServer(serverEntryPoint *serverEntryPoint, globalConfiguration configuration.GlobalConfiguration) {
-	log.Infof("Starting server on %s", serverEntryPoint.httpServer.Addr)
-	var err error
-	if serverEntryPoint.httpServer.TLSConfig != nil {
+	if serverEntryPoint.served != nil {
+		defer close(serverEntryPoint.served)
+	}
+	if serverEntryPoint.tcpRouter != nil {
+		server.startTCPServer(serverEntryPoint)
+		return
+	}
+	if serverEntryPoint.udpLoadBalancer != nil {
+		server.startUDPServer(serverEntryPoint)
+		return
+	}
+
+	if serverEntryPoint.http3Server != nil {
+		http3Served := make(chan struct{})
+		go func() {
+			defer close(http3Served)
+			server.startHTTP3Server(serverEntryPoint)
+		}()
+		defer func() { <-http3Served }()
+	}
+
+	log.Infof("Starting server on %s", serverEntryPoint.httpServer.Addr)
+	var err error
+	if serverEntryPoint.httpServer.TLSConfig != nil {
 		err = serverEntryPoint.httpServer.ServeTLS(serverEntryPoint.listener, "", "")
 	} else {
 		err = serverEntryPoint.httpServer.Serve(serverEntryPoint.listener)
@@ -632,6 +1235,42 @@ func (server *Server) startServer(serverEntryPoint *serverEntryPoint, globalConf
 	}
 }
 
+func (server *Server) startTCPServer(serverEntryPoint *serverEntryPoint) {
+	log.Infof("Starting TCP server on %s", serverEntryPoint.listener.Addr())
+	for {
+		conn, err := serverEntryPoint.listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				log.Debugf("Temporary error accepting TCP connection: %v", err)
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			if !strings.Contains(err.Error(), "use of closed network connection") {
+				log.Error("Error accepting TCP connection: ", err)
+			}
+			return
+		}
+		safe.Go(func() {
+			serverEntryPoint.tcpRouter.ServeTCP(conn)
+		})
+	}
+}
+
+func (server *Server) startUDPServer(serverEntryPoint *serverEntryPoint) {
+	log.Infof("Starting UDP server on %s", serverEntryPoint.udpConn.LocalAddr())
+	proxy := udp.NewProxy(serverEntryPoint.udpConn, serverEntryPoint.udpLoadBalancer, udp.DefaultSessionTimeout)
+	if err := proxy.Serve(); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+		log.Error("Error serving UDP: ", err)
+	}
+}
+
+func (server *Server) startHTTP3Server(serverEntryPoint *serverEntryPoint) {
+	log.Infof("Starting HTTP/3 server on %s", serverEntryPoint.http3Conn.LocalAddr())
+	if err := serverEntryPoint.http3Server.Serve(serverEntryPoint.http3Conn); err != http.ErrServerClosed {
+		log.Error("Error serving HTTP/3: ", err)
+	}
+}
+
 func (server *Server) prepareServer(entryPointName string, entryPoint *configuration.EntryPoint, router *middlewares.HandlerSwitcher, middlewares ...negroni.Handler) (*http.Server, net.Listener, error) {
 	readTimeout, writeTimeout, idleTimeout := buildServerTimeouts(server.globalConfiguration)
 	log.Infof("Preparing server %s %+v with readTimeout=%s writeTimeout=%s idleTimeout=%s", entryPointName, entryPoint, readTimeout, writeTimeout, idleTimeout)
@@ -649,20 +1288,67 @@ func (server *Server) prepareServer(entryPointName string, entryPoint *configura
 		return nil, nil, err
 	}
 
-	listener, err := net.Listen("tcp", entryPoint.Address)
+	listener, err := server.buildListener(entryPointName, entryPoint)
 	if err != nil {
-		log.Error("Error opening listener ", err)
 		return nil, nil, err
 	}
 
+	httpServer := &http.Server{
+		Addr:         entryPoint.Address,
+		Handler:      n,
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+	if server.metricsRegistry.IsEnabled() {
+		httpServer.ConnState = trackOpenConnections(server.metricsRegistry.OpenConnectionsGauge().With("entrypoint", entryPointName))
+	}
+	return httpServer, listener, nil
+}
+
+// trackOpenConnections returns a http.Server ConnState hook setting the gauge to the number of open connections
+func trackOpenConnections(gauge gokitmetrics.Gauge) func(net.Conn, http.ConnState) {
+	var openConnections int64
+	return func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			gauge.Set(float64(atomic.AddInt64(&openConnections, 1)))
+		case http.StateHijacked, http.StateClosed:
+			gauge.Set(float64(atomic.AddInt64(&openConnections, -1)))
+		}
+	}
+}
+
+// buildListener opens the listener of an entry point, or takes the one passed by systemd,
+// accepting the PROXY protocol if configured.
+func (server *Server) buildListener(entryPointName string, entryPoint *configuration.EntryPoint) (net.Listener, error) {
+	listener := server.activatedSockets.listener(entryPointName, entryPoint.Address)
+	if listener == nil {
+		listener = server.takenOverSockets.listener(entryPoint.Address)
+	}
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", entryPoint.Address)
+		if err != nil {
+			log.Error("Error opening listener ", err)
+			return nil, err
+		}
+	}
+
 	if entryPoint.ProxyProtocol != nil {
 		IPs, err := whitelist.NewIP(entryPoint.ProxyProtocol.TrustedIPs)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Error creating whitelist: %s", err)
+			return nil, fmt.Errorf("Error creating whitelist: %s", err)
+		}
+		headerTimeout := time.Duration(entryPoint.ProxyProtocol.HeaderTimeout)
+		if headerTimeout == 0 {
+			headerTimeout = proxyprotocol.DefaultHeaderTimeout
 		}
 		log.Infof("Enabling ProxyProtocol for trusted IPs %v", entryPoint.ProxyProtocol.TrustedIPs)
-		listener = &proxyproto.Listener{
-			Listener: listener,
+		listener = &proxyprotocol.Listener{
+			Listener:      listener,
+			HeaderTimeout: headerTimeout,
 			SourceCheck: func(addr net.Addr) (bool, error) {
 				ip, ok := addr.(*net.TCPAddr)
 				if !ok {
@@ -673,16 +1359,7 @@ func (server *Server) prepareServer(entryPointName string, entryPoint *configura
 		}
 	}
 
-	return &http.Server{
-			Addr:         entryPoint.Address,
-			Handler:      n,
-			TLSConfig:    tlsConfig,
-			ReadTimeout:  readTimeout,
-			WriteTimeout: writeTimeout,
-			IdleTimeout:  idleTimeout,
-		},
-		listener,
-		nil
+	return listener, nil
 }
 
 func buildServerTimeouts(globalConfig configuration.GlobalConfiguration) (readTimeout, writeTimeout, idleTimeout time.Duration) {
@@ -708,43 +1385,171 @@ func buildServerTimeouts(globalConfig configuration.GlobalConfiguration) (readTi
 
 func (server *Server) buildEntryPoints(globalConfiguration configuration.GlobalConfiguration) map[string]*serverEntryPoint {
 	serverEntryPoints := make(map[string]*serverEntryPoint)
-	for entryPointName := range globalConfiguration.EntryPoints {
+	for entryPointName, entryPoint := range globalConfiguration.EntryPoints {
 		router := server.buildDefaultHTTPRouter()
+		if globalConfiguration.Ping != nil && globalConfiguration.Ping.EntryPoint == entryPointName {
+			globalConfiguration.Ping.AddRoutes(router)
+		}
+		if isAPIEntryPoint(globalConfiguration, entryPointName) {
+			router.PathPrefix("/").Handler(globalConfiguration.Web)
+		}
 		serverEntryPoints[entryPointName] = &serverEntryPoint{
 			httpRouter: middlewares.NewHandlerSwitcher(router),
+			served:     make(chan struct{}),
+		}
+		if entryPoint.Protocol == configuration.EntryPointProtocolTCP {
+			var tlsConfig *tls.Config
+			if currentServerEntryPoint, ok := server.serverEntryPoints[entryPointName]; ok {
+				tlsConfig = currentServerEntryPoint.tlsConfig
+			}
+			serverEntryPoints[entryPointName].tcpRouter = tcp.NewHandlerSwitcher(tcp.NewRouter(tlsConfig))
+		}
+		if entryPoint.Protocol == configuration.EntryPointProtocolUDP {
+			serverEntryPoints[entryPointName].udpLoadBalancer = udp.NewLoadBalancerSwitcher(udp.NewLoadBalancer())
 		}
 	}
 	return serverEntryPoints
 }
 
-// getRoundTripper will either use server.defaultForwardingRoundTripper or create a new one
-// given a custom TLS configuration is passed and the passTLSCert option is set to true.
-func (server *Server) getRoundTripper(globalConfiguration configuration.GlobalConfiguration, passTLSCert bool, tls *configuration.TLS) (http.RoundTripper, error) {
-	if passTLSCert {
-		tlsConfig, err := createClientTLSConfig(tls)
+// isAPIEntryPoint returns whether the entrypoint serves the API and the dashboard, and nothing else
+func isAPIEntryPoint(globalConfiguration configuration.GlobalConfiguration, entryPointName string) bool {
+	return globalConfiguration.Web != nil && len(globalConfiguration.Web.EntryPoint) > 0 && globalConfiguration.Web.EntryPoint == entryPointName
+}
+
+// getRoundTripper will either use server.defaultForwardingRoundTripper or the transport of the
+// pool of the settings given the backend uses the h2c protocol, the backend has a TLS configuration,
+// a custom TLS configuration is passed and the passTLSCert option is set to true, the backend
+// overrides the forwarding timeouts, or has servers listening on Unix sockets.
+func (server *Server) getRoundTripper(globalConfiguration configuration.GlobalConfiguration, passTLSCert bool, tls *configuration.TLS, backend *types.Backend) (http.RoundTripper, error) {
+	settings := transportSettings{unixSockets: unixSockets(backend)}
+	if backend.ForwardingTimeouts != nil {
+		forwardingTimeouts, err := overrideForwardingTimeouts(globalConfiguration.ForwardingTimeouts, backend.ForwardingTimeouts)
 		if err != nil {
-			log.Errorf("Failed to create TLSClientConfig: %s", err)
 			return nil, err
 		}
+		globalConfiguration.ForwardingTimeouts = forwardingTimeouts
+		settings.forwardingTimeouts = forwardingTimeouts
+	}
 
-		transport := createHTTPTransport(globalConfiguration)
-		transport.TLSClientConfig = tlsConfig
-		return transport, nil
+	if backend.Protocol == types.BackendProtocolH2C {
+		settings.h2c = true
+		return server.transports.get(settings, func() (*http.Transport, error) {
+			return createH2CTransport(globalConfiguration), nil
+		})
+	}
+
+	if backend.TLS != nil {
+		backendTLS := *backend.TLS
+		settings.backendTLS = &backendTLS
+		settings.backendTLSFiles = backendTLSFiles(backend.TLS)
+		return server.transports.get(settings, func() (*http.Transport, error) {
+			tlsConfig, err := backend.TLS.CreateTLSConfig()
+			if err != nil {
+				log.Errorf("Failed to create backend TLSClientConfig: %s", err)
+				return nil, err
+			}
+
+			return createHTTPTransport(globalConfiguration, tlsConfig), nil
+		})
+	}
+
+	if passTLSCert {
+		settings.passTLSCert = true
+		settings.clientTLS = tls
+		return server.transports.get(settings, func() (*http.Transport, error) {
+			tlsConfig, err := createClientTLSConfig(tls)
+			if err != nil {
+				log.Errorf("Failed to create TLSClientConfig: %s", err)
+				return nil, err
+			}
+
+			return createHTTPTransport(globalConfiguration, tlsConfig), nil
+		})
+	}
+
+	if backend.ForwardingTimeouts != nil || len(settings.unixSockets) > 0 {
+		return server.transports.get(settings, func() (*http.Transport, error) {
+			return createHTTPTransport(globalConfiguration, nil), nil
+		})
 	}
 
 	return server.defaultForwardingRoundTripper, nil
 }
 
+// buildHostsResolver returns the resolver of the host names of the servers of the backend, closing the idle
+// connections of the transport when their addresses change, so that the new connections use the new addresses,
+// and again on the following resolutions as long as connections to the previous addresses, busy until then, are open.
+// It returns nil when the resolution is disabled or the servers have no host name.
+func (server *Server) buildHostsResolver(globalConfiguration configuration.GlobalConfiguration, backend *types.Backend, transport http.RoundTripper) *resolver.HostsResolver {
+	if globalConfiguration.ForwardingTransport == nil || globalConfiguration.ForwardingTransport.ResolveInterval <= 0 {
+		return nil
+	}
+	closer, ok := transport.(interface {
+		CloseIdleConnections()
+	})
+	if !ok {
+		return nil
+	}
+
+	var serverURLs []string
+	for _, srv := range backend.Servers {
+		serverURLs = append(serverURLs, srv.URL)
+	}
+	return resolver.NewHostsResolver(serverURLs, time.Duration(globalConfiguration.ForwardingTransport.ResolveInterval), func(host string, addresses []string) bool {
+		closer.CloseIdleConnections()
+		return resolvedHostsConns.stale(host, addresses)
+	})
+}
+
+// passHostHeader tells whether the Host header of the requests is forwarded to the servers of the backend, instead of
+// the host of their URL: the backend setting overrides the frontend one.
+func passHostHeader(frontendPassHostHeader bool, backend *types.Backend) bool {
+	if backend.PassHostHeader != nil {
+		return *backend.PassHostHeader
+	}
+	return frontendPassHostHeader
+}
+
+// overrideForwardingTimeouts returns the global forwarding timeouts overridden by the ones of the backend
+func overrideForwardingTimeouts(global *configuration.ForwardingTimeouts, backend *types.ForwardingTimeouts) (*configuration.ForwardingTimeouts, error) {
+	timeouts := &configuration.ForwardingTimeouts{DialTimeout: flaeg.Duration(configuration.DefaultDialTimeout)}
+	if global != nil {
+		*timeouts = *global
+	}
+
+	overrides := []struct {
+		name    string
+		value   string
+		timeout *flaeg.Duration
+	}{
+		{name: "dial", value: backend.DialTimeout, timeout: &timeouts.DialTimeout},
+		{name: "response header", value: backend.ResponseHeaderTimeout, timeout: &timeouts.ResponseHeaderTimeout},
+		{name: "idle connection", value: backend.IdleConnTimeout, timeout: &timeouts.IdleConnTimeout},
+	}
+	for _, override := range overrides {
+		if len(override.value) == 0 {
+			continue
+		}
+		timeout, err := time.ParseDuration(override.value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid %s timeout %q", override.name, override.value)
+		}
+		*override.timeout = flaeg.Duration(timeout)
+	}
+	return timeouts, nil
+}
+
 // LoadConfig returns a new gorilla.mux Route from the specified global configuration and the dynamic
 // provider configurations.
 func (server *Server) loadConfig(configurations types.Configurations, globalConfiguration configuration.GlobalConfiguration) (map[string]*serverEntryPoint, error) {
 	serverEntryPoints := server.buildEntryPoints(globalConfiguration)
 	redirectHandlers := make(map[string]negroni.Handler)
-	backends := map[string]http.Handler{}
-	backendsHealthCheck := map[string]*healthcheck.BackendHealthCheck{}
+	backends := newLoadedBackends()
+	frontends := newLoadedFrontends()
 	errorHandler := NewRecordingErrorHandler(middlewares.DefaultNetErrorRecorder{})
 
-	for _, config := range configurations {
+	for _, providerName := range sortedProviderNames(configurations) {
+		config := configurations[providerName]
 		frontendNames := sortedFrontendNamesForConfig(config)
 	frontend:
 		for _, frontendName := range frontendNames {
@@ -765,9 +1570,15 @@ func (server *Server) loadConfig(configurations types.Configurations, globalConf
 					log.Errorf("Skipping frontend %s...", frontendName)
 					continue frontend
 				}
+				if isAPIEntryPoint(globalConfiguration, entryPointName) {
+					log.Errorf("Entrypoint '%s' of frontend %s is dedicated to the API", entryPointName, frontendName)
+					log.Errorf("Skipping entrypoint %s of frontend %s...", entryPointName, frontendName)
+					continue
+				}
 
 				newServerRoute := &serverRoute{route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendName)}
-				for routeName, route := range frontend.Routes {
+				for _, routeName := range sortedRouteNames(frontend.Routes) {
+					route := frontend.Routes[routeName]
 					err := getRoute(newServerRoute, &route)
 					if err != nil {
 						log.Errorf("Error creating route for frontend %s: %v", frontendName, err)
@@ -777,253 +1588,925 @@ func (server *Server) loadConfig(configurations types.Configurations, globalConf
 					log.Debugf("Creating route %s %s", routeName, route.Rule)
 				}
 
-				entryPoint := globalConfiguration.EntryPoints[entryPointName]
-				n := negroni.New()
-				if entryPoint.Redirect != nil {
-					if redirectHandlers[entryPointName] != nil {
-						n.Use(redirectHandlers[entryPointName])
-					} else if handler, err := server.loadEntryPointConfig(entryPointName, entryPoint); err != nil {
-						log.Errorf("Error loading entrypoint configuration for frontend %s: %v", frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					} else {
-						if server.accessLoggerMiddleware != nil {
-							saveFrontend := accesslog.NewSaveNegroniFrontend(handler, frontendName)
-							n.Use(saveFrontend)
-							redirectHandlers[entryPointName] = saveFrontend
-						} else {
-							n.Use(handler)
-							redirectHandlers[entryPointName] = handler
-						}
-					}
-				}
-				if backends[entryPointName+frontend.Backend] == nil {
-					log.Debugf("Creating backend %s", frontend.Backend)
+				entryPoint := globalConfiguration.EntryPoints[entryPointName]
+				n := negroni.New()
+				if entryPoint.Redirect != nil {
+					if redirectHandlers[entryPointName] != nil {
+						n.Use(redirectHandlers[entryPointName])
+					} else if handler, err := server.loadEntryPointConfig(entryPointName, entryPoint); err != nil {
+						log.Errorf("Error loading entrypoint configuration for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					} else {
+						if server.accessLoggerMiddleware != nil {
+							saveFrontend := accesslog.NewSaveNegroniFrontend(handler, frontendName)
+							n.Use(saveFrontend)
+							redirectHandlers[entryPointName] = saveFrontend
+						} else {
+							n.Use(handler)
+							redirectHandlers[entryPointName] = handler
+						}
+					}
+				}
+				var handler http.Handler
+				var frontendBackends []http.Handler
+				if len(frontend.WeightedBackends) > 0 {
+					splitter := loadbalancer.NewBackendSplitter()
+					if frontend.Variants != nil {
+						splitter.SetVariants(frontend.Variants.Header, frontend.Variants.Cookie)
+					}
+					for _, backendName := range sortedWeightedBackendNames(frontend.WeightedBackends) {
+						weight := frontend.WeightedBackends[backendName]
+						if weight < 0 {
+							log.Errorf("Invalid weight %d of backend %s for frontend %s", weight, backendName, frontendName)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
+						if backends.handlers[healthcheck.BackendID(providerName, entryPointName, backendName)] == nil {
+							backendFrontend := *frontend
+							backendFrontend.Backend = backendName
+							backendN := negroni.New()
+							if entryPoint.Redirect != nil {
+								backendN.Use(redirectHandlers[entryPointName])
+							}
+							if err := server.loadBackendHandler(backendN, providerName, config, globalConfiguration, entryPointName, entryPoint, frontendName, &backendFrontend, backends, errorHandler); err != nil {
+								log.Error(err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+						} else {
+							log.Debugf("Reusing backend %s", backendName)
+						}
+						frontendBackends = append(frontendBackends, backends.handlers[healthcheck.BackendID(providerName, entryPointName, backendName)])
+						splitter.AddBackend(backendName, backends.handlers[healthcheck.BackendID(providerName, entryPointName, backendName)], weight)
+					}
+					n.UseHandler(splitter)
+					handler = n
+				} else if middlewares.HasRouteVariables(frontend.Backend) {
+					// the backend is chosen among the ones of the provider by the variables of the route,
+					// the middlewares of the frontend being built once around the template
+					templateBackends := server.buildTemplateBackends(providerName, config, globalConfiguration, entryPointName, entryPoint, frontendName, frontend, backends, errorHandler)
+					for _, backendName := range sortedBackendNamesForConfig(config) {
+						if templateBackend, ok := templateBackends[backendName]; ok {
+							frontendBackends = append(frontendBackends, templateBackend)
+						}
+					}
+					if err := server.buildFrontendHandler(n, middlewares.NewBackendTemplate(frontend.Backend, templateBackends), providerName, config, globalConfiguration, frontendName, frontend, nil); err != nil {
+						log.Error(err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					handler = n
+				} else {
+					if backends.handlers[healthcheck.BackendID(providerName, entryPointName, frontend.Backend)] == nil {
+						if err := server.loadBackendHandler(n, providerName, config, globalConfiguration, entryPointName, entryPoint, frontendName, frontend, backends, errorHandler); err != nil {
+							log.Error(err)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
+					} else {
+						log.Debugf("Reusing backend %s", frontend.Backend)
+					}
+					handler = backends.handlers[healthcheck.BackendID(providerName, entryPointName, frontend.Backend)]
+					frontendBackends = append(frontendBackends, handler)
+				}
+
+				// the frontend is kept as it has been loaded, with the state of its middlewares, when neither it nor its backends have changed
+				frontendSource := newFrontendSource(config, entryPoint, frontend)
+				frontendSource.backends = frontendBackends
+				if loadedHandler := server.loadedFrontends.reused(frontendID(providerName, entryPointName, frontendName), frontendSource); loadedHandler != nil {
+					log.Debugf("Keeping frontend %s, unchanged", frontendName)
+					handler = loadedHandler
+				} else {
+					if len(frontend.Middlewares) > 0 {
+						var err error
+						handler, err = server.buildMiddlewareChain(handler, config, frontendName, frontend)
+						if err != nil {
+							log.Error(err)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
+					}
+					if server.tracer != nil {
+						frontendN := negroni.New(tracing.NewFrontendMiddleware(server.tracer, frontendName))
+						frontendN.UseHandler(handler)
+						handler = frontendN
+					}
+					if server.statistics != nil {
+						frontendN := negroni.New(middlewares.NewFrontendStatistics(server.statistics, frontendName))
+						frontendN.UseHandler(handler)
+						handler = frontendN
+					}
+					if server.metricsRegistry.IsEnabled() {
+						frontendN := negroni.New(middlewares.NewFrontendMetricsWrapper(server.metricsRegistry, frontendName))
+						frontendN.UseHandler(handler)
+						handler = frontendN
+					}
+				}
+				frontends.handlers[frontendID(providerName, entryPointName, frontendName)] = handler
+				frontends.sources[frontendID(providerName, entryPointName, frontendName)] = frontendSource
+				if frontend.Priority > 0 {
+					newServerRoute.route.Priority(frontend.Priority)
+				}
+				server.wireFrontendBackend(newServerRoute, handler)
+
+				err := newServerRoute.route.GetError()
+				if err != nil {
+					log.Errorf("Error building route: %s", err)
+				}
+			}
+		}
+	}
+	for _, config := range configurations {
+		server.loadDynamicCertificates(config.TLSConfiguration, serverEntryPoints, globalConfiguration)
+		server.loadTCPConfig(config, serverEntryPoints, globalConfiguration)
+		server.loadUDPConfig(config, serverEntryPoints)
+	}
+	healthcheck.GetHealthCheck().SetBackendsConfiguration(server.routinesPool.Ctx(), backends.healthChecks)
+	resolver.GetResolver().SetBackendsConfiguration(server.routinesPool.Ctx(), backends.resolvers)
+	healthcheck.GetServersAdmin().SetLoadBalancers(backends.balancers)
+	server.caches.Retain(configurations)
+	server.transports.retain()
+	server.loadedBackends = backends
+	server.loadedFrontends = frontends
+	//sort routes
+	for _, serverEntryPoint := range serverEntryPoints {
+		serverEntryPoint.httpRouter.GetHandler().SortRoutes()
+	}
+	return serverEntryPoints, nil
+}
+
+// loadBackendHandler loads the handler of the frontend backend: the one of the previous configuration, with the runtime
+// state of its load-balancer and health check, when it is built from the same configuration, or the one built in n
+func (server *Server) loadBackendHandler(n *negroni.Negroni, providerName string, config *types.Configuration, globalConfiguration configuration.GlobalConfiguration, entryPointName string, entryPoint *configuration.EntryPoint,
+	frontendName string, frontend *types.Frontend, backends *loadedBackends, errorHandler utils.ErrorHandler) error {
+	backendID := healthcheck.BackendID(providerName, entryPointName, frontend.Backend)
+	source := newBackendSource(config, globalConfiguration, entryPoint, frontendName, frontend)
+	if backends.reuse(server.loadedBackends, backendID, source) {
+		log.Debugf("Keeping backend %s, unchanged", frontend.Backend)
+		server.transports.use(backends.transports[backendID])
+		return nil
+	}
+	mark := server.transports.mark()
+	if err := server.buildBackendHandler(n, providerName, config, globalConfiguration, entryPointName, entryPoint, frontendName, frontend, backends.healthChecks, backends.resolvers, backends.balancers, errorHandler); err != nil {
+		return err
+	}
+	backends.handlers[backendID] = n
+	backends.sources[backendID] = source
+	backends.transports[backendID] = server.transports.takenSince(mark)
+	return nil
+}
+
+// buildTemplateBackends builds the handlers of the backends of the provider the backend template of the frontend
+// can be expanded to. They are built for the frontend only, without its middlewares, and not shared with the other
+// frontends using the same backends. The backends failing to build are left out of the template.
+func (server *Server) buildTemplateBackends(providerName string, config *types.Configuration, globalConfiguration configuration.GlobalConfiguration, entryPointName string, entryPoint *configuration.EntryPoint,
+	frontendName string, frontend *types.Frontend, backends *loadedBackends, errorHandler utils.ErrorHandler) map[string]http.Handler {
+	templateRegexp := middlewares.RouteVariablesRegexp(frontend.Backend)
+	handlers := make(map[string]http.Handler)
+	for _, backendName := range sortedBackendNamesForConfig(config) {
+		if !templateRegexp.MatchString(backendName) {
+			continue
+		}
+		// only what tells how to forward the requests to the backend is kept from the frontend
+		backendFrontend := &types.Frontend{Backend: backendName, PassHostHeader: frontend.PassHostHeader, PassTLSCert: frontend.PassTLSCert}
+		templateBackend := newLoadedBackends()
+		lb, err := server.buildBackendBalancer(providerName, config, globalConfiguration, entryPointName, entryPoint, frontendName, backendFrontend,
+			templateBackend.healthChecks, templateBackend.resolvers, templateBackend.balancers, errorHandler)
+		if err != nil {
+			log.Error(err)
+			log.Errorf("Skipping backend %s of frontend %s...", backendName, frontendName)
+			continue
+		}
+		n := negroni.New()
+		if err := server.buildFrontendHandler(n, lb, providerName, config, globalConfiguration, frontendName, &types.Frontend{Backend: backendName}, config.Backends[backendName]); err != nil {
+			log.Error(err)
+			log.Errorf("Skipping backend %s of frontend %s...", backendName, frontendName)
+			continue
+		}
+		handlers[backendName] = n
+		backends.addPrivate(templateBackend, frontendID(providerName, entryPointName, frontendName))
+	}
+	return handlers
+}
+
+// buildBackendHandler builds the handler of the frontend backend in n: load-balancer and middlewares
+func (server *Server) buildBackendHandler(n *negroni.Negroni, providerName string, config *types.Configuration, globalConfiguration configuration.GlobalConfiguration, entryPointName string, entryPoint *configuration.EntryPoint,
+	frontendName string, frontend *types.Frontend, backendsHealthCheck map[string]*healthcheck.BackendHealthCheck, backendsResolvers map[string]resolver.Refresher,
+	backendsBalancers map[string]*healthcheck.AdminLoadBalancer, errorHandler utils.ErrorHandler) error {
+	lb, err := server.buildBackendBalancer(providerName, config, globalConfiguration, entryPointName, entryPoint, frontendName, frontend, backendsHealthCheck, backendsResolvers, backendsBalancers, errorHandler)
+	if err != nil {
+		return err
+	}
+	return server.buildFrontendHandler(n, lb, providerName, config, globalConfiguration, frontendName, frontend, config.Backends[frontend.Backend])
+}
+
+// buildBackendBalancer builds the load-balancer of the frontend backend, forwarding the requests to its servers
+func (server *Server) buildBackendBalancer(providerName string, config *types.Configuration, globalConfiguration configuration.GlobalConfiguration, entryPointName string, entryPoint *configuration.EntryPoint,
+	frontendName string, frontend *types.Frontend, backendsHealthCheck map[string]*healthcheck.BackendHealthCheck, backendsResolvers map[string]resolver.Refresher,
+	backendsBalancers map[string]*healthcheck.AdminLoadBalancer, errorHandler utils.ErrorHandler) (http.Handler, error) {
+	log.Debugf("Creating backend %s", frontend.Backend)
+
+	if config.Backends[frontend.Backend] == nil {
+		return nil, fmt.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
+	}
+
+	roundTripper, err := server.getRoundTripper(globalConfiguration, frontend.PassTLSCert, entryPoint.TLS, config.Backends[frontend.Backend])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create RoundTripper for frontend %s: %v", frontendName, err)
+	}
+	// the health checks connect to the servers the same way as the requests, without statistics nor tracing
+	healthCheckTransport := roundTripper
+	if server.statistics != nil {
+		roundTripper = middlewares.NewBackendStatistics(server.statistics, frontend.Backend, roundTripper)
+	}
+	if server.tracer != nil {
+		roundTripper = tracing.NewTransport(server.tracer, frontend.Backend, roundTripper)
+	}
+
+	fwd, err := forward.New(
+		forward.Logger(oxyLogger),
+		forward.PassHostHeader(passHostHeader(frontend.PassHostHeader, config.Backends[frontend.Backend])),
+		forward.RoundTripper(roundTripper),
+		forward.ErrorHandler(errorHandler),
+		// gRPC streams must be flushed to the client as they come
+		forward.StreamResponse(config.Backends[frontend.Backend].Protocol == types.BackendProtocolH2C),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error creating forwarder for frontend %s: %v", frontendName, err)
+	}
+
+	var fwdHandler http.Handler = fwd
+	passiveHealthCheck := parsePassiveHealthCheck(fwd, frontend.Backend, config.Backends[frontend.Backend].PassiveHealthCheck)
+	if passiveHealthCheck != nil {
+		log.Debugf("Setting up backend passive health check %s", passiveHealthCheck.PassiveOptions)
+		fwdHandler = passiveHealthCheck
+	}
+
+	var rr *roundrobin.RoundRobin
+	var saveFrontend http.Handler
+	if server.accessLoggerMiddleware != nil {
+		saveBackend := accesslog.NewSaveBackend(fwdHandler, frontend.Backend)
+		saveFrontend = accesslog.NewSaveFrontend(saveBackend, frontendName)
+		rr, _ = roundrobin.New(saveFrontend)
+	} else {
+		rr, _ = roundrobin.New(fwdHandler)
+	}
+
+	lbMethod, err := types.NewLoadBalancerMethod(config.Backends[frontend.Backend].LoadBalancer)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", config.Backends[frontend.Backend].LoadBalancer, frontendName, err)
+	}
+
+	var sticky *roundrobin.StickySession
+	var cookieName string
+	stickiness := config.Backends[frontend.Backend].LoadBalancer.Stickiness
+	if stickiness != nil {
+		if !cookie.IsValidSameSite(stickiness.SameSite) {
+			return nil, fmt.Errorf("Invalid SameSite '%s' for sticky cookie of frontend %s", stickiness.SameSite, frontendName)
+		}
+		cookieName = cookie.GetName(stickiness.CookieName, frontend.Backend)
+		sticky = roundrobin.NewStickySession(cookieName)
+	}
+
+	var balancer serversLoadBalancer
+	switch lbMethod {
+	case types.Drr:
+		log.Debugf("Creating load-balancer drr")
+		if sticky != nil {
+			log.Debugf("Sticky session with cookie %v", cookieName)
+			balancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger), roundrobin.RebalancerStickySession(sticky))
+		} else {
+			balancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger))
+		}
+	case types.Wrr:
+		log.Debugf("Creating load-balancer wrr")
+		if sticky != nil {
+			log.Debugf("Sticky session with cookie %v", cookieName)
+			if server.accessLoggerMiddleware != nil {
+				rr, _ = roundrobin.New(saveFrontend, roundrobin.EnableStickySession(sticky))
+			} else {
+				rr, _ = roundrobin.New(fwdHandler, roundrobin.EnableStickySession(sticky))
+			}
+		}
+		balancer = rr
+	case types.Hash:
+		log.Debugf("Creating load-balancer hash")
+		if sticky != nil {
+			log.Warnf("Sticky sessions are not used by the hash load-balancer of frontend %s", frontendName)
+			sticky = nil
+		}
+		balancer, err = loadbalancer.NewHashBalancer(rr, config.Backends[frontend.Backend].LoadBalancer.ExtractorFunc)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating hash load-balancer for frontend %s: %v", frontendName, err)
+		}
+	case types.LeastConn, types.P2C:
+		if lbMethod == types.LeastConn {
+			log.Debugf("Creating load-balancer leastconn")
+			balancer = loadbalancer.NewLeastConnBalancer(rr)
+		} else {
+			log.Debugf("Creating load-balancer p2c")
+			balancer = loadbalancer.NewP2CBalancer(rr)
+		}
+		if sticky != nil {
+			log.Warnf("Sticky sessions are not used by the %s load-balancer of frontend %s", config.Backends[frontend.Backend].LoadBalancer.Method, frontendName)
+			sticky = nil
+		}
+	}
+
+	var lb http.Handler
+	var serversBalancer *healthcheck.AdminLoadBalancer
+	if balancer != nil {
+		serversBalancer, err = setupServersBalancer(balancer, providerName, entryPointName, config, frontend, globalConfiguration, healthCheckTransport, passiveHealthCheck, backendsHealthCheck)
+		if err != nil {
+			return nil, err
+		}
+		lb = middlewares.NewEmptyBackendHandler(balancer, balancer)
+	}
+
+	if serversBalancer != nil {
+		backendsBalancers[healthcheck.BackendID(providerName, entryPointName, frontend.Backend)] = serversBalancer
+	}
+
+	if dnsSRV := config.Backends[frontend.Backend].DNSSRV; dnsSRV != nil && serversBalancer != nil {
+		backendResolver, err := resolver.NewBackendResolver(serversBalancer, dnsSRV)
+		if err != nil {
+			return nil, fmt.Errorf("Error resolving the servers of backend %s for frontend %s: %v", frontend.Backend, frontendName, err)
+		}
+		// the backend starts with its configured servers, the resolver adding the resolved ones in the background
+		backendsResolvers[healthcheck.BackendID(providerName, entryPointName, frontend.Backend)] = backendResolver
+	}
+
+	if hostsResolver := server.buildHostsResolver(globalConfiguration, config.Backends[frontend.Backend], healthCheckTransport); hostsResolver != nil {
+		backendsResolvers[healthcheck.BackendID(providerName, entryPointName, frontend.Backend)+"/hosts"] = hostsResolver
+	}
+
+	if sticky != nil && (stickiness.Secure || stickiness.HTTPOnly || len(stickiness.SameSite) > 0) {
+		lb = middlewares.NewStickyCookie(lb, cookieName, stickiness.Secure, stickiness.HTTPOnly, stickiness.SameSite)
+	}
+	return lb, nil
+}
+
+// serversLoadBalancer is a load-balancer among the servers of a backend
+type serversLoadBalancer interface {
+	http.Handler
+	healthcheck.LoadBalancer
+}
+
+// setupServersBalancer adds the servers of the backend to its load-balancer, wrapped to be administrated, and sets
+// up the active and passive health checks of the servers
+func setupServersBalancer(balancer serversLoadBalancer, providerName string, entryPointName string, config *types.Configuration, frontend *types.Frontend, globalConfiguration configuration.GlobalConfiguration,
+	healthCheckTransport http.RoundTripper, passiveHealthCheck *healthcheck.PassiveHealthCheck, backendsHealthCheck map[string]*healthcheck.BackendHealthCheck) (*healthcheck.AdminLoadBalancer, error) {
+	serversBalancer := healthcheck.GetServersAdmin().NewLoadBalancer(providerName, frontend.Backend, balancer, backendServerNames(config.Backends[frontend.Backend]))
+	if err := configureLBServers(serversBalancer, config, frontend); err != nil {
+		return nil, err
+	}
+	hcOpts := parseHealthCheckOptions(serversBalancer, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+	if hcOpts != nil {
+		log.Debugf("Setting up backend health check %s", *hcOpts)
+		hcOpts.Transport = healthCheckTransport
+		backendsHealthCheck[healthcheck.BackendID(providerName, entryPointName, frontend.Backend)] = healthcheck.NewBackendHealthCheck(*hcOpts)
+	}
+	if passiveHealthCheck != nil {
+		passiveHealthCheck.SetLoadBalancer(serversBalancer)
+	}
+	return serversBalancer, nil
+}
+
+// buildFrontendHandler builds in n the middlewares of the frontend and of its backend around lb, the handler forwarding
+// the requests, the backend being nil when lb chooses among several backends
+func (server *Server) buildFrontendHandler(n *negroni.Negroni, lb http.Handler, providerName string, config *types.Configuration, globalConfiguration configuration.GlobalConfiguration, frontendName string, frontend *types.Frontend, backend *types.Backend) error {
+	var err error
+	if len(frontend.Errors) > 0 {
+		for _, errorPage := range frontend.Errors {
+			if len(errorPage.File) > 0 {
+				errorPageHandler, err := middlewares.NewErrorPagesHandler(errorPage, "")
+				if err != nil {
+					log.Errorf("Error creating custom error page middleware, %v", err)
+				} else {
+					n.Use(errorPageHandler)
+				}
+			} else if config.Backends[errorPage.Backend] != nil && config.Backends[errorPage.Backend].Servers["error"].URL != "" {
+				errorPageHandler, err := middlewares.NewErrorPagesHandler(errorPage, config.Backends[errorPage.Backend].Servers["error"].URL)
+				if err != nil {
+					log.Errorf("Error creating custom error page middleware, %v", err)
+				} else {
+					n.Use(errorPageHandler)
+				}
+			} else {
+				log.Errorf("Error Page is configured for Frontend %s, but either Backend %s is not set or Backend URL is missing", frontendName, errorPage.Backend)
+			}
+		}
+	}
+
+	// the maintenance response goes through the error pages of the frontend, but not through the other middlewares
+	if frontend.Maintenance != nil {
+		maintenance, err := middlewares.NewMaintenance(frontend.Maintenance)
+		if err != nil {
+			return fmt.Errorf("Error creating maintenance for frontend %s: %v", frontendName, err)
+		}
+		log.Infof("Frontend %s is in maintenance", frontendName)
+		n.Use(maintenance)
+	}
+
+	if frontend.RateLimit != nil && len(frontend.RateLimit.RateSet) > 0 {
+		lb, err = server.buildRateLimiter(lb, frontend.RateLimit)
+		if err != nil {
+			return fmt.Errorf("Error creating rate limiter: %v", err)
+		}
+	}
+
+	if backend != nil && backend.MaxConn != nil && backend.MaxConn.Amount != 0 {
+		maxConns := backend.MaxConn
+		if maxConns.Amount < 0 {
+			return fmt.Errorf("Error creating connlimit: invalid amount %d, must not be negative", maxConns.Amount)
+		}
+		extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
+		if err != nil {
+			return fmt.Errorf("Error creating connlimit: %v", err)
+		}
+		if maxConns.StatusCode != 0 && maxConns.StatusCode != http.StatusTooManyRequests && maxConns.StatusCode != http.StatusServiceUnavailable {
+			return fmt.Errorf("Error creating connlimit: invalid status code %d, must be 429 or 503", maxConns.StatusCode)
+		}
+		var queueTimeout time.Duration
+		if len(maxConns.QueueTimeout) > 0 {
+			queueTimeout, err = time.ParseDuration(maxConns.QueueTimeout)
+			if err != nil {
+				return fmt.Errorf("Error creating connlimit: invalid queue timeout %q: %v", maxConns.QueueTimeout, err)
+			}
+		}
+		log.Debugf("Creating load-balancer connlimit")
+		lb = middlewares.NewConnLimiter(lb, extractFunc, maxConns.Amount, maxConns.QueueSize, queueTimeout, maxConns.StatusCode)
+	}
+
+	if globalConfiguration.Retry != nil && backend != nil || frontend.Retry != nil {
+		var countServers int
+		if backend != nil {
+			countServers = len(backend.Servers)
+		}
+		lb, err = server.buildRetryMiddleware(lb, globalConfiguration, frontend.Retry, countServers, frontend.Backend)
+		if err != nil {
+			return fmt.Errorf("Error creating retries for frontend %s: %v", frontendName, err)
+		}
+	}
+
+	if backend != nil && backend.Buffering != nil {
+		buffering := backend.Buffering
+		log.Debugf("Buffering the requests and responses of frontend %s", frontendName)
+		lb, err = middlewares.NewBuffering(lb, buffering.MemRequestBodyBytes, buffering.MaxRequestBodyBytes, buffering.MemResponseBodyBytes, buffering.MaxResponseBodyBytes)
+		if err != nil {
+			return fmt.Errorf("Error creating buffering for frontend %s: %v", frontendName, err)
+		}
+	}
+
+	if frontend.Mirror != nil {
+		if frontend.Mirror.Percent < 0 || frontend.Mirror.Percent > 100 {
+			return fmt.Errorf("Invalid mirror percentage %d for frontend %s", frontend.Mirror.Percent, frontendName)
+		}
+		mirrorHandler, err := server.buildMirrorHandler(globalConfiguration, config, frontend)
+		if err != nil {
+			return fmt.Errorf("Error creating mirror for frontend %s: %v", frontendName, err)
+		}
+		log.Debugf("Mirroring %d%% of the requests of frontend %s to backend %s", frontend.Mirror.Percent, frontendName, frontend.Mirror.Backend)
+		lb = middlewares.NewMirror(lb, mirrorHandler, frontend.Mirror.Percent)
+	}
+
+	if frontend.Cache != nil {
+		cache, err := server.caches.Get(providerName, frontendName, *frontend.Cache)
+		if err != nil {
+			return fmt.Errorf("Error creating cache for frontend %s: %v", frontendName, err)
+		}
+		log.Debugf("Caching the responses of frontend %s", frontendName)
+		lb = cache.Handler(lb)
+	}
+
+	if server.metricsRegistry.IsEnabled() && backend != nil {
+		n.Use(middlewares.NewMetricsWrapper(server.metricsRegistry, frontend.Backend))
+	}
+
+	ipWhitelistMiddleware, err := configureIPWhitelistMiddleware(frontend.WhitelistSourceRange, frontend.BlacklistSourceRange, frontend.IPStrategy)
+	if err != nil {
+		return fmt.Errorf("Error creating IP Whitelister for frontend %s: %v", frontendName, err)
+	} else if ipWhitelistMiddleware != nil {
+		n.Use(ipWhitelistMiddleware)
+		log.Infof("Configured IP Whitelists: %s, Blacklists: %s", frontend.WhitelistSourceRange, frontend.BlacklistSourceRange)
+	}
+
+	if frontend.GeoIP != nil {
+		if server.geoIPDatabase == nil {
+			return fmt.Errorf("Error creating GeoIP filter for frontend %s: no GeoIP database", frontendName)
+		}
+		geoIPFilter, err := middlewares.NewGeoIPFilter(frontend.GeoIP)
+		if err != nil {
+			return fmt.Errorf("Error creating GeoIP filter for frontend %s: %v", frontendName, err)
+		}
+		n.Use(geoIPFilter)
+	}
+
+	if frontend.UserAgent != nil {
+		userAgentFilter, err := middlewares.NewUserAgentFilter(frontend.UserAgent)
+		if err != nil {
+			return fmt.Errorf("Error creating user agent filter for frontend %s: %v", frontendName, err)
+		}
+		n.Use(userAgentFilter)
+	}
+
+	if frontend.WAF != nil {
+		waf, err := middlewares.NewWAF(frontendName, frontend.WAF)
+		if err != nil {
+			return fmt.Errorf("Error creating WAF for frontend %s: %v", frontendName, err)
+		}
+		log.Debugf("Inspecting the requests of frontend %s with the WAF engine %s", frontendName, frontend.WAF.Address)
+		n.Use(waf)
+	}
+
+	if frontend.Redirect != nil {
+		redirect, err := middlewares.NewRedirect(frontend.Redirect.Regex, frontend.Redirect.Replacement, frontend.Redirect.StatusCode)
+		if err != nil {
+			return fmt.Errorf("Error creating redirect for frontend %s: %v", frontendName, err)
+		}
+		log.Debugf("Creating frontend %s redirect %s -> %s", frontendName, frontend.Redirect.Regex, frontend.Redirect.Replacement)
+		n.Use(redirect)
+	}
+
+	if len(frontend.BasicAuth) > 0 || len(frontend.BasicAuthUsersFile) > 0 {
+		users := types.Users{}
+		for _, user := range frontend.BasicAuth {
+			users = append(users, user)
+		}
+
+		auth := &types.Auth{HeaderField: frontend.BasicAuthHeaderField}
+		auth.Basic = &types.Basic{
+			Users:     users,
+			UsersFile: frontend.BasicAuthUsersFile,
+		}
+		authMiddleware, err := mauth.NewAuthenticator(auth)
+		if err != nil {
+			return fmt.Errorf("Error creating Auth: %s", err)
+		}
+		n.Use(authMiddleware)
+	}
+
+	if frontend.Auth != nil {
+		authMiddleware, err := mauth.NewAuthenticator(frontend.Auth)
+		if err != nil {
+			return fmt.Errorf("Error creating Auth: %s", err)
+		}
+		n.Use(authMiddleware)
+	}
+
+	if frontend.Limits != nil && (frontend.Limits.MaxRequestBodyBytes > 0 || frontend.Limits.MaxRequestHeaderBytes > 0) {
+		log.Debugf("Limiting the requests of frontend %s to %d bytes of body and %d bytes of headers", frontendName, frontend.Limits.MaxRequestBodyBytes, frontend.Limits.MaxRequestHeaderBytes)
+		n.Use(middlewares.NewRequestLimits(frontend.Limits.MaxRequestBodyBytes, frontend.Limits.MaxRequestHeaderBytes))
+	}
+
+	if frontend.PassTLSCert {
+		log.Debugf("Adding TLS client headers middleware for frontend %s", frontendName)
+		n.Use(middlewares.NewTLSClientHeaders())
+	}
+
+	if frontend.Headers.HasCustomHeadersDefined() {
+		headerMiddleware := middlewares.NewHeaderFromStruct(frontend.Headers)
+		log.Debugf("Adding header middleware for frontend %s", frontendName)
+		n.Use(headerMiddleware)
+	}
+	if frontend.Headers.HasSecureHeadersDefined() {
+		secureMiddleware := middlewares.NewSecure(frontend.Headers)
+		log.Debugf("Adding secure middleware for frontend %s", frontendName)
+		n.UseFunc(secureMiddleware.HandlerFuncWithNext)
+	}
+
+	if backend != nil && backend.CircuitBreaker != nil {
+		log.Debugf("Creating circuit breaker %s", backend.CircuitBreaker.Expression)
+		circuitBreaker, err := middlewares.NewCircuitBreaker(lb, backend.CircuitBreaker.Expression, cbreaker.Logger(oxyLogger))
+		if err != nil {
+			return fmt.Errorf("Error creating circuit breaker: %v", err)
+		}
+		n.Use(circuitBreaker)
+	} else {
+		n.UseHandler(lb)
+	}
+	return nil
+}
+
+// buildMiddlewareChain wraps the handler of a frontend in its named middlewares, the first one handling the requests first
+func (server *Server) buildMiddlewareChain(handler http.Handler, config *types.Configuration, frontendName string, frontend *types.Frontend) (http.Handler, error) {
+	for i := len(frontend.Middlewares) - 1; i >= 0; i-- {
+		middlewareName := frontend.Middlewares[i]
+		middleware, ok := config.Middlewares[middlewareName]
+		if !ok || middleware == nil {
+			return nil, fmt.Errorf("Undefined middleware '%s' for frontend %s", middlewareName, frontendName)
+		}
+		var err error
+		handler, err = server.buildMiddleware(handler, middlewareName, middleware)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating middleware %s for frontend %s: %v", middlewareName, frontendName, err)
+		}
+		log.Debugf("Adding middleware %s to frontend %s", middlewareName, frontendName)
+	}
+	return handler, nil
+}
+
+// buildMiddleware wraps next in the middleware configured by the only field set in middleware
+func (server *Server) buildMiddleware(next http.Handler, middlewareName string, middleware *types.Middleware) (http.Handler, error) {
+	var handlers []negroni.Handler
+	var kinds int
+	if middleware.Headers != nil {
+		kinds++
+		if middleware.Headers.HasCustomHeadersDefined() {
+			handlers = append(handlers, middlewares.NewHeaderFromStruct(*middleware.Headers))
+		}
+		if middleware.Headers.HasSecureHeadersDefined() {
+			handlers = append(handlers, negroni.HandlerFunc(middlewares.NewSecure(*middleware.Headers).HandlerFuncWithNext))
+		}
+	}
+	if middleware.Auth != nil {
+		kinds++
+		authMiddleware, err := mauth.NewAuthenticator(middleware.Auth)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, authMiddleware)
+	}
+	if middleware.IPFilter != nil {
+		kinds++
+		ipFilter, err := middlewares.NewIPFilter(middleware.IPFilter.WhitelistSourceRange, middleware.IPFilter.BlacklistSourceRange, middleware.IPFilter.IPStrategy)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, ipFilter)
+	}
+	if middleware.Redirect != nil {
+		kinds++
+		redirect, err := middlewares.NewRedirect(middleware.Redirect.Regex, middleware.Redirect.Replacement, middleware.Redirect.StatusCode)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, redirect)
+	}
+	if middleware.Limits != nil {
+		kinds++
+		handlers = append(handlers, middlewares.NewRequestLimits(middleware.Limits.MaxRequestBodyBytes, middleware.Limits.MaxRequestHeaderBytes))
+	}
+	if middleware.RateLimit != nil {
+		kinds++
+		if len(middleware.RateLimit.RateSet) == 0 {
+			return nil, errors.New("no rate set")
+		}
+	}
+	if middleware.Plugin != nil {
+		kinds++
+	}
+	if middleware.WASM != nil {
+		kinds++
+		wasmMiddleware, err := middlewares.NewWASM(middlewareName, middleware.WASM)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, wasmMiddleware)
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("exactly one of headers, auth, ratelimit, ipFilter, redirect, limits, plugin and wasm must be set, got %d", kinds)
+	}
+
+	if middleware.RateLimit != nil {
+		return server.buildRateLimiter(next, middleware.RateLimit)
+	}
+	if middleware.Plugin != nil {
+		constructor, ok := plugins.Get(middleware.Plugin.Name)
+		if !ok {
+			return nil, fmt.Errorf("unregistered plugin middleware '%s', registered: %v", middleware.Plugin.Name, plugins.Names())
+		}
+		return constructor(next, middleware.Plugin.Settings)
+	}
+	n := negroni.New(handlers...)
+	n.UseHandler(next)
+	return n, nil
+}
 
-					roundTripper, err := server.getRoundTripper(globalConfiguration, frontend.PassTLSCert, entryPoint.TLS)
-					if err != nil {
-						log.Errorf("Failed to create RoundTripper for frontend %s: %v", frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+// loadDynamicCertificates adds the certificates provided by a dynamic configuration
+// to the TLS entry points they are bound to.
+func (server *Server) loadDynamicCertificates(tlsConfigurations []*types.TLSConfiguration, serverEntryPoints map[string]*serverEntryPoint, globalConfiguration configuration.GlobalConfiguration) {
+	for _, tlsConfiguration := range tlsConfigurations {
+		if tlsConfiguration == nil || tlsConfiguration.Certificate == nil {
+			continue
+		}
 
-					fwd, err := forward.New(
-						forward.Logger(oxyLogger),
-						forward.PassHostHeader(frontend.PassHostHeader),
-						forward.RoundTripper(roundTripper),
-						forward.ErrorHandler(errorHandler),
-					)
+		certificate := &configuration.Certificate{
+			CertFile: configuration.FileOrContent(tlsConfiguration.Certificate.CertFile),
+			KeyFile:  configuration.FileOrContent(tlsConfiguration.Certificate.KeyFile),
+		}
+		cert, err := certificate.CreateTLSCertificate()
+		if err != nil {
+			log.Errorf("Error loading dynamic certificate: %v", err)
+			continue
+		}
+		// BuildNameToCertificate parses the CommonName and SubjectAlternateName fields of the certificate
+		config := &tls.Config{Certificates: []tls.Certificate{*cert}}
+		config.BuildNameToCertificate()
 
-					if err != nil {
-						log.Errorf("Error creating forwarder for frontend %s: %v", frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+		entryPoints := tlsConfiguration.EntryPoints
+		if len(entryPoints) == 0 {
+			entryPoints = globalConfiguration.DefaultEntryPoints
+		}
+		for _, entryPointName := range entryPoints {
+			serverEntryPoint, ok := serverEntryPoints[entryPointName]
+			if !ok {
+				log.Errorf("Undefined entrypoint '%s' for dynamic certificate", entryPointName)
+				continue
+			}
+			if globalConfiguration.EntryPoints[entryPointName].TLS == nil {
+				log.Errorf("Entrypoint '%s' is not configured with TLS, skipping dynamic certificate", entryPointName)
+				continue
+			}
 
-					var rr *roundrobin.RoundRobin
-					var saveFrontend http.Handler
-					if server.accessLoggerMiddleware != nil {
-						saveBackend := accesslog.NewSaveBackend(fwd, frontend.Backend)
-						saveFrontend = accesslog.NewSaveFrontend(saveBackend, frontendName)
-						rr, _ = roundrobin.New(saveFrontend)
-					} else {
-						rr, _ = roundrobin.New(fwd)
-					}
+			dynamicCerts, ok := serverEntryPoint.dynamicCerts.Get().(map[string]*tls.Certificate)
+			if !ok {
+				dynamicCerts = make(map[string]*tls.Certificate)
+				serverEntryPoint.dynamicCerts.Set(dynamicCerts)
+			}
+			for domain, domainCert := range config.NameToCertificate {
+				log.Debugf("Adding dynamic certificate for domain %s on entrypoint %s", domain, entryPointName)
+				dynamicCerts[domain] = domainCert
+			}
+		}
+	}
+}
 
-					if config.Backends[frontend.Backend] == nil {
-						log.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+// loadTCPConfig adds the TCP frontends of a dynamic configuration to the routers of the TCP entry points.
+func (server *Server) loadTCPConfig(config *types.Configuration, serverEntryPoints map[string]*serverEntryPoint, globalConfiguration configuration.GlobalConfiguration) {
+	dialTimeout := createDialer(globalConfiguration).Timeout
 
-					lbMethod, err := types.NewLoadBalancerMethod(config.Backends[frontend.Backend].LoadBalancer)
-					if err != nil {
-						log.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", config.Backends[frontend.Backend].LoadBalancer, frontendName, err)
-						log.Errorf("Skipping frontend %s...", frontendName)
-						continue frontend
-					}
+	frontendNames := make([]string, 0, len(config.TCPFrontends))
+	for frontendName := range config.TCPFrontends {
+		frontendNames = append(frontendNames, frontendName)
+	}
+	sort.Strings(frontendNames)
 
-					var sticky *roundrobin.StickySession
-					var cookieName string
-					if stickiness := config.Backends[frontend.Backend].LoadBalancer.Stickiness; stickiness != nil {
-						cookieName = cookie.GetName(stickiness.CookieName, frontend.Backend)
-						sticky = roundrobin.NewStickySession(cookieName)
-					}
+frontend:
+	for _, frontendName := range frontendNames {
+		frontend := config.TCPFrontends[frontendName]
 
-					var lb http.Handler
-					switch lbMethod {
-					case types.Drr:
-						log.Debugf("Creating load-balancer drr")
-						rebalancer, _ := roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger))
-						if sticky != nil {
-							log.Debugf("Sticky session with cookie %v", cookieName)
-							rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger), roundrobin.RebalancerStickySession(sticky))
-						}
-						lb = rebalancer
-						if err := configureLBServers(rebalancer, config, frontend); err != nil {
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-						hcOpts := parseHealthCheckOptions(rebalancer, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
-						}
-						lb = middlewares.NewEmptyBackendHandler(rebalancer, lb)
-					case types.Wrr:
-						log.Debugf("Creating load-balancer wrr")
-						if sticky != nil {
-							log.Debugf("Sticky session with cookie %v", cookieName)
-							if server.accessLoggerMiddleware != nil {
-								rr, _ = roundrobin.New(saveFrontend, roundrobin.EnableStickySession(sticky))
-							} else {
-								rr, _ = roundrobin.New(fwd, roundrobin.EnableStickySession(sticky))
-							}
-						}
-						lb = rr
-						if err := configureLBServers(rr, config, frontend); err != nil {
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-						hcOpts := parseHealthCheckOptions(rr, frontend.Backend, config.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							backendsHealthCheck[entryPointName+frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
-						}
-						lb = middlewares.NewEmptyBackendHandler(rr, lb)
-					}
+		backend, ok := config.TCPBackends[frontend.Backend]
+		if !ok {
+			log.Errorf("Undefined TCP backend '%s' for TCP frontend %s", frontend.Backend, frontendName)
+			log.Errorf("Skipping TCP frontend %s...", frontendName)
+			continue
+		}
+		if len(frontend.EntryPoints) == 0 {
+			log.Errorf("No entrypoint defined for TCP frontend %s", frontendName)
+			log.Errorf("Skipping TCP frontend %s...", frontendName)
+			continue
+		}
 
-					if len(frontend.Errors) > 0 {
-						for _, errorPage := range frontend.Errors {
-							if config.Backends[errorPage.Backend] != nil && config.Backends[errorPage.Backend].Servers["error"].URL != "" {
-								errorPageHandler, err := middlewares.NewErrorPagesHandler(errorPage, config.Backends[errorPage.Backend].Servers["error"].URL)
-								if err != nil {
-									log.Errorf("Error creating custom error page middleware, %v", err)
-								} else {
-									n.Use(errorPageHandler)
-								}
-							} else {
-								log.Errorf("Error Page is configured for Frontend %s, but either Backend %s is not set or Backend URL is missing", frontendName, errorPage.Backend)
-							}
-						}
-					}
+		serverNames := make([]string, 0, len(backend.Servers))
+		for serverName := range backend.Servers {
+			serverNames = append(serverNames, serverName)
+		}
+		sort.Strings(serverNames)
 
-					if frontend.RateLimit != nil && len(frontend.RateLimit.RateSet) > 0 {
-						lb, err = server.buildRateLimiter(lb, frontend.RateLimit)
-						if err != nil {
-							log.Errorf("Error creating rate limiter: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-					}
+		var proxyProtocolVersion int
+		if backend.ProxyProtocol != nil {
+			proxyProtocolVersion = backend.ProxyProtocol.Version
+			if proxyProtocolVersion == 0 {
+				proxyProtocolVersion = 2
+			}
+			if proxyProtocolVersion != 1 && proxyProtocolVersion != 2 {
+				log.Errorf("Invalid PROXY protocol version %d for TCP backend %s", proxyProtocolVersion, frontend.Backend)
+				log.Errorf("Skipping TCP frontend %s...", frontendName)
+				continue
+			}
+		}
 
-					maxConns := config.Backends[frontend.Backend].MaxConn
-					if maxConns != nil && maxConns.Amount != 0 {
-						extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
-						if err != nil {
-							log.Errorf("Error creating connlimit: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-						log.Debugf("Creating load-balancer connlimit")
-						lb, err = connlimit.New(lb, extractFunc, maxConns.Amount, connlimit.Logger(oxyLogger))
-						if err != nil {
-							log.Errorf("Error creating connlimit: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-					}
+		lb := tcp.NewRRLoadBalancer()
+		for _, serverName := range serverNames {
+			address := backend.Servers[serverName].Address
+			log.Debugf("Creating TCP server %s at %s", serverName, address)
+			lb.AddServer(tcp.NewProxy(address, dialTimeout, proxyProtocolVersion))
+		}
 
-					if globalConfiguration.Retry != nil {
-						countServers := len(config.Backends[frontend.Backend].Servers)
-						lb = server.buildRetryMiddleware(lb, globalConfiguration, countServers, frontend.Backend)
-					}
+		// the routes are added once checked on all the entry points, for a frontend not to be served on some of them only
+		routers := make(map[string]*tcp.Router)
+		for _, entryPointName := range frontend.EntryPoints {
+			serverEntryPoint, ok := serverEntryPoints[entryPointName]
+			if !ok || serverEntryPoint.tcpRouter == nil {
+				log.Errorf("Undefined TCP entrypoint '%s' for TCP frontend %s", entryPointName, frontendName)
+				log.Errorf("Skipping TCP frontend %s...", frontendName)
+				continue frontend
+			}
+			if len(frontend.SNI) > 0 && !frontend.Passthrough && globalConfiguration.EntryPoints[entryPointName].TLS == nil {
+				log.Errorf("Entrypoint '%s' is not configured with TLS, SNI routing requires passthrough for TCP frontend %s", entryPointName, frontendName)
+				log.Errorf("Skipping TCP frontend %s...", frontendName)
+				continue frontend
+			}
+			router := serverEntryPoint.tcpRouter.GetHandler()
+			if err := router.CheckRoute(frontend.SNI); err != nil {
+				log.Errorf("Error creating TCP route for frontend %s on entrypoint %s: %v", frontendName, entryPointName, err)
+				log.Errorf("Skipping TCP frontend %s...", frontendName)
+				continue frontend
+			}
+			routers[entryPointName] = router
+		}
 
-					if server.metricsRegistry.IsEnabled() {
-						n.Use(middlewares.NewMetricsWrapper(server.metricsRegistry, frontend.Backend))
-					}
+		for _, entryPointName := range frontend.EntryPoints {
+			router, ok := routers[entryPointName]
+			if !ok {
+				continue
+			}
+			delete(routers, entryPointName)
+			log.Debugf("Creating TCP route for frontend %s on entrypoint %s with SNI %v", frontendName, entryPointName, frontend.SNI)
+			if err := router.AddRoute(frontend.SNI, frontend.Passthrough, lb); err != nil {
+				log.Errorf("Error creating TCP route for frontend %s on entrypoint %s: %v", frontendName, entryPointName, err)
+			}
+		}
+	}
+}
 
-					ipWhitelistMiddleware, err := configureIPWhitelistMiddleware(frontend.WhitelistSourceRange)
-					if err != nil {
-						log.Fatalf("Error creating IP Whitelister: %s", err)
-					} else if ipWhitelistMiddleware != nil {
-						n.Use(ipWhitelistMiddleware)
-						log.Infof("Configured IP Whitelists: %s", frontend.WhitelistSourceRange)
-					}
+// loadUDPConfig binds the UDP frontends of a dynamic configuration to the UDP entry points.
+// An entry point serves a single UDP frontend.
+func (server *Server) loadUDPConfig(config *types.Configuration, serverEntryPoints map[string]*serverEntryPoint) {
+	frontendNames := make([]string, 0, len(config.UDPFrontends))
+	for frontendName := range config.UDPFrontends {
+		frontendNames = append(frontendNames, frontendName)
+	}
+	sort.Strings(frontendNames)
 
-					if len(frontend.BasicAuth) > 0 {
-						users := types.Users{}
-						for _, user := range frontend.BasicAuth {
-							users = append(users, user)
-						}
+frontend:
+	for _, frontendName := range frontendNames {
+		frontend := config.UDPFrontends[frontendName]
 
-						auth := &types.Auth{}
-						auth.Basic = &types.Basic{
-							Users: users,
-						}
-						authMiddleware, err := mauth.NewAuthenticator(auth)
-						if err != nil {
-							log.Errorf("Error creating Auth: %s", err)
-						} else {
-							n.Use(authMiddleware)
-						}
-					}
+		backend, ok := config.UDPBackends[frontend.Backend]
+		if !ok {
+			log.Errorf("Undefined UDP backend '%s' for UDP frontend %s", frontend.Backend, frontendName)
+			log.Errorf("Skipping UDP frontend %s...", frontendName)
+			continue
+		}
+		if len(frontend.EntryPoints) == 0 {
+			log.Errorf("No entrypoint defined for UDP frontend %s", frontendName)
+			log.Errorf("Skipping UDP frontend %s...", frontendName)
+			continue
+		}
 
-					if frontend.Headers.HasCustomHeadersDefined() {
-						headerMiddleware := middlewares.NewHeaderFromStruct(frontend.Headers)
-						log.Debugf("Adding header middleware for frontend %s", frontendName)
-						n.Use(headerMiddleware)
-					}
-					if frontend.Headers.HasSecureHeadersDefined() {
-						secureMiddleware := middlewares.NewSecure(frontend.Headers)
-						log.Debugf("Adding secure middleware for frontend %s", frontendName)
-						n.UseFunc(secureMiddleware.HandlerFuncWithNext)
-					}
+		serverNames := make([]string, 0, len(backend.Servers))
+		for serverName := range backend.Servers {
+			serverNames = append(serverNames, serverName)
+		}
+		sort.Strings(serverNames)
+
+		// the servers are added once all the entry points are checked, for a frontend not to be bound to some of them only
+		var entryPointNames []string
+		balancers := make(map[string]*udp.LoadBalancer)
+		for _, entryPointName := range frontend.EntryPoints {
+			serverEntryPoint, ok := serverEntryPoints[entryPointName]
+			if !ok || serverEntryPoint.udpLoadBalancer == nil {
+				log.Errorf("Undefined UDP entrypoint '%s' for UDP frontend %s", entryPointName, frontendName)
+				log.Errorf("Skipping UDP frontend %s...", frontendName)
+				continue frontend
+			}
 
-					if config.Backends[frontend.Backend].CircuitBreaker != nil {
-						log.Debugf("Creating circuit breaker %s", config.Backends[frontend.Backend].CircuitBreaker.Expression)
-						circuitBreaker, err := middlewares.NewCircuitBreaker(lb, config.Backends[frontend.Backend].CircuitBreaker.Expression, cbreaker.Logger(oxyLogger))
-						if err != nil {
-							log.Errorf("Error creating circuit breaker: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
-						}
-						n.Use(circuitBreaker)
-					} else {
-						n.UseHandler(lb)
-					}
-					backends[entryPointName+frontend.Backend] = n
-				} else {
-					log.Debugf("Reusing backend %s", frontend.Backend)
-				}
-				if frontend.Priority > 0 {
-					newServerRoute.route.Priority(frontend.Priority)
-				}
-				server.wireFrontendBackend(newServerRoute, backends[entryPointName+frontend.Backend])
+			lb := serverEntryPoint.udpLoadBalancer.GetLoadBalancer()
+			if len(lb.Servers()) > 0 {
+				log.Errorf("UDP entrypoint '%s' already bound to another UDP frontend", entryPointName)
+				log.Errorf("Skipping UDP frontend %s...", frontendName)
+				continue frontend
+			}
+			if _, ok := balancers[entryPointName]; !ok {
+				entryPointNames = append(entryPointNames, entryPointName)
+				balancers[entryPointName] = lb
+			}
+		}
 
-				err := newServerRoute.route.GetError()
-				if err != nil {
-					log.Errorf("Error building route: %s", err)
-				}
+		for _, entryPointName := range entryPointNames {
+			lb := balancers[entryPointName]
+			for _, serverName := range serverNames {
+				address := backend.Servers[serverName].Address
+				log.Debugf("Creating UDP server %s at %s on entrypoint %s", serverName, address, entryPointName)
+				lb.AddServer(address)
 			}
 		}
 	}
-	healthcheck.GetHealthCheck().SetBackendsConfiguration(server.routinesPool.Ctx(), backendsHealthCheck)
-	//sort routes
-	for _, serverEntryPoint := range serverEntryPoints {
-		serverEntryPoint.httpRouter.GetHandler().SortRoutes()
+}
+
+// backendServerNames returns the names of the servers of the backend by their URLs, as in the load-balancer
+func backendServerNames(backend *types.Backend) map[string]string {
+	serverNames := make(map[string]string, len(backend.Servers))
+	for serverName, server := range backend.Servers {
+		if u, err := parseServerURL(server.URL); err == nil {
+			serverNames[u.String()] = serverName
+		}
 	}
-	return serverEntryPoints, nil
+	return serverNames
 }
 
 func configureLBServers(lb healthcheck.LoadBalancer, config *types.Configuration, frontend *types.Frontend) error {
 	for serverName, server := range config.Backends[frontend.Backend].Servers {
-		u, err := url.Parse(server.URL)
+		u, err := parseServerURL(server.URL)
 		if err != nil {
 			log.Errorf("Error parsing server URL %s: %v", server.URL, err)
 			return err
 		}
-		log.Debugf("Creating server %s at %s with weight %d", serverName, u, server.Weight)
+		log.Debugf("Creating server %s at %s with weight %d", serverName, server.URL, server.Weight)
 		if err := lb.UpsertServer(u, roundrobin.Weight(server.Weight)); err != nil {
 			log.Errorf("Error adding server %s to load balancer: %v", server.URL, err)
 			return err
@@ -1032,10 +2515,9 @@ func configureLBServers(lb healthcheck.LoadBalancer, config *types.Configuration
 	return nil
 }
 
-func configureIPWhitelistMiddleware(whitelistSourceRanges []string) (negroni.Handler, error) {
-	if len(whitelistSourceRanges) > 0 {
-		ipSourceRanges := whitelistSourceRanges
-		ipWhitelistMiddleware, err := middlewares.NewIPWhitelister(ipSourceRanges)
+func configureIPWhitelistMiddleware(whitelistSourceRanges []string, blacklistSourceRanges []string, ipStrategy *types.IPStrategy) (negroni.Handler, error) {
+	if len(whitelistSourceRanges) > 0 || len(blacklistSourceRanges) > 0 {
+		ipWhitelistMiddleware, err := middlewares.NewIPFilter(whitelistSourceRanges, blacklistSourceRanges, ipStrategy)
 
 		if err != nil {
 			return nil, err
@@ -1057,7 +2539,17 @@ func (server *Server) wireFrontendBackend(serverRoute *serverRoute, handler http
 		}
 	}
 
-	// add prefix - This needs to always be right before ReplacePath on the chain (second in order in this function)
+	// path replace with regex - This needs to always be right before ReplacePath on the chain
+	if len(serverRoute.replacePathRegex) > 0 {
+		replacePathRegex, err := middlewares.NewReplacePathRegex(handler, serverRoute.replacePathRegex, serverRoute.replacePathReplacement)
+		if err != nil {
+			log.Errorf("Error creating ReplacePathRegex middleware: %v", err)
+		} else {
+			handler = replacePathRegex
+		}
+	}
+
+	// add prefix - This needs to always be right before ReplacePathRegex and ReplacePath on the chain
 	// -- Adding Path Prefix should happen after all *Strip Matcher+Modifiers ran, but before Replace (in case it's configured)
 	if len(serverRoute.addPrefix) > 0 {
 		handler = &middlewares.AddPrefix{
@@ -1113,6 +2605,9 @@ func (server *Server) loadEntryPointConfig(entryPointName string, entryPoint *co
 func (server *Server) buildDefaultHTTPRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	if server.defaultBackend != nil {
+		router.NotFoundHandler = server.defaultBackend
+	}
 	router.StrictSlash(true)
 	router.SkipClean(true)
 	return router
@@ -1136,25 +2631,91 @@ func parseHealthCheckOptions(lb healthcheck.LoadBalancer, backend string, hc *ty
 		}
 	}
 
+	timeout := healthcheck.DefaultTimeout
+	if hc.Timeout != "" {
+		timeoutOverride, err := time.ParseDuration(hc.Timeout)
+		switch {
+		case err != nil:
+			log.Errorf("Illegal healthcheck timeout for backend '%s': %s", backend, err)
+		case timeoutOverride <= 0:
+			log.Errorf("Healthcheck timeout smaller than zero for backend '%s'", backend)
+		default:
+			timeout = timeoutOverride
+		}
+	}
+
 	return &healthcheck.Options{
 		Path:     hc.Path,
 		Port:     hc.Port,
 		Interval: interval,
+		Timeout:  timeout,
+		Status:   hc.Status,
 		LB:       lb,
 	}
 }
 
+func parsePassiveHealthCheck(next http.Handler, backend string, phc *types.PassiveHealthCheck) *healthcheck.PassiveHealthCheck {
+	if phc == nil {
+		return nil
+	}
+
+	var ejectionTime time.Duration
+	if phc.EjectionTime != "" {
+		var err error
+		ejectionTime, err = time.ParseDuration(phc.EjectionTime)
+		if err != nil {
+			log.Errorf("Illegal passive healthcheck ejection time for backend '%s': %s", backend, err)
+		}
+	}
+
+	return healthcheck.NewPassiveHealthCheck(next, healthcheck.PassiveOptions{
+		MaxFailures:  phc.MaxFailures,
+		EjectionTime: ejectionTime,
+	})
+}
+
 func getRoute(serverRoute *serverRoute, route *types.Route) error {
 	rules := Rules{route: serverRoute}
 	newRoute, err := rules.Parse(route.Rule)
 	if err != nil {
 		return err
 	}
-	newRoute.Priority(serverRoute.route.GetPriority() + len(route.Rule))
+	priority := route.Priority
+	if priority <= 0 {
+		priority = len(route.Rule)
+	}
+	newRoute.Priority(serverRoute.route.GetPriority() + priority)
 	serverRoute.route = newRoute
 	return nil
 }
 
+func sortedProviderNames(configurations types.Configurations) []string {
+	keys := []string{}
+	for key := range configurations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedRouteNames(routes map[string]types.Route) []string {
+	keys := []string{}
+	for key := range routes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedBackendNamesForConfig(configuration *types.Configuration) []string {
+	keys := []string{}
+	for key := range configuration.Backends {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func sortedFrontendNamesForConfig(configuration *types.Configuration) []string {
 	keys := []string{}
 	for key := range configuration.Frontends {
@@ -1164,6 +2725,15 @@ func sortedFrontendNamesForConfig(configuration *types.Configuration) []string {
 	return keys
 }
 
+func sortedWeightedBackendNames(weightedBackends map[string]int) []string {
+	keys := []string{}
+	for key := range weightedBackends {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (server *Server) configureFrontends(frontends map[string]*types.Frontend) {
 	for _, frontend := range frontends {
 		// default endpoints if not defined in frontends
@@ -1220,6 +2790,10 @@ func (server *Server) registerMetricClients(metricsConfig *types.Metrics) {
 		registries = append(registries, metrics.RegisterStatsd(metricsConfig.StatsD))
 		log.Debugf("Configured StatsD metrics pushing to %s once every %s", metricsConfig.StatsD.Address, metricsConfig.StatsD.PushInterval)
 	}
+	if metricsConfig.InfluxDB != nil {
+		registries = append(registries, metrics.RegisterInfluxDB(metricsConfig.InfluxDB))
+		log.Debugf("Configured InfluxDB metrics pushing to %s once every %s", metricsConfig.InfluxDB.Address, metricsConfig.InfluxDB.PushInterval)
+	}
 
 	if len(registries) > 0 {
 		server.metricsRegistry = metrics.NewMultiRegistry(registries)
@@ -1229,6 +2803,7 @@ func (server *Server) registerMetricClients(metricsConfig *types.Metrics) {
 func stopMetricsClients() {
 	metrics.StopDatadog()
 	metrics.StopStatsd()
+	metrics.StopInfluxDB()
 }
 
 func (server *Server) buildRateLimiter(handler http.Handler, rlConfig *types.RateLimit) (http.Handler, error) {
@@ -1243,10 +2818,48 @@ func (server *Server) buildRateLimiter(handler http.Handler, rlConfig *types.Rat
 			return nil, err
 		}
 	}
-	return ratelimit.New(handler, extractFunc, rateSet, ratelimit.Logger(oxyLogger))
+	return ratelimit.New(handler, extractFunc, rateSet, ratelimit.Logger(oxyLogger), ratelimit.ErrorHandler(&middlewares.RateLimitErrorHandler{}))
+}
+
+// buildMirrorHandler creates a round robin over the servers of the mirror backend of the frontend, passing the Host
+// header as the frontend does
+func (server *Server) buildMirrorHandler(globalConfiguration configuration.GlobalConfiguration, config *types.Configuration, frontend *types.Frontend) (http.Handler, error) {
+	backendName := frontend.Mirror.Backend
+	backend := config.Backends[backendName]
+	if backend == nil {
+		return nil, fmt.Errorf("undefined mirror backend '%s'", backendName)
+	}
+
+	roundTripper, err := server.getRoundTripper(globalConfiguration, false, nil, backend)
+	if err != nil {
+		return nil, err
+	}
+	fwd, err := forward.New(
+		forward.Logger(oxyLogger),
+		forward.PassHostHeader(passHostHeader(frontend.PassHostHeader, backend)),
+		forward.RoundTripper(roundTripper),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rr, err := roundrobin.New(fwd)
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range backend.Servers {
+		u, err := parseServerURL(server.URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := rr.UpsertServer(u, roundrobin.Weight(server.Weight)); err != nil {
+			return nil, err
+		}
+	}
+	return rr, nil
 }
 
-func (server *Server) buildRetryMiddleware(handler http.Handler, globalConfig configuration.GlobalConfiguration, countServers int, backendName string) http.Handler {
+func (server *Server) buildRetryMiddleware(handler http.Handler, globalConfig configuration.GlobalConfiguration, frontendRetry *types.Retry, countServers int, backendName string) (http.Handler, error) {
 	retryListeners := middlewares.RetryListeners{}
 	if server.metricsRegistry.IsEnabled() {
 		retryListeners = append(retryListeners, middlewares.NewMetricsRetryListener(server.metricsRegistry, backendName))
@@ -1256,11 +2869,25 @@ func (server *Server) buildRetryMiddleware(handler http.Handler, globalConfig co
 	}
 
 	retryAttempts := countServers
-	if globalConfig.Retry.Attempts > 0 {
+	if globalConfig.Retry != nil && globalConfig.Retry.Attempts > 0 {
 		retryAttempts = globalConfig.Retry.Attempts
 	}
 
+	var httpCodeRanges middlewares.HTTPCodeRanges
+	var nonIdempotent bool
+	if frontendRetry != nil {
+		nonIdempotent = frontendRetry.NonIdempotent
+		if frontendRetry.Attempts > 0 {
+			retryAttempts = frontendRetry.Attempts
+		}
+		var err error
+		httpCodeRanges, err = middlewares.NewHTTPCodeRanges(frontendRetry.Status)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	log.Debugf("Creating retries max attempts %d", retryAttempts)
 
-	return middlewares.NewRetry(retryAttempts, handler, retryListeners)
+	return middlewares.NewRetry(retryAttempts, httpCodeRanges, nonIdempotent, handler, retryListeners), nil
 }
@@ -5,13 +5,12 @@ package server
 import (
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/containous/traefik/log"
 )
 
 func (server *Server) configureSignals() {
-	signal.Notify(server.signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
+	signal.Notify(server.signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
 }
 
 func (server *Server) listenSignals() {
@@ -30,13 +29,15 @@ func (server *Server) listenSignals() {
 			if err := log.RotateFile(); err != nil {
 				log.Errorf("Error rotating traefik log: %s", err)
 			}
+		case syscall.SIGUSR2:
+			log.Infof("Toggling the debug log level: %+v", sig)
+			log.Infof("Log level set to %s", log.ToggleDebugLevel())
+		case syscall.SIGHUP:
+			log.Infof("Reloading the global configuration: %+v", sig)
+			server.reloadGlobalConfiguration()
 		default:
 			log.Infof("I have to go... %+v", sig)
-			reqAcceptGraceTimeOut := time.Duration(server.globalConfiguration.LifeCycle.RequestAcceptGraceTimeout)
-			if reqAcceptGraceTimeOut > 0 {
-				log.Infof("Waiting %s for incoming requests to cease", reqAcceptGraceTimeOut)
-				time.Sleep(reqAcceptGraceTimeOut)
-			}
+			server.prepareShutdown()
 			log.Info("Stopping server gracefully")
 			server.Stop()
 		}
@@ -19,6 +19,7 @@ func (server *Server) listenSignals() {
 		switch sig {
 		default:
 			log.Infof("I have to go... %+v", sig)
+			server.prepareShutdown()
 			log.Info("Stopping server")
 			server.Stop()
 		}
@@ -1,11 +1,19 @@
 package server
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,13 +23,18 @@ import (
 	"github.com/containous/traefik/healthcheck"
 	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/middlewares"
+	"github.com/containous/traefik/ping"
+	"github.com/containous/traefik/plugins"
+	"github.com/containous/traefik/provider/web"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/containous/traefik/types"
 	"github.com/davecgh/go-spew/spew"
+	gokitmetrics "github.com/go-kit/kit/metrics"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/negroni"
 	"github.com/vulcand/oxy/roundrobin"
+	"golang.org/x/net/http2"
 )
 
 type testLoadBalancer struct{}
@@ -118,293 +131,254 @@ func TestPrepareServerTimeouts(t *testing.T) {
 	}
 }
 
-func TestServerMultipleFrontendRules(t *testing.T) {
-	cases := []struct {
-		expression  string
-		requestURL  string
-		expectedURL string
+func TestServerTLSCertificateSelection(t *testing.T) {
+	tests := []struct {
+		desc               string
+		defaultCertificate *configuration.Certificate
+		serverName         string
+		wantCommonName     string
 	}{
 		{
-			expression:  "Host:foo.bar",
-			requestURL:  "http://foo.bar",
-			expectedURL: "http://foo.bar",
-		},
-		{
-			expression:  "PathPrefix:/management;ReplacePath:/health",
-			requestURL:  "http://foo.bar/management",
-			expectedURL: "http://foo.bar/health",
-		},
-		{
-			expression:  "Host:foo.bar;AddPrefix:/blah",
-			requestURL:  "http://foo.bar/baz",
-			expectedURL: "http://foo.bar/blah/baz",
+			desc:           "matching SNI",
+			serverName:     "snitest.org",
+			wantCommonName: "snitest.org",
 		},
 		{
-			expression:  "PathPrefixStripRegex:/one/{two}/{three:[0-9]+}",
-			requestURL:  "http://foo.bar/one/some/12345/four",
-			expectedURL: "http://foo.bar/four",
+			desc:           "matching SNI in another case",
+			serverName:     "SNITest.org",
+			wantCommonName: "snitest.org",
 		},
 		{
-			expression:  "PathPrefixStripRegex:/one/{two}/{three:[0-9]+};AddPrefix:/zero",
-			requestURL:  "http://foo.bar/one/some/12345/four",
-			expectedURL: "http://foo.bar/zero/four",
+			desc:           "unknown SNI falls back to the first certificate",
+			serverName:     "unknown.com",
+			wantCommonName: "snitest.com",
 		},
 		{
-			expression:  "AddPrefix:/blah;ReplacePath:/baz",
-			requestURL:  "http://foo.bar/hello",
-			expectedURL: "http://foo.bar/baz",
+			desc: "unknown SNI falls back to the default certificate",
+			defaultCertificate: &configuration.Certificate{
+				CertFile: "../integration/fixtures/https/snitest.org.cert",
+				KeyFile:  "../integration/fixtures/https/snitest.org.key",
+			},
+			serverName:     "unknown.com",
+			wantCommonName: "snitest.org",
 		},
 		{
-			expression:  "PathPrefixStrip:/management;ReplacePath:/health",
-			requestURL:  "http://foo.bar/management",
-			expectedURL: "http://foo.bar/health",
+			desc: "no SNI falls back to the default certificate",
+			defaultCertificate: &configuration.Certificate{
+				CertFile: "../integration/fixtures/https/snitest.org.cert",
+				KeyFile:  "../integration/fixtures/https/snitest.org.key",
+			},
+			wantCommonName: "snitest.org",
 		},
 	}
 
-	for _, test := range cases {
+	for _, test := range tests {
 		test := test
-		t.Run(test.expression, func(t *testing.T) {
-			t.Parallel()
-
-			router := mux.NewRouter()
-			route := router.NewRoute()
-			serverRoute := &serverRoute{route: route}
-			rules := &Rules{route: serverRoute}
 
-			expression := test.expression
-			routeResult, err := rules.Parse(expression)
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
 
-			if err != nil {
-				t.Fatalf("Error while building route for %s: %+v", expression, err)
+			entryPoint := &configuration.EntryPoint{
+				Address: "localhost:0",
+				TLS: &configuration.TLS{
+					Certificates: configuration.Certificates{
+						{
+							CertFile: "../integration/fixtures/https/snitest.com.cert",
+							KeyFile:  "../integration/fixtures/https/snitest.com.key",
+						},
+						{
+							CertFile: "../integration/fixtures/https/snitest.org.cert",
+							KeyFile:  "../integration/fixtures/https/snitest.org.key",
+						},
+					},
+					DefaultCertificate: test.defaultCertificate,
+				},
 			}
-
-			request := testhelpers.MustNewRequest(http.MethodGet, test.requestURL, nil)
-			routeMatch := routeResult.Match(request, &mux.RouteMatch{Route: routeResult})
-
-			if !routeMatch {
-				t.Fatalf("Rule %s doesn't match", expression)
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{"https": entryPoint},
 			}
 
-			server := new(Server)
-
-			server.wireFrontendBackend(serverRoute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if r.URL.String() != test.expectedURL {
-					t.Fatalf("got URL %s, expected %s", r.URL.String(), test.expectedURL)
-				}
-			}))
-			serverRoute.route.GetHandler().ServeHTTP(nil, request)
-		})
-	}
-}
-
-func TestServerLoadConfigHealthCheckOptions(t *testing.T) {
-	healthChecks := []*types.HealthCheck{
-		nil,
-		{
-			Path: "/path",
-		},
-	}
-
-	for _, lbMethod := range []string{"Wrr", "Drr"} {
-		for _, healthCheck := range healthChecks {
-			t.Run(fmt.Sprintf("%s/hc=%t", lbMethod, healthCheck != nil), func(t *testing.T) {
-				globalConfig := configuration.GlobalConfiguration{
-					EntryPoints: configuration.EntryPoints{
-						"http": &configuration.EntryPoint{},
-					},
-					HealthCheck: &configuration.HealthCheckConfig{Interval: flaeg.Duration(5 * time.Second)},
-				}
+			srv := NewServer(globalConfig)
+			srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+			router := srv.serverEntryPoints["https"].httpRouter
 
-				dynamicConfigs := types.Configurations{
-					"config": &types.Configuration{
-						Frontends: map[string]*types.Frontend{
-							"frontend": {
-								EntryPoints: []string{"http"},
-								Backend:     "backend",
-							},
-						},
-						Backends: map[string]*types.Backend{
-							"backend": {
-								Servers: map[string]types.Server{
-									"server": {
-										URL: "http://localhost",
-									},
-								},
-								LoadBalancer: &types.LoadBalancer{
-									Method: lbMethod,
-								},
-								HealthCheck: healthCheck,
-							},
-						},
-					},
-				}
+			tlsConfig, err := srv.createTLSConfig("https", entryPoint.TLS, router)
+			require.NoError(t, err)
+			require.NotNil(t, tlsConfig.GetCertificate)
 
-				srv := NewServer(globalConfig)
-				if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
-					t.Fatalf("got error: %s", err)
-				}
+			cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+			require.NoError(t, err)
+			require.NotNil(t, cert)
 
-				wantNumHealthCheckBackends := 0
-				if healthCheck != nil {
-					wantNumHealthCheckBackends = 1
-				}
-				gotNumHealthCheckBackends := len(healthcheck.GetHealthCheck().Backends)
-				if gotNumHealthCheckBackends != wantNumHealthCheckBackends {
-					t.Errorf("got %d health check backends, want %d", gotNumHealthCheckBackends, wantNumHealthCheckBackends)
-				}
-			})
-		}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCommonName, leaf.Subject.CommonName)
+		})
 	}
 }
 
-func TestServerParseHealthCheckOptions(t *testing.T) {
-	lb := &testLoadBalancer{}
-	globalInterval := 15 * time.Second
-
+func TestServerTLSClientAuth(t *testing.T) {
 	tests := []struct {
-		desc     string
-		hc       *types.HealthCheck
-		wantOpts *healthcheck.Options
+		desc           string
+		clientCAFiles  []string
+		clientAuth     string
+		wantClientAuth tls.ClientAuthType
+		wantErr        bool
 	}{
 		{
-			desc:     "nil health check",
-			hc:       nil,
-			wantOpts: nil,
+			desc:           "no client authentication",
+			wantClientAuth: tls.NoClientCert,
 		},
 		{
-			desc: "empty path",
-			hc: &types.HealthCheck{
-				Path: "",
-			},
-			wantOpts: nil,
+			desc:           "client CA defaults to require and verify",
+			clientCAFiles:  []string{"../integration/fixtures/https/clientca/ca1.crt"},
+			wantClientAuth: tls.RequireAndVerifyClientCert,
 		},
 		{
-			desc: "unparseable interval",
-			hc: &types.HealthCheck{
-				Path:     "/path",
-				Interval: "unparseable",
-			},
-			wantOpts: &healthcheck.Options{
-				Path:     "/path",
-				Interval: globalInterval,
-				LB:       lb,
-			},
+			desc:           "client CA with optional verification",
+			clientCAFiles:  []string{"../integration/fixtures/https/clientca/ca1.crt"},
+			clientAuth:     "VerifyClientCertIfGiven",
+			wantClientAuth: tls.VerifyClientCertIfGiven,
 		},
 		{
-			desc: "sub-zero interval",
-			hc: &types.HealthCheck{
-				Path:     "/path",
-				Interval: "-42s",
-			},
-			wantOpts: &healthcheck.Options{
-				Path:     "/path",
-				Interval: globalInterval,
-				LB:       lb,
-			},
+			desc:           "request client certificate",
+			clientAuth:     "RequestClientCert",
+			wantClientAuth: tls.RequestClientCert,
 		},
 		{
-			desc: "parseable interval",
-			hc: &types.HealthCheck{
-				Path:     "/path",
-				Interval: "5m",
-			},
-			wantOpts: &healthcheck.Options{
-				Path:     "/path",
-				Interval: 5 * time.Minute,
-				LB:       lb,
-			},
+			desc:       "invalid client authentication",
+			clientAuth: "foo",
+			wantErr:    true,
 		},
 	}
 
 	for _, test := range tests {
 		test := test
+
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			gotOpts := parseHealthCheckOptions(lb, "backend", test.hc, &configuration.HealthCheckConfig{Interval: flaeg.Duration(globalInterval)})
-			if !reflect.DeepEqual(gotOpts, test.wantOpts) {
-				t.Errorf("got health check options %+v, want %+v", gotOpts, test.wantOpts)
+			tlsOption := &configuration.TLS{
+				Certificates: configuration.Certificates{
+					{
+						CertFile: "../integration/fixtures/https/snitest.com.cert",
+						KeyFile:  "../integration/fixtures/https/snitest.com.key",
+					},
+				},
+				ClientCAFiles: test.clientCAFiles,
+				ClientAuth:    test.clientAuth,
+			}
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{"https": &configuration.EntryPoint{TLS: tlsOption}},
+			}
+
+			srv := NewServer(globalConfig)
+			tlsConfig, err := srv.createTLSConfig("https", tlsOption, middlewares.NewHandlerSwitcher(mux.NewRouter()))
+			if test.wantErr {
+				require.Error(t, err)
+				return
 			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantClientAuth, tlsConfig.ClientAuth)
 		})
 	}
 }
 
-func TestNewServerWithWhitelistSourceRange(t *testing.T) {
-	cases := []struct {
+func TestServerTLSVersionsAndCurves(t *testing.T) {
+	tests := []struct {
 		desc                 string
-		whitelistStrings     []string
-		middlewareConfigured bool
-		errMessage           string
+		minVersion           string
+		maxVersion           string
+		curvePreferences     []string
+		wantMinVersion       uint16
+		wantMaxVersion       uint16
+		wantCurvePreferences []tls.CurveID
+		wantErr              bool
 	}{
 		{
-			desc:                 "no whitelists configued",
-			whitelistStrings:     nil,
-			middlewareConfigured: false,
-			errMessage:           "",
-		}, {
-			desc: "whitelists configued",
-			whitelistStrings: []string{
-				"1.2.3.4/24",
-				"fe80::/16",
-			},
-			middlewareConfigured: true,
-			errMessage:           "",
-		}, {
-			desc: "invalid whitelists configued",
-			whitelistStrings: []string{
-				"foo",
-			},
-			middlewareConfigured: false,
-			errMessage:           "parsing CIDR whitelist [foo]: parsing CIDR whitelist <nil>: invalid CIDR address: foo",
+			desc: "defaults",
+		},
+		{
+			desc:                 "versions and curves",
+			minVersion:           "VersionTLS11",
+			maxVersion:           "VersionTLS12",
+			curvePreferences:     []string{"X25519", "CurveP256"},
+			wantMinVersion:       tls.VersionTLS11,
+			wantMaxVersion:       tls.VersionTLS12,
+			wantCurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		},
+		{
+			desc:       "invalid maximum version",
+			maxVersion: "VersionSSL30",
+			wantErr:    true,
+		},
+		{
+			desc:             "invalid curve",
+			curvePreferences: []string{"CurveP224"},
+			wantErr:          true,
 		},
 	}
 
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.desc, func(t *testing.T) {
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
-			middleware, err := configureIPWhitelistMiddleware(tc.whitelistStrings)
 
-			if tc.errMessage != "" {
-				require.EqualError(t, err, tc.errMessage)
-			} else {
-				assert.NoError(t, err)
+			tlsOption := &configuration.TLS{
+				Certificates: configuration.Certificates{
+					{
+						CertFile: "../integration/fixtures/https/snitest.com.cert",
+						KeyFile:  "../integration/fixtures/https/snitest.com.key",
+					},
+				},
+				MinVersion:       test.minVersion,
+				MaxVersion:       test.maxVersion,
+				CurvePreferences: test.curvePreferences,
+			}
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{"https": &configuration.EntryPoint{TLS: tlsOption}},
+			}
 
-				if tc.middlewareConfigured {
-					require.NotNil(t, middleware, "not expected middleware to be configured")
-				} else {
-					require.Nil(t, middleware, "expected middleware to be configured")
-				}
+			srv := NewServer(globalConfig)
+			tlsConfig, err := srv.createTLSConfig("https", tlsOption, middlewares.NewHandlerSwitcher(mux.NewRouter()))
+			if test.wantErr {
+				require.Error(t, err)
+				return
 			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantMinVersion, tlsConfig.MinVersion)
+			assert.Equal(t, test.wantMaxVersion, tlsConfig.MaxVersion)
+			assert.Equal(t, test.wantCurvePreferences, tlsConfig.CurvePreferences)
 		})
 	}
 }
 
-func TestServerLoadConfigEmptyBasicAuth(t *testing.T) {
+func TestServerLoadConfigDynamicCertificates(t *testing.T) {
 	globalConfig := configuration.GlobalConfiguration{
 		EntryPoints: configuration.EntryPoints{
 			"http": &configuration.EntryPoint{},
+			"https": &configuration.EntryPoint{
+				TLS: &configuration.TLS{},
+			},
 		},
+		DefaultEntryPoints: []string{"http", "https"},
 	}
 
 	dynamicConfigs := types.Configurations{
 		"config": &types.Configuration{
-			Frontends: map[string]*types.Frontend{
-				"frontend": {
-					EntryPoints: []string{"http"},
-					Backend:     "backend",
-					BasicAuth:   []string{""},
-				},
-			},
-			Backends: map[string]*types.Backend{
-				"backend": {
-					Servers: map[string]types.Server{
-						"server": {
-							URL: "http://localhost",
-						},
+			TLSConfiguration: []*types.TLSConfiguration{
+				{
+					Certificate: &types.TLSCertificate{
+						CertFile: "../integration/fixtures/https/snitest.org.cert",
+						KeyFile:  "../integration/fixtures/https/snitest.org.key",
 					},
-					LoadBalancer: &types.LoadBalancer{
-						Method: "Wrr",
+				},
+				{
+					EntryPoints: []string{"https"},
+					Certificate: &types.TLSCertificate{
+						CertFile: "../integration/fixtures/https/snitest.com.cert",
+						KeyFile:  "unknown.key",
 					},
 				},
 			},
@@ -412,217 +386,2303 @@ func TestServerLoadConfigEmptyBasicAuth(t *testing.T) {
 	}
 
 	srv := NewServer(globalConfig)
-	if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
-		t.Fatalf("got error: %s", err)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	assert.Nil(t, entryPoints["http"].dynamicCerts.Get())
+
+	dynamicCerts, ok := entryPoints["https"].dynamicCerts.Get().(map[string]*tls.Certificate)
+	require.True(t, ok)
+	assert.Len(t, dynamicCerts, 1)
+	assert.Contains(t, dynamicCerts, "snitest.org")
+}
+
+func TestServerLoadConfigKeepsUnchangedHandlers(t *testing.T) {
+	newBackendServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+	server1 := newBackendServer("server1")
+	defer server1.Close()
+	server2 := newBackendServer("server2")
+	defer server2.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	// the providers send new configurations, equal or not to the previous ones
+	newDynamicConfigs := func(foo2URL string) types.Configurations {
+		return types.Configurations{
+			"config": &types.Configuration{
+				Frontends: map[string]*types.Frontend{
+					"foo": {
+						EntryPoints: []string{"http"},
+						Backend:     "foo",
+						Routes:      map[string]types.Route{"route": {Rule: "Host:foo.localhost"}},
+					},
+					"bar": {
+						EntryPoints: []string{"http"},
+						Backend:     "bar",
+						Routes:      map[string]types.Route{"route": {Rule: "Host:bar.localhost"}},
+					},
+				},
+				Backends: map[string]*types.Backend{
+					"foo": {
+						Servers:      map[string]types.Server{"foo1": {URL: server1.URL, Weight: 1}, "foo2": {URL: foo2URL, Weight: 1}},
+						LoadBalancer: &types.LoadBalancer{Method: "wrr"},
+					},
+					"bar": {
+						Servers:      map[string]types.Server{"bar1": {URL: server1.URL, Weight: 1}},
+						LoadBalancer: &types.LoadBalancer{Method: "wrr"},
+					},
+				},
+			},
+		}
 	}
+
+	srv := NewServer(globalConfig)
+	_, err := srv.loadConfig(newDynamicConfigs(server1.URL), globalConfig)
+	require.NoError(t, err)
+	fooBackend := srv.loadedBackends.handlers["config/http/foo"]
+	fooBalancer := srv.loadedBackends.balancers["config/http/foo"]
+	fooFrontend := srv.loadedFrontends.handlers["config/http/foo"]
+	barBackend := srv.loadedBackends.handlers["config/http/bar"]
+	barBalancer := srv.loadedBackends.balancers["config/http/bar"]
+	barFrontend := srv.loadedFrontends.handlers["config/http/bar"]
+	require.NotNil(t, fooBackend)
+	require.NotNil(t, barBackend)
+
+	entryPoints, err := srv.loadConfig(newDynamicConfigs(server2.URL), globalConfig)
+	require.NoError(t, err)
+
+	assert.True(t, barBackend == srv.loadedBackends.handlers["config/http/bar"], "unchanged backend rebuilt")
+	assert.True(t, barBalancer == srv.loadedBackends.balancers["config/http/bar"], "load-balancer of an unchanged backend rebuilt")
+	assert.True(t, barFrontend == srv.loadedFrontends.handlers["config/http/bar"], "unchanged frontend rebuilt")
+	assert.False(t, fooBackend == srv.loadedBackends.handlers["config/http/foo"], "changed backend kept")
+	assert.False(t, fooFrontend == srv.loadedFrontends.handlers["config/http/foo"], "frontend of a changed backend kept")
+	assert.False(t, fooBalancer == srv.loadedBackends.balancers["config/http/foo"], "load-balancer of a changed backend kept")
+
+	// the kept frontend is routed by the new router
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://bar.localhost", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "server1", recorder.Body.String())
 }
 
-func TestConfigureBackends(t *testing.T) {
-	validMethod := "Drr"
-	defaultMethod := "wrr"
+// newBenchmarkConfigurations returns a configuration of frontends routing the requests of their host to their own backend
+func newBenchmarkConfigurations(count int, serverURL string, forwardingTimeouts *types.ForwardingTimeouts) types.Configurations {
+	config := &types.Configuration{
+		Frontends: make(map[string]*types.Frontend, count),
+		Backends:  make(map[string]*types.Backend, count),
+	}
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("frontend%d", i)
+		config.Frontends[name] = &types.Frontend{
+			EntryPoints: []string{"http"},
+			Backend:     name,
+			Routes:      map[string]types.Route{"route": {Rule: "Host:" + name + ".localhost"}},
+		}
+		config.Backends[name] = &types.Backend{
+			Servers:            map[string]types.Server{"server1": {URL: serverURL, Weight: 1}},
+			LoadBalancer:       &types.LoadBalancer{Method: "wrr"},
+			ForwardingTimeouts: forwardingTimeouts,
+		}
+	}
+	return types.Configurations{"config": config}
+}
 
-	tests := []struct {
-		desc           string
-		lb             *types.LoadBalancer
-		wantMethod     string
-		wantStickiness *types.Stickiness
+func BenchmarkServerLoadConfig10kFrontends(b *testing.B) {
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{"http": &configuration.EntryPoint{}},
+	}
+
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			srv := NewServer(globalConfig)
+			dynamicConfigs := newBenchmarkConfigurations(10000, "http://127.0.0.1:80", nil)
+			b.StartTimer()
+			if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// the provider sends its configuration again, with a single frontend changed
+	b.Run("one frontend changed", func(b *testing.B) {
+		srv := NewServer(globalConfig)
+		if _, err := srv.loadConfig(newBenchmarkConfigurations(10000, "http://127.0.0.1:80", nil), globalConfig); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			dynamicConfigs := newBenchmarkConfigurations(10000, "http://127.0.0.1:80", nil)
+			dynamicConfigs["config"].Backends["frontend0"].Servers["server1"] = types.Server{URL: fmt.Sprintf("http://127.0.0.1:%d", 1000+i), Weight: 1}
+			b.StartTimer()
+			if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// another provider sends its configuration, the one of the 10k frontends is unchanged
+	b.Run("other provider changed", func(b *testing.B) {
+		srv := NewServer(globalConfig)
+		dynamicConfigs := newBenchmarkConfigurations(10000, "http://127.0.0.1:80", nil)
+		if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			dynamicConfigs["other"] = newBenchmarkConfigurations(1, fmt.Sprintf("http://127.0.0.1:%d", 1000+i), nil)["config"]
+			if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkServerServeHTTP10kFrontends(b *testing.B) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+	defer testServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints:         configuration.EntryPoints{"http": &configuration.EntryPoint{}},
+		MaxIdleConnsPerHost: 200,
+	}
+
+	testCases := []struct {
+		desc               string
+		forwardingTimeouts *types.ForwardingTimeouts
 	}{
 		{
-			desc: "valid load balancer method with sticky enabled",
-			lb: &types.LoadBalancer{
-				Method:     validMethod,
-				Stickiness: &types.Stickiness{},
-			},
-			wantMethod:     validMethod,
-			wantStickiness: &types.Stickiness{},
+			desc: "default transport",
 		},
 		{
-			desc: "valid load balancer method with sticky disabled",
-			lb: &types.LoadBalancer{
-				Method:     validMethod,
-				Stickiness: nil,
+			desc:               "pooled transport",
+			forwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "5s"},
+		},
+	}
+
+	for _, test := range testCases {
+		b.Run(test.desc, func(b *testing.B) {
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(newBenchmarkConfigurations(10000, testServer.URL, test.forwardingTimeouts), globalConfig)
+			if err != nil {
+				b.Fatal(err)
+			}
+			router := entryPoints["http"].httpRouter
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					request := testhelpers.MustNewRequest(http.MethodGet, fmt.Sprintf("http://frontend%d.localhost", i%10000), nil)
+					recorder := httptest.NewRecorder()
+					router.ServeHTTP(recorder, request)
+					if recorder.Code != http.StatusOK {
+						b.Fatalf("unexpected status code %d", recorder.Code)
+					}
+					i += 7919
+				}
+			})
+		})
+	}
+}
+
+func TestServerLoadConfigTCPFrontends(t *testing.T) {
+	// TCP server signaling the connections it accepts
+	startTCPServer := func(t *testing.T) (string, chan struct{}) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		accepted := make(chan struct{}, 1)
+		go func() {
+			defer listener.Close()
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+			accepted <- struct{}{}
+		}()
+		return listener.Addr().String(), accepted
+	}
+	catchAllAddress, catchAllAccepted := startTCPServer(t)
+	sniAddress, sniAccepted := startTCPServer(t)
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+			"tcp":  &configuration.EntryPoint{Protocol: configuration.EntryPointProtocolTCP},
+			"tcp2": &configuration.EntryPoint{Protocol: configuration.EntryPointProtocolTCP},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": &types.Configuration{
+			TCPBackends: map[string]*types.TCPBackend{
+				"catch-all": {Servers: map[string]types.TCPServer{"server1": {Address: catchAllAddress}}},
+				"sni":       {Servers: map[string]types.TCPServer{"server1": {Address: sniAddress}}},
+			},
+			TCPFrontends: map[string]*types.TCPFrontend{
+				"catch-all": {
+					EntryPoints: []string{"tcp"},
+					Backend:     "catch-all",
+				},
+				"sni-passthrough": {
+					EntryPoints: []string{"tcp"},
+					Backend:     "sni",
+					SNI:         []string{"db.localhost"},
+					Passthrough: true,
+				},
+				"sni-taken": {
+					EntryPoints: []string{"tcp2", "tcp"},
+					Backend:     "sni",
+					SNI:         []string{"db.localhost"},
+					Passthrough: true,
+				},
+				"sni-without-tls": {
+					EntryPoints: []string{"tcp"},
+					Backend:     "sni",
+					SNI:         []string{"other.localhost"},
+				},
+				"http-entrypoint": {
+					EntryPoints: []string{"http"},
+					Backend:     "sni",
+				},
+				"undefined-backend": {
+					EntryPoints: []string{"tcp"},
+					Backend:     "unknown",
+				},
 			},
-			wantMethod: validMethod,
 		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	assert.Nil(t, entryPoints["http"].tcpRouter)
+	require.NotNil(t, entryPoints["tcp"].tcpRouter)
+	// the frontend skipped on an entry point is not routed on the others
+	require.NotNil(t, entryPoints["tcp2"].tcpRouter)
+	assert.NoError(t, entryPoints["tcp2"].tcpRouter.GetHandler().CheckRoute([]string{"db.localhost"}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go entryPoints["tcp"].tcpRouter.ServeTCP(conn)
+		}
+	}()
+
+	testCases := []struct {
+		desc       string
+		serverName string
+		accepted   chan struct{}
+	}{
 		{
-			desc: "invalid load balancer method with sticky enabled",
-			lb: &types.LoadBalancer{
-				Method:     "Invalid",
-				Stickiness: &types.Stickiness{},
+			desc:       "SNI route",
+			serverName: "db.localhost",
+			accepted:   sniAccepted,
+		},
+		{
+			desc:       "SNI route skipped without TLS on the entrypoint",
+			serverName: "other.localhost",
+			accepted:   catchAllAccepted,
+		},
+	}
+
+	for _, test := range testCases {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		tls.Client(conn, &tls.Config{ServerName: test.serverName, InsecureSkipVerify: true}).Handshake()
+		conn.Close()
+
+		select {
+		case <-test.accepted:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: connection not forwarded to the expected TCP server", test.desc)
+		}
+	}
+}
+
+func TestServerLoadConfigUDPFrontends(t *testing.T) {
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+			"dns":  &configuration.EntryPoint{Protocol: configuration.EntryPointProtocolUDP},
+			"dns2": &configuration.EntryPoint{Protocol: configuration.EntryPointProtocolUDP},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": &types.Configuration{
+			UDPBackends: map[string]*types.UDPBackend{
+				"dns": {
+					Servers: map[string]types.UDPServer{
+						"server1": {Address: "10.0.0.1:53"},
+						"server2": {Address: "10.0.0.2:53"},
+					},
+				},
+				"other": {
+					Servers: map[string]types.UDPServer{
+						"server1": {Address: "10.0.0.3:53"},
+					},
+				},
 			},
-			wantMethod:     defaultMethod,
-			wantStickiness: &types.Stickiness{},
+			UDPFrontends: map[string]*types.UDPFrontend{
+				"dns": {
+					EntryPoints: []string{"dns"},
+					Backend:     "dns",
+				},
+				"dns-duplicate": {
+					EntryPoints: []string{"dns"},
+					Backend:     "other",
+				},
+				"dns-partial": {
+					EntryPoints: []string{"dns2", "dns"},
+					Backend:     "other",
+				},
+				"http-entrypoint": {
+					EntryPoints: []string{"http"},
+					Backend:     "other",
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	assert.Nil(t, entryPoints["http"].udpLoadBalancer)
+	require.NotNil(t, entryPoints["dns"].udpLoadBalancer)
+	assert.Equal(t, []string{"10.0.0.1:53", "10.0.0.2:53"}, entryPoints["dns"].udpLoadBalancer.GetLoadBalancer().Servers())
+	// the frontend skipped on an entry point is not bound to the others
+	require.NotNil(t, entryPoints["dns2"].udpLoadBalancer)
+	assert.Empty(t, entryPoints["dns2"].udpLoadBalancer.GetLoadBalancer().Servers())
+}
+
+func TestServerMultipleFrontendRules(t *testing.T) {
+	cases := []struct {
+		expression  string
+		requestURL  string
+		expectedURL string
+	}{
+		{
+			expression:  "Host:foo.bar",
+			requestURL:  "http://foo.bar",
+			expectedURL: "http://foo.bar",
+		},
+		{
+			expression:  "PathPrefix:/management;ReplacePath:/health",
+			requestURL:  "http://foo.bar/management",
+			expectedURL: "http://foo.bar/health",
+		},
+		{
+			expression:  "Host:foo.bar;AddPrefix:/blah",
+			requestURL:  "http://foo.bar/baz",
+			expectedURL: "http://foo.bar/blah/baz",
+		},
+		{
+			expression:  "PathPrefixStripRegex:/one/{two}/{three:[0-9]+}",
+			requestURL:  "http://foo.bar/one/some/12345/four",
+			expectedURL: "http://foo.bar/four",
+		},
+		{
+			expression:  "PathPrefixStripRegex:/one/{two}/{three:[0-9]+};AddPrefix:/zero",
+			requestURL:  "http://foo.bar/one/some/12345/four",
+			expectedURL: "http://foo.bar/zero/four",
+		},
+		{
+			expression:  "AddPrefix:/blah;ReplacePath:/baz",
+			requestURL:  "http://foo.bar/hello",
+			expectedURL: "http://foo.bar/baz",
+		},
+		{
+			expression:  "PathPrefixStrip:/management;ReplacePath:/health",
+			requestURL:  "http://foo.bar/management",
+			expectedURL: "http://foo.bar/health",
+		},
+		{
+			expression:  "PathPrefix:/api;ReplacePathRegex: ^/api/v1/(.*) /v2/$1",
+			requestURL:  "http://foo.bar/api/v1/users",
+			expectedURL: "http://foo.bar/v2/users",
+		},
+		{
+			expression:  "PathPrefixStrip:/api;ReplacePathRegex: ^/v([0-9]{1,3})/(.*) /$2/version/$1",
+			requestURL:  "http://foo.bar/api/v10/users",
+			expectedURL: "http://foo.bar/users/version/10",
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			router := mux.NewRouter()
+			route := router.NewRoute()
+			serverRoute := &serverRoute{route: route}
+			rules := &Rules{route: serverRoute}
+
+			expression := test.expression
+			routeResult, err := rules.Parse(expression)
+
+			if err != nil {
+				t.Fatalf("Error while building route for %s: %+v", expression, err)
+			}
+
+			request := testhelpers.MustNewRequest(http.MethodGet, test.requestURL, nil)
+			routeMatch := routeResult.Match(request, &mux.RouteMatch{Route: routeResult})
+
+			if !routeMatch {
+				t.Fatalf("Rule %s doesn't match", expression)
+			}
+
+			server := new(Server)
+
+			server.wireFrontendBackend(serverRoute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.String() != test.expectedURL {
+					t.Fatalf("got URL %s, expected %s", r.URL.String(), test.expectedURL)
+				}
+			}))
+			serverRoute.route.GetHandler().ServeHTTP(nil, request)
+		})
+	}
+}
+
+func TestServerLoadConfigHealthCheckOptions(t *testing.T) {
+	healthChecks := []*types.HealthCheck{
+		nil,
+		{
+			Path: "/path",
+		},
+	}
+
+	for _, lbMethod := range []string{"Wrr", "Drr"} {
+		for _, healthCheck := range healthChecks {
+			t.Run(fmt.Sprintf("%s/hc=%t", lbMethod, healthCheck != nil), func(t *testing.T) {
+				globalConfig := configuration.GlobalConfiguration{
+					EntryPoints: configuration.EntryPoints{
+						"http": &configuration.EntryPoint{},
+					},
+					HealthCheck: &configuration.HealthCheckConfig{Interval: flaeg.Duration(5 * time.Second)},
+				}
+
+				dynamicConfigs := types.Configurations{
+					"config": &types.Configuration{
+						Frontends: map[string]*types.Frontend{
+							"frontend": {
+								EntryPoints: []string{"http"},
+								Backend:     "backend",
+							},
+						},
+						Backends: map[string]*types.Backend{
+							"backend": {
+								Servers: map[string]types.Server{
+									"server": {
+										URL: "http://localhost",
+									},
+								},
+								LoadBalancer: &types.LoadBalancer{
+									Method: lbMethod,
+								},
+								HealthCheck: healthCheck,
+							},
+						},
+					},
+				}
+
+				srv := NewServer(globalConfig)
+				if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
+					t.Fatalf("got error: %s", err)
+				}
+
+				wantNumHealthCheckBackends := 0
+				if healthCheck != nil {
+					wantNumHealthCheckBackends = 1
+				}
+				gotNumHealthCheckBackends := len(healthcheck.GetHealthCheck().Backends)
+				if gotNumHealthCheckBackends != wantNumHealthCheckBackends {
+					t.Errorf("got %d health check backends, want %d", gotNumHealthCheckBackends, wantNumHealthCheckBackends)
+				}
+			})
+		}
+	}
+}
+
+func TestServerParseHealthCheckOptions(t *testing.T) {
+	lb := &testLoadBalancer{}
+	globalInterval := 15 * time.Second
+
+	tests := []struct {
+		desc     string
+		hc       *types.HealthCheck
+		wantOpts *healthcheck.Options
+	}{
+		{
+			desc:     "nil health check",
+			hc:       nil,
+			wantOpts: nil,
+		},
+		{
+			desc: "empty path",
+			hc: &types.HealthCheck{
+				Path: "",
+			},
+			wantOpts: nil,
+		},
+		{
+			desc: "unparseable interval",
+			hc: &types.HealthCheck{
+				Path:     "/path",
+				Interval: "unparseable",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				Timeout:  healthcheck.DefaultTimeout,
+				LB:       lb,
+			},
+		},
+		{
+			desc: "sub-zero interval",
+			hc: &types.HealthCheck{
+				Path:     "/path",
+				Interval: "-42s",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				Timeout:  healthcheck.DefaultTimeout,
+				LB:       lb,
+			},
+		},
+		{
+			desc: "parseable interval",
+			hc: &types.HealthCheck{
+				Path:     "/path",
+				Interval: "5m",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: 5 * time.Minute,
+				Timeout:  healthcheck.DefaultTimeout,
+				LB:       lb,
+			},
+		},
+		{
+			desc: "unparseable timeout",
+			hc: &types.HealthCheck{
+				Path:    "/path",
+				Timeout: "unparseable",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				Timeout:  healthcheck.DefaultTimeout,
+				LB:       lb,
+			},
+		},
+		{
+			desc: "parseable timeout and expected status",
+			hc: &types.HealthCheck{
+				Path:    "/path",
+				Timeout: "2s",
+				Status:  http.StatusNoContent,
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				Timeout:  2 * time.Second,
+				Status:   http.StatusNoContent,
+				LB:       lb,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			gotOpts := parseHealthCheckOptions(lb, "backend", test.hc, &configuration.HealthCheckConfig{Interval: flaeg.Duration(globalInterval)})
+			if !reflect.DeepEqual(gotOpts, test.wantOpts) {
+				t.Errorf("got health check options %+v, want %+v", gotOpts, test.wantOpts)
+			}
+		})
+	}
+}
+
+func TestNewServerWithWhitelistSourceRange(t *testing.T) {
+	cases := []struct {
+		desc                 string
+		whitelistStrings     []string
+		middlewareConfigured bool
+		errMessage           string
+	}{
+		{
+			desc:                 "no whitelists configued",
+			whitelistStrings:     nil,
+			middlewareConfigured: false,
+			errMessage:           "",
+		}, {
+			desc: "whitelists configued",
+			whitelistStrings: []string{
+				"1.2.3.4/24",
+				"fe80::/16",
+			},
+			middlewareConfigured: true,
+			errMessage:           "",
+		}, {
+			desc: "invalid whitelists configued",
+			whitelistStrings: []string{
+				"foo",
+			},
+			middlewareConfigured: false,
+			errMessage:           "parsing CIDR whitelist [foo]: parsing CIDR whitelist <nil>: invalid CIDR address: foo",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			middleware, err := configureIPWhitelistMiddleware(tc.whitelistStrings, nil, nil)
+
+			if tc.errMessage != "" {
+				require.EqualError(t, err, tc.errMessage)
+			} else {
+				assert.NoError(t, err)
+
+				if tc.middlewareConfigured {
+					require.NotNil(t, middleware, "not expected middleware to be configured")
+				} else {
+					require.Nil(t, middleware, "expected middleware to be configured")
+				}
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigEmptyBasicAuth(t *testing.T) {
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+
+	dynamicConfigs := types.Configurations{
+		"config": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend": {
+					EntryPoints: []string{"http"},
+					Backend:     "backend",
+					BasicAuth:   []string{""},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend": {
+					Servers: map[string]types.Server{
+						"server": {
+							URL: "http://localhost",
+						},
+					},
+					LoadBalancer: &types.LoadBalancer{
+						Method: "Wrr",
+					},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	if _, err := srv.loadConfig(dynamicConfigs, globalConfig); err != nil {
+		t.Fatalf("got error: %s", err)
+	}
+}
+
+func TestServerLoadConfigSkipsInvalidFrontends(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		desc     string
+		frontend func(*types.Frontend)
+		backend  func(*types.Backend)
+	}{
+		{
+			desc: "invalid whitelist",
+			frontend: func(frontend *types.Frontend) {
+				frontend.WhitelistSourceRange = []string{"foo"}
+			},
+		},
+		{
+			desc: "negative connlimit amount",
+			backend: func(backend *types.Backend) {
+				backend.MaxConn = &types.MaxConn{Amount: -1, ExtractorFunc: "client.ip"}
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+			}
+			invalidFrontend := buildFrontend(withRoute("route", "Host:invalid.test"), withBackendName("invalid"))
+			invalidBackend := buildBackend(withServer("server", backend.URL))
+			if test.frontend != nil {
+				test.frontend(invalidFrontend)
+			}
+			if test.backend != nil {
+				test.backend(invalidBackend)
+			}
+			dynamicConfigs := types.Configurations{
+				"config": buildDynamicConfig(
+					withFrontend("invalid", invalidFrontend),
+					withFrontend("valid", buildFrontend(withRoute("route", "Host:valid.test"), withBackendName("valid"))),
+					withBackend("invalid", invalidBackend),
+					withBackend("valid", buildBackend(withServer("server", backend.URL))),
+				),
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			require.NoError(t, err)
+
+			for host, expectedStatusCode := range map[string]int{"invalid.test": http.StatusNotFound, "valid.test": http.StatusOK} {
+				recorder := httptest.NewRecorder()
+				entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil))
+				assert.Equal(t, expectedStatusCode, recorder.Code, host)
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigDisabledConnLimit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	limitedBackend := buildBackend(withServer("server", backend.URL))
+	// the KV providers template emits a zero amount when the maxconn is not set
+	limitedBackend.MaxConn = &types.MaxConn{ExtractorFunc: "client.ip"}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "Path:/"))),
+			withBackend("backend", limitedBackend),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+}
+
+func TestServerBasicAuthUsersFile(t *testing.T) {
+	usersFile, err := ioutil.TempFile("", "auth-users")
+	require.NoError(t, err)
+	defer os.Remove(usersFile.Name())
+	_, err = usersFile.Write([]byte("test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/\n"))
+	require.NoError(t, err)
+	require.NoError(t, usersFile.Close())
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Header.Get("X-Webauth-User")))
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc               string
+		usersFile          string
+		user               string
+		password           string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			desc:               "authenticated",
+			usersFile:          usersFile.Name(),
+			user:               "test",
+			password:           "test",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "test",
+		},
+		{
+			desc:               "wrong password",
+			usersFile:          usersFile.Name(),
+			user:               "test",
+			password:           "foo",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:               "missing users file",
+			usersFile:          usersFile.Name() + ".missing",
+			user:               "test",
+			password:           "test",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+			}
+			frontend := buildFrontend(withRoute("route", "Path:/"))
+			frontend.BasicAuthUsersFile = test.usersFile
+			frontend.BasicAuthHeaderField = "X-Webauth-User"
+			dynamicConfigs := types.Configurations{
+				"config": buildDynamicConfig(
+					withFrontend("frontend", frontend),
+					withBackend("backend", buildBackend(withServer("server", testServer.URL))),
+				),
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.SetBasicAuth(test.user, test.password)
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerFrontendAuth(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc                    string
+		auth                    *types.Auth
+		expectedStatusCode      int
+		expectedWWWAuthenticate string
+	}{
+		{
+			desc: "digest",
+			auth: &types.Auth{
+				Digest: &types.Digest{Users: []string{"test:traefik:a2688e031edb4be6a3797f3882655c05"}},
+			},
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedWWWAuthenticate: "Digest",
+		},
+		{
+			desc: "basic",
+			auth: &types.Auth{
+				Basic: &types.Basic{Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}},
+			},
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedWWWAuthenticate: "Basic",
+		},
+		{
+			desc:               "no authentication",
+			auth:               &types.Auth{},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+			}
+			frontend := buildFrontend(withRoute("route", "Path:/"))
+			frontend.Auth = test.auth
+			dynamicConfigs := types.Configurations{
+				"config": buildDynamicConfig(
+					withFrontend("frontend", frontend),
+					withBackend("backend", buildBackend(withServer("server", testServer.URL))),
+				),
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.True(t, strings.HasPrefix(recorder.Header().Get("WWW-Authenticate"), test.expectedWWWAuthenticate))
+		})
+	}
+}
+
+func TestConfigureBackends(t *testing.T) {
+	validMethod := "Drr"
+	defaultMethod := "wrr"
+
+	tests := []struct {
+		desc           string
+		lb             *types.LoadBalancer
+		wantMethod     string
+		wantStickiness *types.Stickiness
+	}{
+		{
+			desc: "valid load balancer method with sticky enabled",
+			lb: &types.LoadBalancer{
+				Method:     validMethod,
+				Stickiness: &types.Stickiness{},
+			},
+			wantMethod:     validMethod,
+			wantStickiness: &types.Stickiness{},
+		},
+		{
+			desc: "valid load balancer method with sticky disabled",
+			lb: &types.LoadBalancer{
+				Method:     validMethod,
+				Stickiness: nil,
+			},
+			wantMethod: validMethod,
+		},
+		{
+			desc: "invalid load balancer method with sticky enabled",
+			lb: &types.LoadBalancer{
+				Method:     "Invalid",
+				Stickiness: &types.Stickiness{},
+			},
+			wantMethod:     defaultMethod,
+			wantStickiness: &types.Stickiness{},
+		},
+		{
+			desc: "invalid load balancer method with sticky disabled",
+			lb: &types.LoadBalancer{
+				Method:     "Invalid",
+				Stickiness: nil,
+			},
+			wantMethod: defaultMethod,
+		},
+		{
+			desc:       "missing load balancer",
+			lb:         nil,
+			wantMethod: defaultMethod,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			backend := &types.Backend{
+				LoadBalancer: test.lb,
+			}
+
+			srv := Server{}
+			srv.configureBackends(map[string]*types.Backend{
+				"backend": backend,
+			})
+
+			wantLB := types.LoadBalancer{
+				Method:     test.wantMethod,
+				Stickiness: test.wantStickiness,
+			}
+			if !reflect.DeepEqual(*backend.LoadBalancer, wantLB) {
+				t.Errorf("got backend load-balancer\n%v\nwant\n%v\n", spew.Sdump(backend.LoadBalancer), spew.Sdump(wantLB))
+			}
+		})
+	}
+}
+
+func TestServerEntrypointWhitelistConfig(t *testing.T) {
+	tests := []struct {
+		desc           string
+		entrypoint     *configuration.EntryPoint
+		wantMiddleware bool
+	}{
+		{
+			desc: "no whitelist middleware if no config on entrypoint",
+			entrypoint: &configuration.EntryPoint{
+				Address: ":0",
+			},
+			wantMiddleware: false,
+		},
+		{
+			desc: "whitelist middleware should be added if configured on entrypoint",
+			entrypoint: &configuration.EntryPoint{
+				Address: ":0",
+				WhitelistSourceRange: []string{
+					"127.0.0.1/32",
+				},
+			},
+			wantMiddleware: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := Server{
+				globalConfiguration: configuration.GlobalConfiguration{
+					EntryPoints: map[string]*configuration.EntryPoint{
+						"test": test.entrypoint,
+					},
+				},
+				metricsRegistry: metrics.NewVoidRegistry(),
+			}
+
+			srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
+			srvEntryPoint, err := srv.setupServerEntryPoint("test", srv.serverEntryPoints["test"])
+			require.NoError(t, err)
+			handler := srvEntryPoint.httpServer.Handler.(*negroni.Negroni)
+			found := false
+			for _, handler := range handler.Handlers() {
+				if reflect.TypeOf(handler) == reflect.TypeOf((*middlewares.IPWhiteLister)(nil)) {
+					found = true
+				}
+			}
+
+			if found && !test.wantMiddleware {
+				t.Errorf("ip whitelist middleware was installed even though it should not")
+			}
+
+			if !found && test.wantMiddleware {
+				t.Errorf("ip whitelist middleware was not installed even though it should have")
+			}
+		})
+	}
+}
+
+func TestServerResponseEmptyBackend(t *testing.T) {
+	const requestPath = "/path"
+	const routeRule = "Path:" + requestPath
+
+	testCases := []struct {
+		desc           string
+		dynamicConfig  func(testServerURL string) *types.Configuration
+		wantStatusCode int
+	}{
+		{
+			desc: "Ok",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withServer("testServer", testServerURL))),
+				)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc: "No Frontend",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig()
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			desc: "Empty Backend LB-Drr",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Drr", false))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc: "Empty Backend LB-Drr Sticky",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Drr", true))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc: "Empty Backend LB-Wrr",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Wrr", false))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc: "Empty Backend LB-Wrr Sticky",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Wrr", true))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			defer testServer.Close()
+
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+			}
+			dynamicConfigs := types.Configurations{"config": test.dynamicConfig(testServer.URL)}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			if err != nil {
+				t.Fatalf("error loading config: %s", err)
+			}
+
+			responseRecorder := &httptest.ResponseRecorder{}
+			request := httptest.NewRequest(http.MethodGet, testServer.URL+requestPath, nil)
+
+			entryPoints["http"].httpRouter.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Result().StatusCode != test.wantStatusCode {
+				t.Errorf("got status code %d, want %d", responseRecorder.Result().StatusCode, test.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestCreateHTTPTransport(t *testing.T) {
+	testCases := []struct {
+		desc                       string
+		forwardingTransport        *configuration.ForwardingTransport
+		expectedMaxIdleConns       int
+		expectedDisableKeepAlives  bool
+		expectedDisableCompression bool
+	}{
+		{
+			desc: "default transport",
+		},
+		{
+			desc: "tuned transport",
+			forwardingTransport: &configuration.ForwardingTransport{
+				MaxIdleConns:       500,
+				DisableKeepAlives:  true,
+				DisableCompression: true,
+				ReadBufferSize:     65536,
+				WriteBufferSize:    65536,
+			},
+			expectedMaxIdleConns:       500,
+			expectedDisableKeepAlives:  true,
+			expectedDisableCompression: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			globalConfig := configuration.GlobalConfiguration{MaxIdleConnsPerHost: 200, ForwardingTransport: test.forwardingTransport}
+			transport := createHTTPTransport(globalConfig, nil)
+
+			assert.Equal(t, 200, transport.MaxIdleConnsPerHost)
+			assert.Equal(t, test.expectedMaxIdleConns, transport.MaxIdleConns)
+			assert.Equal(t, test.expectedDisableKeepAlives, transport.DisableKeepAlives)
+			assert.Equal(t, test.expectedDisableCompression, transport.DisableCompression)
+
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			response, err := (&http.Client{Transport: transport}).Get(server.URL)
+			require.NoError(t, err)
+			defer response.Body.Close()
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		})
+	}
+}
+
+func TestGetRoundTripperSharesTransports(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		backend1 *types.Backend
+		backend2 *types.Backend
+		shared   bool
+	}{
+		{
+			desc:     "default transport",
+			backend1: &types.Backend{},
+			backend2: &types.Backend{},
+			shared:   true,
+		},
+		{
+			desc:     "same forwarding timeouts",
+			backend1: &types.Backend{ForwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "5s"}},
+			backend2: &types.Backend{ForwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "5s"}},
+			shared:   true,
+		},
+		{
+			desc:     "different forwarding timeouts",
+			backend1: &types.Backend{ForwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "5s"}},
+			backend2: &types.Backend{ForwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "10s"}},
+		},
+		{
+			desc:     "same backend TLS",
+			backend1: &types.Backend{TLS: &types.BackendTLS{InsecureSkipVerify: true}},
+			backend2: &types.Backend{TLS: &types.BackendTLS{InsecureSkipVerify: true}},
+			shared:   true,
+		},
+		{
+			desc:     "different backend TLS",
+			backend1: &types.Backend{TLS: &types.BackendTLS{InsecureSkipVerify: true}},
+			backend2: &types.Backend{TLS: &types.BackendTLS{ServerName: "foo.bar"}},
+		},
+		{
+			desc:     "h2c and forwarding timeouts",
+			backend1: &types.Backend{Protocol: types.BackendProtocolH2C, ForwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "5s"}},
+			backend2: &types.Backend{ForwardingTimeouts: &types.ForwardingTimeouts{ResponseHeaderTimeout: "5s"}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := NewServer(configuration.GlobalConfiguration{})
+			roundTripper1, err := srv.getRoundTripper(srv.globalConfiguration, false, nil, test.backend1)
+			require.NoError(t, err)
+			roundTripper2, err := srv.getRoundTripper(srv.globalConfiguration, false, nil, test.backend2)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.shared, roundTripper1 == roundTripper2)
+		})
+	}
+}
+
+func TestGetRoundTripperBackendTLSFiles(t *testing.T) {
+	backendServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer backendServer.Close()
+
+	caFile, err := ioutil.TempFile("", "backend-ca")
+	require.NoError(t, err)
+	defer os.Remove(caFile.Name())
+	_, err = caFile.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backendServer.Certificate().Raw}))
+	require.NoError(t, err)
+	require.NoError(t, caFile.Close())
+
+	srv := NewServer(configuration.GlobalConfiguration{})
+	backend := &types.Backend{TLS: &types.BackendTLS{CA: caFile.Name()}}
+	roundTripper1, err := srv.getRoundTripper(srv.globalConfiguration, false, nil, backend)
+	require.NoError(t, err)
+	roundTripper2, err := srv.getRoundTripper(srv.globalConfiguration, false, nil, backend)
+	require.NoError(t, err)
+	assert.True(t, roundTripper1 == roundTripper2, "transport not shared while the CA file is unchanged")
+
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(caFile.Name(), modTime, modTime))
+	roundTripper3, err := srv.getRoundTripper(srv.globalConfiguration, false, nil, backend)
+	require.NoError(t, err)
+	assert.False(t, roundTripper1 == roundTripper3, "transport shared while the CA file has changed")
+}
+
+func TestServerLoadConfigDropsUnusedTransports(t *testing.T) {
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	timeoutsConfig := func(responseHeaderTimeout string) types.Configurations {
+		backend := buildBackend(withServer("server", "http://127.0.0.1:8080"))
+		if len(responseHeaderTimeout) > 0 {
+			backend.ForwardingTimeouts = &types.ForwardingTimeouts{ResponseHeaderTimeout: responseHeaderTimeout}
+		}
+		return types.Configurations{
+			"config": buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("route", "Host:foo.test"), withBackendName("backend"))),
+				withBackend("backend", backend),
+			),
+		}
+	}
+
+	srv := NewServer(globalConfig)
+	_, err := srv.loadConfig(timeoutsConfig("5s"), globalConfig)
+	require.NoError(t, err)
+	require.Len(t, srv.transports.transports, 1)
+	transport := srv.transports.transports[0].transport
+
+	// the backend is kept as it is, with its transport
+	_, err = srv.loadConfig(timeoutsConfig("5s"), globalConfig)
+	require.NoError(t, err)
+	require.Len(t, srv.transports.transports, 1)
+	assert.True(t, transport == srv.transports.transports[0].transport)
+
+	_, err = srv.loadConfig(timeoutsConfig("10s"), globalConfig)
+	require.NoError(t, err)
+	require.Len(t, srv.transports.transports, 1)
+	assert.False(t, transport == srv.transports.transports[0].transport)
+
+	_, err = srv.loadConfig(timeoutsConfig(""), globalConfig)
+	require.NoError(t, err)
+	assert.Empty(t, srv.transports.transports)
+}
+
+func TestCreateDialerKeepAlive(t *testing.T) {
+	testCases := []struct {
+		desc              string
+		keepAlive         flaeg.Duration
+		expectedKeepAlive time.Duration
+	}{
+		{
+			desc:              "default",
+			expectedKeepAlive: configuration.DefaultKeepAlive,
+		},
+		{
+			desc:              "custom interval",
+			keepAlive:         flaeg.Duration(time.Minute),
+			expectedKeepAlive: time.Minute,
+		},
+		{
+			desc:              "disabled",
+			keepAlive:         flaeg.Duration(-1),
+			expectedKeepAlive: 0,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			globalConfig := configuration.GlobalConfiguration{ForwardingTransport: &configuration.ForwardingTransport{KeepAlive: test.keepAlive}}
+			assert.Equal(t, test.expectedKeepAlive, createDialer(globalConfig).KeepAlive)
+		})
+	}
+}
+
+func TestServerHTTPSBackend(t *testing.T) {
+	httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.TLS.ServerName))
+	}))
+	defer httpsServer.Close()
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: httpsServer.TLS.Certificates[0].Certificate[0]}))
+
+	testCases := []struct {
+		desc               string
+		rootCAs            configuration.RootCAs
+		tls                *types.BackendTLS
+		expectedStatusCode int
+		expectedServerName string
+	}{
+		{
+			desc:               "unknown certificate authority",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			desc:               "certificate authority of the backend",
+			tls:                &types.BackendTLS{CA: serverCA},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "global certificate authority and server name of the backend",
+			rootCAs:            configuration.RootCAs{configuration.FileOrContent(serverCA)},
+			tls:                &types.BackendTLS{ServerName: "example.com"},
+			expectedStatusCode: http.StatusOK,
+			expectedServerName: "example.com",
+		},
+		{
+			desc:               "server name not matching the certificate",
+			tls:                &types.BackendTLS{CA: serverCA, ServerName: "traefik.io"},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+				RootCAs: test.rootCAs,
+			}
+			backend := buildBackend(withServer("server", httpsServer.URL))
+			backend.TLS = test.tls
+			dynamicConfigs := types.Configurations{
+				"config": buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute("route", "Path:/"))),
+					withBackend("backend", backend),
+				),
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, test.expectedServerName, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerH2CBackend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	h2Server := &http2.Server{}
+	backendHandler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Trailer", "Grpc-Status")
+		rw.Write([]byte(req.Proto))
+		rw.Header().Set("Grpc-Status", "0")
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go h2Server.ServeConn(conn, &http2.ServeConnOpts{Handler: backendHandler})
+		}
+	}()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "Path:/grpc"))),
+			withBackend("backend", buildBackend(
+				withServer("server", "http://"+listener.Addr().String()),
+				withProtocol(types.BackendProtocolH2C),
+			)),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/grpc", nil)
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", string(body))
+	assert.Equal(t, "0", response.Trailer.Get("Grpc-Status"))
+}
+
+func TestServerWeightedBackends(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+	stableServer := newTestServer("stable")
+	defer stableServer.Close()
+	canaryServer := newTestServer("canary")
+	defer canaryServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(
+				withRoute("route", "Path:/"),
+				withWeightedBackends(map[string]int{"stable": 3, "canary": 1}),
+			)),
+			withBackend("stable", buildBackend(withServer("server", stableServer.URL))),
+			withBackend("canary", buildBackend(withServer("server", canaryServer.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		counts[recorder.Body.String()]++
+	}
+
+	assert.Equal(t, map[string]int{"stable": 6, "canary": 2}, counts)
+}
+
+func TestListenProvidersThrottle(t *testing.T) {
+	globalConfig := configuration.GlobalConfiguration{ProvidersThrottleDuration: flaeg.Duration(200 * time.Millisecond)}
+	srv := NewServer(globalConfig)
+	stop := make(chan bool)
+	defer close(stop)
+	go srv.listenProviders(stop)
+
+	newConfig := func(backendName string) *types.Configuration {
+		return buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withBackendName(backendName))),
+			withBackend(backendName, buildBackend(withServer("server", "http://127.0.0.1:80"))),
+		)
+	}
+
+	srv.configurationChan <- types.ConfigMessage{ProviderName: "file", Configuration: newConfig("file1")}
+	select {
+	case configurations := <-srv.configurationValidatedChan:
+		assert.Contains(t, configurations["file"].Backends, "file1")
+	case <-time.After(time.Second):
+		t.Fatal("the first configuration was not applied at once")
+	}
+
+	srv.configurationChan <- types.ConfigMessage{ProviderName: "docker", Configuration: newConfig("docker1")}
+	srv.configurationChan <- types.ConfigMessage{ProviderName: "file", Configuration: newConfig("file2")}
+	srv.configurationChan <- types.ConfigMessage{ProviderName: "docker", Configuration: newConfig("docker2")}
+	select {
+	case configurations := <-srv.configurationValidatedChan:
+		require.Len(t, configurations, 2)
+		assert.Contains(t, configurations["file"].Backends, "file2")
+		assert.Contains(t, configurations["docker"].Backends, "docker2")
+	case <-time.After(time.Second):
+		t.Fatal("the throttled configurations were not applied")
+	}
+
+	select {
+	case configurations := <-srv.configurationValidatedChan:
+		t.Fatalf("unexpected reload with %v", configurations)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestServerBackendsOfSeveralProviders(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+	fileServer := newTestServer("file")
+	defer fileServer.Close()
+	dockerServer := newTestServer("docker")
+	defer dockerServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"file": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "Path:/file"))),
+			withBackend("backend", buildBackend(withServer("server", fileServer.URL))),
+		),
+		"docker": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "Path:/docker"))),
+			withBackend("backend", buildBackend(withServer("server", dockerServer.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	for _, providerName := range []string{"file", "docker"} {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+providerName, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, providerName, recorder.Body.String())
+	}
+}
+
+func TestServerBackendForwardingTimeouts(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		rw.Write([]byte("slow"))
+	}))
+	defer slowServer.Close()
+
+	testCases := []struct {
+		desc               string
+		timeouts           *types.ForwardingTimeouts
+		expectedStatusCode int
+	}{
+		{
+			desc:               "global timeouts",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			desc:               "response header timeout of the backend",
+			timeouts:           &types.ForwardingTimeouts{ResponseHeaderTimeout: "100ms"},
+			expectedStatusCode: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+			}
+			dynamicConfigs := types.Configurations{
+				"config": buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute("route", "Path:/"))),
+					withBackend("backend", buildBackend(withServer("server", slowServer.URL), withForwardingTimeouts(test.timeouts))),
+				),
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestPassHostHeader(t *testing.T) {
+	passes := true
+	rewrites := false
+
+	testCases := []struct {
+		desc                   string
+		frontendPassHostHeader bool
+		backendPassHostHeader  *bool
+		expected               bool
+	}{
+		{
+			desc:                   "frontend setting",
+			frontendPassHostHeader: true,
+			expected:               true,
+		},
+		{
+			desc:                  "preserved by the backend",
+			backendPassHostHeader: &passes,
+			expected:              true,
+		},
+		{
+			desc:                   "rewritten by the backend",
+			frontendPassHostHeader: true,
+			backendPassHostHeader:  &rewrites,
+			expected:               false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			backend := &types.Backend{PassHostHeader: test.backendPassHostHeader}
+			assert.Equal(t, test.expected, passHostHeader(test.frontendPassHostHeader, backend))
+		})
+	}
+}
+
+func TestServerMirrorPassHostHeader(t *testing.T) {
+	hosts := make(chan string, 1)
+	mirrorServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		hosts <- req.Host
+	}))
+	defer mirrorServer.Close()
+	mirrorURL, err := url.Parse(mirrorServer.URL)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		passHostHeader bool
+		expectedHost   string
+	}{
+		{
+			desc:           "host passed by the frontend",
+			passHostHeader: true,
+			expectedHost:   "example.com",
+		},
+		{
+			desc:         "host rewritten by the frontend",
+			expectedHost: mirrorURL.Host,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			config := buildDynamicConfig(
+				withBackend("mirror", buildBackend(withServer("server", mirrorServer.URL))),
+			)
+			frontend := &types.Frontend{PassHostHeader: test.passHostHeader, Mirror: &types.Mirror{Backend: "mirror", Percent: 100}}
+
+			srv := NewServer(configuration.GlobalConfiguration{})
+			handler, err := srv.buildMirrorHandler(configuration.GlobalConfiguration{}, config, frontend)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RequestURI = "/"
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, test.expectedHost, <-hosts)
+		})
+	}
+}
+
+func TestOverrideForwardingTimeouts(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		global        *configuration.ForwardingTimeouts
+		backend       *types.ForwardingTimeouts
+		expected      *configuration.ForwardingTimeouts
+		expectedError bool
+	}{
+		{
+			desc:     "without global timeouts",
+			backend:  &types.ForwardingTimeouts{ResponseHeaderTimeout: "10s"},
+			expected: &configuration.ForwardingTimeouts{DialTimeout: flaeg.Duration(configuration.DefaultDialTimeout), ResponseHeaderTimeout: flaeg.Duration(10 * time.Second)},
+		},
+		{
+			desc:     "global timeouts overridden",
+			global:   &configuration.ForwardingTimeouts{DialTimeout: flaeg.Duration(5 * time.Second), IdleConnTimeout: flaeg.Duration(time.Minute)},
+			backend:  &types.ForwardingTimeouts{DialTimeout: "1s", IdleConnTimeout: "10s"},
+			expected: &configuration.ForwardingTimeouts{DialTimeout: flaeg.Duration(time.Second), IdleConnTimeout: flaeg.Duration(10 * time.Second)},
+		},
+		{
+			desc:          "invalid duration",
+			backend:       &types.ForwardingTimeouts{DialTimeout: "1 second"},
+			expectedError: true,
+		},
+		{
+			desc:          "negative duration",
+			backend:       &types.ForwardingTimeouts{IdleConnTimeout: "-1s"},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			timeouts, err := overrideForwardingTimeouts(test.global, test.backend)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, timeouts)
+		})
+	}
+}
+
+func TestServerRoutePriorities(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+
+	testCases := []struct {
+		desc          string
+		shortPriority int
+		longPriority  int
+		expected      string
+	}{
+		{
+			desc:     "rule length",
+			expected: "long",
+		},
+		{
+			desc:          "explicit priority",
+			shortPriority: 100,
+			expected:      "short",
+		},
+		{
+			desc:          "same priority",
+			shortPriority: 10,
+			longPriority:  10,
+			expected:      "short",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			shortServer := newTestServer("short")
+			defer shortServer.Close()
+			longServer := newTestServer("long")
+			defer longServer.Close()
+
+			globalConfig := configuration.GlobalConfiguration{
+				EntryPoints: configuration.EntryPoints{
+					"http": &configuration.EntryPoint{},
+				},
+			}
+			dynamicConfigs := types.Configurations{
+				"a": buildDynamicConfig(
+					withFrontend("short", buildFrontend(withRoutePriority("route", "PathPrefix:/api", test.shortPriority), withBackendName("short"))),
+					withBackend("short", buildBackend(withServer("server", shortServer.URL))),
+				),
+				"b": buildDynamicConfig(
+					withFrontend("long", buildFrontend(withRoutePriority("route", "PathPrefix:/api/v1", test.longPriority), withBackendName("long"))),
+					withBackend("long", buildBackend(withServer("server", longServer.URL))),
+				),
+			}
+
+			// the match order doesn't change across reloads
+			for i := 0; i < 10; i++ {
+				srv := NewServer(globalConfig)
+				entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+				require.NoError(t, err)
+
+				recorder := httptest.NewRecorder()
+				entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+				require.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, test.expected, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerHeadersRouting(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+	tenantAServer := newTestServer("tenant-a")
+	defer tenantAServer.Close()
+	tenantBServer := newTestServer("tenant-b")
+	defer tenantBServer.Close()
+	jsonServer := newTestServer("json")
+	defer jsonServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("tenant-a", buildFrontend(withRoute("route", "PathPrefix:/;Headers:X-Tenant,a"), withBackendName("tenant-a"))),
+			withFrontend("tenant-b", buildFrontend(withRoute("route", "PathPrefix:/;Headers:X-Tenant,b"), withBackendName("tenant-b"))),
+			withFrontend("json", buildFrontend(withRoute("route", "PathPrefix(`/`) && HeadersRegexp(`Accept`, `^application/(.+\\+)?json`)"), withBackendName("json"))),
+			withBackend("tenant-a", buildBackend(withServer("server", tenantAServer.URL))),
+			withBackend("tenant-b", buildBackend(withServer("server", tenantBServer.URL))),
+			withBackend("json", buildBackend(withServer("server", jsonServer.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc               string
+		headers            map[string]string
+		expectedStatusCode int
+		expectedBackend    string
+	}{
+		{
+			desc:               "tenant a",
+			headers:            map[string]string{"X-Tenant": "a"},
+			expectedStatusCode: http.StatusOK,
+			expectedBackend:    "tenant-a",
+		},
+		{
+			desc:               "tenant b with lower case header name",
+			headers:            map[string]string{"x-tenant": "b"},
+			expectedStatusCode: http.StatusOK,
+			expectedBackend:    "tenant-b",
+		},
+		{
+			desc:               "json accept",
+			headers:            map[string]string{"Accept": "application/vnd.api+json"},
+			expectedStatusCode: http.StatusOK,
+			expectedBackend:    "json",
+		},
+		{
+			desc:               "unknown tenant",
+			headers:            map[string]string{"X-Tenant": "c"},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/users", nil)
+			for name, value := range test.headers {
+				request.Header.Set(name, value)
+			}
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, test.expectedBackend, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerMethodsRouting(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+	replicaServer := newTestServer("replica")
+	defer replicaServer.Close()
+	primaryServer := newTestServer("primary")
+	defer primaryServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("reads", buildFrontend(withRoute("route", "PathPrefix:/api;Method:GET,HEAD"), withBackendName("replica"))),
+			withFrontend("writes", buildFrontend(withRoute("route", "PathPrefix(`/api`) && !Method(`GET`, `HEAD`)"), withBackendName("primary"))),
+			withBackend("replica", buildBackend(withServer("server", replicaServer.URL))),
+			withBackend("primary", buildBackend(withServer("server", primaryServer.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	testCases := map[string]string{
+		http.MethodGet:    "replica",
+		http.MethodPost:   "primary",
+		http.MethodPut:    "primary",
+		http.MethodDelete: "primary",
+	}
+
+	for method, expectedBackend := range testCases {
+		method, expectedBackend := method, expectedBackend
+		t.Run(method, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(method, "/api/users", nil))
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, expectedBackend, recorder.Body.String())
+		})
+	}
+}
+
+func TestServerRouteVariables(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name + " " + req.URL.Path))
+		}))
+	}
+	fooServer := newTestServer("foo")
+	defer fooServer.Close()
+	barServer := newTestServer("bar")
+	defer barServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(
+				withRoute("route", "HostRegexp:{subdomain:[a-z]+}.example.com;PathRegexp:^/users/(?P<id>[0-9]+)$;ReplacePath:/v1/users/{id}"),
+				withBackendName("{subdomain}"),
+			)),
+			withBackend("foo", buildBackend(withServer("server", fooServer.URL))),
+			withBackend("bar", buildBackend(withServer("server", barServer.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			url:                "http://foo.example.com/users/42",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "foo /v1/users/42",
 		},
 		{
-			desc: "invalid load balancer method with sticky disabled",
-			lb: &types.LoadBalancer{
-				Method:     "Invalid",
-				Stickiness: nil,
-			},
-			wantMethod: defaultMethod,
+			url:                "http://bar.example.com/users/7",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "bar /v1/users/7",
 		},
 		{
-			desc:       "missing load balancer",
-			lb:         nil,
-			wantMethod: defaultMethod,
+			url:                "http://baz.example.com/users/7",
+			expectedStatusCode: http.StatusNotFound,
 		},
 	}
 
-	for _, test := range tests {
+	for _, test := range testCases {
 		test := test
-		t.Run(test.desc, func(t *testing.T) {
-			t.Parallel()
-			backend := &types.Backend{
-				LoadBalancer: test.lb,
-			}
-
-			srv := Server{}
-			srv.configureBackends(map[string]*types.Backend{
-				"backend": backend,
-			})
+		t.Run(test.url, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.url, nil))
 
-			wantLB := types.LoadBalancer{
-				Method:     test.wantMethod,
-				Stickiness: test.wantStickiness,
-			}
-			if !reflect.DeepEqual(*backend.LoadBalancer, wantLB) {
-				t.Errorf("got backend load-balancer\n%v\nwant\n%v\n", spew.Sdump(backend.LoadBalancer), spew.Sdump(wantLB))
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
 			}
 		})
 	}
 }
 
-func TestServerEntrypointWhitelistConfig(t *testing.T) {
-	tests := []struct {
-		desc           string
-		entrypoint     *configuration.EntryPoint
-		wantMiddleware bool
+func TestServerRouteVariablesPrivateBackends(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("foo"))
+	}))
+	defer backend.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	templateFrontend := buildFrontend(
+		withRoute("route", "HostRegexp:{subdomain:[a-z]+}.example.com"),
+		withBackendName("{subdomain}"),
+	)
+	templateFrontend.Headers.CustomResponseHeaders = map[string]string{"X-Frontend": "template"}
+	brokenBackend := buildBackend(withServer("server", backend.URL))
+	brokenBackend.CircuitBreaker = &types.CircuitBreaker{Expression: "invalid"}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("template", templateFrontend),
+			withFrontend("direct", buildFrontend(withRoute("route", "Host:direct.test"), withBackendName("foo"))),
+			withBackend("foo", buildBackend(withServer("server", backend.URL))),
+			withBackend("broken", brokenBackend),
+			withBackend("foo-bar", buildBackend(withServer("server", backend.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		url                string
+		expectedStatusCode int
+		expectedHeaders    []string
 	}{
 		{
-			desc: "no whitelist middleware if no config on entrypoint",
-			entrypoint: &configuration.EntryPoint{
-				Address: ":0",
-			},
-			wantMiddleware: false,
+			url:                "http://foo.example.com/",
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders:    []string{"template"},
 		},
 		{
-			desc: "whitelist middleware should be added if configured on entrypoint",
-			entrypoint: &configuration.EntryPoint{
-				Address: ":0",
-				WhitelistSourceRange: []string{
-					"127.0.0.1/32",
-				},
-			},
-			wantMiddleware: true,
+			url:                "http://direct.test/",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			url:                "http://broken.example.com/",
+			expectedStatusCode: http.StatusNotFound,
+			expectedHeaders:    []string{"template"},
 		},
 	}
 
-	for _, test := range tests {
+	for _, test := range testCases {
 		test := test
-		t.Run(test.desc, func(t *testing.T) {
-			t.Parallel()
-
-			srv := Server{
-				globalConfiguration: configuration.GlobalConfiguration{
-					EntryPoints: map[string]*configuration.EntryPoint{
-						"test": test.entrypoint,
-					},
-				},
-				metricsRegistry: metrics.NewVoidRegistry(),
-			}
+		t.Run(test.url, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.url, nil))
 
-			srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
-			srvEntryPoint := srv.setupServerEntryPoint("test", srv.serverEntryPoints["test"])
-			handler := srvEntryPoint.httpServer.Handler.(*negroni.Negroni)
-			found := false
-			for _, handler := range handler.Handlers() {
-				if reflect.TypeOf(handler) == reflect.TypeOf((*middlewares.IPWhiteLister)(nil)) {
-					found = true
-				}
-			}
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedHeaders, recorder.Header()["X-Frontend"])
+		})
+	}
+	// the backends of the template are built for it only, the other frontends not sharing them
+	assert.Contains(t, srv.loadedBackends.handlers, healthcheck.BackendID("config", "http", "foo"))
+	assert.NotContains(t, srv.loadedBackends.handlers, healthcheck.BackendID("config", "http", "foo-bar"))
+}
 
-			if found && !test.wantMiddleware {
-				t.Errorf("ip whitelist middleware was installed even though it should not")
-			}
+func TestServerDefaultBackend(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("backend"))
+	}))
+	defer backend.Close()
 
-			if !found && test.wantMiddleware {
-				t.Errorf("ip whitelist middleware was not installed even though it should have")
-			}
-		})
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+		DefaultBackend: &types.DefaultBackend{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "PathPrefix:/api"))),
+			withBackend("backend", buildBackend(withServer("server", backend.URL))),
+		),
 	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "backend", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "maintenance\n", recorder.Body.String())
 }
 
-func TestServerResponseEmptyBackend(t *testing.T) {
-	const requestPath = "/path"
-	const routeRule = "Path:" + requestPath
+// registerTestHeaderPlugin registers the test middleware once, the tests being possibly run several times
+var registerTestHeaderPlugin sync.Once
+
+func TestServerFrontendMiddlewares(t *testing.T) {
+	registerTestHeaderPlugin.Do(func() {
+		plugins.Register("test-header", func(next http.Handler, settings map[string]string) (http.Handler, error) {
+			return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Foo", settings["value"])
+				next.ServeHTTP(rw, req)
+			}), nil
+		})
+	})
+
+	middlewaresConfig := map[string]*types.Middleware{
+		"blacklist":      {IPFilter: &types.IPFilter{BlacklistSourceRange: []string{"192.0.2.0/24"}}},
+		"whitelist":      {IPFilter: &types.IPFilter{WhitelistSourceRange: []string{"10.0.0.0/8"}}},
+		"auth":           {Auth: &types.Auth{Basic: &types.Basic{Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}}}},
+		"headers":        {Headers: &types.Headers{CustomResponseHeaders: map[string]string{"X-Foo": "bar"}}},
+		"plugin":         {Plugin: &types.Plugin{Name: "test-header", Settings: map[string]string{"value": "baz"}}},
+		"unknown-plugin": {Plugin: &types.Plugin{Name: "unknown"}},
+		"invalid-wasm":   {WASM: &types.WASM{Module: "AGFzbQEAAAA="}},
+		"invalid": {
+			Headers:  &types.Headers{CustomResponseHeaders: map[string]string{"X-Foo": "bar"}},
+			Redirect: &types.Redirect{Regex: "^(.*)$", Replacement: "https://example.com"},
+		},
+	}
 
 	testCases := []struct {
-		desc           string
-		dynamicConfig  func(testServerURL string) *types.Configuration
-		wantStatusCode int
+		desc               string
+		middlewares        []string
+		expectedStatusCode int
+		expectedHeader     string
 	}{
 		{
-			desc: "Ok",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withServer("testServer", testServerURL))),
-				)
-			},
-			wantStatusCode: http.StatusOK,
+			desc:               "ip filter",
+			middlewares:        []string{"blacklist"},
+			expectedStatusCode: http.StatusForbidden,
 		},
 		{
-			desc: "No Frontend",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig()
-			},
-			wantStatusCode: http.StatusNotFound,
+			desc:               "headers",
+			middlewares:        []string{"headers"},
+			expectedStatusCode: http.StatusOK,
+			expectedHeader:     "bar",
 		},
 		{
-			desc: "Empty Backend LB-Drr",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Drr", false))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:               "ip filter before auth",
+			middlewares:        []string{"whitelist", "auth"},
+			expectedStatusCode: http.StatusForbidden,
 		},
 		{
-			desc: "Empty Backend LB-Drr Sticky",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Drr", true))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:               "auth before ip filter",
+			middlewares:        []string{"auth", "whitelist"},
+			expectedStatusCode: http.StatusUnauthorized,
 		},
 		{
-			desc: "Empty Backend LB-Wrr",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Wrr", false))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:               "undefined middleware",
+			middlewares:        []string{"headers", "unknown"},
+			expectedStatusCode: http.StatusNotFound,
 		},
 		{
-			desc: "Empty Backend LB-Wrr Sticky",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Wrr", true))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:               "plugin",
+			middlewares:        []string{"plugin"},
+			expectedStatusCode: http.StatusOK,
+			expectedHeader:     "baz",
+		},
+		{
+			desc:               "unregistered plugin",
+			middlewares:        []string{"unknown-plugin"},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			desc:               "wasm module without handle_request function",
+			middlewares:        []string{"invalid-wasm"},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			desc:               "middleware with several settings",
+			middlewares:        []string{"invalid"},
+			expectedStatusCode: http.StatusNotFound,
 		},
 	}
 
 	for _, test := range testCases {
 		test := test
-
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
@@ -636,26 +2696,51 @@ func TestServerResponseEmptyBackend(t *testing.T) {
 					"http": &configuration.EntryPoint{},
 				},
 			}
-			dynamicConfigs := types.Configurations{"config": test.dynamicConfig(testServer.URL)}
+			frontend := buildFrontend(withRoute("route", "Path:/"))
+			frontend.Middlewares = test.middlewares
+			config := buildDynamicConfig(
+				withFrontend("frontend", frontend),
+				withBackend("backend", buildBackend(withServer("server", testServer.URL))),
+			)
+			config.Middlewares = middlewaresConfig
+			dynamicConfigs := types.Configurations{"config": config}
 
 			srv := NewServer(globalConfig)
 			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
-			if err != nil {
-				t.Fatalf("error loading config: %s", err)
-			}
-
-			responseRecorder := &httptest.ResponseRecorder{}
-			request := httptest.NewRequest(http.MethodGet, testServer.URL+requestPath, nil)
+			require.NoError(t, err)
 
-			entryPoints["http"].httpRouter.ServeHTTP(responseRecorder, request)
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
 
-			if responseRecorder.Result().StatusCode != test.wantStatusCode {
-				t.Errorf("got status code %d, want %d", responseRecorder.Result().StatusCode, test.wantStatusCode)
-			}
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Equal(t, test.expectedHeader, recorder.Header().Get("X-Foo"))
 		})
 	}
 }
 
+func TestTrackOpenConnections(t *testing.T) {
+	gauge := &gaugeMock{}
+	connState := trackOpenConnections(gauge)
+
+	for _, state := range []http.ConnState{http.StateNew, http.StateNew, http.StateActive, http.StateIdle, http.StateNew, http.StateClosed, http.StateHijacked} {
+		connState(nil, state)
+	}
+
+	assert.Equal(t, []float64{1, 2, 3, 2, 1}, gauge.values)
+}
+
+type gaugeMock struct {
+	values []float64
+}
+
+func (g *gaugeMock) With(labelValues ...string) gokitmetrics.Gauge {
+	return g
+}
+
+func (g *gaugeMock) Set(value float64) {
+	g.values = append(g.values, value)
+}
+
 func buildDynamicConfig(dynamicConfigBuilders ...func(*types.Configuration)) *types.Configuration {
 	config := &types.Configuration{
 		Frontends: make(map[string]*types.Frontend),
@@ -697,6 +2782,31 @@ func withRoute(routeName, rule string) func(*types.Frontend) {
 	}
 }
 
+func withRoutePriority(routeName, rule string, priority int) func(*types.Frontend) {
+	return func(fe *types.Frontend) {
+		fe.Routes[routeName] = types.Route{Rule: rule, Priority: priority}
+	}
+}
+
+func withEntryPoints(entryPoints ...string) func(*types.Frontend) {
+	return func(fe *types.Frontend) {
+		fe.EntryPoints = entryPoints
+	}
+}
+
+func withBackendName(backendName string) func(*types.Frontend) {
+	return func(fe *types.Frontend) {
+		fe.Backend = backendName
+	}
+}
+
+func withWeightedBackends(weightedBackends map[string]int) func(*types.Frontend) {
+	return func(fe *types.Frontend) {
+		fe.Backend = ""
+		fe.WeightedBackends = weightedBackends
+	}
+}
+
 func buildBackend(backendBuilders ...func(*types.Backend)) *types.Backend {
 	be := &types.Backend{
 		Servers:      make(map[string]types.Server),
@@ -723,3 +2833,159 @@ func withLoadBalancer(method string, sticky bool) func(*types.Backend) {
 		}
 	}
 }
+
+func withProtocol(protocol string) func(*types.Backend) {
+	return func(be *types.Backend) {
+		be.Protocol = protocol
+	}
+}
+
+func withForwardingTimeouts(timeouts *types.ForwardingTimeouts) func(*types.Backend) {
+	return func(be *types.Backend) {
+		be.ForwardingTimeouts = timeouts
+	}
+}
+
+func TestNewWebEntryPoints(t *testing.T) {
+	entryPoints := configuration.EntryPoints{
+		"http": &configuration.EntryPoint{
+			Address:  ":80",
+			Redirect: &configuration.Redirect{EntryPoint: "https"},
+		},
+		"https": &configuration.EntryPoint{
+			Address:  ":443",
+			TLS:      &configuration.TLS{},
+			Compress: true,
+		},
+		"dns": &configuration.EntryPoint{
+			Address:  ":53",
+			Protocol: configuration.EntryPointProtocolUDP,
+		},
+		"admin": &configuration.EntryPoint{
+			Address:  ":8000",
+			Auth:     &types.Auth{},
+			Redirect: &configuration.Redirect{Regex: "^http://(.*)", Replacement: "https://$1"},
+		},
+	}
+
+	expected := map[string]*web.EntryPoint{
+		"http":  {Address: ":80", Protocol: "http", Redirect: "https"},
+		"https": {Address: ":443", Protocol: "http", TLS: true, Compress: true},
+		"dns":   {Address: ":53", Protocol: "udp"},
+		"admin": {Address: ":8000", Protocol: "http", Auth: true, Redirect: "https://$1"},
+	}
+	assert.Equal(t, expected, newWebEntryPoints(entryPoints))
+}
+
+func TestServerPing(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+	defer backend.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http":  &configuration.EntryPoint{},
+			"admin": &configuration.EntryPoint{},
+		},
+		Ping: &ping.Handler{EntryPoint: "http"},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": &types.Configuration{
+			Frontends: map[string]*types.Frontend{
+				"frontend": {
+					EntryPoints: []string{"http", "admin"},
+					Backend:     "backend",
+					Routes: map[string]types.Route{
+						"route": {Rule: "PathPrefix:/"},
+					},
+				},
+			},
+			Backends: map[string]*types.Backend{
+				"backend": {
+					Servers: map[string]types.Server{
+						"server": {URL: backend.URL},
+					},
+					LoadBalancer: &types.LoadBalancer{Method: "wrr"},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/ping", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "OK", recorder.Body.String())
+
+	// the other entrypoints forward /ping to the frontends
+	recorder = httptest.NewRecorder()
+	entryPoints["admin"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/ping", nil))
+	assert.Equal(t, http.StatusTeapot, recorder.Code)
+
+	globalConfig.Ping.SetTerminating()
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/ping", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
+
+func TestServerAPIEntryPoint(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+	defer backend.Close()
+	bothBackend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer bothBackend.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http":    &configuration.EntryPoint{},
+			"traefik": &configuration.EntryPoint{},
+		},
+		Web: &web.Provider{EntryPoint: "traefik"},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "PathPrefix:/"), withEntryPoints("http"))),
+			withFrontend("admin", buildFrontend(withRoute("route", "PathPrefix:/"), withEntryPoints("traefik"))),
+			withFrontend("both", buildFrontend(withRoute("route", "Host:both.test"), withEntryPoints("traefik", "http"), withBackendName("both"))),
+			withBackend("backend", buildBackend(withServer("server", backend.URL))),
+			withBackend("both", buildBackend(withServer("server", bothBackend.URL))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	// unavailable until the provider is started
+	recorder := httptest.NewRecorder()
+	entryPoints["traefik"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/api/version", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	globalConfig.Web.CurrentConfigurations = &srv.currentConfigurations
+	require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+
+	recorder = httptest.NewRecorder()
+	entryPoints["traefik"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/api/version", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// the frontends are not served by the entrypoint of the API
+	recorder = httptest.NewRecorder()
+	entryPoints["traefik"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil))
+	assert.Equal(t, http.StatusTeapot, recorder.Code)
+
+	// the frontends of several entrypoints are still served by the other ones
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://both.test/foo", nil))
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/containous/traefik/log"
+)
+
+// listenFdsStart is the first file descriptor passed by the systemd socket activation, see sd_listen_fds(3)
+const listenFdsStart = 3
+
+// activatedSocket is a socket passed by the systemd socket activation, named by the FileDescriptorName of its unit.
+// Its file descriptor is kept open once taken, so that the entry point can take the socket again when it is
+// restarted by a reload of the global configuration, systemd not passing it again.
+type activatedSocket struct {
+	name       string
+	file       *os.File
+	entryPoint string // the entry point which has taken the socket
+	listener   net.Listener
+	packetConn net.PacketConn
+}
+
+func (s *activatedSocket) addr() net.Addr {
+	if s.listener != nil {
+		return s.listener.Addr()
+	}
+	return s.packetConn.LocalAddr()
+}
+
+// activatedSockets are the sockets passed by the systemd socket activation, taken by the entry points
+// of their names, or of their addresses, instead of opening their own, and kept for them
+type activatedSockets struct {
+	mutex   sync.Mutex
+	sockets []*activatedSocket
+}
+
+// newActivatedSockets returns the sockets passed to Traefik by the systemd socket activation, if any.
+// The environment of the activation is cleared, so that it is not passed to the child processes.
+func newActivatedSockets() *activatedSockets {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	activated := &activatedSockets{}
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return activated
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return activated
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && len(names[i]) > 0 {
+			name = names[i]
+		}
+		socket, err := fileSocket(os.NewFile(uintptr(fd), name))
+		if err != nil {
+			log.Errorf("Error using the socket %s passed by systemd: %v", name, err)
+			continue
+		}
+		log.Infof("Using the socket %s on %s passed by systemd", name, socket.addr())
+		activated.sockets = append(activated.sockets, socket)
+	}
+	return activated
+}
+
+// fileSocket returns the stream or datagram socket of the file, which is kept open, or closed on error
+func fileSocket(file *os.File) (*activatedSocket, error) {
+	socket := &activatedSocket{name: file.Name(), file: file}
+	listener, err := net.FileListener(file)
+	if err == nil {
+		socket.listener = listener
+		return socket, nil
+	}
+	packetConn, packetErr := net.FilePacketConn(file)
+	if packetErr != nil {
+		file.Close()
+		return nil, err
+	}
+	socket.packetConn = packetConn
+	return socket, nil
+}
+
+// reopen opens the socket again from its file descriptor, the previous listener or connection having been closed
+// with the entry point
+func (s *activatedSocket) reopen() error {
+	if s.file == nil {
+		return errors.New("no file descriptor")
+	}
+	if s.listener != nil {
+		listener, err := net.FileListener(s.file)
+		if err != nil {
+			return err
+		}
+		s.listener = listener
+		return nil
+	}
+	packetConn, err := net.FilePacketConn(s.file)
+	if err != nil {
+		return err
+	}
+	s.packetConn = packetConn
+	return nil
+}
+
+func (s *activatedSocket) close() {
+	if s.listener != nil {
+		s.listener.Close()
+	} else {
+		s.packetConn.Close()
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+}
+
+// listener takes the stream socket of the entry point, nil if none has been passed
+func (a *activatedSockets) listener(entryPointName, address string) net.Listener {
+	if socket := a.take(entryPointName, address, true); socket != nil {
+		return socket.listener
+	}
+	return nil
+}
+
+// packetConn takes the datagram socket of the entry point, nil if none has been passed
+func (a *activatedSockets) packetConn(entryPointName, address string) net.PacketConn {
+	if socket := a.take(entryPointName, address, false); socket != nil {
+		return socket.packetConn
+	}
+	return nil
+}
+
+func (a *activatedSockets) take(entryPointName, address string, stream bool) *activatedSocket {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	index := -1
+	for i, socket := range a.sockets {
+		if (socket.listener != nil) != stream || len(socket.entryPoint) > 0 && socket.entryPoint != entryPointName {
+			continue
+		}
+		if socket.name == entryPointName {
+			index = i
+			break
+		}
+		if index < 0 && sameAddress(address, socket.addr()) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+	socket := a.sockets[index]
+	if len(socket.entryPoint) > 0 {
+		if err := socket.reopen(); err != nil {
+			log.Errorf("Error taking again the socket %s passed by systemd for entrypoint %s: %v", socket.name, entryPointName, err)
+			return nil
+		}
+	}
+	socket.entryPoint = entryPointName
+	log.Infof("Entrypoint %s uses the socket %s passed by systemd", entryPointName, socket.name)
+	return socket
+}
+
+// closeUnused closes the sockets taken by no entry point
+func (a *activatedSockets) closeUnused() {
+	if a == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var taken []*activatedSocket
+	for _, socket := range a.sockets {
+		if len(socket.entryPoint) > 0 {
+			taken = append(taken, socket)
+			continue
+		}
+		log.Warnf("Closing the socket %s on %s passed by systemd, unused by the entrypoints", socket.name, socket.addr())
+		socket.close()
+	}
+	a.sockets = taken
+}
+
+// sameAddress returns whether the socket address is the address of an entry point, whose host may be omitted
+func sameAddress(address string, addr net.Addr) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	socketHost, socketPort, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	portNumber, err := net.LookupPort(addr.Network(), port)
+	if err != nil || strconv.Itoa(portNumber) != socketPort {
+		return false
+	}
+	socketIP := net.ParseIP(socketHost)
+	if len(host) == 0 {
+		return socketIP != nil && socketIP.IsUnspecified()
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.Equal(socketIP)
+	}
+	return host == socketHost
+}
@@ -0,0 +1,131 @@
+// +build !windows
+
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameAddress(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		address  string
+		addr     net.Addr
+		expected bool
+	}{
+		{
+			desc:     "any host",
+			address:  ":80",
+			addr:     &net.TCPAddr{IP: net.IPv6unspecified, Port: 80},
+			expected: true,
+		},
+		{
+			desc:     "named port",
+			address:  ":http",
+			addr:     &net.TCPAddr{IP: net.IPv4zero, Port: 80},
+			expected: true,
+		},
+		{
+			desc:     "same IP",
+			address:  "127.0.0.1:8080",
+			addr:     &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080},
+			expected: true,
+		},
+		{
+			desc:    "other port",
+			address: ":80",
+			addr:    &net.TCPAddr{IP: net.IPv4zero, Port: 443},
+		},
+		{
+			desc:    "any host for a specific IP",
+			address: ":80",
+			addr:    &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 80},
+		},
+		{
+			desc:    "other IP",
+			address: "10.0.0.1:80",
+			addr:    &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 80},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, sameAddress(test.address, test.addr))
+		})
+	}
+}
+
+func TestActivatedSockets(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcpListener.Close()
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udpConn.Close()
+
+	file, err := tcpListener.(*net.TCPListener).File()
+	require.NoError(t, err)
+	streamSocket, err := fileSocket(file)
+	require.NoError(t, err)
+	require.NotNil(t, streamSocket.listener)
+	file, err = udpConn.(*net.UDPConn).File()
+	require.NoError(t, err)
+	datagramSocket, err := fileSocket(file)
+	require.NoError(t, err)
+	require.NotNil(t, datagramSocket.packetConn)
+
+	namedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unusedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	activated := &activatedSockets{sockets: []*activatedSocket{
+		streamSocket,
+		datagramSocket,
+		{name: "https", listener: namedListener},
+		{name: "unused", listener: unusedListener},
+	}}
+
+	// by name, then by address
+	assert.Equal(t, namedListener, activated.listener("https", ":443"))
+	assert.Nil(t, activated.listener("http", "127.0.0.1:1"))
+	listener := activated.listener("http", tcpListener.Addr().String())
+	require.NotNil(t, listener)
+	assert.Equal(t, tcpListener.Addr().String(), listener.Addr().String())
+	assert.Nil(t, activated.packetConn("dns", tcpListener.Addr().String()))
+	packetConn := activated.packetConn("dns", udpConn.LocalAddr().String())
+	require.NotNil(t, packetConn)
+	assert.Nil(t, activated.listener("other", tcpListener.Addr().String()))
+
+	// a restarted entry point takes its socket again
+	listener.Close()
+	packetConn.Close()
+	listener = activated.listener("http", tcpListener.Addr().String())
+	require.NotNil(t, listener)
+	tcpListener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+	packetConn = activated.packetConn("dns", udpConn.LocalAddr().String())
+	require.NotNil(t, packetConn)
+	namedListener.Close()
+	assert.Nil(t, activated.listener("https", ":443"))
+
+	activated.closeUnused()
+	assert.Len(t, activated.sockets, 3)
+	listener.Close()
+	packetConn.Close()
+	for _, socket := range activated.sockets {
+		socket.close()
+	}
+
+	// without systemd
+	var notActivated *activatedSockets
+	assert.Nil(t, notActivated.listener("http", ":80"))
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/types"
+)
+
+// transportSettings are the settings of the backends requiring their own transport instead of the default one
+type transportSettings struct {
+	h2c                bool
+	backendTLS         *types.BackendTLS
+	backendTLSFiles    map[string]time.Time // modification times of the files of the backend TLS, for them to be read again once changed
+	passTLSCert        bool
+	clientTLS          *configuration.TLS // TLS of the entry point, when the TLS client certificate is passed
+	forwardingTimeouts *configuration.ForwardingTimeouts
+	unixSockets        map[string]string // paths of the Unix sockets of the servers, indexed by the hosts standing for them
+}
+
+// transportPool holds the transports of the backends not using the default one, kept across the configurations
+// and shared by the backends of the same settings, so that their requests reuse the same idle connections.
+// The transports no longer used by the latest configuration are dropped, and their idle connections closed.
+type transportPool struct {
+	mutex      sync.Mutex
+	transports []*pooledTransport
+	// taken lists the transports returned since the last configuration, in order, for the backends to tell theirs
+	taken []*http.Transport
+}
+
+type pooledTransport struct {
+	settings  transportSettings
+	transport *http.Transport
+	used      bool
+}
+
+// get returns the transport of the settings, created when there is none yet
+func (p *transportPool) get(settings transportSettings, create func() (*http.Transport, error)) (http.RoundTripper, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, pooled := range p.transports {
+		if reflect.DeepEqual(pooled.settings, settings) {
+			pooled.used = true
+			p.taken = append(p.taken, pooled.transport)
+			return pooled.transport, nil
+		}
+	}
+	transport, err := create()
+	if err != nil {
+		return nil, err
+	}
+	if len(settings.unixSockets) > 0 {
+		transport.DialContext = dialUnixSockets(transport.DialContext, settings.unixSockets)
+	}
+	p.transports = append(p.transports, &pooledTransport{settings: settings, transport: transport, used: true})
+	p.taken = append(p.taken, transport)
+	return transport, nil
+}
+
+// mark returns the position of the next transport returned, for takenSince to list the ones returned from there
+func (p *transportPool) mark() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.taken)
+}
+
+// takenSince returns the transports returned since the mark
+func (p *transportPool) takenSince(mark int) []*http.Transport {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if mark > len(p.taken) {
+		return nil
+	}
+	return append([]*http.Transport(nil), p.taken[mark:]...)
+}
+
+// use marks the transports as used by the configuration being loaded, for the backends kept as they are
+func (p *transportPool) use(transports []*http.Transport) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, pooled := range p.transports {
+		for _, transport := range transports {
+			if pooled.transport == transport {
+				pooled.used = true
+			}
+		}
+	}
+}
+
+// retain drops the transports not used by the configuration loaded, closing their idle connections
+func (p *transportPool) retain() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var transports []*pooledTransport
+	for _, pooled := range p.transports {
+		if pooled.used {
+			pooled.used = false
+			transports = append(transports, pooled)
+		} else {
+			pooled.transport.CloseIdleConnections()
+		}
+	}
+	p.transports = transports
+	p.taken = nil
+}
+
+// backendTLSFiles returns the modification times of the files of the backend TLS, the values which are not
+// paths of files being the contents of the certificates and keys
+func backendTLSFiles(backendTLS *types.BackendTLS) map[string]time.Time {
+	files := make(map[string]time.Time)
+	for _, fileOrContent := range []string{backendTLS.CA, backendTLS.Cert, backendTLS.Key} {
+		if len(fileOrContent) == 0 {
+			continue
+		}
+		if info, err := os.Stat(fileOrContent); err == nil {
+			files[fileOrContent] = info.ModTime()
+		}
+	}
+	return files
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/containous/traefik/types"
+)
+
+// unixSocketHostSuffix ends the hosts standing for the Unix sockets of the servers in the load-balancers
+const unixSocketHostSuffix = ".sock"
+
+// parseServerURL parses the URL of a server, an unix:///path/to/app.sock URL of a Unix socket being turned into
+// an http URL whose host encodes the path of the socket, so that the load-balancers and the forwarders handle it
+// as any server, and the transport of the backend connects to the socket.
+func parseServerURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "unix" {
+		return u, err
+	}
+	if len(u.Path) == 0 {
+		return nil, fmt.Errorf("missing Unix socket path in server URL %s", rawURL)
+	}
+	return &url.URL{Scheme: "http", Host: hex.EncodeToString([]byte(u.Path)) + unixSocketHostSuffix}, nil
+}
+
+// unixSockets returns the paths of the Unix sockets of the servers of the backend, indexed by the hosts standing for them
+func unixSockets(backend *types.Backend) map[string]string {
+	var sockets map[string]string
+	for _, srv := range backend.Servers {
+		u, err := url.Parse(srv.URL)
+		if err != nil || u.Scheme != "unix" || len(u.Path) == 0 {
+			continue
+		}
+		if sockets == nil {
+			sockets = make(map[string]string)
+		}
+		sockets[hex.EncodeToString([]byte(u.Path))+unixSocketHostSuffix] = u.Path
+	}
+	return sockets
+}
+
+// dialUnixSockets returns the function connecting to the Unix sockets of the hosts standing for them,
+// and dialing the other addresses with dial. Only the sockets configured for the servers of a backend
+// are dialed, whatever the hosts requested.
+func dialUnixSockets(dial func(ctx context.Context, network, addr string) (net.Conn, error), sockets map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if path, ok := sockets[host]; ok {
+			return dial(ctx, "unix", path)
+		}
+		return dial(ctx, network, addr)
+	}
+}
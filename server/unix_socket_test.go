@@ -0,0 +1,139 @@
+// +build !windows
+
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServerURL(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		rawURL        string
+		expectedURL   string
+		expectedPath  string
+		expectedError bool
+	}{
+		{
+			desc:        "http URL",
+			rawURL:      "http://10.0.0.1:8080",
+			expectedURL: "http://10.0.0.1:8080",
+		},
+		{
+			desc:         "Unix socket",
+			rawURL:       "unix:///var/run/app.sock",
+			expectedURL:  "http://2f7661722f72756e2f6170702e736f636b.sock",
+			expectedPath: "/var/run/app.sock",
+		},
+		{
+			desc:          "Unix socket without path",
+			rawURL:        "unix://",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			u, err := parseServerURL(test.rawURL)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedURL, u.String())
+
+			sockets := unixSockets(&types.Backend{Servers: map[string]types.Server{"server": {URL: test.rawURL}}})
+			assert.Equal(t, test.expectedPath, sockets[u.Host])
+		})
+	}
+}
+
+func TestServerUnixSocketBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik-unix-socket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "app.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	unixServer := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("unix " + req.URL.Path))
+	}))
+	unixServer.Listener = listener
+	unixServer.Start()
+	defer unixServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "PathPrefix:/"))),
+			withBackend("backend", buildBackend(withServer("server", "unix://"+socketPath))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "unix /foo", recorder.Body.String())
+}
+
+func TestServerUnixSocketHostNotDialed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik-unix-socket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "app.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	unixServer := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("unix " + req.URL.Path))
+	}))
+	unixServer.Listener = listener
+	unixServer.Start()
+	defer unixServer.Close()
+
+	globalConfig := configuration.GlobalConfiguration{
+		EntryPoints: configuration.EntryPoints{
+			"http": &configuration.EntryPoint{},
+		},
+	}
+	// a host shaped like the ones standing for the Unix sockets is dialed as any host
+	u, err := parseServerURL("unix://" + socketPath)
+	require.NoError(t, err)
+	dynamicConfigs := types.Configurations{
+		"config": buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("route", "PathPrefix:/"))),
+			withBackend("backend", buildBackend(withServer("server", "http://"+u.Host+":80"))),
+		),
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	assert.NotEqual(t, http.StatusOK, recorder.Code)
+}
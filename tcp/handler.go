@@ -0,0 +1,47 @@
+package tcp
+
+import (
+	"net"
+
+	"github.com/containous/traefik/safe"
+)
+
+// Handler is the TCP counterpart of http.Handler
+type Handler interface {
+	ServeTCP(conn net.Conn)
+}
+
+// HandlerFunc is an adapter to use ordinary functions as TCP handlers
+type HandlerFunc func(conn net.Conn)
+
+// ServeTCP calls f(conn)
+func (f HandlerFunc) ServeTCP(conn net.Conn) {
+	f(conn)
+}
+
+// HandlerSwitcher allows hot switching of TCP routers
+type HandlerSwitcher struct {
+	handler *safe.Safe
+}
+
+// NewHandlerSwitcher builds a new instance of HandlerSwitcher
+func NewHandlerSwitcher(newHandler *Router) *HandlerSwitcher {
+	return &HandlerSwitcher{
+		handler: safe.New(newHandler),
+	}
+}
+
+// ServeTCP forwards the connection to the current router
+func (hs *HandlerSwitcher) ServeTCP(conn net.Conn) {
+	hs.GetHandler().ServeTCP(conn)
+}
+
+// GetHandler returns the current router
+func (hs *HandlerSwitcher) GetHandler() *Router {
+	return hs.handler.Get().(*Router)
+}
+
+// UpdateHandler safely updates the current router with a new one
+func (hs *HandlerSwitcher) UpdateHandler(newHandler *Router) {
+	hs.handler.Set(newHandler)
+}
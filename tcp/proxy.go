@@ -0,0 +1,72 @@
+package tcp
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/proxyprotocol"
+)
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// Proxy forwards a TCP connection to a backend server
+type Proxy struct {
+	address              string
+	dialTimeout          time.Duration
+	proxyProtocolVersion int
+}
+
+// NewProxy creates a new Proxy.
+// If proxyProtocolVersion is not zero, a PROXY protocol header of this version is sent to the server.
+func NewProxy(address string, dialTimeout time.Duration, proxyProtocolVersion int) *Proxy {
+	return &Proxy{
+		address:              address,
+		dialTimeout:          dialTimeout,
+		proxyProtocolVersion: proxyProtocolVersion,
+	}
+}
+
+// ServeTCP forwards the connection to the backend server until one of the sides closes it
+func (p *Proxy) ServeTCP(conn net.Conn) {
+	defer conn.Close()
+
+	backendConn, err := net.DialTimeout("tcp", p.address, p.dialTimeout)
+	if err != nil {
+		log.Errorf("Error while connecting to backend %s: %v", p.address, err)
+		return
+	}
+	defer backendConn.Close()
+
+	if p.proxyProtocolVersion > 0 {
+		if err := proxyprotocol.WriteHeader(backendConn, p.proxyProtocolVersion, conn.RemoteAddr(), conn.LocalAddr()); err != nil {
+			log.Errorf("Error while sending PROXY protocol header to backend %s: %v", p.address, err)
+			return
+		}
+	}
+
+	errChan := make(chan error, 2)
+	go connCopy(backendConn, conn, errChan)
+	go connCopy(conn, backendConn, errChan)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errChan; err != nil {
+			log.Debugf("Error while forwarding connection to %s: %v", p.address, err)
+		}
+	}
+}
+
+func connCopy(dst, src net.Conn, errChan chan<- error) {
+	_, err := io.Copy(dst, src)
+	errChan <- err
+
+	// propagate the end of the stream to the other side
+	if cw, ok := dst.(closeWriter); ok {
+		cw.CloseWrite()
+	} else {
+		dst.Close()
+	}
+}
@@ -0,0 +1,123 @@
+package tcp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyServeTCP(t *testing.T) {
+	backendListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer backendListener.Close()
+
+	// echo server
+	go func() {
+		conn, err := backendListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	proxy := NewProxy(backendListener.Addr().String(), time.Second, 0)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		proxy.ServeTCP(conn)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+
+	response, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(response))
+}
+
+func TestProxyServeTCPProxyProtocol(t *testing.T) {
+	backendListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer backendListener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := backendListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	proxy := NewProxy(backendListener.Addr().String(), time.Second, 1)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		proxy.ServeTCP(conn)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+
+	clientAddr := conn.LocalAddr().(*net.TCPAddr)
+	proxyAddr := conn.RemoteAddr().(*net.TCPAddr)
+	expected := fmt.Sprintf("PROXY TCP4 %s %s %d %d\r\nping", clientAddr.IP, proxyAddr.IP, clientAddr.Port, proxyAddr.Port)
+	select {
+	case data := <-received:
+		assert.Equal(t, expected, data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received by the backend")
+	}
+}
+
+func TestRRLoadBalancer(t *testing.T) {
+	var served []string
+	server := func(name string) Handler {
+		return HandlerFunc(func(conn net.Conn) {
+			served = append(served, name)
+		})
+	}
+
+	lb := NewRRLoadBalancer()
+	lb.ServeTCP(&net.TCPConn{})
+	assert.Empty(t, served)
+
+	lb.AddServer(server("server1"))
+	lb.AddServer(server("server2"))
+	for i := 0; i < 3; i++ {
+		lb.ServeTCP(nil)
+	}
+
+	assert.Equal(t, []string{"server1", "server2", "server1"}, served)
+}
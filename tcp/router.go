@@ -0,0 +1,202 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// recordTypeHandshake is the first byte of a TLS connection
+const recordTypeHandshake = 0x16
+
+// clientHelloTimeout is the maximum duration to wait for the TLS ClientHello of a connection routed on SNI
+const clientHelloTimeout = 10 * time.Second
+
+var errClientHelloRead = errors.New("ClientHello read")
+
+type route struct {
+	handler     Handler
+	passthrough bool
+}
+
+// Router routes the TCP connections to handlers according to the server name (SNI)
+// sent by the TLS clients, or to a catch-all handler.
+type Router struct {
+	tlsConfig *tls.Config
+	routes    map[string]*route
+	catchAll  *route
+}
+
+// NewRouter creates a new Router.
+// If tlsConfig is not nil, TLS is terminated for the routes which are not in passthrough.
+func NewRouter(tlsConfig *tls.Config) *Router {
+	return &Router{
+		tlsConfig: tlsConfig,
+		routes:    make(map[string]*route),
+	}
+}
+
+// AddRoute adds a route for the given domains, or a catch-all route if no domain is given.
+// With passthrough, the connection is forwarded as is, without terminating TLS.
+// Nothing is added when one of the domains already has a route.
+func (r *Router) AddRoute(domains []string, passthrough bool, handler Handler) error {
+	if err := r.CheckRoute(domains); err != nil {
+		return err
+	}
+
+	newRoute := &route{handler: handler, passthrough: passthrough}
+	if len(domains) == 0 {
+		r.catchAll = newRoute
+		return nil
+	}
+	for _, domain := range domains {
+		r.routes[strings.ToLower(domain)] = newRoute
+	}
+	return nil
+}
+
+// CheckRoute returns an error if a route for the given domains, or a catch-all route if no domain is given,
+// cannot be added
+func (r *Router) CheckRoute(domains []string) error {
+	if len(domains) == 0 {
+		if r.catchAll != nil {
+			return errors.New("catch-all route already defined")
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if _, exists := r.routes[domain]; exists || seen[domain] {
+			return fmt.Errorf("route already defined for domain %s", domain)
+		}
+		seen[domain] = true
+	}
+	return nil
+}
+
+// ServeTCP routes the connection
+func (r *Router) ServeTCP(conn net.Conn) {
+	// without SNI routes, nothing has to be read before forwarding the connection,
+	// which allows the protocols where the server speaks first
+	if len(r.routes) == 0 {
+		if r.catchAll == nil {
+			conn.Close()
+			return
+		}
+		r.serve(r.catchAll, conn)
+		return
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(clientHelloTimeout)); err != nil {
+		log.Errorf("Error while setting read deadline: %v", err)
+		conn.Close()
+		return
+	}
+
+	br := bufio.NewReader(conn)
+	serverName, peeked := clientHelloServerName(conn, br)
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		log.Errorf("Error while resetting read deadline: %v", err)
+		conn.Close()
+		return
+	}
+
+	selected := r.match(serverName)
+	if selected == nil {
+		log.Debugf("No TCP route found for server name %q", serverName)
+		conn.Close()
+		return
+	}
+
+	r.serve(selected, &prefixedConn{Conn: conn, reader: io.MultiReader(peeked, br)})
+}
+
+func (r *Router) match(serverName string) *route {
+	if len(serverName) == 0 {
+		return r.catchAll
+	}
+
+	domain := strings.ToLower(serverName)
+	if selected, ok := r.routes[domain]; ok {
+		return selected
+	}
+
+	labels := strings.Split(domain, ".")
+	labels[0] = "*"
+	if selected, ok := r.routes[strings.Join(labels, ".")]; ok {
+		return selected
+	}
+
+	return r.catchAll
+}
+
+func (r *Router) serve(selected *route, conn net.Conn) {
+	if !selected.passthrough && r.tlsConfig != nil {
+		conn = tls.Server(conn, r.tlsConfig)
+	}
+	selected.handler.ServeTCP(conn)
+}
+
+// clientHelloServerName reads the TLS ClientHello of the connection, if any, and
+// returns the server name it contains, along with the bytes read.
+func clientHelloServerName(conn net.Conn, br *bufio.Reader) (string, io.Reader) {
+	peeked := new(bytes.Buffer)
+
+	header, err := br.Peek(1)
+	if err != nil || header[0] != recordTypeHandshake {
+		return "", peeked
+	}
+
+	var serverName string
+	tls.Server(&readOnlyConn{Conn: conn, reader: io.TeeReader(br, peeked)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errClientHelloRead
+		},
+	}).Handshake()
+
+	return serverName, peeked
+}
+
+// prefixedConn is a net.Conn replaying the bytes read during the routing before the remaining ones.
+type prefixedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *prefixedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// CloseWrite closes the write side of the underlying connection, if supported.
+func (c *prefixedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// readOnlyConn is a net.Conn discarding the writes, used to read the ClientHello without answering it.
+type readOnlyConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *readOnlyConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *readOnlyConn) Write(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
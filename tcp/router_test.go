@@ -0,0 +1,131 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterServeTCP(t *testing.T) {
+	comCert, err := tls.LoadX509KeyPair("../integration/fixtures/https/snitest.com.cert", "../integration/fixtures/https/snitest.com.key")
+	require.NoError(t, err)
+	orgCert, err := tls.LoadX509KeyPair("../integration/fixtures/https/snitest.org.cert", "../integration/fixtures/https/snitest.org.key")
+	require.NoError(t, err)
+
+	// answers with its name, terminating TLS itself when a certificate is given
+	backend := func(name string, cert *tls.Certificate) Handler {
+		return HandlerFunc(func(conn net.Conn) {
+			defer conn.Close()
+			if cert != nil {
+				conn = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*cert}})
+			}
+			conn.Write([]byte(name))
+		})
+	}
+
+	testCases := []struct {
+		desc       string
+		tlsConfig  *tls.Config
+		routes     func(router *Router)
+		serverName string
+		expected   string
+	}{
+		{
+			desc: "catch-all without TLS",
+			routes: func(router *Router) {
+				router.AddRoute(nil, false, backend("catch-all", nil))
+			},
+			expected: "catch-all",
+		},
+		{
+			desc: "SNI passthrough",
+			routes: func(router *Router) {
+				router.AddRoute([]string{"snitest.com"}, true, backend("snitest.com", &comCert))
+				router.AddRoute(nil, true, backend("catch-all", &orgCert))
+			},
+			serverName: "snitest.com",
+			expected:   "snitest.com",
+		},
+		{
+			desc: "unknown SNI passthrough to the catch-all route",
+			routes: func(router *Router) {
+				router.AddRoute([]string{"snitest.com"}, true, backend("snitest.com", &comCert))
+				router.AddRoute(nil, true, backend("catch-all", &orgCert))
+			},
+			serverName: "unknown.com",
+			expected:   "catch-all",
+		},
+		{
+			desc:      "SNI with TLS termination",
+			tlsConfig: &tls.Config{Certificates: []tls.Certificate{orgCert}},
+			routes: func(router *Router) {
+				router.AddRoute([]string{"snitest.com"}, true, backend("snitest.com", &comCert))
+				router.AddRoute([]string{"snitest.org"}, false, backend("snitest.org", nil))
+			},
+			serverName: "snitest.org",
+			expected:   "snitest.org",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			router := NewRouter(test.tlsConfig)
+			test.routes(router)
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer listener.Close()
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				router.ServeTCP(conn)
+			}()
+
+			var conn net.Conn
+			if len(test.serverName) > 0 {
+				conn, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+					ServerName:         test.serverName,
+					InsecureSkipVerify: true,
+				})
+			} else {
+				conn, err = net.Dial("tcp", listener.Addr().String())
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+
+			response, err := ioutil.ReadAll(conn)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(response))
+		})
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	router := NewRouter(nil)
+	exact := HandlerFunc(func(conn net.Conn) {})
+	wildcard := HandlerFunc(func(conn net.Conn) {})
+	require.NoError(t, router.AddRoute([]string{"Foo.Bar.com"}, true, exact))
+	require.NoError(t, router.AddRoute([]string{"*.bar.com"}, true, wildcard))
+
+	assert.Error(t, router.AddRoute([]string{"foo.bar.com"}, true, exact))
+	// nothing is added when one of the domains already has a route
+	assert.Error(t, router.AddRoute([]string{"baz.com", "foo.bar.com"}, true, exact))
+	assert.Error(t, router.AddRoute([]string{"qux.com", "Qux.com"}, true, exact))
+	assert.NoError(t, router.CheckRoute([]string{"baz.com", "qux.com"}))
+	assert.NoError(t, router.AddRoute(nil, true, exact))
+	assert.Error(t, router.AddRoute(nil, true, exact))
+
+	assert.True(t, router.routes["foo.bar.com"] == router.match("FOO.bar.com"))
+	assert.True(t, router.routes["*.bar.com"] == router.match("baz.bar.com"))
+	assert.True(t, router.catchAll == router.match("bar.com"))
+	assert.True(t, router.catchAll == router.match(""))
+}
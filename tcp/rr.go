@@ -0,0 +1,53 @@
+package tcp
+
+import (
+	"net"
+	"sync"
+
+	"github.com/containous/traefik/log"
+)
+
+// RRLoadBalancer spreads the TCP connections over its servers in turn
+type RRLoadBalancer struct {
+	servers []Handler
+	current int
+	lock    sync.Mutex
+}
+
+// NewRRLoadBalancer creates a new RRLoadBalancer
+func NewRRLoadBalancer() *RRLoadBalancer {
+	return &RRLoadBalancer{}
+}
+
+// AddServer adds a server to the load balancer
+func (b *RRLoadBalancer) AddServer(server Handler) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.servers = append(b.servers, server)
+}
+
+// ServeTCP forwards the connection to the next server
+func (b *RRLoadBalancer) ServeTCP(conn net.Conn) {
+	next := b.next()
+	if next == nil {
+		log.Error("No TCP server available")
+		conn.Close()
+		return
+	}
+	next.ServeTCP(conn)
+}
+
+func (b *RRLoadBalancer) next() Handler {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.servers) == 0 {
+		return nil
+	}
+	if b.current >= len(b.servers) {
+		b.current = 0
+	}
+	next := b.servers[b.current]
+	b.current++
+	return next
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+)
+
+const (
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+	// maxQueuedBatches bounds the spans kept in memory when the backend is unreachable
+	maxQueuedBatches = 4
+)
+
+// batchExporter queues the finished spans and exports them in batches,
+// once the batch is full or every flush interval.
+type batchExporter struct {
+	serviceName string
+	exporter    Exporter
+	batchSize   int
+	mutex       sync.Mutex
+	spans       []*Span
+	flushChan   chan struct{}
+	stopChan    chan struct{}
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+func newBatchExporter(serviceName string, exporter Exporter, batchSize int, flushInterval string) *batchExporter {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	interval := defaultFlushInterval
+	if len(flushInterval) > 0 {
+		var err error
+		interval, err = time.ParseDuration(flushInterval)
+		if err != nil || interval <= 0 {
+			log.Warnf("Unable to parse %s into flushInterval, using %s as default value", flushInterval, defaultFlushInterval)
+			interval = defaultFlushInterval
+		}
+	}
+
+	b := &batchExporter{
+		serviceName: serviceName,
+		exporter:    exporter,
+		batchSize:   batchSize,
+		flushChan:   make(chan struct{}, 1),
+		stopChan:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	ticker := time.NewTicker(interval)
+	safe.Go(func() {
+		defer close(b.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.flush()
+			case <-b.flushChan:
+				b.flush()
+			case <-b.stopChan:
+				b.flush()
+				return
+			}
+		}
+	})
+	return b
+}
+
+func (b *batchExporter) add(span *Span) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.spans) >= maxQueuedBatches*b.batchSize {
+		log.Debugf("Dropping span %s: too many spans waiting to be exported", span.Name)
+		return
+	}
+	b.spans = append(b.spans, span)
+	if len(b.spans)%b.batchSize == 0 {
+		select {
+		case b.flushChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *batchExporter) flush() {
+	b.mutex.Lock()
+	spans := b.spans
+	b.spans = nil
+	b.mutex.Unlock()
+
+	for len(spans) > 0 {
+		size := b.batchSize
+		if size > len(spans) {
+			size = len(spans)
+		}
+		if err := b.exporter.Export(b.serviceName, spans[:size]); err != nil {
+			log.Warnf("Error exporting %d spans: %v", size, err)
+		}
+		spans = spans[size:]
+	}
+}
+
+func (b *batchExporter) stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopChan)
+	})
+	<-b.done
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// EntryPointMiddleware is a Negroni compatible Handler starting the server span of the requests
+// received by an entrypoint, child of the span context sent by the client if any.
+type EntryPointMiddleware struct {
+	tracer         *Tracer
+	entryPointName string
+}
+
+// NewEntryPointMiddleware returns an EntryPointMiddleware struct for the entrypoint
+func NewEntryPointMiddleware(tracer *Tracer, entryPointName string) *EntryPointMiddleware {
+	return &EntryPointMiddleware{tracer: tracer, entryPointName: entryPointName}
+}
+
+func (m *EntryPointMiddleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx := m.tracer.Extract(r.Context(), r.Header)
+	ctx, span := m.tracer.StartSpan(ctx, "entrypoint "+m.entryPointName, SpanKindServer)
+	defer span.Finish()
+
+	span.SetAttribute("traefik.entrypoint", m.entryPointName)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.host", r.Host)
+	span.SetAttribute("http.url", r.URL.String())
+
+	recorder := &statusRecorder{ResponseWriter: rw, statusCode: http.StatusOK}
+	next(recorder, r.WithContext(ctx))
+	setStatusCode(span, recorder.statusCode)
+}
+
+// FrontendMiddleware is a Negroni compatible Handler starting a span
+// around the middleware chain and the backend of a frontend.
+type FrontendMiddleware struct {
+	tracer       *Tracer
+	frontendName string
+}
+
+// NewFrontendMiddleware returns a FrontendMiddleware struct for the frontend
+func NewFrontendMiddleware(tracer *Tracer, frontendName string) *FrontendMiddleware {
+	return &FrontendMiddleware{tracer: tracer, frontendName: frontendName}
+}
+
+func (m *FrontendMiddleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx, span := m.tracer.StartSpan(r.Context(), "frontend "+m.frontendName, SpanKindInternal)
+	defer span.Finish()
+
+	span.SetAttribute("traefik.frontend", m.frontendName)
+
+	recorder := &statusRecorder{ResponseWriter: rw, statusCode: http.StatusOK}
+	next(recorder, r.WithContext(ctx))
+	setStatusCode(span, recorder.statusCode)
+}
+
+// Transport is a http.RoundTripper starting the client span of the requests forwarded to a backend,
+// and propagating its context to the servers.
+type Transport struct {
+	tracer      *Tracer
+	backendName string
+	next        http.RoundTripper
+}
+
+// NewTransport returns a Transport forwarding the requests of the backend with next
+func NewTransport(tracer *Tracer, backendName string, next http.RoundTripper) *Transport {
+	return &Transport{tracer: tracer, backendName: backendName, next: next}
+}
+
+// RoundTrip forwards the request in a client span
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.StartSpan(req.Context(), "forward "+t.backendName, SpanKindClient)
+	defer span.Finish()
+
+	span.SetAttribute("traefik.backend", t.backendName)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+
+	outReq := req.WithContext(ctx)
+	outReq.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		outReq.Header[name] = values
+	}
+	t.tracer.Inject(ctx, outReq.Header)
+
+	resp, err := t.next.RoundTrip(outReq)
+	if err != nil {
+		span.SetError(err.Error())
+		return nil, err
+	}
+	setStatusCode(span, resp.StatusCode)
+	return resp, nil
+}
+
+func setStatusCode(span *Span, statusCode int) {
+	span.SetAttribute("http.status_code", strconv.Itoa(statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetError(http.StatusText(statusCode))
+	}
+}
+
+// statusRecorder captures the status code of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader captures the status code for later retrieval.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.ResponseWriter.WriteHeader(status)
+	r.statusCode = status
+}
+
+// Hijack hijacks the connection
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("Not a hijacker: %T", r.ResponseWriter)
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (r *statusRecorder) CloseNotify() <-chan bool {
+	if c, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
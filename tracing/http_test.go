@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+type recordingExporter struct {
+	mutex sync.Mutex
+	spans map[string]*Span
+}
+
+func (e *recordingExporter) Export(serviceName string, spans []*Span) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, span := range spans {
+		e.spans[span.Name] = span
+	}
+	return nil
+}
+
+func TestTracingMiddlewares(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		traceParent     string
+		backendStatus   int
+		expectedSampled bool
+		expectedError   bool
+	}{
+		{
+			desc:            "new trace",
+			backendStatus:   http.StatusOK,
+			expectedSampled: true,
+		},
+		{
+			desc:            "trace propagated by the client",
+			traceParent:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			backendStatus:   http.StatusOK,
+			expectedSampled: true,
+		},
+		{
+			desc:          "trace not sampled by the client",
+			traceParent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			backendStatus: http.StatusOK,
+		},
+		{
+			desc:            "backend error",
+			backendStatus:   http.StatusBadGateway,
+			expectedSampled: true,
+			expectedError:   true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var backendTraceParent string
+			backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				backendTraceParent = r.Header.Get(TraceParentHeader)
+				rw.WriteHeader(test.backendStatus)
+			}))
+			defer backend.Close()
+
+			exporter := &recordingExporter{spans: make(map[string]*Span)}
+			tracer := newTracer("test", W3CPropagator{}, constSampler(true), exporter, 10, "1h")
+
+			transport := NewTransport(tracer, "backend1", http.DefaultTransport)
+			forward := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				outReq, err := http.NewRequest(http.MethodGet, backend.URL, nil)
+				require.NoError(t, err)
+				resp, err := transport.RoundTrip(outReq.WithContext(r.Context()))
+				require.NoError(t, err)
+				resp.Body.Close()
+				rw.WriteHeader(resp.StatusCode)
+			})
+			frontend := negroni.New(NewFrontendMiddleware(tracer, "frontend1"))
+			frontend.UseHandler(forward)
+			handler := negroni.New(NewEntryPointMiddleware(tracer, "http"))
+			handler.UseHandler(frontend)
+
+			req := httptest.NewRequest(http.MethodGet, "http://foo.com/bar", nil)
+			if len(test.traceParent) > 0 {
+				req.Header.Set(TraceParentHeader, test.traceParent)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			tracer.Close()
+
+			assert.Equal(t, test.backendStatus, recorder.Code)
+			require.NotEmpty(t, backendTraceParent)
+			backendSpanContext, ok := W3CPropagator{}.Extract(http.Header{http.CanonicalHeaderKey(TraceParentHeader): {backendTraceParent}})
+			require.True(t, ok)
+			assert.Equal(t, test.expectedSampled, backendSpanContext.Sampled)
+
+			if !test.expectedSampled {
+				assert.Empty(t, exporter.spans)
+				return
+			}
+			require.Len(t, exporter.spans, 3)
+			entryPointSpan := exporter.spans["entrypoint http"]
+			frontendSpan := exporter.spans["frontend frontend1"]
+			forwardSpan := exporter.spans["forward backend1"]
+			require.NotNil(t, entryPointSpan)
+			require.NotNil(t, frontendSpan)
+			require.NotNil(t, forwardSpan)
+
+			if len(test.traceParent) > 0 {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entryPointSpan.Context.TraceID.String())
+				assert.Equal(t, "00f067aa0ba902b7", entryPointSpan.ParentID.String())
+			} else {
+				assert.Equal(t, SpanID{}, entryPointSpan.ParentID)
+			}
+			assert.Equal(t, SpanKindServer, entryPointSpan.Kind)
+			assert.Equal(t, entryPointSpan.Context.SpanID, frontendSpan.ParentID)
+			assert.Equal(t, frontendSpan.Context.SpanID, forwardSpan.ParentID)
+			assert.Equal(t, SpanKindClient, forwardSpan.Kind)
+			assert.Equal(t, forwardSpan.Context.TraceID, backendSpanContext.TraceID)
+			assert.Equal(t, forwardSpan.Context.SpanID, backendSpanContext.SpanID)
+
+			assert.Equal(t, "GET", entryPointSpan.Attributes["http.method"])
+			assert.Equal(t, "foo.com", entryPointSpan.Attributes["http.host"])
+			assert.Equal(t, backend.URL, forwardSpan.Attributes["http.url"])
+			if test.expectedError {
+				assert.NotEmpty(t, entryPointSpan.Error)
+				assert.NotEmpty(t, forwardSpan.Error)
+			} else {
+				assert.Empty(t, entryPointSpan.Error)
+				assert.Empty(t, forwardSpan.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// JaegerName is the name of the Jaeger tracing backend
+const JaegerName = "jaeger"
+
+// The propagation formats of the Jaeger backend
+const (
+	JaegerPropagationFormat = "jaeger"
+	W3CPropagationFormat    = "w3c"
+)
+
+const defaultJaegerCollectorEndpoint = "http://localhost:4318"
+
+// JaegerConfig holds the configuration of the Jaeger exporter
+type JaegerConfig struct {
+	SamplingType      string  `description:"Set the sampling type (const, probabilistic or ratelimiting)" export:"true"`
+	SamplingParam     float64 `description:"Set the sampling parameter: 0 or 1 with const, the ratio of the traces with probabilistic, the traces per second with ratelimiting" export:"true"`
+	ServiceName       string  `description:"Override the name of the service in Jaeger" export:"true"`
+	CollectorEndpoint string  `description:"OTLP/HTTP endpoint of the Jaeger collector" export:"true"`
+	Propagation       string  `description:"Format of the trace context headers (jaeger or w3c)" export:"true"`
+	BatchSize         int     `description:"Maximum number of spans exported at once" export:"true"`
+	FlushInterval     string  `description:"Interval between two exports of the spans" export:"true"`
+}
+
+func newJaegerTracer(serviceName string, config *JaegerConfig) (*Tracer, error) {
+	if len(config.ServiceName) > 0 {
+		serviceName = config.ServiceName
+	}
+
+	samplingType, samplingParam := config.SamplingType, config.SamplingParam
+	if len(samplingType) == 0 {
+		samplingType, samplingParam = SamplerTypeConst, 1
+	}
+	sampler, err := NewSampler(samplingType, samplingParam)
+	if err != nil {
+		return nil, err
+	}
+
+	var propagator Propagator
+	switch config.Propagation {
+	case JaegerPropagationFormat, "":
+		propagator = JaegerPropagator{}
+	case W3CPropagationFormat:
+		propagator = W3CPropagator{}
+	default:
+		return nil, fmt.Errorf("unknown Jaeger propagation format %q", config.Propagation)
+	}
+
+	endpoint := config.CollectorEndpoint
+	if len(endpoint) == 0 {
+		endpoint = defaultJaegerCollectorEndpoint
+	}
+	exporter, err := newOTLPExporter(&OTLPConfig{Endpoint: endpoint})
+	if err != nil {
+		return nil, err
+	}
+	return newTracer(serviceName, propagator, sampler, exporter, config.BatchSize, config.FlushInterval), nil
+}
+
+// JaegerTraceHeader is the header holding the span context in the Jaeger format
+const JaegerTraceHeader = "uber-trace-id"
+
+// JaegerPropagator propagates the span context in the uber-trace-id header
+type JaegerPropagator struct{}
+
+// Extract reads the span context in the trace-id:span-id:parent-span-id:flags format
+func (JaegerPropagator) Extract(header http.Header) (SpanContext, bool) {
+	value, err := url.QueryUnescape(header.Get(JaegerTraceHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var spanContext SpanContext
+	if !decodeJaegerID(spanContext.TraceID[:], parts[0]) || !decodeJaegerID(spanContext.SpanID[:], parts[1]) {
+		return SpanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	spanContext.Sampled = flags&0x01 == 0x01
+	return spanContext, true
+}
+
+// Inject writes the span context in the uber-trace-id header
+func (JaegerPropagator) Inject(spanContext SpanContext, header http.Header) {
+	flags := "0"
+	if spanContext.Sampled {
+		flags = "1"
+	}
+	header.Set(JaegerTraceHeader, spanContext.TraceID.String()+":"+spanContext.SpanID.String()+":0:"+flags)
+}
+
+// decodeJaegerID decodes the hexadecimal ID, whose leading zeros may be omitted
+func decodeJaegerID(id []byte, value string) bool {
+	value = strings.ToLower(value)
+	if len(value) == 0 || len(value) > 2*len(id) {
+		return false
+	}
+	return decodeID(id, strings.Repeat("0", 2*len(id)-len(value))+value)
+}
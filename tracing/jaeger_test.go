@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJaegerPropagator(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		value           string
+		expectedOk      bool
+		expectedTraceID string
+		expectedSampled bool
+	}{
+		{
+			desc:            "128 bits trace ID",
+			value:           "4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:1",
+			expectedOk:      true,
+			expectedTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			expectedSampled: true,
+		},
+		{
+			desc:            "64 bits trace ID without leading zeros",
+			value:           "a3ce929d0e0e4736:f067aa0ba902b7:4bf92f3577b34da6:0",
+			expectedOk:      true,
+			expectedTraceID: "0000000000000000a3ce929d0e0e4736",
+		},
+		{
+			desc:            "URL encoded value",
+			value:           "a3ce929d0e0e4736%3A00f067aa0ba902b7%3A0%3A3",
+			expectedOk:      true,
+			expectedTraceID: "0000000000000000a3ce929d0e0e4736",
+			expectedSampled: true,
+		},
+		{
+			desc: "missing header",
+		},
+		{
+			desc:  "missing flags",
+			value: "a3ce929d0e0e4736:00f067aa0ba902b7:0",
+		},
+		{
+			desc:  "zero span ID",
+			value: "a3ce929d0e0e4736:0:0:1",
+		},
+		{
+			desc:  "invalid flags",
+			value: "a3ce929d0e0e4736:00f067aa0ba902b7:0:x",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			if len(test.value) > 0 {
+				header.Set(JaegerTraceHeader, test.value)
+			}
+
+			spanContext, ok := JaegerPropagator{}.Extract(header)
+
+			assert.Equal(t, test.expectedOk, ok)
+			if test.expectedOk {
+				assert.Equal(t, test.expectedTraceID, spanContext.TraceID.String())
+				assert.Equal(t, "00f067aa0ba902b7", spanContext.SpanID.String())
+				assert.Equal(t, test.expectedSampled, spanContext.Sampled)
+
+				injected := http.Header{}
+				JaegerPropagator{}.Inject(spanContext, injected)
+				extracted, ok := JaegerPropagator{}.Extract(injected)
+				assert.True(t, ok)
+				assert.Equal(t, spanContext, extracted)
+			}
+		})
+	}
+}
+
+func TestJaegerTracer(t *testing.T) {
+	var serviceNames []string
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var body otlpTraces
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		for _, resourceSpans := range body.ResourceSpans {
+			serviceNames = append(serviceNames, resourceSpans.Resource.Attributes[0].Value.StringValue)
+		}
+	}))
+	defer collector.Close()
+
+	tracer, err := NewTracer(&Config{
+		Backend:     JaegerName,
+		ServiceName: "traefik",
+		Jaeger: &JaegerConfig{
+			SamplingType:      SamplerTypeConst,
+			SamplingParam:     1,
+			ServiceName:       "edge-proxy",
+			CollectorEndpoint: collector.URL,
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, span := tracer.StartSpan(context.Background(), "span", SpanKindServer)
+	header := http.Header{}
+	tracer.Inject(ctx, header)
+	span.Finish()
+	tracer.Close()
+
+	assert.Equal(t, span.Context.TraceID.String()+":"+span.Context.SpanID.String()+":0:1", header.Get(JaegerTraceHeader))
+	assert.Equal(t, []string{"edge-proxy"}, serviceNames)
+}
+
+func TestNewJaegerTracerWithInvalidConfig(t *testing.T) {
+	_, err := NewTracer(&Config{Backend: JaegerName, Jaeger: &JaegerConfig{SamplingType: "foo"}})
+	assert.Error(t, err)
+
+	_, err = NewTracer(&Config{Backend: JaegerName, Jaeger: &JaegerConfig{Propagation: "foo"}})
+	assert.Error(t, err)
+}
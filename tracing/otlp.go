@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultOTLPEndpoint = "http://localhost:4318"
+
+// OTLPConfig holds the configuration of the OpenTelemetry (OTLP/HTTP) exporter
+type OTLPConfig struct {
+	Endpoint      string `description:"OTLP/HTTP collector endpoint, the spans being sent to its /v1/traces path" export:"true"`
+	BatchSize     int    `description:"Maximum number of spans exported at once" export:"true"`
+	FlushInterval string `description:"Interval between two exports of the spans" export:"true"`
+}
+
+// otlpExporter sends the spans in the JSON encoding of OTLP/HTTP
+type otlpExporter struct {
+	tracesURL string
+	client    *http.Client
+}
+
+func newOTLPExporter(config *OTLPConfig) (*otlpExporter, error) {
+	endpoint := config.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = defaultOTLPEndpoint
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %s: %v", endpoint, err)
+	}
+	return &otlpExporter{
+		tracesURL: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpStatusError is the status code of the failed spans
+const otlpStatusError = 2
+
+// Export posts the spans to the /v1/traces path of the collector
+func (e *otlpExporter) Export(serviceName string, spans []*Span) error {
+	body, err := json.Marshal(newOTLPTraces(serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.tracesURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d sending the spans to %s", resp.StatusCode, e.tracesURL)
+	}
+	return nil
+}
+
+func newOTLPTraces(serviceName string, spans []*Span) otlpTraces {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mutex.Lock()
+		otlpSpan := otlpSpan{
+			TraceID:           span.Context.TraceID.String(),
+			SpanID:            span.Context.SpanID.String(),
+			TraceState:        span.Context.TraceState,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        newOTLPAttributes(span.Attributes),
+		}
+		if span.ParentID != (SpanID{}) {
+			otlpSpan.ParentSpanID = span.ParentID.String()
+		}
+		if len(span.Error) > 0 {
+			otlpSpan.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+		span.mutex.Unlock()
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: newOTLPAttributes(map[string]string{"service.name": serviceName})},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "traefik"}, Spans: otlpSpans}},
+		}},
+	}
+}
+
+func newOTLPAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	otlpAttributes := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		otlpAttributes = append(otlpAttributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: attributes[key]}})
+	}
+	return otlpAttributes
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var traces []otlpTraces
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body otlpTraces
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		traces = append(traces, body)
+	}))
+	defer collector.Close()
+
+	tracer, err := NewTracer(&Config{
+		Backend:     OTLPName,
+		ServiceName: "proxy",
+		OTLP:        &OTLPConfig{Endpoint: collector.URL + "/", BatchSize: 2, FlushInterval: "1h"},
+	})
+	require.NoError(t, err)
+
+	ctx, parent := tracer.StartSpan(context.Background(), "parent", SpanKindServer)
+	_, child := tracer.StartSpan(ctx, "child", SpanKindClient)
+	child.SetAttribute("http.method", "GET")
+	child.SetError("Bad Gateway")
+	child.Finish()
+	parent.Finish()
+	_, other := tracer.StartSpan(context.Background(), "other", SpanKindInternal)
+	other.Finish()
+	tracer.Close()
+
+	// the spans are exported in batches of two
+	require.Len(t, traces, 2)
+	require.Len(t, traces[0].ResourceSpans, 1)
+	resourceSpans := traces[0].ResourceSpans[0]
+	assert.Equal(t, []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: "proxy"}}}, resourceSpans.Resource.Attributes)
+	require.Len(t, resourceSpans.ScopeSpans, 1)
+	spans := resourceSpans.ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, SpanKindClient, spans[0].Kind)
+	assert.Equal(t, parent.Context.TraceID.String(), spans[0].TraceID)
+	assert.Equal(t, parent.Context.SpanID.String(), spans[0].ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusError, Message: "Bad Gateway"}, spans[0].Status)
+	assert.Equal(t, []otlpAttribute{{Key: "http.method", Value: otlpValue{StringValue: "GET"}}}, spans[0].Attributes)
+	assert.NotEmpty(t, spans[0].StartTimeUnixNano)
+
+	assert.Equal(t, "parent", spans[1].Name)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Equal(t, otlpStatus{}, spans[1].Status)
+
+	require.Len(t, traces[1].ResourceSpans[0].ScopeSpans[0].Spans, 1)
+	assert.Equal(t, "other", traces[1].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+}
+
+func TestNewTracerWithUnknownBackend(t *testing.T) {
+	_, err := NewTracer(&Config{Backend: "foo"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Propagator reads and writes the span context in the headers of the requests
+type Propagator interface {
+	Extract(header http.Header) (SpanContext, bool)
+	Inject(spanContext SpanContext, header http.Header)
+}
+
+// The W3C Trace Context headers
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// W3CPropagator propagates the span context in the W3C Trace Context headers
+type W3CPropagator struct{}
+
+// Extract reads the span context in the traceparent and tracestate headers
+func (W3CPropagator) Extract(header http.Header) (SpanContext, bool) {
+	// version-traceid-parentid-flags, the later versions may append fields
+	parts := strings.Split(strings.TrimSpace(header.Get(TraceParentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var spanContext SpanContext
+	if !decodeID(spanContext.TraceID[:], parts[1]) || !decodeID(spanContext.SpanID[:], parts[2]) {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, false
+	}
+	spanContext.Sampled = flags[0]&0x01 == 0x01
+	spanContext.TraceState = strings.Join(header[http.CanonicalHeaderKey(TraceStateHeader)], ",")
+	return spanContext, true
+}
+
+// Inject writes the span context in the traceparent and tracestate headers
+func (W3CPropagator) Inject(spanContext SpanContext, header http.Header) {
+	flags := "00"
+	if spanContext.Sampled {
+		flags = "01"
+	}
+	header.Set(TraceParentHeader, "00-"+spanContext.TraceID.String()+"-"+spanContext.SpanID.String()+"-"+flags)
+	if len(spanContext.TraceState) > 0 {
+		header.Set(TraceStateHeader, spanContext.TraceState)
+	} else {
+		header.Del(TraceStateHeader)
+	}
+}
+
+// decodeID decodes the lowercase hexadecimal ID into id, an ID made of zeros being invalid
+func decodeID(id []byte, value string) bool {
+	if len(value) != 2*len(id) || strings.ToLower(value) != value {
+		return false
+	}
+	if _, err := hex.Decode(id, []byte(value)); err != nil {
+		return false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
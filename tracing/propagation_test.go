@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestW3CPropagatorExtract(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		traceParent     string
+		traceState      string
+		expectedOk      bool
+		expectedSampled bool
+	}{
+		{
+			desc:            "sampled trace",
+			traceParent:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			traceState:      "vendor=value",
+			expectedOk:      true,
+			expectedSampled: true,
+		},
+		{
+			desc:        "not sampled trace",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			expectedOk:  true,
+		},
+		{
+			desc:            "future version with more fields",
+			traceParent:     "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			expectedOk:      true,
+			expectedSampled: true,
+		},
+		{
+			desc: "missing header",
+		},
+		{
+			desc:        "invalid version",
+			traceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			desc:        "zero trace ID",
+			traceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			desc:        "uppercase span ID",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01",
+		},
+		{
+			desc:        "too short trace ID",
+			traceParent: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			if len(test.traceParent) > 0 {
+				header.Set(TraceParentHeader, test.traceParent)
+			}
+			if len(test.traceState) > 0 {
+				header.Set(TraceStateHeader, test.traceState)
+			}
+
+			spanContext, ok := W3CPropagator{}.Extract(header)
+
+			assert.Equal(t, test.expectedOk, ok)
+			if test.expectedOk {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID.String())
+				assert.Equal(t, "00f067aa0ba902b7", spanContext.SpanID.String())
+				assert.Equal(t, test.expectedSampled, spanContext.Sampled)
+				assert.Equal(t, test.traceState, spanContext.TraceState)
+			}
+		})
+	}
+}
+
+func TestW3CPropagatorInject(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceStateHeader, "stale=value")
+	spanContext, ok := W3CPropagator{}.Extract(http.Header{http.CanonicalHeaderKey(TraceParentHeader): {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}})
+	assert.True(t, ok)
+
+	W3CPropagator{}.Inject(spanContext, header)
+
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", header.Get(TraceParentHeader))
+	assert.Empty(t, header.Get(TraceStateHeader))
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// The sampling types of the traces started by Traefik
+const (
+	SamplerTypeConst         = "const"
+	SamplerTypeProbabilistic = "probabilistic"
+	SamplerTypeRateLimiting  = "ratelimiting"
+)
+
+// Sampler decides whether the traces started by Traefik are sampled,
+// the decision of the client being kept for the propagated traces.
+type Sampler interface {
+	Sample(traceID TraceID) bool
+}
+
+// NewSampler returns the sampler of the type configured with param
+func NewSampler(samplerType string, param float64) (Sampler, error) {
+	switch samplerType {
+	case SamplerTypeConst:
+		return constSampler(param != 0), nil
+	case SamplerTypeProbabilistic:
+		if param < 0 || param > 1 {
+			return nil, fmt.Errorf("the probabilistic sampling parameter must be between 0 and 1, got %v", param)
+		}
+		return probabilisticSampler{boundary: uint64(param * (1 << 63))}, nil
+	case SamplerTypeRateLimiting:
+		if param < 0 {
+			return nil, fmt.Errorf("the rate limiting sampling parameter must be positive, got %v", param)
+		}
+		return newRateLimitingSampler(param), nil
+	default:
+		return nil, fmt.Errorf("unknown sampling type %q", samplerType)
+	}
+}
+
+type constSampler bool
+
+func (s constSampler) Sample(TraceID) bool {
+	return bool(s)
+}
+
+// probabilisticSampler samples the traces whose random 63 bits ID is lower than the boundary
+type probabilisticSampler struct {
+	boundary uint64
+}
+
+func (s probabilisticSampler) Sample(traceID TraceID) bool {
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < s.boundary
+}
+
+// rateLimitingSampler samples up to tracesPerSecond traces per second, using a token bucket
+type rateLimitingSampler struct {
+	mutex           sync.Mutex
+	tracesPerSecond float64
+	maxBalance      float64
+	balance         float64
+	lastTick        time.Time
+	now             func() time.Time
+}
+
+func newRateLimitingSampler(tracesPerSecond float64) *rateLimitingSampler {
+	maxBalance := math.Max(tracesPerSecond, 1)
+	return &rateLimitingSampler{
+		tracesPerSecond: tracesPerSecond,
+		maxBalance:      maxBalance,
+		balance:         maxBalance,
+		lastTick:        time.Now(),
+		now:             time.Now,
+	}
+}
+
+func (s *rateLimitingSampler) Sample(TraceID) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.balance = math.Min(s.maxBalance, s.balance+now.Sub(s.lastTick).Seconds()*s.tracesPerSecond)
+	s.lastTick = now
+	if s.balance < 1 {
+		return false
+	}
+	s.balance--
+	return true
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSampler(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		samplerType   string
+		param         float64
+		expectedRatio float64
+		expectedError bool
+	}{
+		{
+			desc:          "const sampling all the traces",
+			samplerType:   SamplerTypeConst,
+			param:         1,
+			expectedRatio: 1,
+		},
+		{
+			desc:          "const sampling no trace",
+			samplerType:   SamplerTypeConst,
+			param:         0,
+			expectedRatio: 0,
+		},
+		{
+			desc:          "probabilistic sampling all the traces",
+			samplerType:   SamplerTypeProbabilistic,
+			param:         1,
+			expectedRatio: 1,
+		},
+		{
+			desc:          "probabilistic sampling no trace",
+			samplerType:   SamplerTypeProbabilistic,
+			param:         0,
+			expectedRatio: 0,
+		},
+		{
+			desc:          "probabilistic sampling out of range",
+			samplerType:   SamplerTypeProbabilistic,
+			param:         1.5,
+			expectedError: true,
+		},
+		{
+			desc:          "negative rate limiting",
+			samplerType:   SamplerTypeRateLimiting,
+			param:         -1,
+			expectedError: true,
+		},
+		{
+			desc:          "unknown type",
+			samplerType:   "remote",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			sampler, err := NewSampler(test.samplerType, test.param)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var sampled int
+			for i := 0; i < 100; i++ {
+				if sampler.Sample(newTraceID()) {
+					sampled++
+				}
+			}
+			assert.Equal(t, test.expectedRatio, float64(sampled)/100)
+		})
+	}
+}
+
+func TestProbabilisticSampler(t *testing.T) {
+	sampler, err := NewSampler(SamplerTypeProbabilistic, 0.5)
+	require.NoError(t, err)
+
+	assert.True(t, sampler.Sample(TraceID{8: 0x10}))
+	assert.False(t, sampler.Sample(TraceID{8: 0xf0}))
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	now := time.Now()
+	sampler := newRateLimitingSampler(2)
+	sampler.lastTick = now
+	sampler.now = func() time.Time { return now }
+
+	assert.True(t, sampler.Sample(TraceID{}))
+	assert.True(t, sampler.Sample(TraceID{}))
+	assert.False(t, sampler.Sample(TraceID{}))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, sampler.Sample(TraceID{}))
+	assert.False(t, sampler.Sample(TraceID{}))
+}
@@ -0,0 +1,236 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// OTLPName is the name of the OpenTelemetry (OTLP) tracing backend
+const OTLPName = "otlp"
+
+// Config holds the tracing configuration
+type Config struct {
+	Backend     string        `description:"Selects the tracing backend ('otlp', 'jaeger' or 'zipkin')" export:"true"`
+	ServiceName string        `description:"Set the name for this service" export:"true"`
+	OTLP        *OTLPConfig   `description:"Settings for the OpenTelemetry (OTLP) exporter" export:"true"`
+	Jaeger      *JaegerConfig `description:"Settings for the Jaeger exporter" export:"true"`
+	Zipkin      *ZipkinConfig `description:"Settings for the Zipkin reporter" export:"true"`
+}
+
+// SpanKind is the role of a span in a trace
+type SpanKind int
+
+// The span kinds, numbered as in OTLP
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns the hexadecimal representation of the trace ID
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span
+type SpanID [8]byte
+
+// String returns the hexadecimal representation of the span ID
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated to the other services of a trace
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	// SamplingDeferred is set on the remote span contexts without sampling decision, left to the sampler
+	SamplingDeferred bool
+}
+
+// Span is a timed operation of a trace
+type Span struct {
+	tracer     *Tracer
+	mutex      sync.Mutex
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	ParentID   SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Error      string
+}
+
+// SetAttribute attaches the key/value pair to the span
+func (s *Span) SetAttribute(key, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Error = message
+}
+
+// Finish ends the span, and exports it if the trace is sampled
+func (s *Span) Finish() {
+	s.mutex.Lock()
+	s.End = time.Now()
+	s.mutex.Unlock()
+
+	if s.Context.Sampled {
+		s.tracer.exporter.add(s)
+	}
+}
+
+// Exporter sends the finished spans to a tracing backend
+type Exporter interface {
+	Export(serviceName string, spans []*Span) error
+}
+
+// Tracer creates the spans of the requests, propagates their context, and exports them
+type Tracer struct {
+	serviceName string
+	propagator  Propagator
+	sampler     Sampler
+	exporter    *batchExporter
+}
+
+// NewTracer creates the tracer of the configured backend
+func NewTracer(config *Config) (*Tracer, error) {
+	serviceName := config.ServiceName
+	if len(serviceName) == 0 {
+		serviceName = "traefik"
+	}
+
+	switch config.Backend {
+	case OTLPName, "":
+		otlpConfig := config.OTLP
+		if otlpConfig == nil {
+			otlpConfig = &OTLPConfig{}
+		}
+		exporter, err := newOTLPExporter(otlpConfig)
+		if err != nil {
+			return nil, err
+		}
+		return newTracer(serviceName, W3CPropagator{}, constSampler(true), exporter, otlpConfig.BatchSize, otlpConfig.FlushInterval), nil
+	case JaegerName:
+		jaegerConfig := config.Jaeger
+		if jaegerConfig == nil {
+			jaegerConfig = &JaegerConfig{}
+		}
+		return newJaegerTracer(serviceName, jaegerConfig)
+	case ZipkinName:
+		zipkinConfig := config.Zipkin
+		if zipkinConfig == nil {
+			zipkinConfig = &ZipkinConfig{SampleRate: 1}
+		}
+		return newZipkinTracer(serviceName, zipkinConfig)
+	default:
+		return nil, fmt.Errorf("unknown tracing backend %q", config.Backend)
+	}
+}
+
+func newTracer(serviceName string, propagator Propagator, sampler Sampler, exporter Exporter, batchSize int, flushInterval string) *Tracer {
+	return &Tracer{
+		serviceName: serviceName,
+		propagator:  propagator,
+		sampler:     sampler,
+		exporter:    newBatchExporter(serviceName, exporter, batchSize, flushInterval),
+	}
+}
+
+// Close exports the pending spans and stops the tracer
+func (t *Tracer) Close() {
+	t.exporter.stop()
+}
+
+type spanContextKey struct{}
+
+type remoteSpanContextKey struct{}
+
+// StartSpan starts a span, child of the span or of the remote span context of ctx,
+// and returns ctx holding the new span.
+func (t *Tracer) StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		tracer:     t,
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: make(map[string]string),
+	}
+
+	if parent, ok := SpanFromContext(ctx); ok {
+		span.Context = parent.Context
+		span.ParentID = parent.Context.SpanID
+	} else if remote, ok := ctx.Value(remoteSpanContextKey{}).(SpanContext); ok {
+		span.Context = remote
+		span.ParentID = remote.SpanID
+		if remote.SamplingDeferred {
+			span.Context.Sampled = t.sampler.Sample(remote.TraceID)
+			span.Context.SamplingDeferred = false
+		}
+	} else {
+		traceID := newTraceID()
+		span.Context = SpanContext{TraceID: traceID, Sampled: t.sampler.Sample(traceID)}
+	}
+	span.Context.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SpanFromContext returns the current span of ctx
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	span, ok := ctx.Value(spanContextKey{}).(*Span)
+	return span, ok
+}
+
+// Extract returns ctx holding the span context propagated in the headers, if any
+func (t *Tracer) Extract(ctx context.Context, header http.Header) context.Context {
+	spanContext, ok := t.propagator.Extract(header)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteSpanContextKey{}, spanContext)
+}
+
+// Inject propagates the context of the current span of ctx in the headers
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	if span, ok := SpanFromContext(ctx); ok {
+		t.propagator.Inject(span.Context, header)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	randomID(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	randomID(id[:])
+	return id
+}
+
+func randomID(id []byte) {
+	if _, err := rand.Read(id); err != nil {
+		log.Errorf("Unable to generate a tracing ID: %v", err)
+	}
+}
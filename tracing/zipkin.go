@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ZipkinName is the name of the Zipkin tracing backend
+const ZipkinName = "zipkin"
+
+// The B3 propagation formats of the Zipkin backend
+const (
+	B3MultiPropagationFormat  = "b3multi"
+	B3SinglePropagationFormat = "b3single"
+)
+
+const defaultZipkinHTTPEndpoint = "http://localhost:9411/api/v2/spans"
+
+// ZipkinConfig holds the configuration of the Zipkin reporter
+type ZipkinConfig struct {
+	HTTPEndpoint  string  `description:"HTTP endpoint the spans are reported to" export:"true"`
+	SampleRate    float64 `description:"Ratio of the traces started by Traefik, or received without sampling decision, which are sampled (0 to 1)" export:"true"`
+	Propagation   string  `description:"Format of the B3 headers (b3multi or b3single)" export:"true"`
+	BatchSize     int     `description:"Maximum number of spans reported at once" export:"true"`
+	FlushInterval string  `description:"Interval between two reports of the spans" export:"true"`
+}
+
+func newZipkinTracer(serviceName string, config *ZipkinConfig) (*Tracer, error) {
+	sampler, err := NewSampler(SamplerTypeProbabilistic, config.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	var propagator Propagator
+	switch config.Propagation {
+	case B3MultiPropagationFormat, "":
+		propagator = B3Propagator{}
+	case B3SinglePropagationFormat:
+		propagator = B3Propagator{SingleHeader: true}
+	default:
+		return nil, fmt.Errorf("unknown Zipkin propagation format %q", config.Propagation)
+	}
+
+	endpoint := config.HTTPEndpoint
+	if len(endpoint) == 0 {
+		endpoint = defaultZipkinHTTPEndpoint
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid Zipkin endpoint %s: %v", endpoint, err)
+	}
+	exporter := &zipkinReporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	return newTracer(serviceName, propagator, sampler, exporter, config.BatchSize, config.FlushInterval), nil
+}
+
+// The B3 headers
+const (
+	B3Header             = "b3"
+	B3TraceIDHeader      = "X-B3-TraceId"
+	B3SpanIDHeader       = "X-B3-SpanId"
+	B3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	B3SampledHeader      = "X-B3-Sampled"
+	B3FlagsHeader        = "X-B3-Flags"
+)
+
+// B3Propagator propagates the span context in the B3 headers of Zipkin,
+// the multiple X-B3-* headers or the single b3 header.
+// Both formats are extracted, the configured one being injected.
+type B3Propagator struct {
+	SingleHeader bool
+}
+
+// Extract reads the span context in the b3 header, or else in the X-B3-* headers.
+// The sampling of the traces without sampling decision is left to the sampler.
+func (B3Propagator) Extract(header http.Header) (SpanContext, bool) {
+	if single := header.Get(B3Header); len(single) > 0 {
+		// traceid-spanid[-sampled[-parentspanid]]
+		parts := strings.Split(single, "-")
+		if len(parts) < 2 || len(parts) > 4 {
+			return SpanContext{}, false
+		}
+		sampled := ""
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+		return newB3SpanContext(parts[0], parts[1], sampled, "")
+	}
+	return newB3SpanContext(header.Get(B3TraceIDHeader), header.Get(B3SpanIDHeader), header.Get(B3SampledHeader), header.Get(B3FlagsHeader))
+}
+
+func newB3SpanContext(traceID, spanID, sampled, flags string) (SpanContext, bool) {
+	var spanContext SpanContext
+	if (len(traceID) != 16 && len(traceID) != 32) || !decodeJaegerID(spanContext.TraceID[:], traceID) || !decodeID(spanContext.SpanID[:], spanID) {
+		return SpanContext{}, false
+	}
+
+	switch sampled {
+	case "1", "true", "d":
+		spanContext.Sampled = true
+	case "":
+		// left to the sampler, unless the debug flag forces the sampling
+		spanContext.Sampled = flags == "1"
+		spanContext.SamplingDeferred = !spanContext.Sampled
+	case "0", "false":
+		// the debug flag forces the sampling
+		spanContext.Sampled = flags == "1"
+	default:
+		return SpanContext{}, false
+	}
+	return spanContext, true
+}
+
+// Inject writes the span context in the configured B3 format
+func (p B3Propagator) Inject(spanContext SpanContext, header http.Header) {
+	sampled := "0"
+	if spanContext.Sampled {
+		sampled = "1"
+	}
+
+	for _, name := range []string{B3Header, B3TraceIDHeader, B3SpanIDHeader, B3ParentSpanIDHeader, B3SampledHeader, B3FlagsHeader} {
+		header.Del(name)
+	}
+	if p.SingleHeader {
+		header.Set(B3Header, spanContext.TraceID.String()+"-"+spanContext.SpanID.String()+"-"+sampled)
+		return
+	}
+	header.Set(B3TraceIDHeader, spanContext.TraceID.String())
+	header.Set(B3SpanIDHeader, spanContext.SpanID.String())
+	header.Set(B3SampledHeader, sampled)
+}
+
+// zipkinReporter sends the spans in the JSON format of the Zipkin v2 API
+type zipkinReporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+var zipkinSpanKinds = map[SpanKind]string{
+	SpanKindServer: "SERVER",
+	SpanKindClient: "CLIENT",
+}
+
+// Export posts the spans to the endpoint
+func (r *zipkinReporter) Export(serviceName string, spans []*Span) error {
+	body, err := json.Marshal(newZipkinSpans(serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d reporting the spans to %s", resp.StatusCode, r.endpoint)
+	}
+	return nil
+}
+
+func newZipkinSpans(serviceName string, spans []*Span) []zipkinSpan {
+	zipkinSpans := make([]zipkinSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mutex.Lock()
+		zipkinSpan := zipkinSpan{
+			TraceID:       span.Context.TraceID.String(),
+			ID:            span.Context.SpanID.String(),
+			Name:          span.Name,
+			Kind:          zipkinSpanKinds[span.Kind],
+			Timestamp:     span.Start.UnixNano() / int64(time.Microsecond),
+			Duration:      int64(span.End.Sub(span.Start) / time.Microsecond),
+			LocalEndpoint: zipkinEndpoint{ServiceName: serviceName},
+			Tags:          make(map[string]string, len(span.Attributes)+1),
+		}
+		if span.ParentID != (SpanID{}) {
+			zipkinSpan.ParentID = span.ParentID.String()
+		}
+		for key, value := range span.Attributes {
+			zipkinSpan.Tags[key] = value
+		}
+		if len(span.Error) > 0 {
+			zipkinSpan.Tags["error"] = span.Error
+		}
+		span.mutex.Unlock()
+		zipkinSpans = append(zipkinSpans, zipkinSpan)
+	}
+	return zipkinSpans
+}
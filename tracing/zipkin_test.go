@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestB3PropagatorExtract(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		headers          map[string]string
+		expectedOk       bool
+		expectedTraceID  string
+		expectedSampled  bool
+		expectedDeferred bool
+	}{
+		{
+			desc: "multiple headers",
+			headers: map[string]string{
+				B3TraceIDHeader:      "4bf92f3577b34da6a3ce929d0e0e4736",
+				B3SpanIDHeader:       "00f067aa0ba902b7",
+				B3ParentSpanIDHeader: "a3ce929d0e0e4736",
+				B3SampledHeader:      "1",
+			},
+			expectedOk:      true,
+			expectedTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			expectedSampled: true,
+		},
+		{
+			desc: "64 bits trace ID not sampled",
+			headers: map[string]string{
+				B3TraceIDHeader: "a3ce929d0e0e4736",
+				B3SpanIDHeader:  "00f067aa0ba902b7",
+				B3SampledHeader: "0",
+			},
+			expectedOk:      true,
+			expectedTraceID: "0000000000000000a3ce929d0e0e4736",
+		},
+		{
+			desc: "debug flag",
+			headers: map[string]string{
+				B3TraceIDHeader: "a3ce929d0e0e4736",
+				B3SpanIDHeader:  "00f067aa0ba902b7",
+				B3FlagsHeader:   "1",
+			},
+			expectedOk:      true,
+			expectedTraceID: "0000000000000000a3ce929d0e0e4736",
+			expectedSampled: true,
+		},
+		{
+			desc:            "single header",
+			headers:         map[string]string{B3Header: "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0-a3ce929d0e0e4736"},
+			expectedOk:      true,
+			expectedTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			desc: "multiple headers without sampling decision",
+			headers: map[string]string{
+				B3TraceIDHeader: "a3ce929d0e0e4736",
+				B3SpanIDHeader:  "00f067aa0ba902b7",
+			},
+			expectedOk:       true,
+			expectedTraceID:  "0000000000000000a3ce929d0e0e4736",
+			expectedDeferred: true,
+		},
+		{
+			desc:             "single header without sampling decision",
+			headers:          map[string]string{B3Header: "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+			expectedOk:       true,
+			expectedTraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			expectedDeferred: true,
+		},
+		{
+			desc:    "only a sampling decision",
+			headers: map[string]string{B3Header: "0"},
+		},
+		{
+			desc: "invalid sampling decision",
+			headers: map[string]string{
+				B3TraceIDHeader: "a3ce929d0e0e4736",
+				B3SpanIDHeader:  "00f067aa0ba902b7",
+				B3SampledHeader: "yes",
+			},
+		},
+		{
+			desc: "missing span ID",
+			headers: map[string]string{
+				B3TraceIDHeader: "a3ce929d0e0e4736",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			for name, value := range test.headers {
+				header.Set(name, value)
+			}
+
+			spanContext, ok := B3Propagator{}.Extract(header)
+
+			assert.Equal(t, test.expectedOk, ok)
+			if test.expectedOk {
+				assert.Equal(t, test.expectedTraceID, spanContext.TraceID.String())
+				assert.Equal(t, "00f067aa0ba902b7", spanContext.SpanID.String())
+				assert.Equal(t, test.expectedSampled, spanContext.Sampled)
+				assert.Equal(t, test.expectedDeferred, spanContext.SamplingDeferred)
+			}
+		})
+	}
+}
+
+func TestB3SamplingDeferred(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		sampled         string
+		sample          bool
+		expectedSampled bool
+	}{
+		{
+			desc:            "sampled by the sampler",
+			sample:          true,
+			expectedSampled: true,
+		},
+		{
+			desc: "not sampled by the sampler",
+		},
+		{
+			desc:            "sampled by the client",
+			sampled:         "1",
+			expectedSampled: true,
+		},
+		{
+			desc:    "not sampled by the client",
+			sampled: "0",
+			sample:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			tracer := newTracer("traefik", B3Propagator{}, constSampler(test.sample), nil, 0, "")
+			defer tracer.Close()
+
+			header := http.Header{}
+			header.Set(B3TraceIDHeader, "a3ce929d0e0e4736")
+			header.Set(B3SpanIDHeader, "00f067aa0ba902b7")
+			if len(test.sampled) > 0 {
+				header.Set(B3SampledHeader, test.sampled)
+			}
+
+			_, span := tracer.StartSpan(tracer.Extract(context.Background(), header), "span", SpanKindServer)
+			assert.Equal(t, test.expectedSampled, span.Context.Sampled)
+			assert.False(t, span.Context.SamplingDeferred)
+		})
+	}
+}
+
+func TestB3PropagatorInject(t *testing.T) {
+	spanContext := SpanContext{TraceID: TraceID{15: 1}, SpanID: SpanID{7: 2}, Sampled: true}
+
+	header := http.Header{}
+	header.Set(B3ParentSpanIDHeader, "a3ce929d0e0e4736")
+	B3Propagator{}.Inject(spanContext, header)
+	assert.Equal(t, "00000000000000000000000000000001", header.Get(B3TraceIDHeader))
+	assert.Equal(t, "0000000000000002", header.Get(B3SpanIDHeader))
+	assert.Equal(t, "1", header.Get(B3SampledHeader))
+	assert.Empty(t, header.Get(B3ParentSpanIDHeader))
+
+	header = http.Header{}
+	B3Propagator{SingleHeader: true}.Inject(spanContext, header)
+	assert.Equal(t, "00000000000000000000000000000001-0000000000000002-1", header.Get(B3Header))
+	extracted, ok := B3Propagator{}.Extract(header)
+	assert.True(t, ok)
+	assert.Equal(t, spanContext, extracted)
+}
+
+func TestZipkinReporter(t *testing.T) {
+	var spans []zipkinSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/spans", r.URL.Path)
+		var body []zipkinSpan
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		spans = append(spans, body...)
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	tracer, err := NewTracer(&Config{
+		Backend:     ZipkinName,
+		ServiceName: "proxy",
+		Zipkin:      &ZipkinConfig{HTTPEndpoint: collector.URL + "/api/v2/spans", SampleRate: 1},
+	})
+	require.NoError(t, err)
+
+	ctx, parent := tracer.StartSpan(context.Background(), "entrypoint http", SpanKindServer)
+	_, child := tracer.StartSpan(ctx, "forward backend1", SpanKindClient)
+	child.SetAttribute("http.status_code", "502")
+	child.SetError("Bad Gateway")
+	child.Finish()
+	parent.Finish()
+	tracer.Close()
+
+	require.Len(t, spans, 2)
+	assert.Equal(t, "forward backend1", spans[0].Name)
+	assert.Equal(t, "CLIENT", spans[0].Kind)
+	assert.Equal(t, parent.Context.SpanID.String(), spans[0].ParentID)
+	assert.Equal(t, map[string]string{"http.status_code": "502", "error": "Bad Gateway"}, spans[0].Tags)
+	assert.Equal(t, zipkinEndpoint{ServiceName: "proxy"}, spans[0].LocalEndpoint)
+	assert.NotZero(t, spans[0].Timestamp)
+
+	assert.Equal(t, "SERVER", spans[1].Kind)
+	assert.Empty(t, spans[1].ParentID)
+	assert.Equal(t, spans[0].TraceID, spans[1].TraceID)
+}
+
+func TestNewZipkinTracerWithInvalidConfig(t *testing.T) {
+	_, err := NewTracer(&Config{Backend: ZipkinName, Zipkin: &ZipkinConfig{SampleRate: 2}})
+	assert.Error(t, err)
+
+	_, err = NewTracer(&Config{Backend: ZipkinName, Zipkin: &ZipkinConfig{Propagation: "foo"}})
+	assert.Error(t, err)
+}
@@ -15,9 +15,15 @@ const (
 	LabelFrontendAuthBasic                       = LabelPrefix + "frontend.auth.basic"
 	LabelFrontendEntryPoints                     = LabelPrefix + "frontend.entryPoints"
 	LabelFrontendPassHostHeader                  = LabelPrefix + "frontend.passHostHeader"
+	LabelFrontendPassTLSCert                     = LabelPrefix + "frontend.passTLSCert"
 	LabelFrontendPriority                        = LabelPrefix + "frontend.priority"
 	LabelFrontendRule                            = LabelPrefix + "frontend.rule"
 	LabelFrontendRuleType                        = LabelPrefix + "frontend.rule.type"
+	LabelFrontendRedirectRegex                   = LabelPrefix + "frontend.redirect.regex"
+	LabelFrontendRedirectReplacement             = LabelPrefix + "frontend.redirect.replacement"
+	LabelFrontendRedirectStatusCode              = LabelPrefix + "frontend.redirect.statusCode"
+	LabelFrontendRequestHeaders                  = LabelPrefix + "frontend.headers.customRequestHeaders"
+	LabelFrontendResponseHeaders                 = LabelPrefix + "frontend.headers.customResponseHeaders"
 	LabelTraefikFrontendValue                    = LabelPrefix + "frontend.value"
 	LabelTraefikFrontendWhitelistSourceRange     = LabelPrefix + "frontend.whitelistSourceRange"
 	LabelBackend                                 = LabelPrefix + "backend"
@@ -32,6 +38,9 @@ const (
 	LabelBackendLoadbalancerStickinessCookieName = LabelPrefix + "backend.loadbalancer.stickiness.cookieName"
 	LabelBackendMaxconnAmount                    = LabelPrefix + "backend.maxconn.amount"
 	LabelBackendMaxconnExtractorfunc             = LabelPrefix + "backend.maxconn.extractorfunc"
+	LabelTLSEntryPoints                          = LabelPrefix + "tls.entryPoints"
+	LabelTLSCertificateCertFile                  = LabelPrefix + "tls.certificate.certFile"
+	LabelTLSCertificateKeyFile                   = LabelPrefix + "tls.certificate.keyFile"
 )
 
 //ServiceLabel converts a key value of Label*, given a serviceName, into a pattern <LabelPrefix>.<serviceName>.<property>
@@ -19,29 +19,134 @@ import (
 
 // Backend holds backend configuration.
 type Backend struct {
-	Servers        map[string]Server `json:"servers,omitempty"`
-	CircuitBreaker *CircuitBreaker   `json:"circuitBreaker,omitempty"`
-	LoadBalancer   *LoadBalancer     `json:"loadBalancer,omitempty"`
-	MaxConn        *MaxConn          `json:"maxConn,omitempty"`
-	HealthCheck    *HealthCheck      `json:"healthCheck,omitempty"`
+	Servers            map[string]Server   `json:"servers,omitempty"`
+	CircuitBreaker     *CircuitBreaker     `json:"circuitBreaker,omitempty"`
+	LoadBalancer       *LoadBalancer       `json:"loadBalancer,omitempty"`
+	MaxConn            *MaxConn            `json:"maxConn,omitempty"`
+	HealthCheck        *HealthCheck        `json:"healthCheck,omitempty"`
+	PassiveHealthCheck *PassiveHealthCheck `json:"passiveHealthCheck,omitempty"`
+	TLS                *BackendTLS         `json:"tls,omitempty"`
+	Protocol           string              `json:"protocol,omitempty"`
+	Buffering          *Buffering          `json:"buffering,omitempty"`
+	ForwardingTimeouts *ForwardingTimeouts `json:"forwardingTimeouts,omitempty"`
+	DNSSRV             *DNSSRV             `json:"dnsSRV,omitempty"`
+	PassHostHeader     *bool               `json:"passHostHeader,omitempty"`
 }
 
-// MaxConn holds maximum connection configuration
+// DNSSRV holds the DNS SRV records name the servers of a backend are resolved from, besides its servers,
+// and the interval of their resolution (30 seconds by default).
+type DNSSRV struct {
+	Name            string `json:"name,omitempty"`
+	Scheme          string `json:"scheme,omitempty"`
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// ForwardingTimeouts holds the timeouts of the requests forwarded to the servers of a backend,
+// overriding the global ones: durations in a format understood by time.ParseDuration.
+type ForwardingTimeouts struct {
+	DialTimeout           string `json:"dialTimeout,omitempty"`
+	ResponseHeaderTimeout string `json:"responseHeaderTimeout,omitempty"`
+	IdleConnTimeout       string `json:"idleConnTimeout,omitempty"`
+}
+
+// Buffering holds the buffering configuration of a backend, the sizes being in bytes.
+// The bodies bigger than the Mem sizes are buffered to disk, and the ones bigger than the Max sizes are rejected,
+// a Max size of 0 meaning no limit.
+type Buffering struct {
+	MaxRequestBodyBytes  int64 `json:"maxRequestBodyBytes,omitempty"`
+	MemRequestBodyBytes  int64 `json:"memRequestBodyBytes,omitempty"`
+	MaxResponseBodyBytes int64 `json:"maxResponseBodyBytes,omitempty"`
+	MemResponseBodyBytes int64 `json:"memResponseBodyBytes,omitempty"`
+}
+
+// BackendProtocolH2C is the backend protocol forwarding the requests with cleartext HTTP/2 (e.g. gRPC)
+const BackendProtocolH2C = "h2c"
+
+// BackendTLS holds the TLS configuration used to connect to the backend servers
+// CA, Cert and Key can be either path or file contents
+type BackendTLS struct {
+	CA                 string `json:"ca,omitempty"`
+	Cert               string `json:"cert,omitempty"`
+	Key                string `json:"key,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+}
+
+// CreateTLSConfig creates a TLS config from BackendTLS structures
+func (backendTLS *BackendTLS) CreateTLSConfig() (*tls.Config, error) {
+	if backendTLS == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: backendTLS.InsecureSkipVerify,
+		// the name sent with SNI and verified in the servers certificates, the host of their URL by default
+		ServerName: backendTLS.ServerName,
+	}
+
+	if backendTLS.CA != "" {
+		ca, err := readFileOrContent(backendTLS.CA)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA. %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid certificate(s) in CA %s", backendTLS.CA)
+		}
+	}
+
+	if len(backendTLS.Cert) > 0 || len(backendTLS.Key) > 0 {
+		certContent, err := readFileOrContent(backendTLS.Cert)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read TLS cert. %s", err)
+		}
+		keyContent, err := readFileOrContent(backendTLS.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read TLS key. %s", err)
+		}
+		cert, err := tls.X509KeyPair(certContent, keyContent)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load TLS keypair: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func readFileOrContent(fileOrContent string) ([]byte, error) {
+	if _, err := os.Stat(fileOrContent); err == nil {
+		return ioutil.ReadFile(fileOrContent)
+	}
+	return []byte(fileOrContent), nil
+}
+
+// MaxConn holds maximum connection configuration.
+// The excess requests wait in a queue of QueueSize requests during at most QueueTimeout,
+// and are rejected with StatusCode (429 or 503) otherwise.
 type MaxConn struct {
 	Amount        int64  `json:"amount,omitempty"`
 	ExtractorFunc string `json:"extractorFunc,omitempty"`
+	QueueSize     int64  `json:"queueSize,omitempty"`
+	QueueTimeout  string `json:"queueTimeout,omitempty"`
+	StatusCode    int    `json:"statusCode,omitempty"`
 }
 
 // LoadBalancer holds load balancing configuration.
 type LoadBalancer struct {
-	Method     string      `json:"method,omitempty"`
-	Sticky     bool        `json:"sticky,omitempty"` // Deprecated: use Stickiness instead
-	Stickiness *Stickiness `json:"stickiness,omitempty"`
+	Method        string      `json:"method,omitempty"`
+	Sticky        bool        `json:"sticky,omitempty"` // Deprecated: use Stickiness instead
+	Stickiness    *Stickiness `json:"stickiness,omitempty"`
+	ExtractorFunc string      `json:"extractorFunc,omitempty"` // request attribute hashed by the hash method
 }
 
 // Stickiness holds sticky session configuration.
+// SameSite can be Lax, Strict or None.
 type Stickiness struct {
 	CookieName string `json:"cookieName,omitempty"`
+	Secure     bool   `json:"secure,omitempty"`
+	HTTPOnly   bool   `json:"httpOnly,omitempty"`
+	SameSite   string `json:"sameSite,omitempty"`
 }
 
 // CircuitBreaker holds circuit breaker configuration.
@@ -54,6 +159,14 @@ type HealthCheck struct {
 	Path     string `json:"path,omitempty"`
 	Port     int    `json:"port,omitempty"`
 	Interval string `json:"interval,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+	Status   int    `json:"status,omitempty"` // expected status code, 200 by default
+}
+
+// PassiveHealthCheck holds passive health check configuration
+type PassiveHealthCheck struct {
+	MaxFailures  int    `json:"maxFailures,omitempty"`
+	EjectionTime string `json:"ejectionTime,omitempty"`
 }
 
 // Server holds server configuration.
@@ -65,13 +178,17 @@ type Server struct {
 // Route holds route configuration.
 type Route struct {
 	Rule string `json:"rule,omitempty"`
+	// Priority is the share of the route in the priority of its frontend, the rule length by default
+	Priority int `json:"priority,omitempty"`
 }
 
 //ErrorPage holds custom error page configuration
+//The error page is served by the error Backend, or rendered from the File template if set
 type ErrorPage struct {
 	Status  []string `json:"status,omitempty"`
 	Backend string   `json:"backend,omitempty"`
 	Query   string   `json:"query,omitempty"`
+	File    string   `json:"file,omitempty"`
 }
 
 // Rate holds a rate limiting configuration for a specific time period
@@ -148,10 +265,141 @@ type Frontend struct {
 	PassTLSCert          bool                 `json:"passTLSCert,omitempty"`
 	Priority             int                  `json:"priority"`
 	BasicAuth            []string             `json:"basicAuth"`
+	BasicAuthUsersFile   string               `json:"basicAuthUsersFile,omitempty"`
+	BasicAuthHeaderField string               `json:"basicAuthHeaderField,omitempty"`
 	WhitelistSourceRange []string             `json:"whitelistSourceRange,omitempty"`
+	BlacklistSourceRange []string             `json:"blacklistSourceRange,omitempty"`
+	IPStrategy           *IPStrategy          `json:"ipStrategy,omitempty"`
+	GeoIP                *GeoIP               `json:"geoIP,omitempty"`
+	WAF                  *WAF                 `json:"waf,omitempty"`
+	UserAgent            *UserAgent           `json:"userAgent,omitempty"`
+	Maintenance          *Maintenance         `json:"maintenance,omitempty"`
 	Headers              Headers              `json:"headers,omitempty"`
 	Errors               map[string]ErrorPage `json:"errors,omitempty"`
 	RateLimit            *RateLimit           `json:"ratelimit,omitempty"`
+	Retry                *Retry               `json:"retry,omitempty"`
+	Mirror               *Mirror              `json:"mirror,omitempty"`
+	WeightedBackends     map[string]int       `json:"weightedBackends,omitempty"`
+	Variants             *Variants            `json:"variants,omitempty"`
+	Auth                 *Auth                `json:"auth,omitempty"`
+	Redirect             *Redirect            `json:"redirect,omitempty"`
+	Limits               *Limits              `json:"limits,omitempty"`
+	Cache                *Cache               `json:"cache,omitempty"`
+	Middlewares          []string             `json:"middlewares,omitempty"`
+}
+
+// IPStrategy holds how the client IP checked against the source ranges of a frontend is determined:
+// the Depth-th address from the end of the X-Forwarded-For header, or the last address of the
+// X-Forwarded-For header and remote address which is not one of the TrustedProxies.
+type IPStrategy struct {
+	Depth          int      `json:"depth,omitempty"`
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+}
+
+// GeoIP holds the countries (ISO 3166-1 codes) and the continents (AF, AN, AS, EU, NA, OC, SA) allowed or blocked by a
+// frontend, the clients being located by the GeoIP database of the entry points.
+type GeoIP struct {
+	AllowedCountries  []string `json:"allowedCountries,omitempty"`
+	BlockedCountries  []string `json:"blockedCountries,omitempty"`
+	AllowedContinents []string `json:"allowedContinents,omitempty"`
+	BlockedContinents []string `json:"blockedContinents,omitempty"`
+}
+
+// Redirect holds the redirection of the requests of a frontend whose URL matches Regex to Replacement,
+// with a StatusCode among 301, 302 (the default), 307 and 308.
+type Redirect struct {
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+}
+
+// Limits holds the maximum sizes, in bytes, of the requests of a frontend, 0 meaning no limit.
+type Limits struct {
+	MaxRequestBodyBytes   int64 `json:"maxRequestBodyBytes,omitempty"`
+	MaxRequestHeaderBytes int64 `json:"maxRequestHeaderBytes,omitempty"`
+}
+
+// WAF holds the web application firewall inspecting the requests of a frontend before they are forwarded: the requests
+// are sent to the Address of the WAF engine, with their body up to MaxBodyBytes, and rejected when it answers
+// 403 Forbidden, unless DetectionOnly. FailOpen forwards the requests when the engine fails to answer.
+type WAF struct {
+	Address       string     `json:"address,omitempty"`
+	DetectionOnly bool       `json:"detectionOnly,omitempty"`
+	FailOpen      bool       `json:"failOpen,omitempty"`
+	MaxBodyBytes  int64      `json:"maxBodyBytes,omitempty"`
+	Timeout       string     `json:"timeout,omitempty"`
+	TLS           *ClientTLS `json:"tls,omitempty"`
+}
+
+// UserAgent holds the User-Agent regular expressions of the requests rejected by a frontend, and of the bots
+// told to the backend in the X-Bot header. KnownBots blocks or tags the usual crawlers and HTTP libraries.
+type UserAgent struct {
+	Blocked   []string `json:"blocked,omitempty"`
+	Bots      []string `json:"bots,omitempty"`
+	KnownBots string   `json:"knownBots,omitempty"`
+}
+
+// Middleware holds the settings of a named middleware, which the frontends chain in the order of their Middlewares.
+// Exactly one of its fields must be set.
+type Middleware struct {
+	Headers   *Headers   `json:"headers,omitempty"`
+	Auth      *Auth      `json:"auth,omitempty"`
+	RateLimit *RateLimit `json:"ratelimit,omitempty"`
+	IPFilter  *IPFilter  `json:"ipFilter,omitempty"`
+	Redirect  *Redirect  `json:"redirect,omitempty"`
+	Limits    *Limits    `json:"limits,omitempty"`
+	Plugin    *Plugin    `json:"plugin,omitempty"`
+	WASM      *WASM      `json:"wasm,omitempty"`
+}
+
+// Plugin holds the name of a middleware registered by a compile-time extension or a Go plugin, and its settings
+type Plugin struct {
+	Name     string            `json:"name,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// WASM holds a WebAssembly module filtering the requests and the responses, read from File or decoded from the
+// base64 Module, and the Config string given to it. Its instances are limited to MaxMemoryPages pages of 64KB of
+// memory and MaxInstructions instructions per call, and read the bodies up to MaxBodyBytes.
+type WASM struct {
+	File            string `json:"file,omitempty"`
+	Module          string `json:"module,omitempty"`
+	Config          string `json:"config,omitempty"`
+	MaxMemoryPages  uint32 `json:"maxMemoryPages,omitempty"`
+	MaxInstructions uint64 `json:"maxInstructions,omitempty"`
+	MaxBodyBytes    int64  `json:"maxBodyBytes,omitempty"`
+}
+
+// IPFilter holds the source ranges allowed and denied by an IP filtering middleware
+type IPFilter struct {
+	WhitelistSourceRange []string    `json:"whitelistSourceRange,omitempty"`
+	BlacklistSourceRange []string    `json:"blacklistSourceRange,omitempty"`
+	IPStrategy           *IPStrategy `json:"ipStrategy,omitempty"`
+}
+
+// Cache holds the response cache configuration of a frontend: a TTL overriding the freshness lifetime
+// of the cacheable responses, and the maximum sizes in bytes of a cached response body and of the whole cache,
+// 0 meaning the default sizes.
+type Cache struct {
+	TTL            string `json:"ttl,omitempty"`
+	MaxObjectBytes int64  `json:"maxObjectBytes,omitempty"`
+	MaxSizeBytes   int64  `json:"maxSizeBytes,omitempty"`
+}
+
+// Mirror holds the configuration of the backend receiving a copy of a percentage of the requests of a frontend.
+// The responses of the mirror backend are discarded.
+type Mirror struct {
+	Backend string `json:"backend,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+}
+
+// Retry holds the retry configuration of a frontend, overriding the global one.
+// Status holds the status code ranges (e.g. "502-504") of the responses to retry, for idempotent requests,
+// or for all of them when NonIdempotent is set.
+type Retry struct {
+	Attempts      int      `json:"attempts,omitempty"`
+	Status        []string `json:"status,omitempty"`
+	NonIdempotent bool     `json:"nonIdempotent,omitempty"`
 }
 
 // LoadBalancerMethod holds the method of load balancing to use.
@@ -162,11 +410,20 @@ const (
 	Wrr LoadBalancerMethod = iota
 	// Drr = Dynamic Round Robin
 	Drr
+	// Hash = Consistent Hashing of a request attribute
+	Hash
+	// LeastConn = Least Connections
+	LeastConn
+	// P2C = Power of Two Choices
+	P2C
 )
 
 var loadBalancerMethodNames = []string{
 	"Wrr",
 	"Drr",
+	"Hash",
+	"LeastConn",
+	"P2C",
 }
 
 // NewLoadBalancerMethod create a new LoadBalancerMethod from a given LoadBalancer.
@@ -188,8 +445,148 @@ type Configurations map[string]*Configuration
 
 // Configuration of a provider.
 type Configuration struct {
-	Backends  map[string]*Backend  `json:"backends,omitempty"`
-	Frontends map[string]*Frontend `json:"frontends,omitempty"`
+	Backends         map[string]*Backend     `json:"backends,omitempty"`
+	Frontends        map[string]*Frontend    `json:"frontends,omitempty"`
+	Middlewares      map[string]*Middleware  `json:"middlewares,omitempty"`
+	TLSConfiguration []*TLSConfiguration     `json:"tls,omitempty" toml:"tls"`
+	TCPBackends      map[string]*TCPBackend  `json:"tcpBackends,omitempty"`
+	TCPFrontends     map[string]*TCPFrontend `json:"tcpFrontends,omitempty"`
+	UDPBackends      map[string]*UDPBackend  `json:"udpBackends,omitempty"`
+	UDPFrontends     map[string]*UDPFrontend `json:"udpFrontends,omitempty"`
+}
+
+// TCPFrontend holds the route of TCP connections to a TCP backend.
+// Connections are matched on the server name (SNI) sent by the TLS clients, or caught all when SNI is empty.
+type TCPFrontend struct {
+	EntryPoints []string `json:"entryPoints,omitempty"`
+	Backend     string   `json:"backend,omitempty"`
+	SNI         []string `json:"sni,omitempty"`
+	Passthrough bool     `json:"passthrough,omitempty"`
+}
+
+// TCPBackend holds the servers the TCP connections are load balanced on
+type TCPBackend struct {
+	Servers       map[string]TCPServer `json:"servers,omitempty"`
+	ProxyProtocol *ProxyProtocol       `json:"proxyProtocol,omitempty"`
+}
+
+// ProxyProtocol holds the PROXY protocol header sent to the servers of a TCP backend.
+// Version is 1 or 2, and defaults to 2.
+type ProxyProtocol struct {
+	Version int `json:"version,omitempty"`
+}
+
+// TCPServer holds the address of a TCP server
+type TCPServer struct {
+	Address string `json:"address,omitempty"`
+}
+
+// UDPFrontend binds UDP entry points to a UDP backend
+type UDPFrontend struct {
+	EntryPoints []string `json:"entryPoints,omitempty"`
+	Backend     string   `json:"backend,omitempty"`
+}
+
+// UDPBackend holds the servers the UDP sessions are load balanced on
+type UDPBackend struct {
+	Servers map[string]UDPServer `json:"servers,omitempty"`
+}
+
+// UDPServer holds the address of a UDP server
+type UDPServer struct {
+	Address string `json:"address,omitempty"`
+}
+
+// TLSConfiguration holds a certificate provided dynamically and the entry points serving it.
+type TLSConfiguration struct {
+	EntryPoints []string        `json:"entryPoints,omitempty"`
+	Certificate *TLSCertificate `json:"certificate,omitempty"`
+}
+
+// TLSCertificate holds a cert/key pair
+// CertFile and KeyFile can be either path or file contents
+type TLSCertificate struct {
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// Redacted returns a copy of the configurations without the secrets they hold, for them to be exposed or logged
+func (configurations Configurations) Redacted() Configurations {
+	redacted := make(Configurations, len(configurations))
+	for providerName, configuration := range configurations {
+		redacted[providerName] = configuration.Redacted()
+	}
+	return redacted
+}
+
+// Redacted returns a copy of the configuration without the secrets it holds, i.e. the keys of the certificates and
+// of the backend and WAF TLS, and the secrets of the authentications, for it to be exposed by the API or logged. The parts
+// holding no secret are shared with the configuration.
+func (configuration *Configuration) Redacted() *Configuration {
+	if configuration == nil {
+		return nil
+	}
+	redacted := *configuration
+
+	if configuration.Backends != nil {
+		redacted.Backends = make(map[string]*Backend, len(configuration.Backends))
+		for backendName, backend := range configuration.Backends {
+			if backend != nil && backend.TLS != nil {
+				backendTLS := *backend.TLS
+				backendTLS.Key = ""
+				redactedBackend := *backend
+				redactedBackend.TLS = &backendTLS
+				backend = &redactedBackend
+			}
+			redacted.Backends[backendName] = backend
+		}
+	}
+
+	if configuration.Frontends != nil {
+		redacted.Frontends = make(map[string]*Frontend, len(configuration.Frontends))
+		for frontendName, frontend := range configuration.Frontends {
+			if frontend != nil && (frontend.Auth != nil || frontend.WAF != nil && frontend.WAF.TLS != nil) {
+				redactedFrontend := *frontend
+				if frontend.Auth != nil {
+					redactedFrontend.Auth = frontend.Auth.redacted()
+				}
+				if frontend.WAF != nil && frontend.WAF.TLS != nil {
+					waf := *frontend.WAF
+					wafTLS := *frontend.WAF.TLS
+					wafTLS.Key = ""
+					waf.TLS = &wafTLS
+					redactedFrontend.WAF = &waf
+				}
+				frontend = &redactedFrontend
+			}
+			redacted.Frontends[frontendName] = frontend
+		}
+	}
+
+	if configuration.Middlewares != nil {
+		redacted.Middlewares = make(map[string]*Middleware, len(configuration.Middlewares))
+		for middlewareName, middleware := range configuration.Middlewares {
+			if middleware != nil && middleware.Auth != nil {
+				redactedMiddleware := *middleware
+				redactedMiddleware.Auth = middleware.Auth.redacted()
+				middleware = &redactedMiddleware
+			}
+			redacted.Middlewares[middlewareName] = middleware
+		}
+	}
+
+	if configuration.TLSConfiguration != nil {
+		redacted.TLSConfiguration = make([]*TLSConfiguration, len(configuration.TLSConfiguration))
+		for i, tlsConfiguration := range configuration.TLSConfiguration {
+			if tlsConfiguration != nil && tlsConfiguration.Certificate != nil {
+				certificate := *tlsConfiguration.Certificate
+				certificate.KeyFile = ""
+				tlsConfiguration = &TLSConfiguration{EntryPoints: tlsConfiguration.EntryPoints, Certificate: &certificate}
+			}
+			redacted.TLSConfiguration[i] = tlsConfiguration
+		}
+	}
+	return &redacted
 }
 
 // ConfigMessage hold configuration information exchanged between parts of traefik.
@@ -207,6 +604,9 @@ type Constraint struct {
 	Regex string `export:"true"`
 }
 
+// constraintLabelPrefix is the prefix of the keys of the constraints on the value of a label
+const constraintLabelPrefix = "label:"
+
 // NewConstraint receive a string and return a *Constraint, after checking syntax and parsing the constraint expression
 func NewConstraint(exp string) (*Constraint, error) {
 	sep := ""
@@ -224,9 +624,9 @@ func NewConstraint(exp string) (*Constraint, error) {
 
 	kv := strings.SplitN(exp, sep, 2)
 	if len(kv) == 2 {
-		// At the moment, it only supports tags
-		if kv[0] != "tag" {
-			return nil, errors.New("constraint must be tag-based. Syntax: tag==us-*")
+		// It supports tags, and the values of labels
+		if kv[0] != "tag" && (!strings.HasPrefix(kv[0], constraintLabelPrefix) || len(kv[0]) == len(constraintLabelPrefix)) {
+			return nil, errors.New("constraint must be tag or label-based. Syntax: tag==us-* or label:environment==prod")
 		}
 
 		constraint.Key = kv[0]
@@ -275,6 +675,17 @@ func (c *Constraint) MatchConstraintWithAtLeastOneTag(tags []string) bool {
 	return false
 }
 
+// IsLabelBased returns whether the constraint applies to the value of a label rather than to the tags
+func (c *Constraint) IsLabelBased() bool {
+	return strings.HasPrefix(c.Key, constraintLabelPrefix)
+}
+
+// MatchConstraintWithLabels tests a label-based constraint for the labels of one single service
+func (c *Constraint) MatchConstraintWithLabels(labels map[string]string) bool {
+	value, ok := labels[strings.TrimPrefix(c.Key, constraintLabelPrefix)]
+	return ok && glob.Glob(c.Regex, value)
+}
+
 //Set []*Constraint
 func (cs *Constraints) Set(str string) error {
 	exps := strings.Split(str, ",")
@@ -319,16 +730,36 @@ type Store struct {
 
 // Cluster holds cluster config
 type Cluster struct {
-	Node  string `description:"Node name" export:"true"`
-	Store *Store `export:"true"`
+	Node      string         `description:"Node name" export:"true"`
+	Store     *Store         `export:"true"`
+	LeaderTTL flaeg.Duration `description:"Duration of the leader lock in the KV store, after which another node is elected when the leader does not renew it" export:"true"`
 }
 
 // Auth holds authentication configuration (BASIC, DIGEST, users)
 type Auth struct {
-	Basic       *Basic   `export:"true"`
-	Digest      *Digest  `export:"true"`
-	Forward     *Forward `export:"true"`
-	HeaderField string   `export:"true"`
+	Basic       *Basic   `json:"basic,omitempty" export:"true"`
+	Digest      *Digest  `json:"digest,omitempty" export:"true"`
+	Forward     *Forward `json:"forward,omitempty" export:"true"`
+	JWT         *JWT     `json:"jwt,omitempty" export:"true"`
+	OIDC        *OIDC    `json:"oidc,omitempty" export:"true"`
+	HeaderField string   `json:"headerField,omitempty" export:"true"`
+}
+
+// redacted returns a copy of the authentication without its secrets
+func (auth *Auth) redacted() *Auth {
+	redacted := *auth
+	if auth.JWT != nil {
+		jwt := *auth.JWT
+		jwt.Secret = ""
+		redacted.JWT = &jwt
+	}
+	if auth.OIDC != nil {
+		oidc := *auth.OIDC
+		oidc.ClientSecret = ""
+		oidc.SessionSecret = ""
+		redacted.OIDC = &oidc
+	}
+	return &redacted
 }
 
 // Users authentication users
@@ -336,21 +767,47 @@ type Users []string
 
 // Basic HTTP basic authentication
 type Basic struct {
-	Users     `mapstructure:","`
-	UsersFile string
+	Users     `json:"users,omitempty" mapstructure:","`
+	UsersFile string `json:"usersFile,omitempty"`
 }
 
 // Digest HTTP authentication
 type Digest struct {
-	Users     `mapstructure:","`
-	UsersFile string
+	Users     `json:"users,omitempty" mapstructure:","`
+	UsersFile string `json:"usersFile,omitempty"`
 }
 
 // Forward authentication
 type Forward struct {
-	Address            string     `description:"Authentication server address"`
-	TLS                *ClientTLS `description:"Enable TLS support" export:"true"`
-	TrustForwardHeader bool       `description:"Trust X-Forwarded-* headers" export:"true"`
+	Address             string     `description:"Authentication server address" json:"address,omitempty"`
+	TLS                 *ClientTLS `description:"Enable TLS support" json:"tls,omitempty" export:"true"`
+	TrustForwardHeader  bool       `description:"Trust X-Forwarded-* headers" json:"trustForwardHeader,omitempty" export:"true"`
+	AuthResponseHeaders []string   `description:"Headers to be forwarded from auth response" json:"authResponseHeaders,omitempty" export:"true"`
+}
+
+// JWT authentication of the Bearer JSON Web Tokens, signed with the Secret (HS algorithms),
+// the PublicKey (RS and ES algorithms) or one of the keys published at JWKSURL.
+// ClaimsHeaders maps claims to the request headers they are forwarded in.
+type JWT struct {
+	Secret        string            `description:"HMAC secret" json:"secret,omitempty"`
+	PublicKey     string            `description:"RSA or ECDSA public key (PEM file or content)" json:"publicKey,omitempty"`
+	JWKSURL       string            `description:"JSON Web Key Set URL" json:"jwksURL,omitempty" export:"true"`
+	Issuer        string            `description:"Expected issuer" json:"issuer,omitempty" export:"true"`
+	Audience      string            `description:"Expected audience" json:"audience,omitempty" export:"true"`
+	ClaimsHeaders map[string]string `description:"Claims forwarded in request headers" json:"claimsHeaders,omitempty" export:"true"`
+}
+
+// OIDC authentication logging the users in with the OpenID Connect authorization code flow of the Issuer.
+// The identity of the logged in users is kept in a session cookie signed with the SessionSecret.
+type OIDC struct {
+	Issuer        string            `description:"OpenID Connect issuer URL" json:"issuer,omitempty" export:"true"`
+	ClientID      string            `description:"Client ID" json:"clientID,omitempty" export:"true"`
+	ClientSecret  string            `description:"Client secret" json:"clientSecret,omitempty"`
+	RedirectURL   string            `description:"Callback URL registered at the issuer" json:"redirectURL,omitempty" export:"true"`
+	Scopes        []string          `description:"Scopes requested in addition to openid" json:"scopes,omitempty" export:"true"`
+	SessionSecret string            `description:"Secret signing the session cookie" json:"sessionSecret,omitempty"`
+	CookieName    string            `description:"Session cookie name" json:"cookieName,omitempty" export:"true"`
+	ClaimsHeaders map[string]string `description:"Claims forwarded in request headers" json:"claimsHeaders,omitempty" export:"true"`
 }
 
 // CanonicalDomain returns a lower case domain with trim space
@@ -368,6 +825,7 @@ type Metrics struct {
 	Prometheus *Prometheus `description:"Prometheus metrics exporter type" export:"true"`
 	Datadog    *Datadog    `description:"DataDog metrics exporter type" export:"true"`
 	StatsD     *Statsd     `description:"StatsD metrics exporter type" export:"true"`
+	InfluxDB   *InfluxDB   `description:"InfluxDB metrics exporter type" export:"true"`
 }
 
 // Prometheus can contain specific configuration used by the Prometheus Metrics exporter
@@ -381,10 +839,23 @@ type Datadog struct {
 	PushInterval string `description:"DataDog push interval" export:"true"`
 }
 
-// Statsd contains address and metrics pushing interval configuration
+// Statsd contains address, metrics prefix and metrics pushing interval configuration
 type Statsd struct {
 	Address      string `description:"StatsD address"`
-	PushInterval string `description:"DataDog push interval" export:"true"`
+	PushInterval string `description:"StatsD push interval" export:"true"`
+	Prefix       string `description:"StatsD metrics prefix, traefik by default" export:"true"`
+}
+
+// InfluxDB contains address, protocol, database and metrics pushing configuration
+type InfluxDB struct {
+	Address         string `description:"InfluxDB address"`
+	Protocol        string `description:"InfluxDB address protocol (udp or http)" export:"true"`
+	PushInterval    string `description:"InfluxDB push interval" export:"true"`
+	Database        string `description:"InfluxDB database used when protocol is http" export:"true"`
+	RetentionPolicy string `description:"InfluxDB retention policy used when protocol is http" export:"true"`
+	Username        string `description:"InfluxDB username (only with http)"`
+	Password        string `description:"InfluxDB password (only with http)"`
+	BatchSize       int    `description:"Maximum number of points written at once" export:"true"`
 }
 
 // Buckets holds Prometheus Buckets
@@ -421,14 +892,125 @@ func (b *Buckets) SetValue(val interface{}) {
 
 // TraefikLog holds the configuration settings for the traefik logger.
 type TraefikLog struct {
-	FilePath string `json:"file,omitempty" description:"Traefik log file path. Stdout is used when omitted or empty"`
-	Format   string `json:"format,omitempty" description:"Traefik log format: json | common"`
+	FilePath string  `json:"file,omitempty" description:"Traefik log file path. Stdout is used when omitted or empty"`
+	Format   string  `json:"format,omitempty" description:"Traefik log format: json | common"`
+	Syslog   *Syslog `json:"syslog,omitempty" description:"Send the Traefik logs to syslog"`
+}
+
+// Syslog holds the syslog output of the logs: the local syslog socket, or a remote syslog server
+// receiving RFC 5424 messages over UDP, TCP or TLS.
+type Syslog struct {
+	Network  string     `json:"network,omitempty" description:"Network of the remote syslog server: udp | tcp | tcp+tls. The local syslog socket is used when omitted or empty" export:"true"`
+	Address  string     `json:"address,omitempty" description:"Address of the remote syslog server" export:"true"`
+	Facility string     `json:"facility,omitempty" description:"Syslog facility, daemon by default" export:"true"`
+	Tag      string     `json:"tag,omitempty" description:"Syslog application name, traefik by default" export:"true"`
+	TLS      *ClientTLS `json:"tls,omitempty" description:"TLS client configuration of the tcp+tls network" export:"true"`
+}
+
+// CreateHook connects to syslog, and returns the hook sending the log entries
+func (s *Syslog) CreateHook() (*log.SyslogHook, error) {
+	var tlsConfig *tls.Config
+	if s.Network == "tcp+tls" && s.TLS != nil {
+		var err error
+		tlsConfig, err = s.TLS.CreateTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return log.NewSyslogHook(s.Network, s.Address, tlsConfig, s.Facility, s.Tag)
+}
+
+// Variants holds how the requests of a frontend are assigned to one of its weighted backends: the backend named by
+// the Header of the request, or by its Cookie, set to the backend chosen by weight for the new clients.
+type Variants struct {
+	Header string `json:"header,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+}
+
+// Maintenance holds the response to the requests of a frontend in maintenance, instead of forwarding them to its backend:
+// the status code (503 by default), the body, and the Retry-After header if any.
+type Maintenance struct {
+	StatusCode int    `json:"statusCode,omitempty"`
+	Body       string `json:"body,omitempty"`
+	RetryAfter string `json:"retryAfter,omitempty"`
+}
+
+// DefaultBackend holds the handling of the requests matching no frontend:
+// they are forwarded to the server at URL if any, and answered with the status code and body otherwise.
+type DefaultBackend struct {
+	URL        string `json:"url,omitempty" description:"URL of the server the requests matching no frontend are forwarded to" export:"true"`
+	StatusCode int    `json:"statusCode,omitempty" description:"Status code of the response to the requests matching no frontend, 404 by default" export:"true"`
+	Body       string `json:"body,omitempty" description:"Body of the response to the requests matching no frontend" export:"true"`
 }
 
 // AccessLog holds the configuration settings for the access logger (middlewares/accesslog).
 type AccessLog struct {
 	FilePath string `json:"file,omitempty" description:"Access log file path. Stdout is used when omitted or empty" export:"true"`
-	Format   string `json:"format,omitempty" description:"Access log format: json | common" export:"true"`
+	Format   string `json:"format,omitempty" description:"Access log format: json | common | combined | template" export:"true"`
+	Template string            `json:"template,omitempty" description:"Go template of the access log lines, used with the template format" export:"true"`
+	Filters  *AccessLogFilters `json:"filters,omitempty" description:"Access log filters, used to keep only specific access logs" export:"true"`
+	Syslog   *Syslog           `json:"syslog,omitempty" description:"Send the access logs to syslog" export:"true"`
+}
+
+// AccessLogFilters holds the filters of the access logs:
+// the requests of the excluded paths are never logged, and when status codes or a minimum duration are given,
+// only the requests matching the status codes or slower than the minimum duration are logged.
+type AccessLogFilters struct {
+	StatusCodes  StatusCodes    `json:"statusCodes,omitempty" description:"Keep the access logs with status codes in the specified ranges" export:"true"`
+	MinDuration  flaeg.Duration `json:"minDuration,omitempty" description:"Keep the access logs of the requests taking at least the specified duration" export:"true"`
+	ExcludePaths ExcludePaths   `json:"excludePaths,omitempty" description:"Drop the access logs of the requests to the specified paths and their sub-paths" export:"true"`
+}
+
+// StatusCodes holds status codes ranges, e.g. 200 or 500-599
+type StatusCodes []string
+
+// Set adds strings elem into the parser
+// it splits str on , and ;
+func (s *StatusCodes) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*s = append(*s, slice...)
+	return nil
+}
+
+// Get StatusCodes
+func (s *StatusCodes) Get() interface{} { return StatusCodes(*s) }
+
+// String return slice in a string
+func (s *StatusCodes) String() string { return fmt.Sprintf("%v", *s) }
+
+// SetValue sets StatusCodes into the parser
+func (s *StatusCodes) SetValue(val interface{}) {
+	*s = StatusCodes(val.(StatusCodes))
+}
+
+// ExcludePaths holds the paths whose requests are not logged
+type ExcludePaths []string
+
+// Set adds strings elem into the parser
+// it splits str on , and ;
+func (e *ExcludePaths) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*e = append(*e, slice...)
+	return nil
+}
+
+// Get ExcludePaths
+func (e *ExcludePaths) Get() interface{} { return ExcludePaths(*e) }
+
+// String return slice in a string
+func (e *ExcludePaths) String() string { return fmt.Sprintf("%v", *e) }
+
+// SetValue sets ExcludePaths into the parser
+func (e *ExcludePaths) SetValue(val interface{}) {
+	*e = ExcludePaths(val.(ExcludePaths))
 }
 
 // ClientTLS holds TLS specific configurations as client
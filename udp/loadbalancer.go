@@ -0,0 +1,76 @@
+package udp
+
+import (
+	"sync"
+
+	"github.com/containous/traefik/safe"
+)
+
+// LoadBalancer picks the servers of the new UDP sessions in turn
+type LoadBalancer struct {
+	servers []string
+	current int
+	lock    sync.Mutex
+}
+
+// NewLoadBalancer creates a new LoadBalancer
+func NewLoadBalancer() *LoadBalancer {
+	return &LoadBalancer{}
+}
+
+// AddServer adds the address of a server to the load balancer
+func (b *LoadBalancer) AddServer(address string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.servers = append(b.servers, address)
+}
+
+// Servers returns the addresses of the servers of the load balancer
+func (b *LoadBalancer) Servers() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]string{}, b.servers...)
+}
+
+// NextServer returns the address of the next server, or an empty string if there is no server
+func (b *LoadBalancer) NextServer() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.servers) == 0 {
+		return ""
+	}
+	if b.current >= len(b.servers) {
+		b.current = 0
+	}
+	next := b.servers[b.current]
+	b.current++
+	return next
+}
+
+// LoadBalancerSwitcher allows hot switching of UDP load balancers
+type LoadBalancerSwitcher struct {
+	loadBalancer *safe.Safe
+}
+
+// NewLoadBalancerSwitcher builds a new instance of LoadBalancerSwitcher
+func NewLoadBalancerSwitcher(newLoadBalancer *LoadBalancer) *LoadBalancerSwitcher {
+	return &LoadBalancerSwitcher{
+		loadBalancer: safe.New(newLoadBalancer),
+	}
+}
+
+// NextServer returns the address of the next server of the current load balancer
+func (s *LoadBalancerSwitcher) NextServer() string {
+	return s.GetLoadBalancer().NextServer()
+}
+
+// GetLoadBalancer returns the current load balancer
+func (s *LoadBalancerSwitcher) GetLoadBalancer() *LoadBalancer {
+	return s.loadBalancer.Get().(*LoadBalancer)
+}
+
+// UpdateLoadBalancer safely updates the current load balancer with a new one
+func (s *LoadBalancerSwitcher) UpdateLoadBalancer(newLoadBalancer *LoadBalancer) {
+	s.loadBalancer.Set(newLoadBalancer)
+}
@@ -0,0 +1,152 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// DefaultSessionTimeout is the duration after which an inactive UDP session is closed
+const DefaultSessionTimeout = 30 * time.Second
+
+// maxDatagramSize is the maximum size of a UDP payload
+const maxDatagramSize = 65535
+
+// ServerPicker returns the address of the server of a new UDP session
+type ServerPicker interface {
+	NextServer() string
+}
+
+// Proxy forwards the datagrams received on a connection to the backend servers.
+// The datagrams of a client are sent to the same server as long as its session is active,
+// and the replies of the server are sent back to the client.
+type Proxy struct {
+	conn           net.PacketConn
+	picker         ServerPicker
+	sessionTimeout time.Duration
+	sessions       map[string]*session
+	lock           sync.Mutex
+}
+
+type session struct {
+	backendConn net.Conn
+	lastActive  int64
+}
+
+func (s *session) touch() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+}
+
+func (s *session) idleSince() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastActive)))
+}
+
+// NewProxy creates a new Proxy
+func NewProxy(conn net.PacketConn, picker ServerPicker, sessionTimeout time.Duration) *Proxy {
+	return &Proxy{
+		conn:           conn,
+		picker:         picker,
+		sessionTimeout: sessionTimeout,
+		sessions:       make(map[string]*session),
+	}
+}
+
+// Serve forwards the datagrams until the connection is closed
+func (p *Proxy) Serve() error {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, clientAddr, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		sess, err := p.getSession(clientAddr)
+		if err != nil {
+			log.Errorf("Error creating UDP session for %s: %v", clientAddr, err)
+			continue
+		}
+
+		if _, err := sess.backendConn.Write(buf[:n]); err != nil {
+			log.Debugf("Error forwarding UDP datagram from %s to %s: %v", clientAddr, sess.backendConn.RemoteAddr(), err)
+		}
+	}
+}
+
+// getSession returns the session of the client, created if there is none, touched under the lock
+// for the session not to be closed by its reply loop before the datagram is forwarded
+func (p *Proxy) getSession(clientAddr net.Addr) (*session, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if sess, ok := p.sessions[clientAddr.String()]; ok {
+		sess.touch()
+		return sess, nil
+	}
+
+	address := p.picker.NextServer()
+	if len(address) == 0 {
+		return nil, errors.New("no UDP server available")
+	}
+
+	backendConn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &session{backendConn: backendConn}
+	sess.touch()
+	p.sessions[clientAddr.String()] = sess
+	go p.reply(clientAddr, sess)
+
+	return sess, nil
+}
+
+// reply sends the datagrams of the server back to the client, until the session is inactive
+func (p *Proxy) reply(clientAddr net.Addr, sess *session) {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		if err := sess.backendConn.SetReadDeadline(time.Now().Add(p.sessionTimeout)); err != nil {
+			log.Errorf("Error setting UDP session deadline: %v", err)
+			p.closeSession(clientAddr, sess, false)
+			return
+		}
+
+		n, err := sess.backendConn.Read(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if p.closeSession(clientAddr, sess, true) {
+					return
+				}
+				continue
+			}
+			p.closeSession(clientAddr, sess, false)
+			return
+		}
+		sess.touch()
+
+		if _, err := p.conn.WriteTo(buf[:n], clientAddr); err != nil {
+			log.Debugf("Error sending UDP datagram back to %s: %v", clientAddr, err)
+		}
+	}
+}
+
+// closeSession removes the session of the client, if it is still the current one, and closes it.
+// When onlyIfIdle is set, the session is kept if a datagram of the client has been received in the meantime,
+// and closeSession returns whether it has been closed.
+func (p *Proxy) closeSession(clientAddr net.Addr, sess *session, onlyIfIdle bool) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if onlyIfIdle && sess.idleSince() < p.sessionTimeout {
+		return false
+	}
+	if p.sessions[clientAddr.String()] == sess {
+		delete(p.sessions, clientAddr.String())
+	}
+	sess.backendConn.Close()
+	return true
+}
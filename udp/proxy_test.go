@@ -0,0 +1,139 @@
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startEchoServer starts a UDP server answering with its name followed by the received datagram
+func startEchoServer(t *testing.T, name string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(append([]byte(name+":"), buf[:n]...), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestProxySessions(t *testing.T) {
+	lb := NewLoadBalancer()
+	lb.AddServer(startEchoServer(t, "server1"))
+	lb.AddServer(startEchoServer(t, "server2"))
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	proxy := NewProxy(conn, NewLoadBalancerSwitcher(lb), 50*time.Millisecond)
+	go proxy.Serve()
+
+	exchange := func(client net.Conn, message string) string {
+		_, err := client.Write([]byte(message))
+		require.NoError(t, err)
+
+		require.NoError(t, client.SetReadDeadline(time.Now().Add(5*time.Second)))
+		buf := make([]byte, maxDatagramSize)
+		n, err := client.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	client1, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client1.Close()
+	client2, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client2.Close()
+
+	// the datagrams of a session go to the same server
+	assert.Equal(t, "server1:foo", exchange(client1, "foo"))
+	assert.Equal(t, "server1:bar", exchange(client1, "bar"))
+	assert.Equal(t, "server2:foo", exchange(client2, "foo"))
+
+	// a new session is created once the previous one is inactive
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, "server1:baz", exchange(client1, "baz"))
+}
+
+func TestProxyCloseSession(t *testing.T) {
+	clientAddr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}
+	newSession := func(t *testing.T, idle time.Duration) *session {
+		backendConn, err := net.Dial("udp", startEchoServer(t, "server"))
+		require.NoError(t, err)
+		return &session{backendConn: backendConn, lastActive: time.Now().Add(-idle).UnixNano()}
+	}
+
+	testCases := []struct {
+		desc            string
+		idle            time.Duration
+		replaced        bool
+		expectedClosed  bool
+		expectedCurrent bool
+	}{
+		{
+			desc:            "session active in the meantime",
+			expectedCurrent: true,
+		},
+		{
+			desc:           "inactive session",
+			idle:           time.Minute,
+			expectedClosed: true,
+		},
+		{
+			desc:            "inactive session replaced by a new one",
+			idle:            time.Minute,
+			replaced:        true,
+			expectedClosed:  true,
+			expectedCurrent: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			proxy := NewProxy(nil, nil, time.Second)
+			sess := newSession(t, test.idle)
+			current := sess
+			if test.replaced {
+				current = newSession(t, 0)
+			}
+			proxy.sessions[clientAddr.String()] = current
+
+			assert.Equal(t, test.expectedClosed, proxy.closeSession(clientAddr, sess, true))
+			_, ok := proxy.sessions[clientAddr.String()]
+			assert.Equal(t, test.expectedCurrent, ok)
+			if ok {
+				assert.True(t, current == proxy.sessions[clientAddr.String()])
+			}
+		})
+	}
+}
+
+func TestLoadBalancerSwitcher(t *testing.T) {
+	lb := NewLoadBalancer()
+	switcher := NewLoadBalancerSwitcher(lb)
+	assert.Empty(t, switcher.NextServer())
+
+	newLB := NewLoadBalancer()
+	newLB.AddServer("127.0.0.1:53")
+	switcher.UpdateLoadBalancer(newLB)
+
+	assert.Equal(t, "127.0.0.1:53", switcher.NextServer())
+	assert.Equal(t, "127.0.0.1:53", switcher.NextServer())
+}
@@ -0,0 +1,752 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The opcodes of the instructions, the ones prefixed by 0xFC being compiled to 0xFC00 | their opcode
+const (
+	opUnreachable  = 0x00
+	opNop          = 0x01
+	opBlock        = 0x02
+	opLoop         = 0x03
+	opIf           = 0x04
+	opElse         = 0x05
+	opEnd          = 0x0B
+	opBr           = 0x0C
+	opBrIf         = 0x0D
+	opBrTable      = 0x0E
+	opReturn       = 0x0F
+	opCall         = 0x10
+	opCallIndirect = 0x11
+	opDrop         = 0x1A
+	opSelect       = 0x1B
+	opSelectTyped  = 0x1C
+	opLocalGet     = 0x20
+	opLocalSet     = 0x21
+	opLocalTee     = 0x22
+	opGlobalGet    = 0x23
+	opGlobalSet    = 0x24
+	opI32Load      = 0x28
+	opI64Store32   = 0x3E
+	opMemorySize   = 0x3F
+	opMemoryGrow   = 0x40
+	opI32Const     = 0x41
+	opI64Const     = 0x42
+	opF32Const     = 0x43
+	opF64Const     = 0x44
+	opI32Eqz       = 0x45
+	opI64Extend32S = 0xC4
+	opRefNull      = 0xD0
+	opRefFunc      = 0xD2
+	opPrefix       = 0xFC
+
+	opI32TruncSatF32S = 0xFC00
+	opI64TruncSatF64U = 0xFC07
+	opMemoryInit      = 0xFC08
+	opDataDrop        = 0xFC09
+	opMemoryCopy      = 0xFC0A
+	opMemoryFill      = 0xFC0B
+)
+
+// instruction is a compiled instruction. The branches jump to target, moving the arity values at the top
+// of the operand stack to height, and the others keep their immediate in value.
+type instruction struct {
+	opcode uint16
+	arity  uint32
+	height uint32
+	target uint32
+	value  uint64
+}
+
+// branch is a target of a br_table instruction
+type branch struct {
+	target uint32
+	arity  uint32
+	height uint32
+}
+
+// memoryAccess holds the type of the value loaded or stored by a memory instruction, and the log of its size
+type memoryAccess struct {
+	typ     ValueType
+	sizeLog uint32
+}
+
+var memoryAccesses = map[byte]memoryAccess{
+	0x28: {I32, 2}, 0x29: {I64, 3}, 0x2A: {F32, 2}, 0x2B: {F64, 3},
+	0x2C: {I32, 0}, 0x2D: {I32, 0}, 0x2E: {I32, 1}, 0x2F: {I32, 1},
+	0x30: {I64, 0}, 0x31: {I64, 0}, 0x32: {I64, 1}, 0x33: {I64, 1}, 0x34: {I64, 2}, 0x35: {I64, 2},
+	0x36: {I32, 2}, 0x37: {I64, 3}, 0x38: {F32, 2}, 0x39: {F64, 3},
+	0x3A: {I32, 0}, 0x3B: {I32, 1}, 0x3C: {I64, 0}, 0x3D: {I64, 1}, 0x3E: {I64, 2},
+}
+
+// numericTypes holds the types of the numeric instructions, by opcode
+var numericTypes = make(map[uint16]FunctionType)
+
+func init() {
+	ranges := []struct {
+		first, last uint16
+		params      []ValueType
+		result      ValueType
+	}{
+		{0x45, 0x45, []ValueType{I32}, I32},
+		{0x46, 0x4F, []ValueType{I32, I32}, I32},
+		{0x50, 0x50, []ValueType{I64}, I32},
+		{0x51, 0x5A, []ValueType{I64, I64}, I32},
+		{0x5B, 0x60, []ValueType{F32, F32}, I32},
+		{0x61, 0x66, []ValueType{F64, F64}, I32},
+		{0x67, 0x69, []ValueType{I32}, I32},
+		{0x6A, 0x78, []ValueType{I32, I32}, I32},
+		{0x79, 0x7B, []ValueType{I64}, I64},
+		{0x7C, 0x8A, []ValueType{I64, I64}, I64},
+		{0x8B, 0x91, []ValueType{F32}, F32},
+		{0x92, 0x98, []ValueType{F32, F32}, F32},
+		{0x99, 0x9F, []ValueType{F64}, F64},
+		{0xA0, 0xA6, []ValueType{F64, F64}, F64},
+		{0xA7, 0xA7, []ValueType{I64}, I32},
+		{0xA8, 0xA9, []ValueType{F32}, I32},
+		{0xAA, 0xAB, []ValueType{F64}, I32},
+		{0xAC, 0xAD, []ValueType{I32}, I64},
+		{0xAE, 0xAF, []ValueType{F32}, I64},
+		{0xB0, 0xB1, []ValueType{F64}, I64},
+		{0xB2, 0xB3, []ValueType{I32}, F32},
+		{0xB4, 0xB5, []ValueType{I64}, F32},
+		{0xB6, 0xB6, []ValueType{F64}, F32},
+		{0xB7, 0xB8, []ValueType{I32}, F64},
+		{0xB9, 0xBA, []ValueType{I64}, F64},
+		{0xBB, 0xBB, []ValueType{F32}, F64},
+		{0xBC, 0xBC, []ValueType{F32}, I32},
+		{0xBD, 0xBD, []ValueType{F64}, I64},
+		{0xBE, 0xBE, []ValueType{I32}, F32},
+		{0xBF, 0xBF, []ValueType{I64}, F64},
+		{0xC0, 0xC1, []ValueType{I32}, I32},
+		{0xC2, 0xC4, []ValueType{I64}, I64},
+		{0xFC00, 0xFC01, []ValueType{F32}, I32},
+		{0xFC02, 0xFC03, []ValueType{F64}, I32},
+		{0xFC04, 0xFC05, []ValueType{F32}, I64},
+		{0xFC06, 0xFC07, []ValueType{F64}, I64},
+	}
+	for _, r := range ranges {
+		for opcode := r.first; opcode <= r.last; opcode++ {
+			numericTypes[opcode] = FunctionType{Params: r.params, Results: []ValueType{r.result}}
+		}
+	}
+}
+
+// controlFrame is a block, loop or if being compiled, the function body being the outermost block
+type controlFrame struct {
+	opcode      byte
+	params      []ValueType
+	results     []ValueType
+	height      int
+	unreachable bool
+	// start is the beginning of the loop, its branches target
+	start int
+	// fixups are the branches to the end of the block, targeting it once it is compiled
+	fixups []fixup
+	// elseFixup is the if instruction jumping to the else branch, or to the end without else
+	elseFixup int
+}
+
+// fixup is a branch instruction, or an entry of the table of a br_table instruction when entry is not -1
+type fixup struct {
+	pc    int
+	entry int
+}
+
+// labelTypes returns the types of the values a branch to the frame moves
+func (f *controlFrame) labelTypes() []ValueType {
+	if f.opcode == opLoop {
+		return f.params
+	}
+	return f.results
+}
+
+// compiler validates the code of a function, as specified by the validation algorithm of the specification,
+// and compiles it with the heights of the operand stack found by the validation
+type compiler struct {
+	module    *Module
+	r         *reader
+	locals    []ValueType
+	operands  []ValueType
+	controls  []*controlFrame
+	code      []instruction
+	brTables  [][]branch
+	maxHeight int
+}
+
+func (m *Module) compileFunction(fn *function, r *reader) error {
+	locals := append([]ValueType(nil), fn.typ.Params...)
+	for i, n := 0, r.u32(); i < int(n) && r.err == nil; i++ {
+		count, typ := r.u32(), r.valueType()
+		if uint64(len(locals))+uint64(count) > maxLocals {
+			return errors.New("too many locals")
+		}
+		for j := uint32(0); j < count; j++ {
+			locals = append(locals, typ)
+		}
+	}
+
+	c := &compiler{module: m, r: r, locals: locals}
+	c.pushControl(opBlock, nil, fn.typ.Results)
+	for len(c.controls) > 0 && r.err == nil {
+		if err := c.instruction(); err != nil {
+			return err
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos != len(r.data) {
+		return errors.New("code after the end of the function")
+	}
+
+	fn.numLocals = len(locals)
+	fn.maxHeight = c.maxHeight
+	fn.code = c.code
+	fn.brTables = c.brTables
+	return nil
+}
+
+// functionType returns the type of the function of the index space
+func (c *compiler) functionType(index uint32) (FunctionType, error) {
+	m := c.module
+	if int(index) < len(m.imports) {
+		return m.types[m.imports[index].typeIndex], nil
+	}
+	if int(index) < len(m.imports)+len(m.functions) {
+		return m.functions[int(index)-len(m.imports)].typ, nil
+	}
+	return FunctionType{}, fmt.Errorf("unknown function %d", index)
+}
+
+func (c *compiler) emit(in instruction) int {
+	c.code = append(c.code, in)
+	return len(c.code) - 1
+}
+
+func (c *compiler) push(t ValueType) {
+	c.operands = append(c.operands, t)
+	if len(c.operands) > c.maxHeight {
+		c.maxHeight = len(c.operands)
+	}
+}
+
+func (c *compiler) pushTypes(types []ValueType) {
+	for _, t := range types {
+		c.push(t)
+	}
+}
+
+// pop pops an operand, of unknown type when the stack is empty in unreachable code
+func (c *compiler) pop() (ValueType, error) {
+	frame := c.controls[len(c.controls)-1]
+	if len(c.operands) == frame.height {
+		if frame.unreachable {
+			return unknown, nil
+		}
+		return unknown, errors.New("type mismatch: operand stack empty")
+	}
+	t := c.operands[len(c.operands)-1]
+	c.operands = c.operands[:len(c.operands)-1]
+	return t, nil
+}
+
+func (c *compiler) popExpect(expected ValueType) (ValueType, error) {
+	actual, err := c.pop()
+	if err != nil {
+		return actual, err
+	}
+	if actual != unknown && expected != unknown && actual != expected {
+		return actual, fmt.Errorf("type mismatch: %v instead of %v", actual, expected)
+	}
+	if actual == unknown {
+		return expected, nil
+	}
+	return actual, nil
+}
+
+// popTypes pops operands of the types, and returns their actual types
+func (c *compiler) popTypes(types []ValueType) ([]ValueType, error) {
+	popped := make([]ValueType, len(types))
+	for i := len(types) - 1; i >= 0; i-- {
+		t, err := c.popExpect(types[i])
+		if err != nil {
+			return nil, err
+		}
+		popped[i] = t
+	}
+	return popped, nil
+}
+
+func (c *compiler) pushControl(opcode byte, params, results []ValueType) *controlFrame {
+	frame := &controlFrame{opcode: opcode, params: params, results: results, height: len(c.operands), elseFixup: -1}
+	c.controls = append(c.controls, frame)
+	c.pushTypes(params)
+	return frame
+}
+
+// endFrame checks that the operand stack holds the results of the frame
+func (c *compiler) endFrame() (*controlFrame, error) {
+	frame := c.controls[len(c.controls)-1]
+	if _, err := c.popTypes(frame.results); err != nil {
+		return nil, err
+	}
+	if len(c.operands) != frame.height {
+		return nil, errors.New("type mismatch: values left on the operand stack")
+	}
+	return frame, nil
+}
+
+func (c *compiler) setUnreachable() {
+	frame := c.controls[len(c.controls)-1]
+	c.operands = c.operands[:frame.height]
+	frame.unreachable = true
+}
+
+// label returns the frame targeted by a branch of the depth
+func (c *compiler) label(depth uint32) (*controlFrame, error) {
+	if int(depth) >= len(c.controls) {
+		return nil, fmt.Errorf("unknown label %d", depth)
+	}
+	return c.controls[len(c.controls)-1-int(depth)], nil
+}
+
+// branchTo returns the branch to the frame, its target being fixed up at the end of the frame unless it is a loop
+func (c *compiler) branchTo(frame *controlFrame, f fixup) branch {
+	b := branch{arity: uint32(len(frame.labelTypes())), height: uint32(frame.height)}
+	if frame.opcode == opLoop {
+		b.target = uint32(frame.start)
+	} else {
+		frame.fixups = append(frame.fixups, f)
+	}
+	return b
+}
+
+func (c *compiler) blockType() (FunctionType, error) {
+	if c.r.pos >= len(c.r.data) {
+		return FunctionType{}, errUnexpectedEnd
+	}
+	switch b := ValueType(c.r.data[c.r.pos]); b {
+	case 0x40:
+		c.r.pos++
+		return FunctionType{}, nil
+	case I32, I64, F32, F64:
+		c.r.pos++
+		return FunctionType{Results: []ValueType{b}}, nil
+	}
+	index := c.r.s33()
+	if c.r.err != nil {
+		return FunctionType{}, c.r.err
+	}
+	if index < 0 || index >= int64(len(c.module.types)) {
+		return FunctionType{}, fmt.Errorf("unknown block type %d", index)
+	}
+	return c.module.types[index], nil
+}
+
+// memoryArgument reads the alignment and offset of a memory instruction, the alignment being at most the size
+// of the access
+func (c *compiler) memoryArgument(sizeLog uint32) (uint32, error) {
+	align, offset := c.r.u32(), c.r.u32()
+	if c.r.err != nil {
+		return 0, c.r.err
+	}
+	if c.module.memory == nil {
+		return 0, errors.New("memory instruction without memory")
+	}
+	if align > sizeLog {
+		return 0, fmt.Errorf("alignment 2^%d larger than the access", align)
+	}
+	return offset, nil
+}
+
+func (c *compiler) zeroByte() error {
+	if c.r.byte() != 0 {
+		return errors.New("unsupported memory index")
+	}
+	if c.module.memory == nil {
+		return errors.New("memory instruction without memory")
+	}
+	return c.r.err
+}
+
+func (c *compiler) popI32s(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := c.popExpect(I32); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instruction validates and compiles the next instruction
+func (c *compiler) instruction() error {
+	r := c.r
+	op := r.byte()
+	if r.err != nil {
+		return r.err
+	}
+
+	if access, ok := memoryAccesses[op]; ok {
+		offset, err := c.memoryArgument(access.sizeLog)
+		if err != nil {
+			return err
+		}
+		if op <= 0x35 {
+			if _, err := c.popExpect(I32); err != nil {
+				return err
+			}
+			c.push(access.typ)
+		} else {
+			if _, err := c.popExpect(access.typ); err != nil {
+				return err
+			}
+			if _, err := c.popExpect(I32); err != nil {
+				return err
+			}
+		}
+		c.emit(instruction{opcode: uint16(op), value: uint64(offset)})
+		return nil
+	}
+
+	opcode := uint16(op)
+	if op == opPrefix {
+		sub := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if sub > 0xFF {
+			return fmt.Errorf("unsupported opcode 0xFC %d", sub)
+		}
+		opcode = opPrefix<<8 | uint16(sub)
+	}
+	if t, ok := numericTypes[opcode]; ok {
+		if _, err := c.popTypes(t.Params); err != nil {
+			return err
+		}
+		c.pushTypes(t.Results)
+		c.emit(instruction{opcode: opcode})
+		return nil
+	}
+
+	switch opcode {
+	case opUnreachable:
+		c.emit(instruction{opcode: opUnreachable})
+		c.setUnreachable()
+
+	case opNop:
+
+	case opBlock, opLoop, opIf:
+		t, err := c.blockType()
+		if err != nil {
+			return err
+		}
+		if op == opIf {
+			if _, err := c.popExpect(I32); err != nil {
+				return err
+			}
+		}
+		if _, err := c.popTypes(t.Params); err != nil {
+			return err
+		}
+		frame := c.pushControl(op, t.Params, t.Results)
+		frame.start = len(c.code)
+		if op == opIf {
+			frame.elseFixup = c.emit(instruction{opcode: opIf})
+		}
+
+	case opElse:
+		frame := c.controls[len(c.controls)-1]
+		if frame.opcode != opIf {
+			return errors.New("else without if")
+		}
+		if _, err := c.endFrame(); err != nil {
+			return err
+		}
+		frame.fixups = append(frame.fixups, fixup{pc: c.emit(instruction{opcode: opElse}), entry: -1})
+		c.code[frame.elseFixup].target = uint32(len(c.code))
+		frame.elseFixup = -1
+		frame.opcode = opElse
+		frame.unreachable = false
+		c.pushTypes(frame.params)
+
+	case opEnd:
+		frame, err := c.endFrame()
+		if err != nil {
+			return err
+		}
+		if frame.opcode == opIf {
+			// without else, the if passes its parameters as results
+			if !equalValueTypes(frame.params, frame.results) {
+				return errors.New("type mismatch: if without else")
+			}
+			c.code[frame.elseFixup].target = uint32(len(c.code))
+		}
+		end := uint32(len(c.code))
+		for _, f := range frame.fixups {
+			if f.entry < 0 {
+				c.code[f.pc].target = end
+			} else {
+				c.brTables[c.code[f.pc].value][f.entry].target = end
+			}
+		}
+		c.controls = c.controls[:len(c.controls)-1]
+		if len(c.controls) == 0 {
+			c.emit(instruction{opcode: opReturn, arity: uint32(len(frame.results))})
+		} else {
+			c.pushTypes(frame.results)
+		}
+
+	case opBr, opBrIf:
+		frame, err := c.label(r.u32())
+		if err != nil {
+			return err
+		}
+		if op == opBrIf {
+			if _, err := c.popExpect(I32); err != nil {
+				return err
+			}
+		}
+		types, err := c.popTypes(frame.labelTypes())
+		if err != nil {
+			return err
+		}
+		b := c.branchTo(frame, fixup{pc: len(c.code), entry: -1})
+		c.emit(instruction{opcode: opcode, target: b.target, arity: b.arity, height: b.height})
+		if op == opBr {
+			c.setUnreachable()
+		} else {
+			c.pushTypes(types)
+		}
+
+	case opBrTable:
+		n := r.count()
+		depths := make([]uint32, n+1)
+		for i := range depths {
+			depths[i] = r.u32()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if _, err := c.popExpect(I32); err != nil {
+			return err
+		}
+		defaultFrame, err := c.label(depths[n])
+		if err != nil {
+			return err
+		}
+		arity := len(defaultFrame.labelTypes())
+		pc := len(c.code)
+		table := make([]branch, len(depths))
+		for i, depth := range depths {
+			frame, err := c.label(depth)
+			if err != nil {
+				return err
+			}
+			if len(frame.labelTypes()) != arity {
+				return errors.New("type mismatch: br_table labels of different arities")
+			}
+			types, err := c.popTypes(frame.labelTypes())
+			if err != nil {
+				return err
+			}
+			c.pushTypes(types)
+			table[i] = c.branchTo(frame, fixup{pc: pc, entry: i})
+		}
+		c.brTables = append(c.brTables, table)
+		c.emit(instruction{opcode: opBrTable, value: uint64(len(c.brTables) - 1)})
+		if _, err := c.popTypes(defaultFrame.labelTypes()); err != nil {
+			return err
+		}
+		c.setUnreachable()
+
+	case opReturn:
+		results := c.controls[0].results
+		if _, err := c.popTypes(results); err != nil {
+			return err
+		}
+		c.emit(instruction{opcode: opReturn, arity: uint32(len(results))})
+		c.setUnreachable()
+
+	case opCall:
+		index := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		t, err := c.functionType(index)
+		if err != nil {
+			return err
+		}
+		if _, err := c.popTypes(t.Params); err != nil {
+			return err
+		}
+		c.pushTypes(t.Results)
+		c.emit(instruction{opcode: opCall, target: index})
+
+	case opCallIndirect:
+		typeIndex, table := r.u32(), r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if int(typeIndex) >= len(c.module.types) {
+			return fmt.Errorf("unknown type %d", typeIndex)
+		}
+		if table != 0 || c.module.table == nil {
+			return fmt.Errorf("unknown table %d", table)
+		}
+		if _, err := c.popExpect(I32); err != nil {
+			return err
+		}
+		t := c.module.types[typeIndex]
+		if _, err := c.popTypes(t.Params); err != nil {
+			return err
+		}
+		c.pushTypes(t.Results)
+		c.emit(instruction{opcode: opCallIndirect, target: typeIndex})
+
+	case opDrop:
+		if _, err := c.pop(); err != nil {
+			return err
+		}
+		c.emit(instruction{opcode: opDrop})
+
+	case opSelect, opSelectTyped:
+		expected := unknown
+		if op == opSelectTyped {
+			if r.u32() != 1 {
+				return errors.New("invalid select arity")
+			}
+			expected = r.valueType()
+			if r.err != nil {
+				return r.err
+			}
+		}
+		if _, err := c.popExpect(I32); err != nil {
+			return err
+		}
+		t1, err := c.popExpect(expected)
+		if err != nil {
+			return err
+		}
+		t2, err := c.popExpect(t1)
+		if err != nil {
+			return err
+		}
+		if t2 == unknown {
+			t2 = t1
+		}
+		c.push(t2)
+		c.emit(instruction{opcode: opSelect})
+
+	case opLocalGet, opLocalSet, opLocalTee:
+		index := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if int(index) >= len(c.locals) {
+			return fmt.Errorf("unknown local %d", index)
+		}
+		t := c.locals[index]
+		if op == opLocalGet {
+			c.push(t)
+		} else {
+			if _, err := c.popExpect(t); err != nil {
+				return err
+			}
+			if op == opLocalTee {
+				c.push(t)
+			}
+		}
+		c.emit(instruction{opcode: opcode, value: uint64(index)})
+
+	case opGlobalGet, opGlobalSet:
+		index := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if int(index) >= len(c.module.globals) {
+			return fmt.Errorf("unknown global %d", index)
+		}
+		g := c.module.globals[index]
+		if op == opGlobalGet {
+			c.push(g.typ)
+		} else {
+			if !g.mutable {
+				return fmt.Errorf("global %d is immutable", index)
+			}
+			if _, err := c.popExpect(g.typ); err != nil {
+				return err
+			}
+		}
+		c.emit(instruction{opcode: opcode, value: uint64(index)})
+
+	case opMemorySize, opMemoryGrow:
+		if err := c.zeroByte(); err != nil {
+			return err
+		}
+		if op == opMemoryGrow {
+			if _, err := c.popExpect(I32); err != nil {
+				return err
+			}
+		}
+		c.push(I32)
+		c.emit(instruction{opcode: opcode})
+
+	case opI32Const:
+		c.push(I32)
+		c.emit(instruction{opcode: opcode, value: uint64(uint32(r.s32()))})
+	case opI64Const:
+		c.push(I64)
+		c.emit(instruction{opcode: opcode, value: uint64(r.s64())})
+	case opF32Const:
+		c.push(F32)
+		c.emit(instruction{opcode: opcode, value: uint64(r.u32le())})
+	case opF64Const:
+		c.push(F64)
+		c.emit(instruction{opcode: opcode, value: r.u64le()})
+
+	case opMemoryInit, opDataDrop:
+		index := r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if c.module.dataCount < 0 {
+			return errors.New("data segment instruction without data count section")
+		}
+		if int(index) >= c.module.dataCount {
+			return fmt.Errorf("unknown data segment %d", index)
+		}
+		if opcode == opMemoryInit {
+			if err := c.zeroByte(); err != nil {
+				return err
+			}
+			if err := c.popI32s(3); err != nil {
+				return err
+			}
+		}
+		c.emit(instruction{opcode: opcode, target: index})
+
+	case opMemoryCopy, opMemoryFill:
+		if opcode == opMemoryCopy {
+			if err := c.zeroByte(); err != nil {
+				return err
+			}
+		}
+		if err := c.zeroByte(); err != nil {
+			return err
+		}
+		if err := c.popI32s(3); err != nil {
+			return err
+		}
+		c.emit(instruction{opcode: opcode})
+
+	default:
+		if opcode > 0xFF {
+			return fmt.Errorf("unsupported opcode 0xFC %d", opcode&0xFF)
+		}
+		return fmt.Errorf("unsupported opcode %#x", opcode)
+	}
+	return r.err
+}
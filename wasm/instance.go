@@ -0,0 +1,240 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+const (
+	// DefaultMaxMemoryPages is the default number of pages an instance memory can grow to, i.e. 16MB
+	DefaultMaxMemoryPages = 256
+	// DefaultMaxInstructions is the default number of instructions a call of an exported function can execute
+	DefaultMaxInstructions = 100000000
+
+	maxTableSize   = 1 << 20
+	maxCallDepth   = 10000
+	maxStackValues = 1 << 20
+)
+
+// Config limits the resources of the instances
+type Config struct {
+	// MaxMemoryPages is the number of pages of 64KB the memory can grow to, DefaultMaxMemoryPages when 0
+	MaxMemoryPages uint32
+	// MaxInstructions is the number of instructions a call of an exported function can execute,
+	// DefaultMaxInstructions when 0
+	MaxInstructions uint64
+}
+
+// HostFunction is a function of the host imported by the modules. It gets its parameters at the beginning of stack,
+// and writes its results there. An error ends the call of the exported function, as a trap does.
+type HostFunction struct {
+	Type FunctionType
+	Call func(instance *Instance, stack []uint64) error
+}
+
+// Imports holds the host functions importable by the modules, by module name then function name
+type Imports map[string]map[string]HostFunction
+
+// Trap is the error of a call ended by a trap
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string {
+	return "wasm: trap: " + t.Reason
+}
+
+func trap(format string, args ...interface{}) *Trap {
+	return &Trap{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Instance is an instance of a module, with its own memory, globals and table. It is not safe for concurrent use.
+type Instance struct {
+	module          *Module
+	hostFunctions   []HostFunction
+	memory          []byte
+	maxPages        uint32
+	globals         []uint64
+	table           []int64
+	droppedData     []bool
+	maxInstructions uint64
+	stack           []uint64
+	frames          []callFrame
+}
+
+// callFrame is a call of a defined function, its locals beginning at base on the value stack
+type callFrame struct {
+	fn   *function
+	pc   int
+	base int
+}
+
+// Instantiate instantiates the module, with the host functions it imports, and runs its start function
+func (m *Module) Instantiate(imports Imports, config Config) (*Instance, error) {
+	inst := &Instance{
+		module:          m,
+		maxPages:        config.MaxMemoryPages,
+		maxInstructions: config.MaxInstructions,
+		globals:         make([]uint64, len(m.globals)),
+		droppedData:     make([]bool, len(m.data)),
+	}
+	if inst.maxPages == 0 {
+		inst.maxPages = DefaultMaxMemoryPages
+	}
+	if inst.maxInstructions == 0 {
+		inst.maxInstructions = DefaultMaxInstructions
+	}
+
+	for _, i := range m.imports {
+		hostFunction, ok := imports[i.module][i.name]
+		if !ok {
+			return nil, fmt.Errorf("wasm: unknown import %s.%s", i.module, i.name)
+		}
+		if t := m.types[i.typeIndex]; !hostFunction.Type.equal(t) {
+			return nil, fmt.Errorf("wasm: import %s.%s of type %v instead of %v", i.module, i.name, t, hostFunction.Type)
+		}
+		inst.hostFunctions = append(inst.hostFunctions, hostFunction)
+	}
+
+	if m.memory != nil {
+		if m.memory.hasMax && m.memory.max < inst.maxPages {
+			inst.maxPages = m.memory.max
+		}
+		if m.memory.min > inst.maxPages {
+			return nil, fmt.Errorf("wasm: memory of %d pages, more than the limit of %d", m.memory.min, inst.maxPages)
+		}
+		inst.memory = make([]byte, int(m.memory.min)*PageSize)
+	}
+	if m.table != nil {
+		if m.table.min > maxTableSize {
+			return nil, fmt.Errorf("wasm: table of %d elements, more than the limit of %d", m.table.min, maxTableSize)
+		}
+		inst.table = make([]int64, m.table.min)
+		for i := range inst.table {
+			inst.table[i] = -1
+		}
+	}
+
+	for i, g := range m.globals {
+		inst.globals[i] = inst.constant(g.init)
+	}
+	for _, e := range m.elements {
+		if !e.active {
+			continue
+		}
+		offset := uint64(uint32(inst.constant(e.offset)))
+		if offset+uint64(len(e.functions)) > uint64(len(inst.table)) {
+			return nil, errors.New("wasm: element segment out of the table")
+		}
+		copy(inst.table[offset:], e.functions)
+	}
+	for i, d := range m.data {
+		if !d.active {
+			continue
+		}
+		offset := uint64(uint32(inst.constant(d.offset)))
+		if offset+uint64(len(d.init)) > uint64(len(inst.memory)) {
+			return nil, errors.New("wasm: data segment out of the memory")
+		}
+		copy(inst.memory[offset:], d.init)
+		inst.droppedData[i] = true
+	}
+
+	if m.start >= 0 {
+		if _, err := inst.call(uint32(m.start), nil); err != nil {
+			return nil, err
+		}
+	}
+	return inst, nil
+}
+
+func (inst *Instance) constant(e constantExpression) uint64 {
+	if e.opcode == opGlobalGet {
+		return inst.globals[e.value]
+	}
+	return e.value
+}
+
+// ExportedFunction returns the type of the exported function
+func (inst *Instance) ExportedFunction(name string) (FunctionType, bool) {
+	e, ok := inst.module.exports[name]
+	if !ok || e.kind != externalFunction {
+		return FunctionType{}, false
+	}
+	return inst.functionType(e.index), true
+}
+
+func (inst *Instance) functionType(index uint32) FunctionType {
+	if int(index) < len(inst.hostFunctions) {
+		return inst.hostFunctions[index].Type
+	}
+	return inst.module.functions[int(index)-len(inst.hostFunctions)].typ
+}
+
+// Call calls the exported function with the arguments, and returns its results
+func (inst *Instance) Call(name string, args ...uint64) ([]uint64, error) {
+	e, ok := inst.module.exports[name]
+	if !ok || e.kind != externalFunction {
+		return nil, fmt.Errorf("wasm: unknown exported function %s", name)
+	}
+	if t := inst.functionType(e.index); len(args) != len(t.Params) {
+		return nil, fmt.Errorf("wasm: function %s called with %d arguments instead of %d", name, len(args), len(t.Params))
+	}
+	return inst.call(e.index, args)
+}
+
+// call calls the function of the index space, the runtime errors of malformed states becoming traps
+// instead of crashing the host
+func (inst *Instance) call(index uint32, args []uint64) (results []uint64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if runtimeError, ok := r.(runtime.Error); ok {
+				results, err = nil, trap("%v", runtimeError)
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	t := inst.functionType(index)
+	stack := append(inst.stack[:0], args...)
+	if int(index) < len(inst.hostFunctions) {
+		for len(stack) < len(t.Results) {
+			stack = append(stack, 0)
+		}
+		if err := inst.hostFunctions[index].Call(inst, stack); err != nil {
+			return nil, err
+		}
+		return append([]uint64(nil), stack[:len(t.Results)]...), nil
+	}
+
+	inst.stack = stack
+	inst.frames = inst.frames[:0]
+	if err := inst.execute(inst.module.functions[int(index)-len(inst.hostFunctions)]); err != nil {
+		return nil, err
+	}
+	return append([]uint64(nil), inst.stack[:len(t.Results)]...), nil
+}
+
+// Memory returns the memory of the instance, which becomes stale when the memory grows
+func (inst *Instance) Memory() []byte {
+	return inst.memory
+}
+
+// Read returns the bytes of the memory at offset, or false if they are out of the memory
+func (inst *Instance) Read(offset, length uint32) ([]byte, bool) {
+	if uint64(offset)+uint64(length) > uint64(len(inst.memory)) {
+		return nil, false
+	}
+	return inst.memory[offset : offset+length], true
+}
+
+// Write writes the bytes to the memory at offset, or returns false if they are out of the memory
+func (inst *Instance) Write(offset uint32, b []byte) bool {
+	if uint64(offset)+uint64(len(b)) > uint64(len(inst.memory)) {
+		return false
+	}
+	copy(inst.memory[offset:], b)
+	return true
+}
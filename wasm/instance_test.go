@@ -0,0 +1,392 @@
+package wasm
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceCall(t *testing.T) {
+	i32, i64, f64 := []ValueType{I32}, []ValueType{I64}, []ValueType{F64}
+
+	testCases := []struct {
+		desc            string
+		module          testModule
+		config          Config
+		args            []uint64
+		expectedResults []uint64
+		expectedTrap    string
+	}{
+		{
+			desc: "i32 addition wrapping around",
+			module: testModule{functions: []testFunction{{
+				typ:  signature([]ValueType{I32, I32}, I32),
+				body: code(opLocalGet, 0, opLocalGet, 1, 0x6A),
+			}}},
+			args:            []uint64{math.MaxInt32, 1},
+			expectedResults: []uint64{1 << 31},
+		},
+		{
+			desc: "i64 multiplication",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(i64, I64),
+				body: code(opLocalGet, 0, i64Const(-3), 0x7E),
+			}}},
+			args:            []uint64{1 << 40},
+			expectedResults: []uint64{1<<64 - 3<<40},
+		},
+		{
+			desc: "f64 square root",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(f64, F64),
+				body: code(opLocalGet, 0, 0x9F),
+			}}},
+			args:            []uint64{math.Float64bits(2)},
+			expectedResults: []uint64{math.Float64bits(math.Sqrt2)},
+		},
+		{
+			desc: "signed division by zero",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(i32, I32),
+				body: code(i32Const(1), opLocalGet, 0, 0x6D),
+			}}},
+			args:         []uint64{0},
+			expectedTrap: "integer divide by zero",
+		},
+		{
+			desc: "signed division overflow",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(i32, I32),
+				body: code(i32Const(math.MinInt32), opLocalGet, 0, 0x6D),
+			}}},
+			args:         []uint64{math.MaxUint32},
+			expectedTrap: "integer overflow",
+		},
+		{
+			desc: "truncation of NaN",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(f64Const(math.Float64bits(math.NaN())), 0xAA),
+			}}},
+			expectedTrap: "invalid conversion to integer",
+		},
+		{
+			desc: "saturating truncation",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(f64Const(math.Float64bits(-1e20)), 0xFC, 2),
+			}}},
+			expectedResults: []uint64{1 << 31},
+		},
+		{
+			desc: "loop summing the integers",
+			module: testModule{functions: []testFunction{{
+				typ:    signature(i32, I32),
+				locals: []ValueType{I32},
+				body: code(
+					opBlock, 0x40, opLoop, 0x40,
+					opLocalGet, 0, 0x45, opBrIf, 1,
+					opLocalGet, 1, opLocalGet, 0, 0x6A, opLocalSet, 1,
+					opLocalGet, 0, i32Const(1), 0x6B, opLocalSet, 0,
+					opBr, 0,
+					opEnd, opEnd,
+					opLocalGet, 1,
+				),
+			}}},
+			args:            []uint64{100},
+			expectedResults: []uint64{5050},
+		},
+		{
+			desc: "recursive factorial",
+			module: testModule{functions: []testFunction{{
+				typ: signature(i64, I64),
+				body: code(
+					opLocalGet, 0, 0x50,
+					opIf, byte(I64), i64Const(1),
+					opElse, opLocalGet, 0, opLocalGet, 0, i64Const(1), 0x7D, opCall, 0, 0x7E,
+					opEnd,
+				),
+			}}},
+			args:            []uint64{20},
+			expectedResults: []uint64{2432902008176640000},
+		},
+		{
+			desc: "br_table",
+			module: testModule{functions: []testFunction{{
+				typ: signature(i32, I32),
+				body: code(
+					opBlock, 0x40, opBlock, 0x40, opBlock, 0x40,
+					opLocalGet, 0, opBrTable, 2, 0, 1, 2,
+					opEnd, i32Const(10), opReturn,
+					opEnd, i32Const(20), opReturn,
+					opEnd, i32Const(30),
+				),
+			}}},
+			args:            []uint64{1},
+			expectedResults: []uint64{20},
+		},
+		{
+			desc: "br_table default",
+			module: testModule{functions: []testFunction{{
+				typ: signature(i32, I32),
+				body: code(
+					opBlock, byte(I32), i32Const(7), opLocalGet, 0, opBrTable, 1, 0, 0,
+					opEnd,
+				),
+			}}},
+			args:            []uint64{100},
+			expectedResults: []uint64{7},
+		},
+		{
+			desc: "indirect call",
+			module: testModule{
+				table: []uint32{1, 2},
+				functions: []testFunction{
+					{typ: signature(i32, I32), body: code(i32Const(5), opLocalGet, 0, opCallIndirect, 0, 0)},
+					{typ: signature(i32, I32), body: code(opLocalGet, 0, i32Const(2), 0x6C)},
+					{typ: signature(nil, I32), body: i32Const(1)},
+				},
+			},
+			args:            []uint64{0},
+			expectedResults: []uint64{10},
+		},
+		{
+			desc: "indirect call type mismatch",
+			module: testModule{
+				table: []uint32{1, 2},
+				functions: []testFunction{
+					{typ: signature(i32, I32), body: code(i32Const(5), opLocalGet, 0, opCallIndirect, 0, 0)},
+					{typ: signature(i32, I32), body: code(opLocalGet, 0, i32Const(2), 0x6C)},
+					{typ: signature(nil, I32), body: i32Const(1)},
+				},
+			},
+			args:         []uint64{1},
+			expectedTrap: "indirect call type mismatch",
+		},
+		{
+			desc: "indirect call out of the table",
+			module: testModule{
+				table: []uint32{0},
+				functions: []testFunction{
+					{typ: signature(i32, I32), body: code(i32Const(5), opLocalGet, 0, opCallIndirect, 0, 0)},
+				},
+			},
+			args:         []uint64{1},
+			expectedTrap: "undefined element",
+		},
+		{
+			desc: "memory store and load",
+			module: testModule{memory: []uint32{1}, functions: []testFunction{{
+				typ: signature(i64, I64),
+				body: code(
+					i32Const(8), opLocalGet, 0, 0x37, 3, 0,
+					i32Const(0), 0x31, 0, 9,
+				),
+			}}},
+			args:            []uint64{0xFFAB},
+			expectedResults: []uint64{0xFF},
+		},
+		{
+			desc: "data segment",
+			module: testModule{memory: []uint32{1}, data: []byte("wasm"), functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(i32Const(0), 0x28, 2, 0),
+			}}},
+			expectedResults: []uint64{0x6d736177},
+		},
+		{
+			desc: "out of bounds memory access",
+			module: testModule{memory: []uint32{1}, functions: []testFunction{{
+				typ:  signature(i32, I32),
+				body: code(opLocalGet, 0, 0x28, 2, 0),
+			}}},
+			args:         []uint64{PageSize - 2},
+			expectedTrap: "out of bounds memory access",
+		},
+		{
+			desc: "memory growing",
+			module: testModule{memory: []uint32{1}, functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(i32Const(2), opMemoryGrow, 0, opDrop, opMemorySize, 0),
+			}}},
+			expectedResults: []uint64{3},
+		},
+		{
+			desc: "memory growing beyond the limit",
+			module: testModule{memory: []uint32{1}, functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(i32Const(2), opMemoryGrow, 0),
+			}}},
+			config:          Config{MaxMemoryPages: 2},
+			expectedResults: []uint64{math.MaxUint32},
+		},
+		{
+			desc: "memory growing beyond the module maximum",
+			module: testModule{memory: []uint32{1, 2}, functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(i32Const(2), opMemoryGrow, 0),
+			}}},
+			expectedResults: []uint64{math.MaxUint32},
+		},
+		{
+			desc: "memory fill and copy",
+			module: testModule{memory: []uint32{1}, functions: []testFunction{{
+				typ: signature(nil, I32),
+				body: code(
+					i32Const(1), i32Const(0x61), i32Const(3), 0xFC, 11, 0,
+					i32Const(0), i32Const(2), i32Const(2), 0xFC, 10, 0, 0,
+					i32Const(0), 0x28, 2, 0,
+				),
+			}}},
+			expectedResults: []uint64{0x61616161},
+		},
+		{
+			desc: "mutable global",
+			module: testModule{
+				globals: []testGlobal{{typ: I32, mutable: true, init: i32Const(40)}},
+				functions: []testFunction{{
+					typ:  signature(nil, I32),
+					body: code(opGlobalGet, 0, i32Const(2), 0x6A, opGlobalSet, 0, opGlobalGet, 0),
+				}},
+			},
+			expectedResults: []uint64{42},
+		},
+		{
+			desc: "unreachable",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: code(opUnreachable),
+			}}},
+			expectedTrap: "unreachable",
+		},
+		{
+			desc: "infinite loop",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: code(opLoop, 0x40, opBr, 0, opEnd),
+			}}},
+			config:       Config{MaxInstructions: 1000},
+			expectedTrap: "instruction limit exceeded",
+		},
+		{
+			desc: "infinite recursion",
+			module: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: code(opCall, 0),
+			}}},
+			expectedTrap: "call stack exhausted",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			test.module.functions[0].export = "f"
+			module, err := Compile(test.module.encode())
+			require.NoError(t, err)
+			instance, err := module.Instantiate(nil, test.config)
+			require.NoError(t, err)
+
+			results, err := instance.Call("f", test.args...)
+			if len(test.expectedTrap) > 0 {
+				assert.Equal(t, &Trap{Reason: test.expectedTrap}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedResults, results)
+		})
+	}
+}
+
+func TestInstanceHostFunctions(t *testing.T) {
+	module, err := Compile(testModule{
+		imports: []testImport{
+			{module: "env", name: "add", typ: signature([]ValueType{I32, I32}, I32)},
+			{module: "env", name: "fail", typ: signature(nil)},
+		},
+		memory: []uint32{1},
+		functions: []testFunction{
+			{typ: signature(nil, I32), body: code(i32Const(2), i32Const(3), opCall, 0), export: "add"},
+			{typ: signature(nil), body: code(opCall, 1), export: "fail"},
+		},
+	}.encode())
+	require.NoError(t, err)
+
+	errHost := errors.New("host error")
+	imports := Imports{"env": {
+		"add": {
+			Type: signature([]ValueType{I32, I32}, I32),
+			Call: func(instance *Instance, stack []uint64) error {
+				stack[0] = stack[0] + stack[1]
+				return nil
+			},
+		},
+		"fail": {
+			Type: signature(nil),
+			Call: func(instance *Instance, stack []uint64) error {
+				return errHost
+			},
+		},
+	}}
+
+	instance, err := module.Instantiate(imports, Config{})
+	require.NoError(t, err)
+	results, err := instance.Call("add")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{5}, results)
+	_, err = instance.Call("fail")
+	assert.Equal(t, errHost, err)
+
+	_, err = instance.Call("unknown")
+	assert.EqualError(t, err, "wasm: unknown exported function unknown")
+	_, err = instance.Call("add", 1)
+	assert.EqualError(t, err, "wasm: function add called with 1 arguments instead of 0")
+
+	_, err = module.Instantiate(Imports{"env": {"add": imports["env"]["add"]}}, Config{})
+	assert.EqualError(t, err, "wasm: unknown import env.fail")
+	_, err = module.Instantiate(Imports{"env": {"add": imports["env"]["fail"], "fail": imports["env"]["fail"]}}, Config{})
+	assert.EqualError(t, err, "wasm: import env.add of type [i32 i32] -> [i32] instead of [] -> []")
+}
+
+func TestInstantiateLimits(t *testing.T) {
+	module, err := Compile(testModule{memory: []uint32{3}, functions: []testFunction{{typ: signature(nil)}}}.encode())
+	require.NoError(t, err)
+
+	_, err = module.Instantiate(nil, Config{MaxMemoryPages: 2})
+	assert.EqualError(t, err, "wasm: memory of 3 pages, more than the limit of 2")
+
+	instance, err := module.Instantiate(nil, Config{MaxMemoryPages: 3})
+	require.NoError(t, err)
+	assert.Len(t, instance.Memory(), 3*PageSize)
+	assert.True(t, instance.Write(3*PageSize-2, []byte("ok")))
+	assert.False(t, instance.Write(3*PageSize-1, []byte("ok")))
+	b, ok := instance.Read(3*PageSize-2, 2)
+	assert.True(t, ok)
+	assert.Equal(t, "ok", string(b))
+	_, ok = instance.Read(math.MaxUint32, 2)
+	assert.False(t, ok)
+}
+
+func TestInstantiateStart(t *testing.T) {
+	start := uint32(0)
+	module, err := Compile(testModule{
+		start:   &start,
+		globals: []testGlobal{{typ: I32, mutable: true, init: i32Const(0)}},
+		functions: []testFunction{
+			{typ: signature(nil), body: code(i32Const(42), opGlobalSet, 0)},
+			{typ: signature(nil, I32), body: code(opGlobalGet, 0), export: "get"},
+		},
+	}.encode())
+	require.NoError(t, err)
+
+	instance, err := module.Instantiate(nil, Config{})
+	require.NoError(t, err)
+	results, err := instance.Call("get")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{42}, results)
+}
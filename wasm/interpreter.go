@@ -0,0 +1,321 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+var errOutOfBounds = &Trap{Reason: "out of bounds memory access"}
+
+// address returns the effective address of an access of size bytes at the base address of the stack and the offset,
+// or false when it is out of the memory
+func address(base uint64, offset uint64, size uint64, memory []byte) (uint64, bool) {
+	ea := uint64(uint32(base)) + offset
+	return ea, ea+size <= uint64(len(memory))
+}
+
+// execute runs the function with its arguments at the top of the value stack, and leaves its results
+// at their place
+func (inst *Instance) execute(fn *function) error {
+	stack := inst.stack[:cap(inst.stack)]
+	sp := len(inst.stack)
+	frames := inst.frames
+	memory := inst.memory
+	fuel := inst.maxInstructions
+	hostFunctions := len(inst.hostFunctions)
+
+	// ensure makes room on the value stack for n values
+	ensure := func(n int) *Trap {
+		if n <= len(stack) {
+			return nil
+		}
+		if n > maxStackValues {
+			return trap("call stack exhausted")
+		}
+		size := 2 * len(stack)
+		if size < n {
+			size = n
+		}
+		grown := make([]uint64, size)
+		copy(grown, stack[:sp])
+		stack = grown
+		return nil
+	}
+
+	base := sp - len(fn.typ.Params)
+	if err := ensure(base + fn.numLocals + fn.maxHeight); err != nil {
+		return err
+	}
+	for i := sp; i < base+fn.numLocals; i++ {
+		stack[i] = 0
+	}
+	sp = base + fn.numLocals
+	operands := sp
+	code := fn.code
+	pc := 0
+
+	for {
+		if fuel == 0 {
+			return trap("instruction limit exceeded")
+		}
+		fuel--
+		in := &code[pc]
+		pc++
+
+		switch in.opcode {
+		case opUnreachable:
+			return trap("unreachable")
+
+		case opIf:
+			sp--
+			if uint32(stack[sp]) == 0 {
+				pc = int(in.target)
+			}
+		case opElse:
+			pc = int(in.target)
+		case opBr:
+			height := operands + int(in.height)
+			copy(stack[height:], stack[sp-int(in.arity):sp])
+			sp = height + int(in.arity)
+			pc = int(in.target)
+		case opBrIf:
+			sp--
+			if uint32(stack[sp]) != 0 {
+				height := operands + int(in.height)
+				copy(stack[height:], stack[sp-int(in.arity):sp])
+				sp = height + int(in.arity)
+				pc = int(in.target)
+			}
+		case opBrTable:
+			sp--
+			table := fn.brTables[in.value]
+			i := uint64(uint32(stack[sp]))
+			if i >= uint64(len(table)) {
+				i = uint64(len(table) - 1)
+			}
+			b := table[i]
+			height := operands + int(b.height)
+			copy(stack[height:], stack[sp-int(b.arity):sp])
+			sp = height + int(b.arity)
+			pc = int(b.target)
+
+		case opReturn:
+			copy(stack[base:], stack[sp-int(in.arity):sp])
+			sp = base + int(in.arity)
+			if len(frames) == 0 {
+				inst.stack = stack[:sp]
+				inst.frames = frames
+				return nil
+			}
+			caller := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+			fn, pc, base = caller.fn, caller.pc, caller.base
+			code = fn.code
+			operands = base + fn.numLocals
+
+		case opCall, opCallIndirect:
+			index := in.target
+			if in.opcode == opCallIndirect {
+				sp--
+				element := uint64(uint32(stack[sp]))
+				if element >= uint64(len(inst.table)) {
+					return trap("undefined element")
+				}
+				if inst.table[element] < 0 {
+					return trap("uninitialized element")
+				}
+				index = uint32(inst.table[element])
+				if !inst.functionType(index).equal(inst.module.types[in.target]) {
+					return trap("indirect call type mismatch")
+				}
+			}
+
+			if int(index) < hostFunctions {
+				hostFunction := inst.hostFunctions[index]
+				params, results := len(hostFunction.Type.Params), len(hostFunction.Type.Results)
+				size := params
+				if results > size {
+					size = results
+				}
+				if err := ensure(sp - params + size); err != nil {
+					return err
+				}
+				if err := hostFunction.Call(inst, stack[sp-params:sp-params+size]); err != nil {
+					return err
+				}
+				memory = inst.memory
+				sp += results - params
+				continue
+			}
+
+			if len(frames) >= maxCallDepth {
+				return trap("call stack exhausted")
+			}
+			frames = append(frames, callFrame{fn: fn, pc: pc, base: base})
+			fn = inst.module.functions[int(index)-hostFunctions]
+			base = sp - len(fn.typ.Params)
+			if err := ensure(base + fn.numLocals + fn.maxHeight); err != nil {
+				return err
+			}
+			for i := sp; i < base+fn.numLocals; i++ {
+				stack[i] = 0
+			}
+			sp = base + fn.numLocals
+			operands = sp
+			code = fn.code
+			pc = 0
+
+		case opDrop:
+			sp--
+		case opSelect:
+			sp -= 2
+			if uint32(stack[sp+1]) == 0 {
+				stack[sp-1] = stack[sp]
+			}
+
+		case opLocalGet:
+			stack[sp] = stack[base+int(in.value)]
+			sp++
+		case opLocalSet:
+			sp--
+			stack[base+int(in.value)] = stack[sp]
+		case opLocalTee:
+			stack[base+int(in.value)] = stack[sp-1]
+		case opGlobalGet:
+			stack[sp] = inst.globals[in.value]
+			sp++
+		case opGlobalSet:
+			sp--
+			inst.globals[in.value] = stack[sp]
+
+		case 0x28, 0x2A, 0x34, 0x35:
+			ea, ok := address(stack[sp-1], in.value, 4, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			v := binary.LittleEndian.Uint32(memory[ea:])
+			if in.opcode == 0x34 {
+				stack[sp-1] = uint64(int64(int32(v)))
+			} else {
+				stack[sp-1] = uint64(v)
+			}
+		case 0x29, 0x2B:
+			ea, ok := address(stack[sp-1], in.value, 8, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			stack[sp-1] = binary.LittleEndian.Uint64(memory[ea:])
+		case 0x2C, 0x2D, 0x30, 0x31:
+			ea, ok := address(stack[sp-1], in.value, 1, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			v := memory[ea]
+			switch in.opcode {
+			case 0x2C:
+				stack[sp-1] = uint64(uint32(int32(int8(v))))
+			case 0x30:
+				stack[sp-1] = uint64(int64(int8(v)))
+			default:
+				stack[sp-1] = uint64(v)
+			}
+		case 0x2E, 0x2F, 0x32, 0x33:
+			ea, ok := address(stack[sp-1], in.value, 2, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			v := binary.LittleEndian.Uint16(memory[ea:])
+			switch in.opcode {
+			case 0x2E:
+				stack[sp-1] = uint64(uint32(int32(int16(v))))
+			case 0x32:
+				stack[sp-1] = uint64(int64(int16(v)))
+			default:
+				stack[sp-1] = uint64(v)
+			}
+
+		case 0x36, 0x38, 0x3E:
+			ea, ok := address(stack[sp-2], in.value, 4, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			binary.LittleEndian.PutUint32(memory[ea:], uint32(stack[sp-1]))
+			sp -= 2
+		case 0x37, 0x39:
+			ea, ok := address(stack[sp-2], in.value, 8, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			binary.LittleEndian.PutUint64(memory[ea:], stack[sp-1])
+			sp -= 2
+		case 0x3A, 0x3C:
+			ea, ok := address(stack[sp-2], in.value, 1, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			memory[ea] = byte(stack[sp-1])
+			sp -= 2
+		case 0x3B, 0x3D:
+			ea, ok := address(stack[sp-2], in.value, 2, memory)
+			if !ok {
+				return errOutOfBounds
+			}
+			binary.LittleEndian.PutUint16(memory[ea:], uint16(stack[sp-1]))
+			sp -= 2
+
+		case opMemorySize:
+			stack[sp] = uint64(len(memory) / PageSize)
+			sp++
+		case opMemoryGrow:
+			pages := uint64(len(memory) / PageSize)
+			delta := uint64(uint32(stack[sp-1]))
+			if pages+delta > uint64(inst.maxPages) {
+				stack[sp-1] = math.MaxUint32
+				break
+			}
+			memory = append(memory, make([]byte, delta*PageSize)...)
+			inst.memory = memory
+			stack[sp-1] = pages
+
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			stack[sp] = in.value
+			sp++
+
+		case opMemoryInit:
+			n, s, d := uint64(uint32(stack[sp-1])), uint64(uint32(stack[sp-2])), uint64(uint32(stack[sp-3]))
+			sp -= 3
+			var data []byte
+			if !inst.droppedData[in.target] {
+				data = inst.module.data[in.target].init
+			}
+			if s+n > uint64(len(data)) || d+n > uint64(len(memory)) {
+				return errOutOfBounds
+			}
+			copy(memory[d:], data[s:s+n])
+		case opDataDrop:
+			inst.droppedData[in.target] = true
+		case opMemoryCopy:
+			n, s, d := uint64(uint32(stack[sp-1])), uint64(uint32(stack[sp-2])), uint64(uint32(stack[sp-3]))
+			sp -= 3
+			if s+n > uint64(len(memory)) || d+n > uint64(len(memory)) {
+				return errOutOfBounds
+			}
+			copy(memory[d:d+n], memory[s:s+n])
+		case opMemoryFill:
+			n, v, d := uint64(uint32(stack[sp-1])), byte(stack[sp-2]), uint64(uint32(stack[sp-3]))
+			sp -= 3
+			if d+n > uint64(len(memory)) {
+				return errOutOfBounds
+			}
+			for i := d; i < d+n; i++ {
+				memory[i] = v
+			}
+
+		default:
+			var err *Trap
+			if sp, err = numeric(in.opcode, stack, sp); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,657 @@
+// Package wasm implements a sandboxed interpreter of WebAssembly modules, for the middlewares written in any language
+// compiled to WebAssembly.
+//
+// The modules are decoded and validated once by Compile, and instantiated any number of times. An instance can only
+// call the host functions it is instantiated with, its memory is limited, and each call of its exported functions
+// is limited in instructions: the traps end the call with an error, without affecting the host.
+//
+// The supported features are the ones of WebAssembly 1.0, with the sign extension operators, the non-trapping
+// float-to-int conversions, the bulk memory operations and the multiple values.
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// ValueType is the type of the values of the modules
+type ValueType byte
+
+// The value types
+const (
+	I32 ValueType = 0x7F
+	I64 ValueType = 0x7E
+	F32 ValueType = 0x7D
+	F64 ValueType = 0x7C
+
+	// unknown is the type of the values of the unreachable code, matching any type
+	unknown ValueType = 0
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	}
+	return "unknown"
+}
+
+// FunctionType is the type of a function, by the types of its parameters and results
+type FunctionType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+func (t FunctionType) equal(other FunctionType) bool {
+	return equalValueTypes(t.Params, other.Params) && equalValueTypes(t.Results, other.Results)
+}
+
+func (t FunctionType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+func equalValueTypes(a, b []ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	magic   = "\x00asm"
+	version = "\x01\x00\x00\x00"
+
+	// PageSize is the size of the pages of the memories
+	PageSize = 65536
+	maxPages = 65536
+
+	// maxLocals is the number of locals of a function
+	maxLocals = 50000
+
+	sectionCustom    = 0
+	sectionType      = 1
+	sectionImport    = 2
+	sectionFunction  = 3
+	sectionTable     = 4
+	sectionMemory    = 5
+	sectionGlobal    = 6
+	sectionExport    = 7
+	sectionStart     = 8
+	sectionElement   = 9
+	sectionCode      = 10
+	sectionData      = 11
+	sectionDataCount = 12
+
+	externalFunction = 0
+	externalTable    = 1
+	externalMemory   = 2
+	externalGlobal   = 3
+
+	functionReference = 0x70
+)
+
+// Module is a decoded and validated module, instantiated by Instantiate. It is safe for concurrent use.
+type Module struct {
+	types     []FunctionType
+	imports   []functionImport
+	functions []*function
+	table     *limits
+	memory    *limits
+	globals   []global
+	exports   map[string]export
+	start     int
+	elements  []element
+	data      []dataSegment
+	dataCount int
+}
+
+type functionImport struct {
+	module    string
+	name      string
+	typeIndex uint32
+}
+
+// function is a function defined by the module, with its compiled code
+type function struct {
+	typ       FunctionType
+	numLocals int // the parameters included
+	maxHeight int
+	code      []instruction
+	brTables  [][]branch
+}
+
+type limits struct {
+	min    uint32
+	max    uint32
+	hasMax bool
+}
+
+type global struct {
+	typ     ValueType
+	mutable bool
+	init    constantExpression
+}
+
+type export struct {
+	kind  byte
+	index uint32
+}
+
+// element is a segment of function indexes, -1 being the null reference, written to the table on instantiation
+// when it is active
+type element struct {
+	active    bool
+	offset    constantExpression
+	functions []int64
+}
+
+// dataSegment is a segment of bytes, written to the memory on instantiation when it is active
+type dataSegment struct {
+	active bool
+	offset constantExpression
+	init   []byte
+}
+
+// constantExpression is a constant, or the value of a global, initializing a global or the offset of a segment
+type constantExpression struct {
+	opcode byte
+	value  uint64
+}
+
+// Compile decodes and validates the binary module
+func Compile(binary []byte) (*Module, error) {
+	r := &reader{data: binary}
+	if string(r.bytes(4)) != magic || string(r.bytes(4)) != version {
+		return nil, errors.New("wasm: not a WebAssembly 1.0 binary module")
+	}
+
+	m := &Module{exports: make(map[string]export), start: -1, dataCount: -1}
+	var functionTypes []uint32
+	var codes []*reader
+	lastSection := byte(0)
+	for r.err == nil && r.pos < len(r.data) {
+		id := r.byte()
+		section := &reader{data: r.bytes(int(r.u32()))}
+		if r.err != nil {
+			break
+		}
+		if id != sectionCustom {
+			// the data count section comes between the element and code sections
+			order := map[byte]int{sectionDataCount: sectionElement*2 + 1}
+			rank := func(id byte) int {
+				if o, ok := order[id]; ok {
+					return o
+				}
+				return int(id) * 2
+			}
+			if id > sectionDataCount || (lastSection != 0 && rank(id) <= rank(lastSection)) {
+				return nil, fmt.Errorf("wasm: unexpected section %d", id)
+			}
+			lastSection = id
+		}
+
+		switch id {
+		case sectionCustom:
+		case sectionType:
+			for i, n := 0, section.count(); i < n; i++ {
+				if section.byte() != 0x60 {
+					return nil, errors.New("wasm: invalid function type")
+				}
+				m.types = append(m.types, FunctionType{Params: section.valueTypes(), Results: section.valueTypes()})
+			}
+		case sectionImport:
+			for i, n := 0, section.count(); i < n; i++ {
+				module, name := section.name(), section.name()
+				if kind := section.byte(); kind != externalFunction {
+					return nil, fmt.Errorf("wasm: unsupported import %s.%s: only functions can be imported", module, name)
+				}
+				typeIndex := section.u32()
+				if section.err == nil && int(typeIndex) >= len(m.types) {
+					return nil, fmt.Errorf("wasm: import %s.%s of unknown type %d", module, name, typeIndex)
+				}
+				m.imports = append(m.imports, functionImport{module: module, name: name, typeIndex: typeIndex})
+			}
+		case sectionFunction:
+			for i, n := 0, section.count(); i < n; i++ {
+				typeIndex := section.u32()
+				if section.err == nil && int(typeIndex) >= len(m.types) {
+					return nil, fmt.Errorf("wasm: function of unknown type %d", typeIndex)
+				}
+				functionTypes = append(functionTypes, typeIndex)
+			}
+		case sectionTable:
+			for i, n := 0, section.count(); i < n; i++ {
+				if m.table != nil {
+					return nil, errors.New("wasm: multiple tables")
+				}
+				if section.byte() != functionReference {
+					return nil, errors.New("wasm: unsupported table element type")
+				}
+				table := section.limits(math.MaxUint32)
+				m.table = &table
+			}
+		case sectionMemory:
+			for i, n := 0, section.count(); i < n; i++ {
+				if m.memory != nil {
+					return nil, errors.New("wasm: multiple memories")
+				}
+				memory := section.limits(maxPages)
+				m.memory = &memory
+			}
+		case sectionGlobal:
+			for i, n := 0, section.count(); i < n; i++ {
+				g := global{typ: section.valueType()}
+				switch section.byte() {
+				case 0:
+				case 1:
+					g.mutable = true
+				default:
+					return nil, errors.New("wasm: invalid global mutability")
+				}
+				var err error
+				if g.init, err = m.constantExpression(section, g.typ, len(m.globals)); err != nil {
+					return nil, err
+				}
+				m.globals = append(m.globals, g)
+			}
+		case sectionExport:
+			for i, n := 0, section.count(); i < n; i++ {
+				name := section.name()
+				e := export{kind: section.byte(), index: section.u32()}
+				if section.err != nil {
+					break
+				}
+				if _, ok := m.exports[name]; ok {
+					return nil, fmt.Errorf("wasm: duplicate export %s", name)
+				}
+				if err := m.checkExport(e, len(functionTypes)); err != nil {
+					return nil, fmt.Errorf("wasm: export %s: %v", name, err)
+				}
+				m.exports[name] = e
+			}
+		case sectionStart:
+			index := section.u32()
+			if section.err == nil {
+				if int(index) >= len(m.imports)+len(functionTypes) {
+					return nil, fmt.Errorf("wasm: unknown start function %d", index)
+				}
+				if t := m.functionType(index, functionTypes); len(t.Params) > 0 || len(t.Results) > 0 {
+					return nil, fmt.Errorf("wasm: start function of type %v", t)
+				}
+				m.start = int(index)
+			}
+		case sectionElement:
+			for i, n := 0, section.count(); i < n; i++ {
+				e, err := m.element(section, len(m.imports)+len(functionTypes))
+				if err != nil {
+					return nil, err
+				}
+				m.elements = append(m.elements, e)
+			}
+		case sectionDataCount:
+			m.dataCount = section.count()
+		case sectionCode:
+			n := section.count()
+			if section.err == nil && n != len(functionTypes) {
+				return nil, errors.New("wasm: function and code sections of different lengths")
+			}
+			for i := 0; i < n; i++ {
+				codes = append(codes, &reader{data: section.bytes(int(section.u32()))})
+			}
+		case sectionData:
+			n := section.count()
+			if section.err == nil && m.dataCount >= 0 && n != m.dataCount {
+				return nil, errors.New("wasm: data count and data sections of different lengths")
+			}
+			for i := 0; i < n; i++ {
+				d, err := m.dataSegment(section)
+				if err != nil {
+					return nil, err
+				}
+				m.data = append(m.data, d)
+			}
+		}
+		if section.err != nil {
+			return nil, fmt.Errorf("wasm: section %d: %v", id, section.err)
+		}
+		if id != sectionCustom && section.pos != len(section.data) {
+			return nil, fmt.Errorf("wasm: section %d longer than its content", id)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("wasm: %v", r.err)
+	}
+	if len(codes) != len(functionTypes) {
+		return nil, errors.New("wasm: functions without code")
+	}
+
+	for _, typeIndex := range functionTypes {
+		m.functions = append(m.functions, &function{typ: m.types[typeIndex]})
+	}
+	for i, code := range codes {
+		if err := m.compileFunction(m.functions[i], code); err != nil {
+			return nil, fmt.Errorf("wasm: function %d: %v", len(m.imports)+i, err)
+		}
+	}
+	return m, nil
+}
+
+// functionType returns the type of the function of the index space, the defined functions having the types
+// of their indexes
+func (m *Module) functionType(index uint32, functionTypes []uint32) FunctionType {
+	if int(index) < len(m.imports) {
+		return m.types[m.imports[index].typeIndex]
+	}
+	return m.types[functionTypes[int(index)-len(m.imports)]]
+}
+
+func (m *Module) checkExport(e export, functions int) error {
+	switch e.kind {
+	case externalFunction:
+		if int(e.index) >= len(m.imports)+functions {
+			return fmt.Errorf("unknown function %d", e.index)
+		}
+	case externalTable:
+		if m.table == nil || e.index != 0 {
+			return fmt.Errorf("unknown table %d", e.index)
+		}
+	case externalMemory:
+		if m.memory == nil || e.index != 0 {
+			return fmt.Errorf("unknown memory %d", e.index)
+		}
+	case externalGlobal:
+		if int(e.index) >= len(m.globals) {
+			return fmt.Errorf("unknown global %d", e.index)
+		}
+	default:
+		return fmt.Errorf("invalid kind %d", e.kind)
+	}
+	return nil
+}
+
+// constantExpression reads a constant expression of the type, which can read the immutable globals preceding
+// the first ones
+func (m *Module) constantExpression(r *reader, typ ValueType, globals int) (constantExpression, error) {
+	e := constantExpression{opcode: r.byte()}
+	var actual ValueType
+	switch e.opcode {
+	case opI32Const:
+		e.value, actual = uint64(uint32(r.s32())), I32
+	case opI64Const:
+		e.value, actual = uint64(r.s64()), I64
+	case opF32Const:
+		e.value, actual = uint64(r.u32le()), F32
+	case opF64Const:
+		e.value, actual = r.u64le(), F64
+	case opGlobalGet:
+		index := r.u32()
+		if r.err == nil && int(index) >= globals {
+			return e, fmt.Errorf("wasm: constant expression of unknown global %d", index)
+		}
+		if r.err == nil && m.globals[index].mutable {
+			return e, fmt.Errorf("wasm: constant expression of mutable global %d", index)
+		}
+		if r.err == nil {
+			e.value, actual = uint64(index), m.globals[index].typ
+		}
+	default:
+		return e, fmt.Errorf("wasm: unsupported constant expression opcode %#x", e.opcode)
+	}
+	if r.byte() != opEnd {
+		return e, errors.New("wasm: constant expression not ended")
+	}
+	if r.err == nil && actual != typ {
+		return e, fmt.Errorf("wasm: constant expression of type %v instead of %v", actual, typ)
+	}
+	return e, r.err
+}
+
+// element reads an element segment of function indexes, of any of the encodings of the segments of the table 0
+func (m *Module) element(r *reader, functions int) (element, error) {
+	flags := r.u32()
+	if flags > 7 {
+		return element{}, fmt.Errorf("wasm: invalid element segment flags %d", flags)
+	}
+	e := element{active: flags&1 == 0}
+	if e.active {
+		if flags&2 != 0 && r.u32() != 0 {
+			return e, errors.New("wasm: element segment of unknown table")
+		}
+		if m.table == nil {
+			return e, errors.New("wasm: element segment without table")
+		}
+		var err error
+		if e.offset, err = m.constantExpression(r, I32, len(m.globals)); err != nil {
+			return e, err
+		}
+	}
+	expressions := flags&4 != 0
+	if flags&3 != 0 {
+		// the element kind, or the reference type of the expressions
+		if kind := r.byte(); (expressions && kind != functionReference) || (!expressions && kind != 0) {
+			return e, errors.New("wasm: unsupported element kind")
+		}
+	}
+	for i, n := 0, r.count(); i < n; i++ {
+		index := int64(-1)
+		if !expressions {
+			index = int64(r.u32())
+		} else {
+			switch r.byte() {
+			case opRefFunc:
+				index = int64(r.u32())
+			case opRefNull:
+				if r.byte() != functionReference {
+					return e, errors.New("wasm: invalid null reference type")
+				}
+			default:
+				return e, errors.New("wasm: unsupported element expression")
+			}
+			if r.byte() != opEnd {
+				return e, errors.New("wasm: element expression not ended")
+			}
+		}
+		if r.err == nil && index >= int64(functions) {
+			return e, fmt.Errorf("wasm: element segment of unknown function %d", index)
+		}
+		e.functions = append(e.functions, index)
+	}
+	return e, r.err
+}
+
+func (m *Module) dataSegment(r *reader) (dataSegment, error) {
+	var d dataSegment
+	switch flags := r.u32(); flags {
+	case 0, 2:
+		if flags == 2 && r.u32() != 0 {
+			return d, errors.New("wasm: data segment of unknown memory")
+		}
+		if m.memory == nil {
+			return d, errors.New("wasm: data segment without memory")
+		}
+		d.active = true
+		var err error
+		if d.offset, err = m.constantExpression(r, I32, len(m.globals)); err != nil {
+			return d, err
+		}
+	case 1:
+	default:
+		return d, fmt.Errorf("wasm: invalid data segment flags %d", flags)
+	}
+	d.init = r.bytes(int(r.u32()))
+	return d, r.err
+}
+
+// reader reads the binary encoding of the modules, its error being kept once a read fails
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+var errUnexpectedEnd = errors.New("unexpected end")
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.fail(errUnexpectedEnd)
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.fail(errUnexpectedEnd)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// leb128 reads an integer of the size in bits, sign extended when signed
+func (r *reader) leb128(size uint, signed bool) uint64 {
+	var value uint64
+	var shift uint
+	for {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		if shift+7 >= size {
+			// the unused bits of the last byte must be the sign extension, or zeros
+			remaining := size - shift
+			if b&0x80 != 0 {
+				r.fail(errors.New("integer too long"))
+				return 0
+			}
+			unused := b >> remaining
+			if signed && remaining < 7 && b&(1<<(remaining-1)) != 0 {
+				if unused != 0x7F>>remaining {
+					r.fail(errors.New("integer too large"))
+					return 0
+				}
+			} else if unused != 0 {
+				r.fail(errors.New("integer too large"))
+				return 0
+			}
+		}
+		value |= uint64(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if signed && shift < 64 && b&0x40 != 0 {
+				value |= math.MaxUint64 << shift
+			}
+			return value
+		}
+	}
+}
+
+func (r *reader) u32() uint32 {
+	return uint32(r.leb128(32, false))
+}
+
+func (r *reader) s32() int32 {
+	return int32(r.leb128(32, true))
+}
+
+func (r *reader) s33() int64 {
+	return int64(r.leb128(33, true))
+}
+
+func (r *reader) s64() int64 {
+	return int64(r.leb128(64, true))
+}
+
+func (r *reader) u32le() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func (r *reader) u64le() uint64 {
+	low := r.u32le()
+	return uint64(low) | uint64(r.u32le())<<32
+}
+
+// count reads the length of a vector, bounded by the bytes left for its elements
+func (r *reader) count() int {
+	n := r.u32()
+	if r.err == nil && int64(n) > int64(len(r.data)-r.pos) {
+		r.fail(errUnexpectedEnd)
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) name() string {
+	b := r.bytes(int(r.u32()))
+	if r.err == nil && !utf8.Valid(b) {
+		r.fail(errors.New("invalid UTF-8 name"))
+	}
+	return string(b)
+}
+
+func (r *reader) valueType() ValueType {
+	t := ValueType(r.byte())
+	switch t {
+	case I32, I64, F32, F64:
+	default:
+		r.fail(fmt.Errorf("unsupported value type %#x", byte(t)))
+	}
+	return t
+}
+
+func (r *reader) valueTypes() []ValueType {
+	n := r.count()
+	types := make([]ValueType, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		types = append(types, r.valueType())
+	}
+	return types
+}
+
+func (r *reader) limits(bound uint32) limits {
+	var l limits
+	switch r.byte() {
+	case 0:
+		l.min = r.u32()
+	case 1:
+		l.min, l.max, l.hasMax = r.u32(), r.u32(), true
+	default:
+		r.fail(errors.New("invalid limits"))
+	}
+	if r.err == nil && (l.min > bound || (l.hasMax && (l.max > bound || l.max < l.min))) {
+		r.fail(errors.New("invalid limits"))
+	}
+	return l
+}
@@ -0,0 +1,391 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testModule is a module encoded in the binary format by the tests
+type testModule struct {
+	imports   []testImport
+	functions []testFunction
+	table     []uint32
+	memory    []uint32
+	globals   []testGlobal
+	data      []byte
+	start     *uint32
+}
+
+type testImport struct {
+	module, name string
+	typ          FunctionType
+}
+
+// testFunction is a function, its body without the final end
+type testFunction struct {
+	typ    FunctionType
+	locals []ValueType
+	body   []byte
+	export string
+}
+
+type testGlobal struct {
+	typ     ValueType
+	mutable bool
+	init    []byte
+}
+
+func (m testModule) encode() []byte {
+	var types [][]byte
+	typeIndex := func(t FunctionType) uint64 {
+		b := append([]byte{0x60}, vector(len(t.Params), valueTypesBytes(t.Params))...)
+		b = append(b, vector(len(t.Results), valueTypesBytes(t.Results))...)
+		for i, e := range types {
+			if string(e) == string(b) {
+				return uint64(i)
+			}
+		}
+		types = append(types, b)
+		return uint64(len(types) - 1)
+	}
+
+	var imports, functions, exports, code []byte
+	var numExports int
+	for _, i := range m.imports {
+		imports = append(imports, name(i.module)...)
+		imports = append(imports, name(i.name)...)
+		imports = append(imports, externalFunction)
+		imports = append(imports, uleb(typeIndex(i.typ))...)
+	}
+	for i, f := range m.functions {
+		functions = append(functions, uleb(typeIndex(f.typ))...)
+		if len(f.export) > 0 {
+			numExports++
+			exports = append(exports, name(f.export)...)
+			exports = append(exports, externalFunction)
+			exports = append(exports, uleb(uint64(len(m.imports)+i))...)
+		}
+		var body []byte
+		body = append(body, uleb(uint64(len(f.locals)))...)
+		for _, l := range f.locals {
+			body = append(body, 1, byte(l))
+		}
+		body = append(body, f.body...)
+		body = append(body, opEnd)
+		code = append(code, uleb(uint64(len(body)))...)
+		code = append(code, body...)
+	}
+
+	var sections []byte
+	var typesSection []byte
+	for _, t := range types {
+		typesSection = append(typesSection, t...)
+	}
+	sections = append(sections, section(sectionType, vector(len(types), typesSection))...)
+	if len(m.imports) > 0 {
+		sections = append(sections, section(sectionImport, vector(len(m.imports), imports))...)
+	}
+	sections = append(sections, section(sectionFunction, vector(len(m.functions), functions))...)
+	if m.table != nil {
+		sections = append(sections, section(sectionTable, append([]byte{1, functionReference, 0}, uleb(uint64(len(m.table)))...))...)
+	}
+	if m.memory != nil {
+		sections = append(sections, section(sectionMemory, append([]byte{1}, limitsBytes(m.memory)...))...)
+	}
+	if len(m.globals) > 0 {
+		var globals []byte
+		for _, g := range m.globals {
+			mutable := byte(0)
+			if g.mutable {
+				mutable = 1
+			}
+			globals = append(globals, byte(g.typ), mutable)
+			globals = append(globals, g.init...)
+			globals = append(globals, opEnd)
+		}
+		sections = append(sections, section(sectionGlobal, vector(len(m.globals), globals))...)
+	}
+	sections = append(sections, section(sectionExport, vector(numExports, exports))...)
+	if m.start != nil {
+		sections = append(sections, section(sectionStart, uleb(uint64(*m.start)))...)
+	}
+	if m.table != nil {
+		element := append([]byte{0}, i32Const(0)...)
+		element = append(element, opEnd)
+		var indexes []byte
+		for _, i := range m.table {
+			indexes = append(indexes, uleb(uint64(i))...)
+		}
+		element = append(element, vector(len(m.table), indexes)...)
+		sections = append(sections, section(sectionElement, vector(1, element))...)
+	}
+	sections = append(sections, section(sectionCode, vector(len(m.functions), code))...)
+	if m.data != nil {
+		data := append([]byte{0}, i32Const(0)...)
+		data = append(data, opEnd)
+		data = append(data, vector(len(m.data), m.data)...)
+		sections = append(sections, section(sectionData, vector(1, data))...)
+	}
+
+	return append([]byte{0, 'a', 's', 'm', 1, 0, 0, 0}, sections...)
+}
+
+func uleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func sleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func vector(n int, contents []byte) []byte {
+	return append(uleb(uint64(n)), contents...)
+}
+
+func section(id byte, contents []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(contents)))...), contents...)
+}
+
+func name(s string) []byte {
+	return vector(len(s), []byte(s))
+}
+
+func valueTypesBytes(types []ValueType) []byte {
+	var b []byte
+	for _, t := range types {
+		b = append(b, byte(t))
+	}
+	return b
+}
+
+func limitsBytes(l []uint32) []byte {
+	if len(l) == 1 {
+		return append([]byte{0}, uleb(uint64(l[0]))...)
+	}
+	return append(append([]byte{1}, uleb(uint64(l[0]))...), uleb(uint64(l[1]))...)
+}
+
+func i32Const(v int32) []byte {
+	return append([]byte{opI32Const}, sleb(int64(v))...)
+}
+
+func i64Const(v int64) []byte {
+	return append([]byte{opI64Const}, sleb(v)...)
+}
+
+func f64Const(v uint64) []byte {
+	b := make([]byte, 9)
+	b[0] = opF64Const
+	binary.LittleEndian.PutUint64(b[1:], v)
+	return b
+}
+
+// code concatenates the opcodes, given as integers, and the encoded instructions
+func code(parts ...interface{}) []byte {
+	var b []byte
+	for _, p := range parts {
+		switch p := p.(type) {
+		case int:
+			b = append(b, byte(p))
+		case byte:
+			b = append(b, p)
+		case []byte:
+			b = append(b, p...)
+		default:
+			panic(p)
+		}
+	}
+	return b
+}
+
+func signature(params []ValueType, results ...ValueType) FunctionType {
+	return FunctionType{Params: params, Results: results}
+}
+
+func TestCompile(t *testing.T) {
+	i32 := []ValueType{I32}
+
+	testCases := []struct {
+		desc          string
+		binary        []byte
+		expectedError string
+	}{
+		{
+			desc:   "empty module",
+			binary: []byte{0, 'a', 's', 'm', 1, 0, 0, 0},
+		},
+		{
+			desc:          "not a module",
+			binary:        []byte("<html>"),
+			expectedError: "wasm: not a WebAssembly 1.0 binary module",
+		},
+		{
+			desc:          "other version",
+			binary:        []byte{0, 'a', 's', 'm', 2, 0, 0, 0},
+			expectedError: "wasm: not a WebAssembly 1.0 binary module",
+		},
+		{
+			desc:          "truncated section",
+			binary:        []byte{0, 'a', 's', 'm', 1, 0, 0, 0, sectionType, 5, 1},
+			expectedError: "wasm: unexpected end",
+		},
+		{
+			desc: "sections out of order",
+			binary: append(testModule{functions: []testFunction{{}}}.encode(),
+				section(sectionType, vector(0, nil))...),
+			expectedError: "wasm: unexpected section 1",
+		},
+		{
+			desc: "valid function",
+			binary: testModule{functions: []testFunction{{
+				typ:    signature([]ValueType{I32, I32}, I32),
+				body:   code(opLocalGet, 0, opLocalGet, 1, 0x6A),
+				export: "add",
+			}}}.encode(),
+		},
+		{
+			desc: "type mismatch",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(i32, I32),
+				body: code(opLocalGet, 0, i64Const(1), 0x6A),
+			}}}.encode(),
+			expectedError: "wasm: function 0: type mismatch: i64 instead of i32",
+		},
+		{
+			desc: "empty operand stack",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(0x45),
+			}}}.encode(),
+			expectedError: "wasm: function 0: type mismatch: operand stack empty",
+		},
+		{
+			desc: "values left on the stack",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: i32Const(1),
+			}}}.encode(),
+			expectedError: "wasm: function 0: type mismatch: values left on the operand stack",
+		},
+		{
+			desc: "unknown local",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(i32, I32),
+				body: code(opLocalGet, 1),
+			}}}.encode(),
+			expectedError: "wasm: function 0: unknown local 1",
+		},
+		{
+			desc: "unknown label",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: code(opBr, 1),
+			}}}.encode(),
+			expectedError: "wasm: function 0: unknown label 1",
+		},
+		{
+			desc: "unknown function",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: code(opCall, 1),
+			}}}.encode(),
+			expectedError: "wasm: function 0: unknown function 1",
+		},
+		{
+			desc: "memory access without memory",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(i32Const(0), 0x28, 2, 0),
+			}}}.encode(),
+			expectedError: "wasm: function 0: memory instruction without memory",
+		},
+		{
+			desc: "alignment larger than the access",
+			binary: testModule{memory: []uint32{1}, functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(i32Const(0), 0x28, 3, 0),
+			}}}.encode(),
+			expectedError: "wasm: function 0: alignment 2^3 larger than the access",
+		},
+		{
+			desc: "immutable global set",
+			binary: testModule{
+				globals: []testGlobal{{typ: I32, init: i32Const(1)}},
+				functions: []testFunction{{
+					typ:  signature(nil),
+					body: code(i32Const(2), opGlobalSet, 0),
+				}},
+			}.encode(),
+			expectedError: "wasm: function 0: global 0 is immutable",
+		},
+		{
+			desc: "unsupported opcode",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil),
+				body: code(0xFD, 0),
+			}}}.encode(),
+			expectedError: "wasm: function 0: unsupported opcode 0xfd",
+		},
+		{
+			desc: "unreachable code is validated",
+			binary: testModule{functions: []testFunction{{
+				typ:  signature(nil, I32),
+				body: code(opUnreachable, 0x6A),
+			}}}.encode(),
+		},
+		{
+			desc: "memory bigger than the address space",
+			binary: testModule{memory: []uint32{65537}, functions: []testFunction{{
+				typ: signature(nil),
+			}}}.encode(),
+			expectedError: "wasm: section 5: invalid limits",
+		},
+		{
+			desc: "start function with parameters",
+			binary: testModule{start: new(uint32), functions: []testFunction{{
+				typ: signature(i32),
+			}}}.encode(),
+			expectedError: "wasm: start function of type [i32] -> []",
+		},
+		{
+			desc: "memory import",
+			binary: []byte{0, 'a', 's', 'm', 1, 0, 0, 0, sectionImport, 7, 1,
+				1, 'm', 1, 'm', externalMemory, 0, 1},
+			expectedError: "wasm: unsupported import m.m: only functions can be imported",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			module, err := Compile(test.binary)
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, module)
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package wasm
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	signBit32 = 1 << 31
+	signBit64 = 1 << 63
+)
+
+var (
+	errDivideByZero    = &Trap{Reason: "integer divide by zero"}
+	errIntegerOverflow = &Trap{Reason: "integer overflow"}
+)
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func f32(v uint64) float32 {
+	return math.Float32frombits(uint32(v))
+}
+
+func f32Value(f float32) uint64 {
+	return uint64(math.Float32bits(f))
+}
+
+func f64(v uint64) float64 {
+	return math.Float64frombits(v)
+}
+
+func f64Value(f float64) uint64 {
+	return math.Float64bits(f)
+}
+
+// nearest rounds to the nearest integer, ties to even, keeping the sign of the zeros
+func nearest(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	t := math.Trunc(f)
+	if d := math.Abs(f - t); d > 0.5 || (d == 0.5 && math.Mod(t, 2) != 0) {
+		t += math.Copysign(1, f)
+	}
+	return math.Copysign(t, f)
+}
+
+// truncate returns the float truncated to an integer, or a trap when it is NaN or out of [min, max)
+func truncate(f, min, max float64) (float64, *Trap) {
+	if math.IsNaN(f) {
+		return 0, trap("invalid conversion to integer")
+	}
+	f = math.Trunc(f)
+	if f < min || f >= max {
+		return 0, errIntegerOverflow
+	}
+	return f, nil
+}
+
+// saturate returns the float truncated to an integer and clamped to [min, max), 0 when it is NaN, and whether it is
+// below or above the bounds
+func saturate(f, min, max float64) (t float64, below, above bool) {
+	if math.IsNaN(f) {
+		return 0, false, false
+	}
+	t = math.Trunc(f)
+	return t, t < min, t >= max
+}
+
+func truncSatI32(f float64) uint64 {
+	t, below, above := saturate(f, math.MinInt32, 1<<31)
+	switch {
+	case below:
+		return signBit32
+	case above:
+		return math.MaxInt32
+	}
+	return uint64(uint32(int32(t)))
+}
+
+func truncSatU32(f float64) uint64 {
+	t, below, above := saturate(f, 0, 1<<32)
+	switch {
+	case below:
+		return 0
+	case above:
+		return math.MaxUint32
+	}
+	return uint64(uint32(t))
+}
+
+func truncSatI64(f float64) uint64 {
+	t, below, above := saturate(f, math.MinInt64, 1<<63)
+	switch {
+	case below:
+		return signBit64
+	case above:
+		return math.MaxInt64
+	}
+	return uint64(int64(t))
+}
+
+func truncSatU64(f float64) uint64 {
+	t, below, above := saturate(f, 0, 1<<64)
+	switch {
+	case below:
+		return 0
+	case above:
+		return math.MaxUint64
+	}
+	return uint64(t)
+}
+
+// u64ToF32 converts the unsigned integer with a single rounding, halving the ones the signed conversion can't convert
+func u64ToF32(v uint64) float32 {
+	if v < signBit64 {
+		return float32(int64(v))
+	}
+	return float32(int64(v>>1|v&1)) * 2
+}
+
+func u64ToF64(v uint64) float64 {
+	if v < signBit64 {
+		return float64(int64(v))
+	}
+	return float64(int64(v>>1|v&1)) * 2
+}
+
+// numeric executes the numeric instruction on the operands at the top of the stack, and returns the new stack pointer
+func numeric(opcode uint16, stack []uint64, sp int) (int, *Trap) {
+	x := stack[sp-1]
+	switch opcode {
+	// the unary instructions replace their operand
+	case 0x45:
+		stack[sp-1] = boolValue(uint32(x) == 0)
+	case 0x50:
+		stack[sp-1] = boolValue(x == 0)
+	case 0x67:
+		stack[sp-1] = uint64(bits.LeadingZeros32(uint32(x)))
+	case 0x68:
+		stack[sp-1] = uint64(bits.TrailingZeros32(uint32(x)))
+	case 0x69:
+		stack[sp-1] = uint64(bits.OnesCount32(uint32(x)))
+	case 0x79:
+		stack[sp-1] = uint64(bits.LeadingZeros64(x))
+	case 0x7A:
+		stack[sp-1] = uint64(bits.TrailingZeros64(x))
+	case 0x7B:
+		stack[sp-1] = uint64(bits.OnesCount64(x))
+
+	case 0x8B:
+		stack[sp-1] = x &^ signBit32
+	case 0x8C:
+		stack[sp-1] = x ^ signBit32
+	case 0x8D:
+		stack[sp-1] = f32Value(float32(math.Ceil(float64(f32(x)))))
+	case 0x8E:
+		stack[sp-1] = f32Value(float32(math.Floor(float64(f32(x)))))
+	case 0x8F:
+		stack[sp-1] = f32Value(float32(math.Trunc(float64(f32(x)))))
+	case 0x90:
+		stack[sp-1] = f32Value(float32(nearest(float64(f32(x)))))
+	case 0x91:
+		stack[sp-1] = f32Value(float32(math.Sqrt(float64(f32(x)))))
+	case 0x99:
+		stack[sp-1] = x &^ signBit64
+	case 0x9A:
+		stack[sp-1] = x ^ signBit64
+	case 0x9B:
+		stack[sp-1] = f64Value(math.Ceil(f64(x)))
+	case 0x9C:
+		stack[sp-1] = f64Value(math.Floor(f64(x)))
+	case 0x9D:
+		stack[sp-1] = f64Value(math.Trunc(f64(x)))
+	case 0x9E:
+		stack[sp-1] = f64Value(nearest(f64(x)))
+	case 0x9F:
+		stack[sp-1] = f64Value(math.Sqrt(f64(x)))
+
+	case 0xA7, 0xAD:
+		stack[sp-1] = uint64(uint32(x))
+	case 0xA8, 0xAA:
+		f := f64(x)
+		if opcode == 0xA8 {
+			f = float64(f32(x))
+		}
+		t, err := truncate(f, math.MinInt32, 1<<31)
+		if err != nil {
+			return sp, err
+		}
+		stack[sp-1] = uint64(uint32(int32(t)))
+	case 0xA9, 0xAB:
+		f := f64(x)
+		if opcode == 0xA9 {
+			f = float64(f32(x))
+		}
+		t, err := truncate(f, 0, 1<<32)
+		if err != nil {
+			return sp, err
+		}
+		stack[sp-1] = uint64(uint32(t))
+	case 0xAC:
+		stack[sp-1] = uint64(int64(int32(x)))
+	case 0xAE, 0xB0:
+		f := f64(x)
+		if opcode == 0xAE {
+			f = float64(f32(x))
+		}
+		t, err := truncate(f, math.MinInt64, 1<<63)
+		if err != nil {
+			return sp, err
+		}
+		stack[sp-1] = uint64(int64(t))
+	case 0xAF, 0xB1:
+		f := f64(x)
+		if opcode == 0xAF {
+			f = float64(f32(x))
+		}
+		t, err := truncate(f, 0, 1<<64)
+		if err != nil {
+			return sp, err
+		}
+		stack[sp-1] = uint64(t)
+	case 0xB2:
+		stack[sp-1] = f32Value(float32(int32(x)))
+	case 0xB3:
+		stack[sp-1] = f32Value(float32(uint32(x)))
+	case 0xB4:
+		stack[sp-1] = f32Value(float32(int64(x)))
+	case 0xB5:
+		stack[sp-1] = f32Value(u64ToF32(x))
+	case 0xB6:
+		stack[sp-1] = f32Value(float32(f64(x)))
+	case 0xB7:
+		stack[sp-1] = f64Value(float64(int32(x)))
+	case 0xB8:
+		stack[sp-1] = f64Value(float64(uint32(x)))
+	case 0xB9:
+		stack[sp-1] = f64Value(float64(int64(x)))
+	case 0xBA:
+		stack[sp-1] = f64Value(u64ToF64(x))
+	case 0xBB:
+		stack[sp-1] = f64Value(float64(f32(x)))
+	case 0xBC, 0xBD, 0xBE, 0xBF:
+		// the values are kept as their bits
+	case 0xC0:
+		stack[sp-1] = uint64(uint32(int32(int8(x))))
+	case 0xC1:
+		stack[sp-1] = uint64(uint32(int32(int16(x))))
+	case 0xC2:
+		stack[sp-1] = uint64(int64(int8(x)))
+	case 0xC3:
+		stack[sp-1] = uint64(int64(int16(x)))
+	case 0xC4:
+		stack[sp-1] = uint64(int64(int32(x)))
+	case 0xFC00:
+		stack[sp-1] = truncSatI32(float64(f32(x)))
+	case 0xFC01:
+		stack[sp-1] = truncSatU32(float64(f32(x)))
+	case 0xFC02:
+		stack[sp-1] = truncSatI32(f64(x))
+	case 0xFC03:
+		stack[sp-1] = truncSatU32(f64(x))
+	case 0xFC04:
+		stack[sp-1] = truncSatI64(float64(f32(x)))
+	case 0xFC05:
+		stack[sp-1] = truncSatU64(float64(f32(x)))
+	case 0xFC06:
+		stack[sp-1] = truncSatI64(f64(x))
+	case 0xFC07:
+		stack[sp-1] = truncSatU64(f64(x))
+
+	default:
+		// the binary instructions replace their first operand with their result
+		sp--
+		y, x := x, stack[sp-1]
+		result, err := binaryNumeric(opcode, x, y)
+		if err != nil {
+			return sp, err
+		}
+		stack[sp-1] = result
+	}
+	return sp, nil
+}
+
+func binaryNumeric(opcode uint16, x, y uint64) (uint64, *Trap) {
+	a, b := uint32(x), uint32(y)
+	switch opcode {
+	case 0x46:
+		return boolValue(a == b), nil
+	case 0x47:
+		return boolValue(a != b), nil
+	case 0x48:
+		return boolValue(int32(a) < int32(b)), nil
+	case 0x49:
+		return boolValue(a < b), nil
+	case 0x4A:
+		return boolValue(int32(a) > int32(b)), nil
+	case 0x4B:
+		return boolValue(a > b), nil
+	case 0x4C:
+		return boolValue(int32(a) <= int32(b)), nil
+	case 0x4D:
+		return boolValue(a <= b), nil
+	case 0x4E:
+		return boolValue(int32(a) >= int32(b)), nil
+	case 0x4F:
+		return boolValue(a >= b), nil
+
+	case 0x51:
+		return boolValue(x == y), nil
+	case 0x52:
+		return boolValue(x != y), nil
+	case 0x53:
+		return boolValue(int64(x) < int64(y)), nil
+	case 0x54:
+		return boolValue(x < y), nil
+	case 0x55:
+		return boolValue(int64(x) > int64(y)), nil
+	case 0x56:
+		return boolValue(x > y), nil
+	case 0x57:
+		return boolValue(int64(x) <= int64(y)), nil
+	case 0x58:
+		return boolValue(x <= y), nil
+	case 0x59:
+		return boolValue(int64(x) >= int64(y)), nil
+	case 0x5A:
+		return boolValue(x >= y), nil
+
+	case 0x5B:
+		return boolValue(f32(x) == f32(y)), nil
+	case 0x5C:
+		return boolValue(f32(x) != f32(y)), nil
+	case 0x5D:
+		return boolValue(f32(x) < f32(y)), nil
+	case 0x5E:
+		return boolValue(f32(x) > f32(y)), nil
+	case 0x5F:
+		return boolValue(f32(x) <= f32(y)), nil
+	case 0x60:
+		return boolValue(f32(x) >= f32(y)), nil
+	case 0x61:
+		return boolValue(f64(x) == f64(y)), nil
+	case 0x62:
+		return boolValue(f64(x) != f64(y)), nil
+	case 0x63:
+		return boolValue(f64(x) < f64(y)), nil
+	case 0x64:
+		return boolValue(f64(x) > f64(y)), nil
+	case 0x65:
+		return boolValue(f64(x) <= f64(y)), nil
+	case 0x66:
+		return boolValue(f64(x) >= f64(y)), nil
+
+	case 0x6A:
+		return uint64(a + b), nil
+	case 0x6B:
+		return uint64(a - b), nil
+	case 0x6C:
+		return uint64(a * b), nil
+	case 0x6D:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			return 0, errIntegerOverflow
+		}
+		return uint64(uint32(int32(a) / int32(b))), nil
+	case 0x6E:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return uint64(a / b), nil
+	case 0x6F:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		if int32(b) == -1 {
+			return 0, nil
+		}
+		return uint64(uint32(int32(a) % int32(b))), nil
+	case 0x70:
+		if b == 0 {
+			return 0, errDivideByZero
+		}
+		return uint64(a % b), nil
+	case 0x71:
+		return uint64(a & b), nil
+	case 0x72:
+		return uint64(a | b), nil
+	case 0x73:
+		return uint64(a ^ b), nil
+	case 0x74:
+		return uint64(a << (b & 31)), nil
+	case 0x75:
+		return uint64(uint32(int32(a) >> (b & 31))), nil
+	case 0x76:
+		return uint64(a >> (b & 31)), nil
+	case 0x77:
+		return uint64(bits.RotateLeft32(a, int(b&31))), nil
+	case 0x78:
+		return uint64(bits.RotateLeft32(a, -int(b&31))), nil
+
+	case 0x7C:
+		return x + y, nil
+	case 0x7D:
+		return x - y, nil
+	case 0x7E:
+		return x * y, nil
+	case 0x7F:
+		if y == 0 {
+			return 0, errDivideByZero
+		}
+		if int64(x) == math.MinInt64 && int64(y) == -1 {
+			return 0, errIntegerOverflow
+		}
+		return uint64(int64(x) / int64(y)), nil
+	case 0x80:
+		if y == 0 {
+			return 0, errDivideByZero
+		}
+		return x / y, nil
+	case 0x81:
+		if y == 0 {
+			return 0, errDivideByZero
+		}
+		if int64(y) == -1 {
+			return 0, nil
+		}
+		return uint64(int64(x) % int64(y)), nil
+	case 0x82:
+		if y == 0 {
+			return 0, errDivideByZero
+		}
+		return x % y, nil
+	case 0x83:
+		return x & y, nil
+	case 0x84:
+		return x | y, nil
+	case 0x85:
+		return x ^ y, nil
+	case 0x86:
+		return x << (y & 63), nil
+	case 0x87:
+		return uint64(int64(x) >> (y & 63)), nil
+	case 0x88:
+		return x >> (y & 63), nil
+	case 0x89:
+		return bits.RotateLeft64(x, int(y&63)), nil
+	case 0x8A:
+		return bits.RotateLeft64(x, -int(y&63)), nil
+
+	case 0x92:
+		return f32Value(f32(x) + f32(y)), nil
+	case 0x93:
+		return f32Value(f32(x) - f32(y)), nil
+	case 0x94:
+		return f32Value(f32(x) * f32(y)), nil
+	case 0x95:
+		return f32Value(f32(x) / f32(y)), nil
+	case 0x96:
+		return f32Value(float32(math.Min(float64(f32(x)), float64(f32(y))))), nil
+	case 0x97:
+		return f32Value(float32(math.Max(float64(f32(x)), float64(f32(y))))), nil
+	case 0x98:
+		return x&^signBit32 | y&signBit32, nil
+	case 0xA0:
+		return f64Value(f64(x) + f64(y)), nil
+	case 0xA1:
+		return f64Value(f64(x) - f64(y)), nil
+	case 0xA2:
+		return f64Value(f64(x) * f64(y)), nil
+	case 0xA3:
+		return f64Value(f64(x) / f64(y)), nil
+	case 0xA4:
+		return f64Value(math.Min(f64(x), f64(y))), nil
+	case 0xA5:
+		return f64Value(math.Max(f64(x), f64(y))), nil
+	case 0xA6:
+		return x&^signBit64 | y&signBit64, nil
+	}
+	return 0, trap("unsupported opcode %#x", opcode)
+}
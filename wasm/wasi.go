@@ -0,0 +1,221 @@
+package wasm
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WASIModule is the name of the module of the WASI functions imported by the modules compiled for WASI
+const WASIModule = "wasi_snapshot_preview1"
+
+// The WASI errors returned by the functions
+const (
+	wasiSuccess    = 0
+	wasiBadFile    = 8
+	wasiFault      = 21
+	wasiInvalid    = 28
+	wasiNoSystem   = 52
+	wasiSeekOnPipe = 70
+)
+
+// ExitError is the error of a call ended by the module exiting with proc_exit
+type ExitError struct {
+	Code uint32
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("wasm: module exited with code %d", e.Code)
+}
+
+// WASI returns the WASI functions of the sandbox of the modules compiled for WASI, to be imported as WASIModule:
+// the standard output and error are written to stdout and stderr, and the clocks and random bytes are the host
+// ones, but there are no arguments, environment variables nor files. The clock subscriptions of poll_oneoff expire
+// immediately, to not block the calls.
+func WASI(stdout, stderr io.Writer) map[string]HostFunction {
+	start := time.Now()
+	i32, i64 := I32, I64
+	function := func(params []ValueType, call func(inst *Instance, stack []uint64) uint32) HostFunction {
+		return HostFunction{
+			Type: FunctionType{Params: params, Results: []ValueType{i32}},
+			Call: func(inst *Instance, stack []uint64) error {
+				stack[0] = uint64(call(inst, stack))
+				return nil
+			},
+		}
+	}
+	empty := function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+		return writeUint32s(inst, uint32(stack[0]), 0, uint32(stack[1]), 0)
+	})
+	none := function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+		return wasiSuccess
+	})
+
+	return map[string]HostFunction{
+		"args_get":          none,
+		"args_sizes_get":    empty,
+		"environ_get":       none,
+		"environ_sizes_get": empty,
+		"clock_res_get": function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			if uint32(stack[0]) > 1 {
+				return wasiInvalid
+			}
+			return writeUint64(inst, uint32(stack[1]), 1)
+		}),
+		"clock_time_get": function([]ValueType{i32, i64, i32}, func(inst *Instance, stack []uint64) uint32 {
+			switch uint32(stack[0]) {
+			case 0:
+				return writeUint64(inst, uint32(stack[2]), uint64(time.Now().UnixNano()))
+			case 1:
+				return writeUint64(inst, uint32(stack[2]), uint64(time.Since(start)))
+			}
+			return wasiInvalid
+		}),
+		"fd_write": function([]ValueType{i32, i32, i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			var w io.Writer
+			switch uint32(stack[0]) {
+			case 1:
+				w = stdout
+			case 2:
+				w = stderr
+			default:
+				return wasiBadFile
+			}
+			iovs, ok := inst.Read(uint32(stack[1]), 8*uint32(stack[2]))
+			if !ok {
+				return wasiFault
+			}
+			written := uint32(0)
+			for i := 0; i < len(iovs); i += 8 {
+				b, ok := inst.Read(binary.LittleEndian.Uint32(iovs[i:]), binary.LittleEndian.Uint32(iovs[i+4:]))
+				if !ok {
+					return wasiFault
+				}
+				w.Write(b)
+				written += uint32(len(b))
+			}
+			return writeUint32s(inst, uint32(stack[3]), written, uint32(stack[3]), written)
+		}),
+		"fd_read": function([]ValueType{i32, i32, i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			if uint32(stack[0]) != 0 {
+				return wasiBadFile
+			}
+			// the standard input is empty
+			return writeUint32s(inst, uint32(stack[3]), 0, uint32(stack[3]), 0)
+		}),
+		"fd_seek": function([]ValueType{i32, i64, i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			if uint32(stack[0]) > 2 {
+				return wasiBadFile
+			}
+			return wasiSeekOnPipe
+		}),
+		"fd_close": function([]ValueType{i32}, func(inst *Instance, stack []uint64) uint32 {
+			if uint32(stack[0]) > 2 {
+				return wasiBadFile
+			}
+			return wasiSuccess
+		}),
+		"fd_fdstat_get": function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			if uint32(stack[0]) > 2 {
+				return wasiBadFile
+			}
+			// a character device, without flags, with the rights to read and write
+			stat := make([]byte, 24)
+			stat[0] = 2
+			binary.LittleEndian.PutUint64(stat[8:], 1<<1|1<<6)
+			if !inst.Write(uint32(stack[1]), stat) {
+				return wasiFault
+			}
+			return wasiSuccess
+		}),
+		"fd_fdstat_set_flags": function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			if uint32(stack[0]) > 2 {
+				return wasiBadFile
+			}
+			return wasiSuccess
+		}),
+		// there are no preopened directories
+		"fd_prestat_get": function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			return wasiBadFile
+		}),
+		"fd_prestat_dir_name": function([]ValueType{i32, i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			return wasiBadFile
+		}),
+		"poll_oneoff": function([]ValueType{i32, i32, i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			return pollOneoff(inst, uint32(stack[0]), uint32(stack[1]), uint32(stack[2]), uint32(stack[3]))
+		}),
+		"random_get": function([]ValueType{i32, i32}, func(inst *Instance, stack []uint64) uint32 {
+			b, ok := inst.Read(uint32(stack[0]), uint32(stack[1]))
+			if !ok {
+				return wasiFault
+			}
+			if _, err := rand.Read(b); err != nil {
+				return wasiNoSystem
+			}
+			return wasiSuccess
+		}),
+		"sched_yield": {
+			Type: FunctionType{Results: []ValueType{i32}},
+			Call: func(inst *Instance, stack []uint64) error {
+				stack[0] = wasiSuccess
+				return nil
+			},
+		},
+		"proc_exit": {
+			Type: FunctionType{Params: []ValueType{i32}},
+			Call: func(inst *Instance, stack []uint64) error {
+				return &ExitError{Code: uint32(stack[0])}
+			},
+		},
+	}
+}
+
+// pollOneoff answers the subscriptions: the clocks expire immediately, and the file descriptors are not pollable
+func pollOneoff(inst *Instance, in, out, subscriptions, eventsPointer uint32) uint32 {
+	if subscriptions == 0 {
+		return wasiInvalid
+	}
+	const subscriptionSize, eventSize = 48, 32
+	s, ok := inst.Read(in, subscriptions*subscriptionSize)
+	if !ok {
+		return wasiFault
+	}
+	events := make([]byte, subscriptions*eventSize)
+	for i := uint32(0); i < subscriptions; i++ {
+		subscription, event := s[i*subscriptionSize:], events[i*eventSize:]
+		copy(event, subscription[:8])
+		eventType := subscription[8]
+		event[10] = eventType
+		if eventType != 0 {
+			binary.LittleEndian.PutUint16(event[8:], wasiBadFile)
+		}
+	}
+	if !inst.Write(out, events) {
+		return wasiFault
+	}
+	return writeUint32s(inst, eventsPointer, subscriptions, eventsPointer, subscriptions)
+}
+
+func writeUint32s(inst *Instance, pointer1, value1, pointer2, value2 uint32) uint32 {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, value1)
+	if !inst.Write(pointer1, b) {
+		return wasiFault
+	}
+	binary.LittleEndian.PutUint32(b, value2)
+	if !inst.Write(pointer2, b) {
+		return wasiFault
+	}
+	return wasiSuccess
+}
+
+func writeUint64(inst *Instance, pointer uint32, value uint64) uint32 {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, value)
+	if !inst.Write(pointer, b) {
+		return wasiFault
+	}
+	return wasiSuccess
+}
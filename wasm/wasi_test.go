@@ -0,0 +1,70 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWASI(t *testing.T) {
+	// the iovec at 0 points to the 6 bytes of the message at 16, the number of bytes written being stored at 8
+	data := make([]byte, 22)
+	binary.LittleEndian.PutUint32(data, 16)
+	binary.LittleEndian.PutUint32(data[4:], 6)
+	copy(data[16:], "hello\n")
+
+	i32 := []ValueType{I32}
+	module, err := Compile(testModule{
+		imports: []testImport{
+			{module: WASIModule, name: "fd_write", typ: signature([]ValueType{I32, I32, I32, I32}, I32)},
+			{module: WASIModule, name: "random_get", typ: signature([]ValueType{I32, I32}, I32)},
+			{module: WASIModule, name: "fd_prestat_get", typ: signature([]ValueType{I32, I32}, I32)},
+			{module: WASIModule, name: "proc_exit", typ: signature(i32)},
+		},
+		memory: []uint32{1},
+		data:   data,
+		functions: []testFunction{
+			{
+				typ:    signature(i32, I32),
+				body:   code(opLocalGet, 0, i32Const(0), i32Const(1), i32Const(8), opCall, 0),
+				export: "write",
+			},
+			{typ: signature(nil, I32), body: code(i32Const(8), 0x28, 2, 0), export: "written"},
+			{typ: signature(nil, I32), body: code(i32Const(32), i32Const(16), opCall, 1), export: "random"},
+			{typ: signature(nil, I32), body: code(i32Const(PageSize), i32Const(1), opCall, 1), export: "random_out"},
+			{typ: signature(nil, I32), body: code(i32Const(3), i32Const(32), opCall, 2), export: "prestat"},
+			{typ: signature(nil), body: code(i32Const(3), opCall, 3), export: "exit"},
+		},
+	}.encode())
+	require.NoError(t, err)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	instance, err := module.Instantiate(Imports{WASIModule: WASI(stdout, stderr)}, Config{})
+	require.NoError(t, err)
+
+	call := func(name string, args ...uint64) uint64 {
+		results, err := instance.Call(name, args...)
+		require.NoError(t, err)
+		return results[0]
+	}
+
+	assert.Equal(t, uint64(wasiSuccess), call("write", 1))
+	assert.Equal(t, uint64(6), call("written"))
+	assert.Equal(t, uint64(wasiSuccess), call("write", 2))
+	assert.Equal(t, uint64(wasiBadFile), call("write", 3))
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Equal(t, "hello\n", stderr.String())
+
+	assert.Equal(t, uint64(wasiSuccess), call("random"))
+	random, _ := instance.Read(32, 16)
+	assert.NotEqual(t, make([]byte, 16), random)
+	assert.Equal(t, uint64(wasiFault), call("random_out"))
+
+	assert.Equal(t, uint64(wasiBadFile), call("prestat"))
+
+	_, err = instance.Call("exit")
+	assert.Equal(t, &ExitError{Code: 3}, err)
+}
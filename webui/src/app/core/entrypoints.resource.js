@@ -0,0 +1,14 @@
+'use strict';
+var angular = require('angular');
+
+var traefikCoreEntryPoints = 'traefik.core.entrypoints';
+module.exports = traefikCoreEntryPoints;
+
+angular
+  .module(traefikCoreEntryPoints, ['ngResource'])
+  .factory('EntryPoints', EntryPoints);
+
+  /** @ngInject */
+  function EntryPoints($resource) {
+    return $resource('../api/entrypoints');
+  }
@@ -10,7 +10,7 @@ angular
 
 /** @ngInject */
 function Providers($resource, $q) {
-  const resourceProvider = $resource('../api/providers');
+  const resourceProvider = $resource('../api/runtime');
   return {
     get: function () {
       return $q((resolve, reject) => {
@@ -0,0 +1,62 @@
+'use strict';
+
+/** @ngInject */
+function EntryPointsController($scope, $interval, $log, EntryPoints, Providers) {
+  const vm = this;
+
+  /**
+   * Count the frontends and the servers down of each entrypoint
+   *
+   * @param {Object} providers Providers from API, with their runtime status
+   */
+  function countProviders(providers) {
+    const counts = {};
+    for (let providerName in providers) {
+      if (providers.hasOwnProperty(providerName) && !providerName.startsWith('$')) {
+        const backends = {};
+        providers[providerName].backends.forEach(backend => backends[backend.backendId] = backend);
+
+        providers[providerName].frontends.forEach(frontend => {
+          (frontend.entryPoints || []).forEach(entryPointName => {
+            const count = counts[entryPointName] = counts[entryPointName] || {frontends: 0, serversDown: 0};
+            count.frontends++;
+
+            const backend = backends[frontend.backend];
+            if (backend) {
+              for (let serverId in backend.servers) {
+                if (backend.servers.hasOwnProperty(serverId) && backend.servers[serverId].status === 'down') {
+                  count.serversDown++;
+                }
+              }
+            }
+          });
+        });
+      }
+    }
+    vm.counts = counts;
+  }
+
+  function loadEntryPoints() {
+    EntryPoints.get(entryPoints => vm.entryPoints = entryPoints, error => {
+      vm.entryPoints = {};
+      $log.error(error);
+    });
+    Providers
+      .get()
+      .then(countProviders)
+      .catch(error => {
+        vm.counts = {};
+        $log.error(error);
+      });
+  }
+
+  loadEntryPoints();
+
+  const intervalId = $interval(loadEntryPoints, 2000);
+
+  $scope.$on('$destroy', function () {
+    $interval.cancel(intervalId);
+  });
+}
+
+module.exports = EntryPointsController;
@@ -0,0 +1,28 @@
+'use strict';
+var angular = require('angular');
+var traefikCoreEntryPoints = require('../../core/entrypoints.resource');
+var traefikCoreProvider = require('../../core/providers.resource');
+var EntryPointsController = require('./entrypoints.controller');
+
+var traefikSectionEntryPoints = 'traefik.section.entrypoints';
+module.exports = traefikSectionEntryPoints;
+
+angular
+  .module(traefikSectionEntryPoints, [
+    traefikCoreEntryPoints,
+    traefikCoreProvider
+  ])
+  .controller('EntryPointsController', EntryPointsController)
+  .config(config);
+
+  /** @ngInject */
+  function config($stateProvider) {
+
+    $stateProvider.state('entrypoints', {
+      url: '/entrypoints',
+      template: require('./entrypoints.html'),
+      controller: 'EntryPointsController',
+      controllerAs: 'entryPointsCtrl'
+    });
+
+  }
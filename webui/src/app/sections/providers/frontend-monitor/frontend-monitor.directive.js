@@ -8,7 +8,8 @@ function frontendMonitor() {
     controllerAs: 'frontendCtrl',
     bindToController: true,
     scope: {
-      frontend: '='
+      frontend: '=',
+      statistics: '='
     }
   };
 }
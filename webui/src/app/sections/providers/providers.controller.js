@@ -18,8 +18,25 @@ function ProvidersController($scope, $interval, $log, Providers) {
 
   const intervalId = $interval(loadProviders, 2000);
 
+  // live statistics of the frontends, when the statistics are enabled
+  let statisticsSource = null;
+  if (typeof EventSource !== 'undefined') {
+    statisticsSource = new EventSource('../api/statistics/stream');
+    statisticsSource.onmessage = event => {
+      $scope.$apply(() => vm.statistics = JSON.parse(event.data));
+    };
+    statisticsSource.onerror = () => {
+      if (!vm.statistics) {
+        statisticsSource.close();
+      }
+    };
+  }
+
   $scope.$on('$destroy', function () {
     $interval.cancel(intervalId);
+    if (statisticsSource) {
+      statisticsSource.close();
+    }
   });
 }
 
@@ -4,6 +4,7 @@ require('nvd3');
 var ndv3 = require('angular-nvd3');
 var traefikSectionHealth = require('./health/health.module');
 var traefikSectionProviders = require('./providers/providers.module');
+var traefikSectionEntryPoints = require('./entrypoints/entrypoints.module');
 
 var traefikSection = 'traefik.section';
 module.exports = traefikSection;
@@ -14,6 +15,7 @@ angular
     'ui.bootstrap',
     ndv3,
     traefikSectionProviders,
+    traefikSectionEntryPoints,
     traefikSectionHealth
    ])
   .config(config);